@@ -131,9 +131,34 @@ func main() {
 	clusterFailoverService := services.NewClusterFailover()
 	clusterFailoverService.Start()
 
-	// Start radacct archival service (keeps main table small for performance)
-	radAcctArchivalService := services.NewRadAcctArchivalService(90) // Keep 90 days
-	radAcctArchivalService.Start()
+	// Partition radacct by month (one-time conversion of the plain table) and keep partitions:
+	// expired months are detached and dropped, usage history stays in the rollup tables
+	if err := services.EnsureRadAcctPartitioned(); err != nil {
+		log.Printf("Warning: radacct partitioning failed: %v", err)
+	}
+	radAcctPartitionService := services.NewRadAcctPartitionService(90) // Keep 90 days
+	radAcctPartitionService.Start()
+
+	// Start usage rollup service (hourly/daily per-subscriber and per-NAS usage every 5 min)
+	radAcctRollupService := services.NewRadAcctRollupService(5 * time.Minute)
+	radAcctRollupService.Start()
+
+	// Start IP history service (immutable IP assignment history from accounting)
+	ipHistoryService := services.NewIPHistoryService(time.Minute)
+	ipHistoryService.Start()
+
+	// Start report scheduler (runs saved reports on their schedule and emails the results)
+	reportSchedulerService := services.NewReportSchedulerService(time.Minute)
+	reportSchedulerService.Start()
+
+	// Start commission settlement service (rolls each finished month into reseller statements)
+	commissionSettlementService := services.NewCommissionSettlementService(time.Hour)
+	commissionSettlementService.Start()
+
+	// Start analytics service (nightly churn, ARPU, renewal rate and cohort metrics)
+	analyticsService := services.NewAnalyticsService(10 * time.Minute)
+	services.Analytics = analyticsService
+	analyticsService.Start()
 
 	// Start stale session cleanup service (closes ghost sessions every 5 min)
 	staleSessionCleanupService := services.NewStaleSessionCleanupService(30) // 30 min threshold
@@ -143,6 +168,13 @@ func main() {
 	dailyNotificationService := services.NewDailyNotificationService()
 	dailyNotificationService.Start()
 
+	// Subscribe communication rules to the event bus (subscriber, payment, ticket and NAS events)
+	services.StartNotificationRules()
+
+	// Start subscriber offline service (publishes subscriber.offline for offline-duration rules)
+	subscriberOfflineService := services.NewSubscriberOfflineService(5 * time.Minute)
+	subscriberOfflineService.Start()
+
 	// Start invoice generation service (auto-generates invoices before subscriber expiry)
 	invoiceGenerationService := services.NewInvoiceGenerationService()
 	invoiceGenerationService.Start()
@@ -151,6 +183,22 @@ func main() {
 	portScanService := services.NewPortScanService()
 	portScanService.Start()
 
+	// Start NAS health monitor (polls CPU/memory/uptime/traffic every minute, alerts on reboot/overload/offline)
+	nasMonitorService := services.NewNasMonitorService(1 * time.Minute)
+	nasMonitorService.Start()
+
+	// Start webhook delivery (sends queued events with retry/backoff, emits subscriber.expired)
+	webhookDeliveryService := services.NewWebhookDeliveryService(10 * time.Second)
+	webhookDeliveryService.Start()
+
+	// Start outbox workers (send queued SMS/WhatsApp/email at per-channel rates with retry and quiet hours)
+	outboxService := services.NewOutboxService(5 * time.Second)
+	outboxService.Start()
+
+	// Keep SMPP gateways bound so delivery receipts arrive between sends
+	smsGatewayService := services.NewSMSGatewayService(time.Minute)
+	smsGatewayService.Start()
+
 	// Warmup subscriber cache for online users (improves RADIUS performance)
 	go database.WarmupSubscriberCache()
 
@@ -203,15 +251,22 @@ func main() {
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(cfg)
+	ssoHandler := handlers.NewSSOHandler(cfg)
 	subscriberHandler := handlers.NewSubscriberHandler()
 	serviceHandler := handlers.NewServiceHandler()
 	nasHandler := handlers.NewNasHandler()
+	nasConfigHandler := handlers.NewNasConfigHandler()
+	hotspotHandler := handlers.NewHotspotHandler()
+	apiKeyHandler := handlers.NewAPIKeyHandler()
+	webhookHandler := handlers.NewWebhookHandler()
+	nasMonitorHandler := handlers.NewNasMonitorHandler(nasMonitorService)
 	resellerHandler := handlers.NewResellerHandler(cfg)
 	dashboardHandler := handlers.NewDashboardHandler()
 	sessionHandler := handlers.NewSessionHandler()
 	settingsHandler := handlers.NewSettingsHandler()
 	userHandler := handlers.NewUserHandler()
 	communicationHandler := handlers.NewCommunicationHandler()
+	outboxHandler := handlers.NewOutboxHandler()
 	prepaidHandler := handlers.NewPrepaidHandler()
 	invoiceHandler := handlers.NewInvoiceHandler()
 	auditHandler := handlers.NewAuditHandler()
@@ -238,6 +293,10 @@ func main() {
 	tunnelHandler := handlers.NewTunnelHandler()
 	collectorHandler := handlers.NewCollectorHandler()
 	notificationBannerHandler := handlers.NewNotificationBannerHandler()
+	listV2Handler := handlers.NewListV2Handler()
+	ipHistoryHandler := handlers.NewIPHistoryHandler()
+	commissionHandler := handlers.NewCommissionHandler()
+	analyticsHandler := handlers.NewAnalyticsHandler()
 
 	// API routes
 	api := app.Group("/api")
@@ -247,11 +306,30 @@ func main() {
 
 	// Public routes
 	api.Post("/auth/login", authHandler.Login)
+	api.Post("/auth/refresh", authHandler.RefreshToken) // Public: uses the refresh token, the access token may have expired
+
+	// Single sign-on (OpenID Connect) - public, the browser is redirected through the IdP
+	api.Get("/auth/sso/providers", ssoHandler.Providers)
+	api.Post("/auth/sso/exchange", ssoHandler.Exchange)
+	api.Get("/auth/sso/:slug/login", ssoHandler.Start)
+	api.Get("/auth/sso/:slug/callback", ssoHandler.Callback)
 	api.Post("/auth/impersonate-exchange", authHandler.ExchangeImpersonateToken) // Exchange temp token for session (no auth - uses one-time token)
 	api.Get("/branding", middleware.OptionalAuth(cfg), settingsHandler.GetBranding)
 	api.Get("/server-time", settingsHandler.GetServerTime) // Public - needed for timezone before auth
 	api.Get("/backups/public-download/:token", backupHandler.PublicDownload)
 
+	// Inbound WhatsApp/SMS for the chatbot (public, authenticated by inbound_webhook_token)
+	inboundHandler := handlers.NewInboundHandler()
+	api.Post("/inbound/whatsapp/:token", inboundHandler.WhatsApp)
+	api.Post("/inbound/sms/:token", inboundHandler.SMS)
+	api.Get("/inbound/sms/:token", inboundHandler.SMS)
+
+	// SMS delivery receipts (public, authenticated by inbound_webhook_token)
+	smsGatewayHandler := handlers.NewSMSGatewayHandler()
+	api.Post("/sms/dlr/:id/:token", smsGatewayHandler.DeliveryReport)
+	api.Get("/sms/dlr/:id/:token", smsGatewayHandler.DeliveryReport)
+	api.Get("/openapi.json", handlers.NewOpenAPIHandler().Spec)
+
 	// HA Cluster public routes (use cluster secret for auth, not JWT)
 	// Must be registered before protected group
 	clusterHandler := handlers.NewClusterHandler()
@@ -271,6 +349,10 @@ func main() {
 	customerHandler := handlers.NewCustomerPortalHandler(cfg)
 	customerPortal := api.Group("/customer")
 	customerPortal.Post("/login", customerHandler.Login)
+	customerPortal.Get("/otp/channels", customerHandler.OTPChannels)
+	customerPortal.Post("/otp/request", customerHandler.RequestOTP)
+	customerPortal.Post("/otp/verify", customerHandler.VerifyOTP)
+	customerPortal.Post("/otp/select", customerHandler.SelectOTPAccount)
 	// Protected customer routes
 	customerProtected := customerPortal.Group("", handlers.CustomerAuthMiddleware(cfg))
 	customerProtected.Get("/dashboard", customerHandler.Dashboard)
@@ -284,6 +366,18 @@ func main() {
 	customerProtected.Get("/invoices", customerHandler.Invoices)
 	customerProtected.Get("/invoices/:id", customerHandler.GetInvoice)
 	customerProtected.Get("/active-banners", notificationBannerHandler.GetActiveForCustomer)
+	// Customer self-service (each action gated by the reseller's portal permissions)
+	customerProtected.Get("/self-service", customerHandler.SelfService)
+	customerProtected.Get("/wallet", customerHandler.Wallet)
+	customerProtected.Post("/wallet/topup", customerHandler.TopUpWallet)
+	customerProtected.Post("/renew", customerHandler.Renew)
+	customerProtected.Get("/plans", customerHandler.Plans)
+	customerProtected.Get("/plans/:id/quote", customerHandler.PlanQuote)
+	customerProtected.Post("/plans/:id/change", customerHandler.ChangePlan)
+	customerProtected.Post("/reset-fup", customerHandler.ResetFUP)
+	customerProtected.Put("/profile", customerHandler.UpdateProfile)
+	customerProtected.Post("/profile/verify", customerHandler.VerifyProfile)
+	customerProtected.Post("/password", customerHandler.ChangePassword)
 
 	// Critical system routes - auth only, NO license check (for fixing license/restart issues)
 	criticalSystem := api.Group("", middleware.AuthRequired(cfg))
@@ -296,7 +390,9 @@ func main() {
 	// Auth routes
 	protected.Post("/auth/logout", authHandler.Logout)
 	protected.Get("/auth/me", authHandler.Me)
-	protected.Post("/auth/refresh", authHandler.RefreshToken)
+	protected.Get("/auth/sessions", authHandler.ListSessions)
+	protected.Post("/auth/sessions/revoke-others", authHandler.RevokeOtherSessions)
+	protected.Delete("/auth/sessions/:id", authHandler.RevokeSession)
 	protected.Put("/auth/password", authHandler.ChangePassword)
 	protected.Post("/auth/change-password", authHandler.ChangePassword)
 
@@ -305,6 +401,11 @@ func main() {
 	protected.Post("/auth/2fa/setup", twoFAHandler.Setup)
 	protected.Post("/auth/2fa/verify", twoFAHandler.Verify)
 	protected.Post("/auth/2fa/disable", twoFAHandler.Disable)
+	protected.Post("/auth/2fa/recovery-codes", twoFAHandler.RegenerateRecoveryCodes)
+	protected.Get("/auth/2fa/webauthn", twoFAHandler.WebAuthnCredentials)
+	protected.Post("/auth/2fa/webauthn/register/begin", twoFAHandler.WebAuthnRegisterBegin)
+	protected.Post("/auth/2fa/webauthn/register/finish", twoFAHandler.WebAuthnRegisterFinish)
+	protected.Delete("/auth/2fa/webauthn/:id", twoFAHandler.WebAuthnDelete)
 
 	// Dashboard routes
 	protected.Get("/dashboard/stats", dashboardHandler.Stats)
@@ -370,14 +471,25 @@ func main() {
 	// NAS routes
 	nas := protected.Group("/nas")
 	nas.Get("/", middleware.RequirePermission("nas.view"), nasHandler.List)
+	nas.Get("/health", middleware.RequirePermission("nas.view"), nasMonitorHandler.Overview)
+	nas.Get("/health/events", middleware.RequirePermission("nas.view"), nasMonitorHandler.Events)
 	nas.Get("/:id", middleware.RequirePermission("nas.view"), nasHandler.Get)
 	nas.Post("/", middleware.RequirePermission("nas.create"), nasHandler.Create)
 	nas.Put("/:id", middleware.RequirePermission("nas.edit"), nasHandler.Update)
 	nas.Delete("/:id", middleware.RequirePermission("nas.delete"), nasHandler.Delete)
 	nas.Post("/:id/sync", middleware.RequirePermission("nas.edit"), nasHandler.Sync)
 	nas.Post("/:id/test", middleware.RequirePermission("nas.view"), nasHandler.TestConnection)
+	nas.Post("/:id/provision", middleware.RequirePermission("nas.edit"), nasHandler.Provision)
 	nas.Get("/:id/pools", middleware.RequirePermission("nas.view"), nasHandler.GetIPPools)
 	nas.Put("/:id/pools", middleware.RequirePermission("nas.edit"), nasHandler.UpdateSubscriberPools)
+	nas.Get("/:id/health", middleware.RequirePermission("nas.view"), nasMonitorHandler.History)
+	nas.Get("/:id/health/events", middleware.RequirePermission("nas.view"), nasMonitorHandler.Events)
+	nas.Post("/:id/health/poll", middleware.RequirePermission("nas.edit"), nasMonitorHandler.PollNow)
+	nas.Get("/:id/config/versions", middleware.RequirePermission("nas.view"), nasConfigHandler.ListVersions)
+	nas.Post("/:id/config/versions", middleware.RequirePermission("nas.edit"), nasConfigHandler.Snapshot)
+	nas.Get("/:id/config/diff", middleware.RequirePermission("nas.view"), nasConfigHandler.Diff)
+	nas.Get("/:id/config/versions/:version", middleware.RequirePermission("nas.view"), nasConfigHandler.GetVersion)
+	nas.Post("/:id/config/versions/:version/restore", middleware.RequirePermission("nas.config_restore"), nasConfigHandler.Restore)
 
 	// IP Pool Management routes (Admin only)
 	ipPools := protected.Group("/ip-pools", middleware.AdminOnly())
@@ -390,6 +502,22 @@ func main() {
 	ipPools.Post("/sync-sessions/:id", handlers.SyncActiveSessionsFromNAS)
 	ipPools.Post("/enable", handlers.EnableProISPIPManagement)
 	ipPools.Post("/disable", handlers.DisableProISPIPManagement)
+	ipPools.Get("/cgnat", handlers.ListCGNATPools)
+	ipPools.Get("/cgnat/lookup", handlers.LookupCGNAT)
+	ipPools.Post("/cgnat", handlers.CreateCGNATPool)
+	ipPools.Put("/cgnat/:id", handlers.UpdateCGNATPool)
+	ipPools.Delete("/cgnat/:id", handlers.DeleteCGNATPool)
+	ipPools.Get("/cgnat/:id/blocks", handlers.GetCGNATPortBlocks)
+	ipPools.Post("/cgnat/:id/sync", handlers.SyncCGNATPool)
+
+	// Hotspot routes
+	hotspot := protected.Group("/hotspot")
+	hotspot.Get("/sessions", middleware.RequirePermission("nas.view"), hotspotHandler.LiveSessions)
+	hotspot.Get("/walled-garden", middleware.RequirePermission("nas.view"), hotspotHandler.ListWalledGarden)
+	hotspot.Post("/walled-garden", middleware.RequirePermission("nas.edit"), hotspotHandler.CreateWalledGarden)
+	hotspot.Post("/walled-garden/sync", middleware.RequirePermission("nas.edit"), hotspotHandler.SyncWalledGarden)
+	hotspot.Put("/walled-garden/:id", middleware.RequirePermission("nas.edit"), hotspotHandler.UpdateWalledGarden)
+	hotspot.Delete("/walled-garden/:id", middleware.RequirePermission("nas.edit"), hotspotHandler.DeleteWalledGarden)
 
 	// Reseller routes
 	resellers := protected.Group("/resellers")
@@ -408,6 +536,28 @@ func main() {
 	resellers.Put("/:id/assigned-nas", middleware.AdminOnly(), resellerHandler.UpdateAssignedNAS)
 	resellers.Get("/:id/assigned-services", middleware.AdminOnly(), resellerHandler.GetAssignedServices)
 	resellers.Put("/:id/assigned-services", middleware.AdminOnly(), resellerHandler.UpdateAssignedServices)
+	resellers.Get("/:id/portal-permissions", middleware.ResellerOrAdmin(), resellerHandler.GetPortalPermissions)
+	resellers.Put("/:id/portal-permissions", middleware.ResellerOrAdmin(), resellerHandler.UpdatePortalPermissions)
+
+	// Reseller commissions: plans, ledger and monthly settlement statements
+	commissions := protected.Group("/commissions")
+	commissions.Get("/", middleware.RequirePermission("commissions.view"), commissionHandler.ListEntries)
+	commissions.Get("/summary", middleware.RequirePermission("commissions.view"), commissionHandler.Summary)
+	commissions.Get("/plans", middleware.RequirePermission("commissions.view"), commissionHandler.ListPlans)
+	commissions.Post("/plans", middleware.RequirePermission("commissions.manage"), commissionHandler.CreatePlan)
+	commissions.Put("/plans/:id", middleware.RequirePermission("commissions.manage"), commissionHandler.UpdatePlan)
+	commissions.Delete("/plans/:id", middleware.RequirePermission("commissions.manage"), commissionHandler.DeletePlan)
+	commissions.Get("/settlements", middleware.RequirePermission("commissions.view"), commissionHandler.ListSettlements)
+	commissions.Post("/settlements/generate", middleware.RequirePermission("commissions.manage"), commissionHandler.GenerateSettlements)
+	commissions.Get("/settlements/:id", middleware.RequirePermission("commissions.view"), commissionHandler.GetSettlement)
+	commissions.Post("/settlements/:id/pay", middleware.RequirePermission("commissions.manage"), commissionHandler.PaySettlement)
+
+	// Subscriber analytics: churn, ARPU, renewal rate and cohorts (precomputed nightly)
+	analyticsGroup := protected.Group("/analytics", middleware.RequirePermission("analytics.view"))
+	analyticsGroup.Get("/overview", analyticsHandler.Overview)
+	analyticsGroup.Get("/metrics", analyticsHandler.Metrics)
+	analyticsGroup.Get("/cohorts", analyticsHandler.Cohorts)
+	analyticsGroup.Post("/recompute", middleware.AdminOnly(), analyticsHandler.Recompute)
 
 	// Session routes
 	sessions := protected.Group("/sessions")
@@ -450,6 +600,16 @@ func main() {
 	notifications.Post("/whatsapp/subscribers/:id/toggle-notifications", notificationHandler.AdminToggleSubscriberWhatsApp)
 	notifications.Post("/whatsapp/notifications/set-all", notificationHandler.AdminSetAllNotifications)
 
+	// SMS gateways (failover chain)
+	smsGateways := protected.Group("/sms-gateways", middleware.RequirePermission("settings.edit"))
+	smsGateways.Get("", smsGatewayHandler.List)
+	smsGateways.Post("", smsGatewayHandler.Create)
+	smsGateways.Put("/order", smsGatewayHandler.Reorder)
+	smsGateways.Put("/:id", smsGatewayHandler.Update)
+	smsGateways.Delete("/:id", smsGatewayHandler.Delete)
+	smsGateways.Post("/:id/test", smsGatewayHandler.Test)
+	smsGateways.Post("/:id/reset", smsGatewayHandler.ResetHealth)
+
 	// Reseller WhatsApp routes (per-reseller, requires notifications.whatsapp permission)
 	resellerWA := protected.Group("/reseller/whatsapp", middleware.RequirePermission("notifications.whatsapp"))
 	resellerWA.Get("/settings", resellerWAHandler.GetSettings)
@@ -563,10 +723,47 @@ func main() {
 	// User management routes
 	users := protected.Group("/users")
 	users.Get("/", middleware.RequirePermission("users.view"), userHandler.List)
+	users.Get("/two-factor-policy", middleware.AdminOnly(), twoFAHandler.GetPolicy)
+	users.Put("/two-factor-policy", middleware.AdminOnly(), twoFAHandler.UpdatePolicy)
 	users.Get("/:id", middleware.RequirePermission("users.view"), userHandler.Get)
 	users.Post("/", middleware.RequirePermission("users.create"), userHandler.Create)
 	users.Put("/:id", middleware.RequirePermission("users.edit"), userHandler.Update)
 	users.Delete("/:id", middleware.RequirePermission("users.delete"), userHandler.Delete)
+	users.Get("/:id/sessions", middleware.RequirePermission("users.view"), userHandler.ListSessions)
+	users.Post("/:id/revoke-sessions", middleware.RequirePermission("users.edit"), userHandler.RevokeSessions)
+	users.Post("/:id/reset-2fa", middleware.AdminOnly(), twoFAHandler.ResetUser)
+	users.Get("/:id/identities", middleware.RequirePermission("users.view"), ssoHandler.UserIdentities)
+	users.Delete("/:id/identities/:identityId", middleware.AdminOnly(), ssoHandler.Unlink)
+
+	// SSO provider management (admins only: role mappings can grant admin access)
+	ssoProviders := protected.Group("/sso")
+	ssoProviders.Get("/providers", middleware.AdminOnly(), ssoHandler.List)
+	ssoProviders.Post("/providers", middleware.AdminOnly(), ssoHandler.Create)
+	ssoProviders.Put("/providers/:id", middleware.AdminOnly(), ssoHandler.Update)
+	ssoProviders.Delete("/providers/:id", middleware.AdminOnly(), ssoHandler.Delete)
+	ssoProviders.Post("/providers/:id/test", middleware.AdminOnly(), ssoHandler.Test)
+	ssoProviders.Put("/settings", middleware.AdminOnly(), ssoHandler.UpdateSettings)
+
+	// API key routes (machine-to-machine access)
+	apiKeys := protected.Group("/api-keys")
+	apiKeys.Get("/", middleware.RequirePermission("api_keys.manage"), apiKeyHandler.List)
+	apiKeys.Post("/", middleware.RequirePermission("api_keys.manage"), apiKeyHandler.Create)
+	apiKeys.Put("/:id", middleware.RequirePermission("api_keys.manage"), apiKeyHandler.Update)
+	apiKeys.Delete("/:id", middleware.RequirePermission("api_keys.manage"), apiKeyHandler.Delete)
+
+	// Webhook routes (outbound event delivery)
+	webhooks := protected.Group("/webhooks")
+	webhooks.Get("/", middleware.RequirePermission("webhooks.manage"), webhookHandler.List)
+	webhooks.Get("/events", middleware.RequirePermission("webhooks.manage"), webhookHandler.Events)
+	webhooks.Get("/deliveries", middleware.RequirePermission("webhooks.manage"), webhookHandler.ListDeliveries)
+	webhooks.Get("/dead-letters", middleware.RequirePermission("webhooks.manage"), webhookHandler.DeadLetters)
+	webhooks.Post("/deliveries/replay", middleware.RequirePermission("webhooks.manage"), webhookHandler.ReplayBulk)
+	webhooks.Post("/deliveries/:id/replay", middleware.RequirePermission("webhooks.manage"), webhookHandler.Replay)
+	webhooks.Post("/", middleware.RequirePermission("webhooks.manage"), webhookHandler.Create)
+	webhooks.Put("/:id", middleware.RequirePermission("webhooks.manage"), webhookHandler.Update)
+	webhooks.Delete("/:id", middleware.RequirePermission("webhooks.manage"), webhookHandler.Delete)
+	webhooks.Post("/:id/test", middleware.RequirePermission("webhooks.manage"), webhookHandler.Test)
+	webhooks.Post("/:id/rotate-secret", middleware.RequirePermission("webhooks.manage"), webhookHandler.RotateSecret)
 
 	// Communication routes
 	communication := protected.Group("/communication")
@@ -577,13 +774,23 @@ func main() {
 	communication.Put("/templates/:id", middleware.RequirePermission("communication.edit"), communicationHandler.UpdateTemplate)
 	communication.Delete("/templates/:id", middleware.RequirePermission("communication.delete"), communicationHandler.DeleteTemplate)
 	// Rules
+	communication.Get("/triggers", middleware.RequirePermission("communication.view"), communicationHandler.ListTriggers)
 	communication.Get("/rules", middleware.RequirePermission("communication.view"), communicationHandler.ListRules)
+	communication.Post("/rules/preview", middleware.RequirePermission("communication.view"), communicationHandler.PreviewRule)
 	communication.Get("/rules/:id", middleware.RequirePermission("communication.view"), communicationHandler.GetRule)
 	communication.Post("/rules", middleware.RequirePermission("communication.create"), communicationHandler.CreateRule)
 	communication.Put("/rules/:id", middleware.RequirePermission("communication.edit"), communicationHandler.UpdateRule)
 	communication.Delete("/rules/:id", middleware.RequirePermission("communication.delete"), communicationHandler.DeleteRule)
 	// Logs
 	communication.Get("/logs", middleware.RequirePermission("communication.view"), communicationHandler.ListLogs)
+	communication.Get("/inbox", middleware.RequirePermission("communication.view_logs"), inboundHandler.List)
+	// Outbox
+	outbox := communication.Group("/outbox", middleware.RequirePermission("communication.outbox"))
+	outbox.Get("/", outboxHandler.List)
+	outbox.Get("/stats", outboxHandler.Stats)
+	outbox.Post("/resend", outboxHandler.ResendFailed)
+	outbox.Post("/:id/resend", outboxHandler.Resend)
+	outbox.Post("/:id/cancel", outboxHandler.Cancel)
 
 	// Bandwidth rules routes
 	bandwidth := protected.Group("/bandwidth")
@@ -653,6 +860,9 @@ func main() {
 	permissions.Post("/groups", middleware.RequirePermission("permissions.create"), permissionHandler.CreateGroup)
 	permissions.Put("/groups/:id", middleware.RequirePermission("permissions.edit"), permissionHandler.UpdateGroup)
 	permissions.Delete("/groups/:id", middleware.RequirePermission("permissions.delete"), permissionHandler.DeleteGroup)
+	// Permission matrix (groups x permissions) and a user's resolved access
+	permissions.Get("/matrix", middleware.RequirePermission("permissions.view"), permissionHandler.Matrix)
+	permissions.Get("/users/:id", middleware.RequirePermission("permissions.view"), permissionHandler.Effective)
 
 	// Report routes
 	reports := protected.Group("/reports")
@@ -665,6 +875,32 @@ func main() {
 	reports.Get("/transactions", reportHandler.GetTransactionReport)
 	reports.Get("/nas", reportHandler.GetNASStats)
 	reports.Get("/export/:type", reportHandler.ExportReport)
+	// Saved and scheduled reports with stored results
+	reports.Get("/types", reportHandler.ListReportTypes)
+	reports.Get("/definitions", middleware.RequirePermission("reports.view"), reportHandler.ListDefinitions)
+	reports.Post("/definitions", middleware.RequirePermission("reports.schedule"), reportHandler.CreateDefinition)
+	reports.Put("/definitions/:id", middleware.RequirePermission("reports.schedule"), reportHandler.UpdateDefinition)
+	reports.Delete("/definitions/:id", middleware.RequirePermission("reports.schedule"), reportHandler.DeleteDefinition)
+	reports.Post("/definitions/:id/run", middleware.RequirePermission("reports.schedule"), reportHandler.RunDefinition)
+	reports.Get("/runs", middleware.RequirePermission("reports.view"), reportHandler.ListRuns)
+	reports.Get("/runs/:id/download", middleware.RequirePermission("reports.view"), reportHandler.DownloadRun)
+
+	// v2 list API: cursor pagination, filter/sort grammar, field selection and NDJSON/CSV streaming
+	v2 := protected.Group("/v2")
+	v2.Get("/subscribers", middleware.RequirePermission("subscribers.view"), listV2Handler.Subscribers)
+	v2.Get("/sessions", middleware.RequirePermission("sessions.view"), listV2Handler.Sessions)
+	v2.Get("/audit", middleware.RequirePermission("audit.view"), listV2Handler.Audit)
+	v2.Get("/transactions", middleware.RequirePermission("reports.view"), listV2Handler.Transactions)
+	v2.Get("/invoices", middleware.RequirePermission("invoices.view"), listV2Handler.Invoices)
+
+	// IP assignment history for lawful intercept requests
+	ipHistory := protected.Group("/ip-history")
+	ipHistory.Get("/", middleware.RequirePermission("ip_history.view"), ipHistoryHandler.List)
+	ipHistory.Get("/lookup", middleware.RequirePermission("ip_history.view"), ipHistoryHandler.Lookup)
+	ipHistory.Get("/signing-key", middleware.RequirePermission("ip_history.view"), ipHistoryHandler.SigningKey)
+	ipHistory.Get("/exports", middleware.RequirePermission("ip_history.export"), ipHistoryHandler.ListExports)
+	ipHistory.Post("/exports", middleware.RequirePermission("ip_history.export"), ipHistoryHandler.CreateExport)
+	ipHistory.Get("/exports/:id/download", middleware.RequirePermission("ip_history.export"), ipHistoryHandler.DownloadExport)
 
 	// Backup routes
 	backups := protected.Group("/backups")
@@ -747,6 +983,9 @@ func main() {
 	services.Post("/:id/cdns", middleware.RequirePermission("services.edit"), cdnHandler.AddServiceCDN)
 	services.Delete("/:id/cdns/:cdnId", middleware.RequirePermission("services.edit"), cdnHandler.DeleteServiceCDN)
 
+	// API keys only reach routes that declare a permission
+	middleware.GuardAPIKeyRoutes(app)
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -758,20 +997,35 @@ func main() {
 		bandwidthRuleService.Stop()
 		cdnBandwidthRuleService.Stop()
 		backupSchedulerService.Stop()
-		radAcctArchivalService.Stop()
+		radAcctPartitionService.Stop()
+		radAcctRollupService.Stop()
+		ipHistoryService.Stop()
+		reportSchedulerService.Stop()
+		commissionSettlementService.Stop()
+		analyticsService.Stop()
 		staleSessionCleanupService.Stop()
 		clusterFailoverService.Stop()
 		clusterService.Stop()
 		dailyQuotaResetService.Stop()
 		dailyNotificationService.Stop()
+		subscriberOfflineService.Stop()
 		sharingDetectionService.Stop()
 		invoiceGenerationService.Stop()
 		portScanService.Stop()
+		nasMonitorService.Stop()
+		webhookDeliveryService.Stop()
+		outboxService.Stop()
+		smsGatewayService.Stop()
 		mikrotik.ShutdownPool()
 		license.Stop()
 		app.Shutdown()
 	}()
 
+	// Every route needs an entry in handlers/openapi_routes.go (checked by go run ./cmd/openapi)
+	for _, r := range handlers.UndocumentedRoutes(app) {
+		log.Printf("Warning: %s %s is not in the OpenAPI document", r.Method, r.Path)
+	}
+
 	// Start server
 	addr := fmt.Sprintf(":%d", cfg.APIPort)
 	log.Printf("Starting ProISP API server on %s", addr)
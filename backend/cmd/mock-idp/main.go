@@ -0,0 +1,278 @@
+// Command mock-idp is a minimal OpenID Connect provider for trying out SSO locally.
+// It signs whatever identity is typed into its login form - never expose it to a network.
+//
+//	go run ./cmd/mock-idp -addr :9400 -client-id proisp -client-secret secret
+//
+// Then add an SSO provider with issuer http://localhost:9400, the same client ID/secret,
+// and groups such as "proisp-admins" mapped to a user type.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-idp-1"
+
+type authCode struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        jwt.MapClaims
+	expires       time.Time
+}
+
+type server struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]*authCode
+	tokens map[string]jwt.MapClaims // access token -> userinfo claims
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html><head><title>Mock IdP</title></head>
+<body style="font-family:sans-serif;max-width:420px;margin:40px auto">
+<h2>Mock identity provider</h2>
+<p>Client: <b>{{.ClientID}}</b></p>
+<form method="post">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{$v}}">
+{{end}}
+<p><label>Subject<br><input name="sub" value="mock-user-1" size="40"></label></p>
+<p><label>Username<br><input name="preferred_username" value="jdoe" size="40"></label></p>
+<p><label>Email<br><input name="email" value="jdoe@example.com" size="40"></label></p>
+<p><label>Name<br><input name="name" value="Jane Doe" size="40"></label></p>
+<p><label>Groups (comma-separated)<br><input name="groups" value="proisp-admins" size="40"></label></p>
+<p><button type="submit">Sign in</button> <button type="submit" name="deny" value="1">Deny</button></p>
+</form></body></html>`))
+
+func main() {
+	addr := flag.String("addr", ":9400", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9400", "issuer URL (must match how ProISP reaches this server)")
+	clientID := flag.String("client-id", "proisp", "expected client_id")
+	clientSecret := flag.String("client-secret", "secret", "expected client_secret")
+	flag.Parse()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	s := &server{
+		issuer:       strings.TrimSuffix(*issuer, "/"),
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		key:          key,
+		codes:        map[string]*authCode{},
+		tokens:       map[string]jwt.MapClaims{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/userinfo", s.userinfo)
+
+	log.Printf("Mock IdP listening on %s (issuer %s, client %s)", *addr, s.issuer, s.clientID)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"userinfo_endpoint":                     s.issuer + "/userinfo",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile", "groups"},
+	})
+}
+
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.Form.Get("client_id") != s.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI := r.Form.Get("redirect_uri")
+	if _, err := url.ParseRequestURI(redirectURI); err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		params := map[string]string{}
+		for _, k := range []string{"client_id", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method", "scope"} {
+			params[k] = r.Form.Get(k)
+		}
+		loginPage.Execute(w, map[string]interface{}{"ClientID": s.clientID, "Params": params})
+		return
+	}
+
+	target, _ := url.Parse(redirectURI)
+	q := target.Query()
+	q.Set("state", r.Form.Get("state"))
+	if r.Form.Get("deny") != "" {
+		q.Set("error", "access_denied")
+		q.Set("error_description", "The user denied the request")
+		target.RawQuery = q.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+		return
+	}
+
+	var groups []string
+	for _, g := range strings.Split(r.Form.Get("groups"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	claims := jwt.MapClaims{
+		"sub":                r.Form.Get("sub"),
+		"preferred_username": r.Form.Get("preferred_username"),
+		"email":              r.Form.Get("email"),
+		"email_verified":     true,
+		"name":               r.Form.Get("name"),
+		"groups":             groups,
+	}
+
+	code := randomHex(16)
+	s.mu.Lock()
+	s.codes[code] = &authCode{
+		clientID:      s.clientID,
+		redirectURI:   redirectURI,
+		nonce:         r.Form.Get("nonce"),
+		codeChallenge: r.Form.Get("code_challenge"),
+		claims:        claims,
+		expires:       time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	q.Set("code", code)
+	target.RawQuery = q.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if clientID != s.clientID || clientSecret != s.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.Form.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.mu.Lock()
+	code := s.codes[r.Form.Get("code")]
+	delete(s.codes, r.Form.Get("code"))
+	s.mu.Unlock()
+	if code == nil || time.Now().After(code.expires) || code.redirectURI != r.Form.Get("redirect_uri") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if code.codeChallenge != "" {
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != code.codeChallenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+			return
+		}
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   s.issuer,
+		"aud":   code.clientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": code.nonce,
+	}
+	for k, v := range code.claims {
+		idClaims[k] = v
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
+	idToken.Header["kid"] = keyID
+	signed, err := idToken.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	accessToken := randomHex(24)
+	s.mu.Lock()
+	s.tokens[accessToken] = code.claims
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"id_token":     signed,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *server) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	claims, ok := s.tokens[token]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,53 @@
+// Command openapi checks that every route registered in cmd/api/main.go has an entry in the
+// OpenAPI endpoint table, and that the table has no entries for routes that were removed.
+// It exits non-zero on any mismatch so it can run in CI next to go vet.
+//
+//	go run ./cmd/openapi
+//	go run ./cmd/openapi -o openapi.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/proisp/backend/internal/handlers"
+	"github.com/proisp/backend/internal/openapi"
+)
+
+func main() {
+	source := flag.String("routes", "cmd/api/main.go", "Go file that registers the API routes")
+	output := flag.String("o", "", "write the OpenAPI document to this file")
+	flag.Parse()
+
+	routes, err := openapi.ParseRoutes(*source)
+	if err != nil {
+		log.Fatalf("Failed to read routes: %v", err)
+	}
+
+	endpoints := handlers.APIEndpoints()
+	missing := openapi.Missing(routes, endpoints)
+	stale := openapi.Stale(routes, endpoints)
+	for _, r := range missing {
+		log.Printf("missing: %s %s is registered but not documented", r.Method, r.Path)
+	}
+	for _, r := range stale {
+		log.Printf("stale: %s %s is documented but not registered", r.Method, r.Path)
+	}
+
+	if *output != "" {
+		data, err := json.MarshalIndent(handlers.BuildOpenAPIDocument(""), "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode document: %v", err)
+		}
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", *output, err)
+		}
+	}
+
+	if len(missing) > 0 || len(stale) > 0 {
+		os.Exit(1)
+	}
+	log.Printf("%d routes, all documented", len(routes))
+}
@@ -0,0 +1,374 @@
+// Package analytics computes subscriber churn, ARPU, renewal rate and cohort retention
+// per month, overall and by service, reseller and region.
+//
+// A subscriber counts as active at the end of a month when its snapshot for that month
+// says so (see Snapshot). Metrics of a month M:
+//
+//	active_start  active at the end of M-1
+//	active_end    active at the end of M
+//	churned       active at the end of M-1 but not at the end of M
+//	renewed       subscribers with a renewal transaction in M
+//	revenue       new and renewal transactions in M, as on the dashboard
+//	arpu          revenue / average of active_start and active_end
+//	churn_rate    churned / active_start
+//	renewal_rate  renewed / (renewed + churned)
+package analytics
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dimensions are the breakdowns metrics are computed for
+var Dimensions = []string{"all", "service", "reseller", "region"}
+
+// dimensionKeys are the SQL expressions that segment a snapshot aliased x
+var dimensionKeys = map[string]string{
+	"all":      "'all'",
+	"service":  "COALESCE(x.service_id::text, '')",
+	"reseller": "COALESCE(x.reseller_id::text, '')",
+	"region":   "COALESCE(x.region, '')",
+}
+
+// ValidDimension reports whether d is a known dimension
+func ValidDimension(d string) bool {
+	_, ok := dimensionKeys[d]
+	return ok
+}
+
+// MonthStart returns the first instant of t's month
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Snapshot records every subscriber's current state as the state of the current month.
+// Subscribers deleted since the last run are marked inactive.
+func Snapshot(now time.Time) (int64, error) {
+	month := MonthStart(now)
+	result := database.DB.Exec(`
+		INSERT INTO subscriber_status_snapshots
+			(month, subscriber_id, service_id, reseller_id, region, status, expiry_date, subscribed_at, active, estimated, updated_at)
+		SELECT ?, s.id, s.service_id, s.reseller_id, COALESCE(s.region, ''), s.status, s.expiry_date, s.created_at,
+			s.status = ? AND s.expiry_date >= ?, false, NOW()
+		FROM subscribers s
+		WHERE s.deleted_at IS NULL
+		ON CONFLICT (month, subscriber_id) DO UPDATE SET
+			service_id = EXCLUDED.service_id, reseller_id = EXCLUDED.reseller_id, region = EXCLUDED.region,
+			status = EXCLUDED.status, expiry_date = EXCLUDED.expiry_date, subscribed_at = EXCLUDED.subscribed_at,
+			active = EXCLUDED.active, estimated = false, updated_at = NOW()`,
+		month, models.SubscriberStatusActive, now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	err := database.DB.Exec(`
+		UPDATE subscriber_status_snapshots SET active = false, updated_at = NOW()
+		WHERE month = ? AND active AND subscriber_id IN (SELECT id FROM subscribers WHERE deleted_at IS NOT NULL)`,
+		month).Error
+	return result.RowsAffected, err
+}
+
+// Backfill estimates the snapshots of past months that have none: a subscriber is taken
+// as active at the end of a month if it existed then and either its current expiry is
+// later or it was sold a new period in the 31 days before. Observed rows are never
+// replaced.
+func Backfill(months int, now time.Time) error {
+	current := MonthStart(now)
+	for i := months - 1; i >= 1; i-- {
+		month := current.AddDate(0, -i, 0)
+		end := month.AddDate(0, 1, 0)
+
+		var exists int64
+		database.DB.Model(&models.SubscriberStatusSnapshot{}).Where("month = ?", month).Limit(1).Count(&exists)
+		if exists > 0 {
+			continue
+		}
+
+		err := database.DB.Exec(`
+			INSERT INTO subscriber_status_snapshots
+				(month, subscriber_id, service_id, reseller_id, region, status, expiry_date, subscribed_at, active, estimated, updated_at)
+			SELECT ?, s.id, s.service_id, s.reseller_id, COALESCE(s.region, ''), s.status, s.expiry_date, s.created_at,
+				(s.deleted_at IS NULL OR s.deleted_at >= ?) AND (s.expiry_date >= ? OR EXISTS (
+					SELECT 1 FROM transactions t WHERE t.subscriber_id = s.id AND t.type IN (?, ?)
+						AND t.created_at < ? AND t.created_at >= ?)),
+				true, NOW()
+			FROM subscribers s
+			WHERE s.created_at < ? AND (s.deleted_at IS NULL OR s.deleted_at >= ?)
+			ON CONFLICT (month, subscriber_id) DO NOTHING`,
+			month, end, end, models.TransactionTypeNew, models.TransactionTypeRenewal, end, end.AddDate(0, 0, -31),
+			end, month).Error
+		if err != nil {
+			return fmt.Errorf("backfill %s: %v", month.Format("2006-01"), err)
+		}
+	}
+	return nil
+}
+
+type keyCount struct {
+	K string
+	N float64
+}
+
+// countBy runs a query selecting (k, n) rows; its %s is replaced by the dimension's key
+// expression
+func countBy(dimension, query string, args ...interface{}) (map[string]float64, error) {
+	var rows []keyCount
+	sql := fmt.Sprintf(query, dimensionKeys[dimension])
+	if err := database.DB.Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]float64, len(rows))
+	for _, r := range rows {
+		counts[r.K] += r.N
+	}
+	return counts, nil
+}
+
+// labels names the keys of a dimension for display
+func labels(dimension string) map[string]string {
+	names := make(map[string]string)
+	type named struct {
+		ID   uint
+		Name string
+	}
+	var rows []named
+	switch dimension {
+	case "all":
+		names["all"] = "All subscribers"
+	case "service":
+		database.DB.Table("services").Select("id, name").Scan(&rows)
+		names[""] = "No service"
+	case "reseller":
+		database.DB.Table("resellers").Select("id, name").Scan(&rows)
+		names[""] = "No reseller"
+	case "region":
+		names[""] = "No region"
+	}
+	for _, r := range rows {
+		names[strconv.FormatUint(uint64(r.ID), 10)] = r.Name
+	}
+	return names
+}
+
+func label(names map[string]string, key string) string {
+	if name, ok := names[key]; ok {
+		return name
+	}
+	return key
+}
+
+func ratio(a, b float64) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return a / b
+}
+
+// computeMonth derives one month's metrics for a dimension
+func computeMonth(month time.Time, dimension string, names map[string]string, estimated bool) ([]models.AnalyticsMonthly, error) {
+	prev := month.AddDate(0, -1, 0)
+	next := month.AddDate(0, 1, 0)
+
+	activeEnd, err := countBy(dimension, `SELECT %s AS k, COUNT(*) AS n FROM subscriber_status_snapshots x
+		WHERE x.month = ? AND x.active GROUP BY 1`, month)
+	if err != nil {
+		return nil, err
+	}
+	activeStart, err := countBy(dimension, `SELECT %s AS k, COUNT(*) AS n FROM subscriber_status_snapshots x
+		WHERE x.month = ? AND x.active GROUP BY 1`, prev)
+	if err != nil {
+		return nil, err
+	}
+	churned, err := countBy(dimension, `SELECT %s AS k, COUNT(*) AS n FROM subscriber_status_snapshots x
+		LEFT JOIN subscriber_status_snapshots y ON y.subscriber_id = x.subscriber_id AND y.month = ?
+		WHERE x.month = ? AND x.active AND NOT COALESCE(y.active, false) GROUP BY 1`, month, prev)
+	if err != nil {
+		return nil, err
+	}
+	joined, err := countBy(dimension, `SELECT %s AS k, COUNT(*) AS n FROM subscriber_status_snapshots x
+		WHERE x.month = ? AND x.subscribed_at >= ? AND x.subscribed_at < ? GROUP BY 1`, month, month, next)
+	if err != nil {
+		return nil, err
+	}
+	renewed, err := countBy(dimension, `SELECT %s AS k, COUNT(DISTINCT t.subscriber_id) AS n FROM transactions t
+		JOIN subscriber_status_snapshots x ON x.subscriber_id = t.subscriber_id AND x.month = ?
+		WHERE t.type = ? AND t.created_at >= ? AND t.created_at < ? GROUP BY 1`,
+		month, models.TransactionTypeRenewal, month, next)
+	if err != nil {
+		return nil, err
+	}
+	revenue, err := countBy(dimension, `SELECT %s AS k, COALESCE(SUM(ABS(t.amount)), 0) AS n FROM transactions t
+		LEFT JOIN subscriber_status_snapshots x ON x.subscriber_id = t.subscriber_id AND x.month = ?
+		WHERE t.type IN (?, ?) AND t.created_at >= ? AND t.created_at < ? GROUP BY 1`,
+		month, models.TransactionTypeNew, models.TransactionTypeRenewal, month, next)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for _, m := range []map[string]float64{activeEnd, activeStart, churned, joined, renewed, revenue} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+
+	now := time.Now()
+	rows := make([]models.AnalyticsMonthly, 0, len(keys))
+	for k := range keys {
+		row := models.AnalyticsMonthly{
+			Period:         month,
+			Dimension:      dimension,
+			DimensionKey:   k,
+			Label:          label(names, k),
+			ActiveStart:    int(activeStart[k]),
+			ActiveEnd:      int(activeEnd[k]),
+			NewSubscribers: int(joined[k]),
+			Churned:        int(churned[k]),
+			Renewed:        int(renewed[k]),
+			Revenue:        revenue[k],
+			Estimated:      estimated,
+			ComputedAt:     now,
+		}
+		row.ARPU = ratio(row.Revenue, float64(row.ActiveStart+row.ActiveEnd)/2)
+		row.ChurnRate = ratio(float64(row.Churned), float64(row.ActiveStart))
+		row.RenewalRate = ratio(float64(row.Renewed), float64(row.Renewed+row.Churned))
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// computeCohort derives the retention of the subscribers who joined in cohort, for every
+// month since
+func computeCohort(cohort time.Time, dimension string, names map[string]string) ([]models.AnalyticsCohort, error) {
+	next := cohort.AddDate(0, 1, 0)
+	size, err := countBy(dimension, `SELECT %s AS k, COUNT(*) AS n FROM subscriber_status_snapshots x
+		WHERE x.month = ? AND x.subscribed_at >= ? AND x.subscribed_at < ? GROUP BY 1`, cohort, cohort, next)
+	if err != nil {
+		return nil, err
+	}
+
+	type retained struct {
+		K     string
+		Month time.Time
+		N     int
+	}
+	var rows []retained
+	err = database.DB.Raw(fmt.Sprintf(`SELECT %s AS k, y.month, COUNT(*) AS n FROM subscriber_status_snapshots x
+		JOIN subscriber_status_snapshots y ON y.subscriber_id = x.subscriber_id AND y.month >= x.month AND y.active
+		WHERE x.month = ? AND x.subscribed_at >= ? AND x.subscribed_at < ? GROUP BY 1, 2`, dimensionKeys[dimension]),
+		cohort, cohort, next).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]map[int]int)
+	for _, r := range rows {
+		offset := (r.Month.Year()-cohort.Year())*12 + int(r.Month.Month()-cohort.Month())
+		if active[r.K] == nil {
+			active[r.K] = make(map[int]int)
+		}
+		active[r.K][offset] += r.N
+	}
+
+	now := time.Now()
+	offsets := (now.Year()-cohort.Year())*12 + int(now.Month()-cohort.Month())
+	var result []models.AnalyticsCohort
+	for k, n := range size {
+		for offset := 0; offset <= offsets; offset++ {
+			a := active[k][offset]
+			result = append(result, models.AnalyticsCohort{
+				Cohort:       cohort,
+				MonthOffset:  offset,
+				Dimension:    dimension,
+				DimensionKey: k,
+				Label:        label(names, k),
+				Size:         int(n),
+				Active:       a,
+				Retention:    ratio(float64(a), n),
+				ComputedAt:   now,
+			})
+		}
+	}
+	return result, nil
+}
+
+// Compute refreshes the metrics and cohorts of the last months (including the current
+// one) from the snapshots
+func Compute(months int, now time.Time) error {
+	current := MonthStart(now)
+	first := current.AddDate(0, -(months - 1), 0)
+
+	names := make(map[string]map[string]string, len(Dimensions))
+	for _, d := range Dimensions {
+		names[d] = labels(d)
+	}
+
+	for month := first; !month.After(current); month = month.AddDate(0, 1, 0) {
+		var snapshots, estimated int64
+		database.DB.Model(&models.SubscriberStatusSnapshot{}).Where("month = ?", month).Count(&snapshots)
+		if snapshots == 0 {
+			continue
+		}
+		database.DB.Model(&models.SubscriberStatusSnapshot{}).
+			Where("month IN ? AND estimated", []time.Time{month, month.AddDate(0, -1, 0)}).Limit(1).Count(&estimated)
+
+		var metrics []models.AnalyticsMonthly
+		var cohorts []models.AnalyticsCohort
+		for _, d := range Dimensions {
+			rows, err := computeMonth(month, d, names[d], estimated > 0)
+			if err != nil {
+				return fmt.Errorf("metrics %s/%s: %v", month.Format("2006-01"), d, err)
+			}
+			metrics = append(metrics, rows...)
+
+			c, err := computeCohort(month, d, names[d])
+			if err != nil {
+				return fmt.Errorf("cohort %s/%s: %v", month.Format("2006-01"), d, err)
+			}
+			cohorts = append(cohorts, c...)
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("period = ?", month).Delete(&models.AnalyticsMonthly{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("cohort = ?", month).Delete(&models.AnalyticsCohort{}).Error; err != nil {
+				return err
+			}
+			if len(metrics) > 0 {
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(metrics, 500).Error; err != nil {
+					return err
+				}
+			}
+			if len(cohorts) > 0 {
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(cohorts, 500).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("save %s: %v", month.Format("2006-01"), err)
+		}
+	}
+	return nil
+}
+
+// Run takes today's snapshot, fills in missing history and recomputes the window
+func Run(months int, now time.Time) error {
+	if months < 2 {
+		months = 2
+	}
+	if _, err := Snapshot(now); err != nil {
+		return fmt.Errorf("snapshot: %v", err)
+	}
+	if err := Backfill(months, now); err != nil {
+		return err
+	}
+	return Compute(months, now)
+}
@@ -0,0 +1,299 @@
+// Package commission posts revenue-share entries up the reseller tree and rolls them
+// into monthly settlement statements.
+package commission
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTiers returns how many ancestors of a seller can earn from one sale
+func maxTiers() int {
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", "commission_max_tiers").First(&pref).Error; err == nil {
+		if v, err := strconv.Atoi(pref.Value); err == nil && v > 0 {
+			return v
+		}
+	}
+	return 5
+}
+
+// planFor returns the plan a reseller earns by: its own, else the default plan
+func planFor(reseller *models.Reseller, cache map[uint]*models.CommissionPlan) *models.CommissionPlan {
+	key := uint(0)
+	if reseller.CommissionPlanID != nil {
+		key = *reseller.CommissionPlanID
+	}
+	if plan, ok := cache[key]; ok {
+		return plan
+	}
+
+	var plan models.CommissionPlan
+	query := database.DB.Preload("Rules").Where("is_active = ?", true)
+	if key != 0 {
+		query = query.Where("id = ?", key)
+	} else {
+		query = query.Where("is_default = ?", true)
+	}
+	if err := query.First(&plan).Error; err != nil {
+		cache[key] = nil
+		return nil
+	}
+	cache[key] = &plan
+	return &plan
+}
+
+// Rule returns the plan's rule for a tier and service; a service-specific rule wins
+// over a catch-all one
+func Rule(plan *models.CommissionPlan, tier int, serviceID *uint) *models.CommissionRule {
+	var match *models.CommissionRule
+	for i := range plan.Rules {
+		r := &plan.Rules[i]
+		if r.Tier != tier {
+			continue
+		}
+		if r.ServiceID != nil {
+			if serviceID != nil && *r.ServiceID == *serviceID {
+				return r
+			}
+			continue
+		}
+		if match == nil {
+			match = r
+		}
+	}
+	return match
+}
+
+// Amount applies a rule to a sale amount, rounded to cents
+func Amount(rule *models.CommissionRule, base float64) float64 {
+	amount := rule.Value
+	if rule.Type == "percent" {
+		amount = base * rule.Value / 100
+	}
+	return math.Round(amount*100) / 100
+}
+
+// Post records the commissions of a sale transaction (a new subscriber, a renewal or a
+// prepaid card redemption) for every ancestor of the seller whose plan has a rule for
+// that tier. Sales charged to a reseller are negative and sales paid by the subscriber
+// are positive; both count at face value. Posting the same transaction twice adds
+// nothing. Returns the entries added.
+func Post(tx *models.Transaction) (int, error) {
+	if tx == nil || tx.ID == 0 || tx.ResellerID == 0 || tx.Amount == 0 {
+		return 0, nil
+	}
+	switch tx.Type {
+	case models.TransactionTypeNew, models.TransactionTypeRenewal, models.TransactionTypePrepaidCard:
+	default:
+		return 0, nil
+	}
+	base := math.Abs(tx.Amount)
+
+	var serviceID *uint
+	if tx.SubscriberID != nil {
+		var sub models.Subscriber
+		if err := database.DB.Select("id, service_id").First(&sub, *tx.SubscriberID).Error; err == nil && sub.ServiceID > 0 {
+			id := sub.ServiceID
+			serviceID = &id
+		}
+	}
+
+	var seller models.Reseller
+	if err := database.DB.First(&seller, tx.ResellerID).Error; err != nil {
+		return 0, err
+	}
+
+	plans := make(map[uint]*models.CommissionPlan)
+	visited := map[uint]bool{seller.ID: true}
+	current := seller
+	added := 0
+	for tier := 1; tier <= maxTiers() && current.ParentID != nil; tier++ {
+		var earner models.Reseller
+		if err := database.DB.First(&earner, *current.ParentID).Error; err != nil {
+			break
+		}
+		if visited[earner.ID] {
+			log.Printf("Commission: Reseller tree loop at reseller %d, stopping", earner.ID)
+			break
+		}
+		visited[earner.ID] = true
+		current = earner
+
+		plan := planFor(&earner, plans)
+		if plan == nil {
+			continue
+		}
+		rule := Rule(plan, tier, serviceID)
+		if rule == nil {
+			continue
+		}
+		amount := Amount(rule, base)
+		if amount == 0 {
+			continue
+		}
+
+		entry := models.Commission{
+			ResellerID:       earner.ID,
+			SourceResellerID: seller.ID,
+			TransactionID:    tx.ID,
+			SubscriberID:     tx.SubscriberID,
+			ServiceID:        serviceID,
+			SaleType:         string(tx.Type),
+			Tier:             tier,
+			BaseAmount:       base,
+			RateType:         rule.Type,
+			RateValue:        rule.Value,
+			Amount:           amount,
+			CreatedAt:        tx.CreatedAt,
+		}
+		result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+		if result.Error != nil {
+			return added, result.Error
+		}
+		added += int(result.RowsAffected)
+	}
+	return added, nil
+}
+
+// PostAsync posts a sale's commissions in the background; failures are logged. Used by
+// the handlers right after they record the sale.
+func PostAsync(tx models.Transaction) {
+	go func() {
+		if _, err := Post(&tx); err != nil {
+			log.Printf("Commission: Failed to post commissions of transaction %d: %v", tx.ID, err)
+		}
+	}()
+}
+
+// PeriodBounds returns the first instant of a YYYY-MM period and of the following month
+func PeriodBounds(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, use YYYY-MM", period)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// GenerateSettlements rolls the unsettled entries of a month into one statement per
+// earning reseller. It can be run again: entries posted late are added to the open
+// statement and paid statements are left alone. Returns the statements touched.
+func GenerateSettlements(period string) (int, error) {
+	start, end, err := PeriodBounds(period)
+	if err != nil {
+		return 0, err
+	}
+
+	var resellerIDs []uint
+	err = database.DB.Model(&models.Commission{}).
+		Where("settlement_id IS NULL AND created_at >= ? AND created_at < ?", start, end).
+		Distinct("reseller_id").Pluck("reseller_id", &resellerIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	touched := 0
+	for _, resellerID := range resellerIDs {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			settlement := models.CommissionSettlement{ResellerID: resellerID, Period: period}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("reseller_id = ? AND period = ?", resellerID, period).
+				Attrs(models.CommissionSettlement{PeriodStart: start, PeriodEnd: end, Status: "open"}).
+				FirstOrCreate(&settlement).Error; err != nil {
+				return err
+			}
+			if settlement.Status != "open" {
+				return nil
+			}
+
+			if err := tx.Model(&models.Commission{}).
+				Where("reseller_id = ? AND settlement_id IS NULL AND created_at >= ? AND created_at < ?", resellerID, start, end).
+				Update("settlement_id", settlement.ID).Error; err != nil {
+				return err
+			}
+
+			var totals struct {
+				Count int
+				Total float64
+			}
+			tx.Model(&models.Commission{}).Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total").
+				Where("settlement_id = ?", settlement.ID).Scan(&totals)
+			touched++
+			return tx.Model(&settlement).Updates(map[string]interface{}{
+				"entry_count": totals.Count,
+				"total":       totals.Total,
+			}).Error
+		})
+		if err != nil {
+			return touched, err
+		}
+	}
+	return touched, nil
+}
+
+// Pay marks an open statement paid. With method "balance" the total is credited to the
+// reseller's balance as a commission transaction; "external" only records the reference
+// of a payout made outside the system.
+func Pay(settlementID uint, method, reference, notes string, userID uint) (*models.CommissionSettlement, error) {
+	if method != "balance" && method != "external" {
+		return nil, fmt.Errorf("payout method must be balance or external")
+	}
+
+	var settlement models.CommissionSettlement
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&settlement, settlementID).Error; err != nil {
+			return fmt.Errorf("statement not found")
+		}
+		if settlement.Status != "open" {
+			return fmt.Errorf("statement is already %s", settlement.Status)
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":        "paid",
+			"payout_method": method,
+			"reference":     reference,
+			"notes":         notes,
+			"paid_at":       now,
+			"paid_by":       userID,
+		}
+
+		if method == "balance" && settlement.Total != 0 {
+			var reseller models.Reseller
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reseller, settlement.ResellerID).Error; err != nil {
+				return fmt.Errorf("reseller not found")
+			}
+			if err := tx.Model(&reseller).Update("balance", gorm.Expr("balance + ?", settlement.Total)).Error; err != nil {
+				return err
+			}
+			credit := models.Transaction{
+				Type:          models.TransactionTypeCommission,
+				Amount:        settlement.Total,
+				BalanceBefore: reseller.Balance,
+				BalanceAfter:  reseller.Balance + settlement.Total,
+				ResellerID:    reseller.ID,
+				Description:   fmt.Sprintf("Commission for %s", settlement.Period),
+				CreatedBy:     userID,
+			}
+			if err := tx.Create(&credit).Error; err != nil {
+				return err
+			}
+			updates["transaction_id"] = credit.ID
+		}
+
+		return tx.Model(&settlement).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	database.DB.First(&settlement, settlementID)
+	return &settlement, nil
+}
@@ -11,7 +11,6 @@ import (
 const (
 	// Cache key prefixes
 	CacheKeySettings       = "proisp:settings"
-	CacheKeyTokenBlacklist = "proisp:token:blacklist:" // Token blacklist for logout
 	CacheKeyDashboardStats = "proisp:dashboard:stats:" // Dashboard stats cache
 
 	// Cache TTLs
@@ -39,6 +38,16 @@ func CacheSet(key string, value interface{}, ttl time.Duration) error {
 	return Redis.Set(ctx, key, data, ttl).Err()
 }
 
+// CacheTake retrieves and deletes a value in one step, for one-time tokens and challenges
+func CacheTake(key string, dest interface{}) error {
+	ctx := context.Background()
+	data, err := Redis.GetDel(ctx, key).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
 // CacheDelete removes a key from Redis cache
 func CacheDelete(keys ...string) error {
 	if len(keys) == 0 {
@@ -70,25 +79,6 @@ func InvalidateSettingsCache() {
 	CacheDelete(CacheKeySettings)
 }
 
-// BlacklistToken adds a token to the blacklist (called on logout)
-// The token stays blacklisted until its original expiry time
-func BlacklistToken(token string, expiryDuration time.Duration) error {
-	ctx := context.Background()
-	key := CacheKeyTokenBlacklist + token
-	return Redis.Set(ctx, key, "1", expiryDuration).Err()
-}
-
-// IsTokenBlacklisted checks if a token has been blacklisted (logged out)
-func IsTokenBlacklisted(token string) bool {
-	ctx := context.Background()
-	key := CacheKeyTokenBlacklist + token
-	exists, err := Redis.Exists(ctx, key).Result()
-	if err != nil {
-		return false // On error, allow token (fail open)
-	}
-	return exists > 0
-}
-
 // GetCompanyName retrieves the company name from system preferences for branding
 // Returns empty string if not set (never returns "ProISP" as default)
 func GetCompanyName() string {
@@ -128,15 +128,8 @@ func EnsureIndexes() {
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_transactions_type ON transactions(type)",
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_transactions_created_at ON transactions(created_at)",
 
-		// RADIUS accounting
-		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_radacct_username ON radacct(username)",
-		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_radacct_nasipaddress ON radacct(nasipaddress)",
-		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_radacct_acctstarttime ON radacct(acctstarttime)",
-		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_radacct_acctstoptime ON radacct(acctstoptime)",
-		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_radacct_acctsessionid ON radacct(acctsessionid)",
-
-		// Active sessions index for QuotaSync
-		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_radacct_active ON radacct(username, acctstarttime) WHERE acctstoptime IS NULL",
+		// RADIUS accounting indexes live on the partitioned radacct table, see services.EnsureRadAcctPartitioned
+		// (partitioned tables cannot be indexed CONCURRENTLY)
 
 		// Services
 		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_services_name ON services(name)",
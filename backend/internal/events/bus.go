@@ -0,0 +1,60 @@
+// Package events is the in-process event bus. Everything published through
+// webhook.Emit is also published here, so in-process consumers such as the
+// communication rules see the same events (and payloads) as webhook endpoints.
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event is a published event. Data is the event payload; subscriber events carry the
+// subscriber's fields (see webhook.SubscriberData) and other events a subscriber_id
+// when they concern one.
+type Event struct {
+	Name string
+	Data map[string]interface{}
+	At   time.Time
+}
+
+// Handler consumes events. Handlers run in their own goroutine and must not assume
+// any ordering between events.
+type Handler func(Event)
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+)
+
+// Subscribe registers a handler for every published event
+func Subscribe(h Handler) {
+	mu.Lock()
+	handlers = append(handlers, h)
+	mu.Unlock()
+}
+
+// Publish hands an event to every subscribed handler without blocking the caller.
+// Payloads that are not a map are published without data.
+func Publish(name string, data interface{}) {
+	mu.RLock()
+	subscribed := handlers
+	mu.RUnlock()
+	if len(subscribed) == 0 {
+		return
+	}
+
+	payload, _ := data.(map[string]interface{})
+	event := Event{Name: name, Data: payload, At: time.Now()}
+
+	for _, h := range subscribed {
+		go func(h Handler) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Events: Handler for %s panicked: %v", name, r)
+				}
+			}()
+			h(event)
+		}(h)
+	}
+}
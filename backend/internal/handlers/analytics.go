@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/proisp/backend/internal/analytics"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+// AnalyticsHandler serves the precomputed subscriber analytics
+type AnalyticsHandler struct{}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler() *AnalyticsHandler {
+	return &AnalyticsHandler{}
+}
+
+// AnalyticsTrend is the change of a segment's metrics against an earlier month. Rates
+// change in points; the *Pct fields are relative changes and nil when the earlier
+// value is zero.
+type AnalyticsTrend struct {
+	Period         string   `json:"period"`
+	ActiveEnd      int      `json:"active_end"`
+	NewSubscribers int      `json:"new_subscribers"`
+	Churned        int      `json:"churned"`
+	Renewed        int      `json:"renewed"`
+	Revenue        float64  `json:"revenue"`
+	ARPU           float64  `json:"arpu"`
+	ChurnRate      float64  `json:"churn_rate"`
+	RenewalRate    float64  `json:"renewal_rate"`
+	ActiveEndPct   *float64 `json:"active_end_pct"`
+	RevenuePct     *float64 `json:"revenue_pct"`
+	ARPUPct        *float64 `json:"arpu_pct"`
+}
+
+// AnalyticsOverviewResponse is a segment's month with its trend comparisons
+type AnalyticsOverviewResponse struct {
+	Current      *models.AnalyticsMonthly  `json:"current"`
+	Previous     *models.AnalyticsMonthly  `json:"previous"`
+	YearAgo      *models.AnalyticsMonthly  `json:"year_ago"`
+	VsPrevious   *AnalyticsTrend           `json:"vs_previous"`
+	VsYearAgo    *AnalyticsTrend           `json:"vs_year_ago"`
+	Series       []models.AnalyticsMonthly `json:"series"`
+	Breakdown    []models.AnalyticsMonthly `json:"breakdown"`
+	LastComputed *time.Time                `json:"last_computed"`
+}
+
+// analyticsSegment resolves the dimension and key of a request. Resellers only ever see
+// their own segment.
+func analyticsSegment(c *fiber.Ctx) (string, string, error) {
+	user := middleware.GetCurrentUser(c)
+	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		return "reseller", strconv.FormatUint(uint64(*user.ResellerID), 10), nil
+	}
+
+	dimension := c.Query("dimension", "all")
+	if !analytics.ValidDimension(dimension) {
+		return "", "", fmt.Errorf("Invalid dimension: %s", dimension)
+	}
+	key := c.Query("key")
+	if dimension == "all" {
+		key = "all"
+	}
+	return dimension, key, nil
+}
+
+// analyticsMonth parses a YYYY-MM query parameter
+func analyticsMonth(c *fiber.Ctx, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	month, err := time.ParseInLocation("2006-01", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s, expected YYYY-MM", name)
+	}
+	return &month, nil
+}
+
+func analyticsPct(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	pct := (current - previous) / previous * 100
+	return &pct
+}
+
+func analyticsTrend(current, earlier *models.AnalyticsMonthly) *AnalyticsTrend {
+	if current == nil || earlier == nil {
+		return nil
+	}
+	return &AnalyticsTrend{
+		Period:         earlier.Period.Format("2006-01"),
+		ActiveEnd:      current.ActiveEnd - earlier.ActiveEnd,
+		NewSubscribers: current.NewSubscribers - earlier.NewSubscribers,
+		Churned:        current.Churned - earlier.Churned,
+		Renewed:        current.Renewed - earlier.Renewed,
+		Revenue:        current.Revenue - earlier.Revenue,
+		ARPU:           current.ARPU - earlier.ARPU,
+		ChurnRate:      current.ChurnRate - earlier.ChurnRate,
+		RenewalRate:    current.RenewalRate - earlier.RenewalRate,
+		ActiveEndPct:   analyticsPct(float64(current.ActiveEnd), float64(earlier.ActiveEnd)),
+		RevenuePct:     analyticsPct(current.Revenue, earlier.Revenue),
+		ARPUPct:        analyticsPct(current.ARPU, earlier.ARPU),
+	}
+}
+
+func analyticsRow(dimension, key string, period time.Time) *models.AnalyticsMonthly {
+	var row models.AnalyticsMonthly
+	if err := database.DB.Where("period = ? AND dimension = ? AND dimension_key = ?", period, dimension, key).
+		First(&row).Error; err != nil {
+		return nil
+	}
+	return &row
+}
+
+// Overview returns a segment's metrics for a month (default: the latest computed one)
+// compared with the month before and the same month a year earlier, its trend over the
+// computed window and, for a dimension without a key, the month of every segment.
+func (h *AnalyticsHandler) Overview(c *fiber.Ctx) error {
+	dimension, key, err := analyticsSegment(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	period, err := analyticsMonth(c, "period")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	if period == nil {
+		var latest models.AnalyticsMonthly
+		if err := database.DB.Order("period DESC").First(&latest).Error; err != nil {
+			return c.JSON(fiber.Map{"success": true, "data": AnalyticsOverviewResponse{}})
+		}
+		p := latest.Period
+		period = &p
+	}
+
+	response := AnalyticsOverviewResponse{}
+	if key != "" {
+		response.Current = analyticsRow(dimension, key, *period)
+		response.Previous = analyticsRow(dimension, key, period.AddDate(0, -1, 0))
+		response.YearAgo = analyticsRow(dimension, key, period.AddDate(-1, 0, 0))
+		response.VsPrevious = analyticsTrend(response.Current, response.Previous)
+		response.VsYearAgo = analyticsTrend(response.Current, response.YearAgo)
+
+		database.DB.Where("dimension = ? AND dimension_key = ? AND period <= ?", dimension, key, *period).
+			Order("period").Find(&response.Series)
+	} else {
+		database.DB.Where("dimension = ? AND period = ?", dimension, *period).
+			Order("revenue DESC, dimension_key").Find(&response.Breakdown)
+	}
+
+	var computed models.AnalyticsMonthly
+	if database.DB.Order("computed_at DESC").First(&computed).Error == nil {
+		response.LastComputed = &computed.ComputedAt
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": response})
+}
+
+// Metrics lists the monthly metrics of a dimension, optionally one segment and a range
+// of months
+func (h *AnalyticsHandler) Metrics(c *fiber.Ctx) error {
+	dimension, key, err := analyticsSegment(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	from, err := analyticsMonth(c, "from")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	to, err := analyticsMonth(c, "to")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	query := database.DB.Where("dimension = ?", dimension)
+	if key != "" {
+		query = query.Where("dimension_key = ?", key)
+	}
+	if from != nil {
+		query = query.Where("period >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("period <= ?", *to)
+	}
+
+	var rows []models.AnalyticsMonthly
+	if err := query.Order("period, dimension_key").Find(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to fetch analytics"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": rows})
+}
+
+// Cohorts lists the retention of each signup month: how many of its subscribers are
+// still active every month after
+func (h *AnalyticsHandler) Cohorts(c *fiber.Ctx) error {
+	dimension, key, err := analyticsSegment(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "key is required for this dimension"})
+	}
+	from, err := analyticsMonth(c, "from")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+
+	query := database.DB.Where("dimension = ? AND dimension_key = ?", dimension, key)
+	if from != nil {
+		query = query.Where("cohort >= ?", *from)
+	}
+
+	var rows []models.AnalyticsCohort
+	if err := query.Order("cohort, month_offset").Find(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to fetch cohorts"})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": rows})
+}
+
+// Recompute refreshes the analytics now instead of waiting for the nightly run
+func (h *AnalyticsHandler) Recompute(c *fiber.Ctx) error {
+	if services.Analytics == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"success": false, "message": "Analytics service is not running"})
+	}
+	go services.Analytics.RunNow()
+
+	c.Locals("audit_description", "Recomputed subscriber analytics")
+	return c.JSON(fiber.Map{"success": true, "message": "Analytics recompute started"})
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+)
+
+type APIKeyHandler struct{}
+
+func NewAPIKeyHandler() *APIKeyHandler {
+	return &APIKeyHandler{}
+}
+
+type APIKeyRequest struct {
+	Name       string     `json:"name"`
+	UserID     uint       `json:"user_id"` // Admins only; defaults to the caller
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	IsActive   *bool      `json:"is_active"`
+}
+
+// List returns API keys (resellers only see their own)
+func (h *APIKeyHandler) List(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+
+	query := database.DB.Model(&models.APIKey{})
+	if user.UserType != models.UserTypeAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	} else if userID := c.QueryInt("user_id", 0); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch API keys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+// Create issues a new API key. The plain key is only returned in this response.
+func (h *APIKeyHandler) Create(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+
+	// Keys cannot mint other keys
+	if middleware.GetAPIKey(c) != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "API keys cannot be created with an API key",
+		})
+	}
+
+	var req APIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required",
+		})
+	}
+
+	// Owner: the caller, or any staff/reseller user when an admin creates it
+	owner := *user
+	if req.UserID != 0 && req.UserID != user.ID {
+		if user.UserType != models.UserTypeAdmin {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Only admins can create keys for other users",
+			})
+		}
+		if err := database.DB.First(&owner, req.UserID).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "User not found",
+			})
+		}
+	}
+
+	scopes, msg := normalizeAPIKeyScopes(req.Scopes)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+	allowedIPs, msg := normalizeAPIKeyIPs(req.AllowedIPs)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Expiry must be in the future",
+		})
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate key",
+		})
+	}
+	plainKey := models.APIKeyPrefix + hex.EncodeToString(secret)
+
+	key := models.APIKey{
+		Name:       req.Name,
+		KeyPrefix:  plainKey[:12],
+		KeyHash:    middleware.HashAPIKey(plainKey),
+		UserID:     owner.ID,
+		Username:   owner.Username,
+		ResellerID: owner.ResellerID,
+		Scopes:     scopes,
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+		IsActive:   true,
+		CreatedBy:  user.Username,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create API key",
+		})
+	}
+
+	c.Locals("audit_description", fmt.Sprintf("Created API key %s (%s) for %s with scopes %s", key.Name, key.KeyPrefix, owner.Username, scopes))
+	c.Locals("audit_entity_id", key.ID)
+	c.Locals("audit_entity_name", key.Name)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "API key created. Copy it now - it will not be shown again.",
+		"key":     plainKey,
+		"data":    key,
+	})
+}
+
+// Update changes name, scopes, IP allowlist, expiry or active state of a key
+func (h *APIKeyHandler) Update(c *fiber.Ctx) error {
+	// Keys cannot manage keys, including themselves
+	if middleware.GetAPIKey(c) != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "API keys cannot be changed with an API key",
+		})
+	}
+
+	key, ferr := h.getKey(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var req APIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Scopes != nil {
+		scopes, msg := normalizeAPIKeyScopes(req.Scopes)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": msg,
+			})
+		}
+		updates["scopes"] = scopes
+	}
+	if req.AllowedIPs != nil {
+		allowedIPs, msg := normalizeAPIKeyIPs(req.AllowedIPs)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": msg,
+			})
+		}
+		updates["allowed_ips"] = allowedIPs
+	}
+	if req.ExpiresAt != nil {
+		updates["expires_at"] = req.ExpiresAt
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(key).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to update API key",
+			})
+		}
+	}
+	database.DB.First(key, key.ID)
+
+	c.Locals("audit_description", fmt.Sprintf("Updated API key %s (%s)", key.Name, key.KeyPrefix))
+	c.Locals("audit_entity_id", key.ID)
+	c.Locals("audit_entity_name", key.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "API key updated",
+		"data":    key,
+	})
+}
+
+// Delete revokes and removes a key
+func (h *APIKeyHandler) Delete(c *fiber.Ctx) error {
+	// Keys cannot manage keys, including themselves
+	if middleware.GetAPIKey(c) != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "API keys cannot be revoked with an API key",
+		})
+	}
+
+	key, ferr := h.getKey(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	if err := database.DB.Delete(key).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete API key",
+		})
+	}
+
+	c.Locals("audit_description", fmt.Sprintf("Revoked API key %s (%s)", key.Name, key.KeyPrefix))
+	c.Locals("audit_entity_id", key.ID)
+	c.Locals("audit_entity_name", key.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "API key revoked",
+	})
+}
+
+// getKey loads the key from :id; non-admins can only reach their own keys
+func (h *APIKeyHandler) getKey(c *fiber.Ctx) (*models.APIKey, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid API key ID")
+	}
+
+	query := database.DB.Where("id = ?", id)
+	if user := middleware.GetCurrentUser(c); user.UserType != models.UserTypeAdmin {
+		query = query.Where("user_id = ?", user.ID)
+	}
+
+	var key models.APIKey
+	if err := query.First(&key).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "API key not found")
+	}
+	return &key, nil
+}
+
+// normalizeAPIKeyScopes checks scopes against the permission names and joins them.
+// Accepts "*", "<group>.*" and exact permission names.
+func normalizeAPIKeyScopes(scopes []string) (string, string) {
+	if len(scopes) == 0 {
+		return "", "At least one scope is required"
+	}
+
+	var names []string
+	database.DB.Model(&models.Permission{}).Pluck("name", &names)
+	known := make(map[string]bool, len(names))
+	groups := make(map[string]bool)
+	for _, name := range names {
+		known[name] = true
+		if idx := strings.Index(name, "."); idx > 0 {
+			groups[name[:idx]] = true
+		}
+	}
+
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		switch {
+		case scope == "":
+			continue
+		case scope == models.APIKeyScopeAll:
+		case strings.HasSuffix(scope, ".*"):
+			if !groups[strings.TrimSuffix(scope, ".*")] {
+				return "", fmt.Sprintf("Unknown scope group: %s", scope)
+			}
+		case !known[scope]:
+			return "", fmt.Sprintf("Unknown scope: %s", scope)
+		}
+		result = append(result, scope)
+	}
+	if len(result) == 0 {
+		return "", "At least one scope is required"
+	}
+	return strings.Join(result, ","), ""
+}
+
+// normalizeAPIKeyIPs validates the IP allowlist (single addresses or CIDRs)
+func normalizeAPIKeyIPs(ips []string) (string, string) {
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		if strings.Contains(ip, "/") {
+			if _, _, err := net.ParseCIDR(ip); err != nil {
+				return "", fmt.Sprintf("Invalid CIDR: %s", ip)
+			}
+		} else if net.ParseIP(ip) == nil {
+			return "", fmt.Sprintf("Invalid IP address: %s", ip)
+		}
+		result = append(result, ip)
+	}
+	return strings.Join(result, ","), ""
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import "github.com/proisp/backend/internal/models"
+
+// Typed response bodies for the main resources. Handlers return these instead of fiber.Map
+// so the OpenAPI document (openapi_routes.go) describes exactly what is sent.
+
+// ListMeta is the pagination block of page/limit lists
+type ListMeta struct {
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"totalPages"`
+}
+
+func newListMeta(page, limit int, total int64) ListMeta {
+	return ListMeta{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: (total + int64(limit) - 1) / int64(limit),
+	}
+}
+
+// MessageResponse is returned by actions that have no data to send back
+type MessageResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// Subscribers
+
+type SubscriberListMeta struct {
+	ListMeta
+	WanCheckEnabled bool   `json:"wan_check_enabled"`
+	WanCheckPort    string `json:"wan_check_port"`
+}
+
+// SubscriberStats counts subscribers matching the list filters, for the summary cards
+type SubscriberStats struct {
+	Total      int64 `json:"total"`
+	Online     int64 `json:"online"`
+	Offline    int64 `json:"offline"`
+	Active     int64 `json:"active"`
+	Inactive   int64 `json:"inactive"`
+	Expired    int64 `json:"expired"`
+	Expiring   int64 `json:"expiring"`
+	FUP0       int64 `json:"fup0"`
+	FUP1       int64 `json:"fup1"`
+	FUP2       int64 `json:"fup2"`
+	FUP3       int64 `json:"fup3"`
+	FUP4       int64 `json:"fup4"`
+	FUP5       int64 `json:"fup5"`
+	FUP6       int64 `json:"fup6"`
+	MonthlyFUP int64 `json:"monthly_fup"`
+}
+
+type SubscriberListResponse struct {
+	Success bool                `json:"success"`
+	Data    []models.Subscriber `json:"data"`
+	Meta    SubscriberListMeta  `json:"meta"`
+	Stats   SubscriberStats     `json:"stats"`
+}
+
+type SubscriberResponse struct {
+	Success bool              `json:"success"`
+	Message string            `json:"message,omitempty"`
+	Data    models.Subscriber `json:"data"`
+}
+
+type SubscriberDailyQuota struct {
+	DownloadUsed  int64   `json:"download_used"`
+	UploadUsed    int64   `json:"upload_used"`
+	TotalUsed     int64   `json:"total_used"`
+	DownloadLimit int64   `json:"download_limit"`
+	UploadLimit   int64   `json:"upload_limit"`
+	DailyDownload []int64 `json:"daily_download"` // Bytes per day of the current month
+	DailyUpload   []int64 `json:"daily_upload"`
+}
+
+type SubscriberMonthlyQuota struct {
+	DownloadUsed  int64 `json:"download_used"`
+	UploadUsed    int64 `json:"upload_used"`
+	TotalUsed     int64 `json:"total_used"`
+	DownloadLimit int64 `json:"download_limit"`
+	UploadLimit   int64 `json:"upload_limit"`
+}
+
+// SubscriberDetailResponse is the edit form view of one subscriber
+type SubscriberDetailResponse struct {
+	Success      bool                   `json:"success"`
+	Data         models.Subscriber      `json:"data"`
+	Password     string                 `json:"password"`
+	Sessions     []models.RadAcct       `json:"sessions"`
+	DailyQuota   SubscriberDailyQuota   `json:"daily_quota"`
+	MonthlyQuota SubscriberMonthlyQuota `json:"monthly_quota"`
+}
+
+// Services
+
+type ServiceListResponse struct {
+	Success bool             `json:"success"`
+	Data    []models.Service `json:"data"`
+}
+
+type ServiceResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message,omitempty"`
+	Data    models.Service `json:"data"`
+}
+
+type ServiceDetailResponse struct {
+	Success         bool           `json:"success"`
+	Data            models.Service `json:"data"`
+	SubscriberCount int64          `json:"subscriber_count"`
+}
+
+// NAS
+
+type NasListResponse struct {
+	Success bool         `json:"success"`
+	Data    []models.Nas `json:"data"`
+}
+
+// NasResponse is returned by create and update, which also import the router's IP pools
+type NasResponse struct {
+	Success        bool       `json:"success"`
+	Message        string     `json:"message,omitempty"`
+	Data           models.Nas `json:"data"`
+	IPPoolsImport  bool       `json:"ip_pools_import"`
+	IPPoolsMessage string     `json:"ip_pools_message"`
+}
+
+type NasDetailResponse struct {
+	Success        bool       `json:"success"`
+	Data           models.Nas `json:"data"`
+	ActiveSessions int64      `json:"active_sessions"`
+}
+
+// Resellers
+
+type ResellerWithCount struct {
+	models.Reseller
+	SubscriberCount int64 `json:"subscriber_count"`
+}
+
+type ResellerListResponse struct {
+	Success bool                `json:"success"`
+	Data    []ResellerWithCount `json:"data"`
+	Meta    ListMeta            `json:"meta"`
+}
+
+type ResellerStats struct {
+	TotalSubscribers  int64   `json:"total_subscribers"`
+	ActiveSubscribers int64   `json:"active_subscribers"`
+	TotalRevenue      float64 `json:"total_revenue"`
+}
+
+type ResellerResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message,omitempty"`
+	Data    models.Reseller `json:"data"`
+}
+
+type ResellerDetailResponse struct {
+	Success bool            `json:"success"`
+	Data    models.Reseller `json:"data"`
+	Stats   ResellerStats   `json:"stats"`
+}
+
+// Invoices
+
+type InvoiceListResponse struct {
+	Success bool             `json:"success"`
+	Data    []models.Invoice `json:"data"`
+	Meta    ListMeta         `json:"meta"`
+}
+
+type InvoiceResponse struct {
+	Success bool           `json:"success"`
+	Message string         `json:"message,omitempty"`
+	Data    models.Invoice `json:"data"`
+}
+
+// Sessions
+
+type SessionListResponse struct {
+	Success bool            `json:"success"`
+	Data    []ActiveSession `json:"data"`
+	Meta    ListMeta        `json:"meta"`
+}
+
+type SessionResponse struct {
+	Success bool           `json:"success"`
+	Data    models.RadAcct `json:"data"`
+}
@@ -8,11 +8,11 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/pquerna/otp/totp"
 	"github.com/proisp/backend/internal/config"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/webauthn"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -150,9 +150,12 @@ func NewAuthHandler(cfg *config.Config) *AuthHandler {
 
 // LoginRequest represents login request body
 type LoginRequest struct {
-	Username string `json:"username" validate:"required"`
-	Password string `json:"password" validate:"required"`
-	TwoFACode string `json:"two_fa_code"`
+	Username     string                       `json:"username" validate:"required"`
+	Password     string                       `json:"password" validate:"required"`
+	TwoFACode    string                       `json:"two_fa_code"`
+	RecoveryCode string                       `json:"recovery_code"` // One-time code instead of the second factor
+	WebAuthn     *webauthn.CredentialResponse `json:"webauthn"`      // Security key assertion for the options returned with requires_2fa
+	Device       string                       `json:"device"`        // Optional device name, defaults to one derived from User-Agent
 }
 
 // LoginResponse represents login response
@@ -160,9 +163,16 @@ type LoginResponse struct {
 	Success             bool      `json:"success"`
 	Message             string    `json:"message,omitempty"`
 	Token               string    `json:"token,omitempty"`
+	RefreshToken        string    `json:"refresh_token,omitempty"`
+	ExpiresIn           int       `json:"expires_in,omitempty"`
 	User                *UserInfo `json:"user,omitempty"`
 	Requires2FA         bool      `json:"requires_2fa,omitempty"`
 	ForcePasswordChange bool      `json:"force_password_change,omitempty"`
+	// Second factor challenge, sent with requires_2fa
+	TwoFAMethods []string                 `json:"two_fa_methods,omitempty"`
+	WebAuthn     *webauthn.RequestOptions `json:"webauthn,omitempty"`
+	// The 2FA policy covers this user but nothing is enrolled yet; only 2FA setup is reachable
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
 }
 
 // UserInfo represents user info in response
@@ -177,40 +187,13 @@ type UserInfo struct {
 	ForcePasswordChange bool            `json:"force_password_change"`
 }
 
-// getResellerPermissions returns the list of permission names for a reseller
-func getResellerPermissions(resellerID uint) []string {
-	var reseller models.Reseller
-	if err := database.DB.First(&reseller, resellerID).Error; err != nil {
+// getUserPermissions returns the permission names granted to a non-admin user
+// (their own permission group, or for resellers the reseller's group)
+func getUserPermissions(user *models.User) []string {
+	if user.UserType == models.UserTypeAdmin {
 		return nil
 	}
-
-	if reseller.PermissionGroup == nil {
-		return nil
-	}
-
-	// Load permissions from junction table (Preload doesn't work with gorm:"-")
-	var permissions []string
-	database.DB.Table("permissions").
-		Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
-		Where("pgp.permission_group_id = ?", *reseller.PermissionGroup).
-		Pluck("name", &permissions)
-
-	return permissions
-}
-
-// getUserPermissions returns the list of permission names for a user based on their permission_group
-func getUserPermissions(permissionGroupID *uint) []string {
-	if permissionGroupID == nil {
-		return nil
-	}
-
-	var permissions []string
-	database.DB.Table("permissions").
-		Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
-		Where("pgp.permission_group_id = ?", *permissionGroupID).
-		Pluck("name", &permissions)
-
-	return permissions
+	return middleware.GetUserPermissions(user).List()
 }
 
 // Login handles user login
@@ -296,20 +279,37 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	// Accounts managed by an identity provider may be barred from password login
+	if allowed, msg := localLoginAllowed(&user); !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(LoginResponse{
+			Success: false,
+			Message: msg,
+		})
+	}
+
 	// Check if 2FA is enabled for this user
 	if user.TwoFactorEnabled {
-		if req.TwoFACode == "" {
-			// Password is correct, but need 2FA code
+		if req.TwoFACode == "" && req.RecoveryCode == "" && req.WebAuthn == nil {
+			// Password is correct, but need a second factor
+			methods, webauthnOptions := twoFactorLoginOptions(c, &user)
 			return c.JSON(LoginResponse{
-				Success:     false,
-				Requires2FA: true,
-				Message:     "2FA code required",
+				Success:      false,
+				Requires2FA:  true,
+				Message:      "2FA code required",
+				TwoFAMethods: methods,
+				WebAuthn:     webauthnOptions,
 			})
 		}
-		// Verify 2FA code
-		if !totp.Validate(req.TwoFACode, user.TwoFactorSecret) {
+		// Verify the second factor
+		if method, ok := verifyLoginSecondFactor(c, &user, &req); !ok {
 			remaining := recordFailedAttempt(clientIP)
 			msg := "Invalid 2FA code"
+			switch method {
+			case models.TwoFactorMethodWebAuthn:
+				msg = "Security key verification failed"
+			case models.TwoFactorMethodRecovery:
+				msg = "Invalid or already used recovery code"
+			}
 			if remaining > 0 {
 				msg += ". " + strconv.Itoa(remaining) + " attempts remaining"
 			}
@@ -323,8 +323,8 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	// Clear failed attempts on successful login
 	clearFailedAttempts(clientIP)
 
-	// Generate token
-	token, err := middleware.GenerateToken(&user, h.cfg)
+	// Start a login session
+	tokens, err := middleware.CreateSession(&user, h.cfg, clientIP, c.Get("User-Agent"), req.Device, nil)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(LoginResponse{
 			Success: false,
@@ -351,21 +351,16 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 	database.DB.Create(&auditLog)
 
-	// Get permissions for reseller users
-	var permissions []string
-	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
-		permissions = getResellerPermissions(*user.ResellerID)
-	}
-
-	// Get permissions for non-reseller users (support, collector, readonly) based on their permission_group
-	if user.UserType != models.UserTypeReseller && user.UserType != models.UserTypeAdmin {
-		permissions = getUserPermissions(user.PermissionGroup)
-	}
+	// Get permissions for non-admin users (resellers, support, collector, readonly)
+	permissions := getUserPermissions(&user)
 
 	return c.JSON(LoginResponse{
-		Success:             true,
-		Token:               token,
-		ForcePasswordChange: user.ForcePasswordChange,
+		Success:                true,
+		Token:                  tokens.AccessToken,
+		RefreshToken:           tokens.RefreshToken,
+		ExpiresIn:              tokens.ExpiresIn,
+		ForcePasswordChange:    user.ForcePasswordChange,
+		TwoFactorSetupRequired: !user.TwoFactorEnabled && middleware.TwoFactorRequired(user.UserType),
 		User: &UserInfo{
 			ID:                  user.ID,
 			Username:            user.Username,
@@ -399,20 +394,13 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 		database.DB.Create(&auditLog)
 	}
 
-	// Add token to blacklist in Redis
-	// This prevents the token from being used after logout
-	authHeader := c.Get("Authorization")
-	if authHeader != "" {
-		parts := strings.Split(authHeader, " ")
-		if len(parts) == 2 && parts[0] == "Bearer" {
-			token := parts[1]
-			// Blacklist token for the remaining JWT expiry duration (default 168 hours = 7 days)
-			expiryDuration := time.Duration(h.cfg.JWTExpireHours) * time.Hour
-			if err := database.BlacklistToken(token, expiryDuration); err != nil {
-				// Log error but don't fail logout
-				// Token will still expire naturally
-			}
+	// End the login session so its access and refresh tokens stop working
+	if sessionID := middleware.GetCurrentSessionID(c); sessionID != 0 {
+		revokedBy := ""
+		if user != nil {
+			revokedBy = user.Username
 		}
+		middleware.RevokeSession(sessionID, revokedBy, models.SessionRevokeLogout)
 	}
 
 	return c.JSON(fiber.Map{
@@ -433,20 +421,13 @@ func (h *AuthHandler) Me(c *fiber.Ctx) error {
 
 	// Get reseller info if applicable
 	var reseller *models.Reseller
-	var permissions []string
 	if user.ResellerID != nil {
 		reseller = &models.Reseller{}
 		database.DB.First(reseller, *user.ResellerID)
-		// Get permissions for reseller
-		if user.UserType == models.UserTypeReseller {
-			permissions = getResellerPermissions(*user.ResellerID)
-		}
 	}
 
-	// Get permissions for non-reseller users (support, collector, readonly) based on their permission_group
-	if user.UserType != models.UserTypeReseller && user.UserType != models.UserTypeAdmin {
-		permissions = getUserPermissions(user.PermissionGroup)
-	}
+	// Get permissions for non-admin users (resellers, support, collector, readonly)
+	permissions := getUserPermissions(user)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -527,33 +508,56 @@ func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
 		})
 	}
 
+	// Log out every other device
+	middleware.RevokeUserSessions(user.ID, middleware.GetCurrentSessionID(c), user.Username, models.SessionRevokePassword)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Password changed successfully",
 	})
 }
 
-// RefreshToken generates a new token
+// RefreshToken exchanges a refresh token for a new access token and a rotated refresh token.
+// It is public: the access token may already have expired.
 func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
-	user := middleware.GetCurrentUser(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	c.BodyParser(&req)
+	if req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"message": "User not found",
+			"message": "refresh_token is required",
 		})
 	}
 
-	token, err := middleware.GenerateToken(user, h.cfg)
+	tokens, user, err := middleware.RefreshSession(req.RefreshToken, h.cfg, c.IP())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		status := fiber.StatusUnauthorized
+		if err == middleware.ErrSessionRotated {
+			status = fiber.StatusConflict
+		}
+		return c.Status(status).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to generate token",
+			"message": err.Error(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"token":   token,
+		"success":       true,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user": &UserInfo{
+			ID:                  user.ID,
+			Username:            user.Username,
+			Email:               user.Email,
+			FullName:            user.FullName,
+			UserType:            user.UserType,
+			ResellerID:          user.ResellerID,
+			Permissions:         getUserPermissions(user),
+			ForcePasswordChange: user.ForcePasswordChange,
+		},
 	})
 }
 
@@ -567,6 +571,7 @@ func HashPassword(password string) (string, error) {
 var (
 	impersonateTokens      = make(map[string]uint) // token -> reseller_id
 	impersonateTokenExpiry = make(map[string]time.Time)
+	impersonateTokenAdmins = make(map[string]uint) // token -> admin user_id
 	impersonateTokenMutex  sync.RWMutex
 )
 
@@ -632,6 +637,7 @@ func (h *AuthHandler) GetImpersonateToken(c *fiber.Ctx) error {
 	impersonateTokenMutex.Lock()
 	impersonateTokens[token] = uint(resellerID)
 	impersonateTokenExpiry[token] = expiry
+	impersonateTokenAdmins[token] = currentUser.ID
 	impersonateTokenMutex.Unlock()
 
 	// Clean up expired tokens
@@ -640,6 +646,7 @@ func (h *AuthHandler) GetImpersonateToken(c *fiber.Ctx) error {
 		impersonateTokenMutex.Lock()
 		delete(impersonateTokens, token)
 		delete(impersonateTokenExpiry, token)
+		delete(impersonateTokenAdmins, token)
 		impersonateTokenMutex.Unlock()
 	}()
 
@@ -672,6 +679,7 @@ func (h *AuthHandler) ExchangeImpersonateToken(c *fiber.Ctx) error {
 	impersonateTokenMutex.RLock()
 	resellerID, exists := impersonateTokens[req.Token]
 	expiry, _ := impersonateTokenExpiry[req.Token]
+	adminID, _ := impersonateTokenAdmins[req.Token]
 	impersonateTokenMutex.RUnlock()
 
 	if !exists {
@@ -686,6 +694,7 @@ func (h *AuthHandler) ExchangeImpersonateToken(c *fiber.Ctx) error {
 		impersonateTokenMutex.Lock()
 		delete(impersonateTokens, req.Token)
 		delete(impersonateTokenExpiry, req.Token)
+		delete(impersonateTokenAdmins, req.Token)
 		impersonateTokenMutex.Unlock()
 
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -698,6 +707,7 @@ func (h *AuthHandler) ExchangeImpersonateToken(c *fiber.Ctx) error {
 	impersonateTokenMutex.Lock()
 	delete(impersonateTokens, req.Token)
 	delete(impersonateTokenExpiry, req.Token)
+	delete(impersonateTokenAdmins, req.Token)
 	impersonateTokenMutex.Unlock()
 
 	// Get reseller and user
@@ -709,8 +719,8 @@ func (h *AuthHandler) ExchangeImpersonateToken(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate JWT token for the reseller
-	jwtToken, err := middleware.GenerateToken(reseller.User, h.cfg)
+	// Start a session for the reseller
+	tokens, err := middleware.CreateSession(reseller.User, h.cfg, c.IP(), c.Get("User-Agent"), "", &adminID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -719,13 +729,7 @@ func (h *AuthHandler) ExchangeImpersonateToken(c *fiber.Ctx) error {
 	}
 
 	// Get permissions for reseller
-	var permissions []string
-	if reseller.PermissionGroup != nil {
-		database.DB.Table("permissions").
-			Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
-			Where("pgp.permission_group_id = ?", *reseller.PermissionGroup).
-			Pluck("name", &permissions)
-	}
+	permissions := getUserPermissions(reseller.User)
 
 	// Build user response
 	userResponse := fiber.Map{
@@ -744,8 +748,10 @@ func (h *AuthHandler) ExchangeImpersonateToken(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"token": jwtToken,
-			"user":  userResponse,
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"user":          userResponse,
 		},
 	})
 }
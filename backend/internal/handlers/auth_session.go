@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+)
+
+// AuthSessionInfo is a login session as shown in the device list
+type AuthSessionInfo struct {
+	models.AuthSession
+	Current bool `json:"current"`
+}
+
+// listAuthSessions returns a user's sessions, newest activity first.
+// Revoked and expired sessions are included only when includeEnded is set.
+func listAuthSessions(userID, currentID uint, includeEnded bool) []AuthSessionInfo {
+	query := database.DB.Where("user_id = ?", userID)
+	if !includeEnded {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+	var sessions []models.AuthSession
+	query.Order("last_activity_at DESC").Limit(100).Find(&sessions)
+
+	result := make([]AuthSessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, AuthSessionInfo{AuthSession: s, Current: s.ID == currentID})
+	}
+	return result
+}
+
+// logSessionRevoke records a user ending their own sessions (/auth routes are not picked up by the audit middleware)
+func logSessionRevoke(c *fiber.Ctx, user *models.User, sessionID uint, device, description string) {
+	database.DB.Create(&models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionLogout,
+		EntityType:  "auth_session",
+		EntityID:    sessionID,
+		EntityName:  device,
+		Description: description,
+		IPAddress:   c.IP(),
+		UserAgent:   c.Get("User-Agent"),
+	})
+}
+
+// ListSessions returns the current user's active sessions (devices)
+func (h *AuthHandler) ListSessions(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    listAuthSessions(user.ID, middleware.GetCurrentSessionID(c), false),
+	})
+}
+
+// RevokeSession logs out one of the current user's sessions
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid session ID",
+		})
+	}
+
+	var session models.AuthSession
+	if err := database.DB.Where("id = ? AND user_id = ?", id, user.ID).First(&session).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Session not found",
+		})
+	}
+
+	if !middleware.RevokeSession(session.ID, user.Username, models.SessionRevokeUser) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Session is already revoked",
+		})
+	}
+
+	logSessionRevoke(c, user, session.ID, session.Device, fmt.Sprintf("Revoked session on %s (%s)", session.Device, session.IPAddress))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions logs the current user out everywhere except this device
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	count := middleware.RevokeUserSessions(user.ID, middleware.GetCurrentSessionID(c), user.Username, models.SessionRevokeUser)
+
+	logSessionRevoke(c, user, 0, "", fmt.Sprintf("Revoked %d other sessions", count))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("%d sessions revoked", count),
+		"data":    fiber.Map{"revoked": count},
+	})
+}
+
+// ListSessions returns a user's sessions for admins; ?all=true includes revoked and expired ones
+func (h *UserHandler) ListSessions(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    listAuthSessions(user.ID, middleware.GetCurrentSessionID(c), c.Query("all") == "true"),
+	})
+}
+
+// RevokeSessions force-logs a user out of every device (e.g. after a lost laptop)
+func (h *UserHandler) RevokeSessions(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	current := middleware.GetCurrentUser(c)
+	if user.UserType == models.UserTypeAdmin && (current == nil || current.UserType != models.UserTypeAdmin) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Only admins can revoke an admin's sessions",
+		})
+	}
+
+	revokedBy := ""
+	if current != nil {
+		revokedBy = current.Username
+	}
+	count := middleware.RevokeUserSessions(user.ID, 0, revokedBy, models.SessionRevokeAdmin)
+
+	c.Locals("audit_description", fmt.Sprintf("Revoked all sessions of %s (%d)", user.Username, count))
+	c.Locals("audit_entity_id", user.ID)
+	c.Locals("audit_entity_name", user.Username)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("%d sessions revoked", count),
+		"data":    fiber.Map{"revoked": count},
+	})
+}
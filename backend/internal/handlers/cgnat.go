@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/ippool"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+// CGNATPoolRequest is the body of POST/PUT /api/ip-pools/cgnat
+type CGNATPoolRequest struct {
+	Name          string `json:"name"`
+	PoolName      string `json:"pool_name"`
+	NasID         *uint  `json:"nas_id"`
+	PrivateRanges string `json:"private_ranges"` // e.g. "100.64.0.0/22" or "100.64.0.1-100.64.3.254"
+	PublicRanges  string `json:"public_ranges"`
+	PortStart     int    `json:"port_start"`
+	PortEnd       int    `json:"port_end"`
+	PortsPerBlock int    `json:"ports_per_block"`
+	IsActive      *bool  `json:"is_active"`
+}
+
+// CGNATPoolInfo is a CGNAT pool with the size of its mapping
+type CGNATPoolInfo struct {
+	models.CGNATPool
+	Blocks int64 `json:"blocks"`
+}
+
+// The pool name becomes part of a RouterOS chain name and rule comment
+var cgnatPoolName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// syncCGNATPoolAsync pushes the rules in the background; a large pool takes longer than
+// a request may. The outcome is stored on the pool (synced_at, sync_error).
+func syncCGNATPoolAsync(pool models.CGNATPool) {
+	go func() {
+		if err := services.IPPool.SyncCGNATPool(&pool); err == nil {
+			log.Printf("IPPoolHandler: CGNAT pool %s synced to NAS", pool.Name)
+		}
+	}()
+}
+
+// ListCGNATPools returns all CGNAT pools
+func ListCGNATPools(c *fiber.Ctx) error {
+	var pools []models.CGNATPool
+	database.DB.Preload("Nas").Order("name").Find(&pools)
+
+	type count struct {
+		CGNATPoolID uint
+		Blocks      int64
+	}
+	var counts []count
+	database.DB.Model(&models.CGNATPortBlock{}).Select("cgnat_pool_id, COUNT(*) AS blocks").Group("cgnat_pool_id").Scan(&counts)
+	blocks := make(map[uint]int64, len(counts))
+	for _, cnt := range counts {
+		blocks[cnt.CGNATPoolID] = cnt.Blocks
+	}
+
+	result := make([]CGNATPoolInfo, len(pools))
+	for i, p := range pools {
+		result[i] = CGNATPoolInfo{CGNATPool: p, Blocks: blocks[p.ID]}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+func applyCGNATPoolRequest(pool *models.CGNATPool, req *CGNATPoolRequest) {
+	pool.Name = strings.TrimSpace(req.Name)
+	pool.PoolName = strings.TrimSpace(req.PoolName)
+	pool.NasID = req.NasID
+	pool.PrivateRanges = strings.TrimSpace(req.PrivateRanges)
+	pool.PublicRanges = strings.TrimSpace(req.PublicRanges)
+	pool.PortStart = req.PortStart
+	pool.PortEnd = req.PortEnd
+	pool.PortsPerBlock = req.PortsPerBlock
+	if pool.PortStart == 0 {
+		pool.PortStart = 1024
+	}
+	if pool.PortEnd == 0 {
+		pool.PortEnd = 65535
+	}
+	if pool.PortsPerBlock == 0 {
+		pool.PortsPerBlock = 2016
+	}
+	if req.IsActive != nil {
+		pool.IsActive = *req.IsActive
+	}
+}
+
+// CreateCGNATPool creates a CGNAT pool, computes its port blocks and pushes the rules
+func CreateCGNATPool(c *fiber.Ctx) error {
+	var req CGNATPoolRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if !cgnatPoolName.MatchString(strings.TrimSpace(req.Name)) {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required and may only contain letters, digits, - and _ (max 32)",
+		})
+	}
+
+	var existing int64
+	database.DB.Model(&models.CGNATPool{}).Where("name = ?", strings.TrimSpace(req.Name)).Count(&existing)
+	if existing > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "A CGNAT pool with this name already exists",
+		})
+	}
+
+	pool := models.CGNATPool{IsActive: true}
+	applyCGNATPoolRequest(&pool, &req)
+	if err := ippool.SaveCGNATPool(&pool); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	syncCGNATPoolAsync(pool)
+
+	return c.Status(201).JSON(fiber.Map{
+		"success": true,
+		"message": "CGNAT pool created, rules are being pushed to the NAS",
+		"data":    pool,
+	})
+}
+
+// UpdateCGNATPool changes a CGNAT pool and re-pushes its rules
+func UpdateCGNATPool(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var pool models.CGNATPool
+	if err := database.DB.First(&pool, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "CGNAT pool not found",
+		})
+	}
+
+	var req CGNATPoolRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if req.Name == "" {
+		req.Name = pool.Name
+	}
+	if !cgnatPoolName.MatchString(strings.TrimSpace(req.Name)) {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "Name may only contain letters, digits, - and _ (max 32)",
+		})
+	}
+
+	// Rules are found on the router by pool name and NAS; clear the old ones when either changes
+	old := pool
+	applyCGNATPoolRequest(&pool, &req)
+	if err := ippool.SaveCGNATPool(&pool); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	moved := old.Name != pool.Name || (old.NasID == nil) != (pool.NasID == nil) ||
+		(old.NasID != nil && pool.NasID != nil && *old.NasID != *pool.NasID)
+	go func() {
+		if moved {
+			if err := services.IPPool.RemoveCGNATPoolRules(&old); err != nil {
+				log.Printf("IPPoolHandler: Failed to remove old rules of CGNAT pool %s: %v", old.Name, err)
+			}
+		}
+		services.IPPool.SyncCGNATPool(&pool)
+	}()
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "CGNAT pool updated, rules are being pushed to the NAS",
+		"data":    pool,
+	})
+}
+
+// DeleteCGNATPool removes a CGNAT pool and its rules. Sessions already recorded keep
+// their translation in the IP history.
+func DeleteCGNATPool(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var pool models.CGNATPool
+	if err := database.DB.First(&pool, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "CGNAT pool not found",
+		})
+	}
+
+	if err := services.IPPool.RemoveCGNATPoolRules(&pool); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to remove NAT rules from the NAS: " + err.Error(),
+		})
+	}
+
+	if err := database.DB.Delete(&pool).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete CGNAT pool: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "CGNAT pool deleted",
+	})
+}
+
+// SyncCGNATPool re-pushes a pool's rules to its NAS
+func SyncCGNATPool(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var pool models.CGNATPool
+	if err := database.DB.First(&pool, id).Error; err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "CGNAT pool not found",
+		})
+	}
+
+	syncCGNATPoolAsync(pool)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Rules are being pushed to the NAS",
+	})
+}
+
+// GetCGNATPortBlocks returns the port-block mapping of a pool
+func GetCGNATPortBlocks(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 100)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	query := database.DB.Model(&models.CGNATPortBlock{}).Where("cgnat_pool_id = ?", id)
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("private_ip = ? OR public_ip = ?", ip, ip)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var blocks []models.CGNATPortBlock
+	query.Order("id").Offset((page - 1) * limit).Limit(limit).Find(&blocks)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    blocks,
+		"pagination": fiber.Map{
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// LookupCGNAT returns the current translation of a private address, or the private
+// address behind a public address and port. For past sessions use /api/ip-history/lookup.
+func LookupCGNAT(c *fiber.Ctx) error {
+	ip := strings.TrimSpace(c.Query("ip"))
+	port := c.QueryInt("port", 0)
+	if ip == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"success": false,
+			"message": "ip is required",
+		})
+	}
+
+	var block *models.CGNATPortBlock
+	if port > 0 {
+		found, err := ippool.LookupCGNAT(ip, port)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to look up mapping: " + err.Error(),
+			})
+		}
+		block = found
+	} else {
+		var found models.CGNATPortBlock
+		database.DB.Where("private_ip = ?", ip).Limit(1).Find(&found)
+		if found.ID != 0 {
+			block = &found
+		}
+	}
+
+	if block == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"success": false,
+			"message": "No CGNAT mapping for this address",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    block,
+	})
+}
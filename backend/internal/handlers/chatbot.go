@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/commission"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+	"github.com/proisp/backend/internal/webhook"
+	"gorm.io/gorm/clause"
+)
+
+// The chatbot answers WhatsApp and SMS messages from subscribers. The sender's phone
+// number is matched to subscribers (of the reseller whose WhatsApp account received
+// it, if any); commands are answered from the subscriber record and anything else
+// opens a support ticket. Replies go through the outbox from the receiving account.
+
+// chatbotReplies are the default reply templates (message template syntax, see
+// services.RenderMessageTemplate). Each can be replaced with the chatbot_reply_<name>
+// system preference.
+var chatbotReplies = map[string]string{
+	"help": "Hi{{if .full_name}} {{.full_name}}{{end}}, you can send:\n" +
+		"balance - plan, expiry and wallet balance\n" +
+		"usage - data usage\n" +
+		"renew <card code> <pin> - renew with a prepaid card\n" +
+		"support <message> - contact support",
+	"balance": "{{.username}}: {{.service_name}} ({{.status}})\n" +
+		"Expires {{.expiry_date}}, {{.days_remaining}} {{plural .days_remaining \"day\" \"days\"}} left\n" +
+		"Wallet balance: {{money .wallet_balance}}",
+	"usage": "{{.username}} usage\n" +
+		"Today: {{bytes .daily_used}}{{if .daily_quota}} of {{bytes .daily_quota}}{{end}}\n" +
+		"This month: {{bytes .monthly_used}}{{if .monthly_quota}} of {{bytes .monthly_quota}}{{end}}" +
+		"{{if .fup_level}}\nSpeed reduced (FUP level {{.fup_level}}){{end}}",
+	"renewed":        "{{.username}} renewed until {{.new_expiry}}. Thank you!",
+	"ticket_created": "Thank you, we received your message. Your ticket number is {{.ticket_number}} and our team will reply soon.",
+	"ticket_updated": "Thank you, your message was added to ticket {{.ticket_number}}.",
+	"unknown_number": "This number is not registered with {{.company_name}}. Please contact us to update your phone number.",
+}
+
+// inboundMessage is a message received from any provider
+type inboundMessage struct {
+	Channel    string // whatsapp, sms
+	From       string
+	Body       string
+	ProviderID string
+	Account    string // Receiving ProxRad account
+}
+
+// chatbotSession is the context a command runs in
+type chatbotSession struct {
+	msg         inboundMessage
+	subscribers []models.Subscriber
+	resellerID  *uint
+	log         *models.InboundMessage
+}
+
+// handleInboundMessage logs an inbound message, runs its command and queues the reply
+func handleInboundMessage(msg inboundMessage) {
+	msg.Body = strings.TrimSpace(msg.Body)
+	if msg.From == "" || msg.Body == "" {
+		return
+	}
+
+	s := &chatbotSession{msg: msg}
+	s.resolveAccount()
+
+	s.log = &models.InboundMessage{
+		Channel:           msg.Channel,
+		Phone:             msg.From,
+		Account:           msg.Account,
+		ProviderMessageID: msg.ProviderID,
+		Body:              msg.Body,
+		ResellerID:        s.resellerID,
+	}
+	// Providers retry webhooks; a message ID seen before is not answered twice
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(s.log)
+	if result.Error != nil {
+		log.Printf("Chatbot: Failed to log %s message from %s: %v", msg.Channel, msg.From, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	limit := int64(getSystemPreferenceFloat("chatbot_messages_per_hour", 20))
+	if !getSystemPreferenceBool("chatbot_enabled", true) ||
+		bumpCounter("proisp:chatbot:"+normalizePhone(msg.From), time.Hour) > limit {
+		s.log.Command = models.InboundCommandIgnored
+		database.DB.Model(s.log).Update("command", s.log.Command)
+		return
+	}
+
+	for _, sub := range findSubscribersByPhone(msg.From) {
+		if s.resellerID == nil || sub.ResellerID == *s.resellerID {
+			s.subscribers = append(s.subscribers, sub)
+		}
+	}
+	if len(s.subscribers) > 0 {
+		s.log.SubscriberID = &s.subscribers[0].ID
+		if s.log.ResellerID == nil {
+			s.log.ResellerID = &s.subscribers[0].ResellerID
+		}
+	}
+
+	reply := s.dispatch()
+	s.log.Reply = reply
+	database.DB.Model(s.log).Updates(map[string]interface{}{
+		"subscriber_id": s.log.SubscriberID,
+		"reseller_id":   s.log.ResellerID,
+		"command":       s.log.Command,
+		"reply":         s.log.Reply,
+		"ticket_id":     s.log.TicketID,
+	})
+	if reply != "" {
+		s.sendReply(reply)
+	}
+}
+
+// resolveAccount scopes the conversation to the reseller whose WhatsApp account
+// received the message
+func (s *chatbotSession) resolveAccount() {
+	if s.msg.Channel != "whatsapp" || s.msg.Account == "" {
+		return
+	}
+	var reseller models.Reseller
+	if err := database.DB.Where("whatsapp_account_unique = ? AND whatsapp_enabled = ?", s.msg.Account, true).
+		First(&reseller).Error; err == nil {
+		s.resellerID = &reseller.ID
+	}
+}
+
+// sendReply queues the reply on the channel and account the message came in on.
+// Replies are urgent: the customer is waiting, so quiet hours do not apply.
+func (s *chatbotSession) sendReply(reply string) {
+	outbound := &models.OutboundMessage{
+		Channel:      s.msg.Channel,
+		Recipient:    s.msg.From,
+		Body:         reply,
+		Source:       "chatbot",
+		SubscriberID: s.log.SubscriberID,
+		ResellerID:   s.log.ResellerID,
+		Urgent:       true,
+	}
+	if s.msg.Channel == "whatsapp" {
+		outbound.Sender = models.OutboundSenderAdmin
+		if s.resellerID != nil {
+			outbound.Sender = s.msg.Account
+		}
+	}
+	if _, err := services.QueueMessage(outbound); err != nil {
+		log.Printf("Chatbot: Failed to queue reply to %s: %v", s.msg.From, err)
+	}
+}
+
+// dispatch runs the message's command and returns the reply
+func (s *chatbotSession) dispatch() string {
+	fields := strings.Fields(s.msg.Body)
+	command := strings.ToLower(strings.Trim(fields[0], ".!?"))
+	args := fields[1:]
+
+	if len(s.subscribers) == 0 {
+		s.log.Command = models.InboundCommandHelp
+		return s.render("unknown_number", nil, nil)
+	}
+
+	switch command {
+	case "help", "menu", "hi", "hello", "start", "":
+		s.log.Command = models.InboundCommandHelp
+		return s.render("help", &s.subscribers[0], nil)
+	case "balance", "expiry":
+		s.log.Command = models.InboundCommandBalance
+		return s.eachSubscriber("balance", chatbotBalanceVars)
+	case "usage", "quota":
+		s.log.Command = models.InboundCommandUsage
+		return s.eachSubscriber("usage", chatbotUsageVars)
+	case "renew":
+		s.log.Command = models.InboundCommandRenew
+		return s.renew(args)
+	case "support":
+		s.log.Command = models.InboundCommandSupport
+		if len(args) == 0 {
+			return "Please send your question after the word support, e.g. support my connection is slow"
+		}
+		return s.openTicket(strings.Join(args, " "))
+	default:
+		s.log.Command = models.InboundCommandSupport
+		return s.openTicket(s.msg.Body)
+	}
+}
+
+// eachSubscriber renders a reply for every subscriber on the number
+func (s *chatbotSession) eachSubscriber(name string, vars func(*models.Subscriber) map[string]interface{}) string {
+	replies := make([]string, 0, len(s.subscribers))
+	for i := range s.subscribers {
+		sub := &s.subscribers[i]
+		replies = append(replies, s.render(name, sub, vars(sub)))
+	}
+	return strings.Join(replies, "\n\n")
+}
+
+func chatbotBalanceVars(sub *models.Subscriber) map[string]interface{} {
+	status := "active"
+	switch {
+	case sub.Status == models.SubscriberStatusStopped:
+		status = "stopped"
+	case sub.Status == models.SubscriberStatusInactive:
+		status = "inactive"
+	case sub.Status == models.SubscriberStatusExpired || sub.ExpiryDate.Before(time.Now()):
+		status = "expired"
+	}
+	return map[string]interface{}{"status": status}
+}
+
+func chatbotUsageVars(sub *models.Subscriber) map[string]interface{} {
+	vars := map[string]interface{}{
+		"daily_used":    sub.DailyQuotaUsed,
+		"monthly_used":  sub.MonthlyQuotaUsed,
+		"daily_quota":   int64(0),
+		"monthly_quota": int64(0),
+		"fup_level":     sub.FUPLevel,
+	}
+	if sub.MonthlyFUPLevel > sub.FUPLevel {
+		vars["fup_level"] = sub.MonthlyFUPLevel
+	}
+	if sub.Service != nil {
+		vars["daily_quota"] = sub.Service.DailyQuota
+		vars["monthly_quota"] = sub.Service.MonthlyQuota
+	}
+	return vars
+}
+
+// renew redeems a prepaid card: renew <code> [pin] [username]. The username is only
+// needed when the number belongs to more than one subscriber.
+func (s *chatbotSession) renew(args []string) string {
+	if len(args) == 0 {
+		return "Please send: renew <card code> <pin>"
+	}
+
+	sub := &s.subscribers[0]
+	if len(s.subscribers) > 1 {
+		sub = nil
+		usernames := make([]string, len(s.subscribers))
+		for i := range s.subscribers {
+			usernames[i] = s.subscribers[i].Username
+			if len(args) > 1 && strings.EqualFold(usernames[i], args[len(args)-1]) {
+				sub = &s.subscribers[i]
+			}
+		}
+		if sub == nil {
+			return fmt.Sprintf("This number has several accounts (%s). Please send: renew <card code> <pin> <username>",
+				strings.Join(usernames, ", "))
+		}
+		args = args[:len(args)-1]
+	}
+	s.log.SubscriberID = &sub.ID
+
+	if !getPortalPermissions(sub.ResellerID).AllowRenew {
+		return "Renewal by message is not available. Please contact your provider."
+	}
+	if portalCardLocked(sub.ID) {
+		return "Too many invalid card attempts. Please try again later."
+	}
+
+	pin := ""
+	if len(args) > 1 {
+		pin = args[1]
+	}
+	card, cardErr := findRedeemableCard(args[0], pin)
+	if cardErr != nil {
+		recordPortalCardFailure(sub.ID)
+		return cardErr.Message
+	}
+	if card.Days <= 0 && card.Hours <= 0 {
+		return "This card does not add time and cannot be used to renew."
+	}
+	transaction := claimPrepaidCard(card, sub.ID, "")
+	if transaction == nil {
+		return "Card has already been used"
+	}
+	commission.PostAsync(*transaction)
+	applyPrepaidCard(card, sub)
+	database.DB.Select("expiry_date").First(sub, sub.ID)
+
+	database.DB.Create(&models.AuditLog{
+		Username:    sub.Username,
+		UserType:    models.UserTypeSubscriber,
+		Action:      models.AuditActionRenew,
+		EntityType:  "subscriber",
+		EntityID:    sub.ID,
+		EntityName:  sub.Username,
+		Description: fmt.Sprintf("Renewed by %s message with prepaid card %s until %s", s.msg.Channel, card.Code, sub.ExpiryDate.Format("2006-01-02")),
+	})
+	webhook.EmitSubscriber(models.WebhookEventSubscriberRenewed, sub, map[string]interface{}{
+		"renewed_by": "chatbot",
+		"method":     "card",
+	})
+
+	return s.render("renewed", sub, map[string]interface{}{"new_expiry": sub.ExpiryDate.Format("2006-01-02")})
+}
+
+// openTicket adds the text to the subscriber's open ticket, or opens one
+func (s *chatbotSession) openTicket(text string) string {
+	sub := &s.subscribers[0]
+
+	var ticket models.Ticket
+	err := database.DB.Where("subscriber_id = ? AND creator_type = ? AND status IN ?",
+		sub.ID, "subscriber", []string{"open", "pending", "in_progress"}).
+		Order("updated_at DESC").First(&ticket).Error
+	if err == nil {
+		database.DB.Create(&models.TicketReply{
+			TicketID: ticket.ID,
+			UserID:   0, // Customer reply
+			Message:  text,
+		})
+		database.DB.Model(&ticket).Update("updated_at", time.Now())
+		s.log.TicketID = &ticket.ID
+		return s.render("ticket_updated", sub, map[string]interface{}{"ticket_number": ticket.TicketNumber})
+	}
+
+	channelName := "WhatsApp"
+	if s.msg.Channel == "sms" {
+		channelName = "SMS"
+	}
+	ticket = models.Ticket{
+		TicketNumber: fmt.Sprintf("TKT-%d-%04d", time.Now().Year(), time.Now().Unix()%10000),
+		Subject:      fmt.Sprintf("%s message from %s", channelName, sub.Username),
+		Description:  text,
+		Message:      text,
+		Priority:     "normal",
+		Category:     "general",
+		Status:       "open",
+		CreatorType:  "subscriber",
+		SubscriberID: &sub.ID,
+		ResellerID:   &sub.ResellerID,
+	}
+	if err := database.DB.Create(&ticket).Error; err != nil {
+		log.Printf("Chatbot: Failed to open ticket for %s: %v", sub.Username, err)
+		return "Sorry, we could not record your message. Please try again later."
+	}
+	s.log.TicketID = &ticket.ID
+	return s.render("ticket_created", sub, map[string]interface{}{"ticket_number": ticket.TicketNumber})
+}
+
+// render renders a reply template for a subscriber
+func (s *chatbotSession) render(name string, sub *models.Subscriber, data map[string]interface{}) string {
+	text := getSystemPreference("chatbot_reply_"+name, "")
+	if text == "" {
+		text = chatbotReplies[name]
+	}
+	reply, err := services.RenderMessageTemplate(text, services.MessageTemplateVars(sub, data))
+	if err != nil {
+		log.Printf("Chatbot: Invalid %s reply template: %v", name, err)
+		reply, _ = services.RenderMessageTemplate(chatbotReplies[name], services.MessageTemplateVars(sub, data))
+	}
+	return strings.TrimSpace(reply)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/commission"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
@@ -672,7 +673,7 @@ func (h *CollectorHandler) autoRenewSubscriber(subscriberID uint) {
 			Update("balance", gorm.Expr("balance - ?", subscriber.Price))
 
 		// Create transaction
-		database.DB.Create(&models.Transaction{
+		transaction := models.Transaction{
 			Type:         models.TransactionTypeRenewal,
 			Amount:       -subscriber.Price,
 			Description:  fmt.Sprintf("Auto-renewal via collector for %s", subscriber.Username),
@@ -680,7 +681,9 @@ func (h *CollectorHandler) autoRenewSubscriber(subscriberID uint) {
 			SubscriberID: &subscriber.ID,
 			ServiceName:  subscriber.Service.Name,
 			CreatedBy:    subscriber.ResellerID,
-		})
+		}
+		database.DB.Create(&transaction)
+		commission.PostAsync(transaction)
 	}
 
 	log.Printf("Collector autoRenew: renewed %s until %s", subscriber.Username, newExpiry.Format("2006-01-02"))
@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/proisp/backend/internal/commission"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// CommissionHandler serves commission plans, the commission ledger and settlement statements
+type CommissionHandler struct{}
+
+// NewCommissionHandler creates a new commission handler
+func NewCommissionHandler() *CommissionHandler {
+	return &CommissionHandler{}
+}
+
+// CommissionRuleRequest is one rate of a plan
+type CommissionRuleRequest struct {
+	ServiceID *uint   `json:"service_id"` // nil = any service
+	Tier      int     `json:"tier"`       // 1 = parent of the seller, 2 = grandparent, ...
+	Type      string  `json:"type"`       // percent, fixed
+	Value     float64 `json:"value"`
+}
+
+// CommissionPlanRequest is the body of POST/PUT /api/commissions/plans
+type CommissionPlanRequest struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	IsDefault   bool                    `json:"is_default"`
+	IsActive    *bool                   `json:"is_active"`
+	Rules       []CommissionRuleRequest `json:"rules"`
+}
+
+// PayCommissionSettlementRequest is the body of POST /api/commissions/settlements/:id/pay
+type PayCommissionSettlementRequest struct {
+	Method    string `json:"method"` // balance, external
+	Reference string `json:"reference"`
+	Notes     string `json:"notes"`
+}
+
+// GenerateCommissionSettlementsRequest is the body of POST /api/commissions/settlements/generate
+type GenerateCommissionSettlementsRequest struct {
+	Period string `json:"period"` // YYYY-MM
+}
+
+// CommissionSummaryRow is one reseller's commission total in a period
+type CommissionSummaryRow struct {
+	ResellerID   uint    `json:"reseller_id"`
+	ResellerName string  `json:"reseller_name"`
+	Entries      int64   `json:"entries"`
+	Sales        float64 `json:"sales"`
+	Total        float64 `json:"total"`
+	Unsettled    float64 `json:"unsettled"`
+}
+
+// commissionResellerScope limits resellers to their own earnings
+func commissionResellerScope(c *fiber.Ctx, query *gorm.DB, column string) *gorm.DB {
+	user := middleware.GetCurrentUser(c)
+	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		return query.Where(column+" = ?", *user.ResellerID)
+	}
+	if id := c.QueryInt("reseller_id", 0); id > 0 {
+		query = query.Where(column+" = ?", id)
+	}
+	return query
+}
+
+func commissionRules(req []CommissionRuleRequest) ([]models.CommissionRule, error) {
+	rules := make([]models.CommissionRule, 0, len(req))
+	seen := make(map[string]bool)
+	for _, r := range req {
+		if r.Tier < 1 {
+			return nil, fmt.Errorf("tier must be 1 or more")
+		}
+		if r.Type != "percent" && r.Type != "fixed" {
+			return nil, fmt.Errorf("rule type must be percent or fixed")
+		}
+		if r.Value < 0 || (r.Type == "percent" && r.Value > 100) {
+			return nil, fmt.Errorf("invalid rule value %v", r.Value)
+		}
+		key := fmt.Sprintf("%d/", r.Tier)
+		if r.ServiceID != nil {
+			key += fmt.Sprint(*r.ServiceID)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate rule for tier %d and the same service", r.Tier)
+		}
+		seen[key] = true
+		rules = append(rules, models.CommissionRule{ServiceID: r.ServiceID, Tier: r.Tier, Type: r.Type, Value: r.Value})
+	}
+	return rules, nil
+}
+
+// savePlan writes a plan with its rules, replacing the previous rules, and keeps a
+// single default plan
+func savePlan(plan *models.CommissionPlan, rules []models.CommissionRule) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if plan.IsDefault {
+			if err := tx.Model(&models.CommissionPlan{}).Where("is_default = ? AND id <> ?", true, plan.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Rules").Save(plan).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.CommissionRule{}).Error; err != nil {
+			return err
+		}
+		for i := range rules {
+			rules[i].PlanID = plan.ID
+		}
+		if len(rules) > 0 {
+			if err := tx.Create(&rules).Error; err != nil {
+				return err
+			}
+		}
+		plan.Rules = rules
+		return nil
+	})
+}
+
+// ListPlans returns commission plans with their rules
+func (h *CommissionHandler) ListPlans(c *fiber.Ctx) error {
+	var plans []models.CommissionPlan
+	database.DB.Preload("Rules", func(db *gorm.DB) *gorm.DB { return db.Order("tier, service_id NULLS LAST") }).
+		Order("name").Find(&plans)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    plans,
+	})
+}
+
+// CreatePlan creates a commission plan
+func (h *CommissionHandler) CreatePlan(c *fiber.Ctx) error {
+	var req CommissionPlanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required",
+		})
+	}
+	rules, err := commissionRules(req.Rules)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	plan := models.CommissionPlan{Name: req.Name, Description: req.Description, IsDefault: req.IsDefault, IsActive: true}
+	if req.IsActive != nil {
+		plan.IsActive = *req.IsActive
+	}
+	if err := savePlan(&plan, rules); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save plan: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Commission plan created",
+		"data":    plan,
+	})
+}
+
+// UpdatePlan changes a plan and replaces its rules. Entries already posted keep the
+// rate they were posted with.
+func (h *CommissionHandler) UpdatePlan(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var plan models.CommissionPlan
+	if err := database.DB.First(&plan, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Commission plan not found",
+		})
+	}
+
+	var req CommissionPlanRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	rules, err := commissionRules(req.Rules)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		plan.Name = name
+	}
+	plan.Description = req.Description
+	plan.IsDefault = req.IsDefault
+	if req.IsActive != nil {
+		plan.IsActive = *req.IsActive
+	}
+	if err := savePlan(&plan, rules); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save plan: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Commission plan updated",
+		"data":    plan,
+	})
+}
+
+// DeletePlan removes a plan; resellers on it fall back to the default plan
+func (h *CommissionHandler) DeletePlan(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var plan models.CommissionPlan
+	if err := database.DB.First(&plan, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Commission plan not found",
+		})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Reseller{}).Where("commission_plan_id = ?", plan.ID).
+			Update("commission_plan_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&plan).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete plan: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Commission plan deleted",
+	})
+}
+
+// ListEntries returns the commission ledger, newest first
+func (h *CommissionHandler) ListEntries(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 25)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 25
+	}
+
+	query := commissionResellerScope(c, database.DB.Model(&models.Commission{}), "reseller_id")
+	if period := c.Query("period"); period != "" {
+		start, end, err := commission.PeriodBounds(period)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+		query = query.Where("created_at >= ? AND created_at < ?", start, end)
+	}
+	if id := c.QueryInt("settlement_id", 0); id > 0 {
+		query = query.Where("settlement_id = ?", id)
+	}
+	if c.QueryBool("unsettled", false) {
+		query = query.Where("settlement_id IS NULL")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.Commission
+	query.Order("created_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries)
+
+	// Name the selling resellers for display
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.SourceResellerID)
+	}
+	var sellers []models.Reseller
+	if len(ids) > 0 {
+		database.DB.Select("id, name").Where("id IN ?", ids).Find(&sellers)
+	}
+	names := make(map[uint]string, len(sellers))
+	for _, r := range sellers {
+		names[r.ID] = r.Name
+	}
+	for i := range entries {
+		entries[i].SourceReseller = names[entries[i].SourceResellerID]
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// Summary returns commission totals per earning reseller for a month (default: the
+// current month)
+func (h *CommissionHandler) Summary(c *fiber.Ctx) error {
+	period := c.Query("period", time.Now().Format("2006-01"))
+	start, end, err := commission.PeriodBounds(period)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	var rows []CommissionSummaryRow
+	query := database.DB.Table("commissions cm").
+		Select(`cm.reseller_id, r.name AS reseller_name, COUNT(*) AS entries, SUM(cm.base_amount) AS sales,
+			SUM(cm.amount) AS total, COALESCE(SUM(cm.amount) FILTER (WHERE cm.settlement_id IS NULL), 0) AS unsettled`).
+		Joins("LEFT JOIN resellers r ON r.id = cm.reseller_id").
+		Where("cm.created_at >= ? AND cm.created_at < ?", start, end)
+	query = commissionResellerScope(c, query, "cm.reseller_id")
+	query.Group("cm.reseller_id, r.name").Order("total DESC").Scan(&rows)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"period":    period,
+			"resellers": rows,
+		},
+	})
+}
+
+// ListSettlements returns settlement statements, newest period first
+func (h *CommissionHandler) ListSettlements(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 25)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 25
+	}
+
+	query := commissionResellerScope(c, database.DB.Model(&models.CommissionSettlement{}), "reseller_id")
+	if period := c.Query("period"); period != "" {
+		query = query.Where("period = ?", period)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var settlements []models.CommissionSettlement
+	query.Preload("Reseller").Order("period DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&settlements)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settlements,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// GetSettlement returns one statement with its entries
+func (h *CommissionHandler) GetSettlement(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var settlement models.CommissionSettlement
+	query := commissionResellerScope(c, database.DB.Preload("Reseller"), "reseller_id")
+	if err := query.First(&settlement, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Statement not found",
+		})
+	}
+
+	var entries []models.Commission
+	database.DB.Where("settlement_id = ?", settlement.ID).Order("created_at").Find(&entries)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"settlement": settlement,
+			"entries":    entries,
+		},
+	})
+}
+
+// GenerateSettlements builds the statements of a finished month now instead of waiting
+// for the settlement service
+func (h *CommissionHandler) GenerateSettlements(c *fiber.Ctx) error {
+	var req GenerateCommissionSettlementsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	_, end, err := commission.PeriodBounds(req.Period)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if end.After(time.Now()) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Only finished months can be settled",
+		})
+	}
+
+	count, err := commission.GenerateSettlements(req.Period)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate statements: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("%d statements generated for %s", count, req.Period),
+		"data":    fiber.Map{"count": count},
+	})
+}
+
+// PaySettlement marks a statement paid, optionally crediting the reseller's balance
+func (h *CommissionHandler) PaySettlement(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+
+	var req PayCommissionSettlementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	settlement, err := commission.Pay(uint(id), req.Method, strings.TrimSpace(req.Reference), req.Notes, user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	c.Locals("audit_description", fmt.Sprintf("Paid commission statement %s of reseller %d (%.2f, %s)",
+		settlement.Period, settlement.ResellerID, settlement.Total, settlement.PayoutMethod))
+	c.Locals("audit_entity_id", settlement.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Statement marked paid",
+		"data":    settlement,
+	})
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"encoding/json"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
 )
 
 type CommunicationHandler struct{}
@@ -121,6 +125,107 @@ func (h *CommunicationHandler) DeleteTemplate(c *fiber.Ctx) error {
 
 // Rules
 
+// RulePreviewRequest is the body of POST /api/communication/rules/preview. The rule is
+// rendered against the given subscriber (by ID or username), or the most recently
+// updated one.
+type RulePreviewRequest struct {
+	TriggerEvent string `json:"trigger_event"`
+	Template     string `json:"template"`
+	Subject      string `json:"subject"`
+	SubscriberID uint   `json:"subscriber_id"`
+	Username     string `json:"username"`
+}
+
+// RulePreviewResponse is a rendered rule
+type RulePreviewResponse struct {
+	Subject      string `json:"subject"`
+	Message      string `json:"message"`
+	SubscriberID uint   `json:"subscriber_id"`
+	Username     string `json:"username"`
+}
+
+// RuleTriggersResponse describes what rules can fire on and use in templates
+type RuleTriggersResponse struct {
+	Triggers            []services.RuleTrigger `json:"triggers"`
+	SubscriberVariables []string               `json:"subscriber_variables"`
+	Helpers             []string               `json:"helpers"`
+}
+
+// validateRuleTemplates checks a rule's message and subject templates
+func validateRuleTemplates(rule *models.CommunicationRule) string {
+	if err := services.ParseMessageTemplate(rule.Template); err != nil {
+		return "Invalid template: " + err.Error()
+	}
+	if err := services.ParseMessageTemplate(rule.Subject); err != nil {
+		return "Invalid subject: " + err.Error()
+	}
+	return ""
+}
+
+// ListTriggers returns the rule triggers with their template variables and helpers
+func (h *CommunicationHandler) ListTriggers(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": RuleTriggersResponse{
+			Triggers:            services.RuleTriggers,
+			SubscriberVariables: services.SubscriberTemplateVariables,
+			Helpers:             services.TemplateHelpers,
+		},
+	})
+}
+
+// PreviewRule renders a template against a real subscriber with sample event data
+func (h *CommunicationHandler) PreviewRule(c *fiber.Ctx) error {
+	var req RulePreviewRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	query := database.DB.Preload("Service").Preload("Reseller.User")
+	user := middleware.GetCurrentUser(c)
+	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		query = query.Where("reseller_id = ?", *user.ResellerID)
+	}
+	switch {
+	case req.SubscriberID > 0:
+		query = query.Where("id = ?", req.SubscriberID)
+	case req.Username != "":
+		query = query.Where("username = ?", req.Username)
+	default:
+		query = query.Order("updated_at DESC")
+	}
+
+	var subscriber models.Subscriber
+	if err := query.First(&subscriber).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Subscriber not found",
+		})
+	}
+
+	rule := models.CommunicationRule{TriggerEvent: req.TriggerEvent, Template: req.Template, Subject: req.Subject}
+	subject, message, err := services.PreviewRule(rule, &subscriber)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": RulePreviewResponse{
+			Subject:      subject,
+			Message:      message,
+			SubscriberID: subscriber.ID,
+			Username:     subscriber.Username,
+		},
+	})
+}
+
 // ListRules returns all communication rules
 func (h *CommunicationHandler) ListRules(c *fiber.Ctx) error {
 	trigger := c.Query("trigger", "")
@@ -167,6 +272,13 @@ func (h *CommunicationHandler) CreateRule(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateRuleTemplates(&rule); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
 	if err := database.DB.Create(&rule).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -200,8 +312,38 @@ func (h *CommunicationHandler) UpdateRule(c *fiber.Ctx) error {
 		})
 	}
 
+	if msg := validateRuleTemplates(&updates); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
 	database.DB.Model(&rule).Updates(updates)
 
+	// Conditions and recipients can be cleared, which Updates skips for zero values
+	var body map[string]interface{}
+	json.Unmarshal(c.Body(), &body)
+	clearable := map[string]interface{}{}
+	if _, ok := body["reseller_id"]; ok {
+		clearable["reseller_id"] = updates.ResellerID
+	}
+	if _, ok := body["service_id"]; ok {
+		clearable["service_id"] = updates.ServiceID
+	}
+	if _, ok := body["region"]; ok {
+		clearable["region"] = updates.Region
+	}
+	if _, ok := body["subject"]; ok {
+		clearable["subject"] = updates.Subject
+	}
+	if _, ok := body["recipient"]; ok {
+		clearable["recipient"] = updates.Recipient
+	}
+	if len(clearable) > 0 {
+		database.DB.Model(&rule).Updates(clearable)
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    rule,
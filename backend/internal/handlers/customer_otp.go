@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+const (
+	customerOTPTTL         = 5 * time.Minute
+	customerOTPMaxAttempts = 5
+	customerOTPResendDelay = 60 * time.Second
+	customerOTPSelectTTL   = 5 * time.Minute
+
+	// Codes sent to one phone number, and requests from one IP, per hour
+	customerOTPPhoneLimit = 5
+	customerOTPIPLimit    = 20
+	customerOTPLimitTTL   = time.Hour
+
+	// Phone numbers match when the shorter one is at least this long and ends the other,
+	// so 0770 123 4567 and +964 770 123 4567 find the same subscriber
+	phoneMatchMinDigits = 9
+)
+
+// customerOTP is the pending login code for a phone number
+type customerOTP struct {
+	CodeHash      string `json:"code_hash"`
+	SubscriberIDs []uint `json:"subscriber_ids"`
+}
+
+// normalizePhone keeps the digits of a phone number and drops leading zeros (trunk or 00 prefix)
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(b.String(), "0")
+}
+
+func phonesMatch(a, b string) bool {
+	a, b = normalizePhone(a), normalizePhone(b)
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return len(a) >= phoneMatchMinDigits && strings.HasSuffix(b, a)
+}
+
+// findSubscribersByPhone returns every subscriber whose phone matches the given number
+func findSubscribersByPhone(phone string) []models.Subscriber {
+	digits := normalizePhone(phone)
+	if len(digits) < phoneMatchMinDigits {
+		return nil
+	}
+
+	var candidates []models.Subscriber
+	database.DB.Preload("Service").
+		Where("phone <> '' AND right(regexp_replace(phone, '[^0-9]', '', 'g'), ?) = ?", phoneMatchMinDigits, digits[len(digits)-phoneMatchMinDigits:]).
+		Order("id ASC").
+		Find(&candidates)
+
+	var matches []models.Subscriber
+	for _, sub := range candidates {
+		if phonesMatch(sub.Phone, phone) {
+			matches = append(matches, sub)
+		}
+	}
+	return matches
+}
+
+// bumpCounter increments a rate limit counter, starting its window on first use
+func bumpCounter(key string, window time.Duration) int64 {
+	ctx := context.Background()
+	count := database.Redis.Incr(ctx, key).Val()
+	if count == 1 {
+		database.Redis.Expire(ctx, key, window)
+	}
+	return count
+}
+
+// otpChannels returns the configured channels customers can receive a login code on
+func otpChannels() []string {
+	channels := []string{}
+	if services.NewSMSService().IsConfigured() {
+		channels = append(channels, "sms")
+	}
+	if services.NewWhatsAppService().IsConfigured() {
+		channels = append(channels, "whatsapp")
+	}
+	return channels
+}
+
+// OTPChannels tells the login page whether code login is available and on which channels
+func (h *CustomerPortalHandler) OTPChannels(c *fiber.Ctx) error {
+	channels := []string{}
+	if getSystemPreferenceBool("customer_otp_login_enabled", true) {
+		channels = otpChannels()
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"enabled":  len(channels) > 0,
+			"channels": channels,
+		},
+	})
+}
+
+// RequestOTP sends a one-time login code to a registered phone number. The response is the
+// same whether or not the number belongs to a subscriber.
+func (h *CustomerPortalHandler) RequestOTP(c *fiber.Ctx) error {
+	var req struct {
+		Phone   string `json:"phone"`
+		Channel string `json:"channel"` // sms or whatsapp
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	if !getSystemPreferenceBool("customer_otp_login_enabled", true) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"success": false, "message": "Login with a code is disabled"})
+	}
+	if req.Channel == "" {
+		req.Channel = "sms"
+	}
+	available := false
+	for _, ch := range otpChannels() {
+		if ch == req.Channel {
+			available = true
+		}
+	}
+	if !available {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "This delivery channel is not available"})
+	}
+
+	phone := normalizePhone(req.Phone)
+	if len(phone) < phoneMatchMinDigits {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Enter your full phone number"})
+	}
+
+	if bumpCounter("proisp:portal:otp_ip:"+c.IP(), customerOTPLimitTTL) > customerOTPIPLimit {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"success": false, "message": "Too many requests. Please try again later"})
+	}
+	ctx := context.Background()
+	if !database.Redis.SetNX(ctx, "proisp:portal:otp_cooldown:"+phone, 1, customerOTPResendDelay).Val() {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Please wait %d seconds before requesting another code", int(customerOTPResendDelay.Seconds())),
+		})
+	}
+	if bumpCounter("proisp:portal:otp_phone:"+phone, customerOTPLimitTTL) > customerOTPPhoneLimit {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"success": false, "message": "Too many codes requested for this number. Please try again later"})
+	}
+
+	response := fiber.Map{
+		"success": true,
+		"message": "If this number is registered, a login code has been sent to it",
+		"data": fiber.Map{
+			"expires_in": int(customerOTPTTL.Seconds()),
+			"resend_in":  int(customerOTPResendDelay.Seconds()),
+		},
+	}
+
+	subscribers := findSubscribersByPhone(phone)
+	if len(subscribers) == 0 {
+		return c.JSON(response)
+	}
+
+	code := generatePIN(6)
+	otp := customerOTP{CodeHash: hashContactCode(code)}
+	for _, sub := range subscribers {
+		otp.SubscriberIDs = append(otp.SubscriberIDs, sub.ID)
+	}
+	key := "proisp:portal:otp:" + phone
+	if err := database.CacheSet(key, otp, customerOTPTTL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to create login code"})
+	}
+	database.CacheDelete("proisp:portal:otp_attempts:" + phone)
+
+	// Deliver to the number on file rather than the one typed in
+	target := subscribers[0]
+	message := fmt.Sprintf("Your %s login code is %s. It expires in %d minutes. Do not share it with anyone.",
+		database.GetCompanyName(), code, int(customerOTPTTL.Minutes()))
+	var sendErr error
+	if req.Channel == "whatsapp" {
+		sendErr = services.NewWhatsAppService().SendTransactionalMessage(target, target.Phone, message)
+	} else {
+		sendErr = services.NewSMSService().SendSMS(target.Phone, message)
+	}
+	if sendErr != nil {
+		log.Printf("CustomerOTP: Failed to send %s code for %s: %v", req.Channel, target.Username, sendErr)
+		database.CacheDelete(key)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"success": false, "message": "Could not send the code. Please try again later"})
+	}
+
+	return c.JSON(response)
+}
+
+// VerifyOTP checks the code and logs in, or returns an account picker when the phone
+// belongs to several subscribers
+func (h *CustomerPortalHandler) VerifyOTP(c *fiber.Ctx) error {
+	var req struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(CustomerLoginResponse{Success: false, Message: "Invalid request body"})
+	}
+
+	phone := normalizePhone(req.Phone)
+	key := "proisp:portal:otp:" + phone
+	attemptsKey := "proisp:portal:otp_attempts:" + phone
+	var otp customerOTP
+	if err := database.CacheGet(key, &otp); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "The code has expired. Please request a new one"})
+	}
+
+	attempts := countCodeAttempt(attemptsKey, customerOTPTTL)
+	if attempts > customerOTPMaxAttempts {
+		database.CacheDelete(key, attemptsKey)
+		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "Too many invalid attempts. Please request a new code"})
+	}
+	codeHash := hashContactCode(strings.TrimSpace(req.Code))
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(otp.CodeHash)) != 1 {
+		if attempts == customerOTPMaxAttempts {
+			database.CacheDelete(key, attemptsKey)
+			return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "Too many invalid attempts. Please request a new code"})
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "Invalid code"})
+	}
+
+	// Take the code so it logs in once, even with parallel requests
+	if err := database.CacheTake(key, &otp); err != nil || otp.CodeHash != codeHash {
+		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "The code has expired. Please request a new one"})
+	}
+	database.CacheDelete(attemptsKey)
+
+	var subscribers []models.Subscriber
+	database.DB.Preload("Service").Where("id IN ?", otp.SubscriberIDs).Order("id ASC").Find(&subscribers)
+	if len(subscribers) == 0 {
+		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "Account not found"})
+	}
+
+	if len(subscribers) == 1 {
+		return h.completeOTPLogin(c, &subscribers[0])
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(CustomerLoginResponse{Success: false, Message: "Failed to start account selection"})
+	}
+	selectionToken := hex.EncodeToString(buf)
+	ids := make([]uint, 0, len(subscribers))
+	accounts := make([]*CustomerInfo, 0, len(subscribers))
+	for i := range subscribers {
+		ids = append(ids, subscribers[i].ID)
+		accounts = append(accounts, newCustomerInfo(&subscribers[i]))
+	}
+	if err := database.CacheSet("proisp:portal:otp_select:"+selectionToken, ids, customerOTPSelectTTL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(CustomerLoginResponse{Success: false, Message: "Failed to start account selection"})
+	}
+
+	return c.JSON(CustomerLoginResponse{
+		Success:           true,
+		RequiresSelection: true,
+		SelectionToken:    selectionToken,
+		Accounts:          accounts,
+	})
+}
+
+// SelectOTPAccount finishes a code login by picking one of the phone's subscriptions
+func (h *CustomerPortalHandler) SelectOTPAccount(c *fiber.Ctx) error {
+	var req struct {
+		SelectionToken string `json:"selection_token"`
+		Username       string `json:"username"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.SelectionToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(CustomerLoginResponse{Success: false, Message: "Invalid request body"})
+	}
+
+	key := "proisp:portal:otp_select:" + req.SelectionToken
+	var ids []uint
+	if err := database.CacheGet(key, &ids); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{Success: false, Message: "Account selection expired. Please log in again"})
+	}
+
+	var subscriber models.Subscriber
+	if err := database.DB.Preload("Service").Where("id IN ? AND username = ?", ids, req.Username).First(&subscriber).Error; err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(CustomerLoginResponse{Success: false, Message: "Account not found"})
+	}
+	database.CacheDelete(key)
+
+	return h.completeOTPLogin(c, &subscriber)
+}
+
+func (h *CustomerPortalHandler) completeOTPLogin(c *fiber.Ctx, subscriber *models.Subscriber) error {
+	token, err := h.generateCustomerToken(subscriber.Username)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(CustomerLoginResponse{Success: false, Message: "Failed to generate token"})
+	}
+
+	logCustomerAction(c, subscriber, models.AuditActionLogin, "Logged in to customer portal with a one-time code")
+
+	return c.JSON(CustomerLoginResponse{
+		Success:  true,
+		Token:    token,
+		Customer: newCustomerInfo(subscriber),
+	})
+}
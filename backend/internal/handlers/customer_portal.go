@@ -9,7 +9,6 @@ import (
 	"github.com/proisp/backend/internal/config"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/models"
-	"github.com/proisp/backend/internal/security"
 )
 
 type CustomerPortalHandler struct {
@@ -32,6 +31,11 @@ type CustomerLoginResponse struct {
 	Message  string          `json:"message,omitempty"`
 	Token    string          `json:"token,omitempty"`
 	Customer *CustomerInfo   `json:"customer,omitempty"`
+
+	// Set by OTP login when the phone number owns several subscriptions
+	RequiresSelection bool            `json:"requires_selection,omitempty"`
+	SelectionToken    string          `json:"selection_token,omitempty"`
+	Accounts          []*CustomerInfo `json:"accounts,omitempty"`
 }
 
 // CustomerInfo represents customer info in response
@@ -129,17 +133,7 @@ func (h *CustomerPortalHandler) Login(c *fiber.Ctx) error {
 	}
 
 	// Verify password against radcheck table (Cleartext-Password)
-	var radcheck models.RadCheck
-	if err := database.DB.Where("username = ? AND attribute = ?", req.Username, "Cleartext-Password").First(&radcheck).Error; err != nil {
-		// Try checking against subscriber's encrypted password
-		plainPassword := security.DecryptPassword(subscriber.PasswordPlain)
-		if plainPassword != req.Password {
-			return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{
-				Success: false,
-				Message: "Invalid username or password",
-			})
-		}
-	} else if radcheck.Value != req.Password {
+	if !customerPasswordMatches(&subscriber, req.Password) {
 		return c.Status(fiber.StatusUnauthorized).JSON(CustomerLoginResponse{
 			Success: false,
 			Message: "Invalid username or password",
@@ -155,6 +149,15 @@ func (h *CustomerPortalHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
+	return c.JSON(CustomerLoginResponse{
+		Success:  true,
+		Token:    token,
+		Customer: newCustomerInfo(&subscriber),
+	})
+}
+
+// newCustomerInfo builds the customer summary returned on login
+func newCustomerInfo(subscriber *models.Subscriber) *CustomerInfo {
 	// Calculate days left
 	daysLeft := 0
 	if subscriber.ExpiryDate.After(time.Now()) {
@@ -172,20 +175,21 @@ func (h *CustomerPortalHandler) Login(c *fiber.Ctx) error {
 		status = "stopped"
 	}
 
-	return c.JSON(CustomerLoginResponse{
-		Success: true,
-		Token:   token,
-		Customer: &CustomerInfo{
-			Username:    subscriber.Username,
-			FullName:    subscriber.FullName,
-			Email:       subscriber.Email,
-			Phone:       subscriber.Phone,
-			ServiceName: subscriber.Service.Name,
-			Status:      status,
-			ExpiryDate:  subscriber.ExpiryDate,
-			DaysLeft:    daysLeft,
-		},
-	})
+	serviceName := ""
+	if subscriber.Service != nil {
+		serviceName = subscriber.Service.Name
+	}
+
+	return &CustomerInfo{
+		Username:    subscriber.Username,
+		FullName:    subscriber.FullName,
+		Email:       subscriber.Email,
+		Phone:       subscriber.Phone,
+		ServiceName: serviceName,
+		Status:      status,
+		ExpiryDate:  subscriber.ExpiryDate,
+		DaysLeft:    daysLeft,
+	}
 }
 
 // Dashboard returns customer dashboard data
@@ -263,10 +267,7 @@ func (h *CustomerPortalHandler) Dashboard(c *fiber.Ctx) error {
 	}
 
 	// Determine effective price (override_price takes precedence over service price)
-	effectivePrice := subscriber.Service.Price
-	if subscriber.OverridePrice && subscriber.Price > 0 {
-		effectivePrice = subscriber.Price
-	}
+	effectivePrice := customerRenewalPrice(&subscriber)
 
 	// Get IP from active session
 	ipAddress := subscriber.IPAddress
@@ -383,31 +384,30 @@ func (h *CustomerPortalHandler) UsageHistory(c *fiber.Ctx) error {
 		historyMap[row.Date] = row
 	}
 
-	// 2) Fallback: query radacct for days missing from history (grouped by session start date)
-	type RadacctDay struct {
+	// 2) Fallback: daily usage rollups for days missing from history, and session counts
+	type RollupDay struct {
 		Date     string `json:"date"`
 		Download int64  `json:"download"`
 		Upload   int64  `json:"upload"`
 		Sessions int    `json:"sessions"`
 	}
-	var radacctDays []RadacctDay
+	var rollupDays []RollupDay
 	database.DB.Raw(`
-		SELECT acctstarttime::date::text AS date,
-		       COALESCE(SUM(acctoutputoctets), 0) AS download,
-		       COALESCE(SUM(acctinputoctets), 0) AS upload,
-		       COUNT(*) AS sessions
-		FROM radacct
-		WHERE username = ? AND acctstarttime >= CURRENT_DATE - INTERVAL '30 days'
-		GROUP BY acctstarttime::date
-		ORDER BY date ASC
-	`, username).Scan(&radacctDays)
+		SELECT day::text AS date,
+		       output_octets AS download,
+		       input_octets AS upload,
+		       sessions
+		FROM subscriber_usage_daily
+		WHERE username = ? AND day >= CURRENT_DATE - INTERVAL '30 days'
+		ORDER BY day ASC
+	`, username).Scan(&rollupDays)
 
-	radacctMap := make(map[string]RadacctDay)
-	for _, rd := range radacctDays {
-		radacctMap[rd.Date] = rd
+	rollupMap := make(map[string]RollupDay)
+	for _, rd := range rollupDays {
+		rollupMap[rd.Date] = rd
 	}
 
-	// 3) Build daily array: history > radacct fallback, live counters for today
+	// 3) Build daily array: history > rollup fallback, live counters for today
 	type DailyUsage struct {
 		Date     string `json:"date"`
 		Download int64  `json:"download"`
@@ -427,12 +427,12 @@ func (h *CustomerPortalHandler) UsageHistory(c *fiber.Ctx) error {
 		if h, ok := historyMap[date]; ok {
 			// Accurate source
 			entry := DailyUsage{Date: h.Date, Download: h.DownloadBytes, Upload: h.UploadBytes}
-			if rd, ok2 := radacctMap[date]; ok2 {
+			if rd, ok2 := rollupMap[date]; ok2 {
 				entry.Sessions = rd.Sessions
 			}
 			result = append(result, entry)
-		} else if rd, ok := radacctMap[date]; ok {
-			// Fallback to radacct (better than empty)
+		} else if rd, ok := rollupMap[date]; ok {
+			// Fallback to the rollup (better than empty)
 			result = append(result, DailyUsage{Date: rd.Date, Download: rd.Download, Upload: rd.Upload, Sessions: rd.Sessions})
 		}
 	}
@@ -443,7 +443,7 @@ func (h *CustomerPortalHandler) UsageHistory(c *fiber.Ctx) error {
 		Download: subscriber.DailyDownloadUsed,
 		Upload:   subscriber.DailyUploadUsed,
 	}
-	if rd, ok := radacctMap[today]; ok {
+	if rd, ok := rollupMap[today]; ok {
 		todayEntry.Sessions = rd.Sessions
 	}
 	result = append(result, todayEntry)
@@ -0,0 +1,772 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/commission"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/security"
+	"github.com/proisp/backend/internal/services"
+	"github.com/proisp/backend/internal/webhook"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Failed prepaid card attempts allowed per subscriber before the portal stops accepting cards
+	portalCardMaxFailures = 5
+	portalCardFailureTTL  = 15 * time.Minute
+
+	// Contact changes wait this long for the code sent to the new email/phone
+	portalContactCodeTTL     = 15 * time.Minute
+	portalContactMaxAttempts = 5
+	portalMinPasswordLength  = 6
+)
+
+// pendingContactChange is kept in Redis until the subscriber confirms the verification code
+type pendingContactChange struct {
+	Email    *string `json:"email,omitempty"`
+	Phone    *string `json:"phone,omitempty"`
+	CodeHash string  `json:"code_hash"`
+}
+
+// getPortalPermissions returns the reseller's portal permissions, or the defaults if none are saved
+func getPortalPermissions(resellerID uint) models.PortalPermissions {
+	var perms models.PortalPermissions
+	if err := database.DB.Where("reseller_id = ?", resellerID).First(&perms).Error; err != nil {
+		return models.DefaultPortalPermissions(resellerID)
+	}
+	return perms
+}
+
+// customerRenewalPrice is what the subscriber pays for one period (override price takes precedence)
+func customerRenewalPrice(subscriber *models.Subscriber) float64 {
+	if subscriber.Service == nil {
+		return subscriber.Price
+	}
+	if subscriber.OverridePrice && subscriber.Price > 0 {
+		return subscriber.Price
+	}
+	return subscriber.Service.Price
+}
+
+// customerPasswordMatches checks a PPPoE password against radcheck, falling back to the stored one
+func customerPasswordMatches(subscriber *models.Subscriber, password string) bool {
+	var radcheck models.RadCheck
+	if err := database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Cleartext-Password").First(&radcheck).Error; err != nil {
+		return security.DecryptPassword(subscriber.PasswordPlain) == password
+	}
+	return radcheck.Value == password
+}
+
+// adjustWallet atomically moves amount (negative to debit) on the subscriber's wallet and records it.
+// It returns false without changing anything when a debit would take the balance below zero.
+func adjustWallet(subscriber *models.Subscriber, amount float64, txType models.WalletTransactionType, reference, description, ip string) (float64, bool) {
+	var balances []float64
+	err := database.DB.Raw(
+		"UPDATE subscribers SET wallet_balance = wallet_balance + ? WHERE id = ? AND wallet_balance + ? >= 0 RETURNING wallet_balance",
+		amount, subscriber.ID, amount,
+	).Scan(&balances).Error
+	if err != nil || len(balances) == 0 {
+		return 0, false
+	}
+
+	subscriber.WalletBalance = balances[0]
+	database.DB.Create(&models.WalletTransaction{
+		SubscriberID: subscriber.ID,
+		ResellerID:   subscriber.ResellerID,
+		Type:         txType,
+		Amount:       amount,
+		BalanceAfter: balances[0],
+		Reference:    reference,
+		Description:  description,
+		IPAddress:    ip,
+	})
+	return balances[0], true
+}
+
+// portalCardLocked reports whether the subscriber has used up their failed card attempts
+func portalCardLocked(subscriberID uint) bool {
+	count, err := database.Redis.Get(context.Background(), fmt.Sprintf("proisp:portal:card_fail:%d", subscriberID)).Int()
+	return err == nil && count >= portalCardMaxFailures
+}
+
+func recordPortalCardFailure(subscriberID uint) {
+	ctx := context.Background()
+	key := fmt.Sprintf("proisp:portal:card_fail:%d", subscriberID)
+	if database.Redis.Incr(ctx, key).Val() == 1 {
+		database.Redis.Expire(ctx, key, portalCardFailureTTL)
+	}
+}
+
+// countCodeAttempt counts a guess at a one-time code and returns the guesses so far.
+// The counter is atomic so parallel guesses cannot get past the limit, and it expires
+// with the code.
+func countCodeAttempt(key string, ttl time.Duration) int64 {
+	ctx := context.Background()
+	count := database.Redis.Incr(ctx, key).Val()
+	if count == 1 {
+		database.Redis.Expire(ctx, key, ttl)
+	}
+	return count
+}
+
+// redeemPortalCard validates and claims a prepaid card for the subscriber
+func (h *CustomerPortalHandler) redeemPortalCard(c *fiber.Ctx, subscriber *models.Subscriber, code, pin string) (*models.PrepaidCard, error) {
+	if portalCardLocked(subscriber.ID) {
+		return nil, c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"success": false,
+			"message": "Too many invalid card attempts. Please try again later",
+		})
+	}
+
+	card, cardErr := findRedeemableCard(strings.TrimSpace(code), strings.TrimSpace(pin))
+	if cardErr != nil {
+		recordPortalCardFailure(subscriber.ID)
+		return nil, c.Status(cardErr.Code).JSON(fiber.Map{"success": false, "message": cardErr.Message})
+	}
+	return card, nil
+}
+
+// loadCustomer loads the logged-in subscriber with their service
+func (h *CustomerPortalHandler) loadCustomer(c *fiber.Ctx, preloads ...string) (*models.Subscriber, error) {
+	username := c.Locals("customer_username").(string)
+
+	query := database.DB.Preload("Service")
+	for _, p := range preloads {
+		query = query.Preload(p)
+	}
+	var subscriber models.Subscriber
+	if err := query.Where("username = ?", username).First(&subscriber).Error; err != nil {
+		return nil, err
+	}
+	return &subscriber, nil
+}
+
+// logCustomerAction records a portal action in the audit log under the subscriber's name
+func logCustomerAction(c *fiber.Ctx, subscriber *models.Subscriber, action models.AuditAction, description string) {
+	database.DB.Create(&models.AuditLog{
+		Username:    subscriber.Username,
+		UserType:    models.UserTypeSubscriber,
+		Action:      action,
+		EntityType:  "subscriber",
+		EntityID:    subscriber.ID,
+		EntityName:  subscriber.Username,
+		Description: description,
+		IPAddress:   c.IP(),
+		UserAgent:   c.Get("User-Agent"),
+	})
+}
+
+func portalActionDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"success": false,
+		"message": "This action is not available from the customer portal. Please contact your provider",
+	})
+}
+
+// SelfService returns which portal actions the subscriber can take and what they cost
+func (h *CustomerPortalHandler) SelfService(c *fiber.Ctx) error {
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+
+	resetPrice := 0.0
+	if subscriber.Service != nil {
+		resetPrice = subscriber.Service.ResetPrice
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"permissions":     getPortalPermissions(subscriber.ResellerID),
+			"wallet_balance":  subscriber.WalletBalance,
+			"renewal_price":   customerRenewalPrice(subscriber),
+			"reset_fup_price": resetPrice,
+			"fup_active":      subscriber.FUPLevel > 0,
+		},
+	})
+}
+
+// Wallet returns the subscriber's wallet balance and recent movements
+func (h *CustomerPortalHandler) Wallet(c *fiber.Ctx) error {
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+
+	var transactions []models.WalletTransaction
+	database.DB.Where("subscriber_id = ?", subscriber.ID).Order("created_at DESC").Limit(50).Find(&transactions)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"balance":      subscriber.WalletBalance,
+			"transactions": transactions,
+		},
+	})
+}
+
+// TopUpWallet credits the value of a prepaid card to the subscriber's wallet
+func (h *CustomerPortalHandler) TopUpWallet(c *fiber.Ctx) error {
+	var req struct {
+		Code string `json:"code"`
+		PIN  string `json:"pin"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowWalletTopUp {
+		return portalActionDisabled(c)
+	}
+
+	card, err := h.redeemPortalCard(c, subscriber, req.Code, req.PIN)
+	if card == nil {
+		return err
+	}
+	if card.Value <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "This card has no value to add to your wallet"})
+	}
+	// Commissions are posted when the wallet pays for a renewal, not on top-up
+	if claimPrepaidCard(card, subscriber.ID, c.IP()) == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Card has already been used"})
+	}
+
+	balance, _ := adjustWallet(subscriber, card.Value, models.WalletTransactionTopUp, card.Code,
+		fmt.Sprintf("Wallet top-up with prepaid card %s", card.Code), c.IP())
+	logCustomerAction(c, subscriber, models.AuditActionUpdate, fmt.Sprintf("Topped up wallet with %.2f (card %s)", card.Value, card.Code))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Wallet topped up successfully",
+		"data": fiber.Map{
+			"amount":  card.Value,
+			"balance": balance,
+		},
+	})
+}
+
+// Renew renews the subscriber's current plan with a prepaid card or from the wallet
+func (h *CustomerPortalHandler) Renew(c *fiber.Ctx) error {
+	var req struct {
+		Method string `json:"method"` // card or wallet
+		Code   string `json:"code"`
+		PIN    string `json:"pin"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowRenew {
+		return portalActionDisabled(c)
+	}
+
+	var newExpiry time.Time
+	var description string
+
+	switch req.Method {
+	case "card":
+		card, err := h.redeemPortalCard(c, subscriber, req.Code, req.PIN)
+		if card == nil {
+			return err
+		}
+		if card.Days <= 0 && card.Hours <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "This card does not add time. Use it to top up your wallet instead",
+			})
+		}
+		transaction := claimPrepaidCard(card, subscriber.ID, c.IP())
+		if transaction == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Card has already been used"})
+		}
+		applyPrepaidCard(card, subscriber)
+		commission.PostAsync(*transaction)
+
+		database.DB.Select("expiry_date").First(subscriber, subscriber.ID)
+		newExpiry = subscriber.ExpiryDate
+		description = fmt.Sprintf("Renewed from customer portal with prepaid card %s until %s", card.Code, newExpiry.Format("2006-01-02"))
+
+	case "wallet":
+		if subscriber.Service == nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No service assigned"})
+		}
+		price := customerRenewalPrice(subscriber)
+		if price <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Your plan has no renewal price. Please contact your provider",
+			})
+		}
+		if _, ok := adjustWallet(subscriber, -price, models.WalletTransactionRenewal, subscriber.Service.Name,
+			fmt.Sprintf("Renewal: %s", subscriber.Service.Name), c.IP()); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": fmt.Sprintf("Insufficient wallet balance. Required: %.2f, Available: %.2f", price, subscriber.WalletBalance),
+			})
+		}
+
+		newExpiry = renewSubscriber(subscriber)
+		description = fmt.Sprintf("Renewed from customer portal with wallet (%.2f) until %s", price, newExpiry.Format("2006-01-02"))
+
+		// Paid by the subscriber, so the amount is positive like invoice payments
+		transaction := models.Transaction{
+			Type:         models.TransactionTypeRenewal,
+			Amount:       price,
+			ResellerID:   subscriber.ResellerID,
+			SubscriberID: &subscriber.ID,
+			ServiceName:  subscriber.Service.Name,
+			Description:  fmt.Sprintf("Customer portal renewal from wallet: %s", subscriber.Username),
+			IPAddress:    c.IP(),
+		}
+		database.DB.Create(&transaction)
+		commission.PostAsync(transaction)
+
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Method must be card or wallet"})
+	}
+
+	logCustomerAction(c, subscriber, models.AuditActionRenew, description)
+	webhook.EmitSubscriber(models.WebhookEventSubscriberRenewed, subscriber, map[string]interface{}{
+		"renewed_by": "customer_portal",
+		"method":     req.Method,
+	})
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Subscription renewed successfully",
+		"data": fiber.Map{
+			"new_expiry":     newExpiry,
+			"wallet_balance": subscriber.WalletBalance,
+		},
+	})
+}
+
+// customerPlans returns the active services the subscriber may move to: the reseller's enabled
+// services when it has assignments, otherwise every active service
+func customerPlans(subscriber *models.Subscriber) []models.Service {
+	query := database.DB.Where("is_active = ? AND id <> ?", true, subscriber.ServiceID)
+
+	var assigned []uint
+	database.DB.Model(&models.ResellerService{}).
+		Where("reseller_id = ? AND is_enabled = ?", subscriber.ResellerID, true).
+		Pluck("service_id", &assigned)
+	if len(assigned) > 0 {
+		query = query.Where("id IN ?", assigned)
+	}
+
+	var plans []models.Service
+	query.Order("sort_order ASC, name ASC").Find(&plans)
+	return plans
+}
+
+// findCustomerPlan loads one of customerPlans by the :id route parameter
+func findCustomerPlan(c *fiber.Ctx, subscriber *models.Subscriber) (*models.Service, error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid plan ID"})
+	}
+	for _, plan := range customerPlans(subscriber) {
+		if plan.ID == uint(id) {
+			return &plan, nil
+		}
+	}
+	return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Plan not available"})
+}
+
+// Plans lists the plans the subscriber can change to, each with a price quote
+func (h *CustomerPortalHandler) Plans(c *fiber.Ctx) error {
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowChangePlan {
+		return portalActionDisabled(c)
+	}
+	if subscriber.Service == nil {
+		return c.JSON(fiber.Map{"success": true, "data": []fiber.Map{}})
+	}
+
+	plans := customerPlans(subscriber)
+	result := make([]fiber.Map, 0, len(plans))
+	for i := range plans {
+		plan := &plans[i]
+		result = append(result, fiber.Map{
+			"id":             plan.ID,
+			"name":           plan.Name,
+			"description":    plan.Description,
+			"download_speed": plan.DownloadSpeed,
+			"upload_speed":   plan.UploadSpeed,
+			"daily_quota":    plan.DailyQuota,
+			"monthly_quota":  plan.MonthlyQuota,
+			"price":          plan.Price,
+			"expiry_value":   plan.ExpiryValue,
+			"expiry_unit":    plan.ExpiryUnit,
+			"quote":          quoteServiceChange(subscriber, plan),
+		})
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": result})
+}
+
+// PlanQuote prices a change to one plan for the rest of the current period
+func (h *CustomerPortalHandler) PlanQuote(c *fiber.Ctx) error {
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowChangePlan {
+		return portalActionDisabled(c)
+	}
+	if subscriber.Service == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No service assigned"})
+	}
+
+	plan, err := findCustomerPlan(c, subscriber)
+	if plan == nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    quoteServiceChange(subscriber, plan),
+	})
+}
+
+// ChangePlan moves the subscriber to another plan, paying the quoted amount from the wallet
+// (or crediting it back when downgrade refunds are enabled)
+func (h *CustomerPortalHandler) ChangePlan(c *fiber.Ctx) error {
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowChangePlan {
+		return portalActionDisabled(c)
+	}
+	if subscriber.Service == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No service assigned"})
+	}
+
+	plan, err := findCustomerPlan(c, subscriber)
+	if plan == nil {
+		return err
+	}
+
+	quote := quoteServiceChange(subscriber, plan)
+	if quote.IsDowngrade && !quote.DowngradeAllowed {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Service downgrade is not allowed"})
+	}
+
+	oldService := subscriber.Service
+	description := fmt.Sprintf("Service change: %s -> %s", oldService.Name, plan.Name)
+	if quote.TotalCharge != 0 {
+		if _, ok := adjustWallet(subscriber, -quote.TotalCharge, models.WalletTransactionChangeService, plan.Name, description, c.IP()); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": fmt.Sprintf("Insufficient wallet balance. Required: %.2f, Available: %.2f", quote.TotalCharge, subscriber.WalletBalance),
+			})
+		}
+	}
+
+	result := database.DB.Model(&models.Subscriber{}).Where("id = ?", subscriber.ID).Updates(map[string]interface{}{
+		"service_id": plan.ID,
+		"price":      plan.Price,
+	})
+	if result.Error != nil {
+		log.Printf("CustomerPortal: Failed to change service for %s: %v", subscriber.Username, result.Error)
+		if quote.TotalCharge != 0 {
+			adjustWallet(subscriber, quote.TotalCharge, models.WalletTransactionChangeService, plan.Name, "Reversal: "+description, c.IP())
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to change plan"})
+	}
+
+	applyServiceRadius(subscriber.Username, plan)
+	logCustomerAction(c, subscriber, models.AuditActionUpdate,
+		fmt.Sprintf("Changed service from %s to %s from customer portal. Charge: %.2f", oldService.Name, plan.Name, quote.TotalCharge))
+	disconnectAfterServiceChange(subscriber)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Plan changed successfully. You will be reconnected shortly",
+		"data": fiber.Map{
+			"service_name":   plan.Name,
+			"charged":        quote.TotalCharge,
+			"wallet_balance": subscriber.WalletBalance,
+		},
+	})
+}
+
+// ResetFUP buys a daily FUP reset at the service's reset price, paid from the wallet
+func (h *CustomerPortalHandler) ResetFUP(c *fiber.Ctx) error {
+	subscriber, err := h.loadCustomer(c, "Nas")
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowResetFUP {
+		return portalActionDisabled(c)
+	}
+	if subscriber.Service == nil || subscriber.Service.ResetPrice <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "FUP reset is not available for your plan"})
+	}
+	if subscriber.FUPLevel == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Your speed is not currently reduced"})
+	}
+
+	price := subscriber.Service.ResetPrice
+	if _, ok := adjustWallet(subscriber, -price, models.WalletTransactionResetFUP, subscriber.Service.Name,
+		fmt.Sprintf("FUP reset: %s", subscriber.Service.Name), c.IP()); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Insufficient wallet balance. Required: %.2f, Available: %.2f", price, subscriber.WalletBalance),
+		})
+	}
+
+	resetSubscriberFUP(subscriber)
+	logCustomerAction(c, subscriber, models.AuditActionResetFUP, fmt.Sprintf("Bought FUP reset from customer portal (%.2f)", price))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "FUP reset successfully",
+		"data": fiber.Map{
+			"charged":        price,
+			"wallet_balance": subscriber.WalletBalance,
+		},
+	})
+}
+
+func hashContactCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// UpdateProfile updates the subscriber's address right away; a new email or phone only takes
+// effect after the code sent to it is confirmed with VerifyProfile
+func (h *CustomerPortalHandler) UpdateProfile(c *fiber.Ctx) error {
+	var req struct {
+		Email   *string `json:"email"`
+		Phone   *string `json:"phone"`
+		Address *string `json:"address"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowContactUpdate {
+		return portalActionDisabled(c)
+	}
+
+	updates := map[string]interface{}{}
+	pending := pendingContactChange{}
+	var sendTo []string
+
+	if req.Address != nil && strings.TrimSpace(*req.Address) != subscriber.Address {
+		address := strings.TrimSpace(*req.Address)
+		if len(address) > 500 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Address is too long"})
+		}
+		updates["address"] = address
+	}
+
+	if req.Email != nil && strings.TrimSpace(*req.Email) != subscriber.Email {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			updates["email"] = ""
+		} else {
+			if _, err := mail.ParseAddress(email); err != nil || strings.ContainsAny(email, " <>") {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid email address"})
+			}
+			pending.Email = &email
+			sendTo = append(sendTo, "email")
+		}
+	}
+
+	if req.Phone != nil && strings.TrimSpace(*req.Phone) != subscriber.Phone {
+		phone := strings.TrimSpace(*req.Phone)
+		if phone == "" {
+			updates["phone"] = ""
+		} else {
+			digits := strings.TrimLeft(strings.NewReplacer(" ", "", "-", "").Replace(phone), "+")
+			if _, err := strconv.ParseUint(digits, 10, 64); err != nil || len(digits) < 6 || len(digits) > 15 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid phone number"})
+			}
+			pending.Phone = &phone
+			sendTo = append(sendTo, "phone")
+		}
+	}
+
+	if len(updates) > 0 {
+		database.DB.Model(&models.Subscriber{}).Where("id = ?", subscriber.ID).Updates(updates)
+		logCustomerAction(c, subscriber, models.AuditActionUpdate, "Updated contact details from customer portal")
+	}
+
+	if len(sendTo) == 0 {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Profile updated",
+			"data":    fiber.Map{"verification_required": false},
+		})
+	}
+
+	code := generatePIN(6)
+	pending.CodeHash = hashContactCode(code)
+	message := fmt.Sprintf("Your %s verification code is %s. It expires in %d minutes.",
+		database.GetCompanyName(), code, int(portalContactCodeTTL.Minutes()))
+
+	if pending.Email != nil {
+		if err := services.NewEmailService().SendEmail(*pending.Email, "Verify your email address", message, false); err != nil {
+			log.Printf("CustomerPortal: Failed to send email verification to %s: %v", subscriber.Username, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"success": false, "message": "Failed to send verification code by email"})
+		}
+	}
+	if pending.Phone != nil {
+		if err := services.NewSMSService().SendSMS(*pending.Phone, message); err != nil {
+			log.Printf("CustomerPortal: Failed to send SMS verification to %s: %v", subscriber.Username, err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"success": false, "message": "Failed to send verification code by SMS"})
+		}
+	}
+
+	if err := database.CacheSet(fmt.Sprintf("proisp:portal:contact:%d", subscriber.ID), pending, portalContactCodeTTL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to start verification"})
+	}
+	database.CacheDelete(fmt.Sprintf("proisp:portal:contact_attempts:%d", subscriber.ID))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "We sent a verification code to your new contact details",
+		"data": fiber.Map{
+			"verification_required": true,
+			"sent_to":               sendTo,
+		},
+	})
+}
+
+// VerifyProfile applies a pending email/phone change once its code is confirmed
+func (h *CustomerPortalHandler) VerifyProfile(c *fiber.Ctx) error {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+
+	key := fmt.Sprintf("proisp:portal:contact:%d", subscriber.ID)
+	attemptsKey := fmt.Sprintf("proisp:portal:contact_attempts:%d", subscriber.ID)
+	var pending pendingContactChange
+	if err := database.CacheGet(key, &pending); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No pending change or the code has expired"})
+	}
+
+	attempts := countCodeAttempt(attemptsKey, portalContactCodeTTL)
+	if attempts > portalContactMaxAttempts {
+		database.CacheDelete(key, attemptsKey)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Too many invalid attempts. Please request a new code"})
+	}
+	codeHash := hashContactCode(strings.TrimSpace(req.Code))
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(pending.CodeHash)) != 1 {
+		if attempts == portalContactMaxAttempts {
+			database.CacheDelete(key, attemptsKey)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Too many invalid attempts. Please request a new code"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid verification code"})
+	}
+
+	// Take the change so a code is only applied once, even by parallel requests
+	if err := database.CacheTake(key, &pending); err != nil || pending.CodeHash != codeHash {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No pending change or the code has expired"})
+	}
+	database.CacheDelete(attemptsKey)
+
+	updates := map[string]interface{}{}
+	if pending.Email != nil {
+		updates["email"] = *pending.Email
+	}
+	if pending.Phone != nil {
+		updates["phone"] = *pending.Phone
+	}
+	database.DB.Model(&models.Subscriber{}).Where("id = ?", subscriber.ID).Updates(updates)
+	logCustomerAction(c, subscriber, models.AuditActionUpdate, "Verified and updated contact details from customer portal")
+
+	return c.JSON(fiber.Map{"success": true, "message": "Contact details updated"})
+}
+
+// ChangePassword changes the subscriber's PPPoE password (also used for portal login)
+func (h *CustomerPortalHandler) ChangePassword(c *fiber.Ctx) error {
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+	}
+
+	subscriber, err := h.loadCustomer(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+	if !getPortalPermissions(subscriber.ResellerID).AllowPasswordChange {
+		return portalActionDisabled(c)
+	}
+
+	if !customerPasswordMatches(subscriber, req.CurrentPassword) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Current password is incorrect"})
+	}
+	if len(req.NewPassword) < portalMinPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("New password must be at least %d characters", portalMinPasswordLength),
+		})
+	}
+	if strings.ContainsAny(req.NewPassword, " \t\r\n\"'") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "New password must not contain spaces or quotes"})
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "New password must be different"})
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to update password"})
+	}
+	database.DB.Model(&models.Subscriber{}).Where("id = ?", subscriber.ID).Updates(map[string]interface{}{
+		"password":       string(hashedPassword),
+		"password_plain": security.EncryptPassword(req.NewPassword),
+	})
+	database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Cleartext-Password").Delete(&models.RadCheck{})
+	database.DB.Create(&models.RadCheck{Username: subscriber.Username, Attribute: "Cleartext-Password", Op: ":=", Value: req.NewPassword})
+
+	logCustomerAction(c, subscriber, models.AuditActionUpdate, "Changed PPPoE password from customer portal")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Password changed. Use the new password the next time your router connects",
+	})
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/mikrotik"
+	"github.com/proisp/backend/internal/models"
+)
+
+type HotspotHandler struct{}
+
+func NewHotspotHandler() *HotspotHandler {
+	return &HotspotHandler{}
+}
+
+type WalledGardenRequest struct {
+	NasID      *uint  `json:"nas_id"`
+	DstHost    string `json:"dst_host"`
+	DstAddress string `json:"dst_address"`
+	DstPort    string `json:"dst_port"`
+	Action     string `json:"action"`
+	Comment    string `json:"comment"`
+	Enabled    *bool  `json:"enabled"`
+}
+
+// ListWalledGarden returns all walled-garden entries (global and per-NAS)
+func (h *HotspotHandler) ListWalledGarden(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.HotspotWalledGarden{})
+	if nasID := c.QueryInt("nas_id", 0); nasID > 0 {
+		query = query.Where("nas_id IS NULL OR nas_id = ?", nasID)
+	}
+
+	var entries []models.HotspotWalledGarden
+	if err := query.Order("id").Find(&entries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch walled garden",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+	})
+}
+
+// CreateWalledGarden adds a walled-garden entry
+func (h *HotspotHandler) CreateWalledGarden(c *fiber.Ctx) error {
+	var req WalledGardenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	entry := models.HotspotWalledGarden{Enabled: true}
+	if msg := applyWalledGardenRequest(&entry, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create walled garden entry",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Walled garden entry created",
+		"data":    entry,
+	})
+}
+
+// UpdateWalledGarden edits a walled-garden entry
+func (h *HotspotHandler) UpdateWalledGarden(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid ID",
+		})
+	}
+
+	var entry models.HotspotWalledGarden
+	if err := database.DB.First(&entry, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Walled garden entry not found",
+		})
+	}
+
+	var req WalledGardenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	if msg := applyWalledGardenRequest(&entry, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	if err := database.DB.Save(&entry).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update walled garden entry",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Walled garden entry updated",
+		"data":    entry,
+	})
+}
+
+// DeleteWalledGarden removes a walled-garden entry
+func (h *HotspotHandler) DeleteWalledGarden(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid ID",
+		})
+	}
+
+	result := database.DB.Delete(&models.HotspotWalledGarden{}, id)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Walled garden entry not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Walled garden entry deleted",
+	})
+}
+
+// SyncWalledGarden pushes the walled garden to every hotspot NAS (or the one given by ?nas_id)
+func (h *HotspotHandler) SyncWalledGarden(c *fiber.Ctx) error {
+	query := database.DB.Where("hotspot_enabled = ? AND is_active = ?", true, true)
+	if nasID := c.QueryInt("nas_id", 0); nasID > 0 {
+		query = query.Where("id = ?", nasID)
+	}
+
+	var nasList []models.Nas
+	query.Find(&nasList)
+	if len(nasList) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "No hotspot-enabled NAS found",
+		})
+	}
+
+	var entries []models.HotspotWalledGarden
+	database.DB.Where("enabled = ?", true).Order("id").Find(&entries)
+
+	type syncResult struct {
+		NasID   uint   `json:"nas_id"`
+		NasName string `json:"nas_name"`
+		Success bool   `json:"success"`
+		Entries int    `json:"entries"`
+		Message string `json:"message,omitempty"`
+	}
+	results := make([]syncResult, 0, len(nasList))
+	synced := 0
+
+	for _, nas := range nasList {
+		result := syncResult{NasID: nas.ID, NasName: nas.Name}
+		if nas.APIUsername == "" || nas.APIPassword == "" {
+			result.Message = "API credentials are not configured"
+			results = append(results, result)
+			continue
+		}
+
+		var nasEntries []mikrotik.WalledGardenEntry
+		for _, e := range entries {
+			if e.NasID != nil && *e.NasID != nas.ID {
+				continue
+			}
+			nasEntries = append(nasEntries, mikrotik.WalledGardenEntry{
+				DstHost:    e.DstHost,
+				DstAddress: e.DstAddress,
+				DstPort:    e.DstPort,
+				Allow:      e.Action != "deny",
+				Comment:    e.Comment,
+			})
+		}
+
+		client := mikrotik.NewClient(fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort), nas.APIUsername, nas.APIPassword)
+		count, err := client.SyncWalledGarden(nasEntries)
+		client.Close()
+		if err != nil {
+			result.Message = err.Error()
+		} else {
+			result.Success = true
+			synced++
+		}
+		result.Entries = count
+		results = append(results, result)
+	}
+
+	// Create audit log
+	user := middleware.GetCurrentUser(c)
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionUpdate,
+		EntityType:  "hotspot",
+		EntityName:  "walled_garden",
+		Description: fmt.Sprintf("Synced walled garden to %d/%d NAS", synced, len(nasList)),
+		IPAddress:   c.IP(),
+	}
+	database.DB.Create(&auditLog)
+
+	return c.JSON(fiber.Map{
+		"success": synced > 0,
+		"message": fmt.Sprintf("Walled garden synced to %d of %d NAS", synced, len(nasList)),
+		"data":    results,
+	})
+}
+
+// LiveSessions returns the hotspot active list straight from the routers
+func (h *HotspotHandler) LiveSessions(c *fiber.Ctx) error {
+	query := database.DB.Where("hotspot_enabled = ? AND is_active = ?", true, true)
+	if nasID := c.QueryInt("nas_id", 0); nasID > 0 {
+		query = query.Where("id = ?", nasID)
+	}
+
+	var nasList []models.Nas
+	query.Find(&nasList)
+
+	type nasSession struct {
+		mikrotik.HotspotSession
+		NasID   uint   `json:"nas_id"`
+		NasName string `json:"nas_name"`
+	}
+	sessions := []nasSession{}
+	var nasErrors []string
+
+	for _, nas := range nasList {
+		if nas.APIUsername == "" || nas.APIPassword == "" {
+			continue
+		}
+		client := mikrotik.NewClient(fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort), nas.APIUsername, nas.APIPassword)
+		active, err := client.GetHotspotActiveSessions()
+		client.Close()
+		if err != nil {
+			nasErrors = append(nasErrors, fmt.Sprintf("%s: %v", nas.Name, err))
+			continue
+		}
+		for _, s := range active {
+			sessions = append(sessions, nasSession{HotspotSession: s, NasID: nas.ID, NasName: nas.Name})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    sessions,
+		"errors":  nasErrors,
+	})
+}
+
+// applyWalledGardenRequest copies and validates request fields; returns an error message if invalid
+func applyWalledGardenRequest(entry *models.HotspotWalledGarden, req *WalledGardenRequest) string {
+	entry.NasID = req.NasID
+	entry.DstHost = strings.TrimSpace(req.DstHost)
+	entry.DstAddress = strings.TrimSpace(req.DstAddress)
+	entry.DstPort = strings.TrimSpace(req.DstPort)
+	entry.Comment = req.Comment
+	if req.Enabled != nil {
+		entry.Enabled = *req.Enabled
+	}
+
+	entry.Action = req.Action
+	if entry.Action == "" {
+		entry.Action = "allow"
+	}
+	if entry.Action != "allow" && entry.Action != "deny" {
+		return "Action must be allow or deny"
+	}
+	if (entry.DstHost == "") == (entry.DstAddress == "") {
+		return "Set either dst_host or dst_address"
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+type InboundHandler struct{}
+
+func NewInboundHandler() *InboundHandler {
+	return &InboundHandler{}
+}
+
+// validToken checks the :token route parameter against inbound_webhook_token
+func (h *InboundHandler) validToken(c *fiber.Ctx) bool {
+	expected := getSystemPreference("inbound_webhook_token", "")
+	token := c.Params("token")
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// WhatsApp receives WhatsApp messages. Ultramsg posts JSON for the admin's instance;
+// proxsms posts form fields with the receiving account (data[wid]) for the admin's
+// and resellers' linked accounts.
+func (h *InboundHandler) WhatsApp(c *fiber.Ctx) error {
+	if !h.validToken(c) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Invalid token"})
+	}
+
+	if phone := c.FormValue("data[phone]"); phone != "" {
+		if t := c.FormValue("type"); t != "" && t != "whatsapp" {
+			return c.JSON(fiber.Map{"success": true})
+		}
+		handleInboundMessage(inboundMessage{
+			Channel:    "whatsapp",
+			From:       phone,
+			Body:       c.FormValue("data[message]"),
+			ProviderID: c.FormValue("data[id]"),
+			Account:    c.FormValue("data[wid]"),
+		})
+		return c.JSON(fiber.Map{"success": true})
+	}
+
+	payload, err := services.NewWhatsAppService().ParseWebhook(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	// Only new text messages from customers; skip our own, groups and delivery acks
+	if (payload.EventType != "" && payload.EventType != "message_received") ||
+		payload.FromMe || strings.HasSuffix(payload.From, "@g.us") ||
+		(payload.Type != "" && payload.Type != "chat") {
+		return c.JSON(fiber.Map{"success": true})
+	}
+	handleInboundMessage(inboundMessage{
+		Channel:    "whatsapp",
+		From:       strings.TrimSuffix(payload.From, "@c.us"),
+		Body:       payload.Body,
+		ProviderID: payload.ID,
+	})
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// SMS receives SMS messages as JSON or form fields. The field names of Twilio
+// (From, Body, MessageSid), Vonage (msisdn, text, messageId) and common gateways
+// (from, message, id) are understood.
+func (h *InboundHandler) SMS(c *fiber.Ctx) error {
+	if !h.validToken(c) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Invalid token"})
+	}
+
+	fields := map[string]string{}
+	if strings.Contains(c.Get("Content-Type"), "application/json") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal(c.Body(), &raw); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+		}
+		for k, v := range raw {
+			if s, ok := v.(string); ok {
+				fields[k] = s
+			}
+		}
+	}
+	value := func(keys ...string) string {
+		for _, k := range keys {
+			if v := fields[k]; v != "" {
+				return v
+			}
+			if v := c.FormValue(k); v != "" {
+				return v
+			}
+			if v := c.Query(k); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	handleInboundMessage(inboundMessage{
+		Channel:    "sms",
+		From:       value("From", "from", "msisdn", "sender", "phone"),
+		Body:       value("Body", "body", "text", "message"),
+		ProviderID: value("MessageSid", "messageId", "message_id", "id"),
+	})
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// List returns received messages with the chatbot's replies, newest first
+func (h *InboundHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	query := database.DB.Model(&models.InboundMessage{})
+	user := middleware.GetCurrentUser(c)
+	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		query = query.Where("reseller_id = ?", *user.ResellerID)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if command := c.Query("command"); command != "" {
+		query = query.Where("command = ?", command)
+	}
+	if subscriberID := c.QueryInt("subscriber_id", 0); subscriberID > 0 {
+		query = query.Where("subscriber_id = ?", subscriberID)
+	}
+	if search := c.Query("search"); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("phone ILIKE ? OR body ILIKE ?", like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var messages []models.InboundMessage
+	query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&messages)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    messages,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
@@ -6,11 +6,13 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/commission"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/mikrotik"
 	"github.com/proisp/backend/internal/models"
 	"github.com/proisp/backend/internal/radius"
+	"github.com/proisp/backend/internal/webhook"
 )
 
 type InvoiceHandler struct{}
@@ -19,6 +21,36 @@ func NewInvoiceHandler() *InvoiceHandler {
 	return &InvoiceHandler{}
 }
 
+// InvoiceItemRequest is one line of a new invoice
+type InvoiceItemRequest struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+}
+
+// CreateInvoiceRequest represents create invoice request
+type CreateInvoiceRequest struct {
+	SubscriberID uint                 `json:"subscriber_id"`
+	DueDate      string               `json:"due_date"` // YYYY-MM-DD
+	Notes        string               `json:"notes"`
+	Items        []InvoiceItemRequest `json:"items"`
+}
+
+// UpdateInvoiceRequest represents update invoice request; empty fields are left unchanged
+type UpdateInvoiceRequest struct {
+	DueDate string `json:"due_date"`
+	Notes   string `json:"notes"`
+	Status  string `json:"status"`
+}
+
+// InvoicePaymentRequest represents a payment recorded against an invoice
+type InvoicePaymentRequest struct {
+	Amount    float64 `json:"amount"`
+	Method    string  `json:"method"`
+	Reference string  `json:"reference"`
+	Notes     string  `json:"notes"`
+}
+
 // List returns all invoices
 func (h *InvoiceHandler) List(c *fiber.Ctx) error {
 	page := c.QueryInt("page", 1)
@@ -42,6 +74,11 @@ func (h *InvoiceHandler) List(c *fiber.Ctx) error {
 		query = query.Where("reseller_id = ?", *user.ResellerID)
 	}
 
+	// Staff limited to specific resellers/NASes only see those subscribers' invoices
+	if user != nil && middleware.GetUserPermissions(user).Scoped() {
+		query = query.Where("subscriber_id IN (?)", middleware.ScopeSubscriberQuery(user, database.DB.Model(&models.Subscriber{}).Select("id"), ""))
+	}
+
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -90,15 +127,10 @@ func (h *InvoiceHandler) List(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    invoices,
-		"meta": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
+	return c.JSON(InvoiceListResponse{
+		Success: true,
+		Data:    invoices,
+		Meta:    newListMeta(page, limit, total),
 	})
 }
 
@@ -125,9 +157,9 @@ func (h *InvoiceHandler) Get(c *fiber.Ctx) error {
 		invoice.Subscriber = subscriber
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    invoice,
+	return c.JSON(InvoiceResponse{
+		Success: true,
+		Data:    invoice,
 	})
 }
 
@@ -135,20 +167,7 @@ func (h *InvoiceHandler) Get(c *fiber.Ctx) error {
 func (h *InvoiceHandler) Create(c *fiber.Ctx) error {
 	user := middleware.GetCurrentUser(c)
 
-	type ItemRequest struct {
-		Description string  `json:"description"`
-		Quantity    int     `json:"quantity"`
-		UnitPrice   float64 `json:"unit_price"`
-	}
-
-	type CreateRequest struct {
-		SubscriberID uint          `json:"subscriber_id"`
-		DueDate      string        `json:"due_date"`
-		Notes        string        `json:"notes"`
-		Items        []ItemRequest `json:"items"`
-	}
-
-	var req CreateRequest
+	var req CreateInvoiceRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -223,9 +242,9 @@ func (h *InvoiceHandler) Create(c *fiber.Ctx) error {
 	database.DB.First(&subscriber, subscriber.ID)
 	invoice.Subscriber = subscriber
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"success": true,
-		"data":    invoice,
+	return c.Status(fiber.StatusCreated).JSON(InvoiceResponse{
+		Success: true,
+		Data:    invoice,
 	})
 }
 
@@ -248,13 +267,7 @@ func (h *InvoiceHandler) Update(c *fiber.Ctx) error {
 		})
 	}
 
-	type UpdateRequest struct {
-		DueDate string `json:"due_date"`
-		Notes   string `json:"notes"`
-		Status  string `json:"status"`
-	}
-
-	var req UpdateRequest
+	var req UpdateInvoiceRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -277,9 +290,9 @@ func (h *InvoiceHandler) Update(c *fiber.Ctx) error {
 
 	database.DB.Model(&invoice).Updates(updates)
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    invoice,
+	return c.JSON(InvoiceResponse{
+		Success: true,
+		Data:    invoice,
 	})
 }
 
@@ -324,14 +337,7 @@ func (h *InvoiceHandler) AddPayment(c *fiber.Ctx) error {
 		})
 	}
 
-	type PaymentRequest struct {
-		Amount    float64 `json:"amount"`
-		Method    string  `json:"method"`
-		Reference string  `json:"reference"`
-		Notes     string  `json:"notes"`
-	}
-
-	var req PaymentRequest
+	var req InvoicePaymentRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -384,7 +390,26 @@ func (h *InvoiceHandler) AddPayment(c *fiber.Ctx) error {
 		Amount:       req.Amount,
 		Description:  fmt.Sprintf("Payment for invoice %s", invoice.InvoiceNumber),
 	}
-	database.DB.Create(&transaction)
+	if err := database.DB.Create(&transaction).Error; err == nil {
+		commission.PostAsync(transaction)
+	}
+
+	paymentData := map[string]interface{}{
+		"payment_id":     payment.ID,
+		"invoice_id":     invoice.ID,
+		"invoice_number": invoice.InvoiceNumber,
+		"subscriber_id":  invoice.SubscriberID,
+		"reseller_id":    invoice.ResellerID,
+		"amount":         req.Amount,
+		"method":         req.Method,
+		"reference":      req.Reference,
+		"amount_paid":    newAmountPaid,
+		"total":          invoice.Total,
+	}
+	webhook.Emit(models.WebhookEventPaymentReceived, paymentData)
+	if newStatus == models.PaymentStatusCompleted {
+		webhook.Emit(models.WebhookEventInvoicePaid, paymentData)
+	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -490,4 +515,10 @@ func (h *InvoiceHandler) autoRenewSubscriber(subscriberID uint) {
 	}
 
 	log.Printf("AutoRenew: Subscriber %s renewed until %s (invoice paid)", sub.Username, newExpiry.Format("2006-01-02"))
+
+	sub.ExpiryDate = newExpiry
+	sub.Status = models.SubscriberStatusActive
+	webhook.EmitSubscriber(models.WebhookEventSubscriberRenewed, &sub, map[string]interface{}{
+		"renewed_by": "invoice",
+	})
 }
@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/iphistory"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type IPHistoryHandler struct{}
+
+func NewIPHistoryHandler() *IPHistoryHandler {
+	return &IPHistoryHandler{}
+}
+
+// IPAssignmentRecord is an IP assignment with the subscriber and NAS it belongs to
+type IPAssignmentRecord struct {
+	models.IPAssignment `gorm:"embedded"`
+	FullName            string `gorm:"column:full_name" json:"full_name"`
+	Phone               string `gorm:"column:phone" json:"phone"`
+	Address             string `gorm:"column:address" json:"address"`
+	NasName             string `gorm:"column:nas_name" json:"nas_name"`
+}
+
+// IPHistoryCriteria selects assignments by address or holder, at a moment or over a period
+type IPHistoryCriteria struct {
+	IPAddress    string `json:"ip_address,omitempty"` // Subscriber address or CGNAT public address
+	Port         int    `json:"port,omitempty"`       // Public port, narrows a CGNAT address to one subscriber
+	Username     string `json:"username,omitempty"`
+	SubscriberID uint   `json:"subscriber_id,omitempty"`
+	MACAddress   string `json:"mac_address,omitempty"`
+	At           string `json:"at,omitempty"`   // Assignments held at this moment
+	From         string `json:"from,omitempty"` // Assignments overlapping from..to
+	To           string `json:"to,omitempty"`
+}
+
+// CreateIPHistoryExportRequest is the body of POST /api/ip-history/exports
+type CreateIPHistoryExportRequest struct {
+	CaseReference string `json:"case_reference"`
+	IPHistoryCriteria
+}
+
+// IPHistorySigningKey is the public half of the key that signs exports
+type IPHistorySigningKey struct {
+	Algorithm   string `json:"algorithm"`
+	PublicKey   string `json:"public_key"`  // Base64 raw public key
+	Fingerprint string `json:"fingerprint"` // Hex SHA-256 of the public key
+}
+
+// parseIPHistoryTime accepts RFC 3339 or "2006-01-02 15:04:05" / "2006-01-02" in server time.
+// ip_assignments stores server local time without a zone.
+func parseIPHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Local(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD HH:MM:SS", value)
+}
+
+// ipHistoryQuery builds the joined assignment query for the criteria, limited to the
+// subscribers of scoped resellers
+func ipHistoryQuery(user *models.User, f IPHistoryCriteria) (*gorm.DB, error) {
+	query := database.DB.Table("ip_assignments").
+		Select("ip_assignments.*, s.full_name, s.phone, s.address, n.name AS nas_name").
+		Joins("LEFT JOIN subscribers s ON s.id = ip_assignments.subscriber_id").
+		Joins("LEFT JOIN nas_devices n ON n.id = ip_assignments.nas_id")
+
+	if ip := strings.TrimSpace(f.IPAddress); ip != "" {
+		// A public CGNAT address matches every session translated to it, or with a port
+		// only the session whose port block contains it
+		if f.Port > 0 {
+			query = query.Where(`(ip_assignments.ip_address = ? AND ip_assignments.public_ip IS NULL)
+				OR (ip_assignments.public_ip = ? AND ip_assignments.public_port_start <= ? AND ip_assignments.public_port_end >= ?)`,
+				ip, ip, f.Port, f.Port)
+		} else {
+			query = query.Where("ip_assignments.ip_address = ? OR ip_assignments.public_ip = ?", ip, ip)
+		}
+	}
+	if f.Username != "" {
+		query = query.Where("ip_assignments.username = ?", strings.TrimSpace(f.Username))
+	}
+	if f.SubscriberID > 0 {
+		query = query.Where("ip_assignments.subscriber_id = ?", f.SubscriberID)
+	}
+	if f.MACAddress != "" {
+		query = query.Where("UPPER(ip_assignments.mac_address) = UPPER(?)", strings.TrimSpace(f.MACAddress))
+	}
+
+	if f.At != "" {
+		at, err := parseIPHistoryTime(f.At)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("ip_assignments.started_at <= ? AND (ip_assignments.stopped_at IS NULL OR ip_assignments.stopped_at >= ?)", at, at)
+	}
+	if f.From != "" {
+		from, err := parseIPHistoryTime(f.From)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(ip_assignments.stopped_at IS NULL OR ip_assignments.stopped_at >= ?)", from)
+	}
+	if f.To != "" {
+		to, err := parseIPHistoryTime(f.To)
+		if err != nil {
+			return nil, err
+		}
+		if len(f.To) == len("2006-01-02") {
+			to = to.Add(24*time.Hour - time.Second)
+		}
+		query = query.Where("ip_assignments.started_at <= ?", to)
+	}
+
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		query = query.Where("s.reseller_id = ?", *user.ResellerID)
+	}
+	if ids := middleware.GetUserPermissions(user).ResellerIDs; len(ids) > 0 {
+		query = query.Where("s.reseller_id IN ?", ids)
+	}
+	return query, nil
+}
+
+func criteriaFromQuery(c *fiber.Ctx) IPHistoryCriteria {
+	return IPHistoryCriteria{
+		IPAddress:    c.Query("ip"),
+		Port:         c.QueryInt("port", 0),
+		Username:     c.Query("username"),
+		SubscriberID: uint(c.QueryInt("subscriber_id", 0)),
+		MACAddress:   c.Query("mac"),
+		At:           c.Query("at"),
+		From:         c.Query("from"),
+		To:           c.Query("to"),
+	}
+}
+
+// Lookup returns who held an IP address (or CGNAT public IP and port) at a moment
+func (h *IPHistoryHandler) Lookup(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	ip := strings.TrimSpace(c.Query("ip"))
+	port := c.QueryInt("port", 0)
+	at := c.Query("at")
+	if ip == "" || at == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "ip and at are required",
+		})
+	}
+
+	query, err := ipHistoryQuery(user, IPHistoryCriteria{IPAddress: ip, Port: port, At: at})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	var records []IPAssignmentRecord
+	if err := query.Order("ip_assignments.started_at DESC").Limit(100).Scan(&records).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to look up IP history",
+		})
+	}
+
+	// Lookups are reads, which the audit middleware skips; log them for the regulator trail
+	target := ip
+	if port > 0 {
+		target = fmt.Sprintf("%s:%d", ip, port)
+	}
+	LogAction(c, models.AuditActionIPLookup, "ip_history", 0,
+		fmt.Sprintf("Looked up IP %s at %s (%d matches)", target, at, len(records)))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    records,
+	})
+}
+
+// List returns IP assignments filtered by address, subscriber, MAC and period
+func (h *IPHistoryHandler) List(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	criteria := criteriaFromQuery(c)
+	query, err := ipHistoryQuery(user, criteria)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var records []IPAssignmentRecord
+	query.Order("ip_assignments.started_at DESC").Offset((page - 1) * limit).Limit(limit).Scan(&records)
+
+	if criteria.IPAddress != "" || criteria.Username != "" || criteria.SubscriberID > 0 || criteria.MACAddress != "" {
+		filter, _ := json.Marshal(criteria)
+		LogAction(c, models.AuditActionIPLookup, "ip_history", 0, "Searched IP history "+string(filter))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    records,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// ipHistoryCSV renders the records of an export
+func ipHistoryCSV(caseReference string, records []IPAssignmentRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"case_reference", "ip_address", "started_at", "stopped_at", "username", "subscriber_id",
+		"full_name", "phone", "address", "mac_address", "nas_name", "nas_ip_address", "acct_session_id", "terminate_cause",
+		"public_ip", "public_ports"})
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	for _, r := range records {
+		subscriberID := ""
+		if r.SubscriberID != nil {
+			subscriberID = strconv.FormatUint(uint64(*r.SubscriberID), 10)
+		}
+		ports := ""
+		if r.PublicPortStart != nil && r.PublicPortEnd != nil {
+			ports = fmt.Sprintf("%d-%d", *r.PublicPortStart, *r.PublicPortEnd)
+		}
+		w.Write([]string{caseReference, r.IPAddress, formatTime(&r.StartedAt), formatTime(r.StoppedAt), r.Username, subscriberID,
+			r.FullName, r.Phone, r.Address, r.MACAddress, r.NasName, r.NasIPAddress, r.AcctSessionID, r.TerminateCause,
+			r.PublicIP, ports})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// CreateExport produces a signed CSV of the matching assignments for a law-enforcement request
+func (h *IPHistoryHandler) CreateExport(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+
+	var req CreateIPHistoryExportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	req.CaseReference = strings.TrimSpace(req.CaseReference)
+	if req.CaseReference == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "case_reference is required",
+		})
+	}
+	if req.IPAddress == "" && req.Username == "" && req.SubscriberID == 0 && req.MACAddress == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "ip_address, username, subscriber_id or mac_address is required",
+		})
+	}
+	if req.At == "" && req.From == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "at or from is required",
+		})
+	}
+
+	query, err := ipHistoryQuery(user, req.IPHistoryCriteria)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	var records []IPAssignmentRecord
+	if err := query.Order("ip_assignments.started_at").Scan(&records).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to query IP history",
+		})
+	}
+
+	content, err := ipHistoryCSV(req.CaseReference, records)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to build export",
+		})
+	}
+	signature, err := iphistory.Sign(content)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to sign export: " + err.Error(),
+		})
+	}
+
+	criteria, _ := json.Marshal(req.IPHistoryCriteria)
+	export := models.IPHistoryExport{
+		CaseReference:   req.CaseReference,
+		RequestedBy:     user.ID,
+		RequestedByName: user.Username,
+		Query:           string(criteria),
+		RecordCount:     len(records),
+		FileName:        "ip-history-" + time.Now().Format("20060102-150405") + ".csv",
+		Content:         string(content),
+		SHA256:          iphistory.Digest(content),
+		Signature:       signature,
+	}
+	if err := database.DB.Create(&export).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save export",
+		})
+	}
+
+	c.Locals("audit_entity_id", export.ID)
+	c.Locals("audit_entity_name", req.CaseReference)
+	c.Locals("audit_description", fmt.Sprintf("Exported %d IP assignments for case %s %s", len(records), req.CaseReference, criteria))
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Export created",
+		"data":    export,
+	})
+}
+
+// ListExports returns the exports made so far, newest first
+func (h *IPHistoryHandler) ListExports(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := database.DB.Model(&models.IPHistoryExport{}).Omit("content")
+	if ref := c.Query("case_reference"); ref != "" {
+		query = query.Where("case_reference ILIKE ?", "%"+ref+"%")
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var exports []models.IPHistoryExport
+	query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&exports)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    exports,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// DownloadExport returns the exact file of an export, with its hash and signature in headers
+func (h *IPHistoryHandler) DownloadExport(c *fiber.Ctx) error {
+	id, _ := strconv.Atoi(c.Params("id"))
+
+	var export models.IPHistoryExport
+	if err := database.DB.First(&export, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "Export not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load export",
+		})
+	}
+
+	LogAction(c, models.AuditActionIPLookup, "ip_history", export.ID,
+		fmt.Sprintf("Downloaded IP history export for case %s", export.CaseReference))
+
+	c.Set("Content-Type", "text/csv")
+	c.Set("Content-Disposition", "attachment; filename="+export.FileName)
+	c.Set("X-Export-SHA256", export.SHA256)
+	c.Set("X-Export-Signature", export.Signature)
+	return c.SendString(export.Content)
+}
+
+// SigningKey returns the Ed25519 public key that verifies export signatures
+func (h *IPHistoryHandler) SigningKey(c *fiber.Ctx) error {
+	publicKey, fingerprint, err := iphistory.PublicKey()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to load signing key: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    IPHistorySigningKey{Algorithm: "Ed25519", PublicKey: publicKey, Fingerprint: fingerprint},
+	})
+}
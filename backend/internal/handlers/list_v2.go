@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/listquery"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// ListV2Handler serves the /api/v2 list endpoints. Each one declares its fields in a
+// listquery.Resource and supplies the base query with the caller's access scope; paging,
+// filters, sorting and streaming are handled by listquery.
+type ListV2Handler struct{}
+
+func NewListV2Handler() *ListV2Handler {
+	return &ListV2Handler{}
+}
+
+var subscriberListResource = &listquery.Resource{
+	Name: "subscribers",
+	Key:  "subscribers.id",
+	Fields: []listquery.Field{
+		{Name: "id", Column: "subscribers.id", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "username", Column: "subscribers.username", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "full_name", Column: "COALESCE(subscribers.full_name, '')", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "email", Column: "subscribers.email", Type: listquery.String, Filter: true},
+		{Name: "phone", Column: "subscribers.phone", Type: listquery.String, Filter: true},
+		{Name: "address", Column: "subscribers.address", Type: listquery.String, Filter: true},
+		{Name: "status", Column: "subscribers.status", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "service_id", Column: "subscribers.service_id", Type: listquery.Int, Filter: true},
+		{Name: "service_name", Column: "COALESCE(services.name, '')", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "reseller_id", Column: "subscribers.reseller_id", Type: listquery.Int, Filter: true},
+		{Name: "nas_id", Column: "subscribers.nas_id", Type: listquery.Int, Filter: true},
+		{Name: "connection_type", Column: "subscribers.connection_type", Type: listquery.String, Filter: true},
+		{Name: "expiry_date", Column: "subscribers.expiry_date", Type: listquery.Time, Filter: true, Sort: true},
+		{Name: "price", Column: "subscribers.price", Type: listquery.Float, Filter: true, Sort: true},
+		{Name: "auto_renew", Column: "subscribers.auto_renew", Type: listquery.Bool, Filter: true},
+		{Name: "is_online", Column: "subscribers.is_online", Type: listquery.Bool, Filter: true, Sort: true},
+		{Name: "last_seen", Column: "subscribers.last_seen", Type: listquery.Time, Filter: true},
+		{Name: "mac_address", Column: "subscribers.mac_address", Type: listquery.String, Filter: true},
+		{Name: "ip_address", Column: "subscribers.ip_address", Type: listquery.String, Filter: true},
+		{Name: "static_ip", Column: "subscribers.static_ip", Type: listquery.String, Filter: true},
+		{Name: "fup_level", Column: "subscribers.fup_level", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "monthly_fup_level", Column: "subscribers.monthly_fup_level", Type: listquery.Int, Filter: true},
+		{Name: "daily_usage", Column: "(subscribers.daily_download_used + subscribers.daily_upload_used)", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "monthly_usage", Column: "(subscribers.monthly_download_used + subscribers.monthly_upload_used)", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "created_at", Column: "subscribers.created_at", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Search: []string{"subscribers.username", "subscribers.full_name", "subscribers.phone", "subscribers.email",
+		"subscribers.address", "subscribers.mac_address", "subscribers.ip_address"},
+	DefaultSort:   "-created_at",
+	DefaultFields: []string{"id", "username", "full_name", "status", "service_name", "expiry_date", "is_online"},
+	MaxLimit:      500,
+}
+
+// Subscribers lists subscribers
+func (h *ListV2Handler) Subscribers(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Unauthorized"})
+	}
+
+	base := database.DB.Model(&models.Subscriber{}).
+		Joins("LEFT JOIN services ON services.id = subscribers.service_id")
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil && !checkUserPermission(user, "subscribers.view_all") {
+		base = base.Where("subscribers.reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?)", *user.ResellerID, *user.ResellerID)
+	}
+	base = middleware.ScopeSubscriberQuery(user, base, "subscribers")
+
+	return listquery.Respond(c, subscriberListResource, base)
+}
+
+var sessionListResource = &listquery.Resource{
+	Name: "sessions",
+	Key:  "radacct.radacctid",
+	Fields: []listquery.Field{
+		{Name: "id", Column: "radacct.radacctid", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "username", Column: "radacct.username", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "full_name", Column: "COALESCE(subscribers.full_name, '')", Type: listquery.String, Filter: true},
+		{Name: "service_name", Column: "COALESCE(services.name, '')", Type: listquery.String, Filter: true},
+		{Name: "nas_ip_address", Column: "radacct.nasipaddress", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "nas_name", Column: "COALESCE(nas_devices.name, radacct.nasipaddress)", Type: listquery.String, Filter: true},
+		{Name: "framed_ip_address", Column: "radacct.framedipaddress", Type: listquery.String, Filter: true},
+		{Name: "calling_station_id", Column: "radacct.callingstationid", Type: listquery.String, Filter: true},
+		{Name: "called_station_id", Column: "radacct.calledstationid", Type: listquery.String, Filter: true},
+		{Name: "acct_session_id", Column: "radacct.acctsessionid", Type: listquery.String, Filter: true},
+		{Name: "acct_start_time", Column: "COALESCE(radacct.acctstarttime, 'epoch'::timestamptz)", Type: listquery.Time, Filter: true, Sort: true},
+		{Name: "acct_stop_time", Column: "radacct.acctstoptime", Type: listquery.Time, Filter: true},
+		{Name: "acct_session_time", Column: "COALESCE(radacct.acctsessiontime, 0)", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "acct_input_octets", Column: "COALESCE(radacct.acctinputoctets, 0)", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "acct_output_octets", Column: "COALESCE(radacct.acctoutputoctets, 0)", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "terminate_cause", Column: "radacct.acctterminatecause", Type: listquery.String, Filter: true},
+		{Name: "connection_type", Column: "COALESCE(subscribers.connection_type, 'pppoe')", Type: listquery.String, Filter: true},
+	},
+	Search:        []string{"radacct.username", "radacct.framedipaddress", "radacct.callingstationid"},
+	DefaultSort:   "-acct_start_time",
+	DefaultFields: []string{"id", "username", "nas_ip_address", "framed_ip_address", "calling_station_id", "acct_start_time", "acct_stop_time", "acct_input_octets", "acct_output_octets"},
+	MaxLimit:      1000,
+}
+
+// Sessions lists RADIUS accounting sessions, online and closed.
+// filter=acct_stop_time:null:true selects the online ones.
+func (h *ListV2Handler) Sessions(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Unauthorized"})
+	}
+
+	base := database.DB.Table("radacct").
+		Joins("LEFT JOIN subscribers ON radacct.username = subscribers.username AND subscribers.deleted_at IS NULL").
+		Joins("LEFT JOIN services ON subscribers.service_id = services.id").
+		Joins("LEFT JOIN nas_devices ON radacct.nasipaddress = nas_devices.ip_address")
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		base = base.Where("radacct.username IN (SELECT username FROM subscribers WHERE reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?))", *user.ResellerID, *user.ResellerID)
+	}
+	if middleware.GetUserPermissions(user).Scoped() {
+		scoped := middleware.ScopeSubscriberQuery(user, database.DB.Model(&models.Subscriber{}).Select("username"), "")
+		base = base.Where("radacct.username IN (?)", scoped)
+	}
+
+	return listquery.Respond(c, sessionListResource, base)
+}
+
+var auditListResource = &listquery.Resource{
+	Name: "audit",
+	Key:  "audit_logs.id",
+	Fields: []listquery.Field{
+		{Name: "id", Column: "audit_logs.id", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "user_id", Column: "audit_logs.user_id", Type: listquery.Int, Filter: true},
+		{Name: "username", Column: "audit_logs.username", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "user_type", Column: "audit_logs.user_type", Type: listquery.Int, Filter: true},
+		{Name: "action", Column: "audit_logs.action", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "entity_type", Column: "audit_logs.entity_type", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "entity_id", Column: "audit_logs.entity_id", Type: listquery.Int, Filter: true},
+		{Name: "entity_name", Column: "audit_logs.entity_name", Type: listquery.String, Filter: true},
+		{Name: "old_value", Column: "audit_logs.old_value", Type: listquery.String},
+		{Name: "new_value", Column: "audit_logs.new_value", Type: listquery.String},
+		{Name: "description", Column: "audit_logs.description", Type: listquery.String, Filter: true},
+		{Name: "ip_address", Column: "audit_logs.ip_address", Type: listquery.String, Filter: true},
+		{Name: "user_agent", Column: "audit_logs.user_agent", Type: listquery.String},
+		{Name: "api_key_id", Column: "audit_logs.api_key_id", Type: listquery.Int, Filter: true},
+		{Name: "created_at", Column: "audit_logs.created_at", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Search:        []string{"audit_logs.username", "audit_logs.entity_name", "audit_logs.description"},
+	DefaultSort:   "-created_at",
+	DefaultFields: []string{"id", "username", "action", "entity_type", "entity_id", "entity_name", "description", "ip_address", "created_at"},
+	MaxLimit:      500,
+}
+
+// Audit lists audit log entries
+func (h *ListV2Handler) Audit(c *fiber.Ctx) error {
+	return listquery.Respond(c, auditListResource, database.DB.Model(&models.AuditLog{}))
+}
+
+var transactionListResource = &listquery.Resource{
+	Name: "transactions",
+	Key:  "transactions.id",
+	Fields: []listquery.Field{
+		{Name: "id", Column: "transactions.id", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "type", Column: "transactions.type", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "amount", Column: "transactions.amount", Type: listquery.Float, Filter: true, Sort: true},
+		{Name: "balance_before", Column: "transactions.balance_before", Type: listquery.Float},
+		{Name: "balance_after", Column: "transactions.balance_after", Type: listquery.Float},
+		{Name: "description", Column: "transactions.description", Type: listquery.String, Filter: true},
+		{Name: "service_name", Column: "transactions.service_name", Type: listquery.String, Filter: true},
+		{Name: "old_service_name", Column: "transactions.old_service_name", Type: listquery.String, Filter: true},
+		{Name: "new_service_name", Column: "transactions.new_service_name", Type: listquery.String, Filter: true},
+		{Name: "reseller_id", Column: "transactions.reseller_id", Type: listquery.Int, Filter: true},
+		{Name: "subscriber_id", Column: "transactions.subscriber_id", Type: listquery.Int, Filter: true},
+		{Name: "subscriber_username", Column: "COALESCE(subscribers.username, '')", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "target_reseller_id", Column: "transactions.target_reseller_id", Type: listquery.Int, Filter: true},
+		{Name: "created_by", Column: "transactions.created_by", Type: listquery.Int, Filter: true},
+		{Name: "ip_address", Column: "transactions.ip_address", Type: listquery.String, Filter: true},
+		{Name: "created_at", Column: "transactions.created_at", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Search:        []string{"transactions.description", "subscribers.username"},
+	DefaultSort:   "-created_at",
+	DefaultFields: []string{"id", "type", "amount", "balance_before", "balance_after", "description", "reseller_id", "subscriber_id", "subscriber_username", "created_at"},
+	MaxLimit:      1000,
+}
+
+// Transactions lists balance transactions. Resellers see their own.
+func (h *ListV2Handler) Transactions(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Unauthorized"})
+	}
+
+	base := database.DB.Model(&models.Transaction{}).
+		Joins("LEFT JOIN subscribers ON subscribers.id = transactions.subscriber_id")
+	base = scopeResellerRows(user, base, "transactions")
+	return listquery.Respond(c, transactionListResource, base)
+}
+
+var invoiceListResource = &listquery.Resource{
+	Name: "invoices",
+	Key:  "invoices.id",
+	Fields: []listquery.Field{
+		{Name: "id", Column: "invoices.id", Type: listquery.Int, Filter: true, Sort: true},
+		{Name: "invoice_number", Column: "invoices.invoice_number", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "subscriber_id", Column: "invoices.subscriber_id", Type: listquery.Int, Filter: true},
+		{Name: "subscriber_username", Column: "COALESCE(subscribers.username, '')", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "reseller_id", Column: "invoices.reseller_id", Type: listquery.Int, Filter: true},
+		{Name: "sub_total", Column: "invoices.sub_total", Type: listquery.Float},
+		{Name: "discount", Column: "invoices.discount", Type: listquery.Float},
+		{Name: "tax", Column: "invoices.tax", Type: listquery.Float},
+		{Name: "total", Column: "invoices.total", Type: listquery.Float, Filter: true, Sort: true},
+		{Name: "amount_paid", Column: "invoices.amount_paid", Type: listquery.Float, Filter: true},
+		{Name: "status", Column: "invoices.status", Type: listquery.String, Filter: true, Sort: true},
+		{Name: "due_date", Column: "invoices.due_date", Type: listquery.Time, Filter: true, Sort: true},
+		{Name: "paid_date", Column: "invoices.paid_date", Type: listquery.Time, Filter: true},
+		{Name: "billing_period_start", Column: "invoices.billing_period_start", Type: listquery.Time, Filter: true},
+		{Name: "billing_period_end", Column: "invoices.billing_period_end", Type: listquery.Time, Filter: true},
+		{Name: "auto_generated", Column: "invoices.auto_generated", Type: listquery.Bool, Filter: true},
+		{Name: "notes", Column: "invoices.notes", Type: listquery.String},
+		{Name: "created_at", Column: "invoices.created_at", Type: listquery.Time, Filter: true, Sort: true},
+	},
+	Search:        []string{"invoices.invoice_number", "subscribers.username"},
+	DefaultSort:   "-created_at",
+	DefaultFields: []string{"id", "invoice_number", "subscriber_id", "subscriber_username", "total", "amount_paid", "status", "due_date", "created_at"},
+	MaxLimit:      1000,
+}
+
+// Invoices lists invoices. Resellers see their own.
+func (h *ListV2Handler) Invoices(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Unauthorized"})
+	}
+
+	base := database.DB.Model(&models.Invoice{}).
+		Joins("LEFT JOIN subscribers ON subscribers.id = invoices.subscriber_id")
+	base = scopeResellerRows(user, base, "invoices")
+	return listquery.Respond(c, invoiceListResource, base)
+}
+
+// scopeResellerRows limits a table with a reseller_id column to the reseller's own rows,
+// and staff with reseller scopes to those resellers
+func scopeResellerRows(user *models.User, query *gorm.DB, table string) *gorm.DB {
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		query = query.Where(table+".reseller_id = ?", *user.ResellerID)
+	}
+	if ids := middleware.GetUserPermissions(user).ResellerIDs; len(ids) > 0 {
+		query = query.Where(table+".reseller_id IN ?", ids)
+	}
+	return query
+}
@@ -47,6 +47,13 @@ func (h *NasHandler) List(c *fiber.Ctx) error {
 		}
 	}
 
+	// Staff limited to specific NASes
+	if user != nil {
+		if nasIDs := middleware.GetUserPermissions(user).NasIDs; len(nasIDs) > 0 {
+			query = query.Where("id IN ?", nasIDs)
+		}
+	}
+
 	if err := query.Find(&nasList).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -60,9 +67,9 @@ func (h *NasHandler) List(c *fiber.Ctx) error {
 		nasList[i].HasAPIPassword = nasList[i].APIPassword != ""
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    nasList,
+	return c.JSON(NasListResponse{
+		Success: true,
+		Data:    nasList,
 	})
 }
 
@@ -77,7 +84,7 @@ func (h *NasHandler) Get(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -94,10 +101,10 @@ func (h *NasHandler) Get(c *fiber.Ctx) error {
 	nas.HasSecret = nas.Secret != ""
 	nas.HasAPIPassword = nas.APIPassword != ""
 
-	return c.JSON(fiber.Map{
-		"success":        true,
-		"data":           nas,
-		"active_sessions": sessionCount,
+	return c.JSON(NasDetailResponse{
+		Success:        true,
+		Data:           nas,
+		ActiveSessions: sessionCount,
 	})
 }
 
@@ -118,6 +125,19 @@ type CreateNasRequest struct {
 	APISSLPort  int    `json:"api_ssl_port"`
 	UseSSL      bool   `json:"use_ssl"`
 	FTPPort     int    `json:"ftp_port"`
+
+	HotspotEnabled bool   `json:"hotspot_enabled"`
+	IPoEAuthMode   string `json:"ipoe_auth_mode"`
+
+	MonitorEnabled   *bool  `json:"monitor_enabled"`
+	SNMPVersion      string `json:"snmp_version"`
+	SNMPPort         int    `json:"snmp_port"`
+	SNMPCommunity    string `json:"snmp_community"`
+	SNMPUsername     string `json:"snmp_username"`
+	SNMPAuthProtocol string `json:"snmp_auth_protocol"`
+	SNMPAuthPassword string `json:"snmp_auth_password"`
+	SNMPPrivProtocol string `json:"snmp_priv_protocol"`
+	SNMPPrivPassword string `json:"snmp_priv_password"`
 }
 
 // Create creates a new NAS device
@@ -164,6 +184,19 @@ func (h *NasHandler) Create(c *fiber.Ctx) error {
 		UseSSL:      req.UseSSL,
 		FTPPort:     req.FTPPort,
 		IsActive:    true,
+
+		HotspotEnabled: req.HotspotEnabled,
+		IPoEAuthMode:   req.IPoEAuthMode,
+
+		MonitorEnabled:   req.MonitorEnabled == nil || *req.MonitorEnabled,
+		SNMPVersion:      req.SNMPVersion,
+		SNMPPort:         req.SNMPPort,
+		SNMPCommunity:    req.SNMPCommunity,
+		SNMPUsername:     req.SNMPUsername,
+		SNMPAuthProtocol: req.SNMPAuthProtocol,
+		SNMPAuthPassword: req.SNMPAuthPassword,
+		SNMPPrivProtocol: req.SNMPPrivProtocol,
+		SNMPPrivPassword: req.SNMPPrivPassword,
 	}
 
 	// Set defaults
@@ -188,6 +221,9 @@ func (h *NasHandler) Create(c *fiber.Ctx) error {
 	if nas.FTPPort == 0 {
 		nas.FTPPort = 21
 	}
+	if nas.SNMPPort == 0 {
+		nas.SNMPPort = 161
+	}
 	if nas.ShortName == "" {
 		nas.ShortName = req.Name
 	}
@@ -236,12 +272,12 @@ func (h *NasHandler) Create(c *fiber.Ctx) error {
 		ipPoolsImported = -1 // -1 indicates async import started
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"success":          true,
-		"message":          "NAS created successfully",
-		"data":             nas,
-		"ip_pools_import":  ipPoolsImported != 0,
-		"ip_pools_message": getIPPoolImportMessage(ipPoolsImported, nas.APIUsername != "" && nas.APIPassword != ""),
+	return c.Status(fiber.StatusCreated).JSON(NasResponse{
+		Success:        true,
+		Message:        "NAS created successfully",
+		Data:           nas,
+		IPPoolsImport:  ipPoolsImported != 0,
+		IPPoolsMessage: getIPPoolImportMessage(ipPoolsImported, nas.APIUsername != "" && nas.APIPassword != ""),
 	})
 }
 
@@ -256,7 +292,7 @@ func (h *NasHandler) Update(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -289,6 +325,19 @@ func (h *NasHandler) Update(c *fiber.Ctx) error {
 		"use_ssl":      "use_ssl",
 		"ftp_port":     "ftp_port",
 		"is_active":    "is_active",
+
+		"hotspot_enabled": "hotspot_enabled",
+		"ipoe_auth_mode":  "ipoe_auth_mode",
+
+		"monitor_enabled":    "monitor_enabled",
+		"snmp_version":       "snmp_version",
+		"snmp_port":          "snmp_port",
+		"snmp_community":     "snmp_community",
+		"snmp_username":      "snmp_username",
+		"snmp_auth_protocol": "snmp_auth_protocol",
+		"snmp_auth_password": "snmp_auth_password",
+		"snmp_priv_protocol": "snmp_priv_protocol",
+		"snmp_priv_password": "snmp_priv_password",
 	}
 
 	updates := make(map[string]interface{})
@@ -361,12 +410,12 @@ func (h *NasHandler) Update(c *fiber.Ctx) error {
 		ipPoolsImported = -1 // -1 indicates async import started
 	}
 
-	return c.JSON(fiber.Map{
-		"success":          true,
-		"message":          "NAS updated successfully",
-		"data":             nas,
-		"ip_pools_import":  ipPoolsImported != 0,
-		"ip_pools_message": getIPPoolImportMessage(ipPoolsImported, (apiUsernameUpdated || apiPasswordUpdated) && nas.APIUsername != "" && nas.APIPassword != ""),
+	return c.JSON(NasResponse{
+		Success:        true,
+		Message:        "NAS updated successfully",
+		Data:           nas,
+		IPPoolsImport:  ipPoolsImported != 0,
+		IPPoolsMessage: getIPPoolImportMessage(ipPoolsImported, (apiUsernameUpdated || apiPasswordUpdated) && nas.APIUsername != "" && nas.APIPassword != ""),
 	})
 }
 
@@ -381,7 +430,7 @@ func (h *NasHandler) Delete(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -437,7 +486,7 @@ func (h *NasHandler) Sync(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -466,7 +515,7 @@ func (h *NasHandler) TestConnection(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -530,6 +579,64 @@ func (h *NasHandler) TestConnection(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// Provision pushes RADIUS client, CoA and PPP AAA configuration to a MikroTik router
+func (h *NasHandler) Provision(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid NAS ID",
+		})
+	}
+
+	var nas models.Nas
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "NAS not found",
+		})
+	}
+
+	var opts services.NasProvisionOptions
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&opts); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	result, err := services.ProvisionNas(&nas, opts)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	// Create audit log
+	user := middleware.GetCurrentUser(c)
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionUpdate,
+		EntityType:  "nas",
+		EntityID:    nas.ID,
+		EntityName:  nas.Name,
+		Description: fmt.Sprintf("Provisioned NAS (RADIUS server %s): %s", result.ServerAddress, result.Status),
+		IPAddress:   c.IP(),
+	}
+	database.DB.Create(&auditLog)
+
+	return c.JSON(fiber.Map{
+		"success": result.Status == "success",
+		"message": nas.ProvisionMessage,
+		"data":    result,
+	})
+}
+
 // GetIPPools fetches available IP pools from a NAS device
 func (h *NasHandler) GetIPPools(c *fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
@@ -541,7 +648,7 @@ func (h *NasHandler) GetIPPools(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -581,7 +688,7 @@ func (h *NasHandler) UpdateSubscriberPools(c *fiber.Ctx) error {
 	}
 
 	var nas models.Nas
-	if err := database.DB.First(&nas, id).Error; err != nil {
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "NAS not found",
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/mikrotik"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+type NasConfigHandler struct{}
+
+func NewNasConfigHandler() *NasConfigHandler {
+	return &NasConfigHandler{}
+}
+
+// ListVersions returns the stored config versions of a NAS (newest first, without content)
+func (h *NasConfigHandler) ListVersions(c *fiber.Ctx) error {
+	nas, ferr := h.getNas(c)
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	var versions []models.NasConfigVersion
+	if err := database.DB.Omit("content").Where("nas_id = ?", nas.ID).
+		Order("version DESC").Find(&versions).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch config versions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    versions,
+	})
+}
+
+// GetVersion returns one config version with sensitive values redacted
+func (h *NasConfigHandler) GetVersion(c *fiber.Ctx) error {
+	nas, ferr := h.getNas(c)
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	version, ferr := h.getVersion(nas.ID, c.Params("version"))
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    version,
+		"content": mikrotik.RedactExport(version.Content),
+	})
+}
+
+// Snapshot exports the running config now and stores it if it changed
+func (h *NasConfigHandler) Snapshot(c *fiber.Ctx) error {
+	nas, ferr := h.getNas(c)
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	user := middleware.GetCurrentUser(c)
+	version, created, err := services.SnapshotNasConfig(nas, "manual", user.Username)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	message := fmt.Sprintf("Stored config version %d", version.Version)
+	if !created {
+		message = fmt.Sprintf("No changes since version %d", version.Version)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": message,
+		"created": created,
+		"data":    version,
+	})
+}
+
+// Diff returns a line-level diff between two versions.
+// Defaults: to = latest version, from = the version before it.
+func (h *NasConfigHandler) Diff(c *fiber.Ctx) error {
+	nas, ferr := h.getNas(c)
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	to := c.QueryInt("to", 0)
+	if to == 0 {
+		var latest models.NasConfigVersion
+		if err := database.DB.Omit("content").Where("nas_id = ?", nas.ID).Order("version DESC").First(&latest).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "No config versions stored for this NAS",
+			})
+		}
+		to = latest.Version
+	}
+	from := c.QueryInt("from", to-1)
+
+	toVersion, ferr := h.getVersion(nas.ID, strconv.Itoa(to))
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+	fromVersion, ferr := h.getVersion(nas.ID, strconv.Itoa(from))
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	ops := mikrotik.DiffLines(mikrotik.NormalizeExport(fromVersion.Content), mikrotik.NormalizeExport(toVersion.Content))
+
+	// Diff the raw text so secret changes still show up, then mask the values
+	added, removed := 0, 0
+	for i := range ops {
+		ops[i].Text = mikrotik.RedactExport(ops[i].Text)
+		switch ops[i].Op {
+		case "+":
+			added++
+		case "-":
+			removed++
+		}
+	}
+
+	changes := make([]mikrotik.DiffLine, 0, added+removed)
+	for _, op := range ops {
+		if op.Op != "=" {
+			changes = append(changes, op)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"from":    fromVersion.Version,
+			"to":      toVersion.Version,
+			"added":   added,
+			"removed": removed,
+			"changes": changes,
+			"unified": mikrotik.UnifiedDiff(ops, c.QueryInt("context", 3)),
+		},
+	})
+}
+
+// Restore pushes a stored version back to the router.
+// mode "import" (default) runs the script on the live config; "reset" wipes the router and boots with it.
+func (h *NasConfigHandler) Restore(c *fiber.Ctx) error {
+	nas, ferr := h.getNas(c)
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	version, ferr := h.getVersion(nas.ID, c.Params("version"))
+	if ferr != nil {
+		return h.fail(c, ferr)
+	}
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid request body",
+			})
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = "import"
+	}
+	if req.Mode != "import" && req.Mode != "reset" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Mode must be import or reset",
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if err := services.RestoreNasConfigVersion(nas, version, req.Mode == "reset", user.Username); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"message": "Restore failed: " + err.Error(),
+		})
+	}
+
+	// Create audit log
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionUpdate,
+		EntityType:  "nas",
+		EntityID:    nas.ID,
+		EntityName:  nas.Name,
+		Description: fmt.Sprintf("Restored config version %d (%s)", version.Version, req.Mode),
+		IPAddress:   c.IP(),
+	}
+	database.DB.Create(&auditLog)
+
+	message := fmt.Sprintf("Config version %d imported", version.Version)
+	if req.Mode == "reset" {
+		message = fmt.Sprintf("Router is resetting and will boot with config version %d", version.Version)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": message,
+	})
+}
+
+// getNas loads the NAS from the :id param, within the user's NAS scope
+func (h *NasConfigHandler) getNas(c *fiber.Ctx) (*models.Nas, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid NAS ID")
+	}
+
+	var nas models.Nas
+	if err := database.DB.First(&nas, id).Error; err != nil || !middleware.CanAccessNas(middleware.GetCurrentUser(c), nas.ID) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "NAS not found")
+	}
+	return &nas, nil
+}
+
+// getVersion loads a config version by its per-NAS version number
+func (h *NasConfigHandler) getVersion(nasID uint, param string) (*models.NasConfigVersion, *fiber.Error) {
+	number, err := strconv.Atoi(param)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid version")
+	}
+
+	var version models.NasConfigVersion
+	if err := database.DB.Where("nas_id = ? AND version = ?", nasID, number).First(&version).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, fmt.Sprintf("Config version %d not found", number))
+	}
+	return &version, nil
+}
+
+// fail writes a lookup error in the standard response format
+func (h *NasConfigHandler) fail(c *fiber.Ctx, e *fiber.Error) error {
+	return c.Status(e.Code).JSON(fiber.Map{
+		"success": false,
+		"message": e.Message,
+	})
+}
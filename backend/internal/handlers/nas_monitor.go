@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+type NasMonitorHandler struct {
+	monitor *services.NasMonitorService
+}
+
+func NewNasMonitorHandler(monitor *services.NasMonitorService) *NasMonitorHandler {
+	return &NasMonitorHandler{monitor: monitor}
+}
+
+// Overview returns every NAS with its most recent health sample
+func (h *NasMonitorHandler) Overview(c *fiber.Ctx) error {
+	query := database.DB.Order("name ASC")
+	// Staff limited to specific NASes
+	if nasIDs := middleware.GetUserPermissions(middleware.GetCurrentUser(c)).NasIDs; len(nasIDs) > 0 {
+		query = query.Where("id IN ?", nasIDs)
+	}
+
+	var nasList []models.Nas
+	if err := query.Find(&nasList).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch NAS devices",
+		})
+	}
+
+	// Latest sample per NAS in one query
+	var latest []models.NasHealthSample
+	database.DB.Raw(`
+		SELECT DISTINCT ON (nas_id) * FROM nas_health_samples
+		WHERE created_at > NOW() - INTERVAL '1 day'
+		ORDER BY nas_id, created_at DESC
+	`).Scan(&latest)
+
+	latestByNas := make(map[uint]models.NasHealthSample, len(latest))
+	for _, s := range latest {
+		latestByNas[s.NasID] = s
+	}
+
+	data := make([]fiber.Map, 0, len(nasList))
+	for _, nas := range nasList {
+		item := fiber.Map{
+			"nas_id":          nas.ID,
+			"name":            nas.Name,
+			"ip_address":      nas.IPAddress,
+			"type":            nas.Type,
+			"is_online":       nas.IsOnline,
+			"last_seen":       nas.LastSeen,
+			"version":         nas.Version,
+			"monitor_enabled": nas.MonitorEnabled,
+			"active_sessions": nas.ActiveSessions,
+		}
+		if s, ok := latestByNas[nas.ID]; ok {
+			item["cpu_load"] = s.CPULoad
+			item["memory_percent"] = s.MemoryPercent()
+			item["uptime_seconds"] = s.UptimeSeconds
+			item["rx_bps"] = s.RxBps
+			item["tx_bps"] = s.TxBps
+			item["sampled_at"] = s.CreatedAt
+		}
+		data = append(data, item)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// History returns the health time series for a NAS (default last 24 hours)
+func (h *NasMonitorHandler) History(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid NAS ID",
+		})
+	}
+	if !middleware.CanAccessNas(middleware.GetCurrentUser(c), uint(id)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "NAS not found",
+		})
+	}
+
+	hours := c.QueryInt("hours", 24)
+	if hours <= 0 || hours > 24*90 {
+		hours = 24
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	var samples []models.NasHealthSample
+	query := database.DB.Where("nas_id = ? AND created_at >= ?", id, since).Order("created_at ASC")
+	if c.Query("interfaces") != "true" {
+		// Per-interface counters are large - only include them on request
+		query = query.Omit("interfaces")
+	}
+	if err := query.Find(&samples).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch health history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    samples,
+		"hours":   hours,
+	})
+}
+
+// Events returns health events (reboot, overload, offline) for one NAS or all
+func (h *NasMonitorHandler) Events(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := database.DB.Order("created_at DESC").Limit(limit)
+	if idParam := c.Params("id"); idParam != "" {
+		id, err := strconv.Atoi(idParam)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid NAS ID",
+			})
+		}
+		if !middleware.CanAccessNas(middleware.GetCurrentUser(c), uint(id)) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "NAS not found",
+			})
+		}
+		query = query.Where("nas_id = ?", id)
+	} else if nasIDs := middleware.GetUserPermissions(middleware.GetCurrentUser(c)).NasIDs; len(nasIDs) > 0 {
+		query = query.Where("nas_id IN ?", nasIDs)
+	}
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("event_type = ?", eventType)
+	}
+
+	var events []models.NasHealthEvent
+	if err := query.Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch health events",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    events,
+	})
+}
+
+// PollNow polls a NAS immediately and returns the fresh sample
+func (h *NasMonitorHandler) PollNow(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid NAS ID",
+		})
+	}
+	if !middleware.CanAccessNas(middleware.GetCurrentUser(c), uint(id)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "NAS not found",
+		})
+	}
+
+	sample, err := h.monitor.PollNow(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"message": "Poll failed: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    sample,
+	})
+}
@@ -550,8 +550,8 @@ func (h *NotificationHandler) AdminSendToSubscribers(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No subscribers with phone numbers found"})
 	}
 
-	wa := h.manager.GetWhatsAppService()
-	sent := 0
+	// Queue messages; the outbox sends them at the WhatsApp rate limit
+	queued := 0
 	failed := 0
 	var errors []string
 
@@ -562,23 +562,31 @@ func (h *NotificationHandler) AdminSendToSubscribers(c *fiber.Ctx) error {
 		msg = strings.ReplaceAll(msg, "{{username}}", sub.Username)
 		msg = strings.ReplaceAll(msg, "{{full_name}}", sub.FullName)
 
-		if err := wa.SendMessage(sub.Phone, msg); err != nil {
+		subID := sub.ID
+		if _, err := services.QueueMessage(&models.OutboundMessage{
+			Channel:      "whatsapp",
+			Recipient:    sub.Phone,
+			Body:         msg,
+			Sender:       models.OutboundSenderAdmin,
+			Source:       "bulk",
+			SubscriberID: &subID,
+		}); err != nil {
 			failed++
 			if len(errors) < 5 {
 				errors = append(errors, fmt.Sprintf("%s: %v", sub.Username, err))
 			}
 		} else {
-			sent++
+			queued++
 		}
 	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
-		"sent":    sent,
+		"queued":  queued,
 		"failed":  failed,
 		"total":   len(targets),
 		"errors":  errors,
-		"message": fmt.Sprintf("Sent to %d subscribers", sent),
+		"message": fmt.Sprintf("Queued for %d subscribers", queued),
 	})
 }
 
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/openapi"
+)
+
+// OpenAPIHandler serves the generated API description
+type OpenAPIHandler struct {
+	once sync.Once
+	doc  *openapi.Document
+}
+
+// NewOpenAPIHandler creates a new OpenAPI handler
+func NewOpenAPIHandler() *OpenAPIHandler {
+	return &OpenAPIHandler{}
+}
+
+// APIEndpoints returns the endpoint table the document is built from
+func APIEndpoints() []openapi.Endpoint {
+	return apiEndpoints()
+}
+
+// BuildOpenAPIDocument assembles the document, with one tag per resource in table order
+func BuildOpenAPIDocument(version string) *openapi.Document {
+	endpoints := apiEndpoints()
+
+	var tags []openapi.Tag
+	seen := map[string]bool{}
+	for _, e := range endpoints {
+		if e.Tag != "" && !seen[e.Tag] {
+			seen[e.Tag] = true
+			tags = append(tags, openapi.Tag{Name: e.Tag})
+		}
+	}
+
+	if version == "" {
+		version = "1.0.0"
+	}
+	info := openapi.Info{
+		Title:       "ProISP API",
+		Description: "Staff endpoints accept a JWT from /api/auth/login or an API key in X-API-Key; API keys only reach endpoints that require a permission within their scopes. Customer portal endpoints take the customer JWT.",
+		Version:     version,
+	}
+	return openapi.Build(info, tags, endpoints)
+}
+
+// Spec returns the OpenAPI 3 document (public)
+func (h *OpenAPIHandler) Spec(c *fiber.Ctx) error {
+	h.once.Do(func() {
+		h.doc = BuildOpenAPIDocument(getCurrentVersion())
+	})
+	return c.JSON(h.doc)
+}
+
+// UndocumentedRoutes lists registered routes missing from the endpoint table
+func UndocumentedRoutes(app *fiber.App) []openapi.Route {
+	var routes []openapi.Route
+	for _, r := range app.GetRoutes(true) {
+		routes = append(routes, openapi.Route{Method: r.Method, Path: r.Path})
+	}
+	return openapi.Missing(routes, apiEndpoints())
+}
@@ -0,0 +1,590 @@
+package handlers
+
+import (
+	"github.com/proisp/backend/internal/listquery"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/openapi"
+)
+
+// apiEndpoints declares every route registered in cmd/api/main.go for the OpenAPI document.
+// Adding a route without an entry here fails `go run ./cmd/openapi` and logs a warning at startup.
+func apiEndpoints() []openapi.Endpoint {
+	var all []openapi.Endpoint
+	for _, group := range [][]openapi.Endpoint{
+		openapi.Tagged("System",
+			openapi.Get("/health", "Health check").Public(),
+			openapi.Get("/api/openapi.json", "This OpenAPI document").Public(),
+			openapi.Get("/.well-known/acme-challenge/:token", "Let's Encrypt HTTP-01 challenge response").Public().Produces("text/plain"),
+			openapi.Post("/api/system/restart-services", "Restart backend services"),
+			openapi.Get("/api/system/update/check", "Check for a new release"),
+			openapi.Get("/api/system/update/status", "Progress of a running update"),
+			openapi.Post("/api/system/update/start", "Download and install the latest release"),
+			openapi.Get("/api/system/remote-support/status", "Remote support status"),
+			openapi.Post("/api/system/remote-support/toggle", "Enable or disable remote support"),
+			openapi.Get("/api/system/tunnel/status", "Cloudflare tunnel status"),
+			openapi.Post("/api/system/tunnel/enable", "Enable the Cloudflare tunnel"),
+			openapi.Post("/api/system/tunnel/disable", "Disable the Cloudflare tunnel"),
+			openapi.Post("/api/system/tunnel/credentials", "Save Cloudflare credentials"),
+			openapi.Get("/api/system/network/current", "Current server network configuration"),
+			openapi.Get("/api/system/network/detect-dns", "Detect how DNS is managed on the server"),
+			openapi.Post("/api/system/network/test", "Validate a network configuration").Body(NetworkConfigRequest{}),
+			openapi.Post("/api/system/network/apply", "Apply a network configuration").Body(NetworkConfigRequest{}),
+		),
+		openapi.Tagged("Auth",
+			openapi.Post("/api/auth/login", "Staff login").Public().Body(LoginRequest{}).Returns(LoginResponse{}),
+			openapi.Post("/api/auth/refresh", "Exchange a refresh token for a new access token").Public(),
+			openapi.Get("/api/auth/sso/providers", "Single sign-on providers shown on the login page").Public(),
+			openapi.Post("/api/auth/sso/exchange", "Exchange a single sign-on code for a session").Public().Returns(LoginResponse{}),
+			openapi.Get("/api/auth/sso/:slug/login", "Redirect to the identity provider").Public(),
+			openapi.Get("/api/auth/sso/:slug/callback", "Identity provider callback").Public(),
+			openapi.Post("/api/auth/impersonate-exchange", "Exchange an impersonation token for a session").Public(),
+			openapi.Post("/api/auth/logout", "End the current session"),
+			openapi.Get("/api/auth/me", "Current user"),
+			openapi.Get("/api/auth/sessions", "Login sessions of the current user"),
+			openapi.Post("/api/auth/sessions/revoke-others", "Revoke all other sessions"),
+			openapi.Delete("/api/auth/sessions/:id", "Revoke a session"),
+			openapi.Put("/api/auth/password", "Change own password"),
+			openapi.Post("/api/auth/change-password", "Change own password (forced change after login)"),
+			openapi.Get("/api/auth/2fa/status", "Two-factor status"),
+			openapi.Post("/api/auth/2fa/setup", "Start authenticator app setup"),
+			openapi.Post("/api/auth/2fa/verify", "Confirm authenticator app setup"),
+			openapi.Post("/api/auth/2fa/disable", "Disable two-factor authentication"),
+			openapi.Post("/api/auth/2fa/recovery-codes", "Regenerate recovery codes"),
+			openapi.Get("/api/auth/2fa/webauthn", "Registered security keys"),
+			openapi.Post("/api/auth/2fa/webauthn/register/begin", "Start security key registration"),
+			openapi.Post("/api/auth/2fa/webauthn/register/finish", "Finish security key registration"),
+			openapi.Delete("/api/auth/2fa/webauthn/:id", "Remove a security key"),
+		),
+		openapi.Tagged("Settings",
+			openapi.Get("/api/branding", "Public branding for the login page").Public(),
+			openapi.Get("/api/server-time", "Server time and timezone").Public(),
+			openapi.Get("/api/settings", "List system preferences"),
+			openapi.Put("/api/settings/bulk", "Update several system preferences"),
+			openapi.Get("/api/settings/timezones", "Available timezones"),
+			openapi.Post("/api/settings/logo", "Upload the company logo").Upload("logo"),
+			openapi.Delete("/api/settings/logo", "Remove the company logo"),
+			openapi.Post("/api/settings/login-background", "Upload the login background").Upload("background"),
+			openapi.Delete("/api/settings/login-background", "Remove the login background"),
+			openapi.Post("/api/settings/favicon", "Upload the favicon").Upload("favicon"),
+			openapi.Delete("/api/settings/favicon", "Remove the favicon"),
+			openapi.Get("/api/settings/ssl-status", "SSL certificate status"),
+			openapi.Post("/api/settings/ssl-stream", "Install an SSL certificate, streaming progress").Produces("application/x-ndjson"),
+			openapi.Get("/api/settings/:key", "Get a system preference"),
+			openapi.Put("/api/settings/:key", "Set a system preference"),
+			openapi.Delete("/api/settings/:key", "Delete a system preference"),
+		),
+		openapi.Tagged("Backups",
+			openapi.Get("/api/backups/public-download/:token", "Download a backup with a one-time token").Public().Produces("application/octet-stream"),
+			openapi.Get("/api/backups", "List backups"),
+			openapi.Post("/api/backups", "Create a database backup").Body(CreateBackupRequest{}),
+			openapi.Post("/api/backups/mikrotik", "Back up a MikroTik router").Body(CreateMikroTikBackupRequest{}),
+			openapi.Post("/api/backups/upload", "Upload a backup file").Upload("file"),
+			openapi.Get("/api/backups/:filename/download", "Download a backup").Produces("application/octet-stream"),
+			openapi.Get("/api/backups/:filename/token", "Create a one-time download token"),
+			openapi.Get("/api/backups/:filename/validate", "Check that a backup can be restored"),
+			openapi.Post("/api/backups/:filename/restore", "Restore a backup"),
+			openapi.Delete("/api/backups/:filename", "Delete a backup"),
+			openapi.Get("/api/backups/schedules", "List backup schedules"),
+			openapi.Get("/api/backups/schedules/:id", "Get a backup schedule"),
+			openapi.Post("/api/backups/schedules", "Create a backup schedule").ReturnsData(models.BackupSchedule{}),
+			openapi.Put("/api/backups/schedules/:id", "Update a backup schedule").ReturnsData(models.BackupSchedule{}),
+			openapi.Delete("/api/backups/schedules/:id", "Delete a backup schedule"),
+			openapi.Post("/api/backups/schedules/:id/toggle", "Enable or disable a backup schedule"),
+			openapi.Post("/api/backups/schedules/:id/run", "Run a backup schedule now"),
+			openapi.Post("/api/backups/test-ftp", "Test FTP upload settings"),
+			openapi.Get("/api/backups/logs", "Backup run history"),
+			openapi.Get("/api/backups/cloud/list", "List cloud backups"),
+			openapi.Get("/api/backups/cloud/usage", "Cloud backup storage usage"),
+			openapi.Post("/api/backups/:filename/cloud-upload", "Upload a backup to cloud storage"),
+			openapi.Get("/api/backups/cloud/download/:backup_id", "Download a cloud backup").Produces("application/octet-stream"),
+			openapi.Get("/api/backups/cloud/:backup_id/token", "Download a cloud backup (legacy path)").Produces("application/octet-stream"),
+			openapi.Delete("/api/backups/cloud/:backup_id", "Delete a cloud backup"),
+		),
+		openapi.Tagged("Cluster",
+			openapi.Post("/api/cluster/join", "Node join request (cluster secret)").Public(),
+			openapi.Post("/api/cluster/heartbeat", "Node heartbeat (cluster secret)").Public(),
+			openapi.Post("/api/cluster/promote", "Promotion notice from a peer (cluster secret)").Public(),
+			openapi.Post("/api/cluster/notify", "Event notice from a peer (cluster secret)").Public(),
+			openapi.Post("/api/cluster/uploads", "Uploaded files for replication (cluster secret)").Public(),
+			openapi.Get("/api/cluster/config", "Cluster configuration"),
+			openapi.Get("/api/cluster/status", "Cluster node status"),
+			openapi.Get("/api/cluster/replication-status", "Database replication status"),
+			openapi.Post("/api/cluster/setup-main", "Make this server the main node"),
+			openapi.Post("/api/cluster/setup-secondary", "Join this server to a main node"),
+			openapi.Post("/api/cluster/leave", "Leave the cluster"),
+			openapi.Delete("/api/cluster/nodes/:id", "Remove a node"),
+			openapi.Post("/api/cluster/failover", "Fail over to this node"),
+			openapi.Post("/api/cluster/test-connection", "Test the connection to the main node"),
+			openapi.Get("/api/cluster/check-main-status", "Check whether the main node is reachable"),
+			openapi.Post("/api/cluster/promote-to-main", "Promote this secondary to main"),
+			openapi.Post("/api/cluster/test-source-connection", "Test the connection to a recovery source"),
+			openapi.Post("/api/cluster/recover-from-server", "Recover data from another server"),
+		),
+		openapi.Tagged("Customer Portal",
+			openapi.Post("/api/customer/login", "Customer login with PPPoE username and password").Public().Body(CustomerLoginRequest{}).Returns(CustomerLoginResponse{}),
+			openapi.Get("/api/customer/otp/channels", "Whether code login is enabled and on which channels").Public(),
+			openapi.Post("/api/customer/otp/request", "Send a login code to a registered phone number").Public(),
+			openapi.Post("/api/customer/otp/verify", "Log in with a code, or get an account picker").Public().Returns(CustomerLoginResponse{}),
+			openapi.Post("/api/customer/otp/select", "Finish a code login by choosing an account").Public().Returns(CustomerLoginResponse{}),
+			openapi.Get("/api/customer/dashboard", "Account overview").Customer(),
+			openapi.Get("/api/customer/sessions", "Connection history").Customer(),
+			openapi.Get("/api/customer/usage", "Daily usage history").Customer(),
+			openapi.Get("/api/customer/tickets", "List own support tickets").Customer(),
+			openapi.Get("/api/customer/tickets/:id", "Get a support ticket").Customer(),
+			openapi.Post("/api/customer/tickets", "Open a support ticket").Customer(),
+			openapi.Post("/api/customer/tickets/:id/reply", "Reply to a support ticket").Customer(),
+			openapi.Get("/api/customer/invoices", "List own invoices").Customer(),
+			openapi.Get("/api/customer/invoices/:id", "Get an invoice").Customer().ReturnsData(models.Invoice{}),
+			openapi.Get("/api/customer/active-banners", "Notification banners for the customer").Customer(),
+			openapi.Get("/api/customer/self-service", "Self-service actions allowed by the reseller").Customer().ReturnsData(models.PortalPermissions{}),
+			openapi.Get("/api/customer/wallet", "Wallet balance and transactions").Customer(),
+			openapi.Post("/api/customer/wallet/topup", "Top up the wallet with a prepaid card").Customer(),
+			openapi.Post("/api/customer/renew", "Renew with a prepaid card or the wallet").Customer(),
+			openapi.Get("/api/customer/plans", "Plans the customer can switch to").Customer(),
+			openapi.Get("/api/customer/plans/:id/quote", "Price of switching to a plan").Customer().ReturnsData(ChangeServicePriceResponse{}),
+			openapi.Post("/api/customer/plans/:id/change", "Switch plan, paid from the wallet").Customer(),
+			openapi.Post("/api/customer/reset-fup", "Reset the fair usage quota").Customer(),
+			openapi.Put("/api/customer/profile", "Request a contact details change").Customer(),
+			openapi.Post("/api/customer/profile/verify", "Confirm a contact details change").Customer(),
+			openapi.Post("/api/customer/password", "Change the PPPoE password").Customer(),
+		),
+		openapi.Tagged("License",
+			openapi.Post("/api/license/revalidate", "Revalidate the license now"),
+			openapi.Get("/api/license", "License details"),
+			openapi.Get("/api/license/status", "License status"),
+		),
+		openapi.Tagged("Dashboard",
+			openapi.Get("/api/dashboard/stats", "Headline counters"),
+			openapi.Get("/api/dashboard/chart", "Chart series"),
+			openapi.Get("/api/dashboard/transactions", "Recent transactions"),
+			openapi.Get("/api/dashboard/resellers", "Top resellers"),
+			openapi.Get("/api/dashboard/sessions", "Session counts"),
+			openapi.Get("/api/dashboard/system-metrics", "CPU, memory and disk usage"),
+			openapi.Get("/api/dashboard/system-capacity", "Server capacity estimate"),
+			openapi.Get("/api/dashboard/system-info", "Server and version information"),
+		),
+		openapi.Tagged("Subscribers",
+			openapi.Get("/api/subscribers", "List subscribers").Paged().
+				Params("search", "status", "service", "reseller", "online", "sort_by", "sort_dir").
+				Returns(SubscriberListResponse{}),
+			openapi.Get("/api/subscribers/archived", "List deleted subscribers").Paged(),
+			openapi.Get("/api/subscribers/:id", "Get a subscriber with usage and sessions").Returns(SubscriberDetailResponse{}),
+			openapi.Post("/api/subscribers", "Create a subscriber").Body(CreateSubscriberRequest{}).Returns(SubscriberResponse{}).Created(),
+			openapi.Post("/api/subscribers/bulk-import", "Import subscribers from a CSV file").Upload("file"),
+			openapi.Post("/api/subscribers/import-excel", "Import subscribers from spreadsheet rows").Body(BulkImportExcelRequest{}),
+			openapi.Post("/api/subscribers/bulk-update", "Update several subscribers").Body(BulkUpdateRequest{}),
+			openapi.Post("/api/subscribers/bulk-action", "Run an action on several subscribers").Body(BulkActionRequest{}),
+			openapi.Post("/api/subscribers/change-bulk", "Change service, reseller or expiry of matching subscribers").Body(ChangeBulkRequest{}),
+			openapi.Put("/api/subscribers/:id", "Update a subscriber; any subset of the create fields").Body(CreateSubscriberRequest{}).Returns(SubscriberResponse{}),
+			openapi.Delete("/api/subscribers/:id", "Delete a subscriber (archived, can be restored)").Returns(MessageResponse{}),
+			openapi.Post("/api/subscribers/:id/renew", "Renew a subscriber"),
+			openapi.Post("/api/subscribers/:id/disconnect", "Disconnect the active session"),
+			openapi.Post("/api/subscribers/:id/reset-fup", "Reset fair usage counters"),
+			openapi.Post("/api/subscribers/:id/reset-mac", "Clear the bound MAC address"),
+			openapi.Post("/api/subscribers/:id/reset-quota", "Reset quota usage"),
+			openapi.Post("/api/subscribers/:id/restore", "Restore a deleted subscriber"),
+			openapi.Delete("/api/subscribers/:id/permanent", "Delete a subscriber permanently"),
+			openapi.Post("/api/subscribers/:id/rename", "Change the username").Body(RenameRequest{}),
+			openapi.Post("/api/subscribers/:id/add-days", "Add days to the expiry").Body(AddDaysRequest{}),
+			openapi.Get("/api/subscribers/:id/calculate-change-service-price", "Price of a service change").Params("service_id").ReturnsData(ChangeServicePriceResponse{}),
+			openapi.Post("/api/subscribers/:id/change-service", "Change the service").Body(ChangeServiceRequest{}),
+			openapi.Post("/api/subscribers/:id/activate", "Activate a subscriber"),
+			openapi.Post("/api/subscribers/:id/deactivate", "Deactivate a subscriber"),
+			openapi.Post("/api/subscribers/:id/refill", "Add credit to a subscriber").Body(RefillRequest{}),
+			openapi.Post("/api/subscribers/:id/ping", "Ping the subscriber's IP from the router"),
+			openapi.Post("/api/subscribers/:id/port-check", "Check whether the subscriber's WAN port is open"),
+			openapi.Get("/api/subscribers/:id/password", "Reveal the PPPoE password"),
+			openapi.Get("/api/subscribers/:id/bandwidth", "Live bandwidth").ReturnsData(BandwidthResponse{}),
+			openapi.Get("/api/subscribers/:id/torch", "Live traffic breakdown from the router").ReturnsData(TorchResponse{}),
+			openapi.Get("/api/subscribers/:id/bandwidth-rules", "List per-subscriber bandwidth rules"),
+			openapi.Post("/api/subscribers/:id/bandwidth-rules", "Create a per-subscriber bandwidth rule"),
+			openapi.Put("/api/subscribers/:id/bandwidth-rules/:ruleId", "Update a per-subscriber bandwidth rule"),
+			openapi.Delete("/api/subscribers/:id/bandwidth-rules/:ruleId", "Delete a per-subscriber bandwidth rule"),
+			openapi.Get("/api/subscribers/:id/cdn-upgrades", "CDN speed upgrades that apply to the subscriber"),
+			openapi.Post("/api/subscribers/:id/wan-check-skip", "Exclude the subscriber from WAN port checks"),
+			openapi.Post("/api/subscribers/:id/wan-check-recheck", "Re-run the WAN port check"),
+		),
+		openapi.Tagged("Services",
+			openapi.Get("/api/services", "List services").Params("all").Returns(ServiceListResponse{}),
+			openapi.Get("/api/services/:id", "Get a service").Returns(ServiceDetailResponse{}),
+			openapi.Post("/api/services", "Create a service").Body(CreateServiceRequest{}).Returns(ServiceResponse{}).Created(),
+			openapi.Put("/api/services/:id", "Update a service; any subset of the create fields").Body(CreateServiceRequest{}).Returns(ServiceResponse{}),
+			openapi.Delete("/api/services/:id", "Delete a service").Returns(MessageResponse{}),
+			openapi.Get("/api/services/:id/cdns", "CDN speeds configured on a service"),
+			openapi.Put("/api/services/:id/cdns", "Replace the CDN speeds of a service"),
+			openapi.Post("/api/services/:id/cdns", "Add a CDN speed to a service"),
+			openapi.Delete("/api/services/:id/cdns/:cdnId", "Remove a CDN speed from a service"),
+		),
+		openapi.Tagged("NAS",
+			openapi.Get("/api/nas", "List NAS devices").Returns(NasListResponse{}),
+			openapi.Get("/api/nas/health", "Health overview of all NAS devices"),
+			openapi.Get("/api/nas/health/events", "Health events of all NAS devices"),
+			openapi.Get("/api/nas/:id", "Get a NAS device").Returns(NasDetailResponse{}),
+			openapi.Post("/api/nas", "Create a NAS device").Body(CreateNasRequest{}).Returns(NasResponse{}).Created(),
+			openapi.Put("/api/nas/:id", "Update a NAS device; any subset of the create fields").Body(CreateNasRequest{}).Returns(NasResponse{}),
+			openapi.Delete("/api/nas/:id", "Delete a NAS device").Returns(MessageResponse{}),
+			openapi.Post("/api/nas/:id/sync", "Sync subscribers to the router"),
+			openapi.Post("/api/nas/:id/test", "Test RADIUS and API connectivity"),
+			openapi.Post("/api/nas/:id/provision", "Push the RADIUS configuration to the router"),
+			openapi.Get("/api/nas/:id/pools", "IP pools on the router"),
+			openapi.Put("/api/nas/:id/pools", "Choose the pools used for subscribers"),
+			openapi.Get("/api/nas/:id/health", "Health history of a NAS device"),
+			openapi.Get("/api/nas/:id/health/events", "Health events of a NAS device"),
+			openapi.Post("/api/nas/:id/health/poll", "Poll a NAS device now"),
+			openapi.Get("/api/nas/:id/config/versions", "List configuration snapshots"),
+			openapi.Post("/api/nas/:id/config/versions", "Take a configuration snapshot"),
+			openapi.Get("/api/nas/:id/config/diff", "Diff two configuration snapshots").Params("from", "to"),
+			openapi.Get("/api/nas/:id/config/versions/:version", "Get a configuration snapshot"),
+			openapi.Post("/api/nas/:id/config/versions/:version/restore", "Restore a configuration snapshot"),
+		),
+		openapi.Tagged("IP Pools",
+			openapi.Get("/api/ip-pools/stats", "Pool usage"),
+			openapi.Get("/api/ip-pools/status", "Whether ProISP manages IP assignment"),
+			openapi.Get("/api/ip-pools/assignments", "Current IP assignments"),
+			openapi.Post("/api/ip-pools/import", "Import pools from all routers"),
+			openapi.Post("/api/ip-pools/import/:id", "Import pools from one router"),
+			openapi.Post("/api/ip-pools/sync-sessions", "Record IPs of active sessions on all routers"),
+			openapi.Post("/api/ip-pools/sync-sessions/:id", "Record IPs of active sessions on one router"),
+			openapi.Post("/api/ip-pools/enable", "Let ProISP manage IP assignment"),
+			openapi.Post("/api/ip-pools/disable", "Hand IP assignment back to the routers"),
+			openapi.Get("/api/ip-pools/cgnat", "List CGNAT port-block pools").ReturnsData([]CGNATPoolInfo{}),
+			openapi.Get("/api/ip-pools/cgnat/lookup", "Current CGNAT translation of a private address, or of a public address and port").Params("ip", "port").ReturnsData(models.CGNATPortBlock{}),
+			openapi.Post("/api/ip-pools/cgnat", "Create a CGNAT pool and push its src-nat rules").Body(CGNATPoolRequest{}).ReturnsData(models.CGNATPool{}).Created(),
+			openapi.Put("/api/ip-pools/cgnat/:id", "Update a CGNAT pool and re-push its rules").Body(CGNATPoolRequest{}).ReturnsData(models.CGNATPool{}),
+			openapi.Delete("/api/ip-pools/cgnat/:id", "Delete a CGNAT pool and remove its rules"),
+			openapi.Get("/api/ip-pools/cgnat/:id/blocks", "Port-block mapping of a CGNAT pool").Paged().Params("ip").ReturnsData([]models.CGNATPortBlock{}),
+			openapi.Post("/api/ip-pools/cgnat/:id/sync", "Re-push a CGNAT pool's rules to its NAS"),
+		),
+		openapi.Tagged("Hotspot",
+			openapi.Get("/api/hotspot/sessions", "Live hotspot sessions"),
+			openapi.Get("/api/hotspot/walled-garden", "List walled garden entries"),
+			openapi.Post("/api/hotspot/walled-garden", "Create a walled garden entry").Body(WalledGardenRequest{}),
+			openapi.Post("/api/hotspot/walled-garden/sync", "Push the walled garden to the routers"),
+			openapi.Put("/api/hotspot/walled-garden/:id", "Update a walled garden entry").Body(WalledGardenRequest{}),
+			openapi.Delete("/api/hotspot/walled-garden/:id", "Delete a walled garden entry"),
+		),
+		openapi.Tagged("Resellers",
+			openapi.Get("/api/resellers", "List resellers").Paged().Params("search").Returns(ResellerListResponse{}),
+			openapi.Get("/api/resellers/:id", "Get a reseller").Returns(ResellerDetailResponse{}),
+			openapi.Post("/api/resellers", "Create a reseller").Body(CreateResellerRequest{}).Returns(ResellerResponse{}).Created(),
+			openapi.Put("/api/resellers/:id", "Update a reseller; any subset of the create fields").Body(CreateResellerRequest{}).Returns(ResellerResponse{}),
+			openapi.Delete("/api/resellers/:id", "Delete a reseller").Returns(MessageResponse{}),
+			openapi.Delete("/api/resellers/:id/permanent", "Delete a reseller permanently"),
+			openapi.Post("/api/resellers/:id/transfer", "Transfer balance to a reseller"),
+			openapi.Post("/api/resellers/:id/withdraw", "Withdraw balance from a reseller"),
+			openapi.Post("/api/resellers/:id/impersonate", "Log in as a reseller"),
+			openapi.Post("/api/resellers/:id/impersonate-token", "Create a token to open a reseller session in a new tab"),
+			openapi.Get("/api/resellers/:id/assigned-nas", "NAS devices assigned to a reseller"),
+			openapi.Put("/api/resellers/:id/assigned-nas", "Assign NAS devices to a reseller").Body(UpdateAssignedNASRequest{}),
+			openapi.Get("/api/resellers/:id/assigned-services", "Services and prices assigned to a reseller"),
+			openapi.Put("/api/resellers/:id/assigned-services", "Assign services and prices to a reseller").Body(UpdateAssignedServicesRequest{}),
+			openapi.Get("/api/resellers/:id/portal-permissions", "Customer portal permissions of a reseller").ReturnsData(models.PortalPermissions{}),
+			openapi.Put("/api/resellers/:id/portal-permissions", "Update customer portal permissions").Body(models.PortalPermissions{}).ReturnsData(models.PortalPermissions{}),
+		),
+		openapi.Tagged("Commissions",
+			openapi.Get("/api/commissions", "Commission ledger entries").Paged().Params("reseller_id", "period", "settlement_id", "unsettled").ReturnsData([]models.Commission{}),
+			openapi.Get("/api/commissions/summary", "Commission totals per reseller for a month").Params("period", "reseller_id").ReturnsData([]CommissionSummaryRow{}),
+			openapi.Get("/api/commissions/plans", "List commission plans").ReturnsData([]models.CommissionPlan{}),
+			openapi.Post("/api/commissions/plans", "Create a commission plan").Body(CommissionPlanRequest{}).ReturnsData(models.CommissionPlan{}).Created(),
+			openapi.Put("/api/commissions/plans/:id", "Update a commission plan and replace its rules").Body(CommissionPlanRequest{}).ReturnsData(models.CommissionPlan{}),
+			openapi.Delete("/api/commissions/plans/:id", "Delete a commission plan"),
+			openapi.Get("/api/commissions/settlements", "Monthly commission statements").Paged().Params("reseller_id", "period", "status").ReturnsData([]models.CommissionSettlement{}),
+			openapi.Post("/api/commissions/settlements/generate", "Generate the statements of a finished month").Body(GenerateCommissionSettlementsRequest{}),
+			openapi.Get("/api/commissions/settlements/:id", "A statement with its entries"),
+			openapi.Post("/api/commissions/settlements/:id/pay", "Mark a statement paid").Body(PayCommissionSettlementRequest{}).ReturnsData(models.CommissionSettlement{}),
+		),
+		openapi.Tagged("Analytics",
+			openapi.Get("/api/analytics/overview", "A month's churn, ARPU and renewal rate with trend comparisons").Params("dimension", "key", "period").ReturnsData(AnalyticsOverviewResponse{}),
+			openapi.Get("/api/analytics/metrics", "Monthly subscriber metrics by service, reseller or region").Params("dimension", "key", "from", "to").ReturnsData([]models.AnalyticsMonthly{}),
+			openapi.Get("/api/analytics/cohorts", "Cohort retention by signup month").Params("dimension", "key", "from").ReturnsData([]models.AnalyticsCohort{}),
+			openapi.Post("/api/analytics/recompute", "Recompute the analytics now").Returns(MessageResponse{}),
+		),
+		openapi.Tagged("Sessions",
+			openapi.Get("/api/sessions", "List sessions").Paged().Params("status", "nas_ip", "search", "type").Returns(SessionListResponse{}),
+			openapi.Get("/api/sessions/:id", "Get an accounting record").Returns(SessionResponse{}),
+			openapi.Post("/api/sessions/:id/disconnect", "Disconnect a session").Returns(MessageResponse{}),
+		),
+		openapi.Tagged("Notifications",
+			openapi.Post("/api/notifications/test-smtp", "Send a test email").Body(TestSMTPRequest{}),
+			openapi.Post("/api/notifications/test-sms", "Send a test SMS").Body(TestSMSRequest{}),
+			openapi.Post("/api/notifications/test-whatsapp", "Send a test WhatsApp message").Body(TestWhatsAppRequest{}),
+			openapi.Get("/api/notifications/whatsapp-status", "WhatsApp connection status"),
+			openapi.Get("/api/notifications/proxrad/accounts", "ProxRad WhatsApp accounts"),
+			openapi.Post("/api/notifications/proxrad/select-account", "Choose the ProxRad WhatsApp account"),
+			openapi.Get("/api/notifications/proxrad/create-link", "Start linking a ProxRad account"),
+			openapi.Get("/api/notifications/proxrad/link-status", "ProxRad link status"),
+			openapi.Delete("/api/notifications/proxrad/unlink", "Unlink the ProxRad account"),
+			openapi.Get("/api/notifications/proxrad/access", "Whether ProxRad is available on this license"),
+			openapi.Post("/api/notifications/proxrad/test-send", "Send a test message through ProxRad"),
+			openapi.Get("/api/notifications/whatsapp/subscribers", "Subscribers with WhatsApp notification settings"),
+			openapi.Post("/api/notifications/whatsapp/send", "Send a WhatsApp message to subscribers"),
+			openapi.Post("/api/notifications/whatsapp/subscribers/:id/toggle-notifications", "Toggle WhatsApp notifications for a subscriber"),
+			openapi.Post("/api/notifications/whatsapp/notifications/set-all", "Turn WhatsApp notifications on or off for all subscribers"),
+			openapi.Get("/api/notifications/updates/pending", "Pending product update notices"),
+			openapi.Post("/api/notifications/updates/:id/read", "Mark an update notice as read"),
+			openapi.Get("/api/notifications/updates/settings", "Update notice settings"),
+			openapi.Put("/api/notifications/updates/settings", "Change update notice settings"),
+		),
+		openapi.Tagged("Reseller Self-Service",
+			openapi.Get("/api/reseller/whatsapp/settings", "Own WhatsApp settings"),
+			openapi.Get("/api/reseller/whatsapp/proxrad/create-link", "Start linking an own ProxRad account"),
+			openapi.Get("/api/reseller/whatsapp/proxrad/link-status", "Own ProxRad link status"),
+			openapi.Delete("/api/reseller/whatsapp/proxrad/unlink", "Unlink the own ProxRad account"),
+			openapi.Post("/api/reseller/whatsapp/proxrad/test-send", "Send a test message through the own account"),
+			openapi.Get("/api/reseller/whatsapp/subscribers", "Own subscribers with WhatsApp settings"),
+			openapi.Post("/api/reseller/whatsapp/send", "Send a WhatsApp message to own subscribers"),
+			openapi.Post("/api/reseller/whatsapp/subscribers/:id/toggle-notifications", "Toggle WhatsApp notifications for an own subscriber"),
+			openapi.Post("/api/reseller/whatsapp/notifications/set-all", "Turn WhatsApp notifications on or off for all own subscribers"),
+			openapi.Get("/api/reseller/branding", "Own branding"),
+			openapi.Put("/api/reseller/branding", "Update own branding"),
+			openapi.Post("/api/reseller/branding/logo", "Upload own logo").Upload("logo"),
+			openapi.Delete("/api/reseller/branding/logo", "Remove own logo"),
+			openapi.Post("/api/reseller/branding/ssl", "Request a certificate for the own domain, streaming progress").Produces("text/event-stream"),
+			openapi.Put("/api/reseller/branding/domain", "Set the own portal domain"),
+		),
+		openapi.Tagged("Notification Banners",
+			openapi.Get("/api/active-banners", "Banners to show the current user"),
+			openapi.Get("/api/notification-banners", "List banners"),
+			openapi.Get("/api/notification-banners/sub-resellers", "Resellers a banner can target"),
+			openapi.Post("/api/notification-banners", "Create a banner").Body(CreateBannerRequest{}),
+			openapi.Put("/api/notification-banners/:id", "Update a banner").Body(CreateBannerRequest{}),
+			openapi.Delete("/api/notification-banners/:id", "Delete a banner"),
+		),
+		openapi.Tagged("Diagnostics",
+			openapi.Post("/api/diagnostic/ping", "Ping a host"),
+			openapi.Post("/api/diagnostic/ping-stream", "Ping a host, streaming replies").Produces("application/x-ndjson"),
+			openapi.Post("/api/diagnostic/traceroute", "Trace the route to a host"),
+			openapi.Post("/api/diagnostic/nslookup", "Resolve a host name"),
+			openapi.Get("/api/diagnostic/search-subscribers", "Find subscribers to run diagnostics against").Params("q"),
+		),
+		openapi.Tagged("Collectors",
+			openapi.Get("/api/collectors", "List collectors"),
+			openapi.Get("/api/collectors/report", "Collection report"),
+			openapi.Get("/api/collectors/:id", "Get a collector"),
+			openapi.Get("/api/collectors/:id/assignments", "Assignments of a collector"),
+			openapi.Post("/api/collectors/assignments", "Assign a collection"),
+			openapi.Delete("/api/collectors/assignments/:id", "Remove an assignment"),
+			openapi.Get("/api/collector/dashboard", "Own collection summary"),
+			openapi.Get("/api/collector/assignments", "Own assignments"),
+			openapi.Get("/api/collector/assignments/:id", "Get an own assignment"),
+			openapi.Post("/api/collector/assignments/:id/collect", "Mark an assignment collected"),
+			openapi.Post("/api/collector/assignments/:id/fail", "Mark an assignment failed"),
+		),
+		openapi.Tagged("Users",
+			openapi.Get("/api/users", "List staff users"),
+			openapi.Get("/api/users/two-factor-policy", "User types required to use two-factor authentication"),
+			openapi.Put("/api/users/two-factor-policy", "Change the two-factor requirement"),
+			openapi.Get("/api/users/:id", "Get a staff user"),
+			openapi.Post("/api/users", "Create a staff user"),
+			openapi.Put("/api/users/:id", "Update a staff user"),
+			openapi.Delete("/api/users/:id", "Delete a staff user"),
+			openapi.Get("/api/users/:id/sessions", "Login sessions of a user"),
+			openapi.Post("/api/users/:id/revoke-sessions", "Revoke all sessions of a user"),
+			openapi.Post("/api/users/:id/reset-2fa", "Remove a user's second factors"),
+			openapi.Get("/api/users/:id/identities", "Single sign-on identities linked to a user"),
+			openapi.Delete("/api/users/:id/identities/:identityId", "Unlink a single sign-on identity"),
+		),
+		openapi.Tagged("Single Sign-On",
+			openapi.Get("/api/sso/providers", "List identity providers"),
+			openapi.Post("/api/sso/providers", "Add an identity provider").Body(SSOProviderRequest{}),
+			openapi.Put("/api/sso/providers/:id", "Update an identity provider").Body(SSOProviderRequest{}),
+			openapi.Delete("/api/sso/providers/:id", "Delete an identity provider"),
+			openapi.Post("/api/sso/providers/:id/test", "Check the provider's discovery document"),
+			openapi.Put("/api/sso/settings", "Local login policy"),
+		),
+		openapi.Tagged("API Keys",
+			openapi.Get("/api/api-keys", "List API keys"),
+			openapi.Post("/api/api-keys", "Create an API key").Body(APIKeyRequest{}),
+			openapi.Put("/api/api-keys/:id", "Update an API key").Body(APIKeyRequest{}),
+			openapi.Delete("/api/api-keys/:id", "Revoke an API key"),
+		),
+		openapi.Tagged("Webhooks",
+			openapi.Get("/api/webhooks", "List webhook endpoints"),
+			openapi.Get("/api/webhooks/events", "Event types that can be subscribed to"),
+			openapi.Get("/api/webhooks/deliveries", "Delivery log"),
+			openapi.Get("/api/webhooks/dead-letters", "Deliveries that exhausted their retries"),
+			openapi.Post("/api/webhooks/deliveries/replay", "Replay several deliveries"),
+			openapi.Post("/api/webhooks/deliveries/:id/replay", "Replay a delivery"),
+			openapi.Post("/api/webhooks", "Create a webhook endpoint").Body(WebhookEndpointRequest{}),
+			openapi.Put("/api/webhooks/:id", "Update a webhook endpoint").Body(WebhookEndpointRequest{}),
+			openapi.Delete("/api/webhooks/:id", "Delete a webhook endpoint"),
+			openapi.Post("/api/webhooks/:id/test", "Send a test event"),
+			openapi.Post("/api/webhooks/:id/rotate-secret", "Rotate the signing secret"),
+		),
+		openapi.Tagged("Communication",
+			openapi.Get("/api/communication/templates", "List message templates"),
+			openapi.Get("/api/communication/templates/:id", "Get a message template"),
+			openapi.Post("/api/communication/templates", "Create a message template"),
+			openapi.Put("/api/communication/templates/:id", "Update a message template"),
+			openapi.Delete("/api/communication/templates/:id", "Delete a message template"),
+			openapi.Get("/api/communication/triggers", "Rule triggers, template variables and helpers").ReturnsData(RuleTriggersResponse{}),
+			openapi.Get("/api/communication/rules", "List notification rules"),
+			openapi.Post("/api/communication/rules/preview", "Render a rule template against a subscriber").Body(RulePreviewRequest{}).ReturnsData(RulePreviewResponse{}),
+			openapi.Get("/api/communication/rules/:id", "Get a notification rule"),
+			openapi.Post("/api/communication/rules", "Create a notification rule"),
+			openapi.Put("/api/communication/rules/:id", "Update a notification rule"),
+			openapi.Delete("/api/communication/rules/:id", "Delete a notification rule"),
+			openapi.Get("/api/communication/logs", "Sent message log"),
+			openapi.Get("/api/communication/outbox", "Queued, sent and failed outbound messages").Paged().Params("status", "channel", "source", "subscriber_id", "search").ReturnsData([]models.OutboundMessage{}),
+			openapi.Get("/api/communication/outbox/stats", "Outbox message counts").ReturnsData(OutboxStats{}),
+			openapi.Post("/api/communication/outbox/resend", "Resend failed messages"),
+			openapi.Post("/api/communication/outbox/:id/resend", "Resend a failed or cancelled message"),
+			openapi.Post("/api/communication/outbox/:id/cancel", "Cancel a pending message"),
+			openapi.Get("/api/communication/inbox", "Messages received by the chatbot with its replies").Paged().Params("channel", "command", "subscriber_id", "search").ReturnsData([]models.InboundMessage{}),
+			openapi.Post("/api/inbound/whatsapp/:token", "Inbound WhatsApp webhook (Ultramsg JSON or proxsms form)").Public(),
+			openapi.Post("/api/inbound/sms/:token", "Inbound SMS webhook (JSON or form fields)").Public(),
+			openapi.Get("/api/inbound/sms/:token", "Inbound SMS webhook (query parameters)").Public(),
+		),
+		openapi.Tagged("SMS Gateways",
+			openapi.Get("/api/sms-gateways", "SMS gateways in failover order with their health").ReturnsData([]SMSGatewayInfo{}),
+			openapi.Post("/api/sms-gateways", "Add an SMS gateway").Body(SMSGatewayRequest{}).ReturnsData(SMSGatewayInfo{}),
+			openapi.Put("/api/sms-gateways/order", "Set the failover order"),
+			openapi.Put("/api/sms-gateways/:id", "Update an SMS gateway").Body(SMSGatewayRequest{}).ReturnsData(SMSGatewayInfo{}),
+			openapi.Delete("/api/sms-gateways/:id", "Delete an SMS gateway"),
+			openapi.Post("/api/sms-gateways/:id/test", "Send a test SMS through one gateway"),
+			openapi.Post("/api/sms-gateways/:id/reset", "Clear a gateway's failures and cooldown"),
+			openapi.Post("/api/sms/dlr/:id/:token", "SMS delivery receipt webhook (JSON or form fields)").Public(),
+			openapi.Get("/api/sms/dlr/:id/:token", "SMS delivery receipt webhook (query parameters)").Public(),
+		),
+		openapi.Tagged("Bandwidth Rules",
+			openapi.Get("/api/bandwidth/rules", "List time-based bandwidth rules"),
+			openapi.Get("/api/bandwidth/rules/:id", "Get a bandwidth rule"),
+			openapi.Post("/api/bandwidth/rules", "Create a bandwidth rule"),
+			openapi.Put("/api/bandwidth/rules/:id", "Update a bandwidth rule"),
+			openapi.Delete("/api/bandwidth/rules/:id", "Delete a bandwidth rule"),
+			openapi.Post("/api/bandwidth/rules/:id/apply", "Apply a bandwidth rule now"),
+		),
+		openapi.Tagged("FUP",
+			openapi.Get("/api/fup/stats", "Fair usage statistics"),
+			openapi.Get("/api/fup/quotas", "Subscribers' quota usage"),
+			openapi.Get("/api/fup/quotas/:id/history", "Quota history of a subscriber"),
+			openapi.Get("/api/fup/top-users", "Heaviest users"),
+			openapi.Post("/api/fup/reset/:id", "Reset a subscriber's fair usage"),
+			openapi.Post("/api/fup/bulk-reset", "Reset fair usage of several subscribers").Body(BulkResetRequest{}),
+			openapi.Post("/api/fup/reset-all", "Reset fair usage of all subscribers"),
+		),
+		openapi.Tagged("Prepaid Cards",
+			openapi.Get("/api/prepaid", "List prepaid cards"),
+			openapi.Get("/api/prepaid/batches", "List card batches"),
+			openapi.Get("/api/prepaid/:id", "Get a prepaid card"),
+			openapi.Post("/api/prepaid/generate", "Generate a batch of cards"),
+			openapi.Post("/api/prepaid/use", "Redeem a card for a subscriber"),
+			openapi.Delete("/api/prepaid/:id", "Delete a prepaid card"),
+			openapi.Delete("/api/prepaid/batch/:batch", "Delete a card batch"),
+		),
+		openapi.Tagged("Invoices",
+			openapi.Get("/api/invoices", "List invoices").Paged().Params("status", "subscriber_id").Returns(InvoiceListResponse{}),
+			openapi.Get("/api/invoices/:id", "Get an invoice with its items").Returns(InvoiceResponse{}),
+			openapi.Post("/api/invoices", "Create an invoice").Body(CreateInvoiceRequest{}).Returns(InvoiceResponse{}).Created(),
+			openapi.Put("/api/invoices/:id", "Update an open invoice").Body(UpdateInvoiceRequest{}).Returns(InvoiceResponse{}),
+			openapi.Delete("/api/invoices/:id", "Delete an open invoice").Returns(MessageResponse{}),
+			openapi.Post("/api/invoices/:id/payment", "Record a payment").Body(InvoicePaymentRequest{}),
+			openapi.Get("/api/invoices/:id/payments", "Payments of an invoice").ReturnsData([]models.Payment{}),
+		),
+		openapi.Tagged("Audit",
+			openapi.Get("/api/audit", "List audit log entries").Paged(),
+			openapi.Get("/api/audit/actions", "Audit action names"),
+			openapi.Get("/api/audit/entity-types", "Audited entity types"),
+			openapi.Get("/api/audit/:id", "Get an audit log entry").ReturnsData(models.AuditLog{}),
+		),
+		openapi.Tagged("Tickets",
+			openapi.Get("/api/tickets", "List support tickets").Paged(),
+			openapi.Get("/api/tickets/stats", "Ticket counts by status"),
+			openapi.Get("/api/tickets/:id", "Get a support ticket"),
+			openapi.Post("/api/tickets", "Open a support ticket"),
+			openapi.Put("/api/tickets/:id", "Update a support ticket"),
+			openapi.Delete("/api/tickets/:id", "Delete a support ticket"),
+			openapi.Post("/api/tickets/:id/reply", "Reply to a support ticket"),
+		),
+		openapi.Tagged("Permissions",
+			openapi.Get("/api/permissions", "List permissions"),
+			openapi.Post("/api/permissions", "Create a permission"),
+			openapi.Delete("/api/permissions/:id", "Delete a permission"),
+			openapi.Post("/api/permissions/seed", "Create the built-in permissions"),
+			openapi.Get("/api/permissions/groups", "List permission groups"),
+			openapi.Get("/api/permissions/groups/:id", "Get a permission group"),
+			openapi.Post("/api/permissions/groups", "Create a permission group"),
+			openapi.Put("/api/permissions/groups/:id", "Update a permission group"),
+			openapi.Delete("/api/permissions/groups/:id", "Delete a permission group"),
+			openapi.Get("/api/permissions/matrix", "Permissions granted to each group"),
+			openapi.Get("/api/permissions/users/:id", "Effective permissions of a user"),
+		),
+		openapi.Tagged("Reports",
+			openapi.Get("/api/reports/subscribers", "Subscriber statistics"),
+			openapi.Get("/api/reports/revenue", "Revenue statistics"),
+			openapi.Get("/api/reports/services", "Subscribers and revenue per service"),
+			openapi.Get("/api/reports/resellers", "Reseller statistics"),
+			openapi.Get("/api/reports/usage", "Data usage statistics"),
+			openapi.Get("/api/reports/expiry", "Upcoming expiries"),
+			openapi.Get("/api/reports/transactions", "Transaction report"),
+			openapi.Get("/api/reports/nas", "Subscribers and sessions per NAS"),
+			openapi.Get("/api/reports/export/:type", "Export a report as JSON, CSV, XLSX or PDF").
+				Params("format", "date_range", "from", "to", "reseller_id", "service_id", "nas_id").
+				Produces("application/octet-stream"),
+			openapi.Get("/api/reports/types", "Report types, formats, date ranges and schedules"),
+			openapi.Get("/api/reports/definitions", "List saved reports").ReturnsData([]models.ReportDefinition{}),
+			openapi.Post("/api/reports/definitions", "Save a report, optionally on a schedule").Body(ReportDefinitionRequest{}).Created(),
+			openapi.Put("/api/reports/definitions/:id", "Update a saved report").Body(ReportDefinitionRequest{}),
+			openapi.Delete("/api/reports/definitions/:id", "Delete a saved report"),
+			openapi.Post("/api/reports/definitions/:id/run", "Run a saved report now").Params("email"),
+			openapi.Get("/api/reports/runs", "Stored report files").Paged().Params("definition_id", "report_type").ReturnsData([]models.ReportRun{}),
+			openapi.Get("/api/reports/runs/:id/download", "Download a stored report file").Produces("application/octet-stream"),
+		),
+		openapi.Tagged("Sharing Detection",
+			openapi.Get("/api/sharing", "Subscribers suspected of sharing their connection"),
+			openapi.Get("/api/sharing/stats", "Sharing detection statistics"),
+			openapi.Get("/api/sharing/history", "Past detections"),
+			openapi.Get("/api/sharing/trends", "Detections over time"),
+			openapi.Get("/api/sharing/repeat-offenders", "Subscribers detected repeatedly"),
+			openapi.Get("/api/sharing/settings", "Sharing detection settings"),
+			openapi.Put("/api/sharing/settings", "Change sharing detection settings"),
+			openapi.Post("/api/sharing/scan", "Run a detection scan now"),
+			openapi.Get("/api/sharing/subscriber/:id", "Detection details of a subscriber"),
+			openapi.Get("/api/sharing/nas-rules", "TTL rule status on each NAS"),
+			openapi.Post("/api/sharing/nas/:nas_id/rules", "Install TTL detection rules on a NAS"),
+			openapi.Delete("/api/sharing/nas/:nas_id/rules", "Remove TTL detection rules from a NAS"),
+		),
+		openapi.Tagged("CDN",
+			openapi.Get("/api/cdns", "List CDNs"),
+			openapi.Get("/api/cdns/speeds", "CDN speeds across services"),
+			openapi.Get("/api/cdns/:id", "Get a CDN"),
+			openapi.Post("/api/cdns", "Create a CDN"),
+			openapi.Put("/api/cdns/:id", "Update a CDN"),
+			openapi.Delete("/api/cdns/:id", "Delete a CDN"),
+			openapi.Post("/api/cdns/:id/sync", "Push a CDN to the routers"),
+			openapi.Post("/api/cdns/sync-all", "Push all CDNs to the routers"),
+			openapi.Post("/api/cdns/:id/sync-pcq", "Push a CDN's PCQ queues to the routers"),
+			openapi.Post("/api/cdns/sync-all-pcq", "Push all PCQ queues to the routers"),
+			openapi.Get("/api/cdn-port-rules", "List CDN port rules"),
+			openapi.Post("/api/cdn-port-rules", "Create a CDN port rule"),
+			openapi.Put("/api/cdn-port-rules/:id", "Update a CDN port rule"),
+			openapi.Delete("/api/cdn-port-rules/:id", "Delete a CDN port rule"),
+			openapi.Post("/api/cdn-port-rules/:id/sync", "Push a port rule to the routers"),
+			openapi.Post("/api/cdn-port-rules/sync-all", "Push all port rules to the routers"),
+			openapi.Get("/api/cdn-bandwidth-rules", "List time-based CDN bandwidth rules"),
+			openapi.Get("/api/cdn-bandwidth-rules/:id", "Get a CDN bandwidth rule"),
+			openapi.Post("/api/cdn-bandwidth-rules", "Create a CDN bandwidth rule"),
+			openapi.Put("/api/cdn-bandwidth-rules/:id", "Update a CDN bandwidth rule"),
+			openapi.Delete("/api/cdn-bandwidth-rules/:id", "Delete a CDN bandwidth rule"),
+			openapi.Post("/api/cdn-bandwidth-rules/:id/apply", "Apply a CDN bandwidth rule now"),
+		),
+		openapi.Tagged("IP History",
+			openapi.Get("/api/ip-history", "List IP assignments by address, subscriber, MAC or period").Paged().Params("ip", "port", "username", "subscriber_id", "mac", "at", "from", "to").ReturnsData([]IPAssignmentRecord{}),
+			openapi.Get("/api/ip-history/lookup", "Find who held an IP address, or CGNAT address and port, at a moment").Params("ip", "port", "at").ReturnsData([]IPAssignmentRecord{}),
+			openapi.Get("/api/ip-history/signing-key", "Get the public key that verifies export signatures").ReturnsData(IPHistorySigningKey{}),
+			openapi.Get("/api/ip-history/exports", "List signed IP history exports").Paged().Params("case_reference").ReturnsData([]models.IPHistoryExport{}),
+			openapi.Post("/api/ip-history/exports", "Create a signed IP history export for a law-enforcement request").Body(CreateIPHistoryExportRequest{}).ReturnsData(models.IPHistoryExport{}).Created(),
+			openapi.Get("/api/ip-history/exports/:id/download", "Download the signed file of an export").Produces("text/csv"),
+		),
+		openapi.Tagged("Lists (v2)",
+			openapi.Get("/api/v2/subscribers", "List subscribers with cursor pagination").Listed().Returns(listquery.Page{}),
+			openapi.Get("/api/v2/sessions", "List RADIUS sessions with cursor pagination").Listed().Returns(listquery.Page{}),
+			openapi.Get("/api/v2/audit", "List audit log entries with cursor pagination").Listed().Returns(listquery.Page{}),
+			openapi.Get("/api/v2/transactions", "List transactions with cursor pagination").Listed().Returns(listquery.Page{}),
+			openapi.Get("/api/v2/invoices", "List invoices with cursor pagination").Listed().Returns(listquery.Page{}),
+		),
+	} {
+		all = append(all, group...)
+	}
+	return all
+}
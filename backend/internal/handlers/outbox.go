@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type OutboxHandler struct{}
+
+func NewOutboxHandler() *OutboxHandler {
+	return &OutboxHandler{}
+}
+
+// OutboxStats counts outbox messages by status and channel
+type OutboxStats struct {
+	ByStatus  map[string]int64 `json:"by_status"`
+	ByChannel map[string]int64 `json:"by_channel"` // Pending and failed messages only
+}
+
+// outboxQuery scopes the outbox to the current reseller's messages
+func outboxQuery(c *fiber.Ctx) *gorm.DB {
+	query := database.DB.Model(&models.OutboundMessage{})
+	user := middleware.GetCurrentUser(c)
+	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		query = query.Where("reseller_id = ?", *user.ResellerID)
+	}
+	return query
+}
+
+// List returns outbox messages, newest first
+func (h *OutboxHandler) List(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	query := outboxQuery(c)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if subscriberID := c.QueryInt("subscriber_id", 0); subscriberID > 0 {
+		query = query.Where("subscriber_id = ?", subscriberID)
+	}
+	if search := c.Query("search"); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("recipient ILIKE ? OR subject ILIKE ? OR body ILIKE ?", like, like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var messages []models.OutboundMessage
+	query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&messages)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    messages,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// Stats returns message counts by status, and by channel for undelivered messages
+func (h *OutboxHandler) Stats(c *fiber.Ctx) error {
+	type row struct {
+		Key   string
+		Count int64
+	}
+	stats := OutboxStats{ByStatus: map[string]int64{}, ByChannel: map[string]int64{}}
+
+	var rows []row
+	outboxQuery(c).Select("status AS key, COUNT(*) AS count").Group("status").Scan(&rows)
+	for _, r := range rows {
+		stats.ByStatus[r.Key] = r.Count
+	}
+
+	rows = nil
+	outboxQuery(c).Select("channel AS key, COUNT(*) AS count").
+		Where("status IN ?", []string{models.OutboundStatusPending, models.OutboundStatusFailed}).
+		Group("channel").Scan(&rows)
+	for _, r := range rows {
+		stats.ByChannel[r.Key] = r.Count
+	}
+
+	return c.JSON(fiber.Map{"success": true, "data": stats})
+}
+
+// Resend re-queues a failed or cancelled message for immediate sending
+func (h *OutboxHandler) Resend(c *fiber.Ctx) error {
+	msg, ferr := h.getMessage(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"success": false, "message": ferr.Message})
+	}
+	if msg.Status != models.OutboundStatusFailed && msg.Status != models.OutboundStatusCancelled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Only failed or cancelled messages can be resent",
+		})
+	}
+
+	requeueOutboundMessages(database.DB.Where("id = ?", msg.ID))
+
+	c.Locals("audit_description", fmt.Sprintf("Resent %s message #%d to %s", msg.Channel, msg.ID, msg.Recipient))
+	c.Locals("audit_entity_id", msg.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Message queued for resending",
+	})
+}
+
+// ResendFailed re-queues the given failed message IDs, or every failed message
+// (optionally of one channel)
+func (h *OutboxHandler) ResendFailed(c *fiber.Ctx) error {
+	var req struct {
+		IDs     []uint `json:"ids"`
+		Channel string `json:"channel"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	query := outboxQuery(c).Where("status = ?", models.OutboundStatusFailed)
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	} else if req.Channel != "" {
+		query = query.Where("channel = ?", req.Channel)
+	}
+	count := requeueOutboundMessages(query)
+
+	c.Locals("audit_description", fmt.Sprintf("Resent %d failed messages", count))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("%d messages queued for resending", count),
+		"data":    fiber.Map{"count": count},
+	})
+}
+
+// Cancel stops a pending message from being sent
+func (h *OutboxHandler) Cancel(c *fiber.Ctx) error {
+	msg, ferr := h.getMessage(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"success": false, "message": ferr.Message})
+	}
+
+	// Conditional on status so a message the workers just sent is not marked cancelled
+	result := database.DB.Model(&models.OutboundMessage{}).
+		Where("id = ? AND status = ?", msg.ID, models.OutboundStatusPending).
+		Update("status", models.OutboundStatusCancelled)
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Only pending messages can be cancelled",
+		})
+	}
+
+	c.Locals("audit_description", fmt.Sprintf("Cancelled %s message #%d to %s", msg.Channel, msg.ID, msg.Recipient))
+	c.Locals("audit_entity_id", msg.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Message cancelled",
+	})
+}
+
+// requeueOutboundMessages resets matching messages to pending with a fresh retry budget
+func requeueOutboundMessages(query *gorm.DB) int64 {
+	result := query.Model(&models.OutboundMessage{}).Updates(map[string]interface{}{
+		"status":          models.OutboundStatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+	})
+	return result.RowsAffected
+}
+
+// getMessage loads the message from :id within the caller's scope
+func (h *OutboxHandler) getMessage(c *fiber.Ctx) (*models.OutboundMessage, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid message ID")
+	}
+
+	var msg models.OutboundMessage
+	if err := outboxQuery(c).First(&msg, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Message not found")
+	}
+	return &msg, nil
+}
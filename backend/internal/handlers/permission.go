@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
 )
 
@@ -14,10 +18,19 @@ func NewPermissionHandler() *PermissionHandler {
 
 // Permission Groups
 
-// GroupWithResellers extends PermissionGroup with assigned resellers
+// GroupWithResellers extends PermissionGroup with assigned resellers and staff users
 type GroupWithResellers struct {
 	models.PermissionGroup
-	Resellers []ResellerInfo `json:"resellers"`
+	Resellers []ResellerInfo  `json:"resellers"`
+	Users     []GroupUserInfo `json:"users"`
+}
+
+// GroupUserInfo contains basic info of a staff user assigned to a group
+type GroupUserInfo struct {
+	ID       uint            `json:"id"`
+	Username string          `json:"username"`
+	FullName string          `json:"full_name"`
+	UserType models.UserType `json:"user_type"`
 }
 
 // ResellerInfo contains basic reseller info for display
@@ -52,9 +65,17 @@ func (h *PermissionHandler) ListGroups(c *fiber.Ctx) error {
 			Where("resellers.permission_group = ?", groups[i].ID).
 			Find(&resellers)
 
+		// Load staff users assigned directly to this group
+		var users []GroupUserInfo
+		database.DB.Model(&models.User{}).
+			Select("id, username, full_name, user_type").
+			Where("permission_group = ?", groups[i].ID).
+			Scan(&users)
+
 		result[i] = GroupWithResellers{
 			PermissionGroup: groups[i],
 			Resellers:       resellers,
+			Users:           users,
 		}
 	}
 
@@ -135,6 +156,8 @@ func (h *PermissionHandler) CreateGroup(c *fiber.Ctx) error {
 		}
 	}
 
+	middleware.InvalidatePermissionCache()
+
 	// Load permissions manually for response (Preload doesn't work with gorm:"-")
 	var permissions []models.Permission
 	database.DB.Table("permissions").
@@ -208,6 +231,8 @@ func (h *PermissionHandler) UpdateGroup(c *fiber.Ctx) error {
 		}
 	}
 
+	middleware.InvalidatePermissionCache()
+
 	// Load permissions manually for response
 	var permissions []models.Permission
 	database.DB.Table("permissions").
@@ -234,10 +259,11 @@ func (h *PermissionHandler) DeleteGroup(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if group is in use
-	var inUse int64
+	// Check if group is in use by resellers or staff users
+	var inUse, usersInUse int64
 	database.DB.Model(&models.Reseller{}).Where("permission_group = ?", id).Count(&inUse)
-	if inUse > 0 {
+	database.DB.Model(&models.User{}).Where("permission_group = ?", id).Count(&usersInUse)
+	if inUse+usersInUse > 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Cannot delete permission group that is in use",
@@ -247,6 +273,7 @@ func (h *PermissionHandler) DeleteGroup(c *fiber.Ctx) error {
 	// Clear permissions from junction table (Association doesn't work with gorm:"-")
 	database.DB.Exec("DELETE FROM permission_group_permissions WHERE permission_group_id = ?", group.ID)
 	database.DB.Delete(&group)
+	middleware.InvalidatePermissionCache()
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -308,6 +335,118 @@ func (h *PermissionHandler) DeletePermission(c *fiber.Ctx) error {
 	})
 }
 
+// PermissionCategory is one row group of the permission matrix ("subscribers", "nas", ...)
+type PermissionCategory struct {
+	Name        string              `json:"name"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// MatrixGroup is one column of the permission matrix
+type MatrixGroup struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	UserCount     int64  `json:"user_count"`
+	ResellerCount int64  `json:"reseller_count"`
+}
+
+// Matrix returns every permission grouped by category, every group, and which group grants what
+func (h *PermissionHandler) Matrix(c *fiber.Ctx) error {
+	var permissions []models.Permission
+	database.DB.Order("name").Find(&permissions)
+
+	categories := []PermissionCategory{}
+	index := map[string]int{}
+	for _, perm := range permissions {
+		category := perm.Name
+		if idx := strings.Index(perm.Name, "."); idx > 0 {
+			category = perm.Name[:idx]
+		}
+		i, ok := index[category]
+		if !ok {
+			i = len(categories)
+			index[category] = i
+			categories = append(categories, PermissionCategory{Name: category})
+		}
+		categories[i].Permissions = append(categories[i].Permissions, perm)
+	}
+
+	var groups []models.PermissionGroup
+	database.DB.Order("name").Find(&groups)
+
+	matrixGroups := make([]MatrixGroup, 0, len(groups))
+	for _, g := range groups {
+		mg := MatrixGroup{ID: g.ID, Name: g.Name, Description: g.Description}
+		database.DB.Model(&models.User{}).Where("permission_group = ?", g.ID).Count(&mg.UserCount)
+		database.DB.Model(&models.Reseller{}).Where("permission_group = ?", g.ID).Count(&mg.ResellerCount)
+		matrixGroups = append(matrixGroups, mg)
+	}
+
+	var rows []struct {
+		PermissionGroupID uint
+		Name              string
+	}
+	database.DB.Table("permission_group_permissions pgp").
+		Select("pgp.permission_group_id, permissions.name").
+		Joins("JOIN permissions ON permissions.id = pgp.permission_id").
+		Scan(&rows)
+
+	grants := make(map[uint][]string, len(groups))
+	for _, g := range groups {
+		grants[g.ID] = []string{}
+	}
+	for _, row := range rows {
+		grants[row.PermissionGroupID] = append(grants[row.PermissionGroupID], row.Name)
+	}
+	for id := range grants {
+		sort.Strings(grants[id])
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"categories": categories,
+			"groups":     matrixGroups,
+			"grants":     grants,
+		},
+	})
+}
+
+// Effective returns what a user can actually do: resolved permissions and resource scopes
+func (h *PermissionHandler) Effective(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	perms := middleware.GetUserPermissions(&user)
+	resellerIDs := perms.ResellerIDs
+	if resellerIDs == nil {
+		resellerIDs = []uint{}
+	}
+	nasIDs := perms.NasIDs
+	if nasIDs == nil {
+		nasIDs = []uint{}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"user_id":      user.ID,
+			"username":     user.Username,
+			"user_type":    user.UserType,
+			"all":          perms.All,
+			"group_id":     perms.GroupID,
+			"permissions":  perms.List(),
+			"reseller_ids": resellerIDs,
+			"nas_ids":      nasIDs,
+		},
+	})
+}
+
 // SeedDefaultPermissions creates default permissions (ProRadius-compatible comprehensive permissions)
 func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 	defaultPerms := []models.Permission{
@@ -388,6 +527,7 @@ func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 		{Name: "nas.delete", Description: "Delete NAS devices"},
 		{Name: "nas.sync", Description: "Sync NAS devices"},
 		{Name: "nas.test", Description: "Test NAS connection"},
+		{Name: "nas.config_restore", Description: "Push a stored config export back to a NAS"},
 
 		// ============ SESSIONS ============
 		{Name: "sessions.view", Description: "View user sessions"},
@@ -417,6 +557,8 @@ func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 		{Name: "resellers.recharge_code", Description: "Recharge voucher codes for users via API"},
 		{Name: "resellers.recharge_code_all", Description: "Recharge voucher codes for all users via API"},
 		{Name: "resellers.billing_add", Description: "Billing add reseller"},
+		{Name: "commissions.view", Description: "View commission ledger and statements"},
+		{Name: "commissions.manage", Description: "Manage commission plans and mark payouts"},
 
 		// ============ INVOICES ============
 		{Name: "invoices.view", Description: "View invoices"},
@@ -446,6 +588,8 @@ func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 		{Name: "reports.usage", Description: "View usage reports"},
 		{Name: "reports.resellers", Description: "View reseller reports"},
 		{Name: "reports.export", Description: "Export reports"},
+		{Name: "reports.schedule", Description: "Create saved and scheduled reports"},
+		{Name: "analytics.view", Description: "View churn, ARPU and cohort analytics"},
 
 		// ============ COMMUNICATION ============
 		{Name: "communication.access_module", Description: "Access communication module"},
@@ -461,6 +605,7 @@ func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 		{Name: "communication.send_whatsapp", Description: "Send WhatsApp messages"},
 		{Name: "communication.send_email", Description: "Send email messages"},
 		{Name: "communication.view_logs", Description: "View communication logs"},
+		{Name: "communication.outbox", Description: "View, resend and cancel queued messages"},
 
 		// ============ BANDWIDTH/ADJUST RULES ============
 		{Name: "bandwidth.view", Description: "View bandwidth/adjust rules"},
@@ -506,6 +651,8 @@ func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 		{Name: "users.delete", Description: "Delete admin users"},
 		{Name: "permissions.view", Description: "View permissions"},
 		{Name: "permissions.manage", Description: "Manage permission groups"},
+		{Name: "api_keys.manage", Description: "Create and revoke API keys"},
+		{Name: "webhooks.manage", Description: "Manage webhooks and replay deliveries"},
 
 		// ============ BACKUPS ============
 		{Name: "backups.view", Description: "View backups"},
@@ -527,6 +674,10 @@ func (h *PermissionHandler) SeedDefaultPermissions(c *fiber.Ctx) error {
 		{Name: "audit.view", Description: "View audit logs"},
 		{Name: "audit.export", Description: "Export audit logs"},
 
+		// ============ IP HISTORY ============
+		{Name: "ip_history.view", Description: "Look up which subscriber held an IP address"},
+		{Name: "ip_history.export", Description: "Create signed IP history exports for law-enforcement requests"},
+
 		// ============ TRANSACTIONS ============
 		{Name: "transactions.view", Description: "View user transactions"},
 		{Name: "transactions.view_all", Description: "View all user transactions"},
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/commission"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
@@ -89,6 +90,7 @@ func (h *PrepaidHandler) Generate(c *fiber.Ctx) error {
 		Count       int     `json:"count"`
 		Value       float64 `json:"value"`
 		Days        int     `json:"days"`
+		Hours       int     `json:"hours"`
 		QuotaRefill int64   `json:"quota_refill"`
 		Prefix      string  `json:"prefix"`
 		CodeLength  int     `json:"code_length"`
@@ -134,6 +136,7 @@ func (h *PrepaidHandler) Generate(c *fiber.Ctx) error {
 			PIN:         pin,
 			Value:       req.Value,
 			Days:        req.Days,
+			Hours:       req.Hours,
 			QuotaRefill: req.QuotaRefill,
 			ServiceID:   req.ServiceID,
 			ResellerID:  resellerID,
@@ -179,66 +182,112 @@ func (h *PrepaidHandler) Use(c *fiber.Ctx) error {
 		})
 	}
 
-	var card models.PrepaidCard
-	if err := database.DB.Where("code = ? AND pin = ?", req.Code, req.PIN).First(&card).Error; err != nil {
+	card, cardErr := findRedeemableCard(req.Code, req.PIN)
+	if cardErr != nil {
+		return c.Status(cardErr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": cardErr.Message,
+		})
+	}
+
+	// Get subscriber
+	var subscriber models.Subscriber
+	if err := database.DB.First(&subscriber, req.SubscriberID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
-			"message": "Invalid card code or PIN",
+			"message": "Subscriber not found",
 		})
 	}
 
-	if card.IsUsed {
+	transaction := claimPrepaidCard(card, subscriber.ID, c.IP())
+	if transaction == nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Card has already been used",
 		})
 	}
+	commission.PostAsync(*transaction)
+	applyPrepaidCard(card, &subscriber)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Card redeemed successfully",
+		"data": fiber.Map{
+			"value":       card.Value,
+			"days":        card.Days,
+			"hours":       card.Hours,
+			"quota":       card.QuotaRefill,
+		},
+	})
+}
+
+// findRedeemableCard looks up an unused, active and unexpired card by code and PIN
+func findRedeemableCard(code, pin string) (*models.PrepaidCard, *fiber.Error) {
+	var card models.PrepaidCard
+	if err := database.DB.Where("code = ? AND pin = ?", code, pin).First(&card).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Invalid card code or PIN")
+	}
+
+	if card.IsUsed {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Card has already been used")
+	}
 
 	if !card.IsActive {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"message": "Card is not active",
-		})
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Card is not active")
 	}
 
 	// Check expiry if set
 	if card.ExpiryDate != nil && card.ExpiryDate.Before(time.Now()) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"message": "Card has expired",
-		})
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Card has expired")
 	}
 
-	// Get subscriber
-	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, req.SubscriberID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"success": false,
-			"message": "Subscriber not found",
-		})
-	}
+	return &card, nil
+}
 
+// claimPrepaidCard marks the card used by the subscriber and records the redemption.
+// It returns the redemption transaction, or nil when a concurrent request already
+// claimed the card.
+func claimPrepaidCard(card *models.PrepaidCard, subscriberID uint, ip string) *models.Transaction {
 	now := time.Now()
+	result := database.DB.Model(&models.PrepaidCard{}).
+		Where("id = ? AND is_used = ?", card.ID, false).
+		Updates(map[string]interface{}{
+			"is_used": true,
+			"used_by": subscriberID,
+			"used_at": &now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil
+	}
 
-	// Update card
-	database.DB.Model(&card).Updates(map[string]interface{}{
-		"is_used": true,
-		"used_by": req.SubscriberID,
-		"used_at": &now,
-	})
+	transaction := models.Transaction{
+		ResellerID:   card.ResellerID,
+		SubscriberID: &subscriberID,
+		Type:         models.TransactionTypePrepaidCard,
+		Amount:       card.Value,
+		Description:  fmt.Sprintf("Prepaid card redeemed: %s", card.Code),
+		IPAddress:    ip,
+	}
+	database.DB.Create(&transaction)
+	return &transaction
+}
 
-	// Update subscriber
+// applyPrepaidCard adds the card's time, service and quota refill to the subscriber
+func applyPrepaidCard(card *models.PrepaidCard, subscriber *models.Subscriber) {
+	now := time.Now()
 	updates := map[string]interface{}{}
 
-	if card.Days > 0 {
-		newExpiry := subscriber.ExpiryDate
+	newExpiry := subscriber.ExpiryDate
+	if card.Days > 0 || card.Hours > 0 {
 		if newExpiry.Before(now) {
 			newExpiry = now
 		}
-		newExpiry = newExpiry.AddDate(0, 0, card.Days)
+		newExpiry = newExpiry.AddDate(0, 0, card.Days).Add(time.Duration(card.Hours) * time.Hour)
 		updates["expiry_date"] = newExpiry
+		updates["status"] = models.SubscriberStatusActive
 	}
 
+	serviceChanged := card.ServiceID > 0 && card.ServiceID != subscriber.ServiceID
 	if card.ServiceID > 0 {
 		updates["service_id"] = card.ServiceID
 	}
@@ -248,27 +297,24 @@ func (h *PrepaidHandler) Use(c *fiber.Ctx) error {
 		updates["monthly_quota_used"] = 0
 	}
 
-	database.DB.Model(&subscriber).Updates(updates)
+	database.DB.Model(subscriber).Updates(updates)
 
-	// Create transaction
-	transaction := models.Transaction{
-		ResellerID:   card.ResellerID,
-		SubscriberID: &subscriber.ID,
-		Type:         models.TransactionTypePrepaidCard,
-		Amount:       card.Value,
-		Description:  fmt.Sprintf("Prepaid card redeemed: %s", card.Code),
+	if card.Days > 0 || card.Hours > 0 {
+		database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Expiration").Delete(&models.RadCheck{})
+		database.DB.Create(&models.RadCheck{
+			Username:  subscriber.Username,
+			Attribute: "Expiration",
+			Op:        ":=",
+			Value:     newExpiry.Format("Jan 02 2006 15:04:05"),
+		})
 	}
-	database.DB.Create(&transaction)
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Card redeemed successfully",
-		"data": fiber.Map{
-			"value":       card.Value,
-			"days":        card.Days,
-			"quota":       card.QuotaRefill,
-		},
-	})
+	if serviceChanged {
+		var service models.Service
+		if database.DB.First(&service, card.ServiceID).Error == nil {
+			applyServiceRadius(subscriber.Username, &service)
+		}
+	}
 }
 
 // Delete deletes unused prepaid cards
@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/reports"
 )
 
 type ReportHandler struct{}
@@ -174,7 +175,7 @@ func (h *ReportHandler) GetResellerStats(c *fiber.Ctx) error {
 	})
 }
 
-// GetUsageStats returns bandwidth usage statistics
+// GetUsageStats returns bandwidth usage statistics from the usage rollup tables
 func (h *ReportHandler) GetUsageStats(c *fiber.Ctx) error {
 	period := c.Query("period", "day") // day, week, month
 
@@ -188,15 +189,23 @@ func (h *ReportHandler) GetUsageStats(c *fiber.Ctx) error {
 		startDate = time.Now().Truncate(24 * time.Hour)
 	}
 
+	// A day is read from the hourly rollups, longer periods from the daily ones
+	subscriberTable, nasTable, bucket := "subscriber_usage_hourly", "nas_usage_hourly", "hour"
+	var since interface{} = startDate
+	if period == "week" || period == "month" {
+		subscriberTable, nasTable, bucket = "subscriber_usage_daily", "nas_usage_daily", "day"
+		since = startDate.Format("2006-01-02")
+	}
+
 	// Total usage
 	type UsageStat struct {
 		TotalUpload   int64 `json:"total_upload"`
 		TotalDownload int64 `json:"total_download"`
 	}
 	var totalUsage UsageStat
-	database.DB.Model(&models.RadiusAccounting{}).
-		Select("COALESCE(SUM(acct_input_octets), 0) as total_upload, COALESCE(SUM(acct_output_octets), 0) as total_download").
-		Where("acct_start_time >= ?", startDate).
+	database.DB.Table(nasTable).
+		Select("COALESCE(SUM(input_octets), 0) as total_upload, COALESCE(SUM(output_octets), 0) as total_download").
+		Where(bucket+" >= ?", since).
 		Scan(&totalUsage)
 
 	// Top users by usage
@@ -206,14 +215,31 @@ func (h *ReportHandler) GetUsageStats(c *fiber.Ctx) error {
 		Download int64  `json:"download"`
 	}
 	var topUsers []TopUser
-	database.DB.Model(&models.RadiusAccounting{}).
-		Select("username, SUM(acct_input_octets) as upload, SUM(acct_output_octets) as download").
-		Where("acct_start_time >= ?", startDate).
+	database.DB.Table(subscriberTable).
+		Select("username, SUM(input_octets) as upload, SUM(output_octets) as download").
+		Where(bucket+" >= ?", since).
 		Group("username").
 		Order("download DESC").
 		Limit(20).
 		Scan(&topUsers)
 
+	// Usage per NAS
+	type NasUsage struct {
+		NasIPAddress string `json:"nas_ip_address"`
+		NasName      string `json:"nas_name"`
+		Upload       int64  `json:"upload"`
+		Download     int64  `json:"download"`
+		Sessions     int64  `json:"sessions"`
+	}
+	var nasUsage []NasUsage
+	database.DB.Table(nasTable+" u").
+		Select("u.nasipaddress as nas_ip_address, COALESCE(MAX(nas_devices.name), u.nasipaddress) as nas_name, SUM(u.input_octets) as upload, SUM(u.output_octets) as download, SUM(u.sessions) as sessions").
+		Joins("LEFT JOIN nas_devices ON nas_devices.ip_address = u.nasipaddress").
+		Where("u."+bucket+" >= ?", since).
+		Group("u.nasipaddress").
+		Order("download DESC").
+		Scan(&nasUsage)
+
 	// Hourly usage for chart
 	type HourlyUsage struct {
 		Hour     int   `json:"hour"`
@@ -221,10 +247,10 @@ func (h *ReportHandler) GetUsageStats(c *fiber.Ctx) error {
 		Download int64 `json:"download"`
 	}
 	var hourlyUsage []HourlyUsage
-	database.DB.Model(&models.RadiusAccounting{}).
-		Select("EXTRACT(HOUR FROM acct_start_time) as hour, SUM(acct_input_octets) as upload, SUM(acct_output_octets) as download").
-		Where("acct_start_time >= ?", time.Now().Truncate(24*time.Hour)).
-		Group("hour").
+	database.DB.Table("nas_usage_hourly").
+		Select("EXTRACT(HOUR FROM hour)::int as hour, SUM(input_octets) as upload, SUM(output_octets) as download").
+		Where("hour >= ?", time.Now().Truncate(24*time.Hour)).
+		Group("EXTRACT(HOUR FROM hour)").
 		Order("hour").
 		Scan(&hourlyUsage)
 
@@ -233,6 +259,7 @@ func (h *ReportHandler) GetUsageStats(c *fiber.Ctx) error {
 		"data": fiber.Map{
 			"totalUsage":  totalUsage,
 			"topUsers":    topUsers,
+			"nasUsage":    nasUsage,
 			"hourlyUsage": hourlyUsage,
 		},
 	})
@@ -373,26 +400,50 @@ func (h *ReportHandler) GetNASStats(c *fiber.Ctx) error {
 	})
 }
 
-// ExportReport exports report data in various formats
+// ExportReport renders a report as CSV, XLSX or PDF with the date range and filters
+// from the query (see reportParamsFromQuery). format=json keeps the raw record dump of
+// subscribers, transactions and invoices.
 func (h *ReportHandler) ExportReport(c *fiber.Ctx) error {
 	reportType := c.Params("type")
-	format := c.Query("format", "json") // json, csv
+	format := c.Query("format", "json") // json, csv, xlsx, pdf
+
+	if format != "json" {
+		if !reports.Valid(reportType) || !reports.ValidFormat(format) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid report type or format",
+			})
+		}
+		params, err := reportParamsFromQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": err.Error(),
+			})
+		}
+		table, err := reports.Build(reportType, params)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to build report: " + err.Error(),
+			})
+		}
+		content, contentType, err := reports.Render(table, format)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to render report: " + err.Error(),
+			})
+		}
+		c.Set("Content-Type", contentType)
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s.%s", reportType, time.Now().Format("20060102"), format))
+		return c.Send(content)
+	}
 
 	switch reportType {
 	case "subscribers":
 		var subscribers []models.Subscriber
 		database.DB.Preload("Service").Preload("Reseller").Find(&subscribers)
-		if format == "csv" {
-			c.Set("Content-Type", "text/csv")
-			c.Set("Content-Disposition", "attachment; filename=subscribers.csv")
-			// Generate CSV
-			csv := "ID,Username,FullName,Status,Service,ExpiryDate\n"
-			for _, s := range subscribers {
-				serviceName := s.Service.Name
-				csv += fmt.Sprintf("%d,%s,%s,%d,%s,%s\n", s.ID, s.Username, s.FullName, s.Status, serviceName, s.ExpiryDate.Format("2006-01-02"))
-			}
-			return c.SendString(csv)
-		}
 		return c.JSON(fiber.Map{"success": true, "data": subscribers})
 
 	case "transactions":
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/reports"
+	"github.com/proisp/backend/internal/services"
+)
+
+// ReportDefinitionRequest is the body of POST/PUT /api/reports/definitions
+type ReportDefinitionRequest struct {
+	Name         string `json:"name"`
+	ReportType   string `json:"report_type"`
+	DateRange    string `json:"date_range"` // see reports.Range, "all" or "custom"
+	DateFrom     string `json:"date_from"`  // custom range only
+	DateTo       string `json:"date_to"`
+	ResellerID   *uint  `json:"reseller_id"`
+	ServiceID    *uint  `json:"service_id"`
+	NasID        *uint  `json:"nas_id"`
+	Formats      string `json:"formats"`  // comma-separated: csv, xlsx, pdf
+	Schedule     string `json:"schedule"` // none, daily, weekly, monthly
+	ScheduleDay  int    `json:"schedule_day"`
+	ScheduleHour int    `json:"schedule_hour"`
+	Recipients   string `json:"recipients"`
+	IsActive     *bool  `json:"is_active"`
+}
+
+// reportResellerScope returns the reseller a user's reports are limited to, or 0
+func reportResellerScope(user *models.User) uint {
+	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		return *user.ResellerID
+	}
+	return 0
+}
+
+// reportParamsFromQuery reads date_range or from/to and the reseller_id, service_id and
+// nas_id filters. Resellers only ever see their own subscribers.
+func reportParamsFromQuery(c *fiber.Ctx) (reports.Params, error) {
+	var p reports.Params
+	if from := c.Query("from"); from != "" {
+		t, err := parseIPHistoryTime(from)
+		if err != nil {
+			return p, err
+		}
+		p.From = t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseIPHistoryTime(to)
+		if err != nil {
+			return p, err
+		}
+		// A bare date includes the whole day
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		p.To = t
+	}
+	if name := c.Query("date_range"); name != "" && name != "all" && name != "custom" {
+		from, to, err := reports.Range(name, time.Now())
+		if err != nil {
+			return p, err
+		}
+		p.From, p.To = from, to
+	}
+
+	p.ResellerID = uint(c.QueryInt("reseller_id", 0))
+	p.ServiceID = uint(c.QueryInt("service_id", 0))
+	p.NasID = uint(c.QueryInt("nas_id", 0))
+	if scope := reportResellerScope(middleware.GetCurrentUser(c)); scope > 0 {
+		p.ResellerID = scope
+	}
+	return p, nil
+}
+
+// applyReportDefinitionRequest validates the request and copies it onto def
+func applyReportDefinitionRequest(def *models.ReportDefinition, req *ReportDefinitionRequest) error {
+	def.Name = strings.TrimSpace(req.Name)
+	if def.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if !reports.Valid(req.ReportType) {
+		return fmt.Errorf("Unknown report type %q", req.ReportType)
+	}
+	def.ReportType = req.ReportType
+
+	def.DateRange = req.DateRange
+	if def.DateRange == "" {
+		def.DateRange = "yesterday"
+	}
+	def.DateFrom, def.DateTo = nil, nil
+	switch def.DateRange {
+	case "all":
+	case "custom":
+		if req.DateFrom == "" || req.DateTo == "" {
+			return fmt.Errorf("date_from and date_to are required for a custom range")
+		}
+		from, err := parseIPHistoryTime(req.DateFrom)
+		if err != nil {
+			return err
+		}
+		to, err := parseIPHistoryTime(req.DateTo)
+		if err != nil {
+			return err
+		}
+		def.DateFrom, def.DateTo = &from, &to
+	default:
+		if _, _, err := reports.Range(def.DateRange, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	var formats []string
+	for _, f := range strings.Split(req.Formats, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !reports.ValidFormat(f) {
+			return fmt.Errorf("Unknown format %q, use csv, xlsx or pdf", f)
+		}
+		formats = append(formats, f)
+	}
+	if len(formats) == 0 {
+		formats = []string{"csv"}
+	}
+	def.Formats = strings.Join(formats, ",")
+
+	def.Schedule = req.Schedule
+	if def.Schedule == "" {
+		def.Schedule = "none"
+	}
+	switch def.Schedule {
+	case "none", "daily":
+	case "weekly":
+		if req.ScheduleDay < 0 || req.ScheduleDay > 6 {
+			return fmt.Errorf("schedule_day must be a weekday 0-6 (0 = Sunday) for weekly reports")
+		}
+	case "monthly":
+		if req.ScheduleDay < 1 || req.ScheduleDay > 28 {
+			return fmt.Errorf("schedule_day must be 1-28 for monthly reports")
+		}
+	default:
+		return fmt.Errorf("schedule must be none, daily, weekly or monthly")
+	}
+	if req.ScheduleHour < 0 || req.ScheduleHour > 23 {
+		return fmt.Errorf("schedule_hour must be 0-23")
+	}
+	def.ScheduleDay = req.ScheduleDay
+	def.ScheduleHour = req.ScheduleHour
+
+	var recipients []string
+	for _, r := range strings.Split(req.Recipients, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(r); err != nil {
+			return fmt.Errorf("Invalid recipient %q", r)
+		}
+		recipients = append(recipients, r)
+	}
+	if def.Schedule != "none" && len(recipients) == 0 {
+		return fmt.Errorf("Scheduled reports need at least one recipient")
+	}
+	def.Recipients = strings.Join(recipients, ",")
+
+	def.ResellerID = req.ResellerID
+	def.ServiceID = req.ServiceID
+	def.NasID = req.NasID
+	if req.IsActive != nil {
+		def.IsActive = *req.IsActive
+	}
+	def.NextRunAt = services.NextReportRun(def, time.Now())
+	return nil
+}
+
+// findReportDefinition loads a definition the current user may see
+func findReportDefinition(c *fiber.Ctx) (*models.ReportDefinition, error) {
+	id, _ := c.ParamsInt("id")
+	query := database.DB.Where("id = ?", id)
+	if scope := reportResellerScope(middleware.GetCurrentUser(c)); scope > 0 {
+		query = query.Where("reseller_id = ?", scope)
+	}
+	var def models.ReportDefinition
+	if err := query.First(&def).Error; err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// ListReportTypes returns the reports, date ranges and formats that can be requested
+func (h *ReportHandler) ListReportTypes(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"types":       reports.Types(),
+			"formats":     reports.Formats,
+			"date_ranges": []string{"today", "yesterday", "last_7_days", "last_30_days", "this_month", "previous_month", "next_7_days", "next_30_days", "all", "custom"},
+			"schedules":   []string{"none", "daily", "weekly", "monthly"},
+		},
+	})
+}
+
+// ListDefinitions returns saved report definitions
+func (h *ReportHandler) ListDefinitions(c *fiber.Ctx) error {
+	query := database.DB.Model(&models.ReportDefinition{})
+	if scope := reportResellerScope(middleware.GetCurrentUser(c)); scope > 0 {
+		query = query.Where("reseller_id = ?", scope)
+	}
+
+	var defs []models.ReportDefinition
+	query.Order("name").Find(&defs)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    defs,
+	})
+}
+
+// CreateDefinition saves a report definition
+func (h *ReportHandler) CreateDefinition(c *fiber.Ctx) error {
+	var req ReportDefinitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if scope := reportResellerScope(user); scope > 0 {
+		req.ResellerID = &scope
+	}
+
+	def := models.ReportDefinition{IsActive: true}
+	if err := applyReportDefinitionRequest(&def, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+	if user != nil {
+		def.CreatedBy = user.ID
+	}
+
+	if err := database.DB.Create(&def).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save report: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Report saved",
+		"data":    def,
+	})
+}
+
+// UpdateDefinition changes a report definition and reschedules it
+func (h *ReportHandler) UpdateDefinition(c *fiber.Ctx) error {
+	def, err := findReportDefinition(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Report not found",
+		})
+	}
+
+	var req ReportDefinitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if scope := reportResellerScope(middleware.GetCurrentUser(c)); scope > 0 {
+		req.ResellerID = &scope
+	}
+
+	if err := applyReportDefinitionRequest(def, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	if err := database.DB.Save(def).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save report: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Report updated",
+		"data":    def,
+	})
+}
+
+// DeleteDefinition removes a report definition. Its stored runs are kept until the
+// retention purge.
+func (h *ReportHandler) DeleteDefinition(c *fiber.Ctx) error {
+	def, err := findReportDefinition(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Report not found",
+		})
+	}
+
+	if err := database.DB.Delete(def).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete report: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Report deleted",
+	})
+}
+
+// RunDefinition runs a report now and stores the result. With email=true the files
+// are also sent to the recipients.
+func (h *ReportHandler) RunDefinition(c *fiber.Ctx) error {
+	def, err := findReportDefinition(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Report not found",
+		})
+	}
+
+	var userID uint
+	if user := middleware.GetCurrentUser(c); user != nil {
+		userID = user.ID
+	}
+
+	runs, err := services.RunReportDefinition(def, "manual", userID, c.QueryBool("email", false))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Report failed: " + err.Error(),
+			"data":    runs,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Report generated",
+		"data":    runs,
+	})
+}
+
+// ListRuns returns stored report files, newest first
+func (h *ReportHandler) ListRuns(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 25)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 25
+	}
+
+	query := database.DB.Model(&models.ReportRun{}).Omit("content")
+	if scope := reportResellerScope(middleware.GetCurrentUser(c)); scope > 0 {
+		query = query.Where("definition_id IN (?)", database.DB.Model(&models.ReportDefinition{}).Select("id").Where("reseller_id = ?", scope))
+	}
+	if id := c.QueryInt("definition_id", 0); id > 0 {
+		query = query.Where("definition_id = ?", id)
+	}
+	if t := c.Query("report_type"); t != "" {
+		query = query.Where("report_type = ?", t)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var runs []models.ReportRun
+	query.Order("created_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    runs,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// DownloadRun returns a stored report file
+func (h *ReportHandler) DownloadRun(c *fiber.Ctx) error {
+	id, _ := c.ParamsInt("id")
+	query := database.DB.Where("id = ? AND status = ?", id, "completed")
+	if scope := reportResellerScope(middleware.GetCurrentUser(c)); scope > 0 {
+		query = query.Where("definition_id IN (?)", database.DB.Model(&models.ReportDefinition{}).Select("id").Where("reseller_id = ?", scope))
+	}
+
+	var run models.ReportRun
+	if err := query.First(&run).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Report file not found",
+		})
+	}
+
+	contentType := map[string]string{
+		"csv":  "text/csv",
+		"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"pdf":  "application/pdf",
+	}[run.Format]
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", "attachment; filename="+run.FileName)
+	return c.Send(run.Content)
+}
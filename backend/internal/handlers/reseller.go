@@ -46,6 +46,9 @@ func (h *ResellerHandler) List(c *fiber.Ctx) error {
 	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
 		query = query.Where("parent_id = ?", *user.ResellerID)
 	}
+	if resellerIDs := middleware.GetUserPermissions(user).ResellerIDs; len(resellerIDs) > 0 {
+		query = query.Where("resellers.id IN ?", resellerIDs)
+	}
 
 	// Search filter
 	if search != "" {
@@ -86,10 +89,6 @@ func (h *ResellerHandler) List(c *fiber.Ctx) error {
 	}
 
 	// Build response with subscriber counts
-	type ResellerWithCount struct {
-		models.Reseller
-		SubscriberCount int64 `json:"subscriber_count"`
-	}
 	resellersWithCounts := make([]ResellerWithCount, len(resellers))
 	for i, r := range resellers {
 		resellersWithCounts[i] = ResellerWithCount{
@@ -98,15 +97,10 @@ func (h *ResellerHandler) List(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    resellersWithCounts,
-		"meta": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
+	return c.JSON(ResellerListResponse{
+		Success: true,
+		Data:    resellersWithCounts,
+		Meta:    newListMeta(page, limit, total),
 	})
 }
 
@@ -129,40 +123,38 @@ func (h *ResellerHandler) Get(c *fiber.Ctx) error {
 	}
 
 	// Get stats
-	var stats struct {
-		TotalSubscribers  int64   `json:"total_subscribers"`
-		ActiveSubscribers int64   `json:"active_subscribers"`
-		TotalRevenue      float64 `json:"total_revenue"`
-	}
+	var stats ResellerStats
 
 	database.DB.Model(&models.Subscriber{}).Where("reseller_id = ?", id).Count(&stats.TotalSubscribers)
 	database.DB.Model(&models.Subscriber{}).Where("reseller_id = ? AND status = ?", id, models.SubscriberStatusActive).Count(&stats.ActiveSubscribers)
 	database.DB.Model(&models.Transaction{}).Where("reseller_id = ? AND type IN (?, ?)", id, models.TransactionTypeNew, models.TransactionTypeRenewal).
 		Select("COALESCE(SUM(ABS(amount)), 0)").Scan(&stats.TotalRevenue)
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    reseller,
-		"stats":   stats,
+	return c.JSON(ResellerDetailResponse{
+		Success: true,
+		Data:    reseller,
+		Stats:   stats,
 	})
 }
 
 // CreateResellerRequest represents create reseller request
 type CreateResellerRequest struct {
-	Username        string  `json:"username"`
-	Password        string  `json:"password"`
-	Email           string  `json:"email"`
-	Phone           string  `json:"phone"`
-	Name            string  `json:"name"`
-	FullName        string  `json:"fullname"`
-	Company         string  `json:"company"`
-	Address         string  `json:"address"`
-	Credit          float64 `json:"credit"`
-	CreditLimit     float64 `json:"credit_limit"`
-	Balance         float64 `json:"balance"`
-	ParentID        *uint   `json:"parent_id"`
-	PermissionGroup *uint   `json:"permission_group"`
-	IsActive        *bool   `json:"is_active"`
+	Username         string  `json:"username"`
+	Password         string  `json:"password"`
+	Email            string  `json:"email"`
+	Phone            string  `json:"phone"`
+	Name             string  `json:"name"`
+	FullName         string  `json:"fullname"`
+	Company          string  `json:"company"`
+	Address          string  `json:"address"`
+	Credit           float64 `json:"credit"`
+	CreditLimit      float64 `json:"credit_limit"`
+	Balance          float64 `json:"balance"`
+	ParentID         *uint   `json:"parent_id"`
+	PermissionGroup  *uint   `json:"permission_group"`
+	CommissionPlanID *uint   `json:"commission_plan_id"`
+	IsActive         *bool   `json:"is_active"`
+	SMSSenderID      string  `json:"sms_sender_id"`
 }
 
 // Create creates a new reseller
@@ -193,6 +185,13 @@ func (h *ResellerHandler) Create(c *fiber.Ctx) error {
 			"message": "Username and password are required",
 		})
 	}
+	req.SMSSenderID = strings.TrimSpace(req.SMSSenderID)
+	if req.SMSSenderID != "" && !smsSenderIDPattern.MatchString(req.SMSSenderID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "SMS sender ID must be up to 11 letters and digits, or a phone number",
+		})
+	}
 
 	// Use the determined name
 	req.Name = resellerName
@@ -260,16 +259,27 @@ func (h *ResellerHandler) Create(c *fiber.Ctx) error {
 		isActive = *req.IsActive
 	}
 
+	// Without an explicit group, fall back to the configured default (groups are deny-by-default)
+	permissionGroup := req.PermissionGroup
+	if permissionGroup == nil {
+		if id, err := strconv.Atoi(getSystemPreference("default_reseller_permission_group", "")); err == nil && id > 0 {
+			groupID := uint(id)
+			permissionGroup = &groupID
+		}
+	}
+
 	// Create reseller
 	reseller := models.Reseller{
-		UserID:          user.ID,
-		Name:            companyName,
-		Address:         req.Address,
-		Balance:         req.Balance,
-		Credit:          credit,
-		ParentID:        parentID,
-		PermissionGroup: req.PermissionGroup,
-		IsActive:        isActive,
+		UserID:           user.ID,
+		Name:             companyName,
+		Address:          req.Address,
+		Balance:          req.Balance,
+		Credit:           credit,
+		ParentID:         parentID,
+		PermissionGroup:  permissionGroup,
+		CommissionPlanID: req.CommissionPlanID,
+		IsActive:         isActive,
+		SMSSenderID:      req.SMSSenderID,
 	}
 
 	if err := database.DB.Create(&reseller).Error; err != nil {
@@ -300,10 +310,10 @@ func (h *ResellerHandler) Create(c *fiber.Ctx) error {
 
 	database.DB.Preload("User").First(&reseller, reseller.ID)
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"success": true,
-		"message": "Reseller created successfully",
-		"data":    reseller,
+	return c.Status(fiber.StatusCreated).JSON(ResellerResponse{
+		Success: true,
+		Message: "Reseller created successfully",
+		Data:    reseller,
 	})
 }
 
@@ -370,6 +380,15 @@ func (h *ResellerHandler) Update(c *fiber.Ctx) error {
 		} else {
 			resellerUpdates["permission_group"] = val
 		}
+		middleware.InvalidatePermissionCache()
+	}
+	if val, ok := req["commission_plan_id"]; ok {
+		if floatVal, ok := val.(float64); ok && floatVal > 0 {
+			resellerUpdates["commission_plan_id"] = uint(floatVal)
+		} else {
+			// null or 0: fall back to the default plan
+			resellerUpdates["commission_plan_id"] = nil
+		}
 	}
 	if val, ok := req["rebrand_enabled"]; ok {
 		if v, ok := val.(bool); ok {
@@ -381,6 +400,18 @@ func (h *ResellerHandler) Update(c *fiber.Ctx) error {
 			resellerUpdates["custom_domain"] = strings.TrimSpace(strings.ToLower(v))
 		}
 	}
+	if val, ok := req["sms_sender_id"]; ok {
+		if v, ok := val.(string); ok {
+			v = strings.TrimSpace(v)
+			if v != "" && !smsSenderIDPattern.MatchString(v) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"success": false,
+					"message": "SMS sender ID must be up to 11 letters and digits, or a phone number",
+				})
+			}
+			resellerUpdates["sms_sender_id"] = v
+		}
+	}
 	if len(resellerUpdates) > 0 {
 		// Use Table() to explicitly target resellers table (avoids GORM confusion with preloaded User)
 		database.DB.Table("resellers").Where("id = ?", reseller.ID).Updates(resellerUpdates)
@@ -440,10 +471,10 @@ func (h *ResellerHandler) Update(c *fiber.Ctx) error {
 
 	database.DB.Preload("User").First(&reseller, id)
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Reseller updated successfully",
-		"data":    reseller,
+	return c.JSON(ResellerResponse{
+		Success: true,
+		Message: "Reseller updated successfully",
+		Data:    reseller,
 	})
 }
 
@@ -816,7 +847,7 @@ func (h *ResellerHandler) Impersonate(c *fiber.Ctx) error {
 	}
 
 	// Generate token for the reseller's user
-	token, err := middleware.GenerateToken(reseller.User, h.cfg)
+	tokens, err := middleware.CreateSession(reseller.User, h.cfg, c.IP(), c.Get("User-Agent"), "", &currentUser.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -825,13 +856,7 @@ func (h *ResellerHandler) Impersonate(c *fiber.Ctx) error {
 	}
 
 	// Get permissions for reseller from junction table
-	var permissions []string
-	if reseller.PermissionGroup != nil {
-		database.DB.Table("permissions").
-			Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
-			Where("pgp.permission_group_id = ?", *reseller.PermissionGroup).
-			Pluck("name", &permissions)
-	}
+	permissions := getUserPermissions(reseller.User)
 
 	// Create audit log
 	resellerUsername := reseller.Name
@@ -880,9 +905,11 @@ func (h *ResellerHandler) Impersonate(c *fiber.Ctx) error {
 		"success": true,
 		"message": "Impersonation successful",
 		"data": fiber.Map{
-			"token":    token,
-			"user":     userResponse,
-			"reseller": reseller,
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+			"user":          userResponse,
+			"reseller":      reseller,
 		},
 	})
 }
@@ -1144,3 +1171,113 @@ func (h *ResellerHandler) UpdateAssignedServices(c *fiber.Ctx) error {
 		"message": "Service assignments updated successfully",
 	})
 }
+
+// canManagePortalPermissions reports whether the user may edit the reseller's portal permissions:
+// admins for any reseller, resellers for themselves and their direct sub-resellers
+func canManagePortalPermissions(user *models.User, resellerID uint) bool {
+	if user.UserType == models.UserTypeAdmin {
+		return true
+	}
+	if user.UserType != models.UserTypeReseller || user.ResellerID == nil {
+		return false
+	}
+	if *user.ResellerID == resellerID {
+		return true
+	}
+	var count int64
+	database.DB.Model(&models.Reseller{}).Where("id = ? AND parent_id = ?", resellerID, *user.ResellerID).Count(&count)
+	return count > 0
+}
+
+// GetPortalPermissions returns which customer portal actions the reseller's subscribers can use
+func (h *ResellerHandler) GetPortalPermissions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid reseller ID",
+		})
+	}
+
+	if !canManagePortalPermissions(middleware.GetCurrentUser(c), uint(id)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Access denied",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    getPortalPermissions(uint(id)),
+	})
+}
+
+// UpdatePortalPermissions saves which customer portal actions the reseller's subscribers can use
+func (h *ResellerHandler) UpdatePortalPermissions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid reseller ID",
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if !canManagePortalPermissions(user, uint(id)) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "Access denied",
+		})
+	}
+
+	var reseller models.Reseller
+	if err := database.DB.First(&reseller, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Reseller not found",
+		})
+	}
+
+	var req models.PortalPermissions
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	perms := getPortalPermissions(reseller.ID)
+	perms.AllowRenew = req.AllowRenew
+	perms.AllowWalletTopUp = req.AllowWalletTopUp
+	perms.AllowChangePlan = req.AllowChangePlan
+	perms.AllowResetFUP = req.AllowResetFUP
+	perms.AllowContactUpdate = req.AllowContactUpdate
+	perms.AllowPasswordChange = req.AllowPasswordChange
+	if err := database.DB.Save(&perms).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save portal permissions",
+		})
+	}
+
+	description := fmt.Sprintf("Updated customer portal permissions: renew=%t wallet_topup=%t change_plan=%t reset_fup=%t contact_update=%t password_change=%t",
+		perms.AllowRenew, perms.AllowWalletTopUp, perms.AllowChangePlan, perms.AllowResetFUP, perms.AllowContactUpdate, perms.AllowPasswordChange)
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionUpdate,
+		EntityType:  "reseller",
+		EntityID:    reseller.ID,
+		EntityName:  reseller.Name,
+		Description: description,
+		IPAddress:   c.IP(),
+	}
+	database.DB.Create(&auditLog)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Portal permissions updated successfully",
+		"data":    perms,
+	})
+}
@@ -314,8 +314,8 @@ func (h *ResellerWhatsAppHandler) SendToSubscribers(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "No subscribers with phone numbers found"})
 	}
 
-	// Send messages
-	sent := 0
+	// Queue messages; the outbox sends them from the reseller's account at the WhatsApp rate limit
+	queued := 0
 	failed := 0
 	var errors []string
 
@@ -324,23 +324,32 @@ func (h *ResellerWhatsAppHandler) SendToSubscribers(c *fiber.Ctx) error {
 		// Replace template variables
 		msg = replaceWAVars(msg, sub.Username, sub.FullName, reseller.Name)
 
-		if err := h.waService.SendMessageWithAccountUnique(reseller.WhatsAppAccountUnique, sub.Phone, msg); err != nil {
+		subID, resellerID := sub.ID, reseller.ID
+		if _, err := services.QueueMessage(&models.OutboundMessage{
+			Channel:      "whatsapp",
+			Recipient:    sub.Phone,
+			Body:         msg,
+			Sender:       reseller.WhatsAppAccountUnique,
+			Source:       "reseller_bulk",
+			SubscriberID: &subID,
+			ResellerID:   &resellerID,
+		}); err != nil {
 			failed++
 			if len(errors) < 5 {
 				errors = append(errors, fmt.Sprintf("%s: %v", sub.Username, err))
 			}
 		} else {
-			sent++
+			queued++
 		}
 	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
-		"sent":    sent,
+		"queued":  queued,
 		"failed":  failed,
 		"total":   len(targets),
 		"errors":  errors,
-		"message": fmt.Sprintf("Sent to %d subscribers", sent),
+		"message": fmt.Sprintf("Queued for %d subscribers", queued),
 	})
 }
 
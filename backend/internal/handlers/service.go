@@ -70,9 +70,9 @@ func (h *ServiceHandler) List(c *fiber.Ctx) error {
 			query = query.Where("id IN ?", serviceIDs)
 		} else {
 			// If no services assigned, return empty list
-			return c.JSON(fiber.Map{
-				"success": true,
-				"data":    []models.Service{},
+			return c.JSON(ServiceListResponse{
+				Success: true,
+				Data:    []models.Service{},
 			})
 		}
 	}
@@ -109,9 +109,9 @@ func (h *ServiceHandler) List(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    services,
+	return c.JSON(ServiceListResponse{
+		Success: true,
+		Data:    services,
 	})
 }
 
@@ -137,10 +137,10 @@ func (h *ServiceHandler) Get(c *fiber.Ctx) error {
 	var subscriberCount int64
 	database.DB.Model(&models.Subscriber{}).Where("service_id = ?", id).Count(&subscriberCount)
 
-	return c.JSON(fiber.Map{
-		"success":          true,
-		"data":             service,
-		"subscriber_count": subscriberCount,
+	return c.JSON(ServiceDetailResponse{
+		Success:         true,
+		Data:            service,
+		SubscriberCount: subscriberCount,
 	})
 }
 
@@ -210,6 +210,8 @@ type CreateServiceRequest struct {
 	AddressListIn    string  `json:"address_list_in"`
 	AddressListOut   string  `json:"address_list_out"`
 	QueueType        string  `json:"queue_type"`
+	IPoE             bool    `json:"ipoe"`
+	LeaseTime        int     `json:"lease_time"`
 	SortOrder        int     `json:"sort_order"`
 	// Time-based speed control
 	TimeBasedSpeedEnabled bool `json:"time_based_speed_enabled"`
@@ -318,6 +320,8 @@ func (h *ServiceHandler) Create(c *fiber.Ctx) error {
 		AddressListIn:    req.AddressListIn,
 		AddressListOut:   req.AddressListOut,
 		QueueType:        req.QueueType,
+		IPoE:             req.IPoE,
+		LeaseTime:        req.LeaseTime,
 		SortOrder:        req.SortOrder,
 		// Time-based speed control
 		TimeBasedSpeedEnabled: req.TimeBasedSpeedEnabled,
@@ -368,10 +372,10 @@ func (h *ServiceHandler) Create(c *fiber.Ctx) error {
 	}
 	database.DB.Create(&auditLog)
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"success": true,
-		"message": "Service created successfully",
-		"data":    service,
+	return c.Status(fiber.StatusCreated).JSON(ServiceResponse{
+		Success: true,
+		Message: "Service created successfully",
+		Data:    service,
 	})
 }
 
@@ -422,6 +426,7 @@ func (h *ServiceHandler) Update(c *fiber.Ctx) error {
 		"price", "day_price", "reset_price",
 		"expiry_value", "expiry_unit", "entire_month", "monthly_account",
 		"nas_id", "pool_name", "address_list_in", "address_list_out", "queue_type",
+		"ipoe", "lease_time",
 		"time_based_speed_enabled",
 		"time_from_hour", "time_from_minute", "time_to_hour", "time_to_minute",
 		"time_download_ratio", "time_upload_ratio",
@@ -469,10 +474,10 @@ func (h *ServiceHandler) Update(c *fiber.Ctx) error {
 
 	database.DB.First(&service, id)
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Service updated successfully",
-		"data":    service,
+	return c.JSON(ServiceResponse{
+		Success: true,
+		Message: "Service updated successfully",
+		Data:    service,
 	})
 }
 
@@ -10,6 +10,7 @@ import (
 	"github.com/proisp/backend/internal/mikrotik"
 	"github.com/proisp/backend/internal/models"
 	"github.com/proisp/backend/internal/radius"
+	"gorm.io/gorm"
 )
 
 type SessionHandler struct{}
@@ -34,6 +35,8 @@ type ActiveSession struct {
 	AcctInputOctets  int64      `json:"acct_input_octets"`
 	AcctOutputOctets int64      `json:"acct_output_octets"`
 	Status           string     `json:"status"`
+	ConnectionType   string     `json:"connection_type"`          // pppoe, hotspot
+	HotspotServer    string     `json:"hotspot_server,omitempty"` // Called-Station-Id of hotspot logins
 }
 
 // List returns all active sessions from radacct
@@ -43,6 +46,7 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 	status := c.Query("status", "online") // online = active sessions (no stop time)
 	nasIP := c.Query("nas_ip", "")
 	search := c.Query("search", "")
+	connectionType := c.Query("type", "") // pppoe, hotspot
 
 	if page < 1 {
 		page = 1
@@ -65,7 +69,9 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 			radacct.acctoutputoctets as acct_output_octets,
 			COALESCE(subscribers.full_name, '') as full_name,
 			COALESCE(services.name, '') as service_name,
-			COALESCE(nas_devices.name, radacct.nasipaddress) as nas_name`)
+			COALESCE(nas_devices.name, radacct.nasipaddress) as nas_name,
+			COALESCE(subscribers.connection_type, 'pppoe') as connection_type,
+			radacct.calledstationid as called_station_id`)
 
 	// Left join to get subscriber info
 	query = query.Joins("LEFT JOIN subscribers ON radacct.username = subscribers.username")
@@ -84,6 +90,11 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 		query = query.Where("radacct.nasipaddress = ?", nasIP)
 	}
 
+	// Filter by connection type
+	if connectionType != "" {
+		query = query.Where("COALESCE(subscribers.connection_type, 'pppoe') = ?", connectionType)
+	}
+
 	// Search
 	if search != "" {
 		searchPattern := "%" + search + "%"
@@ -98,6 +109,13 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 		query = query.Where(resellerFilter, *user.ResellerID, *user.ResellerID)
 	}
 
+	// Staff limited to specific resellers/NASes only see those subscribers' sessions
+	var scopedUsernames *gorm.DB
+	if user != nil && middleware.GetUserPermissions(user).Scoped() {
+		scopedUsernames = middleware.ScopeSubscriberQuery(user, database.DB.Model(&models.Subscriber{}).Select("username"), "")
+		query = query.Where("radacct.username IN (?)", scopedUsernames)
+	}
+
 	// Count total
 	var total int64
 	countQuery := database.DB.Table("radacct")
@@ -109,6 +127,9 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 	if nasIP != "" {
 		countQuery = countQuery.Where("nasipaddress = ?", nasIP)
 	}
+	if connectionType != "" {
+		countQuery = countQuery.Where("username IN (SELECT username FROM subscribers WHERE COALESCE(connection_type, 'pppoe') = ?)", connectionType)
+	}
 	if search != "" {
 		searchPattern := "%" + search + "%"
 		countQuery = countQuery.Where("username ILIKE ? OR framedipaddress ILIKE ? OR callingstationid ILIKE ?",
@@ -118,6 +139,9 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 	if user != nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
 		countQuery = countQuery.Where("username IN (SELECT username FROM subscribers WHERE reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?))", *user.ResellerID, *user.ResellerID)
 	}
+	if scopedUsernames != nil {
+		countQuery = countQuery.Where("username IN (?)", scopedUsernames)
+	}
 	countQuery.Count(&total)
 
 	// Fetch sessions
@@ -135,6 +159,8 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 		FullName         string     `gorm:"column:full_name"`
 		ServiceName      string     `gorm:"column:service_name"`
 		NASName          string     `gorm:"column:nas_name"`
+		ConnectionType   string     `gorm:"column:connection_type"`
+		CalledStationID  string     `gorm:"column:called_station_id"`
 	}
 
 	query.Order("radacct.acctstarttime DESC").Offset(offset).Limit(limit).Find(&results)
@@ -157,18 +183,17 @@ func (h *SessionHandler) List(c *fiber.Ctx) error {
 			AcctInputOctets:  r.AcctInputOctets,
 			AcctOutputOctets: r.AcctOutputOctets,
 			Status:           "online",
+			ConnectionType:   r.ConnectionType,
+		}
+		if r.ConnectionType == models.ConnectionTypeHotspot {
+			sessions[i].HotspotServer = r.CalledStationID
 		}
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    sessions,
-		"meta": fiber.Map{
-			"page":       page,
-			"limit":      limit,
-			"total":      total,
-			"totalPages": (total + int64(limit) - 1) / int64(limit),
-		},
+	return c.JSON(SessionListResponse{
+		Success: true,
+		Data:    sessions,
+		Meta:    newListMeta(page, limit, total),
 	})
 }
 
@@ -184,9 +209,9 @@ func (h *SessionHandler) Get(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    session,
+	return c.JSON(SessionResponse{
+		Success: true,
+		Data:    session,
 	})
 }
 
@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/services"
+)
+
+type SMSGatewayHandler struct{}
+
+func NewSMSGatewayHandler() *SMSGatewayHandler {
+	return &SMSGatewayHandler{}
+}
+
+// smsSenderIDPattern allows alphanumeric sender IDs of up to 11 characters or phone numbers
+var smsSenderIDPattern = regexp.MustCompile(`^([A-Za-z0-9 .\-]{1,11}|\+?[0-9]{1,15})$`)
+
+var smsGatewayProviders = map[string]bool{
+	models.SMSGatewayTwilio: true,
+	models.SMSGatewayVonage: true,
+	models.SMSGatewayCustom: true,
+	models.SMSGatewayHTTP:   true,
+	models.SMSGatewaySMPP:   true,
+}
+
+// SMSGatewayInfo is a gateway as shown to admins, with its secrets left out
+type SMSGatewayInfo struct {
+	models.SMSGateway
+	Settings  models.SMSGatewaySettings `json:"settings"`
+	HasSecret bool                      `json:"has_secret"`
+	Healthy   bool                      `json:"healthy"`
+	DLRURL    string                    `json:"dlr_url"` // Where this gateway's delivery receipts are received
+}
+
+// SMSGatewayRequest is the body of create/update; omitted fields are left unchanged on
+// update. Settings replace the stored ones, except that empty secrets keep theirs.
+type SMSGatewayRequest struct {
+	Name                string                     `json:"name"`
+	Provider            string                     `json:"provider"`
+	Priority            *int                       `json:"priority"`
+	IsActive            *bool                      `json:"is_active"`
+	SenderID            *string                    `json:"sender_id"`
+	AllowSenderOverride *bool                      `json:"allow_sender_override"`
+	Settings            *models.SMSGatewaySettings `json:"settings"`
+}
+
+// smsDLRURL is the delivery receipt URL of a gateway
+func smsDLRURL(c *fiber.Ctx, gw *models.SMSGateway) string {
+	return fmt.Sprintf("%s/api/sms/dlr/%d/%s", c.BaseURL(), gw.ID, getSystemPreference("inbound_webhook_token", ""))
+}
+
+// fillSMSDLRURL defaults the receipt callback of gateways that pass one to the
+// provider and reports whether it was set
+func fillSMSDLRURL(c *fiber.Ctx, gw *models.SMSGateway) bool {
+	settings := gw.Config()
+	if settings.DLRURL != "" || gw.Provider == models.SMSGatewaySMPP || gw.Provider == models.SMSGatewayCustom {
+		return false
+	}
+	settings.DLRURL = smsDLRURL(c, gw)
+	data, _ := json.Marshal(settings)
+	gw.Settings = string(data)
+	return true
+}
+
+func smsGatewayInfo(c *fiber.Ctx, gw *models.SMSGateway) SMSGatewayInfo {
+	settings := gw.Config()
+	hasSecret := settings.TwilioToken != "" || settings.VonageSecret != "" || settings.Password != ""
+	settings.TwilioToken, settings.VonageSecret, settings.Password = "", "", ""
+	return SMSGatewayInfo{
+		SMSGateway: *gw,
+		Settings:   settings,
+		HasSecret:  hasSecret,
+		Healthy:    gw.Healthy(time.Now()),
+		DLRURL:     smsDLRURL(c, gw),
+	}
+}
+
+// List returns all gateways in failover order
+func (h *SMSGatewayHandler) List(c *fiber.Ctx) error {
+	var gateways []models.SMSGateway
+	database.DB.Order("priority, id").Find(&gateways)
+
+	result := make([]SMSGatewayInfo, 0, len(gateways))
+	for i := range gateways {
+		result = append(result, smsGatewayInfo(c, &gateways[i]))
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// Create adds a gateway at the end of the failover order unless a priority is given
+func (h *SMSGatewayHandler) Create(c *fiber.Ctx) error {
+	var req SMSGatewayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	gw := models.SMSGateway{IsActive: true, AllowSenderOverride: true}
+	database.DB.Model(&models.SMSGateway{}).Select("COALESCE(MAX(priority), -1) + 1").Scan(&gw.Priority)
+	if msg := applySMSGatewayRequest(&gw, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	if err := database.DB.Create(&gw).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create gateway",
+		})
+	}
+	// The receipt URL contains the ID, so it can only be filled in now
+	if fillSMSDLRURL(c, &gw) {
+		database.DB.Save(&gw)
+	}
+
+	c.Locals("audit_description", fmt.Sprintf("Created SMS gateway %s (%s)", gw.Name, gw.Provider))
+	c.Locals("audit_entity_id", gw.ID)
+	c.Locals("audit_entity_name", gw.Name)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Gateway created",
+		"data":    smsGatewayInfo(c, &gw),
+	})
+}
+
+// Update changes a gateway. Its health is reset so a fixed gateway is used again at once.
+func (h *SMSGatewayHandler) Update(c *fiber.Ctx) error {
+	gw, ferr := h.getGateway(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var req SMSGatewayRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if msg := applySMSGatewayRequest(gw, &req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+	gw.ConsecutiveFailures = 0
+	gw.DisabledUntil = nil
+	fillSMSDLRURL(c, gw)
+
+	if err := database.DB.Save(gw).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to update gateway",
+		})
+	}
+	if !gw.IsActive {
+		services.CloseSMPPSession(gw.ID)
+	}
+
+	c.Locals("audit_description", fmt.Sprintf("Updated SMS gateway %s", gw.Name))
+	c.Locals("audit_entity_id", gw.ID)
+	c.Locals("audit_entity_name", gw.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Gateway updated",
+		"data":    smsGatewayInfo(c, gw),
+	})
+}
+
+// Delete removes a gateway. Its log entries keep their gateway ID but no longer get receipts.
+func (h *SMSGatewayHandler) Delete(c *fiber.Ctx) error {
+	gw, ferr := h.getGateway(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	database.DB.Delete(gw)
+	services.CloseSMPPSession(gw.ID)
+
+	c.Locals("audit_description", fmt.Sprintf("Deleted SMS gateway %s", gw.Name))
+	c.Locals("audit_entity_id", gw.ID)
+	c.Locals("audit_entity_name", gw.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Gateway deleted",
+	})
+}
+
+// Reorder sets the failover order to the given gateway IDs
+func (h *SMSGatewayHandler) Reorder(c *fiber.Ctx) error {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.BodyParser(&req); err != nil || len(req.IDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Gateway IDs are required",
+		})
+	}
+
+	for i, id := range req.IDs {
+		database.DB.Model(&models.SMSGateway{}).Where("id = ?", id).UpdateColumn("priority", i)
+	}
+
+	c.Locals("audit_description", "Reordered SMS gateway failover")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Failover order saved",
+	})
+}
+
+// Test sends a test SMS through one gateway, outside the failover chain
+func (h *SMSGatewayHandler) Test(c *fiber.Ctx) error {
+	gw, ferr := h.getGateway(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var req struct {
+		Phone    string `json:"phone"`
+		SenderID string `json:"sender_id"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Phone) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Phone number is required",
+		})
+	}
+
+	messageID, err := services.NewSMSService().SendWithGateway(gw, services.SMSMessage{
+		To:       strings.TrimSpace(req.Phone),
+		Text:     "ProxPanel Test: SMS gateway " + gw.Name + " is working correctly!",
+		SenderID: strings.TrimSpace(req.SenderID),
+	})
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"success": false,
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Test SMS sent",
+		"data":    fiber.Map{"message_id": messageID},
+	})
+}
+
+// ResetHealth clears a gateway's failure streak and cooldown
+func (h *SMSGatewayHandler) ResetHealth(c *fiber.Ctx) error {
+	gw, ferr := h.getGateway(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	database.DB.Model(gw).UpdateColumns(map[string]interface{}{
+		"consecutive_failures": 0,
+		"disabled_until":       nil,
+	})
+
+	c.Locals("audit_description", fmt.Sprintf("Reset health of SMS gateway %s", gw.Name))
+	c.Locals("audit_entity_id", gw.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Gateway back in the failover chain",
+	})
+}
+
+// DeliveryReport receives a gateway's delivery receipts (public, authenticated by
+// inbound_webhook_token) as JSON, form fields or query parameters
+func (h *SMSGatewayHandler) DeliveryReport(c *fiber.Ctx) error {
+	expected := getSystemPreference("inbound_webhook_token", "")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(c.Params("token")), []byte(expected)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"success": false, "message": "Invalid token"})
+	}
+	gw, ferr := h.getGateway(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"success": false, "message": ferr.Message})
+	}
+
+	fields := map[string]interface{}{}
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		fields[string(k)] = string(v)
+	})
+	c.Context().PostArgs().VisitAll(func(k, v []byte) {
+		fields[string(k)] = string(v)
+	})
+	if strings.Contains(c.Get("Content-Type"), "application/json") {
+		var body map[string]interface{}
+		if err := json.Unmarshal(c.Body(), &body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid request body"})
+		}
+		for k, v := range body {
+			fields[k] = v
+		}
+	}
+
+	messageID, status, errText := services.ParseSMSDeliveryReport(gw, fields)
+	updated := services.ApplySMSDeliveryReport(gw, messageID, status, errText)
+
+	return c.JSON(fiber.Map{"success": true, "updated": updated})
+}
+
+func (h *SMSGatewayHandler) getGateway(c *fiber.Ctx) (*models.SMSGateway, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid gateway ID")
+	}
+
+	var gw models.SMSGateway
+	if err := database.DB.First(&gw, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Gateway not found")
+	}
+	return &gw, nil
+}
+
+// applySMSGatewayRequest copies the request onto the gateway and validates it
+func applySMSGatewayRequest(gw *models.SMSGateway, req *SMSGatewayRequest) string {
+	if v := strings.TrimSpace(req.Name); v != "" {
+		gw.Name = v
+	}
+	if v := strings.ToLower(strings.TrimSpace(req.Provider)); v != "" {
+		gw.Provider = v
+	}
+	if req.Priority != nil {
+		gw.Priority = *req.Priority
+	}
+	if req.IsActive != nil {
+		gw.IsActive = *req.IsActive
+	}
+	if req.SenderID != nil {
+		gw.SenderID = strings.TrimSpace(*req.SenderID)
+	}
+	if req.AllowSenderOverride != nil {
+		gw.AllowSenderOverride = *req.AllowSenderOverride
+	}
+
+	settings := gw.Config()
+	if req.Settings != nil {
+		old := settings
+		settings = *req.Settings
+		if settings.TwilioToken == "" {
+			settings.TwilioToken = old.TwilioToken
+		}
+		if settings.VonageSecret == "" {
+			settings.VonageSecret = old.VonageSecret
+		}
+		if settings.Password == "" {
+			settings.Password = old.Password
+		}
+		data, _ := json.Marshal(settings)
+		gw.Settings = string(data)
+	}
+
+	if gw.Name == "" {
+		return "Name is required"
+	}
+	if !smsGatewayProviders[gw.Provider] {
+		return "Provider must be twilio, vonage, custom, http or smpp"
+	}
+	if gw.SenderID != "" && !smsSenderIDPattern.MatchString(gw.SenderID) {
+		return "Sender ID must be up to 11 letters and digits, or a phone number"
+	}
+
+	switch gw.Provider {
+	case models.SMSGatewayTwilio:
+		if settings.TwilioSID == "" || settings.TwilioToken == "" {
+			return "Twilio SID and token are required"
+		}
+	case models.SMSGatewayVonage:
+		if settings.VonageKey == "" || settings.VonageSecret == "" {
+			return "Vonage API key and secret are required"
+		}
+	case models.SMSGatewayCustom, models.SMSGatewayHTTP:
+		if settings.URL == "" {
+			return "URL is required"
+		}
+		for _, tmpl := range []string{settings.URL, settings.Body} {
+			if err := services.ParseMessageTemplate(tmpl); err != nil {
+				return "Invalid template: " + err.Error()
+			}
+		}
+		for _, pattern := range []string{settings.SuccessRegex, settings.MessageIDRegex} {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return "Invalid regular expression: " + err.Error()
+			}
+		}
+	case models.SMSGatewaySMPP:
+		if settings.Host == "" || settings.SystemID == "" {
+			return "SMPP host and system ID are required"
+		}
+		if settings.Port < 0 || settings.Port > 65535 {
+			return "Invalid SMPP port"
+		}
+	}
+	return ""
+}
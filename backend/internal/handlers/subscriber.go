@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/commission"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/ippool"
 	"github.com/proisp/backend/internal/license"
@@ -22,6 +23,7 @@ import (
 	"github.com/proisp/backend/internal/models"
 	"github.com/proisp/backend/internal/radius"
 	"github.com/proisp/backend/internal/security"
+	"github.com/proisp/backend/internal/webhook"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -35,33 +37,7 @@ func NewSubscriberHandler() *SubscriberHandler {
 // checkUserPermission checks if a user has a specific permission
 // Admins always have all permissions
 func checkUserPermission(user *models.User, permission string) bool {
-	if user.UserType == models.UserTypeAdmin {
-		return true
-	}
-
-	if user.ResellerID == nil {
-		return false
-	}
-
-	// Get reseller's permission group
-	var reseller models.Reseller
-	if err := database.DB.First(&reseller, *user.ResellerID).Error; err != nil {
-		return false
-	}
-
-	// If no permission group assigned, allow all (default behavior for backward compatibility)
-	if reseller.PermissionGroup == nil {
-		return true
-	}
-
-	// Check if permission exists in the group
-	var count int64
-	database.DB.Table("permissions").
-		Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
-		Where("pgp.permission_group_id = ? AND permissions.name = ?", *reseller.PermissionGroup, permission).
-		Count(&count)
-
-	return count > 0
+	return middleware.HasPermission(user, permission)
 }
 
 // disconnectSubscriberByCoA sends CoA disconnect to force user offline
@@ -155,6 +131,7 @@ func (h *SubscriberHandler) List(c *fiber.Ctx) error {
 			query = query.Where("reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?)", *user.ResellerID, *user.ResellerID)
 		}
 	}
+	query = middleware.ScopeSubscriberQuery(user, query, "")
 
 	// Search filter
 	if search != "" {
@@ -309,23 +286,7 @@ func (h *SubscriberHandler) List(c *fiber.Ctx) error {
 	}
 
 	// Calculate stats
-	var stats struct {
-		Total      int64 `json:"total"`
-		Online     int64 `json:"online"`
-		Offline    int64 `json:"offline"`
-		Active     int64 `json:"active"`
-		Inactive   int64 `json:"inactive"`
-		Expired    int64 `json:"expired"`
-		Expiring   int64 `json:"expiring"`
-		FUP0       int64 `json:"fup0"`
-		FUP1       int64 `json:"fup1"`
-		FUP2       int64 `json:"fup2"`
-		FUP3       int64 `json:"fup3"`
-		FUP4       int64 `json:"fup4"`
-		FUP5       int64 `json:"fup5"`
-		FUP6       int64 `json:"fup6"`
-		MonthlyFUP int64 `json:"monthly_fup"`
-	}
+	var stats SubscriberStats
 
 	// Build reseller filter condition (unless they have view_all permission)
 	resellerFilter := ""
@@ -343,7 +304,7 @@ func (h *SubscriberHandler) List(c *fiber.Ctx) error {
 		if resellerFilter != "" {
 			q = q.Where(resellerFilter, resellerID, resellerID)
 		}
-		return q
+		return middleware.ScopeSubscriberQuery(user, q, "")
 	}
 
 	filteredQuery().Count(&stats.Total)
@@ -375,18 +336,15 @@ func (h *SubscriberHandler) List(c *fiber.Ctx) error {
 		wanPort = wanPref.Value
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    subscribers,
-		"meta": fiber.Map{
-			"page":             page,
-			"limit":            limit,
-			"total":            total,
-			"totalPages":       (total + int64(limit) - 1) / int64(limit),
-			"wan_check_enabled": wanEnabled == "true" || wanEnabled == "1",
-			"wan_check_port":   wanPort,
+	return c.JSON(SubscriberListResponse{
+		Success: true,
+		Data:    subscribers,
+		Meta: SubscriberListMeta{
+			ListMeta:        newListMeta(page, limit, total),
+			WanCheckEnabled: wanEnabled == "true" || wanEnabled == "1",
+			WanCheckPort:    wanPort,
 		},
-		"stats": stats,
+		Stats: stats,
 	})
 }
 
@@ -420,6 +378,12 @@ func (h *SubscriberHandler) Get(c *fiber.Ctx) error {
 			"message": "Subscriber not found",
 		})
 	}
+	if !middleware.CanAccessSubscriber(middleware.GetCurrentUser(c), &subscriber) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Subscriber not found",
+		})
+	}
 
 	// Manually load relations (to avoid garble/GORM Preload issues)
 	if subscriber.ServiceID > 0 {
@@ -497,16 +461,15 @@ func (h *SubscriberHandler) Get(c *fiber.Ctx) error {
 	dailyDownload := make([]int64, daysInMonth)
 	dailyUpload := make([]int64, daysInMonth)
 
-	// Step 1: Seed from radacct as a baseline (for days without history records)
+	// Step 1: Seed from the daily usage rollups as a baseline (for days without history records)
 	var dailyBreakdown []struct {
 		Day         int
 		TotalInput  int64
 		TotalOutput int64
 	}
-	database.DB.Model(&models.RadAcct{}).
-		Select("EXTRACT(DAY FROM acctstarttime)::int as day, COALESCE(SUM(acctinputoctets), 0) as total_input, COALESCE(SUM(acctoutputoctets), 0) as total_output").
-		Where("username = ? AND acctstarttime >= ? AND acctstarttime < ?", subscriber.Username, startOfMonth, startOfMonth.AddDate(0, 1, 0)).
-		Group("EXTRACT(DAY FROM acctstarttime)").
+	database.DB.Model(&models.SubscriberUsageDaily{}).
+		Select("EXTRACT(DAY FROM day)::int as day, input_octets as total_input, output_octets as total_output").
+		Where("username = ? AND day >= ? AND day < ?", subscriber.Username, startOfMonth.Format("2006-01-02"), startOfMonth.AddDate(0, 1, 0).Format("2006-01-02")).
 		Scan(&dailyBreakdown)
 
 	for _, d := range dailyBreakdown {
@@ -518,8 +481,9 @@ func (h *SubscriberHandler) Get(c *fiber.Ctx) error {
 
 	// Step 2: Override past days with daily_usage_history (accurate: saved before daily reset,
 	// so sessions spanning midnight are correctly attributed to the actual usage day).
-	// radacct groups by session START time → sessions started on day N-1 but used on day N
-	// would show on the wrong bar. History records what QuotaSync actually accumulated each day.
+	// Rollups attribute traffic to the hour it was reported in, so a session's traffic
+	// between two interim updates can still land on the wrong side of midnight.
+	// History records what QuotaSync actually accumulated each day.
 	var historyRows []struct {
 		Date          time.Time
 		DownloadBytes int64
@@ -562,26 +526,26 @@ func (h *SubscriberHandler) Get(c *fiber.Ctx) error {
 	// Decrypt password for display in edit form
 	decryptedPassword := security.DecryptPassword(passwordPlain)
 
-	return c.JSON(fiber.Map{
-		"success":       true,
-		"data":          subscriber,
-		"password":      decryptedPassword,
-		"sessions":      sessions,
-		"daily_quota": fiber.Map{
-			"download_used":   dailyQuota.Download,
-			"upload_used":     dailyQuota.Upload,
-			"total_used":      dailyQuota.Total,
-			"download_limit":  downloadLimit,
-			"upload_limit":    uploadLimit,
-			"daily_download":  dailyDownload,
-			"daily_upload":    dailyUpload,
+	return c.JSON(SubscriberDetailResponse{
+		Success:  true,
+		Data:     subscriber,
+		Password: decryptedPassword,
+		Sessions: sessions,
+		DailyQuota: SubscriberDailyQuota{
+			DownloadUsed:  dailyQuota.Download,
+			UploadUsed:    dailyQuota.Upload,
+			TotalUsed:     dailyQuota.Total,
+			DownloadLimit: downloadLimit,
+			UploadLimit:   uploadLimit,
+			DailyDownload: dailyDownload,
+			DailyUpload:   dailyUpload,
 		},
-		"monthly_quota": fiber.Map{
-			"download_used":  monthlyQuota.Download,
-			"upload_used":    monthlyQuota.Upload,
-			"total_used":     monthlyQuota.Total,
-			"download_limit": monthlyDownloadLimit,
-			"upload_limit":   monthlyUploadLimit,
+		MonthlyQuota: SubscriberMonthlyQuota{
+			DownloadUsed:  monthlyQuota.Download,
+			UploadUsed:    monthlyQuota.Upload,
+			TotalUsed:     monthlyQuota.Total,
+			DownloadLimit: monthlyDownloadLimit,
+			UploadLimit:   monthlyUploadLimit,
 		},
 	})
 }
@@ -598,6 +562,7 @@ type CreateSubscriberRequest struct {
 	Building             string  `json:"building"`
 	Nationality          string  `json:"nationality"`
 	Country              string  `json:"country"`
+	Timezone             string  `json:"timezone"`
 	Note                 string  `json:"note"`
 	ServiceID            uint    `json:"service_id"`
 	ExpiryDays           int     `json:"expiry_days"`
@@ -611,6 +576,9 @@ type CreateSubscriberRequest struct {
 	StaticIP             string  `json:"static_ip"`
 	MACAddress           string  `json:"mac_address"`
 	SaveMAC              bool    `json:"save_mac"`
+	ConnectionType       string  `json:"connection_type"` // pppoe (default), hotspot, ipoe
+	CircuitID            string  `json:"circuit_id"`
+	RemoteID             string  `json:"remote_id"`
 }
 
 // GetPassword returns the password for a subscriber (requires subscribers.view permission)
@@ -634,6 +602,7 @@ func (h *SubscriberHandler) GetPassword(c *fiber.Ctx) error {
 			query = query.Where("reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?)", *user.ResellerID, *user.ResellerID)
 		}
 	}
+	query = middleware.ScopeSubscriberQuery(user, query, "")
 
 	if err := query.First(&subscriber).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
@@ -789,6 +758,7 @@ func (h *SubscriberHandler) Create(c *fiber.Ctx) error {
 		Building:             req.Building,
 		Nationality:          req.Nationality,
 		Country:              req.Country,
+		Timezone:             req.Timezone,
 		Note:                 req.Note,
 		ServiceID:            req.ServiceID,
 		Status:               models.SubscriberStatusActive,
@@ -804,6 +774,12 @@ func (h *SubscriberHandler) Create(c *fiber.Ctx) error {
 		StaticIP:             req.StaticIP,
 		MACAddress:           req.MACAddress,
 		SaveMAC:              req.SaveMAC,
+		ConnectionType:       models.ConnectionTypePPPoE,
+		CircuitID:            req.CircuitID,
+		RemoteID:             req.RemoteID,
+	}
+	if req.ConnectionType == models.ConnectionTypeHotspot || req.ConnectionType == models.ConnectionTypeIPoE {
+		subscriber.ConnectionType = req.ConnectionType
 	}
 
 	if req.OverridePrice && req.Price > 0 {
@@ -865,6 +841,7 @@ func (h *SubscriberHandler) Create(c *fiber.Ctx) error {
 			CreatedBy:    user.ID,
 		}
 		database.DB.Create(&transaction)
+		commission.PostAsync(transaction)
 	}
 
 	// Create audit log
@@ -906,11 +883,12 @@ func (h *SubscriberHandler) Create(c *fiber.Ctx) error {
 
 	// Load relations for response
 	database.DB.Preload("Service").First(&subscriber, subscriber.ID)
+	webhook.EmitSubscriber(models.WebhookEventSubscriberCreated, &subscriber, nil)
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"success": true,
-		"message": "Subscriber created successfully",
-		"data":    subscriber,
+	return c.Status(fiber.StatusCreated).JSON(SubscriberResponse{
+		Success: true,
+		Message: "Subscriber created successfully",
+		Data:    subscriber,
 	})
 }
 
@@ -926,6 +904,7 @@ func buildSubscriberChanges(old *models.Subscriber, updates map[string]interface
 		"building":              "Building",
 		"nationality":           "Nationality",
 		"country":               "Country",
+		"timezone":              "Timezone",
 		"note":                  "Note",
 		"service_id":            "Service",
 		"nas_id":                "NAS",
@@ -936,6 +915,9 @@ func buildSubscriberChanges(old *models.Subscriber, updates map[string]interface
 		"expiry_date":           "Expiry Date",
 		"auto_renew":            "Auto Renew",
 		"save_mac":              "Save MAC",
+		"connection_type":       "Connection Type",
+		"circuit_id":            "Circuit ID",
+		"remote_id":             "Remote ID",
 		"auto_recharge":         "Auto Recharge",
 		"auto_recharge_days":    "Auto Recharge Days",
 		"reseller_id":           "Reseller",
@@ -965,6 +947,8 @@ func buildSubscriberChanges(old *models.Subscriber, updates map[string]interface
 			return old.Nationality
 		case "country":
 			return old.Country
+		case "timezone":
+			return old.Timezone
 		case "note":
 			return old.Note
 		case "service_id":
@@ -994,6 +978,12 @@ func buildSubscriberChanges(old *models.Subscriber, updates map[string]interface
 			return fmt.Sprintf("%v", old.AutoRenew)
 		case "save_mac":
 			return fmt.Sprintf("%v", old.SaveMAC)
+		case "connection_type":
+			return old.ConnectionType
+		case "circuit_id":
+			return old.CircuitID
+		case "remote_id":
+			return old.RemoteID
 		case "auto_recharge":
 			return fmt.Sprintf("%v", old.AutoRecharge)
 		case "reseller_id":
@@ -1093,7 +1083,7 @@ func (h *SubscriberHandler) Update(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "Subscriber not found",
@@ -1130,11 +1120,11 @@ func (h *SubscriberHandler) Update(c *fiber.Ctx) error {
 	// Update allowed fields (username and mac_address are NOT allowed to be changed after creation)
 	allowedFields := []string{
 		"full_name", "email", "phone", "address", "region", "building",
-		"nationality", "country", "note", "service_id", "switch_id", "nas_id",
+		"nationality", "country", "timezone", "note", "service_id", "switch_id", "nas_id",
 		"latitude", "longitude", "save_mac", "auto_recharge", "auto_recharge_days",
 		"status", "static_ip", "simultaneous_sessions", "expiry_date",
 		"auto_renew", "auto_invoice", "reseller_id",
-		"price", "override_price",
+		"price", "override_price", "connection_type", "circuit_id", "remote_id",
 	}
 
 	// Store old values for RADIUS and MikroTik updates
@@ -1178,8 +1168,12 @@ func (h *SubscriberHandler) Update(c *fiber.Ctx) error {
 				if b, ok := val.(bool); ok {
 					updates[field] = b
 				}
-			case "static_ip":
-				// Allow empty string to clear static IP
+			case "connection_type":
+				if str, ok := val.(string); ok && (str == models.ConnectionTypePPPoE || str == models.ConnectionTypeHotspot || str == models.ConnectionTypeIPoE) {
+					updates[field] = str
+				}
+			case "static_ip", "circuit_id", "remote_id":
+				// Allow empty string to clear static IP / Option 82 identity
 				if str, ok := val.(string); ok {
 					updates[field] = str
 				}
@@ -1375,11 +1369,12 @@ func (h *SubscriberHandler) Update(c *fiber.Ctx) error {
 			subscriber.Reseller = &reseller
 		}
 	}
+	webhook.EmitSubscriber(models.WebhookEventSubscriberUpdated, &subscriber, nil)
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Subscriber updated successfully",
-		"data":    subscriber,
+	return c.JSON(SubscriberResponse{
+		Success: true,
+		Message: "Subscriber updated successfully",
+		Data:    subscriber,
 	})
 }
 
@@ -1394,7 +1389,7 @@ func (h *SubscriberHandler) Delete(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "Subscriber not found",
@@ -1434,6 +1429,7 @@ func (h *SubscriberHandler) Delete(c *fiber.Ctx) error {
 		UserAgent:   c.Get("User-Agent"),
 	}
 	database.DB.Create(&auditLog)
+	webhook.EmitSubscriber(models.WebhookEventSubscriberDeleted, &subscriber, nil)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -1449,7 +1445,7 @@ func (h *SubscriberHandler) Renew(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Service").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB.Preload("Service"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -1467,7 +1463,55 @@ func (h *SubscriberHandler) Renew(c *fiber.Ctx) error {
 		}
 	}
 
-	// Calculate new expiry
+	newExpiry := renewSubscriber(&subscriber)
+
+	// Deduct balance
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		database.DB.Model(&models.Reseller{}).Where("id = ?", *user.ResellerID).Update("balance", database.DB.Raw("balance - ?", subscriber.Price))
+
+		// Create transaction
+		transaction := models.Transaction{
+			Type:         models.TransactionTypeRenewal,
+			Amount:       -subscriber.Price,
+			ResellerID:   *user.ResellerID,
+			SubscriberID: &subscriber.ID,
+			Description:  fmt.Sprintf("Renewal: %s", subscriber.Username),
+			IPAddress:    c.IP(),
+			CreatedBy:    user.ID,
+		}
+		database.DB.Create(&transaction)
+		commission.PostAsync(transaction)
+	}
+
+	// Create audit log
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionRenew,
+		EntityType:  "subscriber",
+		EntityID:    subscriber.ID,
+		EntityName:  subscriber.Username,
+		Description: fmt.Sprintf("Renewed until %s", newExpiry.Format("2006-01-02")),
+		IPAddress:   c.IP(),
+	}
+	database.DB.Create(&auditLog)
+	webhook.EmitSubscriber(models.WebhookEventSubscriberRenewed, &subscriber, map[string]interface{}{
+		"renewed_by": user.Username,
+	})
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Subscriber renewed successfully",
+		"data": fiber.Map{
+			"new_expiry": newExpiry,
+		},
+	})
+}
+
+// renewSubscriber extends the subscriber by one service period, resets FUP and usage counters
+// and updates the RADIUS expiration. subscriber must have Service loaded.
+func renewSubscriber(subscriber *models.Subscriber) time.Time {
 	var newExpiry time.Time
 	if subscriber.ExpiryDate.After(time.Now()) {
 		// Add days to current expiry
@@ -1526,7 +1570,7 @@ func (h *SubscriberHandler) Renew(c *fiber.Ctx) error {
 		log.Printf("Renew: User %s is offline or no NAS, skipping session baseline update", subscriber.Username)
 	}
 
-	database.DB.Save(&subscriber)
+	database.DB.Save(subscriber)
 
 	// Update RADIUS expiration
 	database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Expiration").Delete(&models.RadCheck{})
@@ -1537,44 +1581,7 @@ func (h *SubscriberHandler) Renew(c *fiber.Ctx) error {
 		Value:     newExpiry.Format("Jan 02 2006 15:04:05"),
 	})
 
-	// Deduct balance
-	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
-		database.DB.Model(&models.Reseller{}).Where("id = ?", *user.ResellerID).Update("balance", database.DB.Raw("balance - ?", subscriber.Price))
-
-		// Create transaction
-		transaction := models.Transaction{
-			Type:         models.TransactionTypeRenewal,
-			Amount:       -subscriber.Price,
-			ResellerID:   *user.ResellerID,
-			SubscriberID: &subscriber.ID,
-			Description:  fmt.Sprintf("Renewal: %s", subscriber.Username),
-			IPAddress:    c.IP(),
-			CreatedBy:    user.ID,
-		}
-		database.DB.Create(&transaction)
-	}
-
-	// Create audit log
-	auditLog := models.AuditLog{
-		UserID:      user.ID,
-		Username:    user.Username,
-		UserType:    user.UserType,
-		Action:      models.AuditActionRenew,
-		EntityType:  "subscriber",
-		EntityID:    subscriber.ID,
-		EntityName:  subscriber.Username,
-		Description: fmt.Sprintf("Renewed until %s", newExpiry.Format("2006-01-02")),
-		IPAddress:   c.IP(),
-	}
-	database.DB.Create(&auditLog)
-
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Subscriber renewed successfully",
-		"data": fiber.Map{
-			"new_expiry": newExpiry,
-		},
-	})
+	return newExpiry
 }
 
 // Disconnect disconnects a subscriber
@@ -1585,7 +1592,7 @@ func (h *SubscriberHandler) Disconnect(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Nas").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB.Preload("Nas"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -1638,10 +1645,36 @@ func (h *SubscriberHandler) ResetFUP(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Nas").Preload("Service").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB.Preload("Nas").Preload("Service"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
+	resetSubscriberFUP(&subscriber)
+
+	// Create audit log
+	user := middleware.GetCurrentUser(c)
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionResetFUP,
+		EntityType:  "subscriber",
+		EntityID:    subscriber.ID,
+		EntityName:  subscriber.Username,
+		Description: "Reset FUP",
+		IPAddress:   c.IP(),
+	}
+	database.DB.Create(&auditLog)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "FUP reset successfully",
+	})
+}
+
+// resetSubscriberFUP clears the daily FUP level and counters and restores the service speed
+// in RADIUS and on the NAS. subscriber must have Nas and Service loaded.
+func resetSubscriberFUP(subscriber *models.Subscriber) {
 	// Reset FUP level and daily quota counters only (not monthly)
 	now := time.Now()
 	updates := map[string]interface{}{
@@ -1682,7 +1715,7 @@ func (h *SubscriberHandler) ResetFUP(c *fiber.Ctx) error {
 		updates["last_session_upload"] = 0
 	}
 
-	database.DB.Model(&subscriber).Updates(updates)
+	database.DB.Model(subscriber).Updates(updates)
 
 	// Restore original speed in RADIUS radreply table (format: upload/download for MikroTik rx/tx)
 	if subscriber.Service.ID > 0 {
@@ -1726,26 +1759,6 @@ func (h *SubscriberHandler) ResetFUP(c *fiber.Ctx) error {
 			}
 		}
 	}
-
-	// Create audit log
-	user := middleware.GetCurrentUser(c)
-	auditLog := models.AuditLog{
-		UserID:      user.ID,
-		Username:    user.Username,
-		UserType:    user.UserType,
-		Action:      models.AuditActionResetFUP,
-		EntityType:  "subscriber",
-		EntityID:    subscriber.ID,
-		EntityName:  subscriber.Username,
-		Description: "Reset FUP",
-		IPAddress:   c.IP(),
-	}
-	database.DB.Create(&auditLog)
-
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "FUP reset successfully",
-	})
 }
 
 // ResetMAC resets subscriber's MAC address
@@ -1756,7 +1769,7 @@ func (h *SubscriberHandler) ResetMAC(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -1800,7 +1813,7 @@ func (h *SubscriberHandler) ResetQuota(c *fiber.Ctx) error {
 	quotaType := c.Query("type", "both")
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -2120,6 +2133,7 @@ func (h *SubscriberHandler) BulkUpdate(c *fiber.Ctx) error {
 			query = query.Where("reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?)", *user.ResellerID, *user.ResellerID)
 		}
 	}
+	query = middleware.ScopeSubscriberQuery(user, query, "")
 
 	result := query.Updates(updates)
 	if result.Error != nil {
@@ -2232,6 +2246,7 @@ func (h *SubscriberHandler) BulkAction(c *fiber.Ctx) error {
 			query = query.Where("reseller_id IN (SELECT id FROM resellers WHERE id = ? OR parent_id = ?)", *user.ResellerID, *user.ResellerID)
 		}
 	}
+	query = middleware.ScopeSubscriberQuery(user, query, "")
 
 	var subscribers []models.Subscriber
 	if err := query.Find(&subscribers).Error; err != nil {
@@ -2292,6 +2307,7 @@ func (h *SubscriberHandler) BulkAction(c *fiber.Ctx) error {
 					CreatedBy:    user.ID,
 				}
 				database.DB.Create(&transaction)
+				commission.PostAsync(transaction)
 			}
 			// Calculate new expiry
 			var newExpiry time.Time
@@ -3178,7 +3194,7 @@ func (h *SubscriberHandler) Restore(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Unscoped().First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Unscoped(), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "Subscriber not found",
@@ -3260,7 +3276,7 @@ func (h *SubscriberHandler) PermanentDelete(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Unscoped().First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Unscoped(), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"success": false,
 			"message": "Subscriber not found",
@@ -3331,7 +3347,7 @@ func (h *SubscriberHandler) Rename(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -3409,7 +3425,7 @@ func (h *SubscriberHandler) AddDays(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -3542,7 +3558,7 @@ func (h *SubscriberHandler) CalculateChangeServicePrice(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Service").First(&subscriber, subscriberID).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Preload("Service"), "").First(&subscriber, subscriberID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -3551,6 +3567,15 @@ func (h *SubscriberHandler) CalculateChangeServicePrice(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Service not found"})
 	}
 
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    quoteServiceChange(&subscriber, &newService),
+	})
+}
+
+// quoteServiceChange prices moving a subscriber to newService for the rest of the current
+// period, using the upgrade/downgrade fee and refund preferences. subscriber must have Service loaded.
+func quoteServiceChange(subscriber *models.Subscriber, newService *models.Service) ChangeServicePriceResponse {
 	oldService := subscriber.Service
 
 	// Get system preferences
@@ -3618,23 +3643,75 @@ func (h *SubscriberHandler) CalculateChangeServicePrice(c *fiber.Ctx) error {
 	newCost = math.Round(newCost*100) / 100
 	priceDifference = math.Round(priceDifference*100) / 100
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data": ChangeServicePriceResponse{
-			RemainingDays:    remainingDays,
-			OldDayPrice:      oldDayPrice,
-			NewDayPrice:      newDayPrice,
-			OldCredit:        oldCredit,
-			NewCost:          newCost,
-			PriceDifference:  priceDifference,
-			ChangeFee:        changeFee,
-			TotalCharge:      totalCharge,
-			IsUpgrade:        isUpgrade,
-			IsDowngrade:      isDowngrade,
-			DowngradeAllowed: allowDowngrade,
-			RefundEnabled:    refundEnabled,
-		},
+	return ChangeServicePriceResponse{
+		RemainingDays:    remainingDays,
+		OldDayPrice:      oldDayPrice,
+		NewDayPrice:      newDayPrice,
+		OldCredit:        oldCredit,
+		NewCost:          newCost,
+		PriceDifference:  priceDifference,
+		ChangeFee:        changeFee,
+		TotalCharge:      totalCharge,
+		IsUpgrade:        isUpgrade,
+		IsDowngrade:      isDowngrade,
+		DowngradeAllowed: allowDowngrade,
+		RefundEnabled:    refundEnabled,
+	}
+}
+
+// applyServiceRadius points the subscriber's RADIUS replies at newService and frees the
+// current pool address so the next session picks one from the new service's pool
+func applyServiceRadius(username string, newService *models.Service) {
+	// Update RADIUS rate limit
+	database.DB.Where("username = ? AND attribute = ?", username, "Mikrotik-Rate-Limit").Delete(&models.RadReply{})
+	database.DB.Create(&models.RadReply{
+		Username:  username,
+		Attribute: "Mikrotik-Rate-Limit",
+		Op:        "=",
+		Value:     fmt.Sprintf("%s/%s", newService.UploadSpeedStr, newService.DownloadSpeedStr),
 	})
+
+	// Remove old Framed-IP-Address so user gets new IP from correct pool on reconnect
+	database.DB.Where("username = ? AND attribute = ?", username, "Framed-IP-Address").Delete(&models.RadReply{})
+	ippool.ReleaseIPForUser(username)
+	log.Printf("ChangeService: Cleared Framed-IP-Address for %s (will get new IP from pool %s)", username, newService.PoolName)
+}
+
+// disconnectAfterServiceChange drops the subscriber's session in the background so they
+// reconnect with the new service
+func disconnectAfterServiceChange(subscriber *models.Subscriber) {
+	if subscriber.NasID != nil {
+		go func(username string, nasID uint) {
+			var nas models.Nas
+			if err := database.DB.First(&nas, nasID).Error; err != nil {
+				log.Printf("ChangeService: Failed to find NAS %d for disconnect: %v", nasID, err)
+				return
+			}
+
+			log.Printf("ChangeService: Service changed for %s, disconnecting from NAS %s", username, nas.IPAddress)
+
+			// Try MikroTik API first (most reliable for PPPoE)
+			client := mikrotik.NewClient(
+				fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort),
+				nas.APIUsername,
+				nas.APIPassword,
+			)
+			if err := client.DisconnectUser(username); err != nil {
+				log.Printf("ChangeService: MikroTik API disconnect failed for %s: %v, trying CoA", username, err)
+
+				// Fallback: try CoA Disconnect-Request
+				coaClient := radius.NewCOAClient(nas.IPAddress, nas.CoAPort, nas.Secret)
+				if err := coaClient.DisconnectUser(username, ""); err != nil {
+					log.Printf("ChangeService: CoA disconnect also failed for %s: %v", username, err)
+				} else {
+					log.Printf("ChangeService: Disconnected %s via CoA", username)
+				}
+			} else {
+				log.Printf("ChangeService: Disconnected %s via MikroTik API (will reconnect with new pool)", username)
+			}
+			client.Close()
+		}(subscriber.Username, *subscriber.NasID)
+	}
 }
 
 // ChangeService changes subscriber's service plan
@@ -3663,7 +3740,7 @@ func (h *SubscriberHandler) ChangeService(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Service").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Preload("Service"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -3799,19 +3876,7 @@ func (h *SubscriberHandler) ChangeService(c *fiber.Ctx) error {
 	}
 	log.Printf("ChangeService: Subscriber updated successfully, rows affected: %d", result.RowsAffected)
 
-	// Update RADIUS rate limit
-	database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Mikrotik-Rate-Limit").Delete(&models.RadReply{})
-	database.DB.Create(&models.RadReply{
-		Username:  subscriber.Username,
-		Attribute: "Mikrotik-Rate-Limit",
-		Op:        "=",
-		Value:     fmt.Sprintf("%s/%s", newService.UploadSpeedStr, newService.DownloadSpeedStr),
-	})
-
-	// Remove old Framed-IP-Address so user gets new IP from correct pool on reconnect
-	database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Framed-IP-Address").Delete(&models.RadReply{})
-	ippool.ReleaseIPForUser(subscriber.Username)
-	log.Printf("ChangeService: Cleared Framed-IP-Address for %s (will get new IP from pool %s)", subscriber.Username, newService.PoolName)
+	applyServiceRadius(subscriber.Username, &newService)
 
 	if req.ExtendExpiry {
 		database.DB.Where("username = ? AND attribute = ?", subscriber.Username, "Expiration").Delete(&models.RadCheck{})
@@ -3868,38 +3933,7 @@ func (h *SubscriberHandler) ChangeService(c *fiber.Ctx) error {
 	// Auto-disconnect user so they reconnect with new service pool IP
 	// Reload subscriber to get NasID
 	database.DB.First(&subscriber, id)
-	if subscriber.NasID != nil {
-		go func(username string, nasID uint) {
-			var nas models.Nas
-			if err := database.DB.First(&nas, nasID).Error; err != nil {
-				log.Printf("ChangeService: Failed to find NAS %d for disconnect: %v", nasID, err)
-				return
-			}
-
-			log.Printf("ChangeService: Service changed for %s, disconnecting from NAS %s", username, nas.IPAddress)
-
-			// Try MikroTik API first (most reliable for PPPoE)
-			client := mikrotik.NewClient(
-				fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort),
-				nas.APIUsername,
-				nas.APIPassword,
-			)
-			if err := client.DisconnectUser(username); err != nil {
-				log.Printf("ChangeService: MikroTik API disconnect failed for %s: %v, trying CoA", username, err)
-
-				// Fallback: try CoA Disconnect-Request
-				coaClient := radius.NewCOAClient(nas.IPAddress, nas.CoAPort, nas.Secret)
-				if err := coaClient.DisconnectUser(username, ""); err != nil {
-					log.Printf("ChangeService: CoA disconnect also failed for %s: %v", username, err)
-				} else {
-					log.Printf("ChangeService: Disconnected %s via CoA", username)
-				}
-			} else {
-				log.Printf("ChangeService: Disconnected %s via MikroTik API (will reconnect with new pool)", username)
-			}
-			client.Close()
-		}(subscriber.Username, *subscriber.NasID)
-	}
+	disconnectAfterServiceChange(&subscriber)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -3927,7 +3961,7 @@ func (h *SubscriberHandler) Activate(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -3974,7 +4008,7 @@ func (h *SubscriberHandler) Deactivate(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Nas").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Preload("Nas"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4058,7 +4092,7 @@ func (h *SubscriberHandler) Refill(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4126,7 +4160,7 @@ func (h *SubscriberHandler) Ping(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4233,7 +4267,7 @@ func (h *SubscriberHandler) PortCheck(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4398,7 +4432,7 @@ func (h *SubscriberHandler) GetBandwidth(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Nas").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Preload("Nas"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4610,7 +4644,7 @@ func (h *SubscriberHandler) GetTorch(c *fiber.Ctx) error {
 	}
 
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Nas").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(user, database.DB.Preload("Nas"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4732,7 +4766,7 @@ func (h *SubscriberHandler) GetBandwidthRules(c *fiber.Ctx) error {
 
 	// Verify subscriber exists
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4754,7 +4788,7 @@ func (h *SubscriberHandler) GetCDNUpgrades(c *fiber.Ctx) error {
 
 	// Get subscriber with service
 	var subscriber models.Subscriber
-	if err := database.DB.Preload("Service").First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB.Preload("Service"), "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4827,7 +4861,7 @@ func (h *SubscriberHandler) CreateBandwidthRule(c *fiber.Ctx) error {
 
 	// Verify subscriber exists
 	var subscriber models.Subscriber
-	if err := database.DB.First(&subscriber, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&subscriber, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -4930,6 +4964,11 @@ func (h *SubscriberHandler) UpdateBandwidthRule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid rule ID"})
 	}
 
+	var subscriber models.Subscriber
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB.Select("id"), "").First(&subscriber, subscriberID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+
 	var rule models.SubscriberBandwidthRule
 	if err := database.DB.Where("id = ? AND subscriber_id = ?", ruleID, subscriberID).First(&rule).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Rule not found"})
@@ -5021,6 +5060,11 @@ func (h *SubscriberHandler) DeleteBandwidthRule(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": "Invalid rule ID"})
 	}
 
+	var subscriber models.Subscriber
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB.Select("id"), "").First(&subscriber, subscriberID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
+	}
+
 	result := database.DB.Where("id = ? AND subscriber_id = ?", ruleID, subscriberID).Delete(&models.SubscriberBandwidthRule{})
 	if result.RowsAffected == 0 {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"success": false, "message": "Rule not found"})
@@ -5332,7 +5376,7 @@ func (h *SubscriberHandler) SkipWanCheck(c *fiber.Ctx) error {
 	}
 
 	var sub models.Subscriber
-	if err := database.DB.First(&sub, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&sub, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
@@ -5372,7 +5416,7 @@ func (h *SubscriberHandler) RecheckWan(c *fiber.Ctx) error {
 	}
 
 	var sub models.Subscriber
-	if err := database.DB.First(&sub, id).Error; err != nil {
+	if err := middleware.ScopeSubscriberQuery(middleware.GetCurrentUser(c), database.DB, "").First(&sub, id).Error; err != nil {
 		return c.Status(404).JSON(fiber.Map{"success": false, "message": "Subscriber not found"})
 	}
 
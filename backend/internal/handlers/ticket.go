@@ -8,6 +8,7 @@ import (
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/webhook"
 )
 
 type TicketHandler struct{}
@@ -22,6 +23,22 @@ type TicketWithNotification struct {
 	HasCustomerReply bool `json:"has_customer_reply"`
 }
 
+// ticketEventData is the ticket.updated payload; change is what happened
+// (status, priority, category, assignment, subject or reply)
+func ticketEventData(ticket *models.Ticket, change string) map[string]interface{} {
+	return map[string]interface{}{
+		"ticket_id":       ticket.ID,
+		"ticket_number":   ticket.TicketNumber,
+		"ticket_subject":  ticket.Subject,
+		"ticket_status":   ticket.Status,
+		"ticket_priority": ticket.Priority,
+		"subscriber_id":   ticket.SubscriberID,
+		"reseller_id":     ticket.ResellerID,
+		"assigned_to":     ticket.AssignedTo,
+		"change":          change,
+	}
+}
+
 // List returns all tickets
 func (h *TicketHandler) List(c *fiber.Ctx) error {
 	page := c.QueryInt("page", 1)
@@ -44,6 +61,11 @@ func (h *TicketHandler) List(c *fiber.Ctx) error {
 		Preload("AssignedUser").
 		Preload("CreatedByUser")
 
+	// Staff limited to specific resellers/NASes only see those subscribers' tickets
+	if user := middleware.GetCurrentUser(c); user != nil && middleware.GetUserPermissions(user).Scoped() {
+		query = query.Where("subscriber_id IN (?)", middleware.ScopeSubscriberQuery(user, database.DB.Model(&models.Subscriber{}).Select("id"), ""))
+	}
+
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -214,9 +236,25 @@ func (h *TicketHandler) Update(c *fiber.Ctx) error {
 		updates["assigned_to"] = req.AssignedTo
 	}
 
+	change := "subject"
+	switch {
+	case req.Status != "" && req.Status != ticket.Status:
+		change = "status"
+	case req.AssignedTo != nil:
+		change = "assignment"
+	case req.Priority != "" && req.Priority != ticket.Priority:
+		change = "priority"
+	case req.Category != "":
+		change = "category"
+	}
+
 	database.DB.Model(&ticket).Updates(updates)
 	database.DB.Preload("Subscriber").Preload("AssignedUser").First(&ticket, ticket.ID)
 
+	if len(updates) > 0 {
+		webhook.Emit(models.WebhookEventTicketUpdated, ticketEventData(&ticket, change))
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    ticket,
@@ -287,6 +325,13 @@ func (h *TicketHandler) AddReply(c *fiber.Ctx) error {
 
 	database.DB.Preload("User").First(&reply, reply.ID)
 
+	// Internal notes are not announced
+	if !reply.IsInternal {
+		data := ticketEventData(&ticket, "reply")
+		data["reply"] = reply.Message
+		webhook.Emit(models.WebhookEventTicketUpdated, data)
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
 		"data":    reply,
@@ -2,30 +2,327 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"image/png"
+	"math/big"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/pquerna/otp/totp"
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/webauthn"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+const (
+	totpPendingKeyPrefix      = "proisp:2fa:pending:"
+	webauthnRegisterKeyPrefix = "proisp:webauthn:register:"
+	webauthnLoginKeyPrefix    = "proisp:webauthn:login:"
+	totpPendingTTL            = 15 * time.Minute
+	webauthnChallengeTTL      = 5 * time.Minute
+)
+
+// recoveryCodeAlphabet leaves out characters that are easy to misread (0/o, 1/l/i)
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
 type TwoFAHandler struct{}
 
 func NewTwoFAHandler() *TwoFAHandler {
 	return &TwoFAHandler{}
 }
 
-// Setup generates a new 2FA secret and returns QR code
-func (h *TwoFAHandler) Setup(c *fiber.Ctx) error {
+// webauthnChallenge is kept in Redis between the two steps of a WebAuthn ceremony
+type webauthnChallenge struct {
+	Challenge []byte `json:"challenge"`
+	RPID      string `json:"rp_id"`
+}
+
+// webauthnRPID is the relying party ID: the webauthn_rp_id preference, else the host the panel was opened on.
+// Security keys registered under one host name do not work under another.
+func webauthnRPID(c *fiber.Ctx) string {
+	if rpID := strings.TrimSpace(getSystemPreference("webauthn_rp_id", "")); rpID != "" {
+		return strings.ToLower(rpID)
+	}
+	if origin := c.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err == nil && u.Hostname() != "" {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	host := c.Hostname()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// newWebAuthnChallenge starts a ceremony and remembers its challenge under key
+func newWebAuthnChallenge(key, rpID string) ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	if err := database.CacheSet(key, webauthnChallenge{Challenge: challenge, RPID: rpID}, webauthnChallengeTTL); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// webauthnUserHandle identifies the user to the authenticator without revealing their name
+func webauthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+func webauthnDescriptors(creds []models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	descriptors := make([]webauthn.CredentialDescriptor, 0, len(creds))
+	for _, cred := range creds {
+		d := webauthn.CredentialDescriptor{Type: "public-key", ID: cred.CredentialID}
+		if cred.Transports != "" {
+			d.Transports = strings.Split(cred.Transports, ",")
+		}
+		descriptors = append(descriptors, d)
+	}
+	return descriptors
+}
+
+// normalizeRecoveryCode drops separators and case so "ABCDE-FGHJK" and "abcdefghjk" match
+func normalizeRecoveryCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(code) {
+		if strings.ContainsRune(recoveryCodeAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCodes replaces a user's recovery codes and returns the new plain codes
+func generateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, models.RecoveryCodeCount)
+	rows := make([]models.TwoFactorRecoveryCode, models.RecoveryCodeCount)
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := range codes {
+		code := make([]byte, 0, 11)
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				code = append(code, '-')
+			}
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+			code = append(code, recoveryCodeAlphabet[n.Int64()])
+		}
+		codes[i] = string(code)
+		rows[i] = models.TwoFactorRecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode burns a recovery code; each code works once
+func useRecoveryCode(userID uint, code, ip string) bool {
+	if len(normalizeRecoveryCode(code)) != 10 {
+		return false
+	}
+	result := database.DB.Model(&models.TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Updates(map[string]interface{}{"used_at": time.Now(), "used_ip": ip})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func remainingRecoveryCodes(userID uint) int64 {
+	var count int64
+	database.DB.Model(&models.TwoFactorRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+func webauthnCredentialCount(userID uint) int64 {
+	var count int64
+	database.DB.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	return count
+}
+
+// secondFactorCount is the number of enrolled authenticators (the app counts as one)
+func secondFactorCount(user *models.User) int64 {
+	count := webauthnCredentialCount(user.ID)
+	if user.TwoFactorSecret != "" {
+		count++
+	}
+	return count
+}
+
+// syncTwoFactorEnabled sets two_factor_enabled from the enrolled factors.
+// Recovery codes are only meaningful alongside a factor and go away with the last one.
+func syncTwoFactorEnabled(userID uint) bool {
+	var user models.User
+	if err := database.DB.Select("id, two_factor_secret").First(&user, userID).Error; err != nil {
+		return false
+	}
+	enabled := secondFactorCount(&user) > 0
+	database.DB.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", enabled)
+	if !enabled {
+		database.DB.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{})
+	}
+	return enabled
+}
+
+// ensureRecoveryCodes issues recovery codes on first enrollment; returns nil if the user already has some
+func ensureRecoveryCodes(userID uint) []string {
+	var count int64
+	database.DB.Model(&models.TwoFactorRecoveryCode{}).Where("user_id = ?", userID).Count(&count)
+	if count > 0 {
+		return nil
+	}
+	codes, _ := generateRecoveryCodes(userID)
+	return codes
+}
+
+// logTwoFactorChange records a change to the user's own second factors (/auth routes are not picked up by the audit middleware)
+func logTwoFactorChange(c *fiber.Ctx, user *models.User, action models.AuditAction, description string) {
+	database.DB.Create(&models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      action,
+		EntityType:  "two_factor",
+		EntityID:    user.ID,
+		EntityName:  user.Username,
+		Description: description,
+		IPAddress:   c.IP(),
+		UserAgent:   c.Get("User-Agent"),
+	})
+}
+
+// lastFactorProtected reports whether the 2FA policy forbids removing the user's only factor
+func lastFactorProtected(user *models.User) bool {
+	return middleware.TwoFactorRequired(user.UserType) && secondFactorCount(user) <= 1
+}
+
+// twoFactorLoginOptions lists the second factors a user can log in with and,
+// if they have security keys, starts a WebAuthn login ceremony
+func twoFactorLoginOptions(c *fiber.Ctx, user *models.User) ([]string, *webauthn.RequestOptions) {
+	var methods []string
+	if user.TwoFactorSecret != "" {
+		methods = append(methods, models.TwoFactorMethodTOTP)
+	}
+
+	var options *webauthn.RequestOptions
+	var creds []models.WebAuthnCredential
+	database.DB.Where("user_id = ?", user.ID).Find(&creds)
+	if len(creds) > 0 {
+		rpID := webauthnRPID(c)
+		if challenge, err := newWebAuthnChallenge(fmt.Sprintf("%s%d", webauthnLoginKeyPrefix, user.ID), rpID); err == nil {
+			methods = append(methods, models.TwoFactorMethodWebAuthn)
+			options = webauthn.NewRequestOptions(challenge, rpID, webauthnDescriptors(creds))
+		}
+	}
+
+	methods = append(methods, models.TwoFactorMethodRecovery)
+	return methods, options
+}
+
+// verifyLoginSecondFactor checks the second factor sent with a login and returns the method used
+func verifyLoginSecondFactor(c *fiber.Ctx, user *models.User, req *LoginRequest) (string, bool) {
+	switch {
+	case req.WebAuthn != nil:
+		var pending webauthnChallenge
+		if err := database.CacheTake(fmt.Sprintf("%s%d", webauthnLoginKeyPrefix, user.ID), &pending); err != nil {
+			return models.TwoFactorMethodWebAuthn, false
+		}
+		credID := req.WebAuthn.RawID
+		if credID == "" {
+			credID = req.WebAuthn.ID
+		}
+		var cred models.WebAuthnCredential
+		if err := database.DB.Where("user_id = ? AND credential_id = ?", user.ID, strings.TrimRight(credID, "=")).First(&cred).Error; err != nil {
+			return models.TwoFactorMethodWebAuthn, false
+		}
+		count, err := webauthn.VerifyAssertion(req.WebAuthn, pending.Challenge, pending.RPID, cred.PublicKey, cred.SignCount)
+		if err != nil {
+			return models.TwoFactorMethodWebAuthn, false
+		}
+		database.DB.Model(&cred).Updates(map[string]interface{}{"sign_count": count, "last_used_at": time.Now()})
+		return models.TwoFactorMethodWebAuthn, true
+
+	case req.RecoveryCode != "":
+		if !useRecoveryCode(user.ID, req.RecoveryCode, c.IP()) {
+			return models.TwoFactorMethodRecovery, false
+		}
+		logTwoFactorChange(c, user, models.AuditActionUpdate,
+			fmt.Sprintf("Used a 2FA recovery code to log in (%d left)", remainingRecoveryCodes(user.ID)))
+		return models.TwoFactorMethodRecovery, true
+	}
+
+	return models.TwoFactorMethodTOTP, user.TwoFactorSecret != "" && totp.Validate(req.TwoFACode, user.TwoFactorSecret)
+}
+
+// confirmSecondFactor accepts an authenticator app code or an unused recovery code
+func confirmSecondFactor(c *fiber.Ctx, user *models.User, code string) bool {
+	if user.TwoFactorSecret != "" && totp.Validate(code, user.TwoFactorSecret) {
+		return true
+	}
+	return useRecoveryCode(user.ID, code, c.IP())
+}
+
+// freshCurrentUser reloads the logged-in user including 2FA secrets
+func freshCurrentUser(c *fiber.Ctx) (*models.User, *fiber.Error) {
 	user := middleware.GetCurrentUser(c)
 	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	}
+	var freshUser models.User
+	if err := database.DB.First(&freshUser, user.ID).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to get user data")
+	}
+	return &freshUser, nil
+}
+
+func twoFAError(c *fiber.Ctx, err *fiber.Error) error {
+	return c.Status(err.Code).JSON(fiber.Map{
+		"success": false,
+		"message": err.Message,
+	})
+}
+
+// Setup generates a new 2FA secret and returns QR code
+func (h *TwoFAHandler) Setup(c *fiber.Ctx) error {
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
+	}
+
+	if user.TwoFactorSecret != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
-			"message": "User not found",
+			"message": "An authenticator app is already set up. Disable it first to replace it",
 		})
 	}
 
@@ -64,27 +361,29 @@ func (h *TwoFAHandler) Setup(c *fiber.Ctx) error {
 	}
 	qrBase64 := base64.StdEncoding.EncodeToString(buf.Bytes())
 
-	// Store secret temporarily (not enabled yet until verified)
-	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("two_factor_secret", key.Secret())
+	// Keep the secret aside until a code proves the app was set up; until then it must not count as a factor
+	if err := database.CacheSet(fmt.Sprintf("%s%d", totpPendingKeyPrefix, user.ID), key.Secret(), totpPendingTTL); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to store 2FA secret",
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"secret":   key.Secret(),
-			"qr_code":  "data:image/png;base64," + qrBase64,
-			"otpauth":  key.URL(),
+			"secret":  key.Secret(),
+			"qr_code": "data:image/png;base64," + qrBase64,
+			"otpauth": key.URL(),
 		},
 	})
 }
 
 // Verify verifies the 2FA code and enables 2FA
 func (h *TwoFAHandler) Verify(c *fiber.Ctx) error {
-	user := middleware.GetCurrentUser(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"success": false,
-			"message": "User not found",
-		})
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
 	}
 
 	var req struct {
@@ -104,16 +403,9 @@ func (h *TwoFAHandler) Verify(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get fresh user data with secret
-	var freshUser models.User
-	if err := database.DB.First(&freshUser, user.ID).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"message": "Failed to get user data",
-		})
-	}
-
-	if freshUser.TwoFactorSecret == "" {
+	pendingKey := fmt.Sprintf("%s%d", totpPendingKeyPrefix, user.ID)
+	var secret string
+	if err := database.CacheGet(pendingKey, &secret); err != nil || secret == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "2FA not set up. Please call setup first",
@@ -121,7 +413,7 @@ func (h *TwoFAHandler) Verify(c *fiber.Ctx) error {
 	}
 
 	// Verify the code
-	valid := totp.Validate(req.Code, freshUser.TwoFactorSecret)
+	valid := totp.Validate(req.Code, secret)
 	if !valid {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
@@ -130,27 +422,34 @@ func (h *TwoFAHandler) Verify(c *fiber.Ctx) error {
 	}
 
 	// Enable 2FA
-	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("two_factor_enabled", true)
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"two_factor_secret":  secret,
+		"two_factor_enabled": true,
+	})
+	database.CacheDelete(pendingKey)
+	codes := ensureRecoveryCodes(user.ID)
+
+	logTwoFactorChange(c, user, models.AuditActionCreate, "Enabled authenticator app 2FA")
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "2FA enabled successfully",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
 	})
 }
 
-// Disable disables 2FA for the user
+// Disable removes the authenticator app; security keys stay enrolled
 func (h *TwoFAHandler) Disable(c *fiber.Ctx) error {
-	user := middleware.GetCurrentUser(c)
-	if user == nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"success": false,
-			"message": "User not found",
-		})
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
 	}
 
 	var req struct {
 		Password string `json:"password"`
-		Code     string `json:"code"`
+		Code     string `json:"code"` // Authenticator app code or recovery code
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -159,16 +458,7 @@ func (h *TwoFAHandler) Disable(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get fresh user data
-	var freshUser models.User
-	if err := database.DB.First(&freshUser, user.ID).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"message": "Failed to get user data",
-		})
-	}
-
-	if !freshUser.TwoFactorEnabled {
+	if user.TwoFactorSecret == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "2FA is not enabled",
@@ -176,27 +466,36 @@ func (h *TwoFAHandler) Disable(c *fiber.Ctx) error {
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(freshUser.Password), []byte(req.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid password",
 		})
 	}
 
+	if lastFactorProtected(user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"success": false,
+			"message": "2FA is required for your account. Add a security key before removing the authenticator app",
+		})
+	}
+
 	// Verify 2FA code
-	valid := totp.Validate(req.Code, freshUser.TwoFactorSecret)
-	if !valid {
+	if !confirmSecondFactor(c, user, req.Code) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"success": false,
 			"message": "Invalid 2FA code",
 		})
 	}
 
-	// Disable 2FA
-	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
-		"two_factor_enabled": false,
-		"two_factor_secret":  "",
-	})
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("two_factor_secret", "")
+	enabled := syncTwoFactorEnabled(user.ID)
+
+	description := "Disabled authenticator app 2FA"
+	if !enabled {
+		description = "Disabled 2FA"
+	}
+	logTwoFactorChange(c, user, models.AuditActionDelete, description)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -206,6 +505,216 @@ func (h *TwoFAHandler) Disable(c *fiber.Ctx) error {
 
 // Status returns 2FA status for current user
 func (h *TwoFAHandler) Status(c *fiber.Ctx) error {
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"enabled":                  user.TwoFactorEnabled,
+			"totp_enabled":             user.TwoFactorSecret != "",
+			"webauthn_credentials":     webauthnCredentialCount(user.ID),
+			"recovery_codes_remaining": remainingRecoveryCodes(user.ID),
+			"required":                 middleware.TwoFactorRequired(user.UserType),
+		},
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; the old ones stop working
+func (h *TwoFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if !user.TwoFactorEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "2FA is not enabled",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid password",
+		})
+	}
+
+	codes, err := generateRecoveryCodes(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate recovery codes",
+		})
+	}
+
+	logTwoFactorChange(c, user, models.AuditActionUpdate, "Regenerated 2FA recovery codes")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "New recovery codes generated. Previous codes no longer work",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// WebAuthnRegisterBegin returns options for navigator.credentials.create()
+func (h *TwoFAHandler) WebAuthnRegisterBegin(c *fiber.Ctx) error {
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid password",
+		})
+	}
+
+	rpID := webauthnRPID(c)
+	challenge, err := newWebAuthnChallenge(fmt.Sprintf("%s%d", webauthnRegisterKeyPrefix, user.ID), rpID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to start registration",
+		})
+	}
+
+	var existing []models.WebAuthnCredential
+	database.DB.Where("user_id = ?", user.ID).Find(&existing)
+
+	rpName := database.GetCompanyName()
+	if rpName == "" {
+		rpName = "ISP Management"
+	}
+	displayName := user.FullName
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    webauthn.NewCreationOptions(challenge, rpID, rpName, webauthnUserHandle(user.ID), user.Username, displayName, webauthnDescriptors(existing)),
+	})
+}
+
+// WebAuthnRegisterFinish verifies the browser's response and stores the new security key
+func (h *TwoFAHandler) WebAuthnRegisterFinish(c *fiber.Ctx) error {
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
+	}
+
+	var req struct {
+		Name       string                       `json:"name"`
+		Credential *webauthn.CredentialResponse `json:"credential"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Credential == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	var pending webauthnChallenge
+	if err := database.CacheTake(fmt.Sprintf("%s%d", webauthnRegisterKeyPrefix, user.ID), &pending); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Registration expired, please try again",
+		})
+	}
+
+	cred, err := webauthn.VerifyRegistration(req.Credential, pending.Challenge, pending.RPID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Security key registration failed: " + err.Error(),
+		})
+	}
+
+	credentialID := webauthn.Encode(cred.ID)
+	var count int64
+	database.DB.Model(&models.WebAuthnCredential{}).Where("credential_id = ?", credentialID).Count(&count)
+	if count > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"success": false,
+			"message": "This security key is already registered",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = fmt.Sprintf("Security key %d", webauthnCredentialCount(user.ID)+1)
+	}
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	aaguid := ""
+	if len(cred.AAGUID) == 16 {
+		g := hex.EncodeToString(cred.AAGUID)
+		aaguid = g[0:8] + "-" + g[8:12] + "-" + g[12:16] + "-" + g[16:20] + "-" + g[20:32]
+	}
+	transports := strings.Join(req.Credential.Transports, ",")
+	if len(transports) > 100 {
+		transports = ""
+	}
+
+	record := models.WebAuthnCredential{
+		UserID:         user.ID,
+		Name:           name,
+		CredentialID:   credentialID,
+		PublicKey:      cred.PublicKey,
+		Algorithm:      cred.Algorithm,
+		SignCount:      cred.SignCount,
+		AAGUID:         aaguid,
+		Transports:     transports,
+		BackupEligible: cred.BackupEligible,
+	}
+	if err := database.DB.Create(&record).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to save security key",
+		})
+	}
+	syncTwoFactorEnabled(user.ID)
+	codes := ensureRecoveryCodes(user.ID)
+
+	logTwoFactorChange(c, user, models.AuditActionCreate, fmt.Sprintf("Added security key %q", name))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Security key added",
+		"data": fiber.Map{
+			"credential":     record,
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// WebAuthnCredentials lists the current user's security keys
+func (h *TwoFAHandler) WebAuthnCredentials(c *fiber.Ctx) error {
 	user := middleware.GetCurrentUser(c)
 	if user == nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -214,19 +723,147 @@ func (h *TwoFAHandler) Status(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get fresh user data
-	var freshUser models.User
-	if err := database.DB.First(&freshUser, user.ID).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+	var creds []models.WebAuthnCredential
+	database.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&creds)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    creds,
+	})
+}
+
+// WebAuthnDelete removes one of the current user's security keys
+func (h *TwoFAHandler) WebAuthnDelete(c *fiber.Ctx) error {
+	user, ferr := freshCurrentUser(c)
+	if ferr != nil {
+		return twoFAError(c, ferr)
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid password",
+		})
+	}
+
+	var cred models.WebAuthnCredential
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Params("id"), user.ID).First(&cred).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Security key not found",
+		})
+	}
+
+	if lastFactorProtected(user) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"success": false,
-			"message": "Failed to get user data",
+			"message": "2FA is required for your account. Add another security key or an authenticator app first",
 		})
 	}
 
+	database.DB.Delete(&cred)
+	syncTwoFactorEnabled(user.ID)
+
+	logTwoFactorChange(c, user, models.AuditActionDelete, fmt.Sprintf("Removed security key %q", cred.Name))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Security key removed",
+	})
+}
+
+// GetPolicy returns the user types that must use 2FA
+func (h *TwoFAHandler) GetPolicy(c *fiber.Ctx) error {
+	types, _ := middleware.ParseTwoFactorPolicy(getSystemPreference("two_factor_required_user_types", ""))
+	names := make([]models.UserType, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data": fiber.Map{
-			"enabled": freshUser.TwoFactorEnabled,
+			"required_user_types": names,
+			"webauthn_rp_id":      getSystemPreference("webauthn_rp_id", ""),
 		},
 	})
 }
+
+// UpdatePolicy sets the user types that must use 2FA
+func (h *TwoFAHandler) UpdatePolicy(c *fiber.Ctx) error {
+	var req struct {
+		RequiredUserTypes []string `json:"required_user_types"`
+		WebAuthnRPID      *string  `json:"webauthn_rp_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	value := strings.ToLower(strings.Join(req.RequiredUserTypes, ","))
+	if _, unknown := middleware.ParseTwoFactorPolicy(value); len(unknown) > 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Unknown user type: " + strings.Join(unknown, ", "),
+		})
+	}
+
+	database.DB.Model(&models.SystemPreference{}).Where("key = ?", "two_factor_required_user_types").Update("value", value)
+	if req.WebAuthnRPID != nil {
+		database.DB.Model(&models.SystemPreference{}).Where("key = ?", "webauthn_rp_id").
+			Update("value", strings.ToLower(strings.TrimSpace(*req.WebAuthnRPID)))
+	}
+	middleware.InvalidateTwoFactorPolicy()
+
+	if value == "" {
+		c.Locals("audit_description", "Removed 2FA requirement for all user types")
+	} else {
+		c.Locals("audit_description", "Set 2FA required for user types: "+value)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "2FA policy updated",
+	})
+}
+
+// ResetUser removes all second factors of a user who lost them (admin only)
+func (h *TwoFAHandler) ResetUser(c *fiber.Ctx) error {
+	var user models.User
+	if err := database.DB.First(&user, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "User not found",
+		})
+	}
+
+	database.DB.Transaction(func(tx *gorm.DB) error {
+		tx.Where("user_id = ?", user.ID).Delete(&models.WebAuthnCredential{})
+		tx.Where("user_id = ?", user.ID).Delete(&models.TwoFactorRecoveryCode{})
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_enabled": false,
+			"two_factor_secret":  "",
+		}).Error
+	})
+
+	c.Locals("audit_entity_id", user.ID)
+	c.Locals("audit_entity_name", user.Username)
+	c.Locals("audit_description", fmt.Sprintf("Reset 2FA for %s", user.Username))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "2FA has been reset. The user must enroll again at next login if required",
+	})
+}
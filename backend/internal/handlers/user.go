@@ -6,6 +6,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
 	"github.com/proisp/backend/internal/models"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -85,9 +86,15 @@ func (h *UserHandler) Get(c *fiber.Ctx) error {
 		})
 	}
 
+	resellerIDs, nasIDs := loadUserScopes(user.ID)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    user,
+		"scopes": fiber.Map{
+			"reseller_ids": resellerIDs,
+			"nas_ids":      nasIDs,
+		},
 	})
 }
 
@@ -102,6 +109,8 @@ func (h *UserHandler) Create(c *fiber.Ctx) error {
 		UserType        models.UserType  `json:"user_type"`
 		IsActive        bool             `json:"is_active"`
 		PermissionGroup *uint            `json:"permission_group"`
+		ResellerIDs     *[]uint          `json:"reseller_ids"` // Limit to these resellers (staff only)
+		NasIDs          *[]uint          `json:"nas_ids"`      // Limit to these NASes (staff only)
 	}
 
 	var req CreateRequest
@@ -156,6 +165,7 @@ func (h *UserHandler) Create(c *fiber.Ctx) error {
 			"message": "Failed to create user",
 		})
 	}
+	saveUserScopes(user.ID, req.ResellerIDs, req.NasIDs)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"success": true,
@@ -183,6 +193,8 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 		UserType        models.UserType  `json:"user_type"`
 		IsActive        bool             `json:"is_active"`
 		PermissionGroup *uint            `json:"permission_group"`
+		ResellerIDs     *[]uint          `json:"reseller_ids"`
+		NasIDs          *[]uint          `json:"nas_ids"`
 	}
 
 	var req UpdateRequest
@@ -215,6 +227,19 @@ func (h *UserHandler) Update(c *fiber.Ctx) error {
 	}
 
 	database.DB.Model(&user).Updates(updates)
+	saveUserScopes(user.ID, req.ResellerIDs, req.NasIDs)
+	middleware.InvalidatePermissionCache()
+
+	// A disabled account or a password reset ends every login of the user
+	revokedBy := ""
+	if current := middleware.GetCurrentUser(c); current != nil {
+		revokedBy = current.Username
+	}
+	if !req.IsActive {
+		middleware.RevokeUserSessions(user.ID, 0, revokedBy, models.SessionRevokeDisabled)
+	} else if req.Password != "" {
+		middleware.RevokeUserSessions(user.ID, middleware.GetCurrentSessionID(c), revokedBy, models.SessionRevokePassword)
+	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -245,6 +270,12 @@ func (h *UserHandler) Delete(c *fiber.Ctx) error {
 	}
 
 	database.DB.Delete(&user)
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.UserScope{})
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.UserIdentity{})
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.WebAuthnCredential{})
+	database.DB.Where("user_id = ?", user.ID).Delete(&models.TwoFactorRecoveryCode{})
+	middleware.InvalidatePermissionCache()
+	middleware.RevokeUserSessions(user.ID, 0, "", models.SessionRevokeDisabled)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -252,6 +283,34 @@ func (h *UserHandler) Delete(c *fiber.Ctx) error {
 	})
 }
 
+// loadUserScopes returns the resellers and NASes a staff user is limited to
+func loadUserScopes(userID uint) ([]uint, []uint) {
+	resellerIDs := []uint{}
+	nasIDs := []uint{}
+	database.DB.Model(&models.UserScope{}).
+		Where("user_id = ? AND resource_type = ?", userID, models.ScopeResourceReseller).
+		Pluck("resource_id", &resellerIDs)
+	database.DB.Model(&models.UserScope{}).
+		Where("user_id = ? AND resource_type = ?", userID, models.ScopeResourceNas).
+		Pluck("resource_id", &nasIDs)
+	return resellerIDs, nasIDs
+}
+
+// saveUserScopes replaces a user's reseller and/or NAS scopes (nil leaves that type unchanged)
+func saveUserScopes(userID uint, resellerIDs, nasIDs *[]uint) {
+	replace := func(resourceType string, ids *[]uint) {
+		if ids == nil {
+			return
+		}
+		database.DB.Where("user_id = ? AND resource_type = ?", userID, resourceType).Delete(&models.UserScope{})
+		for _, id := range *ids {
+			database.DB.Create(&models.UserScope{UserID: userID, ResourceType: resourceType, ResourceID: id})
+		}
+	}
+	replace(models.ScopeResourceReseller, resellerIDs)
+	replace(models.ScopeResourceNas, nasIDs)
+}
+
 // UpdateLastLogin updates user's last login time
 func UpdateLastLogin(userID uint) {
 	now := time.Now()
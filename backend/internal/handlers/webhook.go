@@ -0,0 +1,604 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/webhook"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct{}
+
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{}
+}
+
+type WebhookEndpointRequest struct {
+	Name           string   `json:"name"`
+	URL            string   `json:"url"`
+	Events         []string `json:"events"`
+	IsActive       *bool    `json:"is_active"`
+	MaxAttempts    int      `json:"max_attempts"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+// Events returns the event names endpoints can subscribe to
+func (h *WebhookHandler) Events(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    models.WebhookEvents,
+	})
+}
+
+// List returns the webhook endpoints the user manages with their queue counts
+func (h *WebhookHandler) List(c *fiber.Ctx) error {
+	query, ferr := webhookEndpointScope(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var endpoints []models.WebhookEndpoint
+	if err := query.Order("created_at DESC").Find(&endpoints).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to fetch webhooks",
+		})
+	}
+
+	type statusCount struct {
+		EndpointID uint
+		Status     string
+		Count      int64
+	}
+	var counts []statusCount
+	database.DB.Model(&models.WebhookDelivery{}).
+		Select("endpoint_id, status, COUNT(*) AS count").
+		Group("endpoint_id, status").
+		Scan(&counts)
+
+	stats := make(map[uint]map[string]int64)
+	for _, sc := range counts {
+		if stats[sc.EndpointID] == nil {
+			stats[sc.EndpointID] = map[string]int64{}
+		}
+		stats[sc.EndpointID][sc.Status] = sc.Count
+	}
+
+	result := make([]fiber.Map, 0, len(endpoints))
+	for _, e := range endpoints {
+		s := stats[e.ID]
+		result = append(result, fiber.Map{
+			"endpoint": e,
+			"pending":  s[models.WebhookStatusPending],
+			"success":  s[models.WebhookStatusSuccess],
+			"dead":     s[models.WebhookStatusDead],
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// Create registers an endpoint. The signing secret is only returned in this response.
+// Endpoints created by reseller users only receive that reseller's events.
+func (h *WebhookHandler) Create(c *fiber.Ctx) error {
+	user := middleware.GetCurrentUser(c)
+	resellerID, ferr := webhookReseller(user)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var req WebhookEndpointRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Name is required",
+		})
+	}
+	if msg := validateWebhookURL(req.URL); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+	events, msg := normalizeWebhookEvents(req.Events)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": msg,
+		})
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate secret",
+		})
+	}
+
+	endpoint := models.WebhookEndpoint{
+		Name:           req.Name,
+		URL:            strings.TrimSpace(req.URL),
+		Secret:         secret,
+		Events:         events,
+		ResellerID:     resellerID,
+		IsActive:       true,
+		MaxAttempts:    8,
+		TimeoutSeconds: 10,
+		CreatedBy:      user.Username,
+	}
+	if req.MaxAttempts > 0 {
+		endpoint.MaxAttempts = req.MaxAttempts
+	}
+	if req.TimeoutSeconds > 0 && req.TimeoutSeconds <= 60 {
+		endpoint.TimeoutSeconds = req.TimeoutSeconds
+	}
+	if err := database.DB.Create(&endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to create webhook",
+		})
+	}
+	webhook.InvalidateCache()
+
+	c.Locals("audit_description", fmt.Sprintf("Created webhook %s (%s) for %s", endpoint.Name, endpoint.URL, events))
+	c.Locals("audit_entity_id", endpoint.ID)
+	c.Locals("audit_entity_name", endpoint.Name)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook created. Copy the signing secret now - it will not be shown again.",
+		"secret":  secret,
+		"data":    endpoint,
+	})
+}
+
+// Update changes an endpoint's URL, events, limits or active state
+func (h *WebhookHandler) Update(c *fiber.Ctx) error {
+	endpoint, ferr := h.getEndpoint(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var req WebhookEndpointRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.URL != "" {
+		if msg := validateWebhookURL(req.URL); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": msg,
+			})
+		}
+		updates["url"] = strings.TrimSpace(req.URL)
+	}
+	if req.Events != nil {
+		events, msg := normalizeWebhookEvents(req.Events)
+		if msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"success": false,
+				"message": msg,
+			})
+		}
+		updates["events"] = events
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.MaxAttempts > 0 {
+		updates["max_attempts"] = req.MaxAttempts
+	}
+	if req.TimeoutSeconds > 0 && req.TimeoutSeconds <= 60 {
+		updates["timeout_seconds"] = req.TimeoutSeconds
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(endpoint).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"success": false,
+				"message": "Failed to update webhook",
+			})
+		}
+		webhook.InvalidateCache()
+	}
+	database.DB.First(endpoint, endpoint.ID)
+
+	c.Locals("audit_description", fmt.Sprintf("Updated webhook %s", endpoint.Name))
+	c.Locals("audit_entity_id", endpoint.ID)
+	c.Locals("audit_entity_name", endpoint.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook updated",
+		"data":    endpoint,
+	})
+}
+
+// Delete removes an endpoint and its delivery history
+func (h *WebhookHandler) Delete(c *fiber.Ctx) error {
+	endpoint, ferr := h.getEndpoint(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	database.DB.Where("endpoint_id = ?", endpoint.ID).Delete(&models.WebhookDelivery{})
+	if err := database.DB.Delete(endpoint).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to delete webhook",
+		})
+	}
+	webhook.InvalidateCache()
+
+	c.Locals("audit_description", fmt.Sprintf("Deleted webhook %s (%s)", endpoint.Name, endpoint.URL))
+	c.Locals("audit_entity_id", endpoint.ID)
+	c.Locals("audit_entity_name", endpoint.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Webhook deleted",
+	})
+}
+
+// RotateSecret issues a new signing secret. Deliveries already queued are signed with the new one.
+func (h *WebhookHandler) RotateSecret(c *fiber.Ctx) error {
+	endpoint, ferr := h.getEndpoint(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to generate secret",
+		})
+	}
+	database.DB.Model(endpoint).Update("secret", secret)
+	webhook.InvalidateCache()
+
+	c.Locals("audit_description", fmt.Sprintf("Rotated signing secret of webhook %s", endpoint.Name))
+	c.Locals("audit_entity_id", endpoint.ID)
+	c.Locals("audit_entity_name", endpoint.Name)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Signing secret rotated",
+		"secret":  secret,
+	})
+}
+
+// Test sends a webhook.test event right away and reports the endpoint's response
+func (h *WebhookHandler) Test(c *fiber.Ctx) error {
+	endpoint, ferr := h.getEndpoint(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	user := middleware.GetCurrentUser(c)
+	payload := webhook.Payload{
+		ID:        fmt.Sprintf("evt_test_%d", time.Now().UnixNano()),
+		Event:     models.WebhookEventTest,
+		CreatedAt: time.Now(),
+		Data:      fiber.Map{"message": "Test event", "sent_by": user.Username},
+	}
+	body, _ := json.Marshal(payload)
+
+	delivery := models.WebhookDelivery{
+		EndpointID:    endpoint.ID,
+		EventID:       payload.ID,
+		Event:         payload.Event,
+		Payload:       string(body),
+		Status:        models.WebhookStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := database.DB.Create(&delivery).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"success": false,
+			"message": "Failed to queue test event",
+		})
+	}
+
+	// One attempt only - a failing test should not sit in the retry queue
+	testEndpoint := *endpoint
+	testEndpoint.MaxAttempts = 1
+	if err := webhook.Deliver(&testEndpoint, &delivery); err != nil {
+		return c.JSON(fiber.Map{
+			"success": false,
+			"message": fmt.Sprintf("Delivery failed: %v", err),
+			"data":    delivery,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("Endpoint responded with HTTP %d", delivery.ResponseCode),
+		"data":    delivery,
+	})
+}
+
+// ListDeliveries returns the delivery log, filterable by endpoint, status and event
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	return h.listDeliveries(c, c.Query("status"))
+}
+
+// DeadLetters returns deliveries that exhausted their retries
+func (h *WebhookHandler) DeadLetters(c *fiber.Ctx) error {
+	return h.listDeliveries(c, models.WebhookStatusDead)
+}
+
+func (h *WebhookHandler) listDeliveries(c *fiber.Ctx, status string) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	offset := (page - 1) * limit
+
+	endpoints, ferr := webhookEndpointScope(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	query := database.DB.Model(&models.WebhookDelivery{}).
+		Where("endpoint_id IN (?)", endpoints.Model(&models.WebhookEndpoint{}).Select("id"))
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if endpointID := c.QueryInt("endpoint_id", 0); endpointID > 0 {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	query.Preload("Endpoint").Order("created_at DESC").Offset(offset).Limit(limit).Find(&deliveries)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    deliveries,
+		"meta": fiber.Map{
+			"page":       page,
+			"limit":      limit,
+			"total":      total,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// Replay re-queues a single delivery (any status) for immediate sending
+func (h *WebhookHandler) Replay(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid delivery ID",
+		})
+	}
+
+	endpoints, ferr := webhookEndpointScope(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	var delivery models.WebhookDelivery
+	if err := database.DB.Where("endpoint_id IN (?)", endpoints.Model(&models.WebhookEndpoint{}).Select("id")).
+		First(&delivery, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"success": false,
+			"message": "Delivery not found",
+		})
+	}
+
+	requeueWebhookDeliveries(database.DB.Where("id = ?", delivery.ID))
+
+	c.Locals("audit_description", fmt.Sprintf("Replayed webhook delivery #%d (%s)", delivery.ID, delivery.Event))
+	c.Locals("audit_entity_id", delivery.ID)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Delivery queued for replay",
+	})
+}
+
+// ReplayBulk re-queues the given delivery IDs, or every dead delivery (optionally of one endpoint)
+func (h *WebhookHandler) ReplayBulk(c *fiber.Ctx) error {
+	var req struct {
+		IDs        []uint `json:"ids"`
+		EndpointID uint   `json:"endpoint_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"success": false,
+			"message": "Invalid request body",
+		})
+	}
+
+	endpoints, ferr := webhookEndpointScope(c)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{
+			"success": false,
+			"message": ferr.Message,
+		})
+	}
+
+	query := database.DB.Where("endpoint_id IN (?)", endpoints.Model(&models.WebhookEndpoint{}).Select("id"))
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	} else {
+		query = query.Where("status = ?", models.WebhookStatusDead)
+		if req.EndpointID > 0 {
+			query = query.Where("endpoint_id = ?", req.EndpointID)
+		}
+	}
+	count := requeueWebhookDeliveries(query)
+
+	c.Locals("audit_description", fmt.Sprintf("Replayed %d webhook deliveries", count))
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": fmt.Sprintf("%d deliveries queued for replay", count),
+		"data":    fiber.Map{"count": count},
+	})
+}
+
+// requeueWebhookDeliveries resets matching deliveries to pending with a fresh retry budget
+func requeueWebhookDeliveries(query *gorm.DB) int64 {
+	result := query.Model(&models.WebhookDelivery{}).Updates(map[string]interface{}{
+		"status":          models.WebhookStatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"last_error":      "",
+	})
+	return result.RowsAffected
+}
+
+// getEndpoint loads the endpoint from :id among those the user manages
+func (h *WebhookHandler) getEndpoint(c *fiber.Ctx) (*models.WebhookEndpoint, *fiber.Error) {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	query, ferr := webhookEndpointScope(c)
+	if ferr != nil {
+		return nil, ferr
+	}
+
+	var endpoint models.WebhookEndpoint
+	if err := query.First(&endpoint, id).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Webhook not found")
+	}
+	return &endpoint, nil
+}
+
+// webhookReseller returns the reseller whose events the user's endpoints carry: nil for
+// admins and unscoped staff, who manage endpoints for all events, or the user's own
+// reseller. Staff limited to some resellers or NASes cannot manage webhooks, as
+// endpoints are not limited that finely.
+func webhookReseller(user *models.User) (*uint, *fiber.Error) {
+	if user.UserType == models.UserTypeAdmin {
+		return nil, nil
+	}
+	if user.ResellerID != nil {
+		return user.ResellerID, nil
+	}
+	if middleware.GetUserPermissions(user).Scoped() {
+		return nil, fiber.NewError(fiber.StatusForbidden, "Webhooks cannot be managed by users limited to specific resellers or NAS devices")
+	}
+	return nil, nil
+}
+
+// webhookEndpointScope returns a query over the endpoints the user manages
+func webhookEndpointScope(c *fiber.Ctx) (*gorm.DB, *fiber.Error) {
+	user := middleware.GetCurrentUser(c)
+	resellerID, ferr := webhookReseller(user)
+	if ferr != nil {
+		return nil, ferr
+	}
+	query := database.DB
+	if resellerID != nil {
+		query = query.Where("reseller_id = ?", *resellerID)
+	}
+	return query, nil
+}
+
+// validateWebhookURL requires an absolute http(s) URL
+func validateWebhookURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "URL must be an absolute http:// or https:// address"
+	}
+	return ""
+}
+
+// normalizeWebhookEvents checks event names and joins them. Accepts "*", "<group>.*" and exact names.
+func normalizeWebhookEvents(events []string) (string, string) {
+	known := make(map[string]bool, len(models.WebhookEvents))
+	groups := make(map[string]bool)
+	for _, name := range models.WebhookEvents {
+		known[name] = true
+		groups[name[:strings.Index(name, ".")]] = true
+	}
+
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		switch {
+		case event == "":
+			continue
+		case event == "*":
+		case strings.HasSuffix(event, ".*"):
+			if !groups[strings.TrimSuffix(event, ".*")] {
+				return "", fmt.Sprintf("Unknown event group: %s", event)
+			}
+		case !known[event]:
+			return "", fmt.Sprintf("Unknown event: %s", event)
+		}
+		result = append(result, event)
+	}
+	if len(result) == 0 {
+		return "", "At least one event is required"
+	}
+	return strings.Join(result, ","), ""
+}
@@ -0,0 +1,89 @@
+// Package iphistory signs the IP assignment exports handed to law enforcement, so a
+// recipient can verify a file was produced by this system and not altered since.
+package iphistory
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/proisp/backend/internal/database"
+)
+
+const signingKeyPreference = "ip_history_signing_key"
+
+var (
+	keyMu      sync.Mutex
+	privateKey ed25519.PrivateKey
+)
+
+// signingKey loads the Ed25519 key from system_preferences, generating and persisting
+// one on first use. The key never leaves the database except as signatures.
+func signingKey() (ed25519.PrivateKey, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+
+	if privateKey != nil {
+		return privateKey, nil
+	}
+	if database.DB == nil {
+		return nil, errors.New("database not connected")
+	}
+
+	var pref database.SystemPreference
+	if err := database.DB.Where("key = ?", signingKeyPreference).First(&pref).Error; err == nil && pref.Value != "" {
+		seed, err := hex.DecodeString(pref.Value)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("stored IP history signing key is invalid")
+		}
+		privateKey = ed25519.NewKeyFromSeed(seed)
+		return privateKey, nil
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	pref = database.SystemPreference{Key: signingKeyPreference, Value: hex.EncodeToString(seed), ValueType: "string"}
+	if err := database.DB.Create(&pref).Error; err != nil {
+		// Another instance created it first; use theirs
+		if err := database.DB.Where("key = ?", signingKeyPreference).First(&pref).Error; err != nil {
+			return nil, err
+		}
+		if seed, err = hex.DecodeString(pref.Value); err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("stored IP history signing key is invalid")
+		}
+	}
+	privateKey = ed25519.NewKeyFromSeed(seed)
+	return privateKey, nil
+}
+
+// Digest returns the hex SHA-256 of content
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign returns the base64 Ed25519 signature of content
+func Sign(content []byte) (string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, content)), nil
+}
+
+// PublicKey returns the base64 public key and its SHA-256 fingerprint, for recipients
+// verifying signatures
+func PublicKey() (string, string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", "", err
+	}
+	public := key.Public().(ed25519.PublicKey)
+	return base64.StdEncoding.EncodeToString(public), Digest(public), nil
+}
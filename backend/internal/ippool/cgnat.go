@@ -0,0 +1,167 @@
+package ippool
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// MaxCGNATPrivateAddresses caps the private side of a pool; every address costs three
+// src-nat rules on the router
+const MaxCGNATPrivateAddresses = 16384
+
+// parseCGNATRanges expands comma-separated ranges ("a-b", CIDR or single addresses)
+// in the order given
+func parseCGNATRanges(ranges string) ([]uint32, error) {
+	var ips []uint32
+	for _, r := range strings.Split(ranges, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+
+		var start, end net.IP
+		switch {
+		case strings.Contains(r, "/"):
+			_, network, err := net.ParseCIDR(r)
+			if err != nil || network.IP.To4() == nil {
+				return nil, fmt.Errorf("invalid CIDR: %s", r)
+			}
+			start = network.IP.To4()
+			end = make(net.IP, 4)
+			for i := range start {
+				end[i] = start[i] | ^network.Mask[i]
+			}
+		case strings.Contains(r, "-"):
+			parts := strings.SplitN(r, "-", 2)
+			start = net.ParseIP(strings.TrimSpace(parts[0])).To4()
+			end = net.ParseIP(strings.TrimSpace(parts[1])).To4()
+		default:
+			start = net.ParseIP(r).To4()
+			end = start
+		}
+		if start == nil || end == nil {
+			return nil, fmt.Errorf("invalid IPv4 range: %s", r)
+		}
+
+		from, to := ipToUint32(start), ipToUint32(end)
+		if to < from {
+			return nil, fmt.Errorf("range ends before it starts: %s", r)
+		}
+		if to-from >= MaxCGNATPrivateAddresses {
+			return nil, fmt.Errorf("range too large: %s", r)
+		}
+		for n := from; ; n++ {
+			ips = append(ips, n)
+			if n == to {
+				break
+			}
+		}
+	}
+	return ips, nil
+}
+
+// CGNATBlocks computes the deterministic port-block mapping of a pool. Private address
+// i (in range order) gets public address i mod P and port block i div P, so subscribers
+// are spread over all public addresses before a second block is used on any of them.
+func CGNATBlocks(pool *models.CGNATPool) ([]models.CGNATPortBlock, error) {
+	if pool.PortStart < 1 || pool.PortEnd > 65535 || pool.PortStart > pool.PortEnd {
+		return nil, fmt.Errorf("invalid port range %d-%d", pool.PortStart, pool.PortEnd)
+	}
+	if pool.PortsPerBlock < 1 {
+		return nil, fmt.Errorf("ports per block must be positive")
+	}
+
+	private, err := parseCGNATRanges(pool.PrivateRanges)
+	if err != nil {
+		return nil, fmt.Errorf("private ranges: %v", err)
+	}
+	public, err := parseCGNATRanges(pool.PublicRanges)
+	if err != nil {
+		return nil, fmt.Errorf("public ranges: %v", err)
+	}
+	if len(private) == 0 || len(public) == 0 {
+		return nil, fmt.Errorf("private and public ranges are required")
+	}
+	if len(private) > MaxCGNATPrivateAddresses {
+		return nil, fmt.Errorf("too many private addresses (%d, max %d)", len(private), MaxCGNATPrivateAddresses)
+	}
+
+	blocksPerIP := (pool.PortEnd - pool.PortStart + 1) / pool.PortsPerBlock
+	if blocksPerIP == 0 {
+		return nil, fmt.Errorf("port range %d-%d is smaller than one block of %d ports", pool.PortStart, pool.PortEnd, pool.PortsPerBlock)
+	}
+	if capacity := len(public) * blocksPerIP; len(private) > capacity {
+		return nil, fmt.Errorf("%d private addresses do not fit in %d public addresses with %d blocks each (capacity %d)",
+			len(private), len(public), blocksPerIP, capacity)
+	}
+
+	seen := make(map[uint32]bool, len(private))
+	blocks := make([]models.CGNATPortBlock, 0, len(private))
+	for i, ip := range private {
+		if seen[ip] {
+			return nil, fmt.Errorf("private address %s is listed twice", uint32ToIP(ip))
+		}
+		seen[ip] = true
+
+		block := i / len(public)
+		start := pool.PortStart + block*pool.PortsPerBlock
+		blocks = append(blocks, models.CGNATPortBlock{
+			CGNATPoolID: pool.ID,
+			PrivateIP:   uint32ToIP(ip).String(),
+			PublicIP:    uint32ToIP(public[i%len(public)]).String(),
+			PortStart:   start,
+			PortEnd:     start + pool.PortsPerBlock - 1,
+		})
+	}
+	return blocks, nil
+}
+
+// SaveCGNATPool validates the pool, saves it and replaces its port blocks in one
+// transaction. Private addresses may belong to only one pool.
+func SaveCGNATPool(pool *models.CGNATPool) error {
+	blocks, err := CGNATBlocks(pool)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(pool).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("cgnat_pool_id = ?", pool.ID).Delete(&models.CGNATPortBlock{}).Error; err != nil {
+			return err
+		}
+
+		privateIPs := make([]string, len(blocks))
+		for i := range blocks {
+			blocks[i].CGNATPoolID = pool.ID
+			privateIPs[i] = blocks[i].PrivateIP
+		}
+
+		var taken models.CGNATPortBlock
+		if err := tx.Where("private_ip IN ?", privateIPs).Limit(1).Find(&taken).Error; err != nil {
+			return err
+		}
+		if taken.ID != 0 {
+			return fmt.Errorf("private address %s already belongs to another CGNAT pool", taken.PrivateIP)
+		}
+
+		return tx.CreateInBatches(blocks, 1000).Error
+	})
+}
+
+// LookupCGNAT returns the current block that translates to publicIP:port, or nil
+func LookupCGNAT(publicIP string, port int) (*models.CGNATPortBlock, error) {
+	var block models.CGNATPortBlock
+	err := database.DB.Where("public_ip = ? AND port_start <= ? AND port_end >= ?", publicIP, port, port).
+		Limit(1).Find(&block).Error
+	if err != nil || block.ID == 0 {
+		return nil, err
+	}
+	return &block, nil
+}
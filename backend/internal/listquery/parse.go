@@ -0,0 +1,238 @@
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Error is a malformed request, answered with 400
+type Error struct {
+	msg string
+}
+
+func (e *Error) Error() string { return e.msg }
+
+func errorf(format string, args ...interface{}) error {
+	return &Error{msg: fmt.Sprintf(format, args...)}
+}
+
+type filter struct {
+	field  *Field
+	op     string
+	values []interface{}
+}
+
+type sortKey struct {
+	column string
+	typ    Type
+	desc   bool
+}
+
+// Query is a parsed list request
+type Query struct {
+	res     *Resource
+	fields  []*Field
+	filters []filter
+	search  string
+	sort    string // Normalized sort parameter, stored in cursors
+	keys    []sortKey
+	after   []interface{} // Sort key values of the last row of the previous page
+	Limit   int
+	Count   bool   // Include meta.total
+	Format  string // json, ndjson or csv
+}
+
+// operators maps the filter grammar to SQL
+var operators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=",
+	"like": "ILIKE", "in": "IN", "null": "IS NULL",
+}
+
+// Parse reads the list parameters:
+//
+//	fields=a,b,c              columns to return
+//	filter=field:op:value     repeatable; op is eq, ne, gt, gte, lt, lte, like, in (comma separated values) or null (true/false)
+//	q=text                    free text search
+//	sort=-a,b                 sort keys, "-" for descending
+//	limit=n                   page size
+//	cursor=...                meta.next_cursor of the previous page
+//	count=true                include meta.total
+//	format=json|ndjson|csv    ndjson and csv stream every matching row instead of one page
+func Parse(c *fiber.Ctx, res *Resource) (*Query, error) {
+	q := &Query{res: res, search: strings.TrimSpace(c.Query("q")), Count: c.QueryBool("count")}
+
+	q.Format = c.Query("format", "json")
+	if q.Format != "json" && q.Format != "ndjson" && q.Format != "csv" {
+		return nil, errorf("format must be json, ndjson or csv")
+	}
+
+	q.Limit = c.QueryInt("limit", defaultLimit)
+	if q.Limit < 1 {
+		return nil, errorf("limit must be positive")
+	}
+	if q.Limit > res.maxLimit() {
+		q.Limit = res.maxLimit()
+	}
+
+	if err := q.parseFields(c.Query("fields")); err != nil {
+		return nil, err
+	}
+	for _, raw := range c.Context().QueryArgs().PeekMulti("filter") {
+		if err := q.parseFilter(string(raw)); err != nil {
+			return nil, err
+		}
+	}
+	if err := q.parseSort(c.Query("sort", res.DefaultSort)); err != nil {
+		return nil, err
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if err := q.parseCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (q *Query) parseFields(param string) error {
+	names := q.res.DefaultFields
+	if param != "" {
+		names = strings.Split(param, ",")
+	}
+	if len(names) == 0 {
+		for i := range q.res.Fields {
+			q.fields = append(q.fields, &q.res.Fields[i])
+		}
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		f := q.res.field(name)
+		if f == nil {
+			return errorf("unknown field %q", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			q.fields = append(q.fields, f)
+		}
+	}
+	return nil
+}
+
+func (q *Query) parseFilter(raw string) error {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 {
+		return errorf("filter %q must be field:op:value", raw)
+	}
+	f := q.res.field(parts[0])
+	if f == nil || !f.Filter {
+		return errorf("cannot filter on %q", parts[0])
+	}
+	op := parts[1]
+	if _, ok := operators[op]; !ok {
+		return errorf("unknown filter operator %q", op)
+	}
+
+	flt := filter{field: f, op: op}
+	switch {
+	case op == "null":
+		isNull, err := strconv.ParseBool(parts[2])
+		if err != nil {
+			return errorf("filter %q: null takes true or false", raw)
+		}
+		if !isNull {
+			flt.op = "notnull"
+		}
+		q.filters = append(q.filters, flt)
+		return nil
+	case op == "like":
+		if f.Type != String {
+			return errorf("filter %q: like only applies to text fields", raw)
+		}
+		flt.values = []interface{}{"%" + escapeLike(parts[2]) + "%"}
+		q.filters = append(q.filters, flt)
+		return nil
+	case f.Type == Bool && op != "eq" && op != "ne":
+		return errorf("filter %q: boolean fields support eq and ne", raw)
+	}
+
+	values := []string{parts[2]}
+	if op == "in" {
+		values = strings.Split(parts[2], ",")
+	}
+	for _, s := range values {
+		v, err := parseValue(f.Type, s)
+		if err != nil {
+			return errorf("filter %q: %v", raw, err)
+		}
+		flt.values = append(flt.values, v)
+	}
+	q.filters = append(q.filters, flt)
+	return nil
+}
+
+func (q *Query) parseSort(param string) error {
+	var names []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		desc := strings.HasPrefix(name, "-")
+		f := q.res.field(strings.TrimPrefix(name, "-"))
+		if f == nil || !f.Sort {
+			return errorf("cannot sort on %q", strings.TrimPrefix(name, "-"))
+		}
+		q.keys = append(q.keys, sortKey{column: f.Column, typ: f.Type, desc: desc})
+		names = append(names, name)
+	}
+	q.sort = strings.Join(names, ",")
+
+	// The key breaks ties so every row has a distinct position
+	desc := len(q.keys) > 0 && q.keys[len(q.keys)-1].desc
+	q.keys = append(q.keys, sortKey{column: q.res.Key, typ: Int, desc: desc})
+	return nil
+}
+
+// cursor is what next_cursor encodes: the sort it belongs to and the last row's key values
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func (q *Query) encodeCursor(values []interface{}) string {
+	cur := cursor{Sort: q.sort}
+	for _, v := range values {
+		cur.Values = append(cur.Values, formatValue(v))
+	}
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (q *Query) parseCursor(raw string) error {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return errorf("invalid cursor")
+	}
+	var cur cursor
+	if err := json.Unmarshal(data, &cur); err != nil || len(cur.Values) != len(q.keys) {
+		return errorf("invalid cursor")
+	}
+	if cur.Sort != q.sort {
+		return errorf("cursor was issued for a different sort")
+	}
+	for i, s := range cur.Values {
+		v, err := parseValue(q.keys[i].typ, s)
+		if err != nil {
+			return errorf("invalid cursor")
+		}
+		q.after = append(q.after, v)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package listquery
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Row is one result, with the selected fields in request order
+type Row struct {
+	fields []*Field
+	values []interface{}
+}
+
+func (r Row) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r.fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Name)
+		value, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Page is the JSON response of a list request
+type Page struct {
+	Success bool  `json:"success"`
+	Data    []Row `json:"data"`
+	Meta    Meta  `json:"meta"`
+}
+
+type Meta struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"` // Pass as cursor= to get the next page
+	Total      *int64 `json:"total,omitempty"`       // Only with count=true
+}
+
+// Respond parses the request and answers it from base, which carries the FROM clause,
+// joins and the caller's access scope
+func Respond(c *fiber.Ctx, res *Resource, base *gorm.DB) error {
+	q, err := Parse(c, res)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"success": false, "message": err.Error()})
+	}
+	if q.Format != "json" {
+		return q.Stream(c, base)
+	}
+
+	page, err := q.Page(base)
+	if err != nil {
+		log.Printf("ERROR: Failed to list %s: %v", res.Name, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"success": false, "message": "Failed to fetch " + res.Name})
+	}
+	return c.JSON(page)
+}
+
+// Page fetches one page
+func (q *Query) Page(base *gorm.DB) (*Page, error) {
+	db := q.filter(base).Session(&gorm.Session{})
+	page := &Page{Success: true, Data: []Row{}, Meta: Meta{Limit: q.Limit}}
+
+	if q.Count {
+		var total int64
+		if err := db.Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Meta.Total = &total
+	}
+
+	rows, err := q.ordered(db).Limit(q.Limit + 1).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lastKeys []interface{}
+	for rows.Next() {
+		if len(page.Data) == q.Limit {
+			page.Meta.HasMore = true
+			break
+		}
+		row, keys, err := q.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Data = append(page.Data, row)
+		lastKeys = keys
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if page.Meta.HasMore {
+		page.Meta.NextCursor = q.encodeCursor(lastKeys)
+	}
+	return page, nil
+}
+
+// Stream writes every matching row (after the cursor, if any) as NDJSON or CSV. Rows are
+// read from the database cursor as they are written, so exports of large tables do not
+// have to fit in memory.
+func (q *Query) Stream(c *fiber.Ctx, base *gorm.DB) error {
+	db := q.ordered(q.filter(base))
+	name := q.res.Name
+
+	if q.Format == "csv" {
+		c.Set("Content-Type", "text/csv")
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", name))
+	} else {
+		c.Set("Content-Type", "application/x-ndjson")
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		rows, err := db.Rows()
+		if err != nil {
+			log.Printf("ERROR: Failed to export %s: %v", name, err)
+			return
+		}
+		defer rows.Close()
+
+		var cw *csv.Writer
+		if q.Format == "csv" {
+			cw = csv.NewWriter(w)
+			header := make([]string, len(q.fields))
+			for i, f := range q.fields {
+				header[i] = f.Name
+			}
+			cw.Write(header)
+		}
+
+		count := 0
+		for rows.Next() {
+			row, _, err := q.scan(rows)
+			if err != nil {
+				log.Printf("ERROR: Failed to export %s: %v", name, err)
+				return
+			}
+			if cw != nil {
+				record := make([]string, len(row.values))
+				for i, v := range row.values {
+					record[i] = formatValue(v)
+				}
+				cw.Write(record)
+			} else {
+				line, _ := json.Marshal(row)
+				w.Write(line)
+				w.WriteByte('\n')
+			}
+
+			count++
+			if count%500 == 0 {
+				if cw != nil {
+					cw.Flush()
+				}
+				if err := w.Flush(); err != nil {
+					return // client went away
+				}
+			}
+		}
+		if cw != nil {
+			cw.Flush()
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("ERROR: Export of %s stopped: %v", name, err)
+		}
+	})
+	return nil
+}
+
+// filter applies filters, search and the cursor position
+func (q *Query) filter(db *gorm.DB) *gorm.DB {
+	for _, f := range q.filters {
+		column := f.field.Column
+		switch f.op {
+		case "null":
+			db = db.Where(column + " IS NULL")
+		case "notnull":
+			db = db.Where(column + " IS NOT NULL")
+		case "in":
+			db = db.Where(column+" IN ?", f.values)
+		default:
+			db = db.Where(column+" "+operators[f.op]+" ?", f.values[0])
+		}
+	}
+
+	if q.search != "" && len(q.res.Search) > 0 {
+		pattern := "%" + escapeLike(q.search) + "%"
+		conds := make([]string, len(q.res.Search))
+		args := make([]interface{}, len(q.res.Search))
+		for i, column := range q.res.Search {
+			conds[i] = column + " ILIKE ?"
+			args[i] = pattern
+		}
+		db = db.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+
+	if q.after != nil {
+		cond, args := q.afterCondition()
+		db = db.Where(cond, args...)
+	}
+	return db
+}
+
+// afterCondition selects rows past the cursor. When all keys share a direction a row
+// comparison is used, which PostgreSQL can answer from a composite index.
+func (q *Query) afterCondition() (string, []interface{}) {
+	sameDirection := true
+	for _, k := range q.keys {
+		if k.desc != q.keys[0].desc {
+			sameDirection = false
+		}
+	}
+
+	if sameDirection {
+		columns := make([]string, len(q.keys))
+		for i, k := range q.keys {
+			columns[i] = k.column
+		}
+		op := ">"
+		if q.keys[0].desc {
+			op = "<"
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(q.keys)), ", ")
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op, placeholders), q.after
+	}
+
+	// (a > ?) OR (a = ? AND b < ?) OR ...
+	var ors []string
+	var args []interface{}
+	for i, k := range q.keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, q.keys[j].column+" = ?")
+			args = append(args, q.after[j])
+		}
+		op := ">"
+		if k.desc {
+			op = "<"
+		}
+		ands = append(ands, k.column+" "+op+" ?")
+		args = append(args, q.after[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// ordered selects the requested fields plus the sort keys, in sort order
+func (q *Query) ordered(db *gorm.DB) *gorm.DB {
+	selects := make([]string, 0, len(q.fields)+len(q.keys))
+	for _, f := range q.fields {
+		selects = append(selects, fmt.Sprintf(`%s AS "%s"`, f.Column, f.Name))
+	}
+	orders := make([]string, len(q.keys))
+	for i, k := range q.keys {
+		selects = append(selects, fmt.Sprintf(`%s AS "_key%d"`, k.column, i))
+		orders[i] = k.column + " ASC"
+		if k.desc {
+			orders[i] = k.column + " DESC"
+		}
+	}
+	return db.Select(strings.Join(selects, ", ")).Order(strings.Join(orders, ", "))
+}
+
+// scan reads one row into the selected fields and the sort key values
+func (q *Query) scan(rows *sql.Rows) (Row, []interface{}, error) {
+	n := len(q.fields) + len(q.keys)
+	values := make([]interface{}, n)
+	ptrs := make([]interface{}, n)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return Row{}, nil, err
+	}
+
+	row := Row{fields: q.fields, values: values[:len(q.fields)]}
+	for i, f := range q.fields {
+		row.values[i] = normalize(f.Type, row.values[i])
+	}
+	keys := values[len(q.fields):]
+	for i, k := range q.keys {
+		keys[i] = normalize(k.typ, keys[i])
+	}
+	return row, keys, nil
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -13,9 +14,11 @@ import (
 // AuditLogger middleware logs API actions to audit log
 func AuditLogger() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Skip non-modifying requests
+		// Skip non-modifying requests (API key calls are always logged)
 		method := c.Method()
-		if method == "GET" || method == "HEAD" || method == "OPTIONS" {
+		apiKey := GetAPIKey(c)
+		readOnly := method == "GET" || method == "HEAD" || method == "OPTIONS"
+		if readOnly && apiKey == nil {
 			return c.Next()
 		}
 
@@ -59,8 +62,12 @@ func AuditLogger() fiber.Handler {
 
 		// Only log successful responses
 		statusCode := c.Response().StatusCode()
-		if statusCode >= 200 && statusCode < 400 && user != nil {
-			logAuditEntry(user, method, path, ip, userAgent, requestBody, entityNameBeforeDelete, customDesc, customEntityID, customEntityName)
+		logged := false
+		if !readOnly && statusCode >= 200 && statusCode < 400 && user != nil {
+			logged = logAuditEntry(user, apiKey, method, path, ip, userAgent, requestBody, entityNameBeforeDelete, customDesc, customEntityID, customEntityName)
+		}
+		if !logged && apiKey != nil && user != nil {
+			logAPICall(user, apiKey, method, path, ip, userAgent, statusCode)
 		}
 
 		return err
@@ -77,9 +84,9 @@ func extractIDFromPath(path string) string {
 	return ""
 }
 
-func logAuditEntry(user *models.User, method, path, ip, userAgent string, requestBody []byte, preDeleteName, customDesc string, customEntityID uint, customEntityName string) {
+func logAuditEntry(user *models.User, apiKey *models.APIKey, method, path, ip, userAgent string, requestBody []byte, preDeleteName, customDesc string, customEntityID uint, customEntityName string) bool {
 	if user == nil {
-		return
+		return false
 	}
 
 	// Determine action based on method
@@ -92,13 +99,13 @@ func logAuditEntry(user *models.User, method, path, ip, userAgent string, reques
 	case "DELETE":
 		action = models.AuditActionDelete
 	default:
-		return
+		return false
 	}
 
 	// Determine entity type from path
 	entityType := getEntityTypeFromPath(path)
 	if entityType == "" {
-		return
+		return false
 	}
 
 	// Use handler-provided description if available, otherwise generate generic one
@@ -124,6 +131,30 @@ func logAuditEntry(user *models.User, method, path, ip, userAgent string, reques
 		OldValue:    "{}",
 		NewValue:    "{}",
 	}
+	if apiKey != nil {
+		auditLog.APIKeyID = &apiKey.ID
+	}
+	database.DB.Create(&auditLog)
+	return true
+}
+
+// logAPICall records a request made with an API key that has no entity-level audit entry
+// (reads, failed calls and paths without a known entity type)
+func logAPICall(user *models.User, apiKey *models.APIKey, method, path, ip, userAgent string, statusCode int) {
+	auditLog := models.AuditLog{
+		UserID:      user.ID,
+		Username:    user.Username,
+		UserType:    user.UserType,
+		Action:      models.AuditActionAPICall,
+		EntityType:  getEntityTypeFromPath(path),
+		EntityName:  apiKey.Name,
+		Description: fmt.Sprintf("API key %s: %s %s (%d)", apiKey.KeyPrefix, method, path, statusCode),
+		IPAddress:   ip,
+		UserAgent:   userAgent,
+		OldValue:    "{}",
+		NewValue:    "{}",
+		APIKeyID:    &apiKey.ID,
+	}
 	database.DB.Create(&auditLog)
 }
 
@@ -338,6 +369,13 @@ func getEntityTypeFromPath(path string) string {
 		"notifications":      "notification",
 		"system":             "system",
 		"license":            "license",
+		"api-keys":           "api_key",
+		"hotspot":            "hotspot",
+		"webhooks":           "webhook",
+		"sso":                "sso_provider",
+		"ip-history":         "ip_history",
+		"commissions":        "commission",
+		"analytics":          "analytics",
 	}
 
 	if entity, ok := entityMap[parts[0]]; ok {
@@ -17,18 +17,20 @@ type JWTClaims struct {
 	Username   string          `json:"username"`
 	UserType   models.UserType `json:"user_type"`
 	ResellerID *uint           `json:"reseller_id,omitempty"`
+	SessionID  uint            `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token
-func GenerateToken(user *models.User, cfg *config.Config) (string, error) {
+// GenerateToken generates a short-lived access token bound to a login session (see CreateSession)
+func GenerateToken(user *models.User, sessionID uint, ttl time.Duration, cfg *config.Config) (string, error) {
 	claims := JWTClaims{
 		UserID:   user.ID,
 		Username: user.Username,
 		UserType: user.UserType,
 		ResellerID: user.ResellerID,
+		SessionID:  sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWTExpireHours) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "proisp",
 		},
@@ -50,9 +52,6 @@ func OptionalAuth(cfg *config.Config) fiber.Handler {
 			return c.Next()
 		}
 		tokenString := parts[1]
-		if database.IsTokenBlacklisted(tokenString) {
-			return c.Next()
-		}
 		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return []byte(cfg.JWTSecret), nil
 		})
@@ -60,7 +59,7 @@ func OptionalAuth(cfg *config.Config) fiber.Handler {
 			return c.Next()
 		}
 		claims, ok := token.Claims.(*JWTClaims)
-		if !ok {
+		if !ok || !sessionActive(claims.SessionID, claims.UserID, c.IP()) {
 			return c.Next()
 		}
 		var user models.User
@@ -75,13 +74,19 @@ func OptionalAuth(cfg *config.Config) fiber.Handler {
 		c.Locals("username", claims.Username)
 		c.Locals("userType", claims.UserType)
 		c.Locals("resellerID", claims.ResellerID)
+		c.Locals("sessionID", claims.SessionID)
 		return c.Next()
 	}
 }
 
 // AuthRequired middleware to protect routes
+// Accepts a JWT from Login or an API key (X-API-Key or "Bearer pisp_...")
 func AuthRequired(cfg *config.Config) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if apiKey := extractAPIKey(c); apiKey != "" {
+			return authenticateAPIKey(c, apiKey)
+		}
+
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -101,14 +106,6 @@ func AuthRequired(cfg *config.Config) fiber.Handler {
 
 		tokenString := parts[1]
 
-		// Check if token is blacklisted (user logged out)
-		if database.IsTokenBlacklisted(tokenString) {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"success": false,
-				"message": "Token has been revoked (logged out)",
-			})
-		}
-
 		// Parse and validate token
 		token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return []byte(cfg.JWTSecret), nil
@@ -129,6 +126,14 @@ func AuthRequired(cfg *config.Config) fiber.Handler {
 			})
 		}
 
+		// The login session must still be live (logout and remote revocation end it)
+		if !sessionActive(claims.SessionID, claims.UserID, c.IP()) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"success": false,
+				"message": "Session has been revoked or expired",
+			})
+		}
+
 		// Check if user still exists and is active
 		var user models.User
 		if err := database.DB.First(&user, claims.UserID).Error; err != nil {
@@ -158,12 +163,22 @@ func AuthRequired(cfg *config.Config) fiber.Handler {
 			}
 		}
 
+		// User types covered by the 2FA policy must enroll before doing anything else
+		if twoFactorSetupPending(c, &user, claims.SessionID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"success": false,
+				"message": "Two-factor authentication is required for your account. Please set it up to continue",
+				"code":    "TWO_FACTOR_SETUP_REQUIRED",
+			})
+		}
+
 		// Store user info in context
 		c.Locals("user", &user)
 		c.Locals("userID", claims.UserID)
 		c.Locals("username", claims.Username)
 		c.Locals("userType", claims.UserType)
 		c.Locals("resellerID", claims.ResellerID)
+		c.Locals("sessionID", claims.SessionID)
 
 		return c.Next()
 	}
@@ -185,6 +200,10 @@ func AdminOnly() fiber.Handler {
 				"message": "Admin access required",
 			})
 		}
+		// Admin-only routes have no permission name; only full-scope keys may use them
+		if !apiKeyScopeAllowed(c, models.APIKeyScopeAll) {
+			return apiKeyScopeError(c)
+		}
 		return c.Next()
 	}
 }
@@ -278,7 +297,7 @@ func AdminOrReseller() fiber.Handler {
 
 // RequirePermission middleware checks if user has a specific permission
 // Admins always have all permissions
-// Resellers must have the permission in their permission group
+// Everyone else must have the permission in their permission group (see GetUserPermissions)
 func RequirePermission(permission string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userType, ok := c.Locals("userType").(models.UserType)
@@ -289,30 +308,18 @@ func RequirePermission(permission string) fiber.Handler {
 			})
 		}
 
+		// API keys are limited to their scopes
+		if !apiKeyScopeAllowed(c, permission) {
+			return apiKeyScopeError(c)
+		}
+
 		// Admins have all permissions
 		if userType == models.UserTypeAdmin {
 			return c.Next()
 		}
 
-		// Only resellers can have permission groups
-		if userType != models.UserTypeReseller {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"success": false,
-				"message": "Permission denied",
-			})
-		}
-
-		// Get user from context
-		user := GetCurrentUser(c)
-		if user == nil || user.ResellerID == nil {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"success": false,
-				"message": "Permission denied",
-			})
-		}
-
-		// Check if reseller has the permission
-		if !hasPermission(user, permission) {
+		// Everyone else is limited to their permission group
+		if !HasPermission(GetCurrentUser(c), permission) {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"success": false,
 				"message": "You don't have permission to perform this action",
@@ -323,29 +330,6 @@ func RequirePermission(permission string) fiber.Handler {
 	}
 }
 
-// hasPermission checks if a user has a specific permission
-func hasPermission(user *models.User, permission string) bool {
-	// Get reseller's permission group
-	var reseller models.Reseller
-	if err := database.DB.First(&reseller, *user.ResellerID).Error; err != nil {
-		return false
-	}
-
-	// If no permission group assigned, allow all (default behavior)
-	if reseller.PermissionGroup == nil {
-		return true
-	}
-
-	// Check if permission exists in the group
-	var count int64
-	database.DB.Table("permissions").
-		Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
-		Where("pgp.permission_group_id = ? AND permissions.name = ?", *reseller.PermissionGroup, permission).
-		Count(&count)
-
-	return count > 0
-}
-
 // RequireAnyPermission middleware checks if user has any of the specified permissions
 func RequireAnyPermission(permissions ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -357,31 +341,20 @@ func RequireAnyPermission(permissions ...string) fiber.Handler {
 			})
 		}
 
+		// API keys are limited to their scopes
+		if !apiKeyScopeAllowed(c, permissions...) {
+			return apiKeyScopeError(c)
+		}
+
 		// Admins have all permissions
 		if userType == models.UserTypeAdmin {
 			return c.Next()
 		}
 
-		// Only resellers can have permission groups
-		if userType != models.UserTypeReseller {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"success": false,
-				"message": "Permission denied",
-			})
-		}
-
-		// Get user from context
+		// Check if the user has any of the permissions
 		user := GetCurrentUser(c)
-		if user == nil || user.ResellerID == nil {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"success": false,
-				"message": "Permission denied",
-			})
-		}
-
-		// Check if reseller has any of the permissions
 		for _, perm := range permissions {
-			if hasPermission(user, perm) {
+			if HasPermission(user, perm) {
 				return c.Next()
 			}
 		}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// permissionCacheTTL bounds how long another node (or the RADIUS process) can serve stale
+// permissions after a group changes; changes made through this API invalidate immediately.
+const permissionCacheTTL = 60 * time.Second
+
+// UserPermissions is the resolved access of one user: granted permission names plus
+// optional resource scopes. Admins have All set and no scopes.
+type UserPermissions struct {
+	All         bool
+	GroupID     *uint
+	Names       map[string]bool
+	ResellerIDs []uint // Empty = not limited to specific resellers
+	NasIDs      []uint // Empty = not limited to specific NASes
+	loadedAt    time.Time
+}
+
+// Has reports whether the permission is granted
+func (p *UserPermissions) Has(permission string) bool {
+	return p.All || p.Names[permission]
+}
+
+// List returns the granted permission names, sorted
+func (p *UserPermissions) List() []string {
+	names := make([]string, 0, len(p.Names))
+	for name := range p.Names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scoped reports whether the user is limited to specific resellers or NASes
+func (p *UserPermissions) Scoped() bool {
+	return len(p.ResellerIDs) > 0 || len(p.NasIDs) > 0
+}
+
+var permissionCache sync.Map // user ID -> *UserPermissions
+
+// InvalidatePermissionCache drops all resolved permissions.
+// Call after changing permission groups, group assignments or user scopes.
+func InvalidatePermissionCache() {
+	permissionCache.Range(func(key, _ interface{}) bool {
+		permissionCache.Delete(key)
+		return true
+	})
+}
+
+// GetUserPermissions resolves (and caches) a user's permissions.
+// The user's own permission group wins; resellers without one fall back to their reseller's group.
+// Without any group nothing is granted (deny by default).
+func GetUserPermissions(user *models.User) *UserPermissions {
+	if user == nil {
+		return &UserPermissions{Names: map[string]bool{}}
+	}
+	if user.UserType == models.UserTypeAdmin {
+		return &UserPermissions{All: true, Names: map[string]bool{}}
+	}
+
+	if cached, ok := permissionCache.Load(user.ID); ok {
+		perms := cached.(*UserPermissions)
+		if time.Since(perms.loadedAt) < permissionCacheTTL {
+			return perms
+		}
+	}
+
+	perms := &UserPermissions{Names: map[string]bool{}, loadedAt: time.Now()}
+
+	perms.GroupID = user.PermissionGroup
+	if perms.GroupID == nil && user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		var reseller models.Reseller
+		if err := database.DB.Select("id, permission_group").First(&reseller, *user.ResellerID).Error; err == nil {
+			perms.GroupID = reseller.PermissionGroup
+		}
+	}
+
+	if perms.GroupID != nil {
+		var names []string
+		database.DB.Table("permissions").
+			Joins("JOIN permission_group_permissions pgp ON pgp.permission_id = permissions.id").
+			Where("pgp.permission_group_id = ?", *perms.GroupID).
+			Pluck("name", &names)
+		for _, name := range names {
+			perms.Names[name] = true
+		}
+	}
+
+	// Resellers are already confined to their own tree; scopes are for staff users
+	if user.UserType != models.UserTypeReseller {
+		var scopes []models.UserScope
+		database.DB.Where("user_id = ?", user.ID).Find(&scopes)
+		for _, scope := range scopes {
+			switch scope.ResourceType {
+			case models.ScopeResourceReseller:
+				perms.ResellerIDs = append(perms.ResellerIDs, scope.ResourceID)
+			case models.ScopeResourceNas:
+				perms.NasIDs = append(perms.NasIDs, scope.ResourceID)
+			}
+		}
+	}
+
+	permissionCache.Store(user.ID, perms)
+	return perms
+}
+
+// HasPermission reports whether the user holds a permission
+func HasPermission(user *models.User, permission string) bool {
+	return GetUserPermissions(user).Has(permission)
+}
+
+// CanAccessReseller reports whether a scoped staff user may see the reseller
+func CanAccessReseller(user *models.User, resellerID uint) bool {
+	perms := GetUserPermissions(user)
+	if len(perms.ResellerIDs) == 0 {
+		return true
+	}
+	return containsID(perms.ResellerIDs, resellerID)
+}
+
+// CanAccessNas reports whether a scoped staff user may see the NAS
+func CanAccessNas(user *models.User, nasID uint) bool {
+	perms := GetUserPermissions(user)
+	if len(perms.NasIDs) == 0 {
+		return true
+	}
+	return containsID(perms.NasIDs, nasID)
+}
+
+// CanAccessSubscriber reports whether a scoped staff user may see the subscriber
+func CanAccessSubscriber(user *models.User, sub *models.Subscriber) bool {
+	if !CanAccessReseller(user, sub.ResellerID) {
+		return false
+	}
+	perms := GetUserPermissions(user)
+	if len(perms.NasIDs) > 0 && (sub.NasID == nil || !containsID(perms.NasIDs, *sub.NasID)) {
+		return false
+	}
+	return true
+}
+
+// ScopeSubscriberQuery limits a subscribers query to the user's reseller/NAS scopes.
+// prefix is the table name or alias ("" when the query is on subscribers itself).
+func ScopeSubscriberQuery(user *models.User, query *gorm.DB, prefix string) *gorm.DB {
+	if user == nil {
+		return query
+	}
+	perms := GetUserPermissions(user)
+	if prefix != "" {
+		prefix += "."
+	}
+	if len(perms.ResellerIDs) > 0 {
+		query = query.Where(prefix+"reseller_id IN ?", perms.ResellerIDs)
+	}
+	if len(perms.NasIDs) > 0 {
+		query = query.Where(prefix+"nas_id IN ?", perms.NasIDs)
+	}
+	return query
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
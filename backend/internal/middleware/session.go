@@ -0,0 +1,360 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/config"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+)
+
+const (
+	// sessionCheckTTL bounds how long another node keeps accepting access tokens of a revoked session
+	sessionCheckTTL = 15 * time.Second
+	// sessionActivityInterval throttles last_activity_at writes
+	sessionActivityInterval = time.Minute
+	// refreshReuseGrace tolerates two tabs refreshing the same token at once
+	refreshReuseGrace = 30 * time.Second
+)
+
+var (
+	ErrSessionInvalid = errors.New("invalid or expired refresh token")
+	ErrSessionRotated = errors.New("refresh token was already used")
+	ErrSessionReused  = errors.New("refresh token reuse detected, session revoked")
+)
+
+// TokenPair is what a login or refresh hands to the client
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int // Access token lifetime in seconds
+	Session      *models.AuthSession
+}
+
+// sessionState is the cached result of a session lookup
+type sessionState struct {
+	userID          uint
+	active          bool
+	twoFactorExempt bool // Impersonation and SSO sessions are not subject to the 2FA policy
+	checkedAt       time.Time
+	touchedAt       time.Time
+}
+
+var sessionCache sync.Map // session ID -> *sessionState
+
+// accessTokenTTL is the lifetime of an access token (system preference access_token_minutes)
+func accessTokenTTL() time.Duration {
+	minutes := 15
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", "access_token_minutes").First(&pref).Error; err == nil {
+		if val, err := strconv.Atoi(pref.Value); err == nil && val > 0 {
+			minutes = val
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// sessionTTL is how long a session survives without logging in again
+func sessionTTL(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.JWTExpireHours) * time.Hour
+}
+
+// HashRefreshToken returns the stored form of a refresh token
+func HashRefreshToken(token string) string {
+	return HashAPIKey(token)
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return models.AuthSessionTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// CreateSession records a new login and issues its first access/refresh token pair.
+// impersonatedBy is the admin's user ID when an admin logs in as someone else.
+func CreateSession(user *models.User, cfg *config.Config, ip, userAgent, device string, impersonatedBy *uint) (*TokenPair, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if device == "" {
+		device = DeviceFromUserAgent(userAgent)
+	}
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+
+	now := time.Now()
+	session := models.AuthSession{
+		UserID:           user.ID,
+		RefreshTokenHash: HashRefreshToken(refreshToken),
+		Device:           truncate(device, 100),
+		UserAgent:        userAgent,
+		IPAddress:        ip,
+		LastActivityAt:   now,
+		LastActivityIP:   ip,
+		ImpersonatedBy:   impersonatedBy,
+		ExpiresAt:        now.Add(sessionTTL(cfg)),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	// Keep a month of ended sessions for the device history, drop older ones
+	cutoff := now.AddDate(0, 0, -30)
+	database.DB.Where("user_id = ? AND (revoked_at < ? OR expires_at < ?)", user.ID, cutoff, cutoff).
+		Delete(&models.AuthSession{})
+
+	return issueTokenPair(user, &session, refreshToken, cfg)
+}
+
+// RefreshSession exchanges a refresh token for a new token pair, rotating the refresh token.
+// Presenting a token that was already rotated out revokes the whole session.
+func RefreshSession(refreshToken string, cfg *config.Config, ip string) (*TokenPair, *models.User, error) {
+	hash := HashRefreshToken(refreshToken)
+
+	var session models.AuthSession
+	if err := database.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if err := database.DB.Where("previous_token_hash = ?", hash).First(&session).Error; err != nil {
+			return nil, nil, ErrSessionInvalid
+		}
+		if !session.IsActive() {
+			return nil, nil, ErrSessionInvalid
+		}
+		if session.RotatedAt != nil && time.Since(*session.RotatedAt) < refreshReuseGrace {
+			return nil, nil, ErrSessionRotated
+		}
+		// A stolen token was replayed (or the thief refreshed first): end the session for both
+		RevokeSession(session.ID, "system", models.SessionRevokeTokenReuse)
+		var user models.User
+		if err := database.DB.First(&user, session.UserID).Error; err == nil {
+			database.DB.Create(&models.AuditLog{
+				UserID:      user.ID,
+				Username:    user.Username,
+				UserType:    user.UserType,
+				Action:      models.AuditActionLogout,
+				EntityType:  "auth_session",
+				EntityID:    session.ID,
+				EntityName:  session.Device,
+				Description: "Session revoked: refresh token was used twice",
+				IPAddress:   ip,
+			})
+		}
+		return nil, nil, ErrSessionReused
+	}
+	if !session.IsActive() {
+		return nil, nil, ErrSessionInvalid
+	}
+
+	// The account (and its reseller) may have been disabled since the last refresh
+	var user models.User
+	if err := database.DB.First(&user, session.UserID).Error; err != nil || !user.IsActive {
+		RevokeSession(session.ID, "system", models.SessionRevokeDisabled)
+		return nil, nil, ErrSessionInvalid
+	}
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		var reseller models.Reseller
+		if err := database.DB.First(&reseller, *user.ResellerID).Error; err != nil || !reseller.IsActive {
+			RevokeSession(session.ID, "system", models.SessionRevokeDisabled)
+			return nil, nil, ErrSessionInvalid
+		}
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	// Conditional on the old hash so two concurrent refreshes cannot both win
+	result := database.DB.Model(&models.AuthSession{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  HashRefreshToken(newToken),
+			"previous_token_hash": hash,
+			"rotated_at":          now,
+			"last_activity_at":    now,
+			"last_activity_ip":    ip,
+		})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrSessionRotated
+	}
+	session.LastActivityAt = now
+	session.LastActivityIP = ip
+
+	pair, err := issueTokenPair(&user, &session, newToken, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+func issueTokenPair(user *models.User, session *models.AuthSession, refreshToken string, cfg *config.Config) (*TokenPair, error) {
+	ttl := accessTokenTTL()
+	accessToken, err := GenerateToken(user, session.ID, ttl, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(ttl.Seconds()),
+		Session:      session,
+	}, nil
+}
+
+// RevokeSession ends a single session; its access tokens stop working immediately on this node
+func RevokeSession(sessionID uint, revokedBy, reason string) bool {
+	result := database.DB.Model(&models.AuthSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"revoked_at":    time.Now(),
+			"revoked_by":    revokedBy,
+			"revoke_reason": reason,
+		})
+	sessionCache.Delete(sessionID)
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// RevokeUserSessions ends every active session of a user except exceptID (0 = none) and returns how many were ended
+func RevokeUserSessions(userID, exceptID uint, revokedBy, reason string) int64 {
+	result := database.DB.Model(&models.AuthSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, exceptID, time.Now()).
+		Updates(map[string]interface{}{
+			"revoked_at":    time.Now(),
+			"revoked_by":    revokedBy,
+			"revoke_reason": reason,
+		})
+	sessionCache.Range(func(key, value interface{}) bool {
+		if value.(*sessionState).userID == userID && key.(uint) != exceptID {
+			sessionCache.Delete(key)
+		}
+		return true
+	})
+	return result.RowsAffected
+}
+
+// sessionActive reports whether an access token's session is still live, and records activity.
+// Tokens issued before sessions existed carry no session ID and are rejected.
+func sessionActive(sessionID, userID uint, ip string) bool {
+	if sessionID == 0 {
+		return false
+	}
+	now := time.Now()
+	if cached, ok := sessionCache.Load(sessionID); ok {
+		state := cached.(*sessionState)
+		if now.Sub(state.checkedAt) < sessionCheckTTL {
+			if state.active {
+				touchSession(sessionID, state, ip)
+			}
+			return state.active && state.userID == userID
+		}
+	}
+
+	var session models.AuthSession
+	if err := database.DB.Select("id, user_id, expires_at, revoked_at, last_activity_at, impersonated_by, auth_method").
+		First(&session, sessionID).Error; err != nil {
+		return false
+	}
+	state := &sessionState{
+		userID:          session.UserID,
+		active:          session.IsActive(),
+		twoFactorExempt: session.ImpersonatedBy != nil || session.AuthMethod == models.AuthMethodSSO,
+		checkedAt:       now,
+		touchedAt:       session.LastActivityAt,
+	}
+	sessionCache.Store(sessionID, state)
+	if state.active {
+		touchSession(sessionID, state, ip)
+	}
+	return state.active && state.userID == userID
+}
+
+// touchSession updates last activity at most once per sessionActivityInterval
+func touchSession(sessionID uint, state *sessionState, ip string) {
+	now := time.Now()
+	if now.Sub(state.touchedAt) < sessionActivityInterval {
+		return
+	}
+	state.touchedAt = now
+	go database.DB.Model(&models.AuthSession{}).Where("id = ?", sessionID).UpdateColumns(map[string]interface{}{
+		"last_activity_at": now,
+		"last_activity_ip": ip,
+	})
+}
+
+// GetCurrentSessionID returns the login session of the request, or 0 for API keys
+func GetCurrentSessionID(c *fiber.Ctx) uint {
+	sessionID, ok := c.Locals("sessionID").(uint)
+	if !ok {
+		return 0
+	}
+	return sessionID
+}
+
+// DeviceFromUserAgent turns a User-Agent into a short label like "Chrome on Windows"
+func DeviceFromUserAgent(ua string) string {
+	if ua == "" {
+		return "Unknown device"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/") || strings.Contains(ua, "Opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "Dart/") || strings.Contains(ua, "okhttp"):
+		browser = "Mobile app"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad"):
+		os = "iOS"
+	case strings.Contains(ua, "Mac OS X") || strings.Contains(ua, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	}
+	return truncate(ua, 100)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
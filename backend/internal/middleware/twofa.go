@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+)
+
+// twoFactorPolicyTTL bounds how long another node serves a stale 2FA policy
+const twoFactorPolicyTTL = 30 * time.Second
+
+var twoFactorPolicy struct {
+	sync.Mutex
+	types    map[models.UserType]bool
+	loadedAt time.Time
+}
+
+// twoFactorSetupPaths stay reachable while a user still has to enroll a second factor
+var twoFactorSetupPaths = []string{
+	"/api/auth/2fa/",
+	"/api/auth/me",
+	"/api/auth/logout",
+	"/api/auth/password",
+	"/api/auth/change-password",
+	"/api/auth/sessions",
+}
+
+// userTypeNames are the names accepted in the two_factor_required_user_types preference
+var userTypeNames = map[string]models.UserType{
+	"reseller":  models.UserTypeReseller,
+	"support":   models.UserTypeSupport,
+	"admin":     models.UserTypeAdmin,
+	"collector": models.UserTypeCollector,
+	"readonly":  models.UserTypeReadonly,
+}
+
+// ParseTwoFactorPolicy parses a comma-separated list of user type names (e.g. "admin,support").
+// Unknown names are returned separately so callers can reject them.
+func ParseTwoFactorPolicy(value string) (map[models.UserType]bool, []string) {
+	types := map[models.UserType]bool{}
+	var unknown []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if t, ok := userTypeNames[name]; ok {
+			types[t] = true
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	return types, unknown
+}
+
+// TwoFactorRequired reports whether the 2FA policy (system preference
+// two_factor_required_user_types) requires a second factor for the user type
+func TwoFactorRequired(userType models.UserType) bool {
+	twoFactorPolicy.Lock()
+	defer twoFactorPolicy.Unlock()
+	if twoFactorPolicy.types == nil || time.Since(twoFactorPolicy.loadedAt) > twoFactorPolicyTTL {
+		var pref models.SystemPreference
+		value := ""
+		if err := database.DB.Where("key = ?", "two_factor_required_user_types").First(&pref).Error; err == nil {
+			value = pref.Value
+		}
+		twoFactorPolicy.types, _ = ParseTwoFactorPolicy(value)
+		twoFactorPolicy.loadedAt = time.Now()
+	}
+	return twoFactorPolicy.types[userType]
+}
+
+// InvalidateTwoFactorPolicy makes the next check re-read the policy
+func InvalidateTwoFactorPolicy() {
+	twoFactorPolicy.Lock()
+	twoFactorPolicy.types = nil
+	twoFactorPolicy.Unlock()
+}
+
+// twoFactorSetupPending reports whether a password session must enroll a second factor
+// before using anything beyond the 2FA setup and account endpoints
+func twoFactorSetupPending(c *fiber.Ctx, user *models.User, sessionID uint) bool {
+	if user.TwoFactorEnabled || !TwoFactorRequired(user.UserType) {
+		return false
+	}
+	if cached, ok := sessionCache.Load(sessionID); ok && cached.(*sessionState).twoFactorExempt {
+		return false
+	}
+	path := c.Path()
+	for _, allowed := range twoFactorSetupPaths {
+		if strings.HasPrefix(path, allowed) {
+			return false
+		}
+	}
+	return true
+}
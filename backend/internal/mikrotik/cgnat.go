@@ -0,0 +1,180 @@
+package mikrotik
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// CGNATMapping is the translation of one private address
+type CGNATMapping struct {
+	PrivateIP string
+	PublicIP  string
+	PortStart int
+	PortEnd   int
+}
+
+// CGNATRuleSet is the src-nat configuration of one CGNAT pool
+type CGNATRuleSet struct {
+	Name          string   // Pool name; rules live in chain "cgnat-<name>"
+	PrivateRanges []string // Matched by the jump rule in srcnat ("a-b" or CIDR)
+	Mappings      []CGNATMapping
+}
+
+// cgnatComment tags every rule of a pool so sync and removal only touch our own rules
+func cgnatComment(name string) string {
+	return ProvisionComment + "-CGNAT-" + name
+}
+
+// natRule is the part of a NAT rule that decides whether an existing rule can be kept
+type natRule struct {
+	chain, srcAddress, protocol, action, toAddresses, toPorts, jumpTarget string
+}
+
+func (r natRule) key() string {
+	return strings.Join([]string{r.chain, r.srcAddress, r.protocol, r.action, r.toAddresses, r.toPorts, r.jumpTarget}, "|")
+}
+
+func (r natRule) args(comment string) []string {
+	args := []string{"=chain=" + r.chain, "=src-address=" + r.srcAddress, "=action=" + r.action, "=comment=" + comment}
+	if r.protocol != "" {
+		args = append(args, "=protocol="+r.protocol)
+	}
+	if r.toAddresses != "" {
+		args = append(args, "=to-addresses="+r.toAddresses)
+	}
+	if r.toPorts != "" {
+		args = append(args, "=to-ports="+r.toPorts)
+	}
+	if r.jumpTarget != "" {
+		args = append(args, "=jump-target="+r.jumpTarget)
+	}
+	return args
+}
+
+// cgnatRules builds the deterministic rules of a pool: a jump from srcnat for each
+// private range, then per private address TCP and UDP rules limited to its port block
+// and an ICMP rule that only rewrites the address. The per-address rules never overlap,
+// so their order within the chain does not matter.
+func cgnatRules(set CGNATRuleSet) []natRule {
+	chain := "cgnat-" + set.Name
+	var rules []natRule
+	for _, r := range set.PrivateRanges {
+		rules = append(rules, natRule{chain: "srcnat", srcAddress: r, action: "jump", jumpTarget: chain})
+	}
+	for _, m := range set.Mappings {
+		ports := fmt.Sprintf("%d-%d", m.PortStart, m.PortEnd)
+		rules = append(rules,
+			natRule{chain: chain, srcAddress: m.PrivateIP, protocol: "tcp", action: "src-nat", toAddresses: m.PublicIP, toPorts: ports},
+			natRule{chain: chain, srcAddress: m.PrivateIP, protocol: "udp", action: "src-nat", toAddresses: m.PublicIP, toPorts: ports},
+			natRule{chain: chain, srcAddress: m.PrivateIP, protocol: "icmp", action: "src-nat", toAddresses: m.PublicIP},
+		)
+	}
+	return rules
+}
+
+// listCGNATRules returns the IDs of the pool's existing NAT rules, keyed by rule
+func (c *Client) listCGNATRules(name string) (map[string][]string, error) {
+	results, err := c.runWithArgs("/ip/firewall/nat/print", "?comment="+cgnatComment(name))
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string][]string)
+	for _, r := range results {
+		// RouterOS prints single addresses with a /32 suffix in some versions
+		rule := natRule{
+			chain:       r["chain"],
+			srcAddress:  strings.TrimSuffix(r["src-address"], "/32"),
+			protocol:    r["protocol"],
+			action:      r["action"],
+			toAddresses: r["to-addresses"],
+			toPorts:     r["to-ports"],
+			jumpTarget:  r["jump-target"],
+		}
+		existing[rule.key()] = append(existing[rule.key()], r[".id"])
+	}
+	return existing, nil
+}
+
+// SyncCGNATRules makes the router's src-nat rules for a pool match the mapping. Rules
+// that are already correct are left in place, so established translations are not
+// disturbed; stale rules are removed and missing ones added. Returns added, removed.
+func (c *Client) SyncCGNATRules(set CGNATRuleSet) (int, int, error) {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	existing, err := c.listCGNATRules(set.Name)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query NAT rules: %v", err)
+	}
+
+	// Sort rules into kept, stale and missing; stale rules go first so an address never
+	// has two translations at once
+	var missing []natRule
+	for _, rule := range cgnatRules(set) {
+		if ids := existing[rule.key()]; len(ids) > 0 {
+			existing[rule.key()] = ids[1:]
+			continue
+		}
+		missing = append(missing, rule)
+	}
+
+	removed := 0
+	for _, ids := range existing {
+		for _, id := range ids {
+			if _, err := c.runWithArgs("/ip/firewall/nat/remove", "=.id="+id); err != nil {
+				return 0, removed, fmt.Errorf("failed to remove stale NAT rule %s: %v", id, err)
+			}
+			removed++
+		}
+	}
+
+	comment := cgnatComment(set.Name)
+	added := 0
+	for _, rule := range missing {
+		args := rule.args(comment)
+		if rule.chain == "srcnat" {
+			// The jump has to come before any masquerade rule that would catch the traffic
+			if first, err := c.runWithArgs("/ip/firewall/nat/print", "?chain=srcnat"); err == nil && len(first) > 0 {
+				args = append(args, "=place-before="+first[0][".id"])
+			}
+		}
+		if _, err := c.runWithArgs("/ip/firewall/nat/add", args...); err != nil {
+			return added, removed, fmt.Errorf("failed to add NAT rule for %s: %v", rule.srcAddress, err)
+		}
+		added++
+	}
+
+	log.Printf("MikroTik: Synced CGNAT pool %s (%d rules added, %d removed)", set.Name, added, removed)
+	return added, removed, nil
+}
+
+// RemoveCGNATRules removes every NAT rule of a pool
+func (c *Client) RemoveCGNATRules(name string) (int, error) {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return 0, err
+		}
+	}
+
+	existing, err := c.listCGNATRules(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query NAT rules: %v", err)
+	}
+
+	removed := 0
+	for _, ids := range existing {
+		for _, id := range ids {
+			if _, err := c.runWithArgs("/ip/firewall/nat/remove", "=.id="+id); err != nil {
+				return removed, fmt.Errorf("failed to remove NAT rule: %v", err)
+			}
+			removed++
+		}
+	}
+
+	log.Printf("MikroTik: Removed %d CGNAT rules of pool %s", removed, name)
+	return removed, nil
+}
@@ -666,6 +666,14 @@ func (c *Client) GetActiveSessionMap() (map[string]*ActiveSession, error) {
 	}
 
 	log.Printf("MikroTik: Fetched %d active PPP sessions in bulk", len(sessions))
+
+	// Hotspot clients share the same subscriber paths (quota sync, FUP, stale checks)
+	for name, session := range c.hotspotSessionMap() {
+		if _, exists := sessions[name]; !exists {
+			sessions[name] = session
+		}
+	}
+
 	return sessions, nil
 }
 
@@ -922,7 +930,8 @@ func (c *Client) DisconnectUser(username string) error {
 	}
 
 	if sessionID == "" {
-		return fmt.Errorf("user not connected")
+		// Not a PPP user - try the hotspot active list
+		return c.DisconnectHotspotUser(username)
 	}
 
 	// Remove the session
@@ -1035,6 +1044,7 @@ func (c *Client) UpdateUserRateLimitWithIP(username, ipAddress string, downloadK
 		"<pppoe-" + username + "-1>",                 // <pppoe-username-1>
 		"<pppoe-" + username + "-2>",                 // <pppoe-username-2>
 		"<pppoe-" + username + "-3>",                 // <pppoe-username-3>
+		"<hotspot-" + username + ">",                 // hotspot login queue
 	}
 	// Prefix for domain-suffix matching: <pppoe-username@
 	domainPrefix := "<pppoe-" + username + "@"
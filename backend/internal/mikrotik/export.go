@@ -0,0 +1,332 @@
+package mikrotik
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// sensitiveExportKeys are RouterOS parameters whose values are masked when an export is displayed
+var sensitiveExportKeys = []string{
+	"secret", "password", "passphrase", "auth-password", "encryption-password",
+	"pre-shared-key", "wpa-pre-shared-key", "wpa2-pre-shared-key", "private-key",
+	"authentication-key", "ipsec-secret", "community", "token",
+}
+
+var sensitiveExportPattern = regexp.MustCompile(
+	`(^|\s)(` + strings.Join(sensitiveExportKeys, "|") + `)=("(?:[^"\\]|\\.)*"|\S+)`,
+)
+
+// exportTimestampPattern matches the first line of an export ("# 2024-01-02 12:00:00 by RouterOS 7.12")
+var exportTimestampPattern = regexp.MustCompile(`^#\s.*\sby RouterOS\s`)
+
+// NormalizeExport joins "\" continuation lines and drops the timestamp header so
+// two exports of an unchanged router compare equal
+func NormalizeExport(config string) []string {
+	config = strings.ReplaceAll(config, "\r\n", "\n")
+	raw := strings.Split(config, "\n")
+
+	lines := make([]string, 0, len(raw))
+	var current strings.Builder
+	for _, line := range raw {
+		if current.Len() > 0 {
+			line = strings.TrimLeft(line, " ")
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		current.WriteString(line)
+		joined := strings.TrimRight(current.String(), " ")
+		current.Reset()
+
+		if joined == "" || exportTimestampPattern.MatchString(joined) {
+			continue
+		}
+		lines = append(lines, joined)
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+
+	return lines
+}
+
+// RedactExport masks secrets, passwords and keys in export text
+func RedactExport(config string) string {
+	return sensitiveExportPattern.ReplaceAllString(config, "$1$2=********")
+}
+
+// DiffLine is one line of a line-level diff. Op is "=" (unchanged), "+" (added) or "-" (removed).
+type DiffLine struct {
+	Op      string `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+}
+
+// maxDiffEdits bounds the Myers search; beyond it the changed region is reported as a full replace
+const maxDiffEdits = 4000
+
+// DiffLines computes a line-level diff between two exports (Myers algorithm)
+func DiffLines(a, b []string) []DiffLine {
+	// Common prefix and suffix are the usual case for config changes
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffLine
+	for _, line := range a[:prefix] {
+		ops = append(ops, DiffLine{Op: "=", Text: line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, DiffLine{Op: "=", Text: line})
+	}
+
+	oldLine, newLine := 0, 0
+	for i := range ops {
+		switch ops[i].Op {
+		case "=":
+			oldLine++
+			newLine++
+			ops[i].OldLine, ops[i].NewLine = oldLine, newLine
+		case "-":
+			oldLine++
+			ops[i].OldLine = oldLine
+		case "+":
+			newLine++
+			ops[i].NewLine = newLine
+		}
+	}
+
+	return ops
+}
+
+func myersDiff(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= max && d <= maxDiffEdits; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+	}
+
+	if !found {
+		ops := make([]DiffLine, 0, n+m)
+		for _, line := range a {
+			ops = append(ops, DiffLine{Op: "-", Text: line})
+		}
+		for _, line := range b {
+			ops = append(ops, DiffLine{Op: "+", Text: line})
+		}
+		return ops
+	}
+
+	// Walk the trace backwards from (n, m) to (0, 0)
+	var reversed []DiffLine
+	x, y := n, m
+	for d := len(trace); d > 0; d-- {
+		prev := trace[d-1]
+		get := func(k int) int { return prev[k+d-1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && get(k-1) < get(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := get(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Op: "=", Text: a[x-1]})
+			x--
+			y--
+		}
+		if prevK == k+1 {
+			reversed = append(reversed, DiffLine{Op: "+", Text: b[y-1]})
+		} else {
+			reversed = append(reversed, DiffLine{Op: "-", Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffLine{Op: "=", Text: a[x-1]})
+		x--
+		y--
+	}
+
+	ops := make([]DiffLine, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// UnifiedDiff renders diff lines as a unified diff with the given lines of context
+func UnifiedDiff(ops []DiffLine, context int) string {
+	var b strings.Builder
+
+	for i := 0; i < len(ops); {
+		if ops[i].Op == "=" {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are within 2*context of each other
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Op != "=" {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		stop := end + context + 1
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+		for _, op := range ops[start:stop] {
+			if op.Op != "+" {
+				if oldStart == 0 {
+					oldStart = op.OldLine
+				}
+				oldCount++
+			}
+			if op.Op != "-" {
+				if newStart == 0 {
+					newStart = op.NewLine
+				}
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:stop] {
+			prefix := " "
+			if op.Op != "=" {
+				prefix = op.Op
+			}
+			b.WriteString(prefix + op.Text + "\n")
+		}
+
+		i = stop
+	}
+
+	return b.String()
+}
+
+// ImportConfig uploads an export to the router over FTP and applies it.
+// With reset=false the script is run with /import on top of the running config.
+// With reset=true the router is reset to an empty config and runs the script on boot;
+// the API connection drops while the router reboots.
+func (c *Client) ImportConfig(config string, reset bool) error {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return err
+		}
+	}
+
+	fileName := fmt.Sprintf("proisp_restore_%d.rsc", time.Now().UnixNano())
+	if err := c.uploadFileViaFTP(fileName, config); err != nil {
+		return fmt.Errorf("FTP upload failed: %v", err)
+	}
+
+	// Give RouterOS a moment to index the new file
+	time.Sleep(2 * time.Second)
+
+	if reset {
+		c.conn.SetDeadline(time.Now().Add(30 * time.Second))
+		c.sendWord("/system/reset-configuration")
+		c.sendWord("=no-defaults=yes")
+		c.sendWord("=skip-backup=yes")
+		c.sendWord("=run-after-reset=" + fileName)
+		c.sendWord("")
+		// The router reboots immediately; a read error here is expected
+		response, _ := c.readResponse()
+		for _, word := range response {
+			if strings.HasPrefix(word, "=message=") {
+				return fmt.Errorf("reset-configuration failed: %s", strings.TrimPrefix(word, "=message="))
+			}
+		}
+		return nil
+	}
+
+	// Large scripts can take a while to apply
+	saved := c.timeout
+	c.timeout = 2 * time.Minute
+	_, err := c.runWithArgs("/import", "=file-name="+fileName)
+	c.timeout = saved
+
+	c.removeFile(fileName)
+	return err
+}
+
+// uploadFileViaFTP stores content as filename on the router using the API credentials
+func (c *Client) uploadFileViaFTP(filename, content string) error {
+	host := c.Address
+	if idx := strings.LastIndex(host, ":"); idx > 0 {
+		host = host[:idx]
+	}
+	ftpPort := c.FTPPort
+	if ftpPort == 0 {
+		ftpPort = 21
+	}
+
+	conn, err := ftp.Dial(fmt.Sprintf("%s:%d", host, ftpPort), ftp.DialWithTimeout(10*time.Second))
+	if err != nil {
+		return err
+	}
+	defer conn.Quit()
+
+	if err := conn.Login(c.Username, c.Password); err != nil {
+		return fmt.Errorf("FTP login failed: %v", err)
+	}
+	return conn.Stor(filename, strings.NewReader(content))
+}
@@ -0,0 +1,189 @@
+package mikrotik
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HotspotSession is an authenticated client from /ip/hotspot/active
+type HotspotSession struct {
+	ID              string `json:"id"`
+	Server          string `json:"server"`
+	User            string `json:"user"`
+	Address         string `json:"address"`
+	MACAddress      string `json:"mac_address"`
+	LoginBy         string `json:"login_by"` // mac, http-chap, http-pap, cookie, trial
+	Uptime          string `json:"uptime"`
+	SessionTimeLeft string `json:"session_time_left"`
+	IdleTime        string `json:"idle_time"`
+	BytesIn         int64  `json:"bytes_in"`  // Uploaded by the client
+	BytesOut        int64  `json:"bytes_out"` // Downloaded by the client
+}
+
+// WalledGardenEntry is a destination allowed (or denied) before hotspot login
+type WalledGardenEntry struct {
+	DstHost    string // Goes to /ip/hotspot/walled-garden
+	DstAddress string // Goes to /ip/hotspot/walled-garden/ip
+	DstPort    string
+	Allow      bool
+	Comment    string
+}
+
+// GetHotspotActiveSessions lists all logged-in hotspot clients
+func (c *Client) GetHotspotActiveSessions() ([]HotspotSession, error) {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := c.runWithArgs("/ip/hotspot/active/print")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]HotspotSession, 0, len(results))
+	for _, r := range results {
+		bytesIn, _ := strconv.ParseInt(r["bytes-in"], 10, 64)
+		bytesOut, _ := strconv.ParseInt(r["bytes-out"], 10, 64)
+		sessions = append(sessions, HotspotSession{
+			ID:              r[".id"],
+			Server:          r["server"],
+			User:            r["user"],
+			Address:         r["address"],
+			MACAddress:      r["mac-address"],
+			LoginBy:         r["login-by"],
+			Uptime:          r["uptime"],
+			SessionTimeLeft: r["session-time-left"],
+			IdleTime:        r["idle-time"],
+			BytesIn:         bytesIn,
+			BytesOut:        bytesOut,
+		})
+	}
+
+	return sessions, nil
+}
+
+// DisconnectHotspotUser logs a hotspot client out by username (or MAC for MAC-auth logins)
+func (c *Client) DisconnectHotspotUser(username string) error {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return err
+		}
+	}
+
+	results, err := c.runWithArgs("/ip/hotspot/active/print", "?user="+username)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("user not connected")
+	}
+
+	for _, r := range results {
+		if _, err := c.runWithArgs("/ip/hotspot/active/remove", "=.id="+r[".id"]); err != nil {
+			return fmt.Errorf("hotspot logout failed: %v", err)
+		}
+	}
+
+	log.Printf("MikroTik: Logged out hotspot user %s (%d sessions)", username, len(results))
+	return nil
+}
+
+// SyncWalledGarden replaces the ProISP-managed walled-garden entries with the given list.
+// Entries added by hand on the router (without the ProISP comment) are left alone.
+func (c *Client) SyncWalledGarden(entries []WalledGardenEntry) (int, error) {
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			return 0, err
+		}
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	// Remove our previous entries from both menus
+	for _, menu := range []string{"/ip/hotspot/walled-garden", "/ip/hotspot/walled-garden/ip"} {
+		results, err := c.runWithArgs(menu + "/print")
+		if err != nil {
+			return 0, err
+		}
+		for _, r := range results {
+			if !strings.HasPrefix(r["comment"], ProvisionComment) {
+				continue
+			}
+			if _, err := c.runWithArgs(menu+"/remove", "=.id="+r[".id"]); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	added := 0
+	for _, e := range entries {
+		var menu string
+		var args []string
+		switch {
+		case e.DstHost != "":
+			menu = "/ip/hotspot/walled-garden/add"
+			action := "deny"
+			if e.Allow {
+				action = "allow"
+			}
+			args = []string{"=dst-host=" + e.DstHost, "=action=" + action}
+		case e.DstAddress != "":
+			menu = "/ip/hotspot/walled-garden/ip/add"
+			action := "reject"
+			if e.Allow {
+				action = "accept"
+			}
+			args = []string{"=dst-address=" + e.DstAddress, "=action=" + action}
+		default:
+			continue
+		}
+		if e.DstPort != "" {
+			args = append(args, "=dst-port="+e.DstPort)
+		}
+		// The marker comment is how re-syncs find our entries; keep the operator's note after it
+		comment := ProvisionComment
+		if e.Comment != "" {
+			comment += ": " + e.Comment
+		}
+		args = append(args, "=comment="+comment)
+
+		if _, err := c.runWithArgs(menu, args...); err != nil {
+			return added, fmt.Errorf("failed to add walled-garden entry %s%s: %v", e.DstHost, e.DstAddress, err)
+		}
+		added++
+	}
+
+	log.Printf("MikroTik: Synced %d walled-garden entries to %s", added, c.Address)
+	return added, nil
+}
+
+// hotspotSessionMap returns hotspot clients keyed by user, as ActiveSession for the shared session paths.
+// Routers without the hotspot package return an empty map.
+func (c *Client) hotspotSessionMap() map[string]*ActiveSession {
+	sessions, err := c.GetHotspotActiveSessions()
+	if err != nil {
+		return nil
+	}
+
+	result := make(map[string]*ActiveSession, len(sessions))
+	for _, s := range sessions {
+		if s.User == "" {
+			continue
+		}
+		result[s.User] = &ActiveSession{
+			ID:       s.ID,
+			Name:     s.User,
+			Service:  "hotspot",
+			CallerID: s.MACAddress,
+			Address:  s.Address,
+			Uptime:   s.Uptime,
+			RxBytes:  s.BytesOut,
+			TxBytes:  s.BytesIn,
+		}
+	}
+	return result
+}
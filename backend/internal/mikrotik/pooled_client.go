@@ -241,6 +241,36 @@ func (pc *PooledClient) GetAllConnectionCounts() (map[string]int, error) {
 	return counts, nil
 }
 
+// GetInterfaceStats gets traffic counters for all enabled interfaces
+func (pc *PooledClient) GetInterfaceStats() ([]InterfaceStats, error) {
+	results, err := pc.Execute("/interface/print", "?disabled=false")
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]InterfaceStats, 0, len(results))
+	for _, r := range results {
+		stats = append(stats, InterfaceStats{
+			Name:    r["name"],
+			Type:    r["type"],
+			Running: r["running"] == "true",
+			RxBytes: parseInt64(r["rx-byte"]),
+			TxBytes: parseInt64(r["tx-byte"]),
+		})
+	}
+
+	return stats, nil
+}
+
+// InterfaceStats for GetInterfaceStats return type
+type InterfaceStats struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Running bool   `json:"running"`
+	RxBytes int64  `json:"rx_bytes"`
+	TxBytes int64  `json:"tx_bytes"`
+}
+
 // PooledSystemResource for GetSystemResource return type
 type PooledSystemResource struct {
 	Uptime          string `json:"uptime"`
@@ -273,6 +303,54 @@ func parseInt64(s string) int64 {
 	return i
 }
 
+// ParseUptime converts a RouterOS uptime string to seconds
+// Formats: "1w2d3h4m5s" (v6.44+/v7) or "1w2d03:04:05" (older v6)
+func ParseUptime(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	var total, num int64
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= '0' && ch <= '9':
+			num = num*10 + int64(ch-'0')
+		case ch == 'w':
+			total += num * 7 * 86400
+			num = 0
+		case ch == 'd':
+			total += num * 86400
+			num = 0
+		case ch == 'h':
+			total += num * 3600
+			num = 0
+		case ch == 'm':
+			total += num * 60
+			num = 0
+		case ch == 's':
+			total += num
+			num = 0
+		case ch == ':':
+			// hh:mm:ss tail - parse the rest in one go
+			var h, m, sec int64
+			fmt.Sscanf(s[i-countDigitsBefore(s, i):], "%d:%d:%d", &h, &m, &sec)
+			return total + h*3600 + m*60 + sec
+		}
+	}
+	return total + num
+}
+
+// countDigitsBefore returns how many digits precede position i in s
+func countDigitsBefore(s string, i int) int {
+	n := 0
+	for j := i - 1; j >= 0 && s[j] >= '0' && s[j] <= '9'; j-- {
+		n++
+	}
+	return n
+}
+
 func parseBytes(s string) int64 {
 	// Parse bytes/bits from MikroTik format (e.g., "1234", "1.2kbps", "500Mbps")
 	s = strings.TrimSpace(s)
@@ -0,0 +1,224 @@
+package mikrotik
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ProvisionComment marks RADIUS entries created by ProISP so re-provisioning updates them in place
+const ProvisionComment = "ProISP"
+
+// RadiusProvisionConfig holds the settings pushed to a router during onboarding
+type RadiusProvisionConfig struct {
+	ServerAddress   string // ProISP RADIUS server IP as seen by the router
+	Secret          string
+	AuthPort        int
+	AcctPort        int
+	SrcAddress      string // Optional source address for RADIUS packets
+	Services        string // Comma-separated RouterOS services (default "ppp")
+	CoAPort         int
+	InterimInterval time.Duration
+}
+
+// ProvisionStep is the outcome of one provisioning action
+type ProvisionStep struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// ProvisionRadius configures /radius, /radius incoming and /ppp aaa on the router.
+// Every step is attempted and reported; the caller decides what a partial failure means.
+func (c *Client) ProvisionRadius(cfg RadiusProvisionConfig) []ProvisionStep {
+	if cfg.Services == "" {
+		cfg.Services = "ppp"
+	}
+	if cfg.InterimInterval <= 0 {
+		cfg.InterimInterval = 5 * time.Minute
+	}
+
+	var steps []ProvisionStep
+	record := func(name string, err error, okMsg string) {
+		step := ProvisionStep{Name: name, Success: err == nil, Message: okMsg}
+		if err != nil {
+			step.Message = err.Error()
+		}
+		steps = append(steps, step)
+	}
+
+	if c.conn == nil {
+		if err := c.Connect(); err != nil {
+			record("connect", err, "")
+			return steps
+		}
+	}
+	record("connect", nil, "API login successful")
+
+	// RADIUS client: update our entry if it exists, otherwise add it
+	radiusArgs := []string{
+		"=service=" + cfg.Services,
+		"=address=" + cfg.ServerAddress,
+		"=secret=" + cfg.Secret,
+		fmt.Sprintf("=authentication-port=%d", cfg.AuthPort),
+		fmt.Sprintf("=accounting-port=%d", cfg.AcctPort),
+		"=timeout=3000ms",
+		"=disabled=no",
+		"=comment=" + ProvisionComment,
+	}
+	if cfg.SrcAddress != "" {
+		radiusArgs = append(radiusArgs, "=src-address="+cfg.SrcAddress)
+	}
+
+	existingID, err := c.findRadiusEntry(cfg.ServerAddress)
+	if err == nil {
+		if existingID != "" {
+			_, err = c.runWithArgs("/radius/set", append([]string{"=.id=" + existingID}, radiusArgs...)...)
+			record("radius_client", err, fmt.Sprintf("Updated RADIUS client %s", cfg.ServerAddress))
+		} else {
+			_, err = c.runWithArgs("/radius/add", radiusArgs...)
+			record("radius_client", err, fmt.Sprintf("Added RADIUS client %s", cfg.ServerAddress))
+		}
+	} else {
+		record("radius_client", err, "")
+	}
+
+	// CoA / Disconnect-Message listener
+	_, err = c.runWithArgs("/radius/incoming/set", "=accept=yes", fmt.Sprintf("=port=%d", cfg.CoAPort))
+	record("radius_incoming", err, fmt.Sprintf("CoA enabled on port %d", cfg.CoAPort))
+
+	// PPP AAA: authenticate and account through RADIUS with interim updates
+	interim := formatRouterOSDuration(cfg.InterimInterval)
+	_, err = c.runWithArgs("/ppp/aaa/set", "=use-radius=yes", "=accounting=yes", "=interim-update="+interim)
+	record("ppp_aaa", err, fmt.Sprintf("RADIUS auth and accounting enabled, interim-update %s", interim))
+
+	// Read back what the router actually applied
+	record("verify_config", c.verifyRadiusConfig(cfg), "Router configuration matches")
+
+	log.Printf("MikroTik: Provisioned RADIUS client %s on %s", cfg.ServerAddress, c.Address)
+	return steps
+}
+
+// findRadiusEntry returns the .id of the /radius entry pointing at address ("" if none)
+func (c *Client) findRadiusEntry(address string) (string, error) {
+	results, err := c.runWithArgs("/radius/print", "?address="+address)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range results {
+		if r[".id"] != "" {
+			return r[".id"], nil
+		}
+	}
+	return "", nil
+}
+
+// verifyRadiusConfig re-reads the provisioned settings and reports any mismatch
+func (c *Client) verifyRadiusConfig(cfg RadiusProvisionConfig) error {
+	results, err := c.runWithArgs("/radius/print", "?address="+cfg.ServerAddress)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("RADIUS client %s not found after provisioning", cfg.ServerAddress)
+	}
+	r := results[0]
+	if r["disabled"] == "true" {
+		return fmt.Errorf("RADIUS client %s is disabled", cfg.ServerAddress)
+	}
+	if r["authentication-port"] != fmt.Sprintf("%d", cfg.AuthPort) || r["accounting-port"] != fmt.Sprintf("%d", cfg.AcctPort) {
+		return fmt.Errorf("RADIUS ports are %s/%s, expected %d/%d",
+			r["authentication-port"], r["accounting-port"], cfg.AuthPort, cfg.AcctPort)
+	}
+
+	results, err = c.runWithArgs("/radius/incoming/print")
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 || results[0]["accept"] != "true" {
+		return fmt.Errorf("RADIUS incoming (CoA) is not enabled")
+	}
+	if results[0]["port"] != fmt.Sprintf("%d", cfg.CoAPort) {
+		return fmt.Errorf("CoA port is %s, expected %d", results[0]["port"], cfg.CoAPort)
+	}
+
+	results, err = c.runWithArgs("/ppp/aaa/print")
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 || results[0]["use-radius"] != "true" || results[0]["accounting"] != "true" {
+		return fmt.Errorf("PPP AAA is not using RADIUS")
+	}
+
+	return nil
+}
+
+// runWithArgs sends a command with arguments and returns the parsed reply, failing on !trap
+func (c *Client) runWithArgs(command string, args ...string) ([]map[string]string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if err := c.sendWord(command); err != nil {
+		return nil, fmt.Errorf("failed to send command: %v", err)
+	}
+	for _, arg := range args {
+		if err := c.sendWord(arg); err != nil {
+			return nil, fmt.Errorf("failed to send argument: %v", err)
+		}
+	}
+	if err := c.sendWord(""); err != nil {
+		return nil, fmt.Errorf("failed to send end: %v", err)
+	}
+
+	response, err := c.readResponse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var results []map[string]string
+	current := make(map[string]string)
+	trapped := false
+
+	for _, word := range response {
+		switch {
+		case word == "!trap":
+			trapped = true
+		case word == "!re" || word == "!done":
+			if len(current) > 0 && !trapped {
+				results = append(results, current)
+			}
+			current = make(map[string]string)
+		case strings.HasPrefix(word, "=message=") && trapped:
+			return nil, fmt.Errorf("%s failed: %s", command, strings.TrimPrefix(word, "=message="))
+		case strings.HasPrefix(word, "="):
+			parts := strings.SplitN(word[1:], "=", 2)
+			if len(parts) == 2 {
+				current[parts[0]] = parts[1]
+			}
+		}
+	}
+	if trapped {
+		return nil, fmt.Errorf("%s failed: %v", command, response)
+	}
+
+	return results, nil
+}
+
+// formatRouterOSDuration renders a duration in RouterOS notation (e.g. 5m, 1h30m, 45s)
+func formatRouterOSDuration(d time.Duration) string {
+	secs := int64(d / time.Second)
+	if secs <= 0 {
+		return "0s"
+	}
+	var b strings.Builder
+	if h := secs / 3600; h > 0 {
+		fmt.Fprintf(&b, "%dh", h)
+	}
+	if m := (secs % 3600) / 60; m > 0 {
+		fmt.Fprintf(&b, "%dm", m)
+	}
+	if s := secs % 60; s > 0 {
+		fmt.Fprintf(&b, "%ds", s)
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// SubscriberStatusSnapshot is a subscriber's state at the end of a month; the current
+// month's row is overwritten nightly until the month is over
+type SubscriberStatusSnapshot struct {
+	Month        time.Time  `gorm:"column:month;primaryKey" json:"month"`
+	SubscriberID uint       `gorm:"column:subscriber_id;primaryKey" json:"subscriber_id"`
+	ServiceID    *uint      `gorm:"column:service_id" json:"service_id"`
+	ResellerID   *uint      `gorm:"column:reseller_id" json:"reseller_id"`
+	Region       string     `gorm:"column:region;size:100" json:"region"`
+	Status       int        `gorm:"column:status" json:"status"`
+	ExpiryDate   *time.Time `gorm:"column:expiry_date" json:"expiry_date"`
+	SubscribedAt *time.Time `gorm:"column:subscribed_at" json:"subscribed_at"`
+	Active       bool       `gorm:"column:active" json:"active"`
+	Estimated    bool       `gorm:"column:estimated" json:"estimated"` // Backfilled, not observed
+	UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (SubscriberStatusSnapshot) TableName() string {
+	return "subscriber_status_snapshots"
+}
+
+// AnalyticsMonthly holds a month's subscriber metrics for one segment. Dimension is
+// all, service, reseller or region; DimensionKey is the ID or region name ("all" for all).
+type AnalyticsMonthly struct {
+	Period         time.Time `gorm:"column:period;primaryKey" json:"period"`
+	Dimension      string    `gorm:"column:dimension;primaryKey;size:20" json:"dimension"`
+	DimensionKey   string    `gorm:"column:dimension_key;primaryKey;size:100" json:"dimension_key"`
+	Label          string    `gorm:"column:label;size:255" json:"label"`
+	ActiveStart    int       `gorm:"column:active_start" json:"active_start"`
+	ActiveEnd      int       `gorm:"column:active_end" json:"active_end"`
+	NewSubscribers int       `gorm:"column:new_subscribers" json:"new_subscribers"`
+	Churned        int       `gorm:"column:churned" json:"churned"`
+	Renewed        int       `gorm:"column:renewed" json:"renewed"`
+	Revenue        float64   `gorm:"column:revenue;type:decimal(15,2)" json:"revenue"`
+	ARPU           float64   `gorm:"column:arpu;type:decimal(15,2)" json:"arpu"`
+	ChurnRate      float64   `gorm:"column:churn_rate;type:decimal(7,4)" json:"churn_rate"`     // churned / active_start
+	RenewalRate    float64   `gorm:"column:renewal_rate;type:decimal(7,4)" json:"renewal_rate"` // renewed / (renewed + churned)
+	Estimated      bool      `gorm:"column:estimated" json:"estimated"`
+	ComputedAt     time.Time `gorm:"column:computed_at" json:"computed_at"`
+}
+
+func (AnalyticsMonthly) TableName() string {
+	return "analytics_monthly"
+}
+
+// AnalyticsCohort is how many subscribers who joined in Cohort are still active
+// MonthOffset months later
+type AnalyticsCohort struct {
+	Cohort       time.Time `gorm:"column:cohort;primaryKey" json:"cohort"`
+	MonthOffset  int       `gorm:"column:month_offset;primaryKey" json:"month_offset"`
+	Dimension    string    `gorm:"column:dimension;primaryKey;size:20" json:"dimension"`
+	DimensionKey string    `gorm:"column:dimension_key;primaryKey;size:100" json:"dimension_key"`
+	Label        string    `gorm:"column:label;size:255" json:"label"`
+	Size         int       `gorm:"column:size" json:"size"`
+	Active       int       `gorm:"column:active" json:"active"`
+	Retention    float64   `gorm:"column:retention;type:decimal(7,4)" json:"retention"`
+	ComputedAt   time.Time `gorm:"column:computed_at" json:"computed_at"`
+}
+
+func (AnalyticsCohort) TableName() string {
+	return "analytics_cohorts"
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix marks a bearer token as an API key rather than a JWT
+const APIKeyPrefix = "pisp_"
+
+// APIKeyScopeAll grants every permission the owner has
+const APIKeyScopeAll = "*"
+
+// APIKey is a long-lived credential for machine-to-machine access.
+// It acts as its owner, limited to Scopes. Only the SHA-256 of the key is stored.
+type APIKey struct {
+	ID         uint       `gorm:"column:id;primaryKey" json:"id"`
+	Name       string     `gorm:"column:name;size:100;not null" json:"name"`
+	KeyPrefix  string     `gorm:"column:key_prefix;size:20;index" json:"key_prefix"` // First characters, to recognise a key
+	KeyHash    string     `gorm:"column:key_hash;size:64;uniqueIndex;not null" json:"-"`
+	UserID     uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	Username   string     `gorm:"column:username;size:100" json:"username"`
+	ResellerID *uint      `gorm:"column:reseller_id;index" json:"reseller_id"`
+	Scopes     string     `gorm:"column:scopes;type:text" json:"scopes"`           // Comma-separated permission names, or *
+	AllowedIPs string     `gorm:"column:allowed_ips;type:text" json:"allowed_ips"` // Comma-separated IPs/CIDRs, empty = any
+	ExpiresAt  *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	IsActive   bool       `gorm:"column:is_active;default:true" json:"is_active"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	LastUsedIP string     `gorm:"column:last_used_ip;size:50" json:"last_used_ip"`
+	UsageCount int64      `gorm:"column:usage_count;default:0" json:"usage_count"`
+	CreatedBy  string     `gorm:"column:created_by;size:100" json:"created_by"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsExpired returns true if the key has an expiry in the past
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now())
+}
+
+// HasScope reports whether the key is allowed to use a permission
+func (k *APIKey) HasScope(permission string) bool {
+	for _, scope := range strings.Split(k.Scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == APIKeyScopeAll || scope == permission {
+			return true
+		}
+		// "subscribers.*" covers every subscribers permission
+		if strings.HasSuffix(scope, ".*") && strings.HasPrefix(permission, strings.TrimSuffix(scope, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsIP reports whether a request from ip may use the key
+func (k *APIKey) AllowsIP(ip string) bool {
+	if strings.TrimSpace(k.AllowedIPs) == "" {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	for _, entry := range strings.Split(k.AllowedIPs, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && addr != nil && network.Contains(addr) {
+				return true
+			}
+		} else if entry == ip {
+			return true
+		}
+	}
+	return false
+}
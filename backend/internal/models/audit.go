@@ -19,6 +19,8 @@ const (
 	AuditActionResetMAC   AuditAction = "reset_mac"
 	AuditActionTransfer   AuditAction = "transfer"
 	AuditActionWithdraw   AuditAction = "withdraw"
+	AuditActionAPICall    AuditAction = "api_call"
+	AuditActionIPLookup   AuditAction = "ip_lookup"
 )
 
 // AuditLog represents an audit log entry
@@ -37,6 +39,7 @@ type AuditLog struct {
 	Description string      `gorm:"column:description;size:500" json:"description"`
 	IPAddress   string      `gorm:"column:ip_address;size:50" json:"ip_address"`
 	UserAgent   string      `gorm:"column:user_agent;size:255" json:"user_agent"`
+	APIKeyID    *uint       `gorm:"column:api_key_id;index" json:"api_key_id,omitempty"` // Set when the call used an API key
 	CreatedAt   time.Time   `gorm:"column:created_at;index" json:"created_at"`
 }
 
@@ -121,6 +124,11 @@ type CommunicationRule struct {
 	SendToReseller bool      `gorm:"column:send_to_reseller;default:false" json:"send_to_reseller"`
 	ResellerID     *uint     `gorm:"column:reseller_id" json:"reseller_id"`    // nil = global
 	FUPLevels      string    `gorm:"column:fup_levels;size:20;default:'1,2,3'" json:"fup_levels"` // comma-separated: "1", "2", "3", "1,2", etc.
+	ServiceID      *uint     `gorm:"column:service_id" json:"service_id"`                         // Condition: nil = any service
+	Region         string    `gorm:"column:region;size:100" json:"region"`                        // Condition: empty = any region
+	OfflineHours   int       `gorm:"column:offline_hours;default:24" json:"offline_hours"`       // subscriber.offline: hours offline before firing
+	Subject        string    `gorm:"column:subject;size:255" json:"subject"`                      // Email subject template
+	Recipient      string    `gorm:"column:recipient;size:500" json:"recipient"`                  // Fixed recipients (comma-separated) instead of the subscriber
 	CreatedAt      time.Time `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt      time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
@@ -132,8 +140,10 @@ type CommunicationLog struct {
 	Recipient    string             `gorm:"column:recipient;size:255;not null" json:"recipient"`
 	Subject      string             `gorm:"column:subject;size:255" json:"subject"`
 	Message      string             `gorm:"column:message;type:text" json:"message"`
-	Status       string             `gorm:"column:status;size:20;default:pending" json:"status"` // pending, sent, failed
+	Status       string             `gorm:"column:status;size:20;default:pending" json:"status"` // pending, sent, failed, delivered, undelivered
 	ErrorMessage string             `gorm:"column:error_message;size:500" json:"error_message"`
+	GatewayID    *uint              `gorm:"column:gateway_id" json:"gateway_id"`                           // SMS gateway that sent it
+	ProviderID   string             `gorm:"column:provider_message_id;size:100" json:"provider_message_id"` // Matches delivery receipts
 	SubscriberID *uint              `gorm:"column:subscriber_id;index" json:"subscriber_id"`
 	Subscriber   *Subscriber        `gorm:"-" json:"subscriber,omitempty"`
 	ResellerID   *uint              `gorm:"column:reseller_id;index" json:"reseller_id"`
@@ -141,6 +151,7 @@ type CommunicationLog struct {
 	Rule         *CommunicationRule `gorm:"-" json:"rule,omitempty"`
 	CreatedAt    time.Time          `gorm:"column:created_at;index" json:"created_at"`
 	SentAt       *time.Time         `gorm:"column:sent_at" json:"sent_at"`
+	DeliveredAt  *time.Time         `gorm:"column:delivered_at" json:"delivered_at"` // When the delivery receipt arrived
 }
 
 func (AuditLog) TableName() string {
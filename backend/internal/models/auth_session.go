@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// AuthSessionTokenPrefix marks a refresh token
+const AuthSessionTokenPrefix = "prt_"
+
+// Reasons recorded when a session is revoked
+const (
+	SessionRevokeLogout     = "logout"
+	SessionRevokeUser       = "revoked_by_user"
+	SessionRevokeAdmin      = "revoked_by_admin"
+	SessionRevokePassword   = "password_changed"
+	SessionRevokeDisabled   = "user_disabled"
+	SessionRevokeTokenReuse = "refresh_token_reuse"
+)
+
+// How a session was authenticated
+const (
+	AuthMethodPassword = "password" // Password, plus a second factor when enrolled
+	AuthMethodSSO      = "sso"      // External identity provider, which owns the second factor
+)
+
+// AuthSession is one login of a panel user on one device.
+// Access tokens are short-lived JWTs carrying the session ID; the session is
+// kept alive by a rotating refresh token of which only the SHA-256 is stored.
+type AuthSession struct {
+	ID                uint       `gorm:"column:id;primaryKey" json:"id"`
+	UserID            uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	RefreshTokenHash  string     `gorm:"column:refresh_token_hash;size:64;uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"column:previous_token_hash;size:64;index" json:"-"` // Rotated-out token, to detect reuse
+	RotatedAt         *time.Time `gorm:"column:rotated_at" json:"rotated_at"`
+	Device            string     `gorm:"column:device;size:100" json:"device"`
+	UserAgent         string     `gorm:"column:user_agent;size:500" json:"user_agent"`
+	IPAddress         string     `gorm:"column:ip_address;size:50" json:"ip_address"`
+	LastActivityAt    time.Time  `gorm:"column:last_activity_at" json:"last_activity_at"`
+	LastActivityIP    string     `gorm:"column:last_activity_ip;size:50" json:"last_activity_ip"`
+	ImpersonatedBy    *uint      `gorm:"column:impersonated_by" json:"impersonated_by"`
+	AuthMethod        string     `gorm:"column:auth_method;size:20;default:password" json:"auth_method"`
+	ExpiresAt         time.Time  `gorm:"column:expires_at;index" json:"expires_at"`
+	RevokedAt         *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	RevokedBy         string     `gorm:"column:revoked_by;size:100" json:"revoked_by"`
+	RevokeReason      string     `gorm:"column:revoke_reason;size:50" json:"revoke_reason"`
+	CreatedAt         time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (AuthSession) TableName() string {
+	return "auth_sessions"
+}
+
+// IsActive returns true if the session is neither revoked nor expired
+func (s *AuthSession) IsActive() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
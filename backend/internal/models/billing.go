@@ -22,6 +22,7 @@ const (
 	TransactionTypeAddon        TransactionType = "addon"
 	TransactionTypePrepaidCard  TransactionType = "prepaid_card"
 	TransactionTypeRefill       TransactionType = "refill"
+	TransactionTypeCommission   TransactionType = "commission"
 )
 
 // PaymentStatus represents the status of a payment
@@ -141,6 +142,7 @@ type PrepaidCard struct {
 	// Card details
 	Value           float64        `gorm:"column:value;type:decimal(15,2);not null" json:"value"`
 	Days            int            `gorm:"column:days;default:30" json:"days"`
+	Hours           int            `gorm:"column:hours;default:0" json:"hours"` // Added to Days - short hotspot vouchers
 	QuotaRefill     int64          `gorm:"column:quota_refill;default:0" json:"quota_refill"` // bytes
 
 	// Status
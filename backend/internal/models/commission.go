@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// CommissionPlan decides what ancestors of a selling reseller earn. A reseller uses the
+// plan set on it (Reseller.CommissionPlanID) or else the default plan.
+type CommissionPlan struct {
+	ID          uint             `gorm:"column:id;primaryKey" json:"id"`
+	Name        string           `gorm:"column:name;size:100;not null;uniqueIndex" json:"name"`
+	Description string           `gorm:"column:description;size:255" json:"description"`
+	IsDefault   bool             `gorm:"column:is_default;default:false" json:"is_default"`
+	IsActive    bool             `gorm:"column:is_active;default:true" json:"is_active"`
+	Rules       []CommissionRule `gorm:"foreignKey:PlanID" json:"rules"`
+	CreatedAt   time.Time        `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time        `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (CommissionPlan) TableName() string {
+	return "commission_plans"
+}
+
+// CommissionRule is one rate of a plan. Tier is the distance from the selling reseller:
+// 1 is its parent, 2 the grandparent and so on. A rule with a service beats one without.
+type CommissionRule struct {
+	ID        uint    `gorm:"column:id;primaryKey" json:"id"`
+	PlanID    uint    `gorm:"column:plan_id;not null;index" json:"plan_id"`
+	ServiceID *uint   `gorm:"column:service_id" json:"service_id"` // nil = any service
+	Tier      int     `gorm:"column:tier;not null;default:1" json:"tier"`
+	Type      string  `gorm:"column:type;size:10;not null" json:"type"` // percent, fixed
+	Value     float64 `gorm:"column:value;type:decimal(15,4);not null" json:"value"`
+}
+
+func (CommissionRule) TableName() string {
+	return "commission_rules"
+}
+
+// Commission is a ledger entry earned by a reseller from a sale further down its tree
+type Commission struct {
+	ID               uint      `gorm:"column:id;primaryKey" json:"id"`
+	ResellerID       uint      `gorm:"column:reseller_id;not null;index" json:"reseller_id"` // Earner
+	SourceResellerID uint      `gorm:"column:source_reseller_id;not null" json:"source_reseller_id"`
+	SourceReseller   string    `gorm:"-" json:"source_reseller,omitempty"`
+	TransactionID    uint      `gorm:"column:transaction_id;not null" json:"transaction_id"`
+	SubscriberID     *uint     `gorm:"column:subscriber_id" json:"subscriber_id"`
+	ServiceID        *uint     `gorm:"column:service_id" json:"service_id"`
+	SaleType         string    `gorm:"column:sale_type;size:20" json:"sale_type"` // new, renewal
+	Tier             int       `gorm:"column:tier" json:"tier"`
+	BaseAmount       float64   `gorm:"column:base_amount;type:decimal(15,2)" json:"base_amount"`
+	RateType         string    `gorm:"column:rate_type;size:10" json:"rate_type"`
+	RateValue        float64   `gorm:"column:rate_value;type:decimal(15,4)" json:"rate_value"`
+	Amount           float64   `gorm:"column:amount;type:decimal(15,2)" json:"amount"`
+	SettlementID     *uint     `gorm:"column:settlement_id;index" json:"settlement_id"`
+	CreatedAt        time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (Commission) TableName() string {
+	return "commissions"
+}
+
+// CommissionSettlement is a reseller's monthly commission statement
+type CommissionSettlement struct {
+	ID            uint       `gorm:"column:id;primaryKey" json:"id"`
+	ResellerID    uint       `gorm:"column:reseller_id;not null;uniqueIndex:idx_commission_settlement_period" json:"reseller_id"`
+	Reseller      *Reseller  `gorm:"foreignKey:ResellerID" json:"reseller,omitempty"`
+	Period        string     `gorm:"column:period;size:7;not null;uniqueIndex:idx_commission_settlement_period" json:"period"` // YYYY-MM
+	PeriodStart   time.Time  `gorm:"column:period_start" json:"period_start"`
+	PeriodEnd     time.Time  `gorm:"column:period_end" json:"period_end"`
+	EntryCount    int        `gorm:"column:entry_count" json:"entry_count"`
+	Total         float64    `gorm:"column:total;type:decimal(15,2)" json:"total"`
+	Status        string     `gorm:"column:status;size:20;default:open" json:"status"`  // open, paid
+	PayoutMethod  string     `gorm:"column:payout_method;size:20" json:"payout_method"` // balance, external
+	Reference     string     `gorm:"column:reference;size:100" json:"reference"`
+	Notes         string     `gorm:"column:notes;type:text" json:"notes"`
+	TransactionID *uint      `gorm:"column:transaction_id" json:"transaction_id"` // Balance credit of a balance payout
+	PaidAt        *time.Time `gorm:"column:paid_at" json:"paid_at"`
+	PaidBy        *uint      `gorm:"column:paid_by" json:"paid_by"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (CommissionSettlement) TableName() string {
+	return "commission_settlements"
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// PortalPermissions controls which self-service actions a reseller's subscribers
+// can take from the customer portal. Resellers without a row get DefaultPortalPermissions.
+type PortalPermissions struct {
+	ID                  uint      `gorm:"column:id;primaryKey" json:"id"`
+	ResellerID          uint      `gorm:"column:reseller_id;not null;uniqueIndex" json:"reseller_id"`
+	AllowRenew          bool      `gorm:"column:allow_renew;default:true" json:"allow_renew"`
+	AllowWalletTopUp    bool      `gorm:"column:allow_wallet_topup;default:true" json:"allow_wallet_topup"`
+	AllowChangePlan     bool      `gorm:"column:allow_change_plan;default:false" json:"allow_change_plan"`
+	AllowResetFUP       bool      `gorm:"column:allow_reset_fup;default:true" json:"allow_reset_fup"`
+	AllowContactUpdate  bool      `gorm:"column:allow_contact_update;default:true" json:"allow_contact_update"`
+	AllowPasswordChange bool      `gorm:"column:allow_password_change;default:true" json:"allow_password_change"`
+	CreatedAt           time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt           time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (PortalPermissions) TableName() string {
+	return "portal_permissions"
+}
+
+// DefaultPortalPermissions returns the permissions used until a reseller saves their own
+func DefaultPortalPermissions(resellerID uint) PortalPermissions {
+	return PortalPermissions{
+		ResellerID:          resellerID,
+		AllowRenew:          true,
+		AllowWalletTopUp:    true,
+		AllowChangePlan:     false,
+		AllowResetFUP:       true,
+		AllowContactUpdate:  true,
+		AllowPasswordChange: true,
+	}
+}
+
+// WalletTransactionType represents a movement on a subscriber's wallet
+type WalletTransactionType string
+
+const (
+	WalletTransactionTopUp         WalletTransactionType = "topup"
+	WalletTransactionRenewal       WalletTransactionType = "renewal"
+	WalletTransactionChangeService WalletTransactionType = "change_service"
+	WalletTransactionResetFUP      WalletTransactionType = "reset_fup"
+)
+
+// WalletTransaction records a credit or debit on Subscriber.WalletBalance
+type WalletTransaction struct {
+	ID           uint                  `gorm:"column:id;primaryKey" json:"id"`
+	SubscriberID uint                  `gorm:"column:subscriber_id;not null;index" json:"subscriber_id"`
+	ResellerID   uint                  `gorm:"column:reseller_id;not null;index" json:"reseller_id"`
+	Type         WalletTransactionType `gorm:"column:type;size:30;not null" json:"type"`
+	Amount       float64               `gorm:"column:amount;type:decimal(15,2);not null" json:"amount"` // Positive for credit, negative for debit
+	BalanceAfter float64               `gorm:"column:balance_after;type:decimal(15,2)" json:"balance_after"`
+	Reference    string                `gorm:"column:reference;size:100" json:"reference"` // Prepaid card code, service name, ...
+	Description  string                `gorm:"column:description;size:500" json:"description"`
+	IPAddress    string                `gorm:"column:ip_address;size:50" json:"ip_address"`
+	CreatedAt    time.Time             `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (WalletTransaction) TableName() string {
+	return "wallet_transactions"
+}
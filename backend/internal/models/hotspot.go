@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// HotspotWalledGarden is a destination reachable before hotspot login (payment pages, captive portal assets).
+// Entries with DstHost go to /ip/hotspot/walled-garden, entries with DstAddress to /ip/hotspot/walled-garden/ip.
+type HotspotWalledGarden struct {
+	ID         uint      `gorm:"column:id;primaryKey" json:"id"`
+	NasID      *uint     `gorm:"column:nas_id;index" json:"nas_id"`                 // nil = all hotspot routers
+	DstHost    string    `gorm:"column:dst_host;size:255" json:"dst_host"`          // e.g. *.paypal.com
+	DstAddress string    `gorm:"column:dst_address;size:50" json:"dst_address"`     // e.g. 203.0.113.0/24
+	DstPort    string    `gorm:"column:dst_port;size:50" json:"dst_port"`           // Optional, e.g. 443
+	Action     string    `gorm:"column:action;size:10;default:allow" json:"action"` // allow, deny
+	Comment    string    `gorm:"column:comment;size:255" json:"comment"`
+	Enabled    bool      `gorm:"column:enabled;default:true" json:"enabled"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (HotspotWalledGarden) TableName() string {
+	return "hotspot_walled_garden"
+}
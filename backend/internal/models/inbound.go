@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Chatbot commands recorded on inbound messages
+const (
+	InboundCommandHelp    = "help"
+	InboundCommandBalance = "balance"
+	InboundCommandUsage   = "usage"
+	InboundCommandRenew   = "renew"
+	InboundCommandSupport = "support" // "support <text>" and free text, opens or updates a ticket
+	InboundCommandIgnored = "ignored" // Chatbot disabled or sender over the hourly limit - no reply
+)
+
+// InboundMessage is a WhatsApp or SMS message received from a customer, with the
+// chatbot's reply. Together with the replies in outbound_messages it is the
+// conversation log.
+type InboundMessage struct {
+	ID                uint      `gorm:"column:id;primaryKey" json:"id"`
+	Channel           string    `gorm:"column:channel;size:20;not null" json:"channel"` // whatsapp, sms
+	Phone             string    `gorm:"column:phone;size:50;not null;index" json:"phone"`
+	Account           string    `gorm:"column:account;size:100" json:"account"` // Receiving ProxRad account, "" = admin's
+	ProviderMessageID string    `gorm:"column:provider_message_id;size:100" json:"provider_message_id"`
+	Body              string    `gorm:"column:body;type:text" json:"body"`
+	SubscriberID      *uint     `gorm:"column:subscriber_id;index" json:"subscriber_id"`
+	ResellerID        *uint     `gorm:"column:reseller_id" json:"reseller_id"`
+	Command           string    `gorm:"column:command;size:20" json:"command"`
+	Reply             string    `gorm:"column:reply;type:text" json:"reply"`
+	TicketID          *uint     `gorm:"column:ticket_id" json:"ticket_id"`
+	CreatedAt         time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (InboundMessage) TableName() string {
+	return "inbound_messages"
+}
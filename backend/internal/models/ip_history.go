@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// IPAssignment records that a subscriber held an address for one accounting session.
+// Rows are written by the IP history service and are immutable in the database: an
+// open row can only be closed, and rows are only deleted by the retention purge.
+type IPAssignment struct {
+	ID             int64      `gorm:"column:id;primaryKey" json:"id"`
+	IPAddress      string     `gorm:"column:ip_address;size:45" json:"ip_address"`
+	Username       string     `gorm:"column:username;size:64" json:"username"`
+	SubscriberID   *uint      `gorm:"column:subscriber_id" json:"subscriber_id"`
+	NasID          *uint      `gorm:"column:nas_id" json:"nas_id"`
+	NasIPAddress   string     `gorm:"column:nas_ip_address;size:15" json:"nas_ip_address"`
+	MACAddress     string     `gorm:"column:mac_address;size:50" json:"mac_address"`
+	AcctSessionID  string     `gorm:"column:acct_session_id;size:64" json:"acct_session_id"`
+	AcctUniqueID   *string    `gorm:"column:acct_unique_id;size:32" json:"acct_unique_id"`
+	StartedAt      time.Time  `gorm:"column:started_at" json:"started_at"`
+	StoppedAt      *time.Time `gorm:"column:stopped_at" json:"stopped_at"` // nil while the session is open
+	TerminateCause string     `gorm:"column:terminate_cause;size:32" json:"terminate_cause"`
+	Source         string     `gorm:"column:source;size:20" json:"source"` // accounting, archive
+
+	// CGNAT translation of IPAddress while the session was open; empty for public addresses
+	CGNATPoolID     *uint  `gorm:"column:cgnat_pool_id" json:"cgnat_pool_id"`
+	PublicIP        string `gorm:"column:public_ip;size:15" json:"public_ip"`
+	PublicPortStart *int   `gorm:"column:public_port_start" json:"public_port_start"`
+	PublicPortEnd   *int   `gorm:"column:public_port_end" json:"public_port_end"`
+
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (IPAssignment) TableName() string {
+	return "ip_assignments"
+}
+
+// IPHistoryExport is a file produced for a law-enforcement request. The stored content
+// is exactly what was handed out, with its SHA-256 and Ed25519 signature.
+type IPHistoryExport struct {
+	ID              uint      `gorm:"column:id;primaryKey" json:"id"`
+	CaseReference   string    `gorm:"column:case_reference;size:100" json:"case_reference"`
+	RequestedBy     uint      `gorm:"column:requested_by" json:"requested_by"`
+	RequestedByName string    `gorm:"column:requested_by_name;size:100" json:"requested_by_name"`
+	Query           string    `gorm:"column:query;type:text" json:"query"` // JSON of the export criteria
+	RecordCount     int       `gorm:"column:record_count" json:"record_count"`
+	FileName        string    `gorm:"column:file_name;size:255" json:"file_name"`
+	Content         string    `gorm:"column:content;type:text" json:"-"`
+	SHA256          string    `gorm:"column:sha256;size:64" json:"sha256"`
+	Signature       string    `gorm:"column:signature;type:text" json:"signature"`
+	CreatedAt       time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (IPHistoryExport) TableName() string {
+	return "ip_history_exports"
+}
@@ -42,3 +42,42 @@ func (ip *IPPoolAssignment) IsAvailable() bool {
 func (ip *IPPoolAssignment) IsInUse() bool {
 	return ip.Status == IPPoolStatusInUse
 }
+
+// CGNATPool translates a range of private subscriber addresses to a smaller range of
+// public addresses, giving every private address its own fixed block of ports
+type CGNATPool struct {
+	ID            uint       `gorm:"column:id;primaryKey" json:"id"`
+	Name          string     `gorm:"column:name;size:64;uniqueIndex" json:"name"`
+	PoolName      string     `gorm:"column:pool_name;size:64" json:"pool_name"` // IP pool the private addresses are handed out from
+	NasID         *uint      `gorm:"column:nas_id" json:"nas_id"`               // Router the src-nat rules are pushed to
+	PrivateRanges string     `gorm:"column:private_ranges;type:text" json:"private_ranges"`
+	PublicRanges  string     `gorm:"column:public_ranges;type:text" json:"public_ranges"`
+	PortStart     int        `gorm:"column:port_start;default:1024" json:"port_start"`
+	PortEnd       int        `gorm:"column:port_end;default:65535" json:"port_end"`
+	PortsPerBlock int        `gorm:"column:ports_per_block;default:2016" json:"ports_per_block"`
+	IsActive      bool       `gorm:"column:is_active;default:true" json:"is_active"`
+	SyncedAt      *time.Time `gorm:"column:synced_at" json:"synced_at"`
+	SyncError     string     `gorm:"column:sync_error;type:text" json:"sync_error"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updated_at"`
+
+	Nas *Nas `gorm:"foreignKey:NasID" json:"nas,omitempty"`
+}
+
+func (CGNATPool) TableName() string {
+	return "cgnat_pools"
+}
+
+// CGNATPortBlock is the public address and port range one private address is translated to
+type CGNATPortBlock struct {
+	ID          uint   `gorm:"column:id;primaryKey" json:"id"`
+	CGNATPoolID uint   `gorm:"column:cgnat_pool_id;index" json:"cgnat_pool_id"`
+	PrivateIP   string `gorm:"column:private_ip;size:15;uniqueIndex" json:"private_ip"`
+	PublicIP    string `gorm:"column:public_ip;size:15" json:"public_ip"`
+	PortStart   int    `gorm:"column:port_start" json:"port_start"`
+	PortEnd     int    `gorm:"column:port_end" json:"port_end"`
+}
+
+func (CGNATPortBlock) TableName() string {
+	return "cgnat_port_blocks"
+}
@@ -45,9 +45,31 @@ type Nas struct {
 	// PCQ/CDN Settings
 	SubscriberPools string         `gorm:"column:subscriber_pools;size:500" json:"subscriber_pools"` // Comma-separated pool names or CIDRs for PCQ target
 
+	// Hotspot (captive portal) enabled on this router
+	HotspotEnabled  bool           `gorm:"column:hotspot_enabled;default:false" json:"hotspot_enabled"`
+
+	// IPoE (DHCP + RADIUS): how DHCP Access-Requests identify the subscriber
+	IPoEAuthMode    string         `gorm:"column:ipoe_auth_mode;size:20" json:"ipoe_auth_mode"` // "" (disabled), mac, option82, both
+
 	// Realm Settings (for RADIUS authentication)
 	AllowedRealms   string         `gorm:"column:allowed_realms;size:500" json:"allowed_realms"` // Comma-separated list of allowed realms (e.g., "test.mes.net.lb,other.domain.com")
 
+	// Health Monitoring (RouterOS API for MikroTik, SNMP for other types)
+	MonitorEnabled   bool   `gorm:"column:monitor_enabled;default:true" json:"monitor_enabled"`
+	SNMPVersion      string `gorm:"column:snmp_version;size:5" json:"snmp_version"` // "" (disabled), v2c, v3
+	SNMPPort         int    `gorm:"column:snmp_port;default:161" json:"snmp_port"`
+	SNMPCommunity    string `gorm:"column:snmp_community;size:100" json:"-"` // Hidden from API responses for security
+	SNMPUsername     string `gorm:"column:snmp_username;size:100" json:"snmp_username"`
+	SNMPAuthProtocol string `gorm:"column:snmp_auth_protocol;size:10" json:"snmp_auth_protocol"` // MD5, SHA, SHA256, SHA512
+	SNMPAuthPassword string `gorm:"column:snmp_auth_password;size:255" json:"-"`
+	SNMPPrivProtocol string `gorm:"column:snmp_priv_protocol;size:10" json:"snmp_priv_protocol"` // DES, AES, AES256
+	SNMPPrivPassword string `gorm:"column:snmp_priv_password;size:255" json:"-"`
+
+	// Provisioning (last automatic onboarding run)
+	ProvisionedAt    *time.Time `gorm:"column:provisioned_at" json:"provisioned_at"`
+	ProvisionStatus  string     `gorm:"column:provision_status;size:20" json:"provision_status"` // "", success, partial, failed
+	ProvisionMessage string     `gorm:"column:provision_message;size:500" json:"provision_message"`
+
 	// Status
 	IsActive        bool           `gorm:"column:is_active;default:true" json:"is_active"`
 	IsOnline        bool           `gorm:"column:is_online;default:false" json:"is_online"`
@@ -68,6 +90,11 @@ func (Nas) TableName() string {
 	return "nas_devices"
 }
 
+// UsesSNMP returns true if health data should be polled over SNMP instead of the RouterOS API
+func (n *Nas) UsesSNMP() bool {
+	return n.SNMPVersion != "" && (n.Type != NasTypeMikrotik || n.APIUsername == "")
+}
+
 // GetSecretForRADIUS returns the RADIUS shared secret
 func (n *Nas) GetSecretForRADIUS() []byte {
 	return []byte(n.Secret)
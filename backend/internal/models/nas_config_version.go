@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// NasConfigVersion is one distinct RouterOS export of a NAS. Exports identical to the
+// previous version only bump LastSeenAt, so every row is a real configuration change.
+type NasConfigVersion struct {
+	ID                uint      `gorm:"column:id;primaryKey" json:"id"`
+	NasID             uint      `gorm:"column:nas_id;not null;index" json:"nas_id"`
+	NasName           string    `gorm:"column:nas_name;size:100" json:"nas_name"`
+	Version           int       `gorm:"column:version;not null" json:"version"` // Sequential per NAS
+	Content           string    `gorm:"column:content;type:text" json:"-"`      // Raw export, returned redacted by the API
+	ContentHash       string    `gorm:"column:content_hash;size:64;index" json:"content_hash"`
+	SizeBytes         int       `gorm:"column:size_bytes;default:0" json:"size_bytes"`
+	LineCount         int       `gorm:"column:line_count;default:0" json:"line_count"`
+	AddedLines        int       `gorm:"column:added_lines;default:0" json:"added_lines"` // Compared to the previous version
+	RemovedLines      int       `gorm:"column:removed_lines;default:0" json:"removed_lines"`
+	Source            string    `gorm:"column:source;size:20" json:"source"` // backup, manual, restore
+	ChangedExternally bool      `gorm:"column:changed_externally;default:false" json:"changed_externally"`
+	CreatedBy         string    `gorm:"column:created_by;size:100" json:"created_by"`
+	LastSeenAt        time.Time `gorm:"column:last_seen_at" json:"last_seen_at"`
+	CreatedAt         time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (NasConfigVersion) TableName() string {
+	return "nas_config_versions"
+}
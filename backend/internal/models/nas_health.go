@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NasHealthEventType represents the kind of health event detected on a NAS
+type NasHealthEventType string
+
+const (
+	NasEventOffline         NasHealthEventType = "offline"
+	NasEventOnline          NasHealthEventType = "online"
+	NasEventReboot          NasHealthEventType = "reboot"
+	NasEventOverload        NasHealthEventType = "overload"
+	NasEventOverloadCleared NasHealthEventType = "overload_cleared"
+	NasEventConfigChanged   NasHealthEventType = "config_changed"
+)
+
+// NasHealthSample is one poll of a NAS device's health counters (time series)
+type NasHealthSample struct {
+	ID             uint            `gorm:"column:id;primaryKey" json:"id"`
+	NasID          uint            `gorm:"column:nas_id;not null;index" json:"nas_id"`
+	Source         string          `gorm:"column:source;size:10" json:"source"` // api, snmp
+	CPULoad        int             `gorm:"column:cpu_load;default:0" json:"cpu_load"`
+	MemoryUsed     int64           `gorm:"column:memory_used;default:0" json:"memory_used"`
+	MemoryTotal    int64           `gorm:"column:memory_total;default:0" json:"memory_total"`
+	UptimeSeconds  int64           `gorm:"column:uptime_seconds;default:0" json:"uptime_seconds"`
+	RxBytes        int64           `gorm:"column:rx_bytes;default:0" json:"rx_bytes"` // Sum of interface counters
+	TxBytes        int64           `gorm:"column:tx_bytes;default:0" json:"tx_bytes"`
+	RxBps          int64           `gorm:"column:rx_bps;default:0" json:"rx_bps"` // Rate since previous sample
+	TxBps          int64           `gorm:"column:tx_bps;default:0" json:"tx_bps"`
+	ActiveSessions int             `gorm:"column:active_sessions;default:0" json:"active_sessions"`
+	Interfaces     json.RawMessage `gorm:"column:interfaces;type:jsonb" json:"interfaces"`
+	CreatedAt      time.Time       `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (NasHealthSample) TableName() string {
+	return "nas_health_samples"
+}
+
+// MemoryPercent returns memory usage as a percentage (0 if unknown)
+func (s *NasHealthSample) MemoryPercent() int {
+	if s.MemoryTotal <= 0 {
+		return 0
+	}
+	return int(s.MemoryUsed * 100 / s.MemoryTotal)
+}
+
+// NasHealthEvent records a state change detected by the NAS monitor
+type NasHealthEvent struct {
+	ID        uint               `gorm:"column:id;primaryKey" json:"id"`
+	NasID     uint               `gorm:"column:nas_id;not null;index" json:"nas_id"`
+	NasName   string             `gorm:"column:nas_name;size:100" json:"nas_name"`
+	EventType NasHealthEventType `gorm:"column:event_type;size:30;not null;index" json:"event_type"`
+	Severity  string             `gorm:"column:severity;size:20;default:warning" json:"severity"` // info, warning, critical
+	Message   string             `gorm:"column:message;size:500" json:"message"`
+	Notified  bool               `gorm:"column:notified;default:false" json:"notified"`
+	CreatedAt time.Time          `gorm:"column:created_at;index" json:"created_at"`
+}
+
+func (NasHealthEvent) TableName() string {
+	return "nas_health_events"
+}
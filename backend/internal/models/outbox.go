@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Outbound message states
+const (
+	OutboundStatusPending   = "pending" // Waiting for the first attempt, a retry or the end of quiet hours
+	OutboundStatusSent      = "sent"
+	OutboundStatusFailed    = "failed" // Gave up after MaxAttempts - can be resent from the outbox view
+	OutboundStatusCancelled = "cancelled"
+)
+
+// OutboundSenderAdmin sends WhatsApp from the admin account even when the message
+// concerns a reseller's subscriber
+const OutboundSenderAdmin = "admin"
+
+// OutboundMessage is an SMS, WhatsApp or email message in the outbox. Messages are
+// queued by notifications, communication rules and bulk sends and delivered by the
+// outbox workers with retries; the outcome is also written to communication_logs.
+type OutboundMessage struct {
+	ID            uint       `gorm:"column:id;primaryKey" json:"id"`
+	Channel       string     `gorm:"column:channel;size:20;not null;index" json:"channel"` // sms, whatsapp, email
+	Recipient     string     `gorm:"column:recipient;size:255;not null" json:"recipient"`
+	Subject       string     `gorm:"column:subject;size:255" json:"subject"`
+	Body          string     `gorm:"column:body;type:text" json:"body"`
+	IsHTML        bool       `gorm:"column:is_html;default:false" json:"is_html"`
+	Sender        string     `gorm:"column:sender;size:100" json:"sender"`       // WhatsApp: "" = by subscriber, admin, or a ProxRad account
+	Source        string     `gorm:"column:source;size:30;index" json:"source"`  // rule, notification, bulk, reseller_bulk
+	DedupKey      *string    `gorm:"column:dedup_key;size:200" json:"dedup_key"` // Unique; a repeated key is not queued again
+	SubscriberID  *uint      `gorm:"column:subscriber_id;index" json:"subscriber_id"`
+	ResellerID    *uint      `gorm:"column:reseller_id;index" json:"reseller_id"`
+	RuleID        *uint      `gorm:"column:rule_id" json:"rule_id"`
+	Timezone      string     `gorm:"column:timezone;size:64" json:"timezone"`   // Recipient's timezone for quiet hours
+	Urgent        bool       `gorm:"column:urgent;default:false" json:"urgent"` // Ignores quiet hours
+	Status        string     `gorm:"column:status;size:20;default:pending;index" json:"status"`
+	Attempts      int        `gorm:"column:attempts;default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"column:max_attempts;default:6" json:"max_attempts"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;index" json:"next_attempt_at"`
+	LastAttemptAt *time.Time `gorm:"column:last_attempt_at" json:"last_attempt_at"`
+	LastError     string     `gorm:"column:last_error;type:text" json:"last_error"`
+	SentAt        *time.Time `gorm:"column:sent_at" json:"sent_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at;index" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (OutboundMessage) TableName() string {
+	return "outbound_messages"
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// ReportDefinition is a saved report: a report type with its filters, the formats to
+// render and, optionally, a schedule on which the result is emailed to Recipients
+type ReportDefinition struct {
+	ID           uint       `gorm:"column:id;primaryKey" json:"id"`
+	Name         string     `gorm:"column:name;size:100;not null" json:"name"`
+	ReportType   string     `gorm:"column:report_type;size:30;not null" json:"report_type"`        // see reports.Types
+	DateRange    string     `gorm:"column:date_range;size:30;default:yesterday" json:"date_range"` // see reports.Range, or custom
+	DateFrom     *time.Time `gorm:"column:date_from" json:"date_from"`                             // custom range only
+	DateTo       *time.Time `gorm:"column:date_to" json:"date_to"`
+	ResellerID   *uint      `gorm:"column:reseller_id" json:"reseller_id"`
+	ServiceID    *uint      `gorm:"column:service_id" json:"service_id"`
+	NasID        *uint      `gorm:"column:nas_id" json:"nas_id"`
+	Formats      string     `gorm:"column:formats;size:50;default:csv" json:"formats"`    // comma-separated: csv, xlsx, pdf
+	Schedule     string     `gorm:"column:schedule;size:10;default:none" json:"schedule"` // none, daily, weekly, monthly
+	ScheduleDay  int        `gorm:"column:schedule_day;default:1" json:"schedule_day"`    // weekday 0-6 (weekly) or day of month 1-28 (monthly)
+	ScheduleHour int        `gorm:"column:schedule_hour;default:6" json:"schedule_hour"`  // 0-23, server time
+	Recipients   string     `gorm:"column:recipients;type:text" json:"recipients"`        // comma-separated emails
+	IsActive     bool       `gorm:"column:is_active;default:true" json:"is_active"`
+	LastRunAt    *time.Time `gorm:"column:last_run_at" json:"last_run_at"`
+	NextRunAt    *time.Time `gorm:"column:next_run_at" json:"next_run_at"`
+	CreatedBy    uint       `gorm:"column:created_by" json:"created_by"`
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (ReportDefinition) TableName() string {
+	return "report_definitions"
+}
+
+// ReportRun is one rendered file of a report, kept for download
+type ReportRun struct {
+	ID           uint      `gorm:"column:id;primaryKey" json:"id"`
+	DefinitionID *uint     `gorm:"column:definition_id;index" json:"definition_id"` // nil for ad-hoc runs
+	ReportType   string    `gorm:"column:report_type;size:30" json:"report_type"`
+	Format       string    `gorm:"column:format;size:10" json:"format"`
+	Params       string    `gorm:"column:params;type:text" json:"params"` // JSON of the resolved filters
+	FileName     string    `gorm:"column:file_name;size:255" json:"file_name"`
+	Content      []byte    `gorm:"column:content" json:"-"`
+	Size         int       `gorm:"column:size" json:"size"`
+	RowCount     int       `gorm:"column:row_count" json:"row_count"`
+	Status       string    `gorm:"column:status;size:20" json:"status"` // completed, failed
+	Error        string    `gorm:"column:error;type:text" json:"error"`
+	EmailedTo    string    `gorm:"column:emailed_to;type:text" json:"emailed_to"`
+	Source       string    `gorm:"column:source;size:20" json:"source"` // schedule, manual
+	CreatedBy    uint      `gorm:"column:created_by" json:"created_by"`
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (ReportRun) TableName() string {
+	return "report_runs"
+}
@@ -1339,3 +1339,849 @@ DO $$ BEGIN
         ALTER TABLE services ADD COLUMN monthly_fup6_upload_speed BIGINT DEFAULT 0;
     END IF;
 END $$;
+
+-- NAS health monitoring (v1.0.364+)
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS monitor_enabled BOOLEAN DEFAULT true;
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_version VARCHAR(5) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_port INTEGER DEFAULT 161;
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_community VARCHAR(100) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_username VARCHAR(100) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_auth_protocol VARCHAR(10) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_auth_password VARCHAR(255) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_priv_protocol VARCHAR(10) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS snmp_priv_password VARCHAR(255) DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS nas_health_samples (
+    id BIGSERIAL PRIMARY KEY,
+    nas_id INTEGER NOT NULL,
+    source VARCHAR(10),
+    cpu_load INTEGER DEFAULT 0,
+    memory_used BIGINT DEFAULT 0,
+    memory_total BIGINT DEFAULT 0,
+    uptime_seconds BIGINT DEFAULT 0,
+    rx_bytes BIGINT DEFAULT 0,
+    tx_bytes BIGINT DEFAULT 0,
+    rx_bps BIGINT DEFAULT 0,
+    tx_bps BIGINT DEFAULT 0,
+    active_sessions INTEGER DEFAULT 0,
+    interfaces JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_nas_health_samples_nas_time ON nas_health_samples(nas_id, created_at);
+
+CREATE TABLE IF NOT EXISTS nas_health_events (
+    id SERIAL PRIMARY KEY,
+    nas_id INTEGER NOT NULL,
+    nas_name VARCHAR(100),
+    event_type VARCHAR(30) NOT NULL,
+    severity VARCHAR(20) DEFAULT 'warning',
+    message VARCHAR(500),
+    notified BOOLEAN DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_nas_health_events_nas_id ON nas_health_events(nas_id);
+CREATE INDEX IF NOT EXISTS idx_nas_health_events_created_at ON nas_health_events(created_at);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('nas_monitor_cpu_threshold', '90', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('nas_monitor_memory_threshold', '90', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('nas_monitor_retention_days', '30', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- NAS onboarding / auto-provisioning (v1.0.365+)
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS provisioned_at TIMESTAMP;
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS provision_status VARCHAR(20) DEFAULT '';
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS provision_message VARCHAR(500) DEFAULT '';
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('radius_server_address', '', 'string') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('nas_interim_update_minutes', '5', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- NAS config versions, diffs and restore (v1.0.366+)
+CREATE TABLE IF NOT EXISTS nas_config_versions (
+    id SERIAL PRIMARY KEY,
+    nas_id INTEGER NOT NULL,
+    nas_name VARCHAR(100),
+    version INTEGER NOT NULL,
+    content TEXT,
+    content_hash VARCHAR(64),
+    size_bytes INTEGER DEFAULT 0,
+    line_count INTEGER DEFAULT 0,
+    added_lines INTEGER DEFAULT 0,
+    removed_lines INTEGER DEFAULT 0,
+    source VARCHAR(20),
+    changed_externally BOOLEAN DEFAULT false,
+    created_by VARCHAR(100),
+    last_seen_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(nas_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_nas_config_versions_nas_id ON nas_config_versions(nas_id);
+CREATE INDEX IF NOT EXISTS idx_nas_config_versions_created_at ON nas_config_versions(created_at);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('nas_config_versions_keep', '100', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('nas_config_change_alerts', 'true', 'bool') ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES ('nas.config_restore', 'Push a stored config export back to a NAS') ON CONFLICT (name) DO NOTHING;
+
+-- Hotspot (captive portal) subscribers (v1.0.367+)
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS connection_type VARCHAR(20) DEFAULT 'pppoe';
+CREATE INDEX IF NOT EXISTS idx_subscribers_connection_type ON subscribers(connection_type);
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS hotspot_enabled BOOLEAN DEFAULT false;
+ALTER TABLE prepaid_cards ADD COLUMN IF NOT EXISTS hours INTEGER DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS hotspot_walled_garden (
+    id SERIAL PRIMARY KEY,
+    nas_id INTEGER,
+    dst_host VARCHAR(255),
+    dst_address VARCHAR(50),
+    dst_port VARCHAR(50),
+    action VARCHAR(10) DEFAULT 'allow',
+    comment VARCHAR(255),
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_hotspot_walled_garden_nas_id ON hotspot_walled_garden(nas_id);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('hotspot_voucher_login', 'true', 'bool') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('hotspot_mac_auth_password', '', 'string') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('hotspot_idle_timeout', '300', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('hotspot_session_timeout', '0', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- IPoE (DHCP + RADIUS) subscribers (v1.0.368+)
+ALTER TABLE nas_devices ADD COLUMN IF NOT EXISTS ipoe_auth_mode VARCHAR(20) DEFAULT '';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS ipoe BOOLEAN DEFAULT false;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS lease_time INTEGER DEFAULT 0;
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS circuit_id VARCHAR(255);
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS remote_id VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_subscribers_circuit_id ON subscribers(circuit_id);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('ipoe_lease_time', '3600', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- Scoped API keys (v1.0.369+)
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(20),
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    username VARCHAR(100),
+    reseller_id INTEGER,
+    scopes TEXT,
+    allowed_ips TEXT,
+    expires_at TIMESTAMP,
+    is_active BOOLEAN DEFAULT true,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(50),
+    usage_count BIGINT DEFAULT 0,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_reseller_id ON api_keys(reseller_id);
+
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS api_key_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_audit_logs_api_key_id ON audit_logs(api_key_id);
+
+INSERT INTO permissions (name, description) VALUES ('api_keys.manage', 'Create and revoke API keys') ON CONFLICT (name) DO NOTHING;
+
+-- Outbound webhooks (v1.0.370+)
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    url VARCHAR(500) NOT NULL,
+    secret VARCHAR(100),
+    events TEXT,
+    is_active BOOLEAN DEFAULT true,
+    max_attempts INTEGER DEFAULT 8,
+    timeout_seconds INTEGER DEFAULT 10,
+    last_success_at TIMESTAMP,
+    last_failure_at TIMESTAMP,
+    last_error TEXT,
+    created_by VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    endpoint_id INTEGER NOT NULL,
+    event_id VARCHAR(40),
+    event VARCHAR(50),
+    payload TEXT,
+    status VARCHAR(20) DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMP,
+    response_code INTEGER DEFAULT 0,
+    response_body TEXT,
+    last_error TEXT,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_id ON webhook_deliveries(endpoint_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_id ON webhook_deliveries(event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+
+-- Endpoints of a reseller only receive that reseller's events (NULL = all events)
+ALTER TABLE webhook_endpoints ADD COLUMN IF NOT EXISTS reseller_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_webhook_endpoints_reseller_id ON webhook_endpoints(reseller_id);
+UPDATE webhook_endpoints e SET reseller_id = u.reseller_id FROM users u
+WHERE e.reseller_id IS NULL AND u.username = e.created_by AND u.reseller_id IS NOT NULL;
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('webhook_retention_days', '30', 'int') ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES ('webhooks.manage', 'Manage webhooks and replay deliveries') ON CONFLICT (name) DO NOTHING;
+
+-- Role-based permissions for all staff users (v1.0.371+)
+CREATE TABLE IF NOT EXISTS user_scopes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    resource_type VARCHAR(20) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_scope ON user_scopes(user_id, resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_users_permission_group ON users(permission_group);
+
+-- Permissions are now deny-by-default: a reseller without a group gets nothing.
+-- Resellers that relied on the old allow-all behaviour keep it through a full-access group (runs once).
+-- Permissions introduced alongside it (NAS config restore, API keys, webhooks) are left out of the grant.
+DO $$
+DECLARE
+    legacy_group INTEGER;
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM system_preferences WHERE key = 'permissions_deny_by_default') THEN
+        IF EXISTS (SELECT 1 FROM resellers WHERE permission_group IS NULL AND deleted_at IS NULL) THEN
+            INSERT INTO permission_groups (name, description) VALUES ('Full Access (legacy)', 'Pre-existing permissions - assigned to resellers that had no group before deny-by-default') ON CONFLICT (name) DO NOTHING;
+            SELECT id INTO legacy_group FROM permission_groups WHERE name = 'Full Access (legacy)';
+            INSERT INTO permission_group_permissions (permission_group_id, permission_id)
+                SELECT legacy_group, id FROM permissions
+                WHERE name NOT IN ('nas.config_restore', 'api_keys.manage', 'webhooks.manage')
+                ON CONFLICT DO NOTHING;
+            UPDATE resellers SET permission_group = legacy_group WHERE permission_group IS NULL;
+        END IF;
+        INSERT INTO system_preferences (key, value, value_type) VALUES ('permissions_deny_by_default', 'true', 'bool');
+    END IF;
+END $$;
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('default_reseller_permission_group', '', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- Refresh-token login sessions (v1.0.372+)
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    refresh_token_hash VARCHAR(64) NOT NULL,
+    previous_token_hash VARCHAR(64),
+    rotated_at TIMESTAMP,
+    device VARCHAR(100),
+    user_agent VARCHAR(500),
+    ip_address VARCHAR(50),
+    last_activity_at TIMESTAMP,
+    last_activity_ip VARCHAR(50),
+    impersonated_by INTEGER,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    revoked_by VARCHAR(100),
+    revoke_reason VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_auth_sessions_refresh_token_hash ON auth_sessions(refresh_token_hash);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_previous_token_hash ON auth_sessions(previous_token_hash);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_expires_at ON auth_sessions(expires_at);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('access_token_minutes', '15', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- Single sign-on for staff users (v1.0.373+)
+CREATE TABLE IF NOT EXISTS sso_providers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(50) NOT NULL,
+    type VARCHAR(20) DEFAULT 'oidc',
+    issuer_url VARCHAR(500),
+    client_id VARCHAR(255),
+    client_secret VARCHAR(500),
+    scopes VARCHAR(255) DEFAULT 'openid email profile',
+    redirect_url VARCHAR(500),
+    username_claim VARCHAR(100) DEFAULT 'preferred_username',
+    email_claim VARCHAR(100) DEFAULT 'email',
+    name_claim VARCHAR(100) DEFAULT 'name',
+    groups_claim VARCHAR(100) DEFAULT 'groups',
+    role_mappings TEXT,
+    default_user_type INTEGER DEFAULT 0,
+    default_permission_group INTEGER,
+    allowed_domains TEXT,
+    auto_provision BOOLEAN DEFAULT false,
+    sync_roles BOOLEAN DEFAULT true,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sso_providers_slug ON sso_providers(slug);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    provider_id INTEGER NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identity_subject ON user_identities(provider_id, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('sso_local_login', 'enabled', 'string') ON CONFLICT (key) DO NOTHING;
+
+-- Security keys, recovery codes and 2FA policy (v1.0.374+)
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100),
+    credential_id VARCHAR(1400) NOT NULL,
+    public_key BYTEA NOT NULL,
+    algorithm INTEGER,
+    sign_count BIGINT DEFAULT 0,
+    aaguid VARCHAR(36),
+    transports VARCHAR(100),
+    backup_eligible BOOLEAN DEFAULT false,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webauthn_credentials_credential_id ON webauthn_credentials(credential_id);
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    used_ip VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_recovery_codes_user_id ON two_factor_recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_two_factor_recovery_codes_code_hash ON two_factor_recovery_codes(code_hash);
+
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS auth_method VARCHAR(20) DEFAULT 'password';
+
+-- Authenticator app secrets now stay pending in Redis until verified; drop ones that never were
+UPDATE users SET two_factor_secret = '' WHERE two_factor_enabled = false AND two_factor_secret <> '';
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('two_factor_required_user_types', '', 'string') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('webauthn_rp_id', '', 'string') ON CONFLICT (key) DO NOTHING;
+
+-- Customer portal self-service (v1.0.375+)
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS wallet_balance DECIMAL(15,2) DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS portal_permissions (
+    id SERIAL PRIMARY KEY,
+    reseller_id INTEGER NOT NULL,
+    allow_renew BOOLEAN DEFAULT true,
+    allow_wallet_topup BOOLEAN DEFAULT true,
+    allow_change_plan BOOLEAN DEFAULT false,
+    allow_reset_fup BOOLEAN DEFAULT true,
+    allow_contact_update BOOLEAN DEFAULT true,
+    allow_password_change BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_portal_permissions_reseller_id ON portal_permissions(reseller_id);
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+    id SERIAL PRIMARY KEY,
+    subscriber_id INTEGER NOT NULL,
+    reseller_id INTEGER NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL,
+    balance_after DECIMAL(15,2),
+    reference VARCHAR(100),
+    description VARCHAR(500),
+    ip_address VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_subscriber_id ON wallet_transactions(subscriber_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_reseller_id ON wallet_transactions(reseller_id);
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_created_at ON wallet_transactions(created_at);
+
+-- Customer Portal OTP Login (v1.0.376+)
+CREATE INDEX IF NOT EXISTS idx_subscribers_phone_suffix ON subscribers (right(regexp_replace(phone, '[^0-9]', '', 'g'), 9));
+INSERT INTO system_preferences (key, value, value_type) VALUES ('customer_otp_login_enabled', 'true', 'bool') ON CONFLICT (key) DO NOTHING;
+
+-- Partitioned radacct and usage rollups (v1.0.377+)
+-- radacct is converted to monthly range partitions on acctstarttime at API startup
+-- (services.EnsureRadAcctPartitioned). The rollup_* columns record how much of each
+-- row the rollup service has already counted, so it only adds the increase.
+ALTER TABLE radacct ADD COLUMN IF NOT EXISTS rollup_input_octets BIGINT DEFAULT 0;
+ALTER TABLE radacct ADD COLUMN IF NOT EXISTS rollup_output_octets BIGINT DEFAULT 0;
+ALTER TABLE radacct ADD COLUMN IF NOT EXISTS rollup_session_time INTEGER DEFAULT 0;
+ALTER TABLE radacct ADD COLUMN IF NOT EXISTS rollup_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS subscriber_usage_hourly (
+    hour TIMESTAMP NOT NULL,
+    username VARCHAR(64) NOT NULL,
+    input_octets BIGINT NOT NULL DEFAULT 0,
+    output_octets BIGINT NOT NULL DEFAULT 0,
+    session_time BIGINT NOT NULL DEFAULT 0,
+    sessions INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (hour, username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriber_usage_hourly_username ON subscriber_usage_hourly(username, hour);
+
+CREATE TABLE IF NOT EXISTS subscriber_usage_daily (
+    day DATE NOT NULL,
+    username VARCHAR(64) NOT NULL,
+    input_octets BIGINT NOT NULL DEFAULT 0,
+    output_octets BIGINT NOT NULL DEFAULT 0,
+    session_time BIGINT NOT NULL DEFAULT 0,
+    sessions INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriber_usage_daily_username ON subscriber_usage_daily(username, day);
+
+CREATE TABLE IF NOT EXISTS nas_usage_hourly (
+    hour TIMESTAMP NOT NULL,
+    nasipaddress VARCHAR(15) NOT NULL,
+    input_octets BIGINT NOT NULL DEFAULT 0,
+    output_octets BIGINT NOT NULL DEFAULT 0,
+    session_time BIGINT NOT NULL DEFAULT 0,
+    sessions INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (hour, nasipaddress)
+);
+
+CREATE TABLE IF NOT EXISTS nas_usage_daily (
+    day DATE NOT NULL,
+    nasipaddress VARCHAR(15) NOT NULL,
+    input_octets BIGINT NOT NULL DEFAULT 0,
+    output_octets BIGINT NOT NULL DEFAULT 0,
+    session_time BIGINT NOT NULL DEFAULT 0,
+    sessions INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, nasipaddress)
+);
+
+-- IP assignment history for lawful intercept requests (v1.0.378+)
+-- One row per accounting session that was given an address, fed from radacct by
+-- services.IPHistoryService. Rows are append-only: the only change allowed is closing
+-- an open row, and deletes only happen through the retention purge.
+CREATE TABLE IF NOT EXISTS ip_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    ip_address VARCHAR(45) NOT NULL,
+    username VARCHAR(64) NOT NULL,
+    subscriber_id INTEGER,
+    nas_id INTEGER,
+    nas_ip_address VARCHAR(15),
+    mac_address VARCHAR(50),
+    acct_session_id VARCHAR(64),
+    acct_unique_id VARCHAR(32) UNIQUE,
+    started_at TIMESTAMP NOT NULL,
+    stopped_at TIMESTAMP,
+    terminate_cause VARCHAR(32),
+    source VARCHAR(20) NOT NULL DEFAULT 'accounting',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ip_assignments_ip ON ip_assignments(ip_address, started_at);
+CREATE INDEX IF NOT EXISTS idx_ip_assignments_username ON ip_assignments(username, started_at);
+CREATE INDEX IF NOT EXISTS idx_ip_assignments_subscriber ON ip_assignments(subscriber_id);
+CREATE INDEX IF NOT EXISTS idx_ip_assignments_open ON ip_assignments(acct_unique_id) WHERE stopped_at IS NULL;
+
+CREATE OR REPLACE FUNCTION ip_assignments_immutable() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF current_setting('proisp.ip_history_purge', true) = 'on' THEN
+            RETURN OLD;
+        END IF;
+        RAISE EXCEPTION 'ip_assignments rows can only be removed by the retention purge';
+    END IF;
+    -- Closing an open row may set stopped_at and terminate_cause; every other column stays
+    IF OLD.stopped_at IS NULL AND NEW.stopped_at IS NOT NULL
+        AND to_jsonb(NEW) - 'stopped_at' - 'terminate_cause' = to_jsonb(OLD) - 'stopped_at' - 'terminate_cause' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'ip_assignments rows are immutable, only an open row can be closed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_ip_assignments_immutable ON ip_assignments;
+CREATE TRIGGER trg_ip_assignments_immutable BEFORE UPDATE OR DELETE ON ip_assignments
+    FOR EACH ROW EXECUTE FUNCTION ip_assignments_immutable();
+
+-- Every file handed out for a law-enforcement request, with its hash and signature
+CREATE TABLE IF NOT EXISTS ip_history_exports (
+    id SERIAL PRIMARY KEY,
+    case_reference VARCHAR(100) NOT NULL,
+    requested_by INTEGER,
+    requested_by_name VARCHAR(100),
+    query TEXT,
+    record_count INTEGER NOT NULL DEFAULT 0,
+    file_name VARCHAR(255),
+    content TEXT,
+    sha256 VARCHAR(64),
+    signature TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ip_history_exports_created ON ip_history_exports(created_at);
+
+CREATE OR REPLACE FUNCTION ip_history_exports_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'ip_history_exports rows are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_ip_history_exports_immutable ON ip_history_exports;
+CREATE TRIGGER trg_ip_history_exports_immutable BEFORE UPDATE OR DELETE ON ip_history_exports
+    FOR EACH ROW EXECUTE FUNCTION ip_history_exports_immutable();
+
+INSERT INTO permissions (name, description) VALUES ('ip_history.view', 'Look up which subscriber held an IP address') ON CONFLICT (name) DO NOTHING;
+INSERT INTO permissions (name, description) VALUES ('ip_history.export', 'Create signed IP history exports for law-enforcement requests') ON CONFLICT (name) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('ip_history_retention_days', '730', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- CGNAT port-block pools (v1.0.379+)
+-- Each private address of a pool maps deterministically to a public address and a
+-- fixed port range (see ippool.CGNATBlocks); cgnat_port_blocks holds the expanded
+-- mapping and the src-nat rules pushed to the NAS are generated from it.
+CREATE TABLE IF NOT EXISTS cgnat_pools (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    pool_name VARCHAR(64),
+    nas_id INTEGER,
+    private_ranges TEXT NOT NULL,
+    public_ranges TEXT NOT NULL,
+    port_start INTEGER NOT NULL DEFAULT 1024,
+    port_end INTEGER NOT NULL DEFAULT 65535,
+    ports_per_block INTEGER NOT NULL DEFAULT 2016,
+    is_active BOOLEAN DEFAULT true,
+    synced_at TIMESTAMP,
+    sync_error TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS cgnat_port_blocks (
+    id SERIAL PRIMARY KEY,
+    cgnat_pool_id INTEGER NOT NULL REFERENCES cgnat_pools(id) ON DELETE CASCADE,
+    private_ip VARCHAR(15) NOT NULL UNIQUE,
+    public_ip VARCHAR(15) NOT NULL,
+    port_start INTEGER NOT NULL,
+    port_end INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_cgnat_port_blocks_pool ON cgnat_port_blocks(cgnat_pool_id);
+CREATE INDEX IF NOT EXISTS idx_cgnat_port_blocks_public ON cgnat_port_blocks(public_ip, port_start);
+
+-- The translation each session had, recorded when the assignment is written
+ALTER TABLE ip_assignments ADD COLUMN IF NOT EXISTS cgnat_pool_id INTEGER;
+ALTER TABLE ip_assignments ADD COLUMN IF NOT EXISTS public_ip VARCHAR(15);
+ALTER TABLE ip_assignments ADD COLUMN IF NOT EXISTS public_port_start INTEGER;
+ALTER TABLE ip_assignments ADD COLUMN IF NOT EXISTS public_port_end INTEGER;
+CREATE INDEX IF NOT EXISTS idx_ip_assignments_public ON ip_assignments(public_ip, started_at) WHERE public_ip IS NOT NULL;
+
+-- Saved and scheduled reports (v1.0.380+)
+-- A definition is a report type with filters; scheduled ones are run by
+-- services.ReportSchedulerService and emailed. Every rendered file is kept in report_runs.
+CREATE TABLE IF NOT EXISTS report_definitions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    report_type VARCHAR(30) NOT NULL,
+    date_range VARCHAR(30) DEFAULT 'yesterday',
+    date_from TIMESTAMP,
+    date_to TIMESTAMP,
+    reseller_id INTEGER,
+    service_id INTEGER,
+    nas_id INTEGER,
+    formats VARCHAR(50) DEFAULT 'csv',
+    schedule VARCHAR(10) DEFAULT 'none',
+    schedule_day INTEGER DEFAULT 1,
+    schedule_hour INTEGER DEFAULT 6,
+    recipients TEXT,
+    is_active BOOLEAN DEFAULT true,
+    last_run_at TIMESTAMP,
+    next_run_at TIMESTAMP,
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_definitions_next_run ON report_definitions(next_run_at) WHERE schedule <> 'none' AND is_active;
+
+CREATE TABLE IF NOT EXISTS report_runs (
+    id SERIAL PRIMARY KEY,
+    definition_id INTEGER REFERENCES report_definitions(id) ON DELETE SET NULL,
+    report_type VARCHAR(30),
+    format VARCHAR(10),
+    params TEXT,
+    file_name VARCHAR(255),
+    content BYTEA,
+    size INTEGER DEFAULT 0,
+    row_count INTEGER DEFAULT 0,
+    status VARCHAR(20),
+    error TEXT,
+    emailed_to TEXT,
+    source VARCHAR(20),
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_runs_definition ON report_runs(definition_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_report_runs_created ON report_runs(created_at);
+
+INSERT INTO permissions (name, description) VALUES ('reports.schedule', 'Create saved and scheduled reports') ON CONFLICT (name) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('report_run_retention_days', '90', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- Reseller commission plans and settlements (v1.0.381+)
+-- When a reseller sells (new subscriber or renewal), each ancestor in the reseller tree
+-- earns per its commission plan; entries collect in commissions and are rolled into a
+-- monthly commission_settlements statement per earner, which is then marked paid.
+CREATE TABLE IF NOT EXISTS commission_plans (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description VARCHAR(255),
+    is_default BOOLEAN DEFAULT false,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_commission_plans_default ON commission_plans(is_default) WHERE is_default;
+
+CREATE TABLE IF NOT EXISTS commission_rules (
+    id SERIAL PRIMARY KEY,
+    plan_id INTEGER NOT NULL REFERENCES commission_plans(id) ON DELETE CASCADE,
+    service_id INTEGER,
+    tier INTEGER NOT NULL DEFAULT 1,
+    type VARCHAR(10) NOT NULL,
+    value DECIMAL(15,4) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_commission_rules_plan ON commission_rules(plan_id);
+
+ALTER TABLE resellers ADD COLUMN IF NOT EXISTS commission_plan_id INTEGER;
+
+CREATE TABLE IF NOT EXISTS commission_settlements (
+    id SERIAL PRIMARY KEY,
+    reseller_id INTEGER NOT NULL,
+    period VARCHAR(7) NOT NULL,
+    period_start TIMESTAMP,
+    period_end TIMESTAMP,
+    entry_count INTEGER DEFAULT 0,
+    total DECIMAL(15,2) DEFAULT 0,
+    status VARCHAR(20) DEFAULT 'open',
+    payout_method VARCHAR(20),
+    reference VARCHAR(100),
+    notes TEXT,
+    transaction_id INTEGER,
+    paid_at TIMESTAMP,
+    paid_by INTEGER,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (reseller_id, period)
+);
+
+CREATE TABLE IF NOT EXISTS commissions (
+    id SERIAL PRIMARY KEY,
+    reseller_id INTEGER NOT NULL,
+    source_reseller_id INTEGER NOT NULL,
+    transaction_id INTEGER NOT NULL,
+    subscriber_id INTEGER,
+    service_id INTEGER,
+    sale_type VARCHAR(20),
+    tier INTEGER,
+    base_amount DECIMAL(15,2),
+    rate_type VARCHAR(10),
+    rate_value DECIMAL(15,4),
+    amount DECIMAL(15,2),
+    settlement_id INTEGER REFERENCES commission_settlements(id),
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (transaction_id, reseller_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_commissions_reseller ON commissions(reseller_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_commissions_unsettled ON commissions(created_at) WHERE settlement_id IS NULL;
+
+INSERT INTO permissions (name, description) VALUES ('commissions.view', 'View commission ledger and statements') ON CONFLICT (name) DO NOTHING;
+INSERT INTO permissions (name, description) VALUES ('commissions.manage', 'Manage commission plans and mark payouts') ON CONFLICT (name) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('commission_max_tiers', '5', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- Subscriber analytics: churn, ARPU, renewal rate and cohorts (v1.0.382+)
+-- subscriber_status_snapshots keeps each subscriber's state at the end of every month
+-- (the current month is refreshed nightly). Months before the first snapshot are
+-- backfilled from expiry dates and sales and flagged estimated. analytics.Compute
+-- derives analytics_monthly and analytics_cohorts from them.
+CREATE TABLE IF NOT EXISTS subscriber_status_snapshots (
+    month DATE NOT NULL,
+    subscriber_id INTEGER NOT NULL,
+    service_id INTEGER,
+    reseller_id INTEGER,
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    status INTEGER,
+    expiry_date TIMESTAMP,
+    subscribed_at TIMESTAMP,
+    active BOOLEAN NOT NULL DEFAULT false,
+    estimated BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (month, subscriber_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriber_status_snapshots_subscriber ON subscriber_status_snapshots(subscriber_id, month);
+
+CREATE TABLE IF NOT EXISTS analytics_monthly (
+    period DATE NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    dimension_key VARCHAR(100) NOT NULL,
+    label VARCHAR(255),
+    active_start INTEGER DEFAULT 0,
+    active_end INTEGER DEFAULT 0,
+    new_subscribers INTEGER DEFAULT 0,
+    churned INTEGER DEFAULT 0,
+    renewed INTEGER DEFAULT 0,
+    revenue DECIMAL(15,2) DEFAULT 0,
+    arpu DECIMAL(15,2) DEFAULT 0,
+    churn_rate DECIMAL(7,4) DEFAULT 0,
+    renewal_rate DECIMAL(7,4) DEFAULT 0,
+    estimated BOOLEAN DEFAULT false,
+    computed_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (period, dimension, dimension_key)
+);
+
+CREATE TABLE IF NOT EXISTS analytics_cohorts (
+    cohort DATE NOT NULL,
+    month_offset INTEGER NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    dimension_key VARCHAR(100) NOT NULL,
+    label VARCHAR(255),
+    size INTEGER DEFAULT 0,
+    active INTEGER DEFAULT 0,
+    retention DECIMAL(7,4) DEFAULT 0,
+    computed_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (cohort, month_offset, dimension, dimension_key)
+);
+
+INSERT INTO permissions (name, description) VALUES ('analytics.view', 'View churn, ARPU and cohort analytics') ON CONFLICT (name) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('analytics_months', '13', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('analytics_run_hour', '2', 'int') ON CONFLICT (key) DO NOTHING;
+
+-- Event-driven communication rules: conditions, fixed recipients and subject templates (v1.0.383+)
+ALTER TABLE communication_rules ADD COLUMN IF NOT EXISTS service_id INTEGER;
+ALTER TABLE communication_rules ADD COLUMN IF NOT EXISTS region VARCHAR(100) DEFAULT '';
+ALTER TABLE communication_rules ADD COLUMN IF NOT EXISTS offline_hours INTEGER DEFAULT 24;
+ALTER TABLE communication_rules ADD COLUMN IF NOT EXISTS subject VARCHAR(255) DEFAULT '';
+ALTER TABLE communication_rules ADD COLUMN IF NOT EXISTS recipient VARCHAR(500) DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_communication_rules_trigger ON communication_rules(trigger_event, enabled);
+
+-- Outbound message queue (outbox) with retries, rate limits and quiet hours (v1.0.384+)
+CREATE TABLE IF NOT EXISTS outbound_messages (
+    id SERIAL PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255),
+    body TEXT,
+    is_html BOOLEAN DEFAULT false,
+    sender VARCHAR(100) DEFAULT '',
+    source VARCHAR(30),
+    dedup_key VARCHAR(200),
+    subscriber_id INTEGER,
+    reseller_id INTEGER,
+    rule_id INTEGER,
+    timezone VARCHAR(64) DEFAULT '',
+    urgent BOOLEAN DEFAULT false,
+    status VARCHAR(20) DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 6,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMP,
+    last_error TEXT,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbound_messages_dedup_key ON outbound_messages(dedup_key) WHERE dedup_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_due ON outbound_messages(channel, status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_subscriber_id ON outbound_messages(subscriber_id);
+CREATE INDEX IF NOT EXISTS idx_outbound_messages_created_at ON outbound_messages(created_at);
+
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) DEFAULT '';
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('outbox_max_attempts', '6', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('outbox_rate_sms', '60', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('outbox_rate_whatsapp', '20', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('outbox_rate_email', '60', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('outbox_retention_days', '31', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('quiet_hours_start', '', 'string') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('quiet_hours_end', '', 'string') ON CONFLICT (key) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES ('communication.outbox', 'View, resend and cancel queued messages') ON CONFLICT (name) DO NOTHING;
+
+-- Inbound WhatsApp/SMS chatbot conversations (v1.0.385+)
+CREATE TABLE IF NOT EXISTS inbound_messages (
+    id SERIAL PRIMARY KEY,
+    channel VARCHAR(20) NOT NULL,
+    phone VARCHAR(50) NOT NULL,
+    account VARCHAR(100) DEFAULT '',
+    provider_message_id VARCHAR(100) DEFAULT '',
+    body TEXT,
+    subscriber_id INTEGER,
+    reseller_id INTEGER,
+    command VARCHAR(20),
+    reply TEXT,
+    ticket_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inbound_messages_provider_id ON inbound_messages(channel, provider_message_id) WHERE provider_message_id <> '';
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_phone ON inbound_messages(phone);
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_subscriber_id ON inbound_messages(subscriber_id);
+CREATE INDEX IF NOT EXISTS idx_inbound_messages_created_at ON inbound_messages(created_at);
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('chatbot_enabled', 'true', 'bool') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('chatbot_messages_per_hour', '20', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('inbound_webhook_token', md5(random()::text || clock_timestamp()::text), 'string') ON CONFLICT (key) DO NOTHING;
+
+-- SMS gateways with failover, delivery receipts and reseller sender IDs (v1.0.386+)
+CREATE TABLE IF NOT EXISTS sms_gateways (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    priority INTEGER DEFAULT 0,
+    is_active BOOLEAN DEFAULT true,
+    sender_id VARCHAR(20) DEFAULT '',
+    allow_sender_override BOOLEAN DEFAULT true,
+    settings TEXT,
+    consecutive_failures INTEGER DEFAULT 0,
+    disabled_until TIMESTAMP,
+    last_success_at TIMESTAMP,
+    last_failure_at TIMESTAMP,
+    last_error VARCHAR(500) DEFAULT '',
+    sent_count BIGINT DEFAULT 0,
+    failed_count BIGINT DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE communication_logs ADD COLUMN IF NOT EXISTS gateway_id INTEGER;
+ALTER TABLE communication_logs ADD COLUMN IF NOT EXISTS provider_message_id VARCHAR(100) DEFAULT '';
+ALTER TABLE communication_logs ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_comm_provider_message_id ON communication_logs(gateway_id, provider_message_id) WHERE provider_message_id <> '';
+
+ALTER TABLE resellers ADD COLUMN IF NOT EXISTS sms_sender_id VARCHAR(20) DEFAULT '';
+
+INSERT INTO system_preferences (key, value, value_type) VALUES ('sms_failover_threshold', '3', 'int') ON CONFLICT (key) DO NOTHING;
+INSERT INTO system_preferences (key, value, value_type) VALUES ('sms_failover_cooldown_minutes', '5', 'int') ON CONFLICT (key) DO NOTHING;
@@ -127,6 +127,10 @@ type Service struct {
 	AddressListOut  string `gorm:"column:address_list_out;size:100" json:"address_list_out"`
 	QueueType       string `gorm:"column:queue_type;size:50;default:simple" json:"queue_type"`
 
+	// IPoE - subscribers on this plan authenticate by MAC / Option 82 instead of PPPoE
+	IPoE            bool   `gorm:"column:ipoe;default:false" json:"ipoe"`
+	LeaseTime       int    `gorm:"column:lease_time;default:0" json:"lease_time"` // DHCP lease seconds, 0 = ipoe_lease_time setting
+
 	// Status
 	IsActive        bool      `gorm:"column:is_active;default:true" json:"is_active"`
 	SortOrder       int       `gorm:"column:sort_order;default:0" json:"sort_order"`
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SMS gateway providers
+const (
+	SMSGatewayTwilio = "twilio"
+	SMSGatewayVonage = "vonage"
+	SMSGatewayCustom = "custom" // Legacy URL/body template
+	SMSGatewayHTTP   = "http"   // Generic HTTP API with configurable request and response parsing
+	SMSGatewaySMPP   = "smpp"
+)
+
+// Communication log statuses set by delivery receipts
+const (
+	CommStatusDelivered   = "delivered"
+	CommStatusUndelivered = "undelivered"
+)
+
+// SMSGatewaySettings holds the provider-specific settings of a gateway. Only the
+// fields of the gateway's provider are used.
+type SMSGatewaySettings struct {
+	// Twilio
+	TwilioSID   string `json:"twilio_sid,omitempty"`
+	TwilioToken string `json:"twilio_token,omitempty"`
+	TwilioFrom  string `json:"twilio_from,omitempty"`
+	// Vonage
+	VonageKey    string `json:"vonage_key,omitempty"`
+	VonageSecret string `json:"vonage_secret,omitempty"`
+	VonageFrom   string `json:"vonage_from,omitempty"`
+
+	// Custom and HTTP. URL and Body are templates with {{.to}}, {{.message}},
+	// {{.sender}} and {{.dlr_url}}; values are escaped for the URL and content type.
+	URL         string            `json:"url,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	ContentType string            `json:"content_type,omitempty"` // Default application/json
+	Params      string            `json:"params,omitempty"`       // Custom only: query template for GET
+
+	// HTTP response parsing. Field paths are dotted JSON paths, e.g. "messages.0.id".
+	SuccessField   string `json:"success_field,omitempty"`
+	SuccessValues  string `json:"success_values,omitempty"` // Comma-separated; empty = any non-empty value
+	SuccessRegex   string `json:"success_regex,omitempty"`  // For plain text responses
+	MessageIDField string `json:"message_id_field,omitempty"`
+	MessageIDRegex string `json:"message_id_regex,omitempty"` // First group is the ID
+	ErrorField     string `json:"error_field,omitempty"`
+
+	// HTTP delivery receipts posted to the DLR URL
+	DLRIDField         string `json:"dlr_id_field,omitempty"`
+	DLRStatusField     string `json:"dlr_status_field,omitempty"`
+	DLRErrorField      string `json:"dlr_error_field,omitempty"`
+	DLRDeliveredValues string `json:"dlr_delivered_values,omitempty"` // Comma-separated
+	DLRFailedValues    string `json:"dlr_failed_values,omitempty"`    // Comma-separated
+
+	// SMPP
+	Host            string `json:"host,omitempty"`
+	Port            int    `json:"port,omitempty"`
+	TLS             bool   `json:"tls,omitempty"`
+	SystemID        string `json:"system_id,omitempty"`
+	Password        string `json:"password,omitempty"`
+	SystemType      string `json:"system_type,omitempty"`
+	SourceTON       int    `json:"source_ton,omitempty"`
+	SourceNPI       int    `json:"source_npi,omitempty"`
+	DestTON         int    `json:"dest_ton,omitempty"`
+	DestNPI         int    `json:"dest_npi,omitempty"`
+	EnquireLinkSecs int    `json:"enquire_link_secs,omitempty"`
+
+	// Delivery receipts: requested from SMPP gateways, and the callback URL given to
+	// Twilio, Vonage and HTTP gateways
+	RequestDLR bool   `json:"request_dlr,omitempty"`
+	DLRURL     string `json:"dlr_url,omitempty"`
+}
+
+// SMSGateway is one SMS provider account. Active gateways are tried in priority
+// order; a gateway failing sms_failover_threshold times in a row is skipped for
+// sms_failover_cooldown_minutes.
+type SMSGateway struct {
+	ID                  uint       `gorm:"column:id;primaryKey" json:"id"`
+	Name                string     `gorm:"column:name;size:100;not null" json:"name"`
+	Provider            string     `gorm:"column:provider;size:20;not null" json:"provider"`
+	Priority            int        `gorm:"column:priority;default:0" json:"priority"` // Lower is tried first
+	IsActive            bool       `gorm:"column:is_active" json:"is_active"`
+	SenderID            string     `gorm:"column:sender_id;size:20" json:"sender_id"`                 // Default sender
+	AllowSenderOverride bool       `gorm:"column:allow_sender_override" json:"allow_sender_override"` // Use resellers' sender IDs
+	Settings            string     `gorm:"column:settings;type:text" json:"-"`                        // JSON of SMSGatewaySettings
+	ConsecutiveFailures int        `gorm:"column:consecutive_failures;default:0" json:"consecutive_failures"`
+	DisabledUntil       *time.Time `gorm:"column:disabled_until" json:"disabled_until"`
+	LastSuccessAt       *time.Time `gorm:"column:last_success_at" json:"last_success_at"`
+	LastFailureAt       *time.Time `gorm:"column:last_failure_at" json:"last_failure_at"`
+	LastError           string     `gorm:"column:last_error;size:500" json:"last_error"`
+	SentCount           int64      `gorm:"column:sent_count;default:0" json:"sent_count"`
+	FailedCount         int64      `gorm:"column:failed_count;default:0" json:"failed_count"`
+	CreatedAt           time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (SMSGateway) TableName() string {
+	return "sms_gateways"
+}
+
+// Config parses Settings; invalid JSON yields empty settings
+func (g *SMSGateway) Config() SMSGatewaySettings {
+	var settings SMSGatewaySettings
+	if g.Settings != "" {
+		json.Unmarshal([]byte(g.Settings), &settings)
+	}
+	return settings
+}
+
+// Healthy reports whether the gateway is outside its failover cooldown
+func (g *SMSGateway) Healthy(now time.Time) bool {
+	return g.DisabledUntil == nil || !now.Before(*g.DisabledUntil)
+}
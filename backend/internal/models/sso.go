@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// SSO provider protocols
+const (
+	SSOTypeOIDC = "oidc"
+)
+
+// Values of the sso_local_login preference
+const (
+	LocalLoginEnabled      = "enabled"       // Anyone with a password may log in locally
+	LocalLoginUnlinkedOnly = "unlinked_only" // Users linked to an SSO identity must use SSO
+	LocalLoginAdminsOnly   = "admins_only"   // Only admins may log in locally (break-glass)
+)
+
+// SSORoleMapping maps an IdP group to a user type and optional permission group
+type SSORoleMapping struct {
+	Group           string   `json:"group"`
+	UserType        UserType `json:"user_type"`
+	PermissionGroup *uint    `json:"permission_group,omitempty"`
+}
+
+// SSOProvider is an external identity provider staff can log in with
+type SSOProvider struct {
+	ID                     uint      `gorm:"column:id;primaryKey" json:"id"`
+	Name                   string    `gorm:"column:name;size:100;not null" json:"name"`
+	Slug                   string    `gorm:"column:slug;size:50;uniqueIndex;not null" json:"slug"`
+	Type                   string    `gorm:"column:type;size:20;default:oidc" json:"type"`
+	IssuerURL              string    `gorm:"column:issuer_url;size:500" json:"issuer_url"`
+	ClientID               string    `gorm:"column:client_id;size:255" json:"client_id"`
+	ClientSecret           string    `gorm:"column:client_secret;size:500" json:"-"`
+	Scopes                 string    `gorm:"column:scopes;size:255;default:'openid email profile'" json:"scopes"` // Space-separated
+	RedirectURL            string    `gorm:"column:redirect_url;size:500" json:"redirect_url"`                    // Empty = derived from the request
+	UsernameClaim          string    `gorm:"column:username_claim;size:100;default:preferred_username" json:"username_claim"`
+	EmailClaim             string    `gorm:"column:email_claim;size:100;default:email" json:"email_claim"`
+	NameClaim              string    `gorm:"column:name_claim;size:100;default:name" json:"name_claim"`
+	GroupsClaim            string    `gorm:"column:groups_claim;size:100;default:groups" json:"groups_claim"`
+	RoleMappings           string    `gorm:"column:role_mappings;type:text" json:"role_mappings"` // JSON array of SSORoleMapping, first match wins
+	DefaultUserType        UserType  `gorm:"column:default_user_type;default:0" json:"default_user_type"`
+	DefaultPermissionGroup *uint     `gorm:"column:default_permission_group" json:"default_permission_group"`
+	AllowedDomains         string    `gorm:"column:allowed_domains;type:text" json:"allowed_domains"` // Comma-separated email domains, empty = any
+	AutoProvision          bool      `gorm:"column:auto_provision;default:false" json:"auto_provision"`
+	SyncRoles              bool      `gorm:"column:sync_roles;default:true" json:"sync_roles"` // Re-apply mappings on every login
+	IsActive               bool      `gorm:"column:is_active;default:true" json:"is_active"`
+	CreatedAt              time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt              time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (SSOProvider) TableName() string {
+	return "sso_providers"
+}
+
+// Mappings parses RoleMappings; invalid JSON yields no mappings
+func (p *SSOProvider) Mappings() []SSORoleMapping {
+	var mappings []SSORoleMapping
+	if strings.TrimSpace(p.RoleMappings) == "" {
+		return mappings
+	}
+	json.Unmarshal([]byte(p.RoleMappings), &mappings)
+	return mappings
+}
+
+// AllowsEmail reports whether the email's domain may use this provider
+func (p *SSOProvider) AllowsEmail(email string) bool {
+	if strings.TrimSpace(p.AllowedDomains) == "" {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range strings.Split(p.AllowedDomains, ",") {
+		if strings.ToLower(strings.TrimSpace(allowed)) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// UserIdentity links a panel user to an account at an SSO provider
+type UserIdentity struct {
+	ID          uint       `gorm:"column:id;primaryKey" json:"id"`
+	UserID      uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	ProviderID  uint       `gorm:"column:provider_id;not null;uniqueIndex:idx_user_identity_subject" json:"provider_id"`
+	Subject     string     `gorm:"column:subject;size:255;not null;uniqueIndex:idx_user_identity_subject" json:"subject"`
+	Email       string     `gorm:"column:email;size:255" json:"email"`
+	LastLoginAt *time.Time `gorm:"column:last_login_at" json:"last_login_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
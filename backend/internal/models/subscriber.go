@@ -17,6 +17,13 @@ const (
 	SubscriberStatusStopped  SubscriberStatus = 4
 )
 
+// Connection types
+const (
+	ConnectionTypePPPoE   = "pppoe"
+	ConnectionTypeHotspot = "hotspot"
+	ConnectionTypeIPoE    = "ipoe"
+)
+
 // Subscriber represents a PPPoE/Hotspot subscriber
 type Subscriber struct {
 	ID              uint             `gorm:"column:id;primaryKey" json:"id"`
@@ -31,6 +38,7 @@ type Subscriber struct {
 	Building        string           `gorm:"column:building;size:100" json:"building"`
 	Nationality     string           `gorm:"column:nationality;size:100" json:"nationality"`
 	Country         string           `gorm:"column:country;size:100" json:"country"`
+	Timezone        string           `gorm:"column:timezone;size:64" json:"timezone"` // IANA zone for quiet hours; empty = system timezone
 	Note            string           `gorm:"column:note;type:text" json:"note"`
 
 	// Service & Billing
@@ -42,6 +50,7 @@ type Subscriber struct {
 	Price           float64          `gorm:"column:price;type:decimal(15,2)" json:"price"`
 	OverridePrice   bool             `gorm:"column:override_price;default:false" json:"override_price"`
 	AutoRenew       bool             `gorm:"column:auto_renew;default:false" json:"auto_renew"`
+	WalletBalance   float64          `gorm:"column:wallet_balance;type:decimal(15,2);default:0;<-:false" json:"wallet_balance"` // Portal prepaid credit; only changed by atomic SQL
 
 	// Quota & FUP - stored in database for persistence
 	DailyDownloadUsed   int64      `gorm:"column:daily_download_used;default:0" json:"daily_download_used"`
@@ -67,6 +76,9 @@ type Subscriber struct {
 	SaveMAC         bool    `gorm:"column:save_mac;default:true" json:"save_mac"`
 	NasID           *uint   `gorm:"column:nas_id" json:"nas_id"`
 	Nas             *Nas    `gorm:"foreignKey:NasID;references:ID" json:"nas,omitempty"`
+	ConnectionType  string  `gorm:"column:connection_type;size:20;default:pppoe;index" json:"connection_type"` // pppoe, hotspot, ipoe
+	CircuitID       string  `gorm:"column:circuit_id;size:255;index" json:"circuit_id"` // DHCP Option 82 agent circuit-id (IPoE)
+	RemoteID        string  `gorm:"column:remote_id;size:255" json:"remote_id"`         // DHCP Option 82 agent remote-id (IPoE)
 
 	// Location
 	SwitchID        *uint   `gorm:"column:switch_id" json:"switch_id"`
@@ -200,6 +212,11 @@ func (s *Subscriber) IsExpired() bool {
 	return time.Now().After(s.ExpiryDate)
 }
 
+// IsHotspot returns true if the subscriber logs in through a MikroTik hotspot (captive portal)
+func (s *Subscriber) IsHotspot() bool {
+	return s.ConnectionType == ConnectionTypeHotspot
+}
+
 // DaysRemaining returns the number of days remaining in subscription
 func (s *Subscriber) DaysRemaining() int {
 	if s.IsExpired() {
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Second factor methods
+const (
+	TwoFactorMethodTOTP     = "totp"
+	TwoFactorMethodWebAuthn = "webauthn"
+	TwoFactorMethodRecovery = "recovery"
+)
+
+// RecoveryCodeCount is how many one-time recovery codes are issued at a time
+const RecoveryCodeCount = 10
+
+// WebAuthnCredential is a security key or passkey registered as a second factor
+type WebAuthnCredential struct {
+	ID             uint       `gorm:"column:id;primaryKey" json:"id"`
+	UserID         uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	Name           string     `gorm:"column:name;size:100" json:"name"`
+	CredentialID   string     `gorm:"column:credential_id;size:1400;uniqueIndex;not null" json:"-"` // base64url
+	PublicKey      []byte     `gorm:"column:public_key;not null" json:"-"`                          // COSE_Key
+	Algorithm      int        `gorm:"column:algorithm" json:"algorithm"`
+	SignCount      uint32     `gorm:"column:sign_count;default:0" json:"-"`
+	AAGUID         string     `gorm:"column:aaguid;size:36" json:"aaguid"`
+	Transports     string     `gorm:"column:transports;size:100" json:"transports"`                // Comma-separated hints for the browser
+	BackupEligible bool       `gorm:"column:backup_eligible;default:false" json:"backup_eligible"` // Synced passkey
+	LastUsedAt     *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt      time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// TwoFactorRecoveryCode is a one-time code that stands in for a lost second factor.
+// Only the SHA-256 is stored; the plain codes are shown once when generated.
+type TwoFactorRecoveryCode struct {
+	ID        uint       `gorm:"column:id;primaryKey" json:"id"`
+	UserID    uint       `gorm:"column:user_id;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"column:code_hash;size:64;not null;index" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	UsedIP    string     `gorm:"column:used_ip;size:50" json:"used_ip"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (TwoFactorRecoveryCode) TableName() string {
+	return "two_factor_recovery_codes"
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// SubscriberUsageHourly is a subscriber's accounting totals for one hour, maintained
+// incrementally from radacct by the rollup service. Input is upload, output is download.
+type SubscriberUsageHourly struct {
+	Hour         time.Time `gorm:"column:hour;primaryKey" json:"hour"`
+	Username     string    `gorm:"column:username;primaryKey;size:64" json:"username"`
+	InputOctets  int64     `gorm:"column:input_octets" json:"input_octets"`
+	OutputOctets int64     `gorm:"column:output_octets" json:"output_octets"`
+	SessionTime  int64     `gorm:"column:session_time" json:"session_time"` // Seconds online
+	Sessions     int       `gorm:"column:sessions" json:"sessions"`         // Sessions first seen in this hour
+}
+
+func (SubscriberUsageHourly) TableName() string {
+	return "subscriber_usage_hourly"
+}
+
+// SubscriberUsageDaily is a subscriber's accounting totals for one day
+type SubscriberUsageDaily struct {
+	Day          time.Time `gorm:"column:day;type:date;primaryKey" json:"day"`
+	Username     string    `gorm:"column:username;primaryKey;size:64" json:"username"`
+	InputOctets  int64     `gorm:"column:input_octets" json:"input_octets"`
+	OutputOctets int64     `gorm:"column:output_octets" json:"output_octets"`
+	SessionTime  int64     `gorm:"column:session_time" json:"session_time"`
+	Sessions     int       `gorm:"column:sessions" json:"sessions"`
+}
+
+func (SubscriberUsageDaily) TableName() string {
+	return "subscriber_usage_daily"
+}
+
+// NasUsageHourly is the accounting totals of all sessions on a NAS for one hour
+type NasUsageHourly struct {
+	Hour         time.Time `gorm:"column:hour;primaryKey" json:"hour"`
+	NasIPAddress string    `gorm:"column:nasipaddress;primaryKey;size:15" json:"nasipaddress"`
+	InputOctets  int64     `gorm:"column:input_octets" json:"input_octets"`
+	OutputOctets int64     `gorm:"column:output_octets" json:"output_octets"`
+	SessionTime  int64     `gorm:"column:session_time" json:"session_time"`
+	Sessions     int       `gorm:"column:sessions" json:"sessions"`
+}
+
+func (NasUsageHourly) TableName() string {
+	return "nas_usage_hourly"
+}
+
+// NasUsageDaily is the accounting totals of all sessions on a NAS for one day
+type NasUsageDaily struct {
+	Day          time.Time `gorm:"column:day;type:date;primaryKey" json:"day"`
+	NasIPAddress string    `gorm:"column:nasipaddress;primaryKey;size:15" json:"nasipaddress"`
+	InputOctets  int64     `gorm:"column:input_octets" json:"input_octets"`
+	OutputOctets int64     `gorm:"column:output_octets" json:"output_octets"`
+	SessionTime  int64     `gorm:"column:session_time" json:"session_time"`
+	Sessions     int       `gorm:"column:sessions" json:"sessions"`
+}
+
+func (NasUsageDaily) TableName() string {
+	return "nas_usage_daily"
+}
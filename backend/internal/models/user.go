@@ -117,6 +117,7 @@ type Reseller struct {
 	Children        []Reseller     `gorm:"foreignKey:ParentID;references:ID" json:"children,omitempty"`
 	PermissionGroup *uint          `gorm:"column:permission_group" json:"permission_group"`
 	BandwidthRuleID *uint          `gorm:"column:bandwidth_rule_id" json:"bandwidth_rule_id"`
+	CommissionPlanID *uint         `gorm:"column:commission_plan_id" json:"commission_plan_id"`
 	IsActive        bool           `gorm:"column:is_active;default:true" json:"is_active"`
 	CreatedAt       time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt       time.Time      `gorm:"column:updated_at" json:"updated_at"`
@@ -129,6 +130,7 @@ type Reseller struct {
 	WhatsAppTrialStart    *time.Time     `gorm:"column:whatsapp_trial_start" json:"-"`
 	RebrandEnabled        bool           `gorm:"column:rebrand_enabled;default:false" json:"rebrand_enabled"`
 	CustomDomain          string         `gorm:"column:custom_domain;size:255" json:"custom_domain"`
+	SMSSenderID           string         `gorm:"column:sms_sender_id;size:20" json:"sms_sender_id"` // Sender ID for its subscribers' SMS
 
 	// Assigned NAS (many-to-many)
 	NASList         []ResellerNAS  `gorm:"-" json:"nas_list,omitempty"`
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Resource types a staff user can be limited to
+const (
+	ScopeResourceReseller = "reseller"
+	ScopeResourceNas      = "nas"
+)
+
+// UserScope limits a non-admin staff user to specific resellers or NASes.
+// A user with no scopes of a type is unrestricted for that type.
+type UserScope struct {
+	ID           uint      `gorm:"column:id;primaryKey" json:"id"`
+	UserID       uint      `gorm:"column:user_id;not null;uniqueIndex:idx_user_scope" json:"user_id"`
+	ResourceType string    `gorm:"column:resource_type;size:20;not null;uniqueIndex:idx_user_scope" json:"resource_type"`
+	ResourceID   uint      `gorm:"column:resource_id;not null;uniqueIndex:idx_user_scope" json:"resource_id"`
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (UserScope) TableName() string {
+	return "user_scopes"
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook event names
+const (
+	WebhookEventSubscriberCreated = "subscriber.created"
+	WebhookEventSubscriberUpdated = "subscriber.updated"
+	WebhookEventSubscriberRenewed = "subscriber.renewed"
+	WebhookEventSubscriberExpired = "subscriber.expired"
+	WebhookEventSubscriberDeleted = "subscriber.deleted"
+	WebhookEventSessionStart      = "session.start"
+	WebhookEventSessionStop       = "session.stop"
+	WebhookEventPaymentReceived   = "payment.received"
+	WebhookEventInvoicePaid       = "invoice.paid"
+	WebhookEventFUPChanged        = "fup.changed"
+	WebhookEventTicketUpdated     = "ticket.updated"
+	WebhookEventNasDown           = "nas.down"
+	WebhookEventTest              = "webhook.test"
+)
+
+// WebhookEvents lists every event an endpoint can subscribe to
+var WebhookEvents = []string{
+	WebhookEventSubscriberCreated,
+	WebhookEventSubscriberUpdated,
+	WebhookEventSubscriberRenewed,
+	WebhookEventSubscriberExpired,
+	WebhookEventSubscriberDeleted,
+	WebhookEventSessionStart,
+	WebhookEventSessionStop,
+	WebhookEventPaymentReceived,
+	WebhookEventInvoicePaid,
+	WebhookEventFUPChanged,
+	WebhookEventTicketUpdated,
+	WebhookEventNasDown,
+}
+
+// Webhook delivery states
+const (
+	WebhookStatusPending = "pending" // Waiting for the first attempt or a retry
+	WebhookStatusSuccess = "success"
+	WebhookStatusDead    = "dead" // Gave up after MaxAttempts - shown in the dead-letter view
+)
+
+// WebhookEndpoint is an external URL that receives signed event payloads
+type WebhookEndpoint struct {
+	ID             uint       `gorm:"column:id;primaryKey" json:"id"`
+	Name           string     `gorm:"column:name;size:100;not null" json:"name"`
+	URL            string     `gorm:"column:url;size:500;not null" json:"url"`
+	Secret         string     `gorm:"column:secret;size:100" json:"-"`             // HMAC-SHA256 signing key
+	Events         string     `gorm:"column:events;type:text" json:"events"`       // Comma-separated event names, or *
+	ResellerID     *uint      `gorm:"column:reseller_id;index" json:"reseller_id"` // Nil = all events; else only that reseller's and its sub-resellers'
+	IsActive       bool       `gorm:"column:is_active;default:true" json:"is_active"`
+	MaxAttempts    int        `gorm:"column:max_attempts;default:8" json:"max_attempts"`
+	TimeoutSeconds int        `gorm:"column:timeout_seconds;default:10" json:"timeout_seconds"`
+	LastSuccessAt  *time.Time `gorm:"column:last_success_at" json:"last_success_at"`
+	LastFailureAt  *time.Time `gorm:"column:last_failure_at" json:"last_failure_at"`
+	LastError      string     `gorm:"column:last_error;type:text" json:"last_error"`
+	CreatedBy      string     `gorm:"column:created_by;size:100" json:"created_by"`
+	CreatedAt      time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// Subscribes reports whether the endpoint wants an event
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	for _, name := range strings.Split(e.Events, ",") {
+		name = strings.TrimSpace(name)
+		if name == "*" || name == event {
+			return true
+		}
+		// "subscriber.*" covers every subscriber event
+		if strings.HasSuffix(name, ".*") && strings.HasPrefix(event, strings.TrimSuffix(name, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Receives reports whether the endpoint gets events of a reseller. resellerID is 0 for
+// events not tied to a reseller (nas.down), which only reach endpoints without a reseller.
+func (e *WebhookEndpoint) Receives(resellerID uint, parentID *uint) bool {
+	if e.ResellerID == nil {
+		return true
+	}
+	if resellerID == 0 {
+		return false
+	}
+	return *e.ResellerID == resellerID || (parentID != nil && *e.ResellerID == *parentID)
+}
+
+// WebhookDelivery is one event queued for one endpoint, kept for retries, the dead-letter view and replay
+type WebhookDelivery struct {
+	ID            uint       `gorm:"column:id;primaryKey" json:"id"`
+	EndpointID    uint       `gorm:"column:endpoint_id;not null;index" json:"endpoint_id"`
+	EventID       string     `gorm:"column:event_id;size:40;index" json:"event_id"`
+	Event         string     `gorm:"column:event;size:50;index" json:"event"`
+	Payload       string     `gorm:"column:payload;type:text" json:"payload"`
+	Status        string     `gorm:"column:status;size:20;default:pending;index" json:"status"`
+	Attempts      int        `gorm:"column:attempts;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;index" json:"next_attempt_at"`
+	LastAttemptAt *time.Time `gorm:"column:last_attempt_at" json:"last_attempt_at"`
+	ResponseCode  int        `gorm:"column:response_code" json:"response_code"`
+	ResponseBody  string     `gorm:"column:response_body;type:text" json:"response_body"`
+	LastError     string     `gorm:"column:last_error;type:text" json:"last_error"`
+	DeliveredAt   *time.Time `gorm:"column:delivered_at" json:"delivered_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"updated_at"`
+
+	Endpoint *WebhookEndpoint `gorm:"foreignKey:EndpointID" json:"endpoint,omitempty"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,28 @@
+package openapi_test
+
+import (
+	"testing"
+
+	"github.com/proisp/backend/internal/handlers"
+	"github.com/proisp/backend/internal/openapi"
+)
+
+// TestRouteCoverage fails when a route registered in cmd/api/main.go has no entry in the
+// endpoint table, or the table documents a route that is no longer registered
+func TestRouteCoverage(t *testing.T) {
+	routes, err := openapi.ParseRoutes("../../cmd/api/main.go")
+	if err != nil {
+		t.Fatalf("Failed to read routes: %v", err)
+	}
+	if len(routes) == 0 {
+		t.Fatal("No routes found in cmd/api/main.go")
+	}
+
+	endpoints := handlers.APIEndpoints()
+	for _, r := range openapi.Missing(routes, endpoints) {
+		t.Errorf("missing: %s %s is registered but not documented", r.Method, r.Path)
+	}
+	for _, r := range openapi.Stale(routes, endpoints) {
+		t.Errorf("stale: %s %s is documented but not registered", r.Method, r.Path)
+	}
+}
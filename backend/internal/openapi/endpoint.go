@@ -0,0 +1,330 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Auth is how a caller authenticates to an endpoint
+type Auth int
+
+const (
+	AuthStaff    Auth = iota // Staff JWT or API key
+	AuthPublic               // No credentials
+	AuthCustomer             // Customer portal JWT
+)
+
+// Endpoint declares one route for the spec. Build it with Get/Post/Put/Patch/Delete and
+// the chained modifiers, e.g.
+//
+//	openapi.Post("/api/subscribers", "Create a subscriber").Body(CreateSubscriberRequest{}).Returns(SubscriberResponse{})
+type Endpoint struct {
+	Method      string
+	Path        string // Fiber syntax: /api/subscribers/:id
+	Tag         string
+	Summary     string
+	Request     interface{}
+	Response    interface{}
+	Data        interface{} // Type of "data" inside the generic envelope
+	Query       []Parameter
+	Auth        Auth
+	Status      int    // Success status, 200 unless set by Created
+	UploadField string // Form field of a multipart/form-data file upload
+	ContentType string // Response media type when it is not JSON
+}
+
+func newEndpoint(method, path, summary string) Endpoint {
+	return Endpoint{Method: method, Path: path, Summary: summary}
+}
+
+func Get(path, summary string) Endpoint    { return newEndpoint("GET", path, summary) }
+func Post(path, summary string) Endpoint   { return newEndpoint("POST", path, summary) }
+func Put(path, summary string) Endpoint    { return newEndpoint("PUT", path, summary) }
+func Patch(path, summary string) Endpoint  { return newEndpoint("PATCH", path, summary) }
+func Delete(path, summary string) Endpoint { return newEndpoint("DELETE", path, summary) }
+
+// Body sets the JSON request body type
+func (e Endpoint) Body(v interface{}) Endpoint {
+	e.Request = v
+	return e
+}
+
+// Returns sets the success response type; without it the generic envelope is documented
+func (e Endpoint) Returns(v interface{}) Endpoint {
+	e.Response = v
+	return e
+}
+
+// ReturnsData documents the type of "data" in the generic {success, message, data} envelope
+func (e Endpoint) ReturnsData(v interface{}) Endpoint {
+	e.Data = v
+	return e
+}
+
+// Params documents query string parameters
+func (e Endpoint) Params(names ...string) Endpoint {
+	for _, name := range names {
+		e.Query = append(e.Query, Parameter{Name: name, In: "query", Schema: &Schema{Type: "string"}})
+	}
+	return e
+}
+
+// Paged documents the page/limit query parameters of offset-paginated lists
+func (e Endpoint) Paged() Endpoint {
+	e.Query = append(e.Query,
+		Parameter{Name: "page", In: "query", Description: "Page number, starting at 1", Schema: &Schema{Type: "integer"}},
+		Parameter{Name: "limit", In: "query", Description: "Page size", Schema: &Schema{Type: "integer"}},
+	)
+	return e
+}
+
+// Listed documents the query parameters of the /api/v2 list grammar (see package listquery)
+func (e Endpoint) Listed() Endpoint {
+	e.Query = append(e.Query,
+		Parameter{Name: "fields", In: "query", Description: "Comma separated fields to return", Schema: &Schema{Type: "string"}},
+		Parameter{Name: "filter", In: "query", Description: "field:op:value, repeatable. op is eq, ne, gt, gte, lt, lte, like, in or null", Schema: &Schema{Type: "string"}},
+		Parameter{Name: "q", In: "query", Description: "Free text search", Schema: &Schema{Type: "string"}},
+		Parameter{Name: "sort", In: "query", Description: "Comma separated sort fields, prefix with - for descending", Schema: &Schema{Type: "string"}},
+		Parameter{Name: "limit", In: "query", Description: "Page size", Schema: &Schema{Type: "integer"}},
+		Parameter{Name: "cursor", In: "query", Description: "meta.next_cursor of the previous page", Schema: &Schema{Type: "string"}},
+		Parameter{Name: "count", In: "query", Description: "Include meta.total", Schema: &Schema{Type: "boolean"}},
+		Parameter{Name: "format", In: "query", Description: "json (one page), ndjson or csv (every matching row, streamed)", Schema: &Schema{Type: "string"}},
+	)
+	return e
+}
+
+// Created documents a 201 success status
+func (e Endpoint) Created() Endpoint {
+	e.Status = 201
+	return e
+}
+
+func (e Endpoint) Public() Endpoint {
+	e.Auth = AuthPublic
+	return e
+}
+
+func (e Endpoint) Customer() Endpoint {
+	e.Auth = AuthCustomer
+	return e
+}
+
+// Upload marks a multipart/form-data request carrying a file in the given form field
+func (e Endpoint) Upload(field string) Endpoint {
+	e.UploadField = field
+	return e
+}
+
+// Produces sets a non-JSON response media type such as text/csv or application/pdf
+func (e Endpoint) Produces(contentType string) Endpoint {
+	e.ContentType = contentType
+	return e
+}
+
+// Tagged sets the tag on a group of endpoints
+func Tagged(tag string, endpoints ...Endpoint) []Endpoint {
+	for i := range endpoints {
+		endpoints[i].Tag = tag
+	}
+	return endpoints
+}
+
+// Envelope is the response shape shared by handlers that do not declare their own
+type Envelope struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// ErrorResponse is returned with every 4xx/5xx status
+type ErrorResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// normalizePath drops the trailing slash of group roots: Fiber registers subscribers.Get("/")
+// as "/api/subscribers/" but serves it at "/api/subscribers" too
+func normalizePath(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)\??|\*`)
+
+// specPath converts a Fiber path to OpenAPI syntax and lists its parameters
+func specPath(path string) (string, []Parameter) {
+	var params []Parameter
+	converted := pathParam.ReplaceAllStringFunc(normalizePath(path), func(m string) string {
+		name := "path"
+		if m != "*" {
+			name = strings.TrimSuffix(m[1:], "?")
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		return "{" + name + "}"
+	})
+	return converted, params
+}
+
+// operationID makes "getApiSubscribersId"-style IDs that are unique per method and path
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range path {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		} else {
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+// Build assembles the document for the given endpoints
+func Build(info Info, tags []Tag, endpoints []Endpoint) *Document {
+	gen := newSchemaGenerator()
+	public := []SecurityRequirement{}
+	customer := []SecurityRequirement{{"customerAuth": {}}}
+	errorResponse := &Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: gen.schemaFor(ErrorResponse{})}},
+	}
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Servers: []Server{{URL: "/"}},
+		Tags:    tags,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth":   {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Staff token from POST /api/auth/login"},
+				"apiKey":       {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "API key created under Settings > API Keys"},
+				"customerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Customer token from POST /api/customer/login"},
+			},
+		},
+		Security: []SecurityRequirement{{"bearerAuth": {}}, {"apiKey": {}}},
+	}
+
+	for _, e := range endpoints {
+		path, params := specPath(e.Path)
+		op := &Operation{
+			Summary:     e.Summary,
+			OperationID: operationID(e.Method, normalizePath(e.Path)),
+			Parameters:  append(params, e.Query...),
+			Responses:   map[string]*Response{"default": errorResponse},
+		}
+		if e.Tag != "" {
+			op.Tags = []string{e.Tag}
+		}
+		switch e.Auth {
+		case AuthPublic:
+			op.Security = &public
+		case AuthCustomer:
+			op.Security = &customer
+		}
+
+		if e.UploadField != "" {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"multipart/form-data": {Schema: &Schema{Type: "object", Properties: map[string]*Schema{
+					e.UploadField: {Type: "string", Format: "binary"},
+				}}},
+			}}
+		} else if e.Request != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+				"application/json": {Schema: gen.schemaFor(e.Request)},
+			}}
+		}
+
+		success := &Response{Description: "Success"}
+		switch {
+		case e.ContentType != "":
+			success.Content = map[string]MediaType{e.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		case e.Response != nil:
+			success.Content = map[string]MediaType{"application/json": {Schema: gen.schemaFor(e.Response)}}
+		case e.Data != nil:
+			envelope := gen.structSchema(reflect.TypeOf(Envelope{}))
+			envelope.Properties["data"] = gen.schemaFor(e.Data)
+			success.Content = map[string]MediaType{"application/json": {Schema: envelope}}
+		default:
+			success.Content = map[string]MediaType{"application/json": {Schema: gen.schemaFor(Envelope{})}}
+		}
+		status := "200"
+		if e.Status != 0 {
+			status = strconv.Itoa(e.Status)
+		}
+		op.Responses[status] = success
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(e.Method)] = op
+	}
+
+	doc.Components.Schemas = gen.schemas
+	return doc
+}
+
+// Route is a method and path registered on the router
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Missing returns registered routes without an endpoint declaration. HEAD routes, which
+// Fiber adds for every GET, are ignored.
+func Missing(routes []Route, endpoints []Endpoint) []Route {
+	declared := map[Route]bool{}
+	for _, e := range endpoints {
+		declared[Route{e.Method, normalizePath(e.Path)}] = true
+	}
+
+	seen := map[Route]bool{}
+	var missing []Route
+	for _, r := range routes {
+		r.Path = normalizePath(r.Path)
+		if r.Method == "HEAD" || seen[r] || declared[r] {
+			continue
+		}
+		seen[r] = true
+		missing = append(missing, r)
+	}
+	sortRoutes(missing)
+	return missing
+}
+
+// Stale returns endpoint declarations that no longer match a registered route
+func Stale(routes []Route, endpoints []Endpoint) []Route {
+	registered := map[Route]bool{}
+	for _, r := range routes {
+		registered[Route{r.Method, normalizePath(r.Path)}] = true
+	}
+
+	var stale []Route
+	for _, e := range endpoints {
+		if r := (Route{e.Method, normalizePath(e.Path)}); !registered[r] {
+			stale = append(stale, r)
+		}
+	}
+	sortRoutes(stale)
+	return stale
+}
+
+func sortRoutes(routes []Route) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+}
@@ -0,0 +1,166 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaGenerator turns Go types into schemas, registering named structs as components
+// so each model is described once and referenced everywhere else
+type schemaGenerator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: map[string]*Schema{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+// schemaFor describes the value v (a struct, slice, map or nil)
+func (g *schemaGenerator) schemaFor(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return g.schemaForType(reflect.TypeOf(v))
+}
+
+func (g *schemaGenerator) schemaForType(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		s := g.schemaForType(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return marshalerSchema(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaForType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaForType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.register(t)}
+	}
+	// interface{} and anything else: any value
+	return &Schema{}
+}
+
+// register adds a named struct to the components and returns its schema name
+func (g *schemaGenerator) register(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		// Same type name in two packages, e.g. models.Subscriber and a handler type
+		pkg := t.PkgPath()
+		name = strings.Title(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{} // placeholder so recursive types terminate
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields follows encoding/json's rules: json tag names, "-" skipped, unexported
+// fields ignored and anonymous struct fields flattened
+func (g *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && !ft.Implements(marshalerType) {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schemaForType(ft)
+	}
+}
+
+// marshalerSchema guesses the JSON shape of a type with custom marshalling from its zero value
+func marshalerSchema(t reflect.Type) *Schema {
+	data, err := json.Marshal(reflect.New(t).Interface())
+	if err != nil || len(data) == 0 {
+		return &Schema{}
+	}
+	switch data[0] {
+	case '"':
+		return &Schema{Type: "string"}
+	case 't', 'f':
+		return &Schema{Type: "boolean"}
+	case '{':
+		return &Schema{Type: "object"}
+	case '[':
+		return &Schema{Type: "array", Items: &Schema{}}
+	case 'n':
+		// Nullable wrappers around a time, such as gorm.DeletedAt
+		if t.Kind() == reflect.Struct {
+			for i := 0; i < t.NumField(); i++ {
+				if t.Field(i).Type == timeType {
+					return &Schema{Type: "string", Format: "date-time", Nullable: true}
+				}
+			}
+		}
+		return &Schema{}
+	}
+	return &Schema{Type: "number"}
+}
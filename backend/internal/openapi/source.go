@@ -0,0 +1,88 @@
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+var routeMethods = map[string]string{
+	"Get": "GET", "Post": "POST", "Put": "PUT", "Patch": "PATCH", "Delete": "DELETE",
+}
+
+// ParseRoutes reads the routes registered in a Go source file without running it, so the
+// spec can be checked in CI where there is no database. It follows the pattern used by
+// cmd/api/main.go: groups assigned to variables (api := app.Group("/api")) and routes
+// registered on them with string literal paths.
+func ParseRoutes(filename string) ([]Route, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := map[string]string{"app": ""}
+	var routes []Route
+	var parseErr error
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			if len(node.Lhs) != 1 || len(node.Rhs) != 1 {
+				return true
+			}
+			name, ok := node.Lhs[0].(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if recv, method, path, ok := routeCall(node.Rhs[0]); ok && method == "Group" {
+				if prefix, known := prefixes[recv]; known {
+					prefixes[name.Name] = prefix + path
+				}
+			}
+		case *ast.CallExpr:
+			recv, method, path, ok := routeCall(node)
+			if !ok {
+				return true
+			}
+			httpMethod, isRoute := routeMethods[method]
+			if !isRoute {
+				return true
+			}
+			prefix, known := prefixes[recv]
+			if !known {
+				parseErr = fmt.Errorf("%s: route on unknown router %q", fset.Position(node.Pos()), recv)
+				return false
+			}
+			routes = append(routes, Route{Method: httpMethod, Path: prefix + path})
+		}
+		return true
+	})
+
+	return routes, parseErr
+}
+
+// routeCall matches recv.Method("/path", ...)
+func routeCall(expr ast.Expr) (recv, method, path string, ok bool) {
+	call, isCall := expr.(*ast.CallExpr)
+	if !isCall || len(call.Args) == 0 {
+		return "", "", "", false
+	}
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel {
+		return "", "", "", false
+	}
+	ident, isIdent := sel.X.(*ast.Ident)
+	lit, isLit := call.Args[0].(*ast.BasicLit)
+	if !isIdent || !isLit || lit.Kind != token.STRING {
+		return "", "", "", false
+	}
+	path, err := strconv.Unquote(lit.Value)
+	if err != nil || (path != "" && !strings.HasPrefix(path, "/")) {
+		return "", "", "", false
+	}
+	return ident.Name, sel.Sel.Name, path, true
+}
@@ -0,0 +1,207 @@
+package radius
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/commission"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/security"
+	"golang.org/x/crypto/bcrypt"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// isHotspotRequest reports whether an Access-Request comes from a MikroTik hotspot login.
+// PPP sends Service-Type=Framed-User, the hotspot sends Login-User.
+func isHotspotRequest(p *radius.Packet) bool {
+	return rfc2865.ServiceType_Get(p) == rfc2865.ServiceType_Value_LoginUser
+}
+
+// normalizeMAC returns a MAC address as upper-case colon-separated hex, or "" if s is not a MAC
+func normalizeMAC(s string) string {
+	hw, err := net.ParseMAC(strings.TrimSpace(s))
+	if err != nil || len(hw) != 6 {
+		return ""
+	}
+	return strings.ToUpper(hw.String())
+}
+
+// verifyPassword checks the request credentials against the plain password.
+// MS-CHAPv2 is tried first (PPPoE), then CHAP (hotspot http-chap), then PAP.
+// Returns the MS-CHAP2-Success value when MS-CHAPv2 was used, and the method name for logging.
+func verifyPassword(p *radius.Packet, originalUsername, plainPassword string) (bool, []byte, string) {
+	mschapChallenge := getMSCHAPChallenge(p)
+	mschap2Response := getMSCHAP2Response(p)
+	if len(mschapChallenge) > 0 && len(mschap2Response) >= 50 {
+		// Use originalUsername for hash calculation (client uses full username with realm)
+		ok, success := verifyMSCHAP2(originalUsername, plainPassword, mschapChallenge, mschap2Response)
+		return ok, success, "MS-CHAPv2"
+	}
+
+	if chapPassword := rfc2865.CHAPPassword_Get(p); len(chapPassword) == 17 {
+		return verifyCHAP(p, chapPassword, plainPassword), nil, "CHAP"
+	}
+
+	return rfc2865.UserPassword_GetString(p) == plainPassword, nil, "PAP"
+}
+
+// verifyCHAP checks a CHAP-Password (RFC 2865 section 2.2): MD5(ident + password + challenge).
+// The challenge is CHAP-Challenge if present, otherwise the request authenticator.
+func verifyCHAP(p *radius.Packet, chapPassword []byte, plainPassword string) bool {
+	challenge := rfc2865.CHAPChallenge_Get(p)
+	if len(challenge) == 0 {
+		challenge = p.Authenticator[:]
+	}
+
+	h := md5.New()
+	h.Write(chapPassword[:1])
+	h.Write([]byte(plainPassword))
+	h.Write(challenge)
+	return bytes.Equal(h.Sum(nil), chapPassword[1:])
+}
+
+// findHotspotMACSubscriber looks up the hotspot subscriber bound to a MAC address (hotspot MAC login)
+func findHotspotMACSubscriber(mac string) (*models.Subscriber, error) {
+	candidates := []string{mac, strings.ToLower(mac), strings.ReplaceAll(mac, ":", "-"), strings.ToLower(strings.ReplaceAll(mac, ":", "-"))}
+
+	var subscriber models.Subscriber
+	if err := database.DB.Preload("Service").
+		Where("connection_type = ? AND mac_address IN ?", models.ConnectionTypeHotspot, candidates).
+		First(&subscriber).Error; err != nil {
+		return nil, err
+	}
+	return &subscriber, nil
+}
+
+// checkHotspotMACPassword verifies the password the router sends with a MAC login.
+// Without hotspot_mac_auth_password set, the MAC itself is the credential.
+func checkHotspotMACPassword(p *radius.Packet, originalUsername string) bool {
+	expected := getSettingString("hotspot_mac_auth_password", "")
+	if expected == "" {
+		return true
+	}
+	ok, _, _ := verifyPassword(p, originalUsername, expected)
+	return ok
+}
+
+// redeemHotspotVoucher turns an unused prepaid card into a hotspot subscriber on first login.
+// The card code is the username and the PIN the password (the code itself when the card has no PIN).
+func redeemHotspotVoucher(p *radius.Packet, code string) (*models.Subscriber, error) {
+	var card models.PrepaidCard
+	if err := database.DB.Where("code = ? AND is_used = ? AND is_active = ?", code, false, true).First(&card).Error; err != nil {
+		return nil, fmt.Errorf("no unused voucher")
+	}
+	if card.ExpiryDate != nil && card.ExpiryDate.Before(time.Now()) {
+		return nil, fmt.Errorf("voucher expired")
+	}
+
+	password := card.PIN
+	if password == "" {
+		password = card.Code
+	}
+	if ok, _, _ := verifyPassword(p, code, password); !ok {
+		return nil, fmt.Errorf("wrong voucher PIN")
+	}
+
+	var service models.Service
+	if err := database.DB.First(&service, card.ServiceID).Error; err != nil {
+		return nil, fmt.Errorf("voucher service not found")
+	}
+
+	now := time.Now()
+	expiryDate := now.AddDate(0, 0, card.Days).Add(time.Duration(card.Hours) * time.Hour)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	subscriber := models.Subscriber{
+		Username:             card.Code,
+		Password:             string(hashedPassword),
+		PasswordPlain:        security.EncryptPassword(password),
+		FullName:             "Voucher " + card.Code,
+		Note:                 fmt.Sprintf("Created by hotspot voucher login (batch %s)", card.BatchID),
+		ServiceID:            card.ServiceID,
+		Status:               models.SubscriberStatusActive,
+		ExpiryDate:           expiryDate,
+		Price:                card.Value,
+		ResellerID:           card.ResellerID,
+		SimultaneousSessions: 1,
+		ConnectionType:       models.ConnectionTypeHotspot,
+	}
+
+	tx := database.DB.Begin()
+	// Claim the card first so two concurrent logins cannot both redeem it
+	claim := tx.Model(&models.PrepaidCard{}).Where("id = ? AND is_used = ?", card.ID, false).
+		Updates(map[string]interface{}{"is_used": true, "used_at": &now})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, fmt.Errorf("voucher already used")
+	}
+	if err := tx.Create(&subscriber).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create subscriber: %v", err)
+	}
+	tx.Model(&models.PrepaidCard{}).Where("id = ?", card.ID).Update("used_by", subscriber.ID)
+
+	radCheck := []models.RadCheck{
+		{Username: subscriber.Username, Attribute: "Cleartext-Password", Op: ":=", Value: password},
+		{Username: subscriber.Username, Attribute: "Expiration", Op: ":=", Value: expiryDate.Format("Jan 02 2006 15:04:05")},
+		{Username: subscriber.Username, Attribute: "Simultaneous-Use", Op: ":=", Value: "1"},
+	}
+	tx.Create(&radCheck)
+
+	transaction := models.Transaction{
+		ResellerID:   card.ResellerID,
+		SubscriberID: &subscriber.ID,
+		Type:         models.TransactionTypePrepaidCard,
+		Amount:       card.Value,
+		Description:  fmt.Sprintf("Hotspot voucher redeemed: %s", card.Code),
+		ServiceName:  service.Name,
+	}
+	tx.Create(&transaction)
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to redeem voucher: %v", err)
+	}
+	commission.PostAsync(transaction)
+
+	log.Printf("Hotspot: Voucher %s redeemed, subscriber expires %s", card.Code, expiryDate.Format("2006-01-02 15:04"))
+
+	subscriber.Service = &service
+	return &subscriber, nil
+}
+
+// hotspotSessionTimeout returns the Session-Timeout for a hotspot login: the smallest of the
+// time until expiry, the hotspot_session_timeout setting and what is left of the daily time quota.
+// A negative result means the daily time quota is used up.
+func hotspotSessionTimeout(subscriber *models.Subscriber) int {
+	timeout := int(time.Until(subscriber.ExpiryDate).Seconds())
+
+	if limit := getSettingInt("hotspot_session_timeout", 0); limit > 0 && (timeout <= 0 || limit < timeout) {
+		timeout = limit
+	}
+
+	if subscriber.Service != nil && subscriber.Service.TimeQuota > 0 {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		var used int64
+		database.DB.Model(&models.RadAcct{}).
+			Where("username = ? AND acctstarttime >= ?", subscriber.Username, today).
+			Select("COALESCE(SUM(acctsessiontime), 0)").Scan(&used)
+
+		left := subscriber.Service.TimeQuota*60 - int(used)
+		if left <= 0 {
+			return -1
+		}
+		if timeout <= 0 || left < timeout {
+			timeout = left
+		}
+	}
+
+	return timeout
+}
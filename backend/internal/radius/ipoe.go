@@ -0,0 +1,146 @@
+package radius
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
+)
+
+// DHCP Option 82 relay agent information, sent by the DHCP server as ADSL-Forum VSAs (RFC 4679)
+const (
+	vendorADSLForum   = 3561
+	vsaAgentCircuitID = 1
+	vsaAgentRemoteID  = 2
+)
+
+// IPoE auth modes (Nas.IPoEAuthMode)
+const (
+	IPoEAuthMAC      = "mac"
+	IPoEAuthOption82 = "option82"
+	IPoEAuthBoth     = "both"
+)
+
+// ipoeSubscriberScope limits lookups to subscribers in IPoE mode, either per subscriber or per plan
+const ipoeSubscriberScope = "(connection_type = 'ipoe' OR service_id IN (SELECT id FROM services WHERE ipoe = true))"
+
+var errNoOption82 = errors.New("request has no Option 82 circuit-id")
+
+// getVendorAttribute extracts a vendor-specific attribute value from a RADIUS packet
+func getVendorAttribute(p *radius.Packet, vendor uint32, vsaType byte) []byte {
+	for _, attr := range p.Attributes {
+		if attr.Type != 26 || len(attr.Attribute) < 6 {
+			continue
+		}
+		if binary.BigEndian.Uint32(attr.Attribute[0:4]) != vendor {
+			continue
+		}
+		// One attribute can carry several sub-attributes
+		data := attr.Attribute[4:]
+		for len(data) >= 2 {
+			length := int(data[1])
+			if length < 2 || length > len(data) {
+				break
+			}
+			if data[0] == vsaType {
+				return data[2:length]
+			}
+			data = data[length:]
+		}
+	}
+	return nil
+}
+
+// hasChallengeCredentials reports whether the request carries CHAP or MS-CHAP data (a PPP login)
+func hasChallengeCredentials(p *radius.Packet) bool {
+	return len(rfc2865.CHAPPassword_Get(p)) > 0 || len(getMSCHAP2Response(p)) > 0
+}
+
+// getNasIPoEMode returns the IPoE auth mode configured for the NAS, or "" when IPoE is off
+func getNasIPoEMode(nasIP string) string {
+	var nas models.Nas
+	if err := database.DB.Select("id, ipoe_auth_mode").Where("ip_address = ?", nasIP).First(&nas).Error; err != nil {
+		return ""
+	}
+	return nas.IPoEAuthMode
+}
+
+// option82Candidates returns the values an Option 82 field may be stored as: raw text and hex
+func option82Candidates(value []byte) []string {
+	raw := strings.TrimSpace(string(value))
+	encoded := hex.EncodeToString(value)
+	return []string{raw, encoded, strings.ToUpper(encoded)}
+}
+
+// findIPoESubscriber identifies the subscriber behind a DHCP Access-Request.
+// Option 82 (circuit-id, optionally narrowed by remote-id) is tried first, then the client MAC.
+func findIPoESubscriber(p *radius.Packet, mode, username string) (*models.Subscriber, error) {
+	var subscriber models.Subscriber
+
+	if mode == IPoEAuthOption82 || mode == IPoEAuthBoth {
+		if circuitID := getVendorAttribute(p, vendorADSLForum, vsaAgentCircuitID); len(circuitID) > 0 {
+			query := database.DB.Preload("Service").Where(ipoeSubscriberScope).
+				Where("circuit_id IN ?", option82Candidates(circuitID))
+			if remoteID := getVendorAttribute(p, vendorADSLForum, vsaAgentRemoteID); len(remoteID) > 0 {
+				query = query.Where("(remote_id IS NULL OR remote_id = '' OR remote_id IN ?)", option82Candidates(remoteID))
+			}
+			if err := query.First(&subscriber).Error; err == nil {
+				return &subscriber, nil
+			} else if mode == IPoEAuthOption82 {
+				return nil, err
+			}
+		} else if mode == IPoEAuthOption82 {
+			return nil, errNoOption82
+		}
+	}
+
+	mac := normalizeMAC(username)
+	candidates := []string{mac, strings.ToLower(mac), strings.ReplaceAll(mac, ":", "-"), strings.ToLower(strings.ReplaceAll(mac, ":", "-"))}
+	if err := database.DB.Preload("Service").Where(ipoeSubscriberScope).
+		Where("mac_address IN ? OR username IN ?", candidates, candidates).
+		First(&subscriber).Error; err != nil {
+		return nil, err
+	}
+	return &subscriber, nil
+}
+
+// resolveIPoEAcctUsername maps DHCP accounting (User-Name = client MAC) back to the subscriber
+// username so radacct, quota sync and session lists see the account, not the MAC.
+func resolveIPoEAcctUsername(p *radius.Packet, username, nasIP string) string {
+	if normalizeMAC(username) == "" {
+		return username
+	}
+	var count int64
+	database.DB.Model(&models.Subscriber{}).Where("username = ?", username).Count(&count)
+	if count > 0 {
+		return username
+	}
+
+	mode := getNasIPoEMode(nasIP)
+	if mode == "" {
+		return username
+	}
+	subscriber, err := findIPoESubscriber(p, mode, username)
+	if err != nil {
+		return username
+	}
+	return subscriber.Username
+}
+
+// ipoeLeaseTime returns the DHCP lease (sent as Session-Timeout), capped at the time until expiry
+func ipoeLeaseTime(subscriber *models.Subscriber) int {
+	lease := getSettingInt("ipoe_lease_time", 3600)
+	if subscriber.Service != nil && subscriber.Service.LeaseTime > 0 {
+		lease = subscriber.Service.LeaseTime
+	}
+	if remaining := int(time.Until(subscriber.ExpiryDate).Seconds()); remaining > 0 && remaining < lease {
+		lease = remaining
+	}
+	return lease
+}
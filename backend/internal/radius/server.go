@@ -18,6 +18,7 @@ import (
 	"github.com/proisp/backend/internal/ippool"
 	"github.com/proisp/backend/internal/models"
 	"github.com/proisp/backend/internal/security"
+	"github.com/proisp/backend/internal/webhook"
 	"golang.org/x/crypto/md4"
 	"layeh.com/radius"
 	"layeh.com/radius/rfc2865"
@@ -122,6 +123,15 @@ func getSettingBool(key string, defaultVal bool) bool {
 	return pref.Value == "true" || pref.Value == "1"
 }
 
+// getSettingString retrieves a string setting from database with default fallback
+func getSettingString(key, defaultVal string) string {
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", key).First(&pref).Error; err != nil {
+		return defaultVal
+	}
+	return pref.Value
+}
+
 // findAvailableIP finds an available IP in the same /24 subnet that's not used by any online user
 // or assigned as a static IP to anyone
 func findAvailableIP(conflictIP string) string {
@@ -223,6 +233,13 @@ func (s *Server) GetSecret(remoteAddr net.Addr) ([]byte, error) {
 	secret, ok := s.secrets[host]
 	s.secretsMu.RUnlock()
 	if !ok {
+		// An auth probe from the API (TestAuth) speaks for the NAS it was announced for
+		if nasIP, err := database.Redis.Get(context.Background(), authProbeKey(host)).Result(); err == nil {
+			var nas models.Nas
+			if err := database.DB.Where("ip_address = ? AND is_active = ?", nasIP, true).First(&nas).Error; err == nil {
+				return []byte(nas.Secret), nil
+			}
+		}
 		return nil, fmt.Errorf("unknown NAS: %s", host)
 	}
 
@@ -287,6 +304,12 @@ func (s *Server) handleAuth(w radius.ResponseWriter, r *radius.Request) {
 	nasIP := rfc2865.NASIPAddress_Get(r.Packet)
 	callingStationID := rfc2865.CallingStationID_GetString(r.Packet)
 
+	// Provisioning probes only check that the secret round-trips
+	if username == AuthProbeUsername {
+		w.Write(r.Response(radius.CodeAccessReject))
+		return
+	}
+
 	log.Printf("Auth request: user=%s, nas=%s, mac=%s", username, nasIP, callingStationID)
 
 	// Start timing
@@ -298,14 +321,53 @@ func (s *Server) handleAuth(w radius.ResponseWriter, r *radius.Request) {
 		log.Printf("Realm stripped: %s -> %s", originalUsername, username)
 	}
 
+	hotspot := isHotspotRequest(r.Packet)
+	macLogin := false
+
+	// IPoE: a DHCP server on an IPoE NAS sends the client MAC as User-Name and no password
+	ipoeMode := ""
+	if !hotspot && normalizeMAC(username) != "" && !hasChallengeCredentials(r.Packet) {
+		ipoeMode = getNasIPoEMode(nasIP.String())
+	}
+	ipoe := ipoeMode != ""
+
 	// Get subscriber from cache or database
-	subscriber, err := s.getSubscriber(username)
+	var subscriber *models.Subscriber
+	var err error
+	if ipoe {
+		subscriber, err = findIPoESubscriber(r.Packet, ipoeMode, username)
+		if err == nil {
+			log.Printf("IPoE login (%s): %s -> %s", ipoeMode, username, subscriber.Username)
+			if callingStationID == "" {
+				callingStationID = normalizeMAC(username)
+			}
+		}
+	} else {
+		subscriber, err = s.getSubscriber(username)
+	}
+	if err != nil && hotspot {
+		// Hotspot MAC login: the router sends the client MAC as the username
+		if mac := normalizeMAC(username); mac != "" {
+			if subscriber, err = findHotspotMACSubscriber(mac); err == nil {
+				macLogin = true
+				log.Printf("Hotspot MAC login: %s -> %s", username, subscriber.Username)
+			}
+		} else if getSettingBool("hotspot_voucher_login", true) {
+			// First login with an unused prepaid card creates the subscriber
+			if subscriber, err = redeemHotspotVoucher(r.Packet, username); err != nil {
+				log.Printf("Hotspot voucher %s not redeemed: %v", username, err)
+			}
+		}
+	}
 	if err != nil {
 		log.Printf("Auth reject (user not found): %s", username)
 		s.logPostAuth(username, callingStationID, "Access-Reject")
 		w.Write(r.Response(radius.CodeAccessReject))
 		return
 	}
+	if macLogin || ipoe {
+		username = subscriber.Username
+	}
 
 	// Check if subscriber is active
 	if subscriber.Status != models.SubscriberStatusActive {
@@ -323,54 +385,64 @@ func (s *Server) handleAuth(w radius.ResponseWriter, r *radius.Request) {
 		return
 	}
 
-	// Get password from radcheck table (Cleartext-Password)
-	var radcheck models.RadCheck
-	var plainPassword string
-	if err := database.DB.Where("username = ? AND attribute = ?", username, "Cleartext-Password").First(&radcheck).Error; err == nil {
-		plainPassword = radcheck.Value
-	} else {
-		// Fallback: try to decrypt from subscriber.PasswordPlain
-		plainPassword = security.DecryptPassword(subscriber.PasswordPlain)
-	}
-	if plainPassword == "" {
-		log.Printf("Auth reject (password not found): %s", username)
-		s.logPostAuth(username, callingStationID, "Access-Reject")
-		w.Write(r.Response(radius.CodeAccessReject))
-		return
-	}
-
-	// Try MS-CHAPv2 first (preferred for PPPoE)
-	mschapChallenge := getMSCHAPChallenge(r.Packet)
-	mschap2Response := getMSCHAP2Response(r.Packet)
-
 	var authSuccess bool
 	var mschap2SuccessResponse []byte
 
-	if len(mschapChallenge) > 0 && len(mschap2Response) >= 50 {
-		// MS-CHAPv2 authentication - use originalUsername for hash calculation (client uses full username with realm)
-		authSuccess, mschap2SuccessResponse = verifyMSCHAP2(originalUsername, plainPassword, mschapChallenge, mschap2Response)
-		if !authSuccess {
-			log.Printf("Auth reject (MS-CHAPv2 failed): %s", username)
+	if macLogin {
+		// The MAC is the credential; the router may add a shared MAC-auth password
+		if !checkHotspotMACPassword(r.Packet, originalUsername) {
+			log.Printf("Auth reject (wrong MAC-auth password): %s", originalUsername)
 			s.logPostAuth(username, callingStationID, "Access-Reject")
 			w.Write(r.Response(radius.CodeAccessReject))
 			return
 		}
-		log.Printf("MS-CHAPv2 auth success for: %s", username)
+		authSuccess = true
+	} else if ipoe {
+		// DHCP requests carry no password; the MAC / Option 82 match on a known NAS is the credential
+		authSuccess = true
 	} else {
-		// Fall back to PAP authentication
-		password := rfc2865.UserPassword_GetString(r.Packet)
-		if plainPassword != password {
-			log.Printf("Auth reject (wrong password - PAP): %s", username)
+		// Get password from radcheck table (Cleartext-Password)
+		var radcheck models.RadCheck
+		var plainPassword string
+		if err := database.DB.Where("username = ? AND attribute = ?", username, "Cleartext-Password").First(&radcheck).Error; err == nil {
+			plainPassword = radcheck.Value
+		} else {
+			// Fallback: try to decrypt from subscriber.PasswordPlain
+			plainPassword = security.DecryptPassword(subscriber.PasswordPlain)
+		}
+		if plainPassword == "" {
+			log.Printf("Auth reject (password not found): %s", username)
 			s.logPostAuth(username, callingStationID, "Access-Reject")
 			w.Write(r.Response(radius.CodeAccessReject))
 			return
 		}
-		authSuccess = true
-		log.Printf("PAP auth success for: %s", username)
+
+		// MS-CHAPv2 (preferred for PPPoE), CHAP (hotspot), then PAP
+		var method string
+		authSuccess, mschap2SuccessResponse, method = verifyPassword(r.Packet, originalUsername, plainPassword)
+		if !authSuccess {
+			log.Printf("Auth reject (wrong password - %s): %s", method, username)
+			s.logPostAuth(username, callingStationID, "Access-Reject")
+			w.Write(r.Response(radius.CodeAccessReject))
+			return
+		}
+		log.Printf("%s auth success for: %s", method, username)
 	}
 
-	// Check MAC binding
-	if subscriber.SaveMAC && subscriber.MACAddress != "" {
+	// Hotspot: enforce the daily time quota before accepting
+	hotspotTimeout := 0
+	if hotspot {
+		hotspotTimeout = hotspotSessionTimeout(subscriber)
+		if hotspotTimeout < 0 {
+			log.Printf("Auth reject (daily time quota used): %s", username)
+			s.logPostAuth(username, callingStationID, "Access-Reject")
+			w.Write(r.Response(radius.CodeAccessReject))
+			return
+		}
+	}
+
+	// Check MAC binding (a MAC login already matched on it)
+	if !macLogin && subscriber.SaveMAC && subscriber.MACAddress != "" {
 		normalizedMAC := strings.ToUpper(strings.ReplaceAll(callingStationID, "-", ":"))
 		normalizedSavedMAC := strings.ToUpper(strings.ReplaceAll(subscriber.MACAddress, "-", ":"))
 		if normalizedMAC != normalizedSavedMAC {
@@ -391,6 +463,11 @@ func (s *Server) handleAuth(w radius.ResponseWriter, r *radius.Request) {
 		response.Add(26, vsaData)
 	}
 
+	// Return the account name so hotspot MAC logins are accounted under the subscriber
+	if macLogin {
+		rfc2865.UserName_SetString(response, username)
+	}
+
 	// Add Mikrotik rate limit
 	// Priority: 1) Per-subscriber bandwidth rule, 2) radreply (FUP), 3) service default
 	var radReply models.RadReply
@@ -487,7 +564,7 @@ func (s *Server) handleAuth(w radius.ResponseWriter, r *radius.Request) {
 	}
 
 	// Priority 3: ProISP IP management - allocate from pool
-	if framedIPToSend == "" && proispIPManagement && subscriber.Service != nil && subscriber.Service.PoolName != "" {
+	if framedIPToSend == "" && !hotspot && proispIPManagement && subscriber.Service != nil && subscriber.Service.PoolName != "" {
 		// Get NAS ID for the allocation
 		var nasID uint
 		var nas models.Nas
@@ -524,32 +601,45 @@ func (s *Server) handleAuth(w radius.ResponseWriter, r *radius.Request) {
 		}
 	}
 
-	// Send IP to MikroTik
-	if framedIPToSend != "" {
+	// Send IP to MikroTik (hotspot clients already have a DHCP lease)
+	if !hotspot && framedIPToSend != "" {
 		ip := net.ParseIP(framedIPToSend)
 		if ip != nil {
 			rfc2865.FramedIPAddress_Set(response, ip)
 			log.Printf("Sending Framed-IP-Address=%s for %s", framedIPToSend, username)
 		}
-	} else if subscriber.Service != nil && subscriber.Service.PoolName != "" {
+	} else if !hotspot && subscriber.Service != nil && subscriber.Service.PoolName != "" {
 		// No specific IP - send Framed-Pool for MikroTik to assign (legacy mode)
 		rfc2869.FramedPool_SetString(response, subscriber.Service.PoolName)
 		log.Printf("Sending Framed-Pool=%s for %s (MikroTik will assign IP)", subscriber.Service.PoolName, username)
 	}
 
 	// Add session timeout - use minimum of (time until expiry, default_session_timeout)
+	// Hotspot logins use their own limit, including the daily time quota
 	defaultSessionTimeout := getSettingInt("default_session_timeout", 86400)
 	remainingSeconds := int(time.Until(subscriber.ExpiryDate).Seconds())
 	sessionTimeout := defaultSessionTimeout
 	if remainingSeconds > 0 && remainingSeconds < defaultSessionTimeout {
 		sessionTimeout = remainingSeconds
 	}
+	if hotspot {
+		sessionTimeout = hotspotTimeout
+	}
+	if ipoe {
+		// For DHCP, Session-Timeout is the lease time
+		sessionTimeout = ipoeLeaseTime(subscriber)
+	}
 	if sessionTimeout > 0 {
 		rfc2865.SessionTimeout_Set(response, rfc2865.SessionTimeout(sessionTimeout))
 	}
 
 	// Add idle timeout from settings
 	idleTimeout := getSettingInt("idle_timeout", 0)
+	if hotspot {
+		idleTimeout = getSettingInt("hotspot_idle_timeout", 300)
+	} else if ipoe {
+		idleTimeout = 0
+	}
 	if idleTimeout > 0 {
 		rfc2865.IdleTimeout_Set(response, rfc2865.IdleTimeout(idleTimeout))
 	}
@@ -591,6 +681,14 @@ func (s *Server) handleAcct(w radius.ResponseWriter, r *radius.Request) {
 	nasIP := rfc2865.NASIPAddress_Get(r.Packet)
 	framedIP := rfc2865.FramedIPAddress_Get(r.Packet)
 	callingStationID := rfc2865.CallingStationID_GetString(r.Packet)
+
+	// IPoE accounting arrives under the client MAC - record it for the subscriber
+	if mapped := resolveIPoEAcctUsername(r.Packet, username, nasIP.String()); mapped != username {
+		if callingStationID == "" {
+			callingStationID = normalizeMAC(username)
+		}
+		username = mapped
+	}
 	sessionTime := rfc2866.AcctSessionTime_Get(r.Packet)
 	inputOctets := rfc2866.AcctInputOctets_Get(r.Packet)
 	outputOctets := rfc2866.AcctOutputOctets_Get(r.Packet)
@@ -773,6 +871,14 @@ func (s *Server) handleAcct(w radius.ResponseWriter, r *radius.Request) {
 			}
 			database.DB.Model(&models.Subscriber{}).Where("username = ?", username).Updates(updates)
 
+			webhook.EmitSubscriberByUsername(models.WebhookEventSessionStart, username, map[string]interface{}{
+				"session_id":         sessionID,
+				"nas_ip_address":     nasIP.String(),
+				"framed_ip_address":  framedIP.String(),
+				"calling_station_id": callingStationID,
+				"session_start":      now,
+			})
+
 			// WAN Management Check: if enabled and subscriber is unchecked,
 			// log that QuotaSync will perform the check on the next cycle.
 			if getSettingBool("wan_check_enabled", false) {
@@ -826,6 +932,18 @@ func (s *Server) handleAcct(w radius.ResponseWriter, r *radius.Request) {
 
 			// Update quota
 			s.updateQuota(username, int64(inputOctets), int64(outputOctets))
+
+			webhook.EmitSubscriberByUsername(models.WebhookEventSessionStop, username, map[string]interface{}{
+				"session_id":         sessionID,
+				"nas_ip_address":     nasIP.String(),
+				"framed_ip_address":  framedIP.String(),
+				"calling_station_id": callingStationID,
+				"session_stop":       now,
+				"session_time":       sessionTime,
+				"input_octets":       inputOctets,
+				"output_octets":      outputOctets,
+				"terminate_cause":    cause,
+			})
 		}()
 
 	case rfc2866.AcctStatusType_Value_InterimUpdate:
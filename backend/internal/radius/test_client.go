@@ -1,11 +1,17 @@
 package radius
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"layeh.com/radius"
+	"layeh.com/radius/rfc2865"
 )
 
 const (
@@ -59,6 +65,97 @@ func TestSecret(nasIP string, authPort int, secret string) TestResult {
 	return result
 }
 
+// TestSecretOnPort verifies the RADIUS secret against one specific CoA port
+// Used after provisioning, when the configured CoA port is known
+func TestSecretOnPort(nasIP string, coaPort int, secret string) TestResult {
+	if secret == "" {
+		return TestResult{ErrorMsg: "RADIUS secret not configured"}
+	}
+
+	result := sendCoATest(nasIP, coaPort, secret)
+	result.SecretSet = true
+	if !result.Success && result.ErrorMsg == "Timeout - no response" {
+		result.ErrorMsg = fmt.Sprintf("No CoA response on port %d - secret may be wrong or CoA not enabled", coaPort)
+	}
+	return result
+}
+
+// AuthProbeUsername is the User-Name of the Access-Request sent by TestAuth. The auth
+// server rejects it without looking up a subscriber.
+const AuthProbeUsername = "proisp-auth-probe"
+
+// authProbeTTL is how long the RADIUS server accepts a probe announced by TestAuth
+const authProbeTTL = 10 * time.Second
+
+// authProbeKey names the Redis key announcing a probe from host on behalf of a NAS
+func authProbeKey(host string) string {
+	return "proisp:radius:auth_probe:" + host
+}
+
+// TestAuth sends an Access-Request to the RADIUS auth server the way the NAS would,
+// signed with the NAS secret, and checks that a reply signed with the same secret comes
+// back. The server only knows NASes by source address, so the probe is announced first
+// and the server answers it with the secret stored for nasIP.
+func TestAuth(serverAddr string, authPort int, nasIP, secret string) TestResult {
+	result := TestResult{}
+	if secret == "" {
+		result.ErrorMsg = "RADIUS secret not configured"
+		return result
+	}
+	result.SecretSet = true
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(serverAddr, strconv.Itoa(authPort)), 2*time.Second)
+	if err != nil {
+		result.ErrorMsg = fmt.Sprintf("Cannot connect to auth port %d", authPort)
+		return result
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	host, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+	if err := database.Redis.Set(context.Background(), authProbeKey(host), nasIP, authProbeTTL).Err(); err != nil {
+		result.ErrorMsg = fmt.Sprintf("Failed to announce auth probe: %v", err)
+		return result
+	}
+	defer database.Redis.Del(context.Background(), authProbeKey(host))
+
+	request := radius.New(radius.CodeAccessRequest, []byte(secret))
+	rfc2865.UserName_SetString(request, AuthProbeUsername)
+	rfc2865.UserPassword_SetString(request, AuthProbeUsername)
+	if ip := net.ParseIP(nasIP); ip != nil {
+		rfc2865.NASIPAddress_Set(request, ip)
+	}
+	packet, err := request.Encode()
+	if err != nil {
+		result.ErrorMsg = fmt.Sprintf("Failed to build Access-Request: %v", err)
+		return result
+	}
+	if _, err := conn.Write(packet); err != nil {
+		result.ErrorMsg = fmt.Sprintf("Failed to send Access-Request: %v", err)
+		return result
+	}
+
+	response := make([]byte, 4096)
+	n, err := conn.Read(response)
+	if err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			result.ErrorMsg = fmt.Sprintf("No response on auth port %d - RADIUS server unreachable or NAS not registered", authPort)
+			return result
+		}
+		result.ErrorMsg = fmt.Sprintf("Read error: %v", err)
+		return result
+	}
+	if !radius.IsAuthenticResponse(response[:n], packet, []byte(secret)) {
+		result.ErrorMsg = "Reply is not signed with this secret - the RADIUS server has a different secret for this NAS"
+		return result
+	}
+
+	result.Success = true
+	result.SecretValid = true
+	result.ErrorMsg = "Access-Request answered (secret valid)"
+	return result
+}
+
 func sendCoATest(nasIP string, coaPort int, secret string) TestResult {
 	result := TestResult{}
 
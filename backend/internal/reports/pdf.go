@@ -0,0 +1,171 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Page layout of the PDF renderer: A4 landscape, points
+const (
+	pdfPageWidth  = 842.0
+	pdfPageHeight = 595.0
+	pdfMargin     = 36.0
+	pdfFontSize   = 8.0
+	pdfRowHeight  = 12.0
+	pdfCharWidth  = 0.5 * pdfFontSize // Average Helvetica advance; good enough for column sizing
+)
+
+// RenderPDF lays the table out on A4 landscape pages using the built-in Helvetica font,
+// repeating the header on every page. Columns are sized by content and cells that do not
+// fit are cut. Only Latin-1 is representable; other characters print as '?'.
+func RenderPDF(t *Table) ([]byte, error) {
+	widths := pdfColumnWidths(t)
+	usable := pdfPageHeight - 2*pdfMargin - 3*pdfRowHeight // Title, subtitle and header
+	perPage := int(usable / pdfRowHeight)
+
+	var pages []string
+	for start := 0; start == 0 || start < len(t.Rows); start += perPage {
+		end := start + perPage
+		if end > len(t.Rows) {
+			end = len(t.Rows)
+		}
+		pages = append(pages, pdfPage(t, widths, t.Rows[start:end], len(pages)+1))
+	}
+
+	// Objects: 1 catalog, 2 page tree, 3 regular font, 4 bold font, then a page and its
+	// content stream per page
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes(), nil
+}
+
+// pdfColumnWidths shares the usable width between columns in proportion to their
+// longest cell, with a floor so short columns stay readable
+func pdfColumnWidths(t *Table) []float64 {
+	n := len(t.Columns)
+	if n == 0 {
+		return nil
+	}
+	lengths := make([]float64, n)
+	for i, c := range t.Columns {
+		lengths[i] = float64(len([]rune(c)))
+	}
+	for _, row := range t.Rows {
+		for i := 0; i < n && i < len(row); i++ {
+			if l := float64(len([]rune(row[i]))); l > lengths[i] {
+				lengths[i] = l
+			}
+		}
+	}
+
+	var total float64
+	for i := range lengths {
+		if lengths[i] < 4 {
+			lengths[i] = 4
+		}
+		if lengths[i] > 60 {
+			lengths[i] = 60
+		}
+		total += lengths[i]
+	}
+	usable := pdfPageWidth - 2*pdfMargin
+	widths := make([]float64, n)
+	for i := range lengths {
+		widths[i] = usable * lengths[i] / total
+	}
+	return widths
+}
+
+func pdfPage(t *Table, widths []float64, rows [][]string, number int) string {
+	var b strings.Builder
+	y := pdfPageHeight - pdfMargin
+
+	pdfText(&b, "F2", 12, pdfMargin, y, t.Title)
+	footer := fmt.Sprintf("Page %d - generated %s", number, time.Now().Format("2006-01-02 15:04"))
+	pdfText(&b, "F1", pdfFontSize, pdfMargin, pdfMargin/2, footer)
+	y -= pdfRowHeight
+	if t.Subtitle != "" {
+		pdfText(&b, "F1", pdfFontSize, pdfMargin, y, t.Subtitle)
+	}
+	y -= pdfRowHeight * 1.5
+
+	pdfRow(&b, "F2", widths, y, t.Columns)
+	fmt.Fprintf(&b, "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, y-3, pdfPageWidth-pdfMargin, y-3)
+	for _, row := range rows {
+		y -= pdfRowHeight
+		pdfRow(&b, "F1", widths, y, row)
+	}
+	return b.String()
+}
+
+func pdfRow(b *strings.Builder, font string, widths []float64, y float64, cells []string) {
+	x := pdfMargin
+	for i, w := range widths {
+		if i < len(cells) {
+			maxChars := int((w - 4) / pdfCharWidth)
+			cell := []rune(cells[i])
+			if len(cell) > maxChars && maxChars > 1 {
+				cell = append(cell[:maxChars-1], '.')
+			}
+			pdfText(b, font, pdfFontSize, x, y, string(cell))
+		}
+		x += w
+	}
+}
+
+func pdfText(b *strings.Builder, font string, size, x, y float64, text string) {
+	fmt.Fprintf(b, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(text))
+}
+
+// pdfEscape encodes text for a literal string in WinAnsiEncoding
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
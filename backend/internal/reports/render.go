@@ -0,0 +1,200 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Formats lists the output formats Render accepts
+var Formats = []string{"csv", "xlsx", "pdf"}
+
+// ValidFormat reports whether format is a known output format
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Render encodes a table and returns the content and its MIME type
+func Render(t *Table, format string) ([]byte, string, error) {
+	switch format {
+	case "csv":
+		b, err := RenderCSV(t)
+		return b, "text/csv", err
+	case "xlsx":
+		b, err := RenderXLSX(t)
+		return b, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", err
+	case "pdf":
+		b, err := RenderPDF(t)
+		return b, "application/pdf", err
+	}
+	return nil, "", fmt.Errorf("unknown format %q", format)
+}
+
+// RenderCSV writes the header and rows as RFC 4180 CSV
+func RenderCSV(t *Table) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(t.Columns); err != nil {
+		return nil, err
+	}
+	for _, row := range t.Rows {
+		if err := w.Write(csvSafe(row)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvSafe prefixes cells that a spreadsheet would evaluate as a formula. Negative
+// numbers are left alone.
+func csvSafe(row []string) []string {
+	out := make([]string, len(row))
+	for i, v := range row {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				v = "'" + v
+			}
+		}
+		out[i] = v
+	}
+	return out
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+// Style 1 is the bold header
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+
+// RenderXLSX writes a single-sheet workbook. Numeric cells are stored as numbers so
+// they can be summed; everything else is an inline string.
+func RenderXLSX(t *Table) ([]byte, error) {
+	sheetName := t.Title
+	if sheetName == "" {
+		sheetName = "Report"
+	}
+	if len(sheetName) > 31 {
+		sheetName = sheetName[:31]
+	}
+
+	var sheet strings.Builder
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0">`)
+	sheet.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`)
+	writeXLSXRow(&sheet, 1, t.Columns, true)
+	for i, row := range t.Rows {
+		writeXLSXRow(&sheet, i+2, row, false)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	var workbook strings.Builder
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`)
+	workbook.WriteString(xmlEscape(strings.NewReplacer("/", " ", "\\", " ", "?", " ", "*", " ", "[", " ", "]", " ", ":", " ").Replace(sheetName)))
+	workbook.WriteString(`" sheetId="1" r:id="rId1"/></sheets></workbook>`)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+	for _, p := range parts {
+		w, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeXLSXRow(b *strings.Builder, n int, cells []string, header bool) {
+	fmt.Fprintf(b, `<row r="%d">`, n)
+	for i, v := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(n)
+		switch {
+		case header:
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr" s="1"><is><t>%s</t></is></c>`, ref, xmlEscape(v))
+		case isNumber(v):
+			fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, v)
+		case v != "":
+			fmt.Fprintf(b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(v))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// isNumber accepts plain decimals only, so IDs like "007" or phone numbers keep their text form
+func isNumber(v string) bool {
+	if v == "" || len(v) > 15 || (len(v) > 1 && v[0] == '0' && v[1] != '.') {
+		return false
+	}
+	_, err := strconv.ParseFloat(v, 64)
+	return err == nil && !strings.ContainsAny(v, "eEinfINFx+")
+}
+
+// xlsxColumn returns the spreadsheet column letters for a zero-based index
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	// Control characters are not allowed in XML 1.0
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,447 @@
+// Package reports builds the tabular reports that can be exported on demand or run on a
+// schedule, and renders them as CSV, XLSX or PDF.
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// Params narrows a report. Zero values mean "all".
+type Params struct {
+	From       time.Time
+	To         time.Time // Exclusive
+	ResellerID uint
+	ServiceID  uint
+	NasID      uint
+}
+
+// Table is a built report: a title, column headers and rows of display strings
+type Table struct {
+	Title    string
+	Subtitle string
+	Columns  []string
+	Rows     [][]string
+}
+
+// Type is a report that can be built
+type Type struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	build       func(p Params) (*Table, error)
+}
+
+var types = map[string]Type{
+	"subscribers":  {Name: "subscribers", Title: "Subscribers", Description: "Subscriber list with service, reseller, status and expiry", build: buildSubscribers},
+	"revenue":      {Name: "revenue", Title: "Revenue", Description: "Completed payments per day and method", build: buildRevenue},
+	"services":     {Name: "services", Title: "Services", Description: "Subscribers and revenue per service", build: buildServices},
+	"resellers":    {Name: "resellers", Title: "Resellers", Description: "Balance, subscribers and revenue per reseller", build: buildResellers},
+	"usage":        {Name: "usage", Title: "Usage", Description: "Upload, download and online time per subscriber", build: buildUsage},
+	"expiry":       {Name: "expiry", Title: "Expiry", Description: "Subscribers expiring in the period", build: buildExpiry},
+	"transactions": {Name: "transactions", Title: "Transactions", Description: "Balance transactions in the period", build: buildTransactions},
+	"nas":          {Name: "nas", Title: "NAS", Description: "Sessions and traffic per NAS", build: buildNAS},
+}
+
+// Types returns the available reports sorted by name
+func Types() []Type {
+	list := make([]Type, 0, len(types))
+	for _, t := range types {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Valid reports whether name is a known report type
+func Valid(name string) bool {
+	_, ok := types[name]
+	return ok
+}
+
+// Build runs a report
+func Build(name string, p Params) (*Table, error) {
+	t, ok := types[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown report type %q", name)
+	}
+	table, err := t.build(p)
+	if err != nil {
+		return nil, err
+	}
+	table.Title = t.Title + " report"
+	if !p.From.IsZero() || !p.To.IsZero() {
+		table.Subtitle = fmt.Sprintf("%s to %s", p.From.Format("2006-01-02"), p.To.Add(-time.Second).Format("2006-01-02"))
+	}
+	return table, nil
+}
+
+// Range resolves a named date range relative to now: today, yesterday, last_7_days,
+// last_30_days, this_month, previous_month, next_7_days or next_30_days. The end is exclusive.
+func Range(name string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday", "":
+		return today.AddDate(0, 0, -1), today, nil
+	case "last_7_days":
+		return today.AddDate(0, 0, -7), today, nil
+	case "last_30_days":
+		return today.AddDate(0, 0, -30), today, nil
+	case "this_month":
+		return month, today.AddDate(0, 0, 1), nil
+	case "previous_month":
+		return month.AddDate(0, -1, 0), month, nil
+	case "next_7_days":
+		return today, today.AddDate(0, 0, 8), nil
+	case "next_30_days":
+		return today, today.AddDate(0, 0, 31), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown date range %q", name)
+}
+
+// inRange limits column to the params' period
+func inRange(q *gorm.DB, column string, p Params) *gorm.DB {
+	if !p.From.IsZero() {
+		q = q.Where(column+" >= ?", p.From)
+	}
+	if !p.To.IsZero() {
+		q = q.Where(column+" < ?", p.To)
+	}
+	return q
+}
+
+// scopeSubscribers applies the reseller, service and NAS filters to a query joined to subscribers as s
+func scopeSubscribers(q *gorm.DB, p Params) *gorm.DB {
+	if p.ResellerID > 0 {
+		q = q.Where("s.reseller_id = ?", p.ResellerID)
+	}
+	if p.ServiceID > 0 {
+		q = q.Where("s.service_id = ?", p.ServiceID)
+	}
+	if p.NasID > 0 {
+		q = q.Where("s.nas_id = ?", p.NasID)
+	}
+	return q
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// formatBytes renders octets in GB with two decimals, the unit used on the reports pages
+func formatBytes(v int64) string {
+	return strconv.FormatFloat(float64(v)/(1024*1024*1024), 'f', 2, 64)
+}
+
+func subscriberStatus(status int) string {
+	switch status {
+	case 1:
+		return "active"
+	case 2:
+		return "inactive"
+	case 3:
+		return "expired"
+	case 4:
+		return "stopped"
+	}
+	return strconv.Itoa(status)
+}
+
+func buildSubscribers(p Params) (*Table, error) {
+	type row struct {
+		ID         uint
+		Username   string
+		FullName   string
+		Phone      string
+		Service    string
+		Reseller   string
+		Status     int
+		ExpiryDate *time.Time
+		IsOnline   bool
+		CreatedAt  *time.Time
+	}
+	var rows []row
+	q := database.DB.Table("subscribers s").
+		Select("s.id, s.username, s.full_name, s.phone, sv.name AS service, r.name AS reseller, s.status, s.expiry_date, s.is_online, s.created_at").
+		Joins("LEFT JOIN services sv ON sv.id = s.service_id").
+		Joins("LEFT JOIN resellers r ON r.id = s.reseller_id").
+		Where("s.deleted_at IS NULL")
+	q = inRange(scopeSubscribers(q, p), "s.created_at", p)
+	if err := q.Order("s.username").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"ID", "Username", "Full name", "Phone", "Service", "Reseller", "Status", "Expiry", "Online", "Created"}}
+	for _, r := range rows {
+		online := "no"
+		if r.IsOnline {
+			online = "yes"
+		}
+		t.Rows = append(t.Rows, []string{strconv.FormatUint(uint64(r.ID), 10), r.Username, r.FullName, r.Phone, r.Service, r.Reseller,
+			subscriberStatus(r.Status), formatTime(r.ExpiryDate), online, formatTime(r.CreatedAt)})
+	}
+	return t, nil
+}
+
+func buildRevenue(p Params) (*Table, error) {
+	type row struct {
+		Day    time.Time
+		Method string
+		Count  int64
+		Amount float64
+	}
+	var rows []row
+	q := database.DB.Table("payments pm").
+		Select("DATE(pm.created_at) AS day, COALESCE(pm.method, '') AS method, COUNT(*) AS count, COALESCE(SUM(pm.amount), 0) AS amount").
+		Joins("LEFT JOIN subscribers s ON s.id = pm.subscriber_id").
+		Where("pm.status = ?", "completed")
+	if p.ResellerID > 0 {
+		q = q.Where("pm.reseller_id = ?", p.ResellerID)
+	}
+	q = inRange(q, "pm.created_at", p)
+	if p.ServiceID > 0 {
+		q = q.Where("s.service_id = ?", p.ServiceID)
+	}
+	if p.NasID > 0 {
+		q = q.Where("s.nas_id = ?", p.NasID)
+	}
+	if err := q.Group("DATE(pm.created_at), pm.method").Order("day, method").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"Date", "Method", "Payments", "Amount"}}
+	var count int64
+	var total float64
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{r.Day.Format("2006-01-02"), r.Method, strconv.FormatInt(r.Count, 10), formatMoney(r.Amount)})
+		count += r.Count
+		total += r.Amount
+	}
+	t.Rows = append(t.Rows, []string{"Total", "", strconv.FormatInt(count, 10), formatMoney(total)})
+	return t, nil
+}
+
+func buildServices(p Params) (*Table, error) {
+	type row struct {
+		Name        string
+		Subscribers int64
+		Active      int64
+		Revenue     float64
+	}
+	var rows []row
+	subscribers := scopeSubscribers(database.DB.Table("subscribers s").Where("s.deleted_at IS NULL"), p)
+	payments := inRange(database.DB.Table("payments pm").Where("pm.status = ?", "completed"), "pm.created_at", p)
+
+	q := database.DB.Table("services sv").
+		Select(`sv.name,
+			COALESCE(sub.total, 0) AS subscribers, COALESCE(sub.active, 0) AS active, COALESCE(rev.amount, 0) AS revenue`).
+		Joins("LEFT JOIN (?) sub ON sub.service_id = sv.id",
+			subscribers.Select("s.service_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE s.status = 1) AS active").Group("s.service_id")).
+		Joins("LEFT JOIN (?) rev ON rev.service_id = sv.id",
+			scopeSubscribers(payments.Joins("JOIN subscribers s ON s.id = pm.subscriber_id"), p).
+				Select("s.service_id, SUM(pm.amount) AS amount").Group("s.service_id")).
+		Where("sv.deleted_at IS NULL")
+	if p.ServiceID > 0 {
+		q = q.Where("sv.id = ?", p.ServiceID)
+	}
+	if err := q.Order("sv.name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"Service", "Subscribers", "Active", "Revenue"}}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{r.Name, strconv.FormatInt(r.Subscribers, 10), strconv.FormatInt(r.Active, 10), formatMoney(r.Revenue)})
+	}
+	return t, nil
+}
+
+func buildResellers(p Params) (*Table, error) {
+	type row struct {
+		Name        string
+		Balance     float64
+		Subscribers int64
+		Active      int64
+		Revenue     float64
+	}
+	var rows []row
+	subscribers := scopeSubscribers(database.DB.Table("subscribers s").Where("s.deleted_at IS NULL"), Params{ServiceID: p.ServiceID, NasID: p.NasID})
+	payments := inRange(database.DB.Table("payments pm").Where("pm.status = ?", "completed"), "pm.created_at", p)
+
+	q := database.DB.Table("resellers r").
+		Select(`r.name, r.balance,
+			COALESCE(sub.total, 0) AS subscribers, COALESCE(sub.active, 0) AS active, COALESCE(rev.amount, 0) AS revenue`).
+		Joins("LEFT JOIN (?) sub ON sub.reseller_id = r.id",
+			subscribers.Select("s.reseller_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE s.status = 1) AS active").Group("s.reseller_id")).
+		Joins("LEFT JOIN (?) rev ON rev.reseller_id = r.id",
+			payments.Select("pm.reseller_id, SUM(pm.amount) AS amount").Group("pm.reseller_id")).
+		Where("r.deleted_at IS NULL")
+	if p.ResellerID > 0 {
+		q = q.Where("r.id = ? OR r.parent_id = ?", p.ResellerID, p.ResellerID)
+	}
+	if err := q.Order("r.name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"Reseller", "Balance", "Subscribers", "Active", "Revenue"}}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{r.Name, formatMoney(r.Balance), strconv.FormatInt(r.Subscribers, 10),
+			strconv.FormatInt(r.Active, 10), formatMoney(r.Revenue)})
+	}
+	return t, nil
+}
+
+func buildUsage(p Params) (*Table, error) {
+	type row struct {
+		Username    string
+		FullName    string
+		Upload      int64
+		Download    int64
+		SessionTime int64
+		Sessions    int64
+	}
+	var rows []row
+	q := database.DB.Table("subscriber_usage_daily u").
+		Select(`u.username, MAX(s.full_name) AS full_name, SUM(u.input_octets) AS upload, SUM(u.output_octets) AS download,
+			SUM(u.session_time) AS session_time, SUM(u.sessions) AS sessions`).
+		Joins("LEFT JOIN subscribers s ON s.username = u.username AND s.deleted_at IS NULL")
+	q = scopeSubscribers(q, p)
+	if !p.From.IsZero() {
+		q = q.Where("u.day >= ?", p.From.Format("2006-01-02"))
+	}
+	if !p.To.IsZero() {
+		q = q.Where("u.day < ?", p.To.Format("2006-01-02"))
+	}
+	if err := q.Group("u.username").Order("download DESC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"Username", "Full name", "Upload (GB)", "Download (GB)", "Online (hours)", "Sessions"}}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{r.Username, r.FullName, formatBytes(r.Upload), formatBytes(r.Download),
+			strconv.FormatFloat(float64(r.SessionTime)/3600, 'f', 1, 64), strconv.FormatInt(r.Sessions, 10)})
+	}
+	return t, nil
+}
+
+func buildExpiry(p Params) (*Table, error) {
+	type row struct {
+		Username   string
+		FullName   string
+		Phone      string
+		Service    string
+		Reseller   string
+		Price      float64
+		ExpiryDate *time.Time
+	}
+	var rows []row
+	q := database.DB.Table("subscribers s").
+		Select("s.username, s.full_name, s.phone, sv.name AS service, r.name AS reseller, s.price, s.expiry_date").
+		Joins("LEFT JOIN services sv ON sv.id = s.service_id").
+		Joins("LEFT JOIN resellers r ON r.id = s.reseller_id").
+		Where("s.deleted_at IS NULL")
+	q = inRange(scopeSubscribers(q, p), "s.expiry_date", p)
+	if err := q.Order("s.expiry_date").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"Username", "Full name", "Phone", "Service", "Reseller", "Price", "Expiry"}}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{r.Username, r.FullName, r.Phone, r.Service, r.Reseller, formatMoney(r.Price), formatTime(r.ExpiryDate)})
+	}
+	return t, nil
+}
+
+func buildTransactions(p Params) (*Table, error) {
+	type row struct {
+		CreatedAt   *time.Time
+		Type        string
+		Amount      float64
+		Username    string
+		Reseller    string
+		ServiceName string
+		Description string
+	}
+	var rows []row
+	q := database.DB.Table("transactions t").
+		Select("t.created_at, t.type, t.amount, s.username, r.name AS reseller, t.service_name, t.description").
+		Joins("LEFT JOIN subscribers s ON s.id = t.subscriber_id").
+		Joins("LEFT JOIN resellers r ON r.id = t.reseller_id")
+	if p.ResellerID > 0 {
+		q = q.Where("t.reseller_id = ?", p.ResellerID)
+	}
+	if p.ServiceID > 0 {
+		q = q.Where("s.service_id = ?", p.ServiceID)
+	}
+	if p.NasID > 0 {
+		q = q.Where("s.nas_id = ?", p.NasID)
+	}
+	q = inRange(q, "t.created_at", p)
+	if err := q.Order("t.created_at").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"Date", "Type", "Amount", "Subscriber", "Reseller", "Service", "Description"}}
+	var total float64
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{formatTime(r.CreatedAt), r.Type, formatMoney(r.Amount), r.Username, r.Reseller, r.ServiceName, r.Description})
+		total += r.Amount
+	}
+	t.Rows = append(t.Rows, []string{"Total", "", formatMoney(total), "", "", "", ""})
+	return t, nil
+}
+
+func buildNAS(p Params) (*Table, error) {
+	type row struct {
+		Name      string
+		IPAddress string
+		Online    int64
+		Sessions  int64
+		Upload    int64
+		Download  int64
+	}
+	var rows []row
+	usage := database.DB.Table("nas_usage_daily u").
+		Select("u.nasipaddress, SUM(u.sessions) AS sessions, SUM(u.input_octets) AS upload, SUM(u.output_octets) AS download").
+		Group("u.nasipaddress")
+	if !p.From.IsZero() {
+		usage = usage.Where("u.day >= ?", p.From.Format("2006-01-02"))
+	}
+	if !p.To.IsZero() {
+		usage = usage.Where("u.day < ?", p.To.Format("2006-01-02"))
+	}
+
+	q := database.DB.Table("nas_devices n").
+		Select(`n.name, n.ip_address,
+			(SELECT COUNT(*) FROM subscribers s WHERE s.nas_id = n.id AND s.is_online AND s.deleted_at IS NULL) AS online,
+			COALESCE(u.sessions, 0) AS sessions, COALESCE(u.upload, 0) AS upload, COALESCE(u.download, 0) AS download`).
+		Joins("LEFT JOIN (?) u ON u.nasipaddress = n.ip_address", usage).
+		Where("n.deleted_at IS NULL")
+	if p.NasID > 0 {
+		q = q.Where("n.id = ?", p.NasID)
+	}
+	if err := q.Order("n.name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: []string{"NAS", "IP address", "Online now", "Sessions", "Upload (GB)", "Download (GB)"}}
+	for _, r := range rows {
+		t.Rows = append(t.Rows, []string{r.Name, r.IPAddress, strconv.FormatInt(r.Online, 10), strconv.FormatInt(r.Sessions, 10),
+			formatBytes(r.Upload), formatBytes(r.Download)})
+	}
+	return t, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/analytics"
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+)
+
+// AnalyticsService precomputes the subscriber analytics once a night, after
+// analytics_run_hour: it snapshots every subscriber's state for the current month and
+// recomputes churn, ARPU, renewal rate and cohorts for the last analytics_months months.
+type AnalyticsService struct {
+	checkInterval time.Duration
+	lastRun       string // Date of the last successful run
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+	running       sync.Mutex // Held while a computation runs
+}
+
+// NewAnalyticsService creates a new analytics service
+func NewAnalyticsService(interval time.Duration) *AnalyticsService {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	return &AnalyticsService{
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the analytics service
+func (s *AnalyticsService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("AnalyticsService started (interval: %v)", s.checkInterval)
+}
+
+// Stop stops the analytics service
+func (s *AnalyticsService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("AnalyticsService stopped")
+}
+
+func (s *AnalyticsService) run() {
+	defer s.wg.Done()
+
+	// Compute right away on a fresh install so the dashboard is not empty until tomorrow
+	if database.DB != nil {
+		var count int64
+		database.DB.Model(&models.AnalyticsMonthly{}).Limit(1).Count(&count)
+		if count == 0 {
+			s.RunNow()
+		}
+	}
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if now.Hour() >= getIntPreference("analytics_run_hour", 2) && s.lastRun != now.Format("2006-01-02") {
+				s.RunNow()
+			}
+		}
+	}
+}
+
+// RunNow snapshots and recomputes the analytics. A run that is already in progress is
+// not repeated.
+func (s *AnalyticsService) RunNow() error {
+	if database.DB == nil {
+		return nil
+	}
+	if !s.running.TryLock() {
+		return nil
+	}
+	defer s.running.Unlock()
+
+	started := time.Now()
+	if err := analytics.Run(getIntPreference("analytics_months", 13), started); err != nil {
+		log.Printf("AnalyticsService: Failed to compute analytics: %v", err)
+		return err
+	}
+	s.lastRun = started.Format("2006-01-02")
+	log.Printf("AnalyticsService: Computed analytics in %v", time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// Analytics is the running analytics service, used by the API to trigger a recompute
+var Analytics *AnalyticsService
@@ -1100,6 +1100,11 @@ func (s *BackupSchedulerService) ExportMikroTikConfigs(timestamp string, nasIDs
 
 		exportedFiles = append(exportedFiles, rscFile)
 		log.Printf("BackupScheduler: Exported config from NAS %s (%s), %d bytes", nas.Name, nas.IPAddress, len(configText))
+
+		// Keep a version history for diffs and restore
+		if _, _, err := RecordNasConfigVersion(&nas, configText, "backup", ""); err != nil {
+			log.Printf("BackupScheduler: Failed to record config version for NAS %s: %v", nas.Name, err)
+		}
 	}
 
 	if len(exportedFiles) == 0 {
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/commission"
+	"github.com/proisp/backend/internal/database"
+)
+
+// CommissionSettlementService closes each month's commission ledger into settlement
+// statements once the month is over. Entries are posted by the sale handlers as they
+// happen (see commission.Post); this only rolls them up.
+type CommissionSettlementService struct {
+	checkInterval time.Duration
+	lastPeriod    string // Last month that was settled without error
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+}
+
+// NewCommissionSettlementService creates a new commission settlement service
+func NewCommissionSettlementService(interval time.Duration) *CommissionSettlementService {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &CommissionSettlementService{
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the commission settlement service
+func (s *CommissionSettlementService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("CommissionSettlementService started (interval: %v)", s.checkInterval)
+}
+
+// Stop stops the commission settlement service
+func (s *CommissionSettlementService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("CommissionSettlementService stopped")
+}
+
+func (s *CommissionSettlementService) run() {
+	defer s.wg.Done()
+
+	s.settle()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.settle()
+		}
+	}
+}
+
+// settle generates the statements of the previous month
+func (s *CommissionSettlementService) settle() {
+	if database.DB == nil {
+		return
+	}
+
+	now := time.Now()
+	period := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0).Format("2006-01")
+	if period == s.lastPeriod {
+		return
+	}
+
+	count, err := commission.GenerateSettlements(period)
+	if err != nil {
+		log.Printf("CommissionSettlementService: Failed to settle %s: %v", period, err)
+		return
+	}
+	if count > 0 {
+		log.Printf("CommissionSettlementService: Generated %d commission statements for %s", count, period)
+	}
+	s.lastPeriod = period
+}
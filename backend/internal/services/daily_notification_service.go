@@ -145,11 +145,10 @@ func (s *DailyNotificationService) fireExpiryWarningRules(now time.Time) {
 		log.Printf("DailyNotif[%s]: Sending expiry_warning to %d subscribers (expires in %d days)", rule.Name, len(subscribers), daysAhead)
 
 		for _, sub := range subscribers {
-			if dailyNotifAlreadySent(sub.ID, rule.ID, now) {
-				log.Printf("DailyNotif[%s]: Skipping %s — already sent today", rule.Name, sub.Username)
+			if !RuleMatches(rule, &sub) {
 				continue
 			}
-			dailySendNotification(rule, sub, daysAhead)
+			dailySendNotification(rule, sub, daysAhead, now)
 		}
 	}
 }
@@ -190,101 +189,59 @@ func (s *DailyNotificationService) fireExpiredRules(now time.Time) {
 		log.Printf("DailyNotif[%s]: Sending 'expired' to %d subscribers", rule.Name, len(subscribers))
 
 		for _, sub := range subscribers {
-			if dailyNotifAlreadySent(sub.ID, rule.ID, now) {
-				log.Printf("DailyNotif[%s]: Skipping %s — already sent today", rule.Name, sub.Username)
+			if !RuleMatches(rule, &sub) {
 				continue
 			}
-			dailySendNotification(rule, sub, 0)
+			dailySendNotification(rule, sub, 0, now)
 		}
 	}
 }
 
-// dailyNotifAlreadySent returns true if we already sent a notification for this subscriber+rule today
-func dailyNotifAlreadySent(subscriberID, ruleID uint, now time.Time) bool {
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	var count int64
-	database.DB.Model(&models.CommunicationLog{}).
-		Where("subscriber_id = ? AND rule_id = ? AND created_at >= ?", subscriberID, ruleID, startOfDay).
-		Count(&count)
-	return count > 0
-}
-
-// dailySendNotification sends the notification and logs it
-func dailySendNotification(rule models.CommunicationRule, sub models.Subscriber, daysRemaining int) {
-	expiryDate := ""
-	if !sub.ExpiryDate.IsZero() {
-		expiryDate = sub.ExpiryDate.Format("2006-01-02")
-	}
-	serviceName := ""
-	if sub.Service != nil {
-		serviceName = sub.Service.Name
+// dailySendNotification queues the notification. The dedup key holds the day so a
+// subscriber gets each rule's message at most once a day.
+func dailySendNotification(rule models.CommunicationRule, sub models.Subscriber, daysRemaining int, now time.Time) {
+	ruleSubject, msg, err := RenderRule(rule, &sub, map[string]interface{}{"days_before": daysRemaining})
+	if err != nil {
+		log.Printf("DailyNotif[%s]: %v", rule.Name, err)
+		return
 	}
-	balance := fmt.Sprintf("%.2f", sub.Price)
-
-	msg := rule.Template
-	msg = strings.ReplaceAll(msg, "{username}", sub.Username)
-	msg = strings.ReplaceAll(msg, "{full_name}", sub.FullName)
-	msg = strings.ReplaceAll(msg, "{expiry_date}", expiryDate)
-	msg = strings.ReplaceAll(msg, "{service_name}", serviceName)
-	msg = strings.ReplaceAll(msg, "{balance}", balance)
-	msg = strings.ReplaceAll(msg, "{days_before}", fmt.Sprintf("%d", daysRemaining))
 
-	sent := false
-	errMsg := ""
+	outbound := &models.OutboundMessage{
+		Channel:      rule.Channel,
+		Body:         msg,
+		Source:       "rule",
+		DedupKey:     DedupKey("rule", rule.ID, "sub", sub.ID, now.Format("2006-01-02")),
+		SubscriberID: &sub.ID,
+		ResellerID:   &sub.ResellerID,
+		RuleID:       &rule.ID,
+	}
 
 	switch rule.Channel {
-	case "whatsapp":
-		phone := getNotifPhone(rule, sub)
-		if phone != "" {
-			wa := NewWhatsAppService()
-			if err := wa.SendMessageForSubscriber(sub, phone, msg); err != nil {
-				log.Printf("DailyNotif[%s]: WhatsApp failed for %s: %v", rule.Name, sub.Username, err)
-				errMsg = err.Error()
-			} else {
-				log.Printf("DailyNotif[%s]: WhatsApp sent to %s", rule.Name, sub.Username)
-				sent = true
-			}
-		} else {
-			log.Printf("DailyNotif[%s]: No phone for %s, skipping", rule.Name, sub.Username)
-		}
-
-	case "sms":
-		phone := getNotifPhone(rule, sub)
-		if phone != "" {
-			sms := NewSMSService()
-			if err := sms.SendSMS(phone, msg); err != nil {
-				log.Printf("DailyNotif[%s]: SMS failed for %s: %v", rule.Name, sub.Username, err)
-				errMsg = err.Error()
-			} else {
-				log.Printf("DailyNotif[%s]: SMS sent to %s", rule.Name, sub.Username)
-				sent = true
-			}
-		} else {
-			log.Printf("DailyNotif[%s]: No phone for %s, skipping", rule.Name, sub.Username)
-		}
-
+	case "whatsapp", "sms":
+		outbound.Recipient = getNotifPhone(rule, sub)
 	case "email":
-		email := getNotifEmail(rule, sub)
-		if email != "" {
-			emailSvc := NewEmailService()
-			subject := fmt.Sprintf("Expiry Reminder - %s", sub.Username)
-			if rule.TriggerEvent == "expired" {
-				subject = fmt.Sprintf("Account Expired - %s", sub.Username)
-			}
-			if err := emailSvc.SendEmail(email, subject, msg, false); err != nil {
-				log.Printf("DailyNotif[%s]: Email failed for %s: %v", rule.Name, sub.Username, err)
-				errMsg = err.Error()
-			} else {
-				log.Printf("DailyNotif[%s]: Email sent to %s", rule.Name, sub.Username)
-				sent = true
-			}
-		} else {
-			log.Printf("DailyNotif[%s]: No email for %s, skipping", rule.Name, sub.Username)
+		outbound.Recipient = getNotifEmail(rule, sub)
+		outbound.Subject = fmt.Sprintf("Expiry Reminder - %s", sub.Username)
+		if rule.TriggerEvent == "expired" {
+			outbound.Subject = fmt.Sprintf("Account Expired - %s", sub.Username)
+		}
+		if ruleSubject != "" {
+			outbound.Subject = ruleSubject
 		}
+	default:
+		return
+	}
+	if outbound.Recipient == "" {
+		log.Printf("DailyNotif[%s]: No %s recipient for %s, skipping", rule.Name, rule.Channel, sub.Username)
+		return
 	}
 
-	// Log the attempt (sent or failed) for dedup tracking
-	logDailyNotif(rule, sub, msg, sent, errMsg)
+	queued, err := QueueMessage(outbound)
+	if err != nil {
+		log.Printf("DailyNotif[%s]: Failed to queue %s for %s: %v", rule.Name, rule.Channel, sub.Username, err)
+	} else if !queued {
+		log.Printf("DailyNotif[%s]: Skipping %s — already sent today", rule.Name, sub.Username)
+	}
 }
 
 // getNotifPhone returns the phone to notify (subscriber or reseller depending on rule)
@@ -302,34 +259,3 @@ func getNotifEmail(rule models.CommunicationRule, sub models.Subscriber) string
 	}
 	return sub.Email
 }
-
-// logDailyNotif records a CommunicationLog entry for dedup and audit
-func logDailyNotif(rule models.CommunicationRule, sub models.Subscriber, message string, sent bool, errMsg string) {
-	now := time.Now()
-	subID := sub.ID
-	ruleID := rule.ID
-
-	status := "sent"
-	if !sent {
-		status = "failed"
-	}
-
-	recipient := sub.Phone
-	if rule.Channel == "email" {
-		recipient = sub.Email
-	}
-
-	commLog := models.CommunicationLog{
-		Type:         rule.Channel,
-		Recipient:    recipient,
-		Subject:      fmt.Sprintf("%s - %s", rule.TriggerEvent, sub.Username),
-		Message:      message,
-		Status:       status,
-		ErrorMessage: errMsg,
-		SubscriberID: &subID,
-		RuleID:       &ruleID,
-		CreatedAt:    now,
-		SentAt:       &now,
-	}
-	database.DB.Create(&commLog)
-}
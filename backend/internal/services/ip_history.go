@@ -0,0 +1,257 @@
+package services
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// IPHistoryService copies every address handed out in radacct into ip_assignments, the
+// immutable history used to answer "who held this IP at that moment". radacct rows are
+// dropped with their partition after 90 days and subscriber rows only keep the latest
+// IP and MAC, so the history is kept separately for the legally required period
+// (ip_history_retention_days).
+type IPHistoryService struct {
+	checkInterval time.Duration
+	since         *time.Time // Sessions that started before this were copied already; nil = all rows
+	lastPurge     time.Time
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+}
+
+// NewIPHistoryService creates a new IP history service
+func NewIPHistoryService(interval time.Duration) *IPHistoryService {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &IPHistoryService{
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the IP history service
+func (s *IPHistoryService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	// Resume a day before the newest copied session; open sessions are always examined
+	var latest *time.Time
+	if database.DB != nil {
+		database.DB.Raw("SELECT MAX(started_at) FROM ip_assignments WHERE source = 'accounting'").Scan(&latest)
+	}
+	if latest != nil {
+		since := latest.Add(-24 * time.Hour)
+		s.since = &since
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("IPHistoryService started (interval: %v)", s.checkInterval)
+}
+
+// Stop stops the IP history service
+func (s *IPHistoryService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("IPHistoryService stopped")
+}
+
+func (s *IPHistoryService) run() {
+	defer s.wg.Done()
+
+	if s.since == nil && database.DB != nil {
+		if err := backfillIPHistoryArchive(); err != nil {
+			log.Printf("IPHistoryService: Failed to backfill radacct_archive: %v", err)
+		}
+	}
+	s.sync()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.sync()
+		}
+	}
+}
+
+func (s *IPHistoryService) sync() {
+	if database.DB == nil {
+		return
+	}
+
+	started := time.Now()
+	added, closed, err := SyncIPHistory(s.since)
+	if err != nil {
+		log.Printf("IPHistoryService: Sync failed: %v", err)
+		return
+	}
+	if added > 0 || closed > 0 {
+		log.Printf("IPHistoryService: %d assignments added, %d closed", added, closed)
+	}
+	since := started.Add(-10 * time.Minute) // margin for clock skew between NAS and server
+	s.since = &since
+
+	if time.Since(s.lastPurge) >= 24*time.Hour {
+		s.lastPurge = time.Now()
+		s.purge()
+	}
+}
+
+// purge deletes closed assignments older than ip_history_retention_days; 0 keeps them forever
+func (s *IPHistoryService) purge() {
+	days := 730
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", "ip_history_retention_days").First(&pref).Error; err == nil {
+		if v, err := strconv.Atoi(pref.Value); err == nil && v >= 0 {
+			days = v
+		}
+	}
+	if days == 0 {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var deleted int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// The immutability trigger only lets deletes through with this setting on
+		if err := tx.Exec("SET LOCAL proisp.ip_history_purge = 'on'").Error; err != nil {
+			return err
+		}
+		result := tx.Exec("DELETE FROM ip_assignments WHERE stopped_at IS NOT NULL AND stopped_at < ?", cutoff)
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		log.Printf("IPHistoryService: Retention purge failed: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("IPHistoryService: Purged %d assignments older than %d days", deleted, days)
+	}
+}
+
+// SyncIPHistory records new radacct sessions with an address, with their CGNAT public
+// address and port block if the address belongs to a CGNAT pool, and closes the ones that
+// have stopped. Only sessions still open or started after since are examined for new
+// rows; nil examines every row.
+func SyncIPHistory(since *time.Time) (added, closed int64, err error) {
+	window := "TRUE"
+	var args []interface{}
+	if since != nil {
+		window = "(r.acctstarttime >= ? OR r.acctstoptime IS NULL OR r.acctstoptime >= ?)"
+		args = append(args, *since, *since)
+	}
+
+	result := database.DB.Exec(`
+		INSERT INTO ip_assignments (ip_address, username, subscriber_id, nas_id, nas_ip_address,
+			mac_address, acct_session_id, acct_unique_id, started_at, stopped_at, terminate_cause, source,
+			cgnat_pool_id, public_ip, public_port_start, public_port_end)
+		SELECT r.framedipaddress, r.username,
+			(SELECT s.id FROM subscribers s WHERE s.username = r.username ORDER BY s.deleted_at NULLS FIRST, s.id DESC LIMIT 1),
+			(SELECT n.id FROM nas_devices n WHERE n.ip_address = r.nasipaddress AND n.deleted_at IS NULL LIMIT 1),
+			r.nasipaddress, r.callingstationid, r.acctsessionid, r.acctuniqueid,
+			COALESCE(r.acctstarttime, r.acctupdatetime, r.acctstoptime), r.acctstoptime,
+			NULLIF(r.acctterminatecause, ''), 'accounting',
+			b.cgnat_pool_id, b.public_ip, b.port_start, b.port_end
+		FROM radacct r
+		-- The CGNAT translation in force when the session is first seen
+		LEFT JOIN (cgnat_port_blocks b JOIN cgnat_pools p ON p.id = b.cgnat_pool_id AND p.is_active)
+			ON b.private_ip = r.framedipaddress
+		WHERE `+window+`
+		AND r.framedipaddress NOT IN ('', '0.0.0.0')
+		AND r.acctuniqueid <> ''
+		AND COALESCE(r.acctstarttime, r.acctupdatetime, r.acctstoptime) IS NOT NULL
+		ON CONFLICT (acct_unique_id) DO NOTHING
+	`, args...)
+	if result.Error != nil {
+		return 0, 0, result.Error
+	}
+	added = result.RowsAffected
+
+	result = database.DB.Exec(`
+		UPDATE ip_assignments a SET stopped_at = r.acctstoptime, terminate_cause = NULLIF(r.acctterminatecause, '')
+		FROM radacct r
+		WHERE a.stopped_at IS NULL AND a.source = 'accounting'
+		AND r.acctuniqueid = a.acct_unique_id AND r.acctstoptime IS NOT NULL
+	`)
+	if result.Error != nil {
+		return added, 0, result.Error
+	}
+	closed = result.RowsAffected
+
+	// Sessions whose accounting row was removed without a stop would stay open forever
+	result = database.DB.Exec(`
+		UPDATE ip_assignments a SET stopped_at = NOW(), terminate_cause = 'Accounting-Removed'
+		WHERE a.stopped_at IS NULL AND a.source = 'accounting'
+		AND NOT EXISTS (SELECT 1 FROM radacct r WHERE r.acctuniqueid = a.acct_unique_id)
+	`)
+	if result.Error != nil {
+		return added, closed, result.Error
+	}
+	return added, closed + result.RowsAffected, nil
+}
+
+// backfillIPHistoryArchive copies the sessions of the legacy radacct_archive table.
+// It runs once, on the first start of the service.
+func backfillIPHistoryArchive() error {
+	var exists bool
+	database.DB.Raw("SELECT to_regclass('radacct_archive') IS NOT NULL").Scan(&exists)
+	if !exists {
+		return nil
+	}
+	var done bool
+	database.DB.Raw("SELECT EXISTS (SELECT 1 FROM ip_assignments WHERE source = 'archive')").Scan(&done)
+	if done {
+		return nil
+	}
+
+	result := database.DB.Exec(`
+		INSERT INTO ip_assignments (ip_address, username, subscriber_id, nas_id, nas_ip_address,
+			mac_address, acct_session_id, acct_unique_id, started_at, stopped_at, terminate_cause, source)
+		SELECT r.framedipaddress, COALESCE(r.username, ''),
+			(SELECT s.id FROM subscribers s WHERE s.username = r.username ORDER BY s.deleted_at NULLS FIRST, s.id DESC LIMIT 1),
+			(SELECT n.id FROM nas_devices n WHERE n.ip_address = r.nasipaddress AND n.deleted_at IS NULL LIMIT 1),
+			r.nasipaddress, r.callingstationid, r.acctsessionid, NULLIF(r.acctuniqueid, ''),
+			COALESCE(r.acctstarttime, r.acctupdatetime, r.acctstoptime),
+			COALESCE(r.acctstoptime, r.acctupdatetime, r.acctstarttime),
+			NULLIF(r.acctterminatecause, ''), 'archive'
+		FROM radacct_archive r
+		WHERE COALESCE(r.framedipaddress, '') NOT IN ('', '0.0.0.0')
+		AND COALESCE(r.acctstarttime, r.acctupdatetime, r.acctstoptime) IS NOT NULL
+		ON CONFLICT (acct_unique_id) DO NOTHING
+	`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("IPHistoryService: Backfilled %d assignments from radacct_archive", result.RowsAffected)
+	}
+	return nil
+}
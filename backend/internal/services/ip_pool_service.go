@@ -3,6 +3,8 @@ package services
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/ippool"
@@ -142,6 +144,87 @@ func (s *IPPoolService) GetAllPoolStats() ([]map[string]interface{}, error) {
 	return ippool.Manager.GetAllPoolStats()
 }
 
+// SyncCGNATPool pushes the pool's src-nat rules to its NAS and records the outcome on the pool
+func (s *IPPoolService) SyncCGNATPool(pool *models.CGNATPool) error {
+	err := s.syncCGNATPool(pool)
+
+	updates := map[string]interface{}{"sync_error": ""}
+	if err != nil {
+		updates["sync_error"] = err.Error()
+		log.Printf("IPPoolService: CGNAT pool %s sync failed: %v", pool.Name, err)
+	} else {
+		updates["synced_at"] = time.Now()
+	}
+	database.DB.Model(&models.CGNATPool{}).Where("id = ?", pool.ID).Updates(updates)
+	return err
+}
+
+func (s *IPPoolService) syncCGNATPool(pool *models.CGNATPool) error {
+	if pool.NasID == nil {
+		return fmt.Errorf("no NAS selected")
+	}
+	var nas models.Nas
+	if err := database.DB.First(&nas, *pool.NasID).Error; err != nil {
+		return fmt.Errorf("NAS not found")
+	}
+	if nas.APIUsername == "" || nas.APIPassword == "" {
+		return fmt.Errorf("NAS %s has no API credentials configured", nas.Name)
+	}
+
+	client := mikrotik.NewClient(nas.IPAddress, nas.APIUsername, nas.APIPassword)
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to MikroTik: %v", err)
+	}
+	defer client.Close()
+
+	// An inactive pool keeps its mapping but has no rules on the router
+	if !pool.IsActive {
+		_, err := client.RemoveCGNATRules(pool.Name)
+		return err
+	}
+
+	var blocks []models.CGNATPortBlock
+	database.DB.Where("cgnat_pool_id = ?", pool.ID).Order("id").Find(&blocks)
+
+	set := mikrotik.CGNATRuleSet{Name: pool.Name}
+	for _, r := range strings.Split(pool.PrivateRanges, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			set.PrivateRanges = append(set.PrivateRanges, r)
+		}
+	}
+	for _, b := range blocks {
+		set.Mappings = append(set.Mappings, mikrotik.CGNATMapping{
+			PrivateIP: b.PrivateIP,
+			PublicIP:  b.PublicIP,
+			PortStart: b.PortStart,
+			PortEnd:   b.PortEnd,
+		})
+	}
+
+	_, _, err := client.SyncCGNATRules(set)
+	return err
+}
+
+// RemoveCGNATPoolRules removes the pool's src-nat rules from its NAS
+func (s *IPPoolService) RemoveCGNATPoolRules(pool *models.CGNATPool) error {
+	if pool.NasID == nil {
+		return nil
+	}
+	var nas models.Nas
+	if err := database.DB.First(&nas, *pool.NasID).Error; err != nil {
+		return nil // NAS is gone, and its rules with it
+	}
+
+	client := mikrotik.NewClient(nas.IPAddress, nas.APIUsername, nas.APIPassword)
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to MikroTik: %v", err)
+	}
+	defer client.Close()
+
+	_, err := client.RemoveCGNATRules(pool.Name)
+	return err
+}
+
 // Global instance
 var IPPool = NewIPPoolService()
 
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+)
+
+// Message templates are Go text/template documents rendered against a flat map of
+// variables, e.g. "Hi {{.full_name}}{{if gt .days_remaining 0}}, {{.days_remaining}}
+// days left{{end}}". The older placeholder forms {username} and {{username}} are still
+// accepted and rewritten to {{.username}} before parsing.
+
+// templateFuncs are the formatting helpers available in message templates
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"title": strings.Title,
+	"trim":  strings.TrimSpace,
+	"default": func(def, value interface{}) interface{} {
+		if value == nil || fmt.Sprint(value) == "" || fmt.Sprint(value) == "0" {
+			return def
+		}
+		return value
+	},
+	"date": func(layout string, value interface{}) string {
+		if t, ok := templateTime(value); ok {
+			return t.Format(layout)
+		}
+		return ""
+	},
+	"money": func(value interface{}) string {
+		return fmt.Sprintf("%.2f", templateFloat(value))
+	},
+	"bytes": func(value interface{}) string {
+		return formatFUPQuota(int64(templateFloat(value)))
+	},
+	"daysUntil": func(value interface{}) int {
+		t, ok := templateTime(value)
+		if !ok || time.Now().After(t) {
+			return 0
+		}
+		return int(time.Until(t).Hours() / 24)
+	},
+	"plural": func(n interface{}, singular, plural string) string {
+		if templateFloat(n) == 1 {
+			return singular
+		}
+		return plural
+	},
+}
+
+// TemplateHelpers lists the helper functions for the rule editor
+var TemplateHelpers = []string{
+	"upper", "lower", "title", "trim", "default <fallback> <value>", "date <layout> <time>",
+	"money <number>", "bytes <number>", "daysUntil <time>", "plural <n> <singular> <plural>",
+}
+
+var (
+	legacyDoubleVar = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	legacySingleVar = regexp.MustCompile(`(^|[^{])\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	templateWords   = map[string]bool{
+		"if": true, "else": true, "end": true, "range": true, "with": true, "define": true,
+		"template": true, "block": true, "break": true, "continue": true, "nil": true,
+	}
+)
+
+// upgradeLegacyTemplate rewrites {name} and {{name}} placeholders to {{.name}}
+func upgradeLegacyTemplate(text string) string {
+	text = legacyDoubleVar.ReplaceAllStringFunc(text, func(m string) string {
+		name := legacyDoubleVar.FindStringSubmatch(m)[1]
+		if templateWords[name] || templateFuncs[name] != nil {
+			return m
+		}
+		return "{{." + name + "}}"
+	})
+	return legacySingleVar.ReplaceAllString(text, "$1{{.$2}}")
+}
+
+// ParseMessageTemplate checks a message template without rendering it
+func ParseMessageTemplate(text string) error {
+	_, err := template.New("message").Funcs(templateFuncs).Parse(upgradeLegacyTemplate(text))
+	return err
+}
+
+// RenderMessageTemplate renders a message template. Variables the template uses but
+// the data does not have render as empty.
+func RenderMessageTemplate(text string, vars map[string]interface{}) (string, error) {
+	tmpl, err := template.New("message").Funcs(templateFuncs).Parse(upgradeLegacyTemplate(text))
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(out.String(), "<no value>", ""), nil
+}
+
+// SubscriberTemplateVars returns the variables describing a subscriber. Service and
+// Reseller.User should be preloaded.
+func SubscriberTemplateVars(sub *models.Subscriber) map[string]interface{} {
+	vars := map[string]interface{}{
+		"subscriber_id":  sub.ID,
+		"username":       sub.Username,
+		"full_name":      sub.FullName,
+		"email":          sub.Email,
+		"phone":          sub.Phone,
+		"address":        sub.Address,
+		"region":         sub.Region,
+		"ip_address":     sub.IPAddress,
+		"mac_address":    sub.MACAddress,
+		"price":          sub.Price,
+		"balance":        fmt.Sprintf("%.2f", sub.Price),
+		"wallet_balance": sub.WalletBalance,
+		"expiry":         sub.ExpiryDate,
+		"expiry_date":    "",
+		"days_remaining": sub.DaysRemaining(),
+		"is_online":      sub.IsOnline,
+		"last_seen":      sub.LastSeen,
+		"service_name":   "",
+		"reseller_name":  "",
+	}
+	if !sub.ExpiryDate.IsZero() {
+		vars["expiry_date"] = sub.ExpiryDate.Format("2006-01-02")
+	}
+	if sub.Service != nil {
+		vars["service_name"] = sub.Service.Name
+	}
+	if sub.Reseller != nil {
+		vars["reseller_name"] = sub.Reseller.Name
+	}
+	return vars
+}
+
+// MessageTemplateVars merges event data with the subscriber's variables (which win)
+// and the company name
+func MessageTemplateVars(sub *models.Subscriber, data map[string]interface{}) map[string]interface{} {
+	vars := make(map[string]interface{}, len(data)+24)
+	for k, v := range data {
+		vars[k] = v
+	}
+	if sub != nil {
+		for k, v := range SubscriberTemplateVars(sub) {
+			vars[k] = v
+		}
+	}
+	if _, ok := vars["company_name"]; !ok {
+		vars["company_name"] = companyName()
+	}
+	vars["now"] = time.Now()
+	return vars
+}
+
+func companyName() string {
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", "company_name").First(&pref).Error; err != nil || pref.Value == "" {
+		return "ProxPanel"
+	}
+	return pref.Value
+}
+
+func templateTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false
+		}
+		return *v, !v.IsZero()
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func templateFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/mikrotik"
+	"github.com/proisp/backend/internal/models"
+)
+
+// RecordNasConfigVersion stores an export as a new version when it differs from the
+// latest one. It returns the current version and whether a new row was created.
+// Changes that don't touch ProISP-managed lines raise a config_changed alert.
+func RecordNasConfigVersion(nas *models.Nas, content, source, createdBy string) (*models.NasConfigVersion, bool, error) {
+	lines := mikrotik.NormalizeExport(content)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	var latest models.NasConfigVersion
+	hasLatest := database.DB.Where("nas_id = ?", nas.ID).Order("version DESC").First(&latest).Error == nil
+
+	if hasLatest && latest.ContentHash == hash {
+		database.DB.Model(&latest).Update("last_seen_at", now)
+		latest.LastSeenAt = now
+		return &latest, false, nil
+	}
+
+	version := models.NasConfigVersion{
+		NasID:       nas.ID,
+		NasName:     nas.Name,
+		Version:     1,
+		Content:     content,
+		ContentHash: hash,
+		SizeBytes:   len(content),
+		LineCount:   len(lines),
+		Source:      source,
+		CreatedBy:   createdBy,
+		LastSeenAt:  now,
+		CreatedAt:   now,
+	}
+
+	var unmanaged []string
+	if hasLatest {
+		version.Version = latest.Version + 1
+		for _, op := range mikrotik.DiffLines(mikrotik.NormalizeExport(latest.Content), lines) {
+			switch op.Op {
+			case "+":
+				version.AddedLines++
+			case "-":
+				version.RemovedLines++
+			default:
+				continue
+			}
+			if !isManagedConfigLine(op.Text) {
+				unmanaged = append(unmanaged, op.Op+" "+op.Text)
+			}
+		}
+		version.ChangedExternally = source != "restore" && len(unmanaged) > 0
+	}
+
+	if err := database.DB.Create(&version).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to store config version: %v", err)
+	}
+
+	log.Printf("NasConfig: Stored version %d for %s (+%d/-%d lines, source %s)",
+		version.Version, nas.Name, version.AddedLines, version.RemovedLines, source)
+
+	if version.ChangedExternally {
+		alertConfigChange(nas, &version, unmanaged)
+	}
+
+	pruneNasConfigVersions(nas.ID, version.Version)
+	return &version, true, nil
+}
+
+// SnapshotNasConfig exports the running config of a NAS and records it
+func SnapshotNasConfig(nas *models.Nas, source, createdBy string) (*models.NasConfigVersion, bool, error) {
+	if nas.APIUsername == "" || nas.APIPassword == "" {
+		return nil, false, fmt.Errorf("API credentials are not configured for this NAS")
+	}
+
+	client := mikrotik.NewClient(fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort), nas.APIUsername, nas.APIPassword)
+	client.FTPPort = nas.FTPPort
+	content, err := client.ExportConfig()
+	client.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("export failed: %v", err)
+	}
+
+	return RecordNasConfigVersion(nas, content, source, createdBy)
+}
+
+// RestoreNasConfigVersion pushes a stored export back to the router.
+// In import mode the resulting config is recorded as a "restore" version so it is not
+// reported as an outside change. Reset mode reboots the router; the next backup records it.
+func RestoreNasConfigVersion(nas *models.Nas, version *models.NasConfigVersion, reset bool, username string) error {
+	if nas.APIUsername == "" || nas.APIPassword == "" {
+		return fmt.Errorf("API credentials are not configured for this NAS")
+	}
+
+	client := mikrotik.NewClient(fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort), nas.APIUsername, nas.APIPassword)
+	client.FTPPort = nas.FTPPort
+	err := client.ImportConfig(version.Content, reset)
+	client.Close()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("NasConfig: %s restored version %d to %s (reset=%v)", username, version.Version, nas.Name, reset)
+
+	if !reset {
+		if _, _, err := SnapshotNasConfig(nas, "restore", username); err != nil {
+			log.Printf("NasConfig: Post-restore snapshot of %s failed: %v", nas.Name, err)
+		}
+	}
+	return nil
+}
+
+// isManagedConfigLine reports whether an export line belongs to an object ProISP creates
+// (queues, mangle rules, address lists and RADIUS entries are all commented with the company name)
+func isManagedConfigLine(line string) bool {
+	lower := strings.ToLower(line)
+	if strings.Contains(lower, strings.ToLower(mikrotik.ProvisionComment)) {
+		return true
+	}
+	if company := strings.Trim(database.GetCompanyName(), "\""); company != "" {
+		return strings.Contains(lower, strings.ToLower(company))
+	}
+	return false
+}
+
+// alertConfigChange records a config_changed event and notifies the NAS alert contacts
+func alertConfigChange(nas *models.Nas, version *models.NasConfigVersion, unmanaged []string) {
+	if getStringPreference("nas_config_change_alerts", "true") != "true" {
+		return
+	}
+
+	preview := unmanaged
+	if len(preview) > 5 {
+		preview = preview[:5]
+	}
+	message := fmt.Sprintf("Configuration changed outside ProISP (version %d, %d lines): %s",
+		version.Version, len(unmanaged), mikrotik.RedactExport(strings.Join(preview, " | ")))
+	if len(message) > 500 {
+		message = message[:500]
+	}
+
+	event := models.NasHealthEvent{
+		NasID:     nas.ID,
+		NasName:   nas.Name,
+		EventType: models.NasEventConfigChanged,
+		Severity:  "warning",
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+
+	if err := NewNotificationManager().SendNasAlert(nas.Name, string(models.NasEventConfigChanged), message); err != nil {
+		log.Printf("NasConfig: Alert for %s not delivered: %v", nas.Name, err)
+	} else {
+		event.Notified = true
+	}
+
+	database.DB.Create(&event)
+}
+
+// pruneNasConfigVersions keeps only the most recent versions of a NAS
+func pruneNasConfigVersions(nasID uint, latestVersion int) {
+	keep := getIntPreference("nas_config_versions_keep", 100)
+	if latestVersion <= keep {
+		return
+	}
+	database.DB.Where("nas_id = ? AND version <= ?", nasID, latestVersion-keep).Delete(&models.NasConfigVersion{})
+}
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/mikrotik"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/snmp"
+	"github.com/proisp/backend/internal/webhook"
+)
+
+// NasMonitorService periodically polls NAS devices for CPU, memory, uptime,
+// interface traffic and session counts, stores the samples as a time series
+// and raises alerts on reboots, overloads and offline routers.
+type NasMonitorService struct {
+	pollInterval   time.Duration
+	offlineAfter   int // Consecutive failed polls before a NAS is declared offline
+	overloadAfter  int // Consecutive samples above threshold before raising overload
+	maxConcurrency int
+	notifier       *NotificationManager
+	states         map[uint]*nasMonitorState
+	statesMu       sync.Mutex
+	lastPrune      time.Time
+	stopChan       chan struct{}
+	wg             sync.WaitGroup
+	mu             sync.Mutex
+	isRunning      bool
+}
+
+// nasMonitorState keeps the last observation per NAS between polls
+type nasMonitorState struct {
+	initialized    bool
+	online         bool
+	failures       int
+	overloaded     bool
+	overloadStreak int
+	lastUptime     int64
+	lastRx         int64
+	lastTx         int64
+	lastPollAt     time.Time
+}
+
+// nasHealthData is the normalized result of an API or SNMP poll
+type nasHealthData struct {
+	source        string
+	version       string
+	cpuLoad       int
+	memoryUsed    int64
+	memoryTotal   int64
+	uptimeSeconds int64
+	rxBytes       int64
+	txBytes       int64
+	interfaces    interface{}
+}
+
+// NewNasMonitorService creates a new NAS monitor service
+func NewNasMonitorService(pollInterval time.Duration) *NasMonitorService {
+	if pollInterval <= 0 {
+		pollInterval = 1 * time.Minute
+	}
+	return &NasMonitorService{
+		pollInterval:   pollInterval,
+		offlineAfter:   3,
+		overloadAfter:  3,
+		maxConcurrency: 10,
+		notifier:       NewNotificationManager(),
+		states:         make(map[uint]*nasMonitorState),
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// Start begins the monitor loop
+func (s *NasMonitorService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("NasMonitorService started (interval: %v)", s.pollInterval)
+}
+
+// Stop stops the monitor loop
+func (s *NasMonitorService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("NasMonitorService stopped")
+}
+
+func (s *NasMonitorService) run() {
+	defer s.wg.Done()
+
+	// Let the connection pool and RADIUS settle before the first poll
+	select {
+	case <-time.After(30 * time.Second):
+		s.pollAll()
+	case <-s.stopChan:
+		return
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.pollAll()
+			s.pruneIfDue()
+		}
+	}
+}
+
+// pollAll polls every active, monitored NAS with bounded concurrency
+func (s *NasMonitorService) pollAll() {
+	if database.DB == nil {
+		return
+	}
+
+	var nasList []models.Nas
+	if err := database.DB.Where("is_active = ? AND monitor_enabled = ?", true, true).Find(&nasList).Error; err != nil {
+		log.Printf("NasMonitor: Failed to load NAS devices: %v", err)
+		return
+	}
+
+	sem := make(chan struct{}, s.maxConcurrency)
+	var wg sync.WaitGroup
+	for i := range nasList {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(nas models.Nas) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.pollNas(&nas)
+		}(nasList[i])
+	}
+	wg.Wait()
+}
+
+// PollNow polls a single NAS immediately and returns the stored sample
+func (s *NasMonitorService) PollNow(nasID uint) (*models.NasHealthSample, error) {
+	var nas models.Nas
+	if err := database.DB.First(&nas, nasID).Error; err != nil {
+		return nil, fmt.Errorf("NAS not found")
+	}
+	return s.pollNas(&nas)
+}
+
+// pollNas collects one sample from a NAS, stores it and evaluates alert conditions
+func (s *NasMonitorService) pollNas(nas *models.Nas) (*models.NasHealthSample, error) {
+	state := s.getState(nas.ID)
+
+	data, err := s.collect(nas)
+	if err != nil {
+		s.handlePollFailure(nas, state, err)
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// Active sessions from accounting (works for every NAS type)
+	var sessionCount int64
+	database.DB.Model(&models.RadAcct{}).
+		Where("nasipaddress = ? AND acctstoptime IS NULL", nas.IPAddress).
+		Count(&sessionCount)
+
+	sample := models.NasHealthSample{
+		NasID:          nas.ID,
+		Source:         data.source,
+		CPULoad:        data.cpuLoad,
+		MemoryUsed:     data.memoryUsed,
+		MemoryTotal:    data.memoryTotal,
+		UptimeSeconds:  data.uptimeSeconds,
+		RxBytes:        data.rxBytes,
+		TxBytes:        data.txBytes,
+		ActiveSessions: int(sessionCount),
+		CreatedAt:      now,
+	}
+	if ifaces, err := json.Marshal(data.interfaces); err == nil {
+		sample.Interfaces = ifaces
+	}
+
+	s.statesMu.Lock()
+	// Rates since previous sample (skip on counter reset / reboot)
+	if state.initialized && !state.lastPollAt.IsZero() {
+		elapsed := now.Sub(state.lastPollAt).Seconds()
+		if elapsed > 0 && data.rxBytes >= state.lastRx && data.txBytes >= state.lastTx {
+			sample.RxBps = int64(float64(data.rxBytes-state.lastRx) * 8 / elapsed)
+			sample.TxBps = int64(float64(data.txBytes-state.lastTx) * 8 / elapsed)
+		}
+	}
+	rebooted := state.initialized && data.uptimeSeconds > 0 && data.uptimeSeconds < state.lastUptime
+	wasOffline := state.initialized && !state.online
+	state.initialized = true
+	state.online = true
+	state.failures = 0
+	state.lastUptime = data.uptimeSeconds
+	state.lastRx = data.rxBytes
+	state.lastTx = data.txBytes
+	state.lastPollAt = now
+	s.statesMu.Unlock()
+
+	if err := database.DB.Create(&sample).Error; err != nil {
+		log.Printf("NasMonitor: Failed to store sample for %s: %v", nas.Name, err)
+	}
+
+	updates := map[string]interface{}{
+		"is_online":       true,
+		"last_seen":       &now,
+		"active_sessions": sample.ActiveSessions,
+	}
+	if data.version != "" {
+		updates["version"] = data.version
+	}
+	database.DB.Model(&models.Nas{}).Where("id = ?", nas.ID).Updates(updates)
+
+	if wasOffline {
+		s.raiseEvent(nas, models.NasEventOnline, "info", "NAS is reachable again")
+	}
+	if rebooted {
+		s.raiseEvent(nas, models.NasEventReboot, "warning",
+			fmt.Sprintf("NAS rebooted (uptime reset to %s)", formatDurationSeconds(data.uptimeSeconds)))
+	}
+	s.checkOverload(nas, state, &sample)
+
+	return &sample, nil
+}
+
+// collect polls the NAS via RouterOS API or SNMP depending on its configuration
+func (s *NasMonitorService) collect(nas *models.Nas) (*nasHealthData, error) {
+	if nas.UsesSNMP() {
+		return s.collectSNMP(nas)
+	}
+	if nas.Type == models.NasTypeMikrotik && nas.APIUsername != "" {
+		return s.collectMikrotik(nas)
+	}
+	return nil, fmt.Errorf("no monitoring method configured (set API credentials or SNMP)")
+}
+
+// collectMikrotik reads /system/resource and /interface counters over the pooled API
+func (s *NasMonitorService) collectMikrotik(nas *models.Nas) (*nasHealthData, error) {
+	client := mikrotik.NewPooledClient(
+		fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort),
+		nas.APIUsername,
+		nas.APIPassword,
+	)
+
+	res, err := client.GetSystemResource()
+	if err != nil {
+		return nil, err
+	}
+
+	data := &nasHealthData{
+		source:        "api",
+		version:       res.Version,
+		cpuLoad:       res.CPULoad,
+		memoryUsed:    res.TotalMemory - res.FreeMemory,
+		memoryTotal:   res.TotalMemory,
+		uptimeSeconds: mikrotik.ParseUptime(res.Uptime),
+	}
+
+	ifaces, err := client.GetInterfaceStats()
+	if err != nil {
+		log.Printf("NasMonitor: Interface stats failed for %s: %v", nas.Name, err)
+	} else {
+		for _, ifc := range ifaces {
+			data.rxBytes += ifc.RxBytes
+			data.txBytes += ifc.TxBytes
+		}
+		data.interfaces = ifaces
+	}
+
+	return data, nil
+}
+
+// collectSNMP reads HOST-RESOURCES-MIB and IF-MIB counters over SNMPv2c/v3
+func (s *NasMonitorService) collectSNMP(nas *models.Nas) (*nasHealthData, error) {
+	stats, err := snmp.Poll(snmp.Config{
+		Address:      nas.IPAddress,
+		Port:         nas.SNMPPort,
+		Version:      nas.SNMPVersion,
+		Community:    nas.SNMPCommunity,
+		Username:     nas.SNMPUsername,
+		AuthProtocol: nas.SNMPAuthProtocol,
+		AuthPassword: nas.SNMPAuthPassword,
+		PrivProtocol: nas.SNMPPrivProtocol,
+		PrivPassword: nas.SNMPPrivPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := &nasHealthData{
+		source:        "snmp",
+		cpuLoad:       stats.CPULoad,
+		memoryUsed:    stats.MemoryUsed,
+		memoryTotal:   stats.MemoryTotal,
+		uptimeSeconds: stats.UptimeSeconds,
+		interfaces:    stats.Interfaces,
+	}
+	for _, ifc := range stats.Interfaces {
+		data.rxBytes += ifc.RxBytes
+		data.txBytes += ifc.TxBytes
+	}
+	return data, nil
+}
+
+// handlePollFailure counts failures and marks the NAS offline after offlineAfter misses
+func (s *NasMonitorService) handlePollFailure(nas *models.Nas, state *nasMonitorState, pollErr error) {
+	s.statesMu.Lock()
+	state.failures++
+	goingOffline := false
+	if state.failures == s.offlineAfter {
+		// After a restart we have no history - trust the stored status to avoid duplicate alerts
+		goingOffline = state.online || (!state.initialized && nas.IsOnline)
+		state.initialized = true
+		state.online = false
+	}
+	s.statesMu.Unlock()
+
+	if !goingOffline {
+		return
+	}
+
+	message := fmt.Sprintf("NAS unreachable for %d consecutive polls: %v", state.failures, pollErr)
+	database.DB.Model(&models.Nas{}).Where("id = ?", nas.ID).Update("is_online", false)
+	s.raiseEvent(nas, models.NasEventOffline, "critical", message)
+	webhook.Emit(models.WebhookEventNasDown, map[string]interface{}{
+		"nas_id":   nas.ID,
+		"nas_name": nas.Name,
+		"nas_ip":   nas.IPAddress,
+		"message":  message,
+	})
+}
+
+// checkOverload raises/clears overload alerts based on CPU and memory thresholds
+func (s *NasMonitorService) checkOverload(nas *models.Nas, state *nasMonitorState, sample *models.NasHealthSample) {
+	cpuThreshold := getIntPreference("nas_monitor_cpu_threshold", 90)
+	memThreshold := getIntPreference("nas_monitor_memory_threshold", 90)
+
+	over := sample.CPULoad >= cpuThreshold || sample.MemoryPercent() >= memThreshold
+
+	s.statesMu.Lock()
+	raise, clear := false, false
+	if over {
+		state.overloadStreak++
+		if !state.overloaded && state.overloadStreak >= s.overloadAfter {
+			state.overloaded = true
+			raise = true
+		}
+	} else {
+		state.overloadStreak = 0
+		if state.overloaded {
+			state.overloaded = false
+			clear = true
+		}
+	}
+	s.statesMu.Unlock()
+
+	if raise {
+		s.raiseEvent(nas, models.NasEventOverload, "warning",
+			fmt.Sprintf("High load: CPU %d%% (threshold %d%%), memory %d%% (threshold %d%%)",
+				sample.CPULoad, cpuThreshold, sample.MemoryPercent(), memThreshold))
+	}
+	if clear {
+		s.raiseEvent(nas, models.NasEventOverloadCleared, "info",
+			fmt.Sprintf("Load back to normal: CPU %d%%, memory %d%%", sample.CPULoad, sample.MemoryPercent()))
+	}
+}
+
+// raiseEvent stores a health event and sends it through the notification channels
+func (s *NasMonitorService) raiseEvent(nas *models.Nas, eventType models.NasHealthEventType, severity, message string) {
+	event := models.NasHealthEvent{
+		NasID:     nas.ID,
+		NasName:   nas.Name,
+		EventType: eventType,
+		Severity:  severity,
+		Message:   message,
+		CreatedAt: time.Now(),
+	}
+
+	log.Printf("NasMonitor: [%s] %s: %s", nas.Name, eventType, message)
+
+	if err := s.notifier.SendNasAlert(nas.Name, string(eventType), message); err != nil {
+		log.Printf("NasMonitor: Alert for %s not delivered: %v", nas.Name, err)
+	} else {
+		event.Notified = true
+	}
+
+	database.DB.Create(&event)
+}
+
+// getState returns (creating if needed) the in-memory state for a NAS
+func (s *NasMonitorService) getState(nasID uint) *nasMonitorState {
+	s.statesMu.Lock()
+	defer s.statesMu.Unlock()
+
+	state, ok := s.states[nasID]
+	if !ok {
+		state = &nasMonitorState{}
+		s.states[nasID] = state
+	}
+	return state
+}
+
+// pruneIfDue removes samples older than the retention period once a day
+func (s *NasMonitorService) pruneIfDue() {
+	if time.Since(s.lastPrune) < 24*time.Hour {
+		return
+	}
+	s.lastPrune = time.Now()
+
+	retentionDays := getIntPreference("nas_monitor_retention_days", 30)
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := database.DB.Where("created_at < ?", cutoff).Delete(&models.NasHealthSample{})
+	if result.Error != nil {
+		log.Printf("NasMonitor: Failed to prune samples: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("NasMonitor: Pruned %d samples older than %d days", result.RowsAffected, retentionDays)
+	}
+}
+
+// getIntPreference reads an integer system preference with a default
+func getIntPreference(key string, defaultValue int) int {
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", key).First(&pref).Error; err != nil {
+		return defaultValue
+	}
+	val, err := strconv.Atoi(pref.Value)
+	if err != nil || val <= 0 {
+		return defaultValue
+	}
+	return val
+}
+
+// formatDurationSeconds formats seconds as "1d 2h 3m"
+func formatDurationSeconds(seconds int64) string {
+	d := seconds / 86400
+	h := (seconds % 86400) / 3600
+	m := (seconds % 3600) / 60
+	if d > 0 {
+		return fmt.Sprintf("%dd %dh %dm", d, h, m)
+	}
+	if h > 0 {
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	return fmt.Sprintf("%dm %ds", m, seconds%60)
+}
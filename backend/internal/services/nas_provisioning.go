@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/mikrotik"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/radius"
+)
+
+// NasProvisionOptions are operator overrides for a provisioning run
+type NasProvisionOptions struct {
+	ServerAddress  string `json:"server_address"`  // RADIUS server IP as seen by the router (default: radius_server_address pref, then SERVER_IP)
+	SrcAddress     string `json:"src_address"`     // Router-side source address for RADIUS packets
+	InterimMinutes int    `json:"interim_minutes"` // Accounting interim-update interval (default: nas_interim_update_minutes pref)
+	Services       string `json:"services"`        // RouterOS RADIUS services (default: ppp)
+	SkipVerifyCoA  bool   `json:"skip_verify_coa"` // Skip the CoA test when the router can't be reached on UDP from here
+}
+
+// NasProvisionResult is the outcome of a provisioning run
+type NasProvisionResult struct {
+	Status        string                   `json:"status"` // success, partial, failed
+	ServerAddress string                   `json:"server_address"`
+	Steps         []mikrotik.ProvisionStep `json:"steps"`
+	CoATest       *radius.TestResult       `json:"coa_test,omitempty"`
+	AuthTest      *radius.TestResult       `json:"auth_test,omitempty"`
+}
+
+// ProvisionNas pushes the RADIUS client, CoA listener and PPP AAA settings to a
+// MikroTik router using its API credentials, then verifies the secret with a CoA test
+// and a test authentication against the RADIUS server.
+// The outcome is stored on the NAS row.
+func ProvisionNas(nas *models.Nas, opts NasProvisionOptions) (*NasProvisionResult, error) {
+	if nas.Type != models.NasTypeMikrotik {
+		return nil, fmt.Errorf("automatic provisioning is only supported for MikroTik routers")
+	}
+	if nas.APIUsername == "" || nas.APIPassword == "" {
+		return nil, fmt.Errorf("API credentials are not configured for this NAS")
+	}
+	if nas.Secret == "" {
+		return nil, fmt.Errorf("RADIUS secret is not configured for this NAS")
+	}
+
+	serverAddr := opts.ServerAddress
+	if serverAddr == "" {
+		serverAddr = getStringPreference("radius_server_address", "")
+	}
+	if serverAddr == "" {
+		serverAddr = getLocalServerIP()
+	}
+	if serverAddr == "" {
+		return nil, fmt.Errorf("could not determine the RADIUS server address - set radius_server_address")
+	}
+
+	interim := opts.InterimMinutes
+	if interim <= 0 {
+		interim = getIntPreference("nas_interim_update_minutes", 5)
+	}
+
+	services := opts.Services
+	if services == "" && nas.HotspotEnabled {
+		services = "ppp,hotspot"
+	}
+
+	cfg := mikrotik.RadiusProvisionConfig{
+		ServerAddress:   serverAddr,
+		Secret:          nas.Secret,
+		AuthPort:        nas.AuthPort,
+		AcctPort:        nas.AcctPort,
+		SrcAddress:      opts.SrcAddress,
+		Services:        services,
+		CoAPort:         nas.CoAPort,
+		InterimInterval: time.Duration(interim) * time.Minute,
+	}
+	if cfg.AuthPort == 0 {
+		cfg.AuthPort = 1812
+	}
+	if cfg.AcctPort == 0 {
+		cfg.AcctPort = 1813
+	}
+	if cfg.CoAPort == 0 {
+		cfg.CoAPort = 1700
+	}
+
+	client := mikrotik.NewClient(fmt.Sprintf("%s:%d", nas.IPAddress, nas.APIPort), nas.APIUsername, nas.APIPassword)
+	defer client.Close()
+
+	result := &NasProvisionResult{
+		ServerAddress: serverAddr,
+		Steps:         client.ProvisionRadius(cfg),
+	}
+
+	// End-to-end check: the router must accept a CoA signed with our secret on the configured port
+	if !opts.SkipVerifyCoA && len(result.Steps) > 1 {
+		test := radius.TestSecretOnPort(nas.IPAddress, cfg.CoAPort, nas.Secret)
+		result.CoATest = &test
+		result.Steps = append(result.Steps, mikrotik.ProvisionStep{
+			Name:    "coa_test",
+			Success: test.SecretValid,
+			Message: test.ErrorMsg,
+		})
+	}
+
+	// The RADIUS server must answer an Access-Request signed with the secret, as the router sends them
+	if len(result.Steps) > 1 {
+		test := radius.TestAuth(serverAddr, cfg.AuthPort, nas.IPAddress, nas.Secret)
+		result.AuthTest = &test
+		result.Steps = append(result.Steps, mikrotik.ProvisionStep{
+			Name:    "auth_test",
+			Success: test.SecretValid,
+			Message: test.ErrorMsg,
+		})
+	}
+
+	var failed []string
+	for _, step := range result.Steps {
+		if !step.Success {
+			failed = append(failed, fmt.Sprintf("%s: %s", step.Name, step.Message))
+		}
+	}
+	message := "Router provisioned"
+	switch {
+	case len(failed) == 0:
+		result.Status = "success"
+	case len(failed) == len(result.Steps) || !result.Steps[0].Success:
+		result.Status = "failed"
+		message = strings.Join(failed, "; ")
+	default:
+		result.Status = "partial"
+		message = strings.Join(failed, "; ")
+	}
+	if len(message) > 500 {
+		message = message[:500]
+	}
+
+	now := time.Now()
+	database.DB.Model(nas).Updates(map[string]interface{}{
+		"provisioned_at":    &now,
+		"provision_status":  result.Status,
+		"provision_message": message,
+	})
+	nas.ProvisionedAt = &now
+	nas.ProvisionStatus = result.Status
+	nas.ProvisionMessage = message
+
+	return result, nil
+}
+
+// getStringPreference reads a string system preference
+func getStringPreference(key, defaultValue string) string {
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", key).First(&pref).Error; err != nil || pref.Value == "" {
+		return defaultValue
+	}
+	return pref.Value
+}
@@ -1,10 +1,14 @@
 package services
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
+	"mime"
 	"net/smtp"
 	"strings"
+	"time"
 
 	"github.com/proisp/backend/internal/database"
 	"github.com/proisp/backend/internal/models"
@@ -97,6 +101,66 @@ func (s *EmailService) SendEmailWithConfig(config *EmailConfig, to, subject, bod
 		"\r\n"+
 		"%s", from, to, subject, contentType, body)
 
+	return s.deliver(config, to, []byte(msg))
+}
+
+// EmailAttachment is a file attached to an email
+type EmailAttachment struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// SendEmailWithAttachments sends an email with files attached as a multipart/mixed message
+func (s *EmailService) SendEmailWithAttachments(to, subject, body string, isHTML bool, attachments []EmailAttachment) error {
+	config, err := s.GetConfig()
+	if err != nil {
+		return err
+	}
+	if config.Host == "" || config.Port == "" {
+		return fmt.Errorf("SMTP not configured")
+	}
+
+	from := config.FromAddr
+	if config.FromName != "" {
+		from = fmt.Sprintf("%s <%s>", config.FromName, config.FromAddr)
+	}
+
+	contentType := "text/plain"
+	if isHTML {
+		contentType = "text/html"
+	}
+
+	boundary := fmt.Sprintf("proisp-%d", time.Now().UnixNano())
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n"+
+		"To: %s\r\n"+
+		"Subject: %s\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: multipart/mixed; boundary=\"%s\"\r\n"+
+		"\r\n", from, to, mime.QEncoding.Encode("UTF-8", subject), boundary)
+	fmt.Fprintf(&msg, "--%s\r\nContent-Type: %s; charset=UTF-8\r\n\r\n%s\r\n", boundary, contentType, body)
+
+	for _, a := range attachments {
+		fmt.Fprintf(&msg, "--%s\r\n"+
+			"Content-Type: %s\r\n"+
+			"Content-Transfer-Encoding: base64\r\n"+
+			"Content-Disposition: attachment; filename=\"%s\"\r\n"+
+			"\r\n", boundary, a.ContentType, strings.ReplaceAll(a.FileName, "\"", ""))
+		encoded := base64.StdEncoding.EncodeToString(a.Content)
+		for len(encoded) > 76 {
+			msg.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		msg.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+
+	return s.deliver(config, to, msg.Bytes())
+}
+
+// deliver sends a built message over TLS, STARTTLS or plain SMTP depending on the port
+func (s *EmailService) deliver(config *EmailConfig, to string, msg []byte) error {
 	addr := fmt.Sprintf("%s:%s", config.Host, config.Port)
 
 	// Determine if we should use TLS
@@ -111,13 +175,13 @@ func (s *EmailService) SendEmailWithConfig(config *EmailConfig, to, subject, bod
 
 	if useTLS {
 		// Direct TLS connection (port 465)
-		return s.sendWithTLS(addr, config, auth, to, []byte(msg))
+		return s.sendWithTLS(addr, config, auth, to, msg)
 	} else if useStartTLS {
 		// STARTTLS connection (port 587)
-		return s.sendWithStartTLS(addr, config, auth, to, []byte(msg))
+		return s.sendWithStartTLS(addr, config, auth, to, msg)
 	} else {
 		// Plain connection
-		return smtp.SendMail(addr, auth, config.FromAddr, []string{to}, []byte(msg))
+		return smtp.SendMail(addr, auth, config.FromAddr, []string{to}, msg)
 	}
 }
 
@@ -40,6 +40,7 @@ const (
 	NotifyServiceChange     NotificationType = "service_change"
 	NotifyPaymentReceived   NotificationType = "payment_received"
 	NotifyInvoice           NotificationType = "invoice"
+	NotifyNasAlert          NotificationType = "nas_alert"
 )
 
 // NotificationChannel represents notification delivery channel
@@ -69,7 +70,7 @@ type NotificationData struct {
 	CustomData     map[string]string
 }
 
-// SendNotification sends a notification through enabled channels
+// SendNotification queues a notification in the outbox for each enabled channel
 func (m *NotificationManager) SendNotification(notifType NotificationType, data *NotificationData) error {
 	// Get notification settings
 	settings := m.getNotificationSettings(notifType)
@@ -86,27 +87,24 @@ func (m *NotificationManager) SendNotification(notifType NotificationType, data
 	if settings.EmailEnabled && data.Email != "" {
 		if err := m.sendEmailNotification(notifType, data); err != nil {
 			errors = append(errors, fmt.Sprintf("email: %v", err))
-			log.Printf("Email notification failed for %s: %v", data.Username, err)
+			log.Printf("Failed to queue email notification for %s: %v", data.Username, err)
 		}
 	}
 
 	if settings.SMSEnabled && data.Phone != "" {
 		if err := m.sendSMSNotification(notifType, data); err != nil {
 			errors = append(errors, fmt.Sprintf("sms: %v", err))
-			log.Printf("SMS notification failed for %s: %v", data.Username, err)
+			log.Printf("Failed to queue SMS notification for %s: %v", data.Username, err)
 		}
 	}
 
 	if settings.WhatsAppEnabled && data.Phone != "" {
 		if err := m.sendWhatsAppNotification(notifType, data); err != nil {
 			errors = append(errors, fmt.Sprintf("whatsapp: %v", err))
-			log.Printf("WhatsApp notification failed for %s: %v", data.Username, err)
+			log.Printf("Failed to queue WhatsApp notification for %s: %v", data.Username, err)
 		}
 	}
 
-	// Log notification
-	m.logNotification(notifType, data, errors)
-
 	if len(errors) > 0 {
 		return fmt.Errorf("notification errors: %s", strings.Join(errors, "; "))
 	}
@@ -216,6 +214,12 @@ func (m *NotificationManager) getDefaultEmailTemplate(notifType NotificationType
 <strong>Due Date:</strong> {{expiry_date}}<br>
 <strong>Service:</strong> {{service_name}}</p>
 <p>Please make the payment before the due date to avoid service interruption.</p>
+`,
+		NotifyNasAlert: `
+<h2>NAS Alert: {{nas_name}}</h2>
+<p><strong>Event:</strong> {{event}}<br>
+<strong>Time:</strong> {{event_time}}</p>
+<p>{{message}}</p>
 `,
 	}
 
@@ -234,6 +238,7 @@ func (m *NotificationManager) getDefaultSMSTemplate(notifType NotificationType)
 		NotifyQuotaLow:       "{{company_name}}: Low quota warning! Remaining: {{quota_remaining}}",
 		NotifyPaymentReceived: "{{company_name}}: Payment of {{amount}} received. Thank you!",
 		NotifyInvoice:         "{{company_name}}: Invoice {{invoice_number}} for {{amount}} due {{expiry_date}}. Please pay to avoid interruption.",
+		NotifyNasAlert:        "{{company_name}} NAS alert [{{nas_name}}] {{event}}: {{message}}",
 	}
 
 	if template, ok := templates[notifType]; ok {
@@ -295,6 +300,12 @@ A new invoice has been generated:
 • *Service:* {{service_name}}
 
 Please pay before the due date to avoid disconnection.`,
+		NotifyNasAlert: `🚨 *NAS Alert: {{nas_name}}*
+
+• *Event:* {{event}}
+• *Time:* {{event_time}}
+
+{{message}}`,
 	}
 
 	if template, ok := templates[notifType]; ok {
@@ -317,7 +328,7 @@ func (m *NotificationManager) sendEmailNotification(notifType NotificationType,
 	subject := m.getNotificationSubject(notifType, data)
 	body := m.replaceTemplateVars(template, data)
 
-	return m.email.SendEmail(data.Email, subject, body, true)
+	return m.queue(ChannelEmail, data.Email, subject, body, notifType, data)
 }
 
 func (m *NotificationManager) sendSMSNotification(notifType NotificationType, data *NotificationData) error {
@@ -332,7 +343,7 @@ func (m *NotificationManager) sendSMSNotification(notifType NotificationType, da
 	}
 
 	message := m.replaceTemplateVars(template, data)
-	return m.sms.SendSMS(data.Phone, message)
+	return m.queue(ChannelSMS, data.Phone, "", message, notifType, data)
 }
 
 func (m *NotificationManager) sendWhatsAppNotification(notifType NotificationType, data *NotificationData) error {
@@ -347,7 +358,30 @@ func (m *NotificationManager) sendWhatsAppNotification(notifType NotificationTyp
 	}
 
 	message := m.replaceTemplateVars(template, data)
-	return m.whatsapp.SendMessage(data.Phone, message)
+	return m.queue(ChannelWhatsApp, data.Phone, "", message, notifType, data)
+}
+
+// queue adds a rendered notification to the outbox. WhatsApp goes out from the admin
+// account and NAS alerts skip quiet hours.
+func (m *NotificationManager) queue(channel NotificationChannel, to, subject, body string, notifType NotificationType, data *NotificationData) error {
+	msg := &models.OutboundMessage{
+		Channel:   string(channel),
+		Recipient: to,
+		Subject:   subject,
+		Body:      body,
+		IsHTML:    channel == ChannelEmail,
+		Source:    "notification",
+		Urgent:    notifType == NotifyNasAlert,
+	}
+	if channel == ChannelWhatsApp {
+		msg.Sender = models.OutboundSenderAdmin
+	}
+	if data.SubscriberID != 0 {
+		subID := data.SubscriberID
+		msg.SubscriberID = &subID
+	}
+	_, err := QueueMessage(msg)
+	return err
 }
 
 func (m *NotificationManager) getNotificationSubject(notifType NotificationType, data *NotificationData) string {
@@ -360,6 +394,7 @@ func (m *NotificationManager) getNotificationSubject(notifType NotificationType,
 		NotifyQuotaExhausted: "%s - Quota Exhausted",
 		NotifyPaymentReceived: "%s - Payment Received",
 		NotifyInvoice:        "%s - Invoice #%s",
+		NotifyNasAlert:       "%s - NAS Alert",
 	}
 
 	if subject, ok := subjects[notifType]; ok {
@@ -371,57 +406,35 @@ func (m *NotificationManager) getNotificationSubject(notifType NotificationType,
 	return fmt.Sprintf("%s - Notification", data.CompanyName)
 }
 
+// replaceTemplateVars renders a notification template (see RenderMessageTemplate).
+// An invalid template is sent as written.
 func (m *NotificationManager) replaceTemplateVars(template string, data *NotificationData) string {
-	replacements := map[string]string{
-		"{{username}}":        data.Username,
-		"{{full_name}}":       data.FullName,
-		"{{email}}":           data.Email,
-		"{{phone}}":           data.Phone,
-		"{{service_name}}":    data.ServiceName,
-		"{{expiry_date}}":     data.ExpiryDate,
-		"{{days_remaining}}":  fmt.Sprintf("%d", data.DaysRemaining),
-		"{{quota_used}}":      data.QuotaUsed,
-		"{{quota_remaining}}": data.QuotaRemaining,
-		"{{amount}}":          data.Amount,
-		"{{invoice_number}}":  data.InvoiceNumber,
-		"{{company_name}}":    data.CompanyName,
-	}
-
-	result := template
-	for key, value := range replacements {
-		result = strings.ReplaceAll(result, key, value)
-	}
-
-	// Replace custom data
+	vars := map[string]interface{}{
+		"username":        data.Username,
+		"full_name":       data.FullName,
+		"email":           data.Email,
+		"phone":           data.Phone,
+		"service_name":    data.ServiceName,
+		"expiry_date":     data.ExpiryDate,
+		"days_remaining":  data.DaysRemaining,
+		"quota_used":      data.QuotaUsed,
+		"quota_remaining": data.QuotaRemaining,
+		"amount":          data.Amount,
+		"invoice_number":  data.InvoiceNumber,
+		"company_name":    data.CompanyName,
+	}
+
+	// Custom data
 	for key, value := range data.CustomData {
-		result = strings.ReplaceAll(result, "{{"+key+"}}", value)
+		vars[key] = value
 	}
 
-	return result
-}
-
-func (m *NotificationManager) logNotification(notifType NotificationType, data *NotificationData, errors []string) {
-	status := "sent"
-	errorMsg := ""
-	if len(errors) > 0 {
-		status = "failed"
-		errorMsg = strings.Join(errors, "; ")
-	}
-
-	now := time.Now()
-
-	// Log to database using CommunicationLog
-	commLog := models.CommunicationLog{
-		Type:         string(notifType),
-		Recipient:    data.Email,
-		Subject:      m.getNotificationSubject(notifType, data),
-		Status:       status,
-		ErrorMessage: errorMsg,
-		SubscriberID: &data.SubscriberID,
-		CreatedAt:    now,
-		SentAt:       &now,
+	result, err := RenderMessageTemplate(template, vars)
+	if err != nil {
+		log.Printf("Notification: Invalid template: %v", err)
+		return template
 	}
-	database.DB.Create(&commLog)
+	return result
 }
 
 func (m *NotificationManager) getSettingBool(key string) bool {
@@ -478,6 +491,27 @@ func (m *NotificationManager) SendExpiryReminders(daysBeforeExpiry int) error {
 	return nil
 }
 
+// SendNasAlert sends a NAS health alert to the operator contacts configured in
+// nas_alert_email / nas_alert_phone through the enabled notification channels
+func (m *NotificationManager) SendNasAlert(nasName, event, message string) error {
+	data := &NotificationData{
+		Username: nasName,
+		FullName: "Network Operations",
+		Email:    m.getSettingString("nas_alert_email"),
+		Phone:    m.getSettingString("nas_alert_phone"),
+		CustomData: map[string]string{
+			"nas_name":   nasName,
+			"event":      event,
+			"message":    message,
+			"event_time": time.Now().Format("2006-01-02 15:04:05"),
+		},
+	}
+	if data.Email == "" && data.Phone == "" {
+		return fmt.Errorf("no NAS alert recipients configured")
+	}
+	return m.SendNotification(NotifyNasAlert, data)
+}
+
 // GetEmailService returns the email service
 func (m *NotificationManager) GetEmailService() *EmailService {
 	return m.email
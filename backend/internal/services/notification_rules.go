@@ -0,0 +1,369 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/events"
+	"github.com/proisp/backend/internal/models"
+)
+
+// EventSubscriberOffline is published on the event bus when a subscriber has been
+// offline for the offline_hours of a subscriber.offline rule. It is not a webhook event.
+const EventSubscriberOffline = "subscriber.offline"
+
+// RuleTrigger describes an event communication rules can fire on
+type RuleTrigger struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Scheduled   bool     `json:"scheduled"` // Evaluated by a scheduled job rather than the event bus
+	Variables   []string `json:"variables"` // Event variables on top of the subscriber variables
+}
+
+// RuleTriggers lists every trigger_event a rule can use
+var RuleTriggers = []RuleTrigger{
+	{Name: "expiry_warning", Description: "Subscription expires in days_before days (daily at the notification send time)", Scheduled: true, Variables: []string{"days_before"}},
+	{Name: "expired", Description: "Subscription expired today (daily at the notification send time)", Scheduled: true, Variables: []string{"days_before"}},
+	{Name: "quota_warning", Description: "Monthly quota reached days_before percent", Scheduled: true, Variables: []string{"quota_used", "quota_total", "quota_percent"}},
+	{Name: "fup_applied", Description: "FUP level applied", Scheduled: true, Variables: []string{"quota_used", "quota_total", "fup_level"}},
+	{Name: models.WebhookEventSubscriberCreated, Description: "Subscriber created"},
+	{Name: models.WebhookEventSubscriberRenewed, Description: "Subscriber renewed", Variables: []string{"amount", "new_expiry"}},
+	{Name: models.WebhookEventPaymentReceived, Description: "Invoice payment recorded", Variables: []string{"amount", "method", "reference", "invoice_number", "amount_paid", "total"}},
+	{Name: EventSubscriberOffline, Description: "Subscriber offline for offline_hours hours", Variables: []string{"offline_hours", "offline_since"}},
+	{Name: models.WebhookEventTicketUpdated, Description: "Ticket status, priority or assignment changed, or a reply was added", Variables: []string{"ticket_number", "ticket_subject", "ticket_status", "ticket_priority", "change", "reply"}},
+	{Name: models.WebhookEventNasDown, Description: "NAS stopped answering health polls (use a fixed recipient)", Variables: []string{"nas_id", "nas_name", "nas_ip", "message"}},
+}
+
+// SubscriberTemplateVariables are available in every rule that concerns a subscriber
+var SubscriberTemplateVariables = []string{
+	"username", "full_name", "email", "phone", "address", "region", "ip_address", "mac_address",
+	"price", "balance", "wallet_balance", "expiry", "expiry_date", "days_remaining", "is_online",
+	"last_seen", "service_name", "reseller_name", "company_name", "now",
+}
+
+// sampleEventData is the event data used to preview a rule
+var sampleEventData = map[string]map[string]interface{}{
+	"expiry_warning":                     {"days_before": 3},
+	"expired":                            {"days_before": 0},
+	"quota_warning":                      {"quota_used": "80.00 GB", "quota_total": "100.00 GB", "quota_percent": 80},
+	"fup_applied":                        {"quota_used": "50.00 GB", "quota_total": "100.00 GB", "fup_level": 1},
+	models.WebhookEventSubscriberRenewed: {"amount": 25.0},
+	models.WebhookEventPaymentReceived:   {"amount": 25.0, "method": "cash", "reference": "PREVIEW", "invoice_number": "INV-0001", "amount_paid": 25.0, "total": 25.0},
+	EventSubscriberOffline:               {"offline_hours": 24},
+	models.WebhookEventTicketUpdated:     {"ticket_number": "TKT-0001", "ticket_subject": "No internet", "ticket_status": "in_progress", "ticket_priority": "normal", "change": "status"},
+	models.WebhookEventNasDown:           {"nas_id": 1, "nas_name": "Core-1", "nas_ip": "10.0.0.1", "message": "NAS unreachable for 3 consecutive polls"},
+}
+
+// busTriggers are the rule triggers delivered by the event bus
+var busTriggers = map[string]bool{
+	models.WebhookEventSubscriberCreated: true,
+	models.WebhookEventSubscriberRenewed: true,
+	models.WebhookEventPaymentReceived:   true,
+	EventSubscriberOffline:               true,
+	models.WebhookEventTicketUpdated:     true,
+	models.WebhookEventNasDown:           true,
+}
+
+var startRulesOnce sync.Once
+
+// StartNotificationRules subscribes the communication rules to the event bus
+func StartNotificationRules() {
+	startRulesOnce.Do(func() {
+		events.Subscribe(handleRuleEvent)
+	})
+}
+
+// RuleMatches reports whether a rule's conditions (reseller, service, region) hold for
+// a subscriber. A rule with conditions never matches an event without a subscriber.
+func RuleMatches(rule models.CommunicationRule, sub *models.Subscriber) bool {
+	if rule.ResellerID == nil && rule.ServiceID == nil && rule.Region == "" {
+		return true
+	}
+	if sub == nil {
+		return false
+	}
+	if rule.ResellerID != nil && *rule.ResellerID != sub.ResellerID {
+		return false
+	}
+	if rule.ServiceID != nil && *rule.ServiceID != sub.ServiceID {
+		return false
+	}
+	if rule.Region != "" && !strings.EqualFold(rule.Region, sub.Region) {
+		return false
+	}
+	return true
+}
+
+// RenderRule renders a rule's subject and message for a subscriber (may be nil) and
+// event data
+func RenderRule(rule models.CommunicationRule, sub *models.Subscriber, data map[string]interface{}) (string, string, error) {
+	vars := MessageTemplateVars(sub, data)
+	vars["event"] = rule.TriggerEvent
+
+	message, err := RenderMessageTemplate(rule.Template, vars)
+	if err != nil {
+		return "", "", fmt.Errorf("template: %v", err)
+	}
+	subject := ""
+	if rule.Subject != "" {
+		if subject, err = RenderMessageTemplate(rule.Subject, vars); err != nil {
+			return "", "", fmt.Errorf("subject: %v", err)
+		}
+	}
+	return strings.TrimSpace(subject), message, nil
+}
+
+// PreviewRule renders a rule against a real subscriber with sample event data
+func PreviewRule(rule models.CommunicationRule, sub *models.Subscriber) (string, string, error) {
+	return RenderRule(rule, sub, sampleEventData[rule.TriggerEvent])
+}
+
+func handleRuleEvent(ev events.Event) {
+	if !busTriggers[ev.Name] || database.DB == nil {
+		return
+	}
+
+	var rules []models.CommunicationRule
+	if err := database.DB.Where("trigger_event = ? AND enabled = ?", ev.Name, true).Find(&rules).Error; err != nil {
+		log.Printf("RuleEvent[%s]: Failed to query communication rules: %v", ev.Name, err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	sub := eventSubscriber(ev)
+	for _, rule := range rules {
+		if rule.Template == "" || !RuleMatches(rule, sub) {
+			continue
+		}
+		if ev.Name == EventSubscriberOffline && int(templateFloat(ev.Data["offline_hours"])) != ruleOfflineHours(rule) {
+			continue
+		}
+
+		subject, message, err := RenderRule(rule, sub, ev.Data)
+		if err != nil {
+			log.Printf("RuleEvent[%s]: %v", rule.Name, err)
+			continue
+		}
+		if subject == "" {
+			subject = fmt.Sprintf("%s - %s", companyName(), ev.Name)
+		}
+		sendRuleMessage(rule, sub, subject, message)
+	}
+}
+
+// eventSubscriber loads the subscriber an event concerns, if any
+func eventSubscriber(ev events.Event) *models.Subscriber {
+	id := uint(templateFloat(uintValue(ev.Data["subscriber_id"])))
+	if id == 0 && strings.HasPrefix(ev.Name, "subscriber.") {
+		id = uint(templateFloat(uintValue(ev.Data["id"])))
+	}
+	if id == 0 {
+		return nil
+	}
+	var sub models.Subscriber
+	if err := database.DB.Preload("Service").Preload("Reseller.User").First(&sub, id).Error; err != nil {
+		return nil
+	}
+	return &sub
+}
+
+// uintValue dereferences *uint payload values
+func uintValue(v interface{}) interface{} {
+	if p, ok := v.(*uint); ok {
+		if p == nil {
+			return 0
+		}
+		return *p
+	}
+	return v
+}
+
+func ruleOfflineHours(rule models.CommunicationRule) int {
+	if rule.OfflineHours <= 0 {
+		return 24
+	}
+	return rule.OfflineHours
+}
+
+// ruleRecipients returns the rule's fixed recipients, or the subscriber's (or its
+// reseller's) phone or email for the rule's channel
+func ruleRecipients(rule models.CommunicationRule, sub *models.Subscriber) []string {
+	var recipients []string
+	if rule.Recipient != "" {
+		for _, r := range strings.Split(rule.Recipient, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				recipients = append(recipients, r)
+			}
+		}
+		return recipients
+	}
+	if sub == nil {
+		return nil
+	}
+	to := getNotifPhone(rule, *sub)
+	if rule.Channel == "email" {
+		to = getNotifEmail(rule, *sub)
+	}
+	if to == "" {
+		return nil
+	}
+	return []string{to}
+}
+
+// sendRuleMessage queues a rendered rule message for each recipient. NAS outages
+// are urgent and skip quiet hours.
+func sendRuleMessage(rule models.CommunicationRule, sub *models.Subscriber, subject, message string) {
+	recipients := ruleRecipients(rule, sub)
+	if len(recipients) == 0 {
+		log.Printf("RuleEvent[%s]: No recipient for %s, skipping", rule.Name, rule.Channel)
+		return
+	}
+
+	for _, to := range recipients {
+		ruleID := rule.ID
+		outbound := &models.OutboundMessage{
+			Channel:   rule.Channel,
+			Recipient: to,
+			Subject:   subject,
+			Body:      message,
+			Source:    "rule",
+			RuleID:    &ruleID,
+			Urgent:    rule.TriggerEvent == models.WebhookEventNasDown,
+		}
+		if sub != nil {
+			subID, resellerID := sub.ID, sub.ResellerID
+			outbound.SubscriberID = &subID
+			outbound.ResellerID = &resellerID
+		}
+		if _, err := QueueMessage(outbound); err != nil {
+			log.Printf("RuleEvent[%s]: Failed to queue %s to %s: %v", rule.Name, rule.Channel, to, err)
+		}
+	}
+}
+
+// SubscriberOfflineService publishes subscriber.offline when an active subscriber's
+// last_seen passes the offline_hours of an enabled subscriber.offline rule. Each
+// subscriber crosses each threshold once per outage, so no deduplication is needed.
+type SubscriberOfflineService struct {
+	checkInterval time.Duration
+	lastCheck     time.Time
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+}
+
+// NewSubscriberOfflineService creates a new subscriber offline service
+func NewSubscriberOfflineService(interval time.Duration) *SubscriberOfflineService {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &SubscriberOfflineService{
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the subscriber offline service
+func (s *SubscriberOfflineService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.lastCheck = time.Now()
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("SubscriberOfflineService started (interval: %v)", s.checkInterval)
+}
+
+// Stop stops the subscriber offline service
+func (s *SubscriberOfflineService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("SubscriberOfflineService stopped")
+}
+
+func (s *SubscriberOfflineService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+// check publishes an event for every subscriber that crossed a threshold since the
+// last check
+func (s *SubscriberOfflineService) check() {
+	if database.DB == nil {
+		return
+	}
+	now := time.Now()
+	since := s.lastCheck
+	s.lastCheck = now
+
+	var rules []models.CommunicationRule
+	if err := database.DB.Where("trigger_event = ? AND enabled = ?", EventSubscriberOffline, true).Find(&rules).Error; err != nil {
+		log.Printf("SubscriberOfflineService: Failed to query rules: %v", err)
+		return
+	}
+
+	seen := make(map[int]bool)
+	for _, rule := range rules {
+		hours := ruleOfflineHours(rule)
+		if seen[hours] {
+			continue
+		}
+		seen[hours] = true
+
+		threshold := time.Duration(hours) * time.Hour
+		var subscribers []models.Subscriber
+		if err := database.DB.Preload("Service").
+			Where("is_online = ? AND status = ? AND last_seen > ? AND last_seen <= ?",
+				false, models.SubscriberStatusActive, since.Add(-threshold), now.Add(-threshold)).
+			Find(&subscribers).Error; err != nil {
+			log.Printf("SubscriberOfflineService: Query failed: %v", err)
+			continue
+		}
+
+		for i := range subscribers {
+			data := map[string]interface{}{
+				"id":            subscribers[i].ID,
+				"subscriber_id": subscribers[i].ID,
+				"username":      subscribers[i].Username,
+				"offline_hours": hours,
+				"offline_since": subscribers[i].LastSeen,
+			}
+			events.Publish(EventSubscriberOffline, data)
+		}
+		if len(subscribers) > 0 {
+			log.Printf("SubscriberOfflineService: %d subscribers offline for %dh", len(subscribers), hours)
+		}
+	}
+}
@@ -55,6 +55,9 @@ type SMSConfig struct {
 	CustomHeaders map[string]string
 	CustomBody    string // Template with {{to}}, {{message}} placeholders
 	CustomParams  string // URL params template for GET requests
+	CustomSender  string // Value of the {{sender}} placeholder
+	// Delivery receipt callback passed to Twilio and Vonage
+	DLRURL string
 }
 
 // GetConfig retrieves SMS configuration from database
@@ -103,34 +106,36 @@ func (s *SMSService) GetConfig() (*SMSConfig, error) {
 	return config, nil
 }
 
-// SendSMS sends an SMS message
+// SendSMS sends an SMS message through the gateway failover chain
 func (s *SMSService) SendSMS(to, message string) error {
-	config, err := s.GetConfig()
-	if err != nil {
-		return err
-	}
-
-	return s.SendSMSWithConfig(config, to, message)
+	_, err := s.Send(SMSMessage{To: to, Text: message})
+	return err
 }
 
 // SendSMSWithConfig sends an SMS with specific config
 func (s *SMSService) SendSMSWithConfig(config *SMSConfig, to, message string) error {
+	_, err := s.sendWithConfig(config, to, message)
+	return err
+}
+
+// sendWithConfig sends an SMS with specific config and returns the provider's message ID
+func (s *SMSService) sendWithConfig(config *SMSConfig, to, message string) (string, error) {
 	switch config.Provider {
 	case SMSProviderTwilio:
 		return s.sendViaTwilio(config, to, message)
 	case SMSProviderVonage:
 		return s.sendViaVonage(config, to, message)
 	case SMSProviderCustom:
-		return s.sendViaCustom(config, to, message)
+		return "", s.sendViaCustom(config, to, message)
 	default:
-		return fmt.Errorf("unsupported SMS provider: %s", config.Provider)
+		return "", fmt.Errorf("unsupported SMS provider: %s", config.Provider)
 	}
 }
 
 // sendViaTwilio sends SMS via Twilio
-func (s *SMSService) sendViaTwilio(config *SMSConfig, to, message string) error {
+func (s *SMSService) sendViaTwilio(config *SMSConfig, to, message string) (string, error) {
 	if config.TwilioSID == "" || config.TwilioToken == "" {
-		return fmt.Errorf("Twilio credentials not configured")
+		return "", fmt.Errorf("Twilio credentials not configured")
 	}
 
 	apiURL := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", config.TwilioSID)
@@ -139,10 +144,13 @@ func (s *SMSService) sendViaTwilio(config *SMSConfig, to, message string) error
 	data.Set("To", to)
 	data.Set("From", config.TwilioFrom)
 	data.Set("Body", message)
+	if config.DLRURL != "" {
+		data.Set("StatusCallback", config.DLRURL)
+	}
 
 	req, err := http.NewRequest("POST", apiURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return "", fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.SetBasicAuth(config.TwilioSID, config.TwilioToken)
@@ -150,22 +158,26 @@ func (s *SMSService) sendViaTwilio(config *SMSConfig, to, message string) error
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return "", fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("Twilio error (%d): %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("Twilio error (%d): %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	var twilioResp struct {
+		SID string `json:"sid"`
+	}
+	json.Unmarshal(body, &twilioResp)
+	return twilioResp.SID, nil
 }
 
 // sendViaVonage sends SMS via Vonage (Nexmo)
-func (s *SMSService) sendViaVonage(config *SMSConfig, to, message string) error {
+func (s *SMSService) sendViaVonage(config *SMSConfig, to, message string) (string, error) {
 	if config.VonageKey == "" || config.VonageSecret == "" {
-		return fmt.Errorf("Vonage credentials not configured")
+		return "", fmt.Errorf("Vonage credentials not configured")
 	}
 
 	apiURL := "https://rest.nexmo.com/sms/json"
@@ -177,45 +189,51 @@ func (s *SMSService) sendViaVonage(config *SMSConfig, to, message string) error
 		"from":       config.VonageFrom,
 		"text":       message,
 	}
+	if config.DLRURL != "" {
+		payload["callback"] = config.DLRURL
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
+		return "", fmt.Errorf("failed to marshal payload: %v", err)
 	}
 
 	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return "", fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %v", err)
+		return "", fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Vonage error (%d): %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("Vonage error (%d): %s", resp.StatusCode, string(body))
 	}
 
 	// Check Vonage response for errors
 	var vonageResp struct {
 		Messages []struct {
 			Status    string `json:"status"`
+			MessageID string `json:"message-id"`
 			ErrorText string `json:"error-text"`
 		} `json:"messages"`
 	}
-	if err := json.Unmarshal(body, &vonageResp); err == nil {
-		if len(vonageResp.Messages) > 0 && vonageResp.Messages[0].Status != "0" {
-			return fmt.Errorf("Vonage error: %s", vonageResp.Messages[0].ErrorText)
+	if err := json.Unmarshal(body, &vonageResp); err == nil && len(vonageResp.Messages) > 0 {
+		if vonageResp.Messages[0].Status != "0" {
+			return "", fmt.Errorf("Vonage error: %s", vonageResp.Messages[0].ErrorText)
 		}
+		// Long messages are split; the last part's receipt completes the message
+		return vonageResp.Messages[len(vonageResp.Messages)-1].MessageID, nil
 	}
 
-	return nil
+	return "", nil
 }
 
 // sendViaCustom sends SMS via custom API
@@ -232,15 +250,18 @@ func (s *SMSService) sendViaCustom(config *SMSConfig, to, message string) error
 	// Replace placeholders in URL
 	apiURL := strings.ReplaceAll(config.CustomURL, "{{to}}", url.QueryEscape(to))
 	apiURL = strings.ReplaceAll(apiURL, "{{message}}", url.QueryEscape(message))
+	apiURL = strings.ReplaceAll(apiURL, "{{sender}}", url.QueryEscape(config.CustomSender))
 
 	var reqBody io.Reader
 	if method == "POST" && config.CustomBody != "" {
 		body := strings.ReplaceAll(config.CustomBody, "{{to}}", to)
 		body = strings.ReplaceAll(body, "{{message}}", message)
+		body = strings.ReplaceAll(body, "{{sender}}", config.CustomSender)
 		reqBody = strings.NewReader(body)
 	} else if method == "GET" && config.CustomParams != "" {
 		params := strings.ReplaceAll(config.CustomParams, "{{to}}", url.QueryEscape(to))
 		params = strings.ReplaceAll(params, "{{message}}", url.QueryEscape(message))
+		params = strings.ReplaceAll(params, "{{sender}}", url.QueryEscape(config.CustomSender))
 		if !strings.Contains(apiURL, "?") {
 			apiURL += "?" + params
 		} else {
@@ -417,6 +417,29 @@ func (s *WhatsAppService) SendMessage(to, message string) error {
 	return s.SendMessageWithConfig(config, to, message)
 }
 
+// IsConfigured reports whether the admin WhatsApp account is set up for the selected provider
+func (s *WhatsAppService) IsConfigured() bool {
+	if s.getProvider() == "proxrad" {
+		_, err := s.GetProxRadConfig()
+		return err == nil
+	}
+	_, err := s.GetConfig()
+	return err == nil
+}
+
+// SendTransactionalMessage sends a message the subscriber asked for (e.g. a login code) through the
+// reseller's WhatsApp account when linked, otherwise the admin's. Notification opt-in does not apply.
+func (s *WhatsAppService) SendTransactionalMessage(sub models.Subscriber, to, message string) error {
+	if sub.ResellerID > 0 {
+		var reseller models.Reseller
+		if err := database.DB.First(&reseller, sub.ResellerID).Error; err == nil &&
+			reseller.WhatsAppEnabled && reseller.WhatsAppAccountUnique != "" {
+			return s.SendMessageWithAccountUnique(reseller.WhatsAppAccountUnique, to, message)
+		}
+	}
+	return s.SendMessage(to, message)
+}
+
 // SendMessageForSubscriber sends WhatsApp using the subscriber's reseller account if connected,
 // otherwise falls back to the admin's configured WhatsApp account.
 func (s *WhatsAppService) SendMessageForSubscriber(sub models.Subscriber, to, message string) error {
@@ -656,39 +679,64 @@ func (s *WhatsAppService) SendTemplateMessage(to string, template string, data m
 	return s.SendMessage(to, message)
 }
 
-// BulkSendMessage sends messages to multiple recipients
-func (s *WhatsAppService) BulkSendMessage(recipients []string, message string) ([]error, error) {
-	config, err := s.GetConfig()
-	if err != nil {
-		return nil, err
+// BulkSendMessage queues a message to multiple recipients from the admin account.
+// The outbox sends them at the WhatsApp rate limit; it returns the number queued.
+func (s *WhatsAppService) BulkSendMessage(recipients []string, message string) (int, error) {
+	if _, err := s.GetConfig(); err != nil {
+		return 0, err
 	}
 
-	errors := make([]error, len(recipients))
-	for i, to := range recipients {
-		errors[i] = s.SendMessageWithConfig(config, to, message)
-		// Add delay to stay within WhatsApp rate limit (1 message/second minimum)
-		time.Sleep(1000 * time.Millisecond)
+	queued := 0
+	for _, to := range recipients {
+		ok, err := QueueMessage(&models.OutboundMessage{
+			Channel:   "whatsapp",
+			Recipient: to,
+			Body:      message,
+			Sender:    models.OutboundSenderAdmin,
+			Source:    "bulk",
+		})
+		if err != nil {
+			return queued, err
+		}
+		if ok {
+			queued++
+		}
 	}
 
-	return errors, nil
+	return queued, nil
 }
 
 // UltramsgWebhookPayload represents incoming webhook from Ultramsg
 type UltramsgWebhookPayload struct {
+	EventType string `json:"event_type"` // Set from the envelope, e.g. message_received
 	ID        string `json:"id"`
-	From      string `json:"from"`
+	From      string `json:"from"` // e.g. 9647701234567@c.us, or ...@g.us for groups
 	To        string `json:"to"`
 	Body      string `json:"body"`
 	Type      string `json:"type"`
 	Timestamp string `json:"timestamp"`
 	Ack       string `json:"ack"`
+	FromMe    bool   `json:"fromMe"`
 }
 
-// ParseWebhook parses incoming webhook payload
+// ParseWebhook parses incoming webhook payload. Ultramsg wraps the message in
+// {"event_type": ..., "data": {...}}; a bare message is accepted too.
 func (s *WhatsAppService) ParseWebhook(body []byte) (*UltramsgWebhookPayload, error) {
+	var envelope struct {
+		EventType string          `json:"event_type"`
+		Data      json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook: %v", err)
+	}
+	if len(envelope.Data) > 0 && envelope.Data[0] == '{' {
+		body = envelope.Data
+	}
+
 	var payload UltramsgWebhookPayload
 	if err := json.Unmarshal(body, &payload); err != nil {
 		return nil, fmt.Errorf("failed to parse webhook: %v", err)
 	}
+	payload.EventType = envelope.EventType
 	return &payload, nil
 }
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/webhook"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxChannels are the channels the outbox delivers, each with its own worker and rate
+var OutboxChannels = []string{"sms", "whatsapp", "email"}
+
+// minOutboxRetentionDays keeps sent messages, and with them their dedup keys, for at
+// least a full month so monthly notifications (keyed by YYYY-MM) are not sent twice
+const minOutboxRetentionDays = 31
+
+// outboxDefaultRates are messages per minute per channel when outbox_rate_<channel> is unset
+var outboxDefaultRates = map[string]int{"sms": 60, "whatsapp": 20, "email": 60}
+
+// QueueMessage adds a message to the outbox. A message whose DedupKey was queued before
+// is dropped and queued is false. Unset fields get their defaults: the recipient's
+// timezone (the subscriber's, else the system's), max attempts and a first attempt now,
+// or at the end of quiet hours.
+func QueueMessage(msg *models.OutboundMessage) (bool, error) {
+	if msg.Recipient == "" {
+		return false, fmt.Errorf("no recipient")
+	}
+	if msg.DedupKey != nil && *msg.DedupKey == "" {
+		msg.DedupKey = nil
+	}
+	if msg.Timezone == "" && msg.SubscriberID != nil {
+		database.DB.Model(&models.Subscriber{}).Where("id = ?", *msg.SubscriberID).Pluck("timezone", &msg.Timezone)
+	}
+	if msg.Timezone == "" {
+		msg.Timezone = getConfiguredTimezone().String()
+	}
+	if msg.MaxAttempts <= 0 {
+		msg.MaxAttempts = getIntPreference("outbox_max_attempts", 6)
+	}
+	msg.Status = models.OutboundStatusPending
+	msg.NextAttemptAt = time.Now()
+	if !msg.Urgent {
+		if until, quiet := quietHoursUntil(msg.NextAttemptAt, msg.Timezone); quiet {
+			msg.NextAttemptAt = until
+		}
+	}
+
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(msg)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DedupKey builds an outbox deduplication key from its parts, e.g.
+// DedupKey("rule", 3, "sub", 42, "2026-05-01")
+func DedupKey(parts ...interface{}) *string {
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = fmt.Sprint(p)
+	}
+	key := strings.Join(s, ":")
+	return &key
+}
+
+// quietHoursUntil reports whether t falls in the quiet hours (quiet_hours_start to
+// quiet_hours_end, HH:MM, in the recipient's timezone) and when they end
+func quietHoursUntil(t time.Time, timezone string) (time.Time, bool) {
+	startHour, startMinute, ok := parseClock(getStringPreference("quiet_hours_start", ""))
+	if !ok {
+		return t, false
+	}
+	endHour, endMinute, ok := parseClock(getStringPreference("quiet_hours_end", ""))
+	if !ok {
+		return t, false
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		loc = getConfiguredTimezone()
+	}
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), startHour, startMinute, 0, 0, loc)
+	end := time.Date(local.Year(), local.Month(), local.Day(), endHour, endMinute, 0, 0, loc)
+
+	switch {
+	case !start.Before(end) && !end.Before(start):
+		return t, false // Empty window
+	case start.Before(end):
+		// Same-day window, e.g. 13:00-15:00
+		if !local.Before(start) && local.Before(end) {
+			return end, true
+		}
+	default:
+		// Window across midnight, e.g. 22:00-08:00
+		if !local.Before(start) {
+			return end.AddDate(0, 0, 1), true
+		}
+		if local.Before(end) {
+			return end, true
+		}
+	}
+	return t, false
+}
+
+func parseClock(value string) (int, int, bool) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(value), "%d:%d", &hour, &minute); err != nil {
+		return 0, 0, false
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// deliverOutbound sends a message through its channel. The receipt is set for SMS;
+// resellers' messages go out with their sender ID.
+func deliverOutbound(msg *models.OutboundMessage) (*SMSReceipt, error) {
+	switch msg.Channel {
+	case "sms":
+		sms := SMSMessage{To: msg.Recipient, Text: msg.Body}
+		if msg.ResellerID != nil && *msg.ResellerID != 0 {
+			database.DB.Model(&models.Reseller{}).Where("id = ?", *msg.ResellerID).Pluck("sms_sender_id", &sms.SenderID)
+		}
+		return NewSMSService().Send(sms)
+	case "email":
+		return nil, NewEmailService().SendEmail(msg.Recipient, msg.Subject, msg.Body, msg.IsHTML)
+	case "whatsapp":
+		wa := NewWhatsAppService()
+		switch {
+		case msg.Sender == models.OutboundSenderAdmin:
+			return nil, wa.SendMessage(msg.Recipient, msg.Body)
+		case msg.Sender != "":
+			return nil, wa.SendMessageWithAccountUnique(msg.Sender, msg.Recipient, msg.Body)
+		case msg.SubscriberID != nil:
+			var sub models.Subscriber
+			if err := database.DB.Preload("Reseller").First(&sub, *msg.SubscriberID).Error; err != nil {
+				return nil, fmt.Errorf("subscriber %d not found", *msg.SubscriberID)
+			}
+			return nil, wa.SendMessageForSubscriber(sub, msg.Recipient, msg.Body)
+		default:
+			return nil, wa.SendMessage(msg.Recipient, msg.Body)
+		}
+	}
+	return nil, fmt.Errorf("unknown channel %s", msg.Channel)
+}
+
+// OutboxService drains the outbox with one worker per channel, each sending at most
+// outbox_rate_<channel> messages a minute. Failures are retried with exponential
+// backoff until the message's MaxAttempts; messages due in the recipient's quiet hours
+// wait until they end.
+type OutboxService struct {
+	pollInterval time.Duration
+	lastPrune    time.Time
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+	isRunning    bool
+}
+
+// NewOutboxService creates a new outbox service
+func NewOutboxService(pollInterval time.Duration) *OutboxService {
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	return &OutboxService{
+		pollInterval: pollInterval,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start begins the outbox workers
+func (s *OutboxService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	for _, channel := range OutboxChannels {
+		s.wg.Add(1)
+		go s.work(channel)
+	}
+	s.wg.Add(1)
+	go s.housekeeping()
+
+	log.Printf("OutboxService started (poll interval: %v)", s.pollInterval)
+}
+
+// Stop stops the outbox workers; a message being sent is finished first
+func (s *OutboxService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("OutboxService stopped")
+}
+
+// wait sleeps for d and reports whether the service is still running
+func (s *OutboxService) wait(d time.Duration) bool {
+	select {
+	case <-s.stopChan:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// work sends one channel's due messages, one at a time at the channel's rate
+func (s *OutboxService) work(channel string) {
+	defer s.wg.Done()
+
+	for {
+		if database.DB == nil {
+			if !s.wait(s.pollInterval) {
+				return
+			}
+			continue
+		}
+
+		msg := s.claim(channel)
+		if msg == nil {
+			if !s.wait(s.pollInterval) {
+				return
+			}
+			continue
+		}
+
+		if !msg.Urgent {
+			if until, quiet := quietHoursUntil(time.Now(), msg.Timezone); quiet {
+				database.DB.Model(msg).Update("next_attempt_at", until)
+				continue
+			}
+		}
+
+		s.send(msg)
+
+		rate := getIntPreference("outbox_rate_"+channel, outboxDefaultRates[channel])
+		if !s.wait(time.Minute / time.Duration(rate)) {
+			return
+		}
+	}
+}
+
+// claim takes the next due message of a channel. Claiming pushes next_attempt_at
+// forward under SKIP LOCKED so cluster nodes never send the same message twice.
+func (s *OutboxService) claim(channel string) *models.OutboundMessage {
+	now := time.Now()
+	var ids []uint
+	if err := database.DB.Raw(`
+		UPDATE outbound_messages SET next_attempt_at = ?
+		WHERE id = (
+			SELECT id FROM outbound_messages
+			WHERE channel = ? AND status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`, now.Add(5*time.Minute), channel, models.OutboundStatusPending, now).
+		Scan(&ids).Error; err != nil {
+		log.Printf("Outbox: Failed to claim %s message: %v", channel, err)
+		return nil
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var msg models.OutboundMessage
+	if err := database.DB.First(&msg, ids[0]).Error; err != nil {
+		return nil
+	}
+	return &msg
+}
+
+// send delivers a claimed message and records the outcome
+func (s *OutboxService) send(msg *models.OutboundMessage) {
+	now := time.Now()
+	receipt, err := deliverOutbound(msg)
+
+	updates := map[string]interface{}{
+		"attempts":        msg.Attempts + 1,
+		"last_attempt_at": now,
+	}
+	switch {
+	case err == nil:
+		updates["status"] = models.OutboundStatusSent
+		updates["sent_at"] = now
+		updates["last_error"] = ""
+	case msg.Attempts+1 >= msg.MaxAttempts:
+		updates["status"] = models.OutboundStatusFailed
+		updates["last_error"] = err.Error()
+		log.Printf("Outbox: %s to %s failed after %d attempts: %v", msg.Channel, msg.Recipient, msg.Attempts+1, err)
+	default:
+		updates["next_attempt_at"] = now.Add(webhook.RetryDelay(msg.Attempts + 1))
+		updates["last_error"] = err.Error()
+	}
+	database.DB.Model(msg).Updates(updates)
+
+	// The communication log shows the final outcome only
+	if err == nil || msg.Attempts+1 >= msg.MaxAttempts {
+		logOutbound(msg, receipt, err, now)
+	}
+}
+
+func logOutbound(msg *models.OutboundMessage, receipt *SMSReceipt, sendErr error, at time.Time) {
+	commLog := models.CommunicationLog{
+		Type:         msg.Channel,
+		Recipient:    msg.Recipient,
+		Subject:      msg.Subject,
+		Message:      msg.Body,
+		Status:       "sent",
+		SubscriberID: msg.SubscriberID,
+		ResellerID:   msg.ResellerID,
+		RuleID:       msg.RuleID,
+		CreatedAt:    at,
+		SentAt:       &at,
+	}
+	if receipt != nil {
+		commLog.GatewayID = receipt.GatewayID
+		commLog.ProviderID = receipt.MessageID
+	}
+	if sendErr != nil {
+		commLog.Status = "failed"
+		commLog.ErrorMessage = sendErr.Error()
+		if len(commLog.ErrorMessage) > 500 {
+			commLog.ErrorMessage = commLog.ErrorMessage[:500]
+		}
+	}
+	database.DB.Create(&commLog)
+}
+
+// housekeeping deletes sent and cancelled messages past the retention period, once a
+// day. Failed messages are kept until resent or cancelled.
+func (s *OutboxService) housekeeping() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			if database.DB == nil || time.Since(s.lastPrune) < 24*time.Hour {
+				continue
+			}
+			s.lastPrune = time.Now()
+
+			days := getIntPreference("outbox_retention_days", minOutboxRetentionDays)
+			if days < minOutboxRetentionDays {
+				days = minOutboxRetentionDays
+			}
+			cutoff := time.Now().AddDate(0, 0, -days)
+			result := database.DB.Where("status IN ? AND created_at < ?",
+				[]string{models.OutboundStatusSent, models.OutboundStatusCancelled}, cutoff).
+				Delete(&models.OutboundMessage{})
+			if result.RowsAffected > 0 {
+				log.Printf("Outbox: Pruned %d messages older than %d days", result.RowsAffected, days)
+			}
+		}
+	}
+}
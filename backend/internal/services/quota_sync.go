@@ -13,6 +13,7 @@ import (
 	"github.com/proisp/backend/internal/mikrotik"
 	"github.com/proisp/backend/internal/models"
 	"github.com/proisp/backend/internal/radius"
+	"github.com/proisp/backend/internal/webhook"
 )
 
 // getConfiguredTimezone returns the system timezone from settings
@@ -1276,6 +1277,14 @@ func (s *QuotaSyncService) checkAndEnforceFUP(client *mikrotik.Client, nas *mode
 			}
 			go fireFUPAppliedRules(sub, targetFUPLevel, quotaUsed, quotaTotal)
 		}
+		webhook.EmitSubscriber(models.WebhookEventFUPChanged, sub, map[string]interface{}{
+			"previous_fup_level": oldEffectiveLevel,
+			"fup_level":          targetFUPLevel,
+			"fup_source":         fupSource,
+			"rate_limit":         fupRateLimit,
+			"daily_used":         dailyUsed,
+			"monthly_used":       monthlyUsed,
+		})
 		} else if targetFUPLevel == 0 && oldEffectiveLevel > 0 {
 			// Restore original speed (both daily and monthly FUP cleared)
 			log.Printf("FUP: %s all FUP cleared, restoring original speed", sub.Username)
@@ -1317,6 +1326,13 @@ func (s *QuotaSyncService) checkAndEnforceFUP(client *mikrotik.Client, nas *mode
 				log.Printf("FUP: Speed restore pending for %s - will apply on reconnect", sub.Username)
 			}
 			log.Printf("FUP: Restored speed for %s to %s", sub.Username, originalRateLimitK)
+			webhook.EmitSubscriber(models.WebhookEventFUPChanged, sub, map[string]interface{}{
+				"previous_fup_level": oldEffectiveLevel,
+				"fup_level":          0,
+				"rate_limit":         originalRateLimitK,
+				"daily_used":         dailyUsed,
+				"monthly_used":       monthlyUsed,
+			})
 		}
 	} else if targetFUPLevel > 0 && fupDownload > 0 && targetFUPLevel == oldEffectiveLevel {
 		// FUP level unchanged but verify radreply still has the correct FUP speed
@@ -1896,23 +1912,17 @@ func fireFUPAppliedRules(sub *models.Subscriber, fupLevel int, quotaUsed, quotaT
 		return
 	}
 
-	// Prepare template variables
-	expiryDate := ""
-	if !sub.ExpiryDate.IsZero() {
-		expiryDate = sub.ExpiryDate.Format("2006-01-02")
-	}
-	serviceName := ""
-	if sub.Service != nil {
-		serviceName = sub.Service.Name
+	// Event template variables
+	data := map[string]interface{}{
+		"quota_used":  formatFUPQuota(quotaUsed),
+		"quota_total": formatFUPQuota(quotaTotal),
+		"fup_level":   fupLevel,
 	}
-	balance := fmt.Sprintf("%.2f", sub.Price)
-	quotaUsedStr := formatFUPQuota(quotaUsed)
-	quotaTotalStr := formatFUPQuota(quotaTotal)
 
 	levelStr := strconv.Itoa(fupLevel)
 
 	for _, rule := range rules {
-		if rule.Template == "" {
+		if rule.Template == "" || !RuleMatches(rule, sub) {
 			continue
 		}
 
@@ -1922,52 +1932,42 @@ func fireFUPAppliedRules(sub *models.Subscriber, fupLevel int, quotaUsed, quotaT
 			continue
 		}
 
-		msg := rule.Template
-		msg = strings.ReplaceAll(msg, "{username}", sub.Username)
-		msg = strings.ReplaceAll(msg, "{full_name}", sub.FullName)
-		msg = strings.ReplaceAll(msg, "{expiry_date}", expiryDate)
-		msg = strings.ReplaceAll(msg, "{service_name}", serviceName)
-		msg = strings.ReplaceAll(msg, "{balance}", balance)
-		msg = strings.ReplaceAll(msg, "{quota_used}", quotaUsedStr)
-		msg = strings.ReplaceAll(msg, "{quota_total}", quotaTotalStr)
-		msg = strings.ReplaceAll(msg, "{fup_level}", fmt.Sprintf("%d", fupLevel))
+		ruleSubject, msg, err := RenderRule(rule, sub, data)
+		if err != nil {
+			log.Printf("FUPRule[%s]: %v", rule.Name, err)
+			continue
+		}
 
+		outbound := &models.OutboundMessage{
+			Channel:      rule.Channel,
+			Body:         msg,
+			Source:       "rule",
+			SubscriberID: &sub.ID,
+			ResellerID:   &sub.ResellerID,
+			RuleID:       &rule.ID,
+		}
 		switch rule.Channel {
-		case "whatsapp":
-			if sub.Phone != "" {
-				wa := NewWhatsAppService()
-				if err := wa.SendMessageForSubscriber(*sub, sub.Phone, msg); err != nil {
-					log.Printf("FUPRule[%s]: WhatsApp send failed for %s: %v", rule.Name, sub.Username, err)
-				} else {
-					log.Printf("FUPRule[%s]: WhatsApp sent to %s", rule.Name, sub.Username)
-				}
-			}
-		case "sms":
-			if sub.Phone != "" {
-				sms := NewSMSService()
-				if err := sms.SendSMS(sub.Phone, msg); err != nil {
-					log.Printf("FUPRule[%s]: SMS send failed for %s: %v", rule.Name, sub.Username, err)
-				} else {
-					log.Printf("FUPRule[%s]: SMS sent to %s", rule.Name, sub.Username)
-				}
-			}
+		case "whatsapp", "sms":
+			outbound.Recipient = sub.Phone
 		case "email":
-			if sub.Email != "" {
-				emailSvc := NewEmailService()
-				subject := fmt.Sprintf("FUP Level %d Applied - %s", fupLevel, sub.Username)
-				if err := emailSvc.SendEmail(sub.Email, subject, msg, false); err != nil {
-					log.Printf("FUPRule[%s]: Email send failed for %s: %v", rule.Name, sub.Username, err)
-				} else {
-					log.Printf("FUPRule[%s]: Email sent to %s", rule.Name, sub.Username)
-				}
+			outbound.Recipient = sub.Email
+			outbound.Subject = fmt.Sprintf("FUP Level %d Applied - %s", fupLevel, sub.Username)
+			if ruleSubject != "" {
+				outbound.Subject = ruleSubject
 			}
 		}
+		if outbound.Recipient == "" {
+			continue
+		}
+		if _, err := QueueMessage(outbound); err != nil {
+			log.Printf("FUPRule[%s]: Failed to queue %s for %s: %v", rule.Name, rule.Channel, sub.Username, err)
+		}
 	}
 }
 
 // fireQuotaWarningRules sends notifications when a subscriber's monthly quota reaches a threshold.
 // The rule's days_before field is repurposed as the threshold percentage (e.g. 80 = fire at 80% used).
-// Deduplication: only fires once per rule per subscriber per month.
+// Deduplication: the outbox dedup key holds the month, so it fires once per rule per subscriber per month.
 func fireQuotaWarningRules(sub models.Subscriber, monthlyUsed int64) {
 	if sub.Service == nil {
 		return
@@ -1997,7 +1997,7 @@ func fireQuotaWarningRules(sub models.Subscriber, monthlyUsed int64) {
 
 	now := getNow()
 	for _, rule := range rules {
-		if rule.Template == "" {
+		if rule.Template == "" || !RuleMatches(rule, &sub) {
 			continue
 		}
 		threshold := int64(rule.DaysBefore) // days_before repurposed as threshold %
@@ -2007,91 +2007,49 @@ func fireQuotaWarningRules(sub models.Subscriber, monthlyUsed int64) {
 		if usedPercent < threshold {
 			continue
 		}
-		// Per-month dedup: only send once per rule per subscriber this month
-		if quotaWarningAlreadySentThisMonth(sub.ID, rule.ID, now) {
+		ruleSubject, msg, err := RenderRule(rule, &sub, map[string]interface{}{
+			"quota_used":    formatFUPQuota(monthlyUsed),
+			"quota_total":   formatFUPQuota(quotaTotal),
+			"quota_percent": usedPercent,
+		})
+		if err != nil {
+			log.Printf("QuotaWarning[%s]: %v", rule.Name, err)
 			continue
 		}
-		log.Printf("QuotaWarning[%s]: %s used %d%% of monthly quota (threshold=%d%%), sending notification",
-			rule.Name, sub.Username, usedPercent, threshold)
 
-		serviceName := sub.Service.Name
-		balance := fmt.Sprintf("%.2f", sub.Price)
-		usedStr := formatFUPQuota(monthlyUsed)
-		totalStr := formatFUPQuota(quotaTotal)
-
-		msg := rule.Template
-		msg = strings.ReplaceAll(msg, "{username}", sub.Username)
-		msg = strings.ReplaceAll(msg, "{full_name}", sub.FullName)
-		msg = strings.ReplaceAll(msg, "{service_name}", serviceName)
-		msg = strings.ReplaceAll(msg, "{balance}", balance)
-		msg = strings.ReplaceAll(msg, "{quota_used}", usedStr)
-		msg = strings.ReplaceAll(msg, "{quota_total}", totalStr)
-		msg = strings.ReplaceAll(msg, "{quota_percent}", fmt.Sprintf("%d", usedPercent))
-
-		sent := false
-		errMsg := ""
-
-		phone := sub.Phone
-		if rule.SendToReseller && sub.Reseller != nil && sub.Reseller.User != nil && sub.Reseller.User.Phone != "" {
-			phone = sub.Reseller.User.Phone
+		outbound := &models.OutboundMessage{
+			Channel:      rule.Channel,
+			Body:         msg,
+			Source:       "rule",
+			DedupKey:     DedupKey("rule", rule.ID, "sub", sub.ID, now.Format("2006-01")),
+			SubscriberID: &sub.ID,
+			ResellerID:   &sub.ResellerID,
+			RuleID:       &rule.ID,
 		}
-		email := sub.Email
-		if rule.SendToReseller && sub.Reseller != nil && sub.Reseller.User != nil && sub.Reseller.User.Email != "" {
-			email = sub.Reseller.User.Email
-		}
-
 		switch rule.Channel {
-		case "whatsapp":
-			if phone != "" {
-				wa := NewWhatsAppService()
-				if err := wa.SendMessageForSubscriber(sub, phone, msg); err != nil {
-					log.Printf("QuotaWarning[%s]: WhatsApp failed for %s: %v", rule.Name, sub.Username, err)
-					errMsg = err.Error()
-				} else {
-					log.Printf("QuotaWarning[%s]: WhatsApp sent to %s", rule.Name, sub.Username)
-					sent = true
-				}
-			}
-		case "sms":
-			if phone != "" {
-				sms := NewSMSService()
-				if err := sms.SendSMS(phone, msg); err != nil {
-					log.Printf("QuotaWarning[%s]: SMS failed for %s: %v", rule.Name, sub.Username, err)
-					errMsg = err.Error()
-				} else {
-					log.Printf("QuotaWarning[%s]: SMS sent to %s", rule.Name, sub.Username)
-					sent = true
-				}
-			}
+		case "whatsapp", "sms":
+			outbound.Recipient = getNotifPhone(rule, sub)
 		case "email":
-			if email != "" {
-				emailSvc := NewEmailService()
-				subject := fmt.Sprintf("Quota Warning (%d%% used) - %s", usedPercent, sub.Username)
-				if err := emailSvc.SendEmail(email, subject, msg, false); err != nil {
-					log.Printf("QuotaWarning[%s]: Email failed for %s: %v", rule.Name, sub.Username, err)
-					errMsg = err.Error()
-				} else {
-					log.Printf("QuotaWarning[%s]: Email sent to %s", rule.Name, sub.Username)
-					sent = true
-				}
+			outbound.Recipient = getNotifEmail(rule, sub)
+			outbound.Subject = fmt.Sprintf("Quota Warning (%d%% used) - %s", usedPercent, sub.Username)
+			if ruleSubject != "" {
+				outbound.Subject = ruleSubject
 			}
 		}
+		if outbound.Recipient == "" {
+			continue
+		}
 
-		// Log for per-month dedup
-		logDailyNotif(rule, sub, msg, sent, errMsg)
+		queued, err := QueueMessage(outbound)
+		if err != nil {
+			log.Printf("QuotaWarning[%s]: Failed to queue %s for %s: %v", rule.Name, rule.Channel, sub.Username, err)
+		} else if queued {
+			log.Printf("QuotaWarning[%s]: %s used %d%% of monthly quota (threshold=%d%%), notification queued",
+				rule.Name, sub.Username, usedPercent, threshold)
+		}
 	}
 }
 
-// quotaWarningAlreadySentThisMonth returns true if quota_warning was already sent for this subscriber+rule this month.
-func quotaWarningAlreadySentThisMonth(subscriberID, ruleID uint, now time.Time) bool {
-	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	var count int64
-	database.DB.Model(&models.CommunicationLog{}).
-		Where("subscriber_id = ? AND rule_id = ? AND created_at >= ?", subscriberID, ruleID, startOfMonth).
-		Count(&count)
-	return count > 0
-}
-
 // formatFUPQuota formats a byte count as a human-readable string.
 func formatFUPQuota(bytes int64) string {
 	if bytes <= 0 {
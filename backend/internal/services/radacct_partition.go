@@ -0,0 +1,362 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"gorm.io/gorm"
+)
+
+// radacct is range partitioned by month on acctstarttime. Partitions are named
+// radacct_pYYYYMM; radacct_default catches rows outside every range.
+const (
+	radAcctPartitionPrefix = "radacct_p"
+	radAcctMonthsAhead     = 3 // Future months that always have a partition
+)
+
+// radAcctIndexes are created on the partitioned table and inherited by every partition.
+// The names match schema.sql so its CREATE INDEX IF NOT EXISTS statements stay no-ops.
+var radAcctIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_radacct_username ON radacct(username)",
+	"CREATE INDEX IF NOT EXISTS idx_radacct_start ON radacct(acctstarttime)",
+	"CREATE INDEX IF NOT EXISTS idx_radacct_stop ON radacct(acctstoptime)",
+	"CREATE INDEX IF NOT EXISTS idx_radacct_session ON radacct(acctsessionid, username)",
+	"CREATE INDEX IF NOT EXISTS idx_radacct_nasip ON radacct(nasipaddress)",
+	"CREATE INDEX IF NOT EXISTS idx_radacct_framedip ON radacct(framedipaddress)",
+	"CREATE INDEX IF NOT EXISTS idx_radacct_callingstationid ON radacct(callingstationid)",
+	// Active sessions index for QuotaSync
+	"CREATE INDEX IF NOT EXISTS idx_radacct_active ON radacct(username, acctstarttime) WHERE acctstoptime IS NULL",
+}
+
+// RadAcctPartitionService keeps the monthly radacct partitions: it creates partitions
+// ahead of time and detaches and drops whole months once they are past retention,
+// instead of deleting old rows one by one. Usage history outlives the raw rows in the
+// rollup tables (see RadAcctRollupService).
+type RadAcctPartitionService struct {
+	retentionDays int           // Days of raw accounting rows to keep
+	checkInterval time.Duration // How often to check partitions
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+}
+
+// NewRadAcctPartitionService creates a new partition maintenance service
+func NewRadAcctPartitionService(retentionDays int) *RadAcctPartitionService {
+	if retentionDays <= 0 {
+		retentionDays = 90 // Default: keep 90 days
+	}
+	return &RadAcctPartitionService{
+		retentionDays: retentionDays,
+		checkInterval: 24 * time.Hour, // Check daily
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the partition service
+func (s *RadAcctPartitionService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("RadAcctPartitionService started (retention: %d days, check interval: %v)",
+		s.retentionDays, s.checkInterval)
+}
+
+// Stop stops the partition service
+func (s *RadAcctPartitionService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("RadAcctPartitionService stopped")
+}
+
+func (s *RadAcctPartitionService) run() {
+	defer s.wg.Done()
+
+	// Make sure next months exist right away, the rest waits for 3 AM
+	if err := ensureRadAcctPartitions(database.DB, "radacct", time.Now(), time.Now()); err != nil {
+		log.Printf("RadAcctPartitionService: Failed to create partitions: %v", err)
+	}
+	s.scheduleFirstRun()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.maintain()
+		}
+	}
+}
+
+// scheduleFirstRun waits until 3 AM to run first maintenance
+func (s *RadAcctPartitionService) scheduleFirstRun() {
+	now := time.Now()
+	next3AM := time.Date(now.Year(), now.Month(), now.Day(), 3, 0, 0, 0, now.Location())
+	if now.After(next3AM) {
+		next3AM = next3AM.Add(24 * time.Hour)
+	}
+
+	log.Printf("RadAcctPartitionService: First run scheduled at %v", next3AM)
+	select {
+	case <-time.After(next3AM.Sub(now)):
+		s.maintain()
+	case <-s.stopChan:
+		return
+	}
+}
+
+// maintain creates upcoming partitions, drops expired ones and prunes hourly rollups
+func (s *RadAcctPartitionService) maintain() {
+	if database.DB == nil {
+		return
+	}
+
+	now := time.Now()
+	if err := ensureRadAcctPartitions(database.DB, "radacct", now, now); err != nil {
+		log.Printf("RadAcctPartitionService: Failed to create partitions: %v", err)
+	}
+
+	cutoff := now.AddDate(0, 0, -s.retentionDays)
+	dropped := s.dropExpiredPartitions(cutoff)
+
+	// Daily rollups are kept for good; hourly ones only as long as the raw rows
+	var pruned int64
+	for _, table := range []string{"subscriber_usage_hourly", "nas_usage_hourly"} {
+		result := database.DB.Exec("DELETE FROM "+table+" WHERE hour < ?", cutoff)
+		if result.Error != nil {
+			log.Printf("RadAcctPartitionService: Failed to prune %s: %v", table, result.Error)
+			continue
+		}
+		pruned += result.RowsAffected
+	}
+
+	log.Printf("RadAcctPartitionService: Maintenance complete (dropped partitions: %d, pruned hourly rollups: %d)", dropped, pruned)
+}
+
+// dropExpiredPartitions detaches and drops monthly partitions that end before cutoff
+func (s *RadAcctPartitionService) dropExpiredPartitions(cutoff time.Time) int {
+	var names []string
+	database.DB.Raw(`
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'radacct'::regclass
+		ORDER BY c.relname
+	`).Scan(&names)
+
+	dropped := 0
+	for _, name := range names {
+		if len(name) != len(radAcctPartitionPrefix)+6 {
+			continue // radacct_default
+		}
+		month, err := time.ParseInLocation("200601", name[len(radAcctPartitionPrefix):], time.Local)
+		if err != nil {
+			continue
+		}
+		if !month.AddDate(0, 1, 0).Before(cutoff) {
+			continue
+		}
+
+		// Long-lived sessions keep their month alive until they close, and rows are
+		// only dropped once the rollup service has counted them
+		var pending int64
+		database.DB.Raw(`SELECT COUNT(*) FROM ` + name + ` WHERE acctstoptime IS NULL OR rollup_at IS NULL
+			OR COALESCE(acctinputoctets, 0) <> COALESCE(rollup_input_octets, 0)
+			OR COALESCE(acctoutputoctets, 0) <> COALESCE(rollup_output_octets, 0)`).Scan(&pending)
+		if pending > 0 {
+			log.Printf("RadAcctPartitionService: Keeping %s, %d rows are still open or not rolled up", name, pending)
+			continue
+		}
+
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("ALTER TABLE radacct DETACH PARTITION " + name).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DROP TABLE " + name).Error
+		})
+		if err != nil {
+			log.Printf("RadAcctPartitionService: Failed to drop %s: %v", name, err)
+			continue
+		}
+		log.Printf("RadAcctPartitionService: Dropped partition %s", name)
+		dropped++
+	}
+	return dropped
+}
+
+// ensureRadAcctPartitions creates the monthly partitions of parent from the month of
+// from through radAcctMonthsAhead months after to, plus the default partition
+func ensureRadAcctPartitions(db *gorm.DB, parent string, from, to time.Time) error {
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.Local)
+	last := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, radAcctMonthsAhead, 0)
+
+	for !month.After(last) {
+		next := month.AddDate(0, 1, 0)
+		if err := createRadAcctPartition(db, parent, month, next); err != nil {
+			return err
+		}
+		month = next
+	}
+	return db.Exec("CREATE TABLE IF NOT EXISTS radacct_default PARTITION OF " + parent + " DEFAULT").Error
+}
+
+// createRadAcctPartition creates the partition of parent for one month. Postgres refuses
+// while radacct_default holds rows of that month (a NAS with a skewed clock can send
+// them early), so the default partition is detached, its rows for the month moved into
+// the new partition and then attached again, all in one transaction.
+func createRadAcctPartition(db *gorm.DB, parent string, from, to time.Time) error {
+	name := radAcctPartitionPrefix + from.Format("200601")
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		name, parent, from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	var exists, hasDefault bool
+	db.Raw("SELECT to_regclass(?) IS NOT NULL", name).Scan(&exists)
+	db.Raw("SELECT EXISTS (SELECT 1 FROM pg_inherits WHERE inhrelid = to_regclass('radacct_default') AND inhparent = to_regclass(?))", parent).Scan(&hasDefault)
+	if exists || !hasDefault {
+		return db.Exec(create).Error
+	}
+
+	var stray int64
+	db.Raw("SELECT COUNT(*) FROM radacct_default WHERE acctstarttime >= ? AND acctstarttime < ?", from, to).Scan(&stray)
+	if stray == 0 {
+		return db.Exec(create).Error
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		steps := []struct {
+			sql  string
+			args []interface{}
+		}{
+			{"ALTER TABLE " + parent + " DETACH PARTITION radacct_default", nil},
+			{create, nil},
+			{"INSERT INTO " + name + " SELECT * FROM radacct_default WHERE acctstarttime >= ? AND acctstarttime < ?", []interface{}{from, to}},
+			{"DELETE FROM radacct_default WHERE acctstarttime >= ? AND acctstarttime < ?", []interface{}{from, to}},
+			{"ALTER TABLE " + parent + " ATTACH PARTITION radacct_default DEFAULT", nil},
+		}
+		for _, step := range steps {
+			if err := tx.Exec(step.sql, step.args...).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		log.Printf("RadAcctPartitionService: Moved %d rows from radacct_default into %s", stray, name)
+	}
+	return err
+}
+
+// EnsureRadAcctPartitioned converts a plain radacct table (fresh installs and upgrades
+// create one from schema.sql) into the partitioned layout. It runs once, at startup:
+// existing rows and the legacy radacct_archive are rolled up first, then the rows are
+// copied into monthly partitions in a single transaction. RADIUS accounting writes wait
+// for the copy to finish.
+func EnsureRadAcctPartitioned() error {
+	if database.DB == nil {
+		return nil
+	}
+
+	var kind string
+	database.DB.Raw("SELECT relkind::text FROM pg_class WHERE oid = to_regclass('radacct')").Scan(&kind)
+	if kind != "r" {
+		return nil // already partitioned (or missing)
+	}
+
+	log.Println("RadAcct: Converting radacct to monthly partitions...")
+	if err := RollupRadAcct(nil); err != nil {
+		return fmt.Errorf("rollup before conversion: %w", err)
+	}
+	if err := rollupRadAcctArchive(); err != nil {
+		return fmt.Errorf("rollup of radacct_archive: %w", err)
+	}
+
+	var copied int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE radacct IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var sequence string
+		tx.Raw("SELECT COALESCE(pg_get_serial_sequence('radacct', 'radacctid'), '')").Scan(&sequence)
+
+		// The partition key is part of every unique key, so it cannot be NULL
+		if err := tx.Exec("UPDATE radacct SET acctstarttime = COALESCE(acctupdatetime, acctstoptime, NOW()) WHERE acctstarttime IS NULL").Error; err != nil {
+			return err
+		}
+
+		steps := []string{
+			"CREATE TABLE radacct_partitioned (LIKE radacct INCLUDING DEFAULTS) PARTITION BY RANGE (acctstarttime)",
+			"ALTER TABLE radacct_partitioned ALTER COLUMN acctstarttime SET NOT NULL",
+		}
+		for _, sql := range steps {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+
+		var oldest *time.Time
+		tx.Raw("SELECT MIN(acctstarttime) FROM radacct").Scan(&oldest)
+		from := time.Now()
+		if oldest != nil && oldest.Before(from) {
+			from = *oldest
+		}
+		if err := ensureRadAcctPartitions(tx, "radacct_partitioned", from, time.Now()); err != nil {
+			return err
+		}
+
+		result := tx.Exec("INSERT INTO radacct_partitioned SELECT * FROM radacct")
+		if result.Error != nil {
+			return result.Error
+		}
+		copied = result.RowsAffected
+
+		// The new table's radacctid default uses the old sequence; keep it when dropping
+		steps = nil
+		if sequence != "" {
+			steps = append(steps, "ALTER SEQUENCE "+sequence+" OWNED BY NONE")
+		}
+		steps = append(steps,
+			"DROP TABLE radacct",
+			"ALTER TABLE radacct_partitioned RENAME TO radacct",
+			"ALTER TABLE radacct ADD CONSTRAINT radacct_pkey PRIMARY KEY (radacctid, acctstarttime)",
+			"ALTER TABLE radacct ADD CONSTRAINT radacct_acctuniqueid_key UNIQUE (acctuniqueid, acctstarttime)",
+		)
+		if sequence != "" {
+			steps = append(steps, "ALTER SEQUENCE "+sequence+" OWNED BY radacct.radacctid")
+		}
+		steps = append(steps, radAcctIndexes...)
+		for _, sql := range steps {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("RadAcct: radacct is now partitioned by month (%d rows copied)", copied)
+	return nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+)
+
+// RadAcctRollupService keeps the hourly and daily usage tables (subscriber_usage_*,
+// nas_usage_*) up to date. Each run adds the growth of every radacct row since the
+// previous run, so reports never have to aggregate raw accounting rows.
+type RadAcctRollupService struct {
+	checkInterval time.Duration
+	since         *time.Time // Only rows active after this are examined; nil = all rows
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+}
+
+// NewRadAcctRollupService creates a new rollup service
+func NewRadAcctRollupService(interval time.Duration) *RadAcctRollupService {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &RadAcctRollupService{
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the rollup service
+func (s *RadAcctRollupService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	// Resume from the newest rolled-up hour; rows closed before it were counted already
+	var latest *time.Time
+	if database.DB != nil {
+		database.DB.Raw("SELECT MAX(hour) FROM subscriber_usage_hourly").Scan(&latest)
+	}
+	if latest != nil {
+		since := latest.Add(-time.Hour)
+		s.since = &since
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("RadAcctRollupService started (interval: %v)", s.checkInterval)
+}
+
+// Stop stops the rollup service
+func (s *RadAcctRollupService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("RadAcctRollupService stopped")
+}
+
+func (s *RadAcctRollupService) run() {
+	defer s.wg.Done()
+
+	s.rollup()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.rollup()
+		}
+	}
+}
+
+func (s *RadAcctRollupService) rollup() {
+	if database.DB == nil {
+		return
+	}
+
+	started := time.Now()
+	if err := RollupRadAcct(s.since); err != nil {
+		log.Printf("RadAcctRollupService: Rollup failed: %v", err)
+		return
+	}
+	// Closed rows only change in the run that sees them close, so the next run can skip
+	// everything that stopped before this one started (with a margin for clock skew)
+	since := started.Add(-10 * time.Minute)
+	s.since = &since
+}
+
+// rollupInserts adds the rows of the "deltas" CTE (username, nasipaddress, hour,
+// input_octets, output_octets, session_time, sessions) to the four rollup tables.
+// It completes a WITH statement whose earlier CTEs define deltas.
+const rollupInserts = `
+	subscriber_hourly AS (
+		INSERT INTO subscriber_usage_hourly AS t (hour, username, input_octets, output_octets, session_time, sessions)
+		SELECT hour, username, SUM(input_octets), SUM(output_octets), SUM(session_time), SUM(sessions)
+		FROM deltas GROUP BY hour, username
+		ON CONFLICT (hour, username) DO UPDATE SET
+			input_octets = t.input_octets + EXCLUDED.input_octets,
+			output_octets = t.output_octets + EXCLUDED.output_octets,
+			session_time = t.session_time + EXCLUDED.session_time,
+			sessions = t.sessions + EXCLUDED.sessions
+	),
+	subscriber_daily AS (
+		INSERT INTO subscriber_usage_daily AS t (day, username, input_octets, output_octets, session_time, sessions)
+		SELECT hour::date, username, SUM(input_octets), SUM(output_octets), SUM(session_time), SUM(sessions)
+		FROM deltas GROUP BY hour::date, username
+		ON CONFLICT (day, username) DO UPDATE SET
+			input_octets = t.input_octets + EXCLUDED.input_octets,
+			output_octets = t.output_octets + EXCLUDED.output_octets,
+			session_time = t.session_time + EXCLUDED.session_time,
+			sessions = t.sessions + EXCLUDED.sessions
+	),
+	nas_hourly AS (
+		INSERT INTO nas_usage_hourly AS t (hour, nasipaddress, input_octets, output_octets, session_time, sessions)
+		SELECT hour, nasipaddress, SUM(input_octets), SUM(output_octets), SUM(session_time), SUM(sessions)
+		FROM deltas GROUP BY hour, nasipaddress
+		ON CONFLICT (hour, nasipaddress) DO UPDATE SET
+			input_octets = t.input_octets + EXCLUDED.input_octets,
+			output_octets = t.output_octets + EXCLUDED.output_octets,
+			session_time = t.session_time + EXCLUDED.session_time,
+			sessions = t.sessions + EXCLUDED.sessions
+	)
+	INSERT INTO nas_usage_daily AS t (day, nasipaddress, input_octets, output_octets, session_time, sessions)
+	SELECT hour::date, nasipaddress, SUM(input_octets), SUM(output_octets), SUM(session_time), SUM(sessions)
+	FROM deltas GROUP BY hour::date, nasipaddress
+	ON CONFLICT (day, nasipaddress) DO UPDATE SET
+		input_octets = t.input_octets + EXCLUDED.input_octets,
+		output_octets = t.output_octets + EXCLUDED.output_octets,
+		session_time = t.session_time + EXCLUDED.session_time,
+		sessions = t.sessions + EXCLUDED.sessions
+`
+
+// RollupRadAcct adds the growth of every radacct row since it was last rolled up to the
+// usage tables and records the counted values on the row, in one statement. Growth is
+// attributed to the hour of the row's latest activity (stop, interim update or start).
+// Only rows still open or closed after since are examined; nil examines all rows.
+func RollupRadAcct(since *time.Time) error {
+	window := "TRUE"
+	var args []interface{}
+	if since != nil {
+		window = "(acctstoptime IS NULL OR acctstoptime >= ?)"
+		args = append(args, *since)
+	}
+
+	sql := `
+	WITH changed AS (
+		SELECT radacctid, acctstarttime, username, nasipaddress,
+			COALESCE(acctinputoctets, 0) AS input_octets,
+			COALESCE(acctoutputoctets, 0) AS output_octets,
+			COALESCE(acctsessiontime, 0) AS session_time,
+			COALESCE(rollup_input_octets, 0) AS rolled_input,
+			COALESCE(rollup_output_octets, 0) AS rolled_output,
+			COALESCE(rollup_session_time, 0) AS rolled_time,
+			rollup_at,
+			COALESCE(acctstoptime, acctupdatetime, acctstarttime) AS activity_at
+		FROM radacct
+		WHERE ` + window + `
+		AND (rollup_at IS NULL
+			OR COALESCE(acctinputoctets, 0) <> COALESCE(rollup_input_octets, 0)
+			OR COALESCE(acctoutputoctets, 0) <> COALESCE(rollup_output_octets, 0)
+			OR COALESCE(acctsessiontime, 0) <> COALESCE(rollup_session_time, 0))
+	),
+	marked AS (
+		UPDATE radacct r SET
+			rollup_input_octets = c.input_octets,
+			rollup_output_octets = c.output_octets,
+			rollup_session_time = c.session_time,
+			rollup_at = NOW()
+		FROM changed c
+		WHERE r.radacctid = c.radacctid AND r.acctstarttime = c.acctstarttime
+	),
+	deltas AS (
+		SELECT username, nasipaddress, date_trunc('hour', activity_at) AS hour,
+			-- Counters that went backwards (NAS reset) count from zero
+			CASE WHEN input_octets >= rolled_input THEN input_octets - rolled_input ELSE input_octets END AS input_octets,
+			CASE WHEN output_octets >= rolled_output THEN output_octets - rolled_output ELSE output_octets END AS output_octets,
+			CASE WHEN session_time >= rolled_time THEN session_time - rolled_time ELSE session_time END AS session_time,
+			CASE WHEN rollup_at IS NULL THEN 1 ELSE 0 END AS sessions
+		FROM changed
+		WHERE activity_at IS NOT NULL
+	),` + rollupInserts
+
+	return database.DB.Exec(sql, args...).Error
+}
+
+// rollupRadAcctArchive adds every row of the legacy radacct_archive table to the usage
+// tables. Archived rows never change, so this runs once, when radacct is partitioned.
+func rollupRadAcctArchive() error {
+	var exists bool
+	database.DB.Raw("SELECT to_regclass('radacct_archive') IS NOT NULL").Scan(&exists)
+	if !exists {
+		return nil
+	}
+
+	sql := `
+	WITH deltas AS (
+		SELECT COALESCE(username, '') AS username, COALESCE(nasipaddress, '') AS nasipaddress,
+			date_trunc('hour', COALESCE(acctstoptime, acctupdatetime, acctstarttime)) AS hour,
+			COALESCE(acctinputoctets, 0) AS input_octets,
+			COALESCE(acctoutputoctets, 0) AS output_octets,
+			COALESCE(acctsessiontime, 0) AS session_time,
+			1 AS sessions
+		FROM radacct_archive
+		WHERE COALESCE(acctstoptime, acctupdatetime, acctstarttime) IS NOT NULL
+	),` + rollupInserts
+
+	return database.DB.Exec(sql).Error
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/reports"
+)
+
+// ReportSchedulerService runs report definitions that are due, stores every rendered
+// file in report_runs and emails them to the definition's recipients. Stored runs are
+// purged after report_run_retention_days.
+type ReportSchedulerService struct {
+	checkInterval time.Duration
+	lastPurge     time.Time
+	stopChan      chan struct{}
+	wg            sync.WaitGroup
+	mu            sync.Mutex
+	isRunning     bool
+}
+
+// NewReportSchedulerService creates a new report scheduler service
+func NewReportSchedulerService(interval time.Duration) *ReportSchedulerService {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &ReportSchedulerService{
+		checkInterval: interval,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start begins the report scheduler service
+func (s *ReportSchedulerService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("ReportSchedulerService started (interval: %v)", s.checkInterval)
+}
+
+// Stop stops the report scheduler service
+func (s *ReportSchedulerService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("ReportSchedulerService stopped")
+}
+
+func (s *ReportSchedulerService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.runDue()
+			if time.Since(s.lastPurge) > 24*time.Hour {
+				s.purge()
+				s.lastPurge = time.Now()
+			}
+		}
+	}
+}
+
+func (s *ReportSchedulerService) runDue() {
+	if database.DB == nil {
+		return
+	}
+
+	now := time.Now()
+	var due []models.ReportDefinition
+	database.DB.Where("is_active = ? AND schedule <> ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, "none", now).
+		Order("next_run_at").Find(&due)
+
+	for i := range due {
+		def := &due[i]
+		if _, err := RunReportDefinition(def, "schedule", 0, true); err != nil {
+			log.Printf("ReportSchedulerService: Report %q failed: %v", def.Name, err)
+		}
+
+		// Schedule from now rather than from the missed slot so a long outage does not
+		// send a burst of reports
+		database.DB.Model(def).Updates(map[string]interface{}{
+			"last_run_at": now,
+			"next_run_at": NextReportRun(def, now),
+		})
+	}
+}
+
+func (s *ReportSchedulerService) purge() {
+	if database.DB == nil {
+		return
+	}
+	days := getIntPreference("report_run_retention_days", 90)
+	if days <= 0 {
+		return
+	}
+	result := database.DB.Where("created_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&models.ReportRun{})
+	if result.Error != nil {
+		log.Printf("ReportSchedulerService: Failed to purge report runs: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("ReportSchedulerService: Purged %d report runs older than %d days", result.RowsAffected, days)
+	}
+}
+
+// NextReportRun returns the first slot of the definition's schedule after the given
+// time, or nil for unscheduled definitions. Weekly runs use ScheduleDay as the weekday
+// (0 = Sunday), monthly runs as the day of the month (capped at 28).
+func NextReportRun(def *models.ReportDefinition, after time.Time) *time.Time {
+	hour := def.ScheduleHour
+	if hour < 0 || hour > 23 {
+		hour = 0
+	}
+	next := time.Date(after.Year(), after.Month(), after.Day(), hour, 0, 0, 0, after.Location())
+
+	switch def.Schedule {
+	case "daily":
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+	case "weekly":
+		weekday := def.ScheduleDay % 7
+		if weekday < 0 {
+			weekday = 0
+		}
+		next = next.AddDate(0, 0, (weekday-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+	case "monthly":
+		day := def.ScheduleDay
+		if day < 1 {
+			day = 1
+		}
+		if day > 28 {
+			day = 28
+		}
+		next = time.Date(after.Year(), after.Month(), day, hour, 0, 0, 0, after.Location())
+		if !next.After(after) {
+			next = next.AddDate(0, 1, 0)
+		}
+	default:
+		return nil
+	}
+	return &next
+}
+
+// ReportParams resolves a definition's date range and filters relative to now
+func ReportParams(def *models.ReportDefinition, now time.Time) (reports.Params, error) {
+	var p reports.Params
+	if def.DateRange == "custom" {
+		if def.DateFrom != nil {
+			p.From = *def.DateFrom
+		}
+		if def.DateTo != nil {
+			p.To = *def.DateTo
+		}
+	} else if def.DateRange != "all" {
+		from, to, err := reports.Range(def.DateRange, now)
+		if err != nil {
+			return p, err
+		}
+		p.From, p.To = from, to
+	}
+	if def.ResellerID != nil {
+		p.ResellerID = *def.ResellerID
+	}
+	if def.ServiceID != nil {
+		p.ServiceID = *def.ServiceID
+	}
+	if def.NasID != nil {
+		p.NasID = *def.NasID
+	}
+	return p, nil
+}
+
+// RunReportDefinition builds a definition's report, stores one run per format and, when
+// sendEmail is set, emails the files to its recipients. On failure a failed run is
+// recorded so the history shows why a scheduled report did not arrive.
+func RunReportDefinition(def *models.ReportDefinition, source string, userID uint, sendEmail bool) ([]models.ReportRun, error) {
+	now := time.Now()
+	defID := def.ID
+	failed := func(err error) ([]models.ReportRun, error) {
+		run := models.ReportRun{DefinitionID: &defID, ReportType: def.ReportType, Status: "failed",
+			Error: err.Error(), Source: source, CreatedBy: userID}
+		database.DB.Create(&run)
+		return []models.ReportRun{run}, err
+	}
+
+	params, err := ReportParams(def, now)
+	if err != nil {
+		return failed(err)
+	}
+	table, err := reports.Build(def.ReportType, params)
+	if err != nil {
+		return failed(err)
+	}
+	paramsJSON, _ := json.Marshal(params)
+
+	var runs []models.ReportRun
+	var attachments []EmailAttachment
+	for _, format := range strings.Split(def.Formats, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" {
+			continue
+		}
+		content, contentType, err := reports.Render(table, format)
+		if err != nil {
+			return failed(err)
+		}
+		run := models.ReportRun{
+			DefinitionID: &defID,
+			ReportType:   def.ReportType,
+			Format:       format,
+			Params:       string(paramsJSON),
+			FileName:     fmt.Sprintf("%s_%s.%s", def.ReportType, now.Format("20060102_1504"), format),
+			Content:      content,
+			Size:         len(content),
+			RowCount:     len(table.Rows),
+			Status:       "completed",
+			Source:       source,
+			CreatedBy:    userID,
+		}
+		runs = append(runs, run)
+		attachments = append(attachments, EmailAttachment{FileName: run.FileName, ContentType: contentType, Content: content})
+	}
+
+	var sent []string
+	if sendEmail && len(attachments) > 0 {
+		subject := fmt.Sprintf("%s - %s", def.Name, table.Title)
+		body := fmt.Sprintf("%s\n%s\n\n%d rows, attached as %s.\n", table.Title, table.Subtitle, len(table.Rows), def.Formats)
+		email := NewEmailService()
+		for _, to := range strings.Split(def.Recipients, ",") {
+			to = strings.TrimSpace(to)
+			if to == "" {
+				continue
+			}
+			if err := email.SendEmailWithAttachments(to, subject, body, false, attachments); err != nil {
+				log.Printf("ReportSchedulerService: Failed to email report %q to %s: %v", def.Name, to, err)
+				continue
+			}
+			sent = append(sent, to)
+		}
+	}
+
+	for i := range runs {
+		runs[i].EmailedTo = strings.Join(sent, ", ")
+		if err := database.DB.Create(&runs[i]).Error; err != nil {
+			return runs, err
+		}
+	}
+	return runs, nil
+}
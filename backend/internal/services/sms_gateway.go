@@ -0,0 +1,276 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// SMSMessage is an SMS to send
+type SMSMessage struct {
+	To       string
+	Text     string
+	SenderID string // Replaces the gateway's sender where the gateway allows it
+}
+
+// SMSReceipt identifies a sent SMS so its delivery receipt can be matched
+type SMSReceipt struct {
+	GatewayID *uint // Nil when sent with the legacy single-provider settings
+	MessageID string
+}
+
+// Send sends an SMS through the active gateways in priority order until one accepts
+// it. Gateways in their failover cooldown are tried last rather than not at all.
+// Without gateways the legacy sms_provider settings are used.
+func (s *SMSService) Send(msg SMSMessage) (*SMSReceipt, error) {
+	var gateways []models.SMSGateway
+	database.DB.Where("is_active = ?", true).Order("priority, id").Find(&gateways)
+	if len(gateways) == 0 {
+		config, err := s.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+		id, err := s.sendWithConfig(config, msg.To, msg.Text)
+		if err != nil {
+			return nil, err
+		}
+		return &SMSReceipt{MessageID: id}, nil
+	}
+
+	now := time.Now()
+	ordered := make([]models.SMSGateway, 0, len(gateways))
+	var cooling []models.SMSGateway
+	for _, gw := range gateways {
+		if gw.Healthy(now) {
+			ordered = append(ordered, gw)
+		} else {
+			cooling = append(cooling, gw)
+		}
+	}
+	ordered = append(ordered, cooling...)
+
+	var errs []string
+	for i := range ordered {
+		gw := &ordered[i]
+		id, err := s.SendWithGateway(gw, msg)
+		if err == nil {
+			recordSMSGatewaySuccess(gw)
+			return &SMSReceipt{GatewayID: &gw.ID, MessageID: id}, nil
+		}
+		recordSMSGatewayFailure(gw, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", gw.Name, err))
+	}
+	return nil, fmt.Errorf("all SMS gateways failed: %s", strings.Join(errs, "; "))
+}
+
+// SendWithGateway sends an SMS through one gateway and returns the provider's
+// message ID, which is empty when the provider does not report one
+func (s *SMSService) SendWithGateway(gw *models.SMSGateway, msg SMSMessage) (string, error) {
+	settings := gw.Config()
+	sender := gw.SenderID
+	if msg.SenderID != "" && gw.AllowSenderOverride {
+		sender = msg.SenderID
+	}
+
+	switch gw.Provider {
+	case models.SMSGatewayTwilio, models.SMSGatewayVonage, models.SMSGatewayCustom:
+		config := &SMSConfig{
+			Provider:      SMSProvider(gw.Provider),
+			TwilioSID:     settings.TwilioSID,
+			TwilioToken:   settings.TwilioToken,
+			TwilioFrom:    settings.TwilioFrom,
+			VonageKey:     settings.VonageKey,
+			VonageSecret:  settings.VonageSecret,
+			VonageFrom:    settings.VonageFrom,
+			CustomURL:     settings.URL,
+			CustomMethod:  settings.Method,
+			CustomHeaders: settings.Headers,
+			CustomBody:    settings.Body,
+			CustomParams:  settings.Params,
+			CustomSender:  sender,
+			DLRURL:        settings.DLRURL,
+		}
+		if sender != "" {
+			config.TwilioFrom = sender
+			config.VonageFrom = sender
+		}
+		return s.sendWithConfig(config, msg.To, msg.Text)
+	case models.SMSGatewayHTTP:
+		return s.sendViaHTTP(settings, msg.To, msg.Text, sender)
+	case models.SMSGatewaySMPP:
+		client, err := smppClient(gw)
+		if err != nil {
+			return "", err
+		}
+		return client.Submit(sender, msg.To, msg.Text, settings.RequestDLR)
+	}
+	return "", fmt.Errorf("unsupported SMS provider: %s", gw.Provider)
+}
+
+// IsConfigured reports whether SMS can be sent, through gateways or the legacy settings
+func (s *SMSService) IsConfigured() bool {
+	var count int64
+	database.DB.Model(&models.SMSGateway{}).Where("is_active = ?", true).Count(&count)
+	if count > 0 {
+		return true
+	}
+	_, err := s.GetConfig()
+	return err == nil
+}
+
+// Health columns are written with UpdateColumns so updated_at keeps marking
+// settings changes, which rebind SMPP sessions
+
+func recordSMSGatewaySuccess(gw *models.SMSGateway) {
+	database.DB.Model(&models.SMSGateway{}).Where("id = ?", gw.ID).UpdateColumns(map[string]interface{}{
+		"consecutive_failures": 0,
+		"disabled_until":       nil,
+		"last_success_at":      time.Now(),
+		"sent_count":           gorm.Expr("sent_count + 1"),
+	})
+}
+
+// recordSMSGatewayFailure counts a failed send and starts the gateway's cooldown when
+// it has failed sms_failover_threshold times in a row
+func recordSMSGatewayFailure(gw *models.SMSGateway, sendErr error) {
+	errText := sendErr.Error()
+	if len(errText) > 500 {
+		errText = errText[:500]
+	}
+	now := time.Now()
+
+	var failures []int
+	database.DB.Raw(`
+		UPDATE sms_gateways
+		SET consecutive_failures = consecutive_failures + 1, failed_count = failed_count + 1,
+			last_error = ?, last_failure_at = ?
+		WHERE id = ?
+		RETURNING consecutive_failures`, errText, now, gw.ID).Scan(&failures)
+	if len(failures) == 0 {
+		return
+	}
+
+	threshold := getIntPreference("sms_failover_threshold", 3)
+	if failures[0] < threshold || !gw.Healthy(now) {
+		return
+	}
+	cooldown := getIntPreference("sms_failover_cooldown_minutes", 5)
+	database.DB.Model(&models.SMSGateway{}).Where("id = ?", gw.ID).
+		UpdateColumn("disabled_until", now.Add(time.Duration(cooldown)*time.Minute))
+	log.Printf("SMS: Gateway %s failed %d times in a row, skipping it for %d minutes: %v", gw.Name, failures[0], cooldown, sendErr)
+}
+
+// ParseSMSDeliveryReport extracts the message ID, status and error from a delivery
+// receipt posted to a gateway's DLR URL. fields holds the JSON body, form and query
+// values.
+func ParseSMSDeliveryReport(gw *models.SMSGateway, fields map[string]interface{}) (string, string, string) {
+	value := func(paths ...string) string {
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			if v, ok := jsonPath(fields, path); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	switch gw.Provider {
+	case models.SMSGatewayTwilio:
+		return value("MessageSid", "SmsSid"), value("MessageStatus", "SmsStatus"), value("ErrorCode")
+	case models.SMSGatewayVonage:
+		return value("messageId"), value("status"), value("err-code")
+	}
+	settings := gw.Config()
+	return value(settings.DLRIDField, "id", "message_id", "messageId"),
+		value(settings.DLRStatusField, "status", "stat", "state"),
+		value(settings.DLRErrorField, "error", "err")
+}
+
+// Provider statuses that end a message's delivery; anything else is intermediate.
+// The numbers are Kannel's DLR types.
+var (
+	smsDeliveredStatuses   = []string{"delivered", "delivrd", "1"}
+	smsUndeliveredStatuses = []string{"undelivered", "undeliv", "failed", "expired", "rejected", "rejectd", "deleted", "unknown", "2", "16"}
+)
+
+// smsDeliveryStatus maps a provider status to a final communication log status, or
+// "" for an intermediate status
+func smsDeliveryStatus(gw *models.SMSGateway, status string) string {
+	delivered, undelivered := smsDeliveredStatuses, smsUndeliveredStatuses
+	if gw.Provider == models.SMSGatewayHTTP {
+		settings := gw.Config()
+		if settings.DLRDeliveredValues != "" {
+			delivered = strings.Split(settings.DLRDeliveredValues, ",")
+		}
+		if settings.DLRFailedValues != "" {
+			undelivered = strings.Split(settings.DLRFailedValues, ",")
+		}
+	}
+	switch {
+	case containsFold(delivered, status):
+		return models.CommStatusDelivered
+	case containsFold(undelivered, status):
+		return models.CommStatusUndelivered
+	}
+	return ""
+}
+
+func containsFold(values []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplySMSDeliveryReport sets the delivery outcome on the communication log of the
+// message a gateway sent. Intermediate statuses are ignored. It returns the number of
+// log entries updated.
+func ApplySMSDeliveryReport(gw *models.SMSGateway, messageID, status, errText string) int64 {
+	if messageID == "" {
+		return 0
+	}
+	final := smsDeliveryStatus(gw, status)
+	if final == "" {
+		return 0
+	}
+
+	updates := map[string]interface{}{"status": final}
+	if final == models.CommStatusDelivered {
+		updates["delivered_at"] = time.Now()
+	} else {
+		message := "Not delivered: " + status
+		if errText != "" {
+			message += " (error " + errText + ")"
+		}
+		updates["error_message"] = message
+	}
+
+	result := database.DB.Model(&models.CommunicationLog{}).
+		Where("gateway_id = ? AND provider_message_id IN ?", gw.ID, smsMessageIDForms(messageID)).
+		Updates(updates)
+	return result.RowsAffected
+}
+
+// smsMessageIDForms returns a message ID with its hex and decimal forms, as some
+// SMSCs report receipts for hex IDs in decimal or the other way round
+func smsMessageIDForms(id string) []string {
+	forms := []string{id}
+	if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+		forms = append(forms, strconv.FormatUint(n, 16), strings.ToUpper(strconv.FormatUint(n, 16)))
+	}
+	if n, err := strconv.ParseUint(id, 16, 64); err == nil {
+		forms = append(forms, strconv.FormatUint(n, 10))
+	}
+	return forms
+}
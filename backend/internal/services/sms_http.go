@@ -0,0 +1,195 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/proisp/backend/internal/models"
+)
+
+// sendViaHTTP sends an SMS through a generic HTTP API. The URL and body templates get
+// to, message, sender and dlr_url escaped for where they appear: query-escaped in the
+// URL and form bodies, JSON-escaped in JSON bodies. The response is checked and the
+// message ID extracted as the gateway's settings describe.
+func (s *SMSService) sendViaHTTP(settings models.SMSGatewaySettings, to, message, sender string) (string, error) {
+	if settings.URL == "" {
+		return "", fmt.Errorf("HTTP gateway URL not configured")
+	}
+	method := strings.ToUpper(settings.Method)
+	if method == "" {
+		method = "POST"
+	}
+	contentType := settings.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	vars := func(escape func(string) string) map[string]interface{} {
+		return map[string]interface{}{
+			"to":      escape(to),
+			"message": escape(message),
+			"sender":  escape(sender),
+			"dlr_url": escape(settings.DLRURL),
+		}
+	}
+
+	apiURL, err := RenderMessageTemplate(settings.URL, vars(url.QueryEscape))
+	if err != nil {
+		return "", fmt.Errorf("invalid URL template: %v", err)
+	}
+
+	var reqBody io.Reader
+	if settings.Body != "" && method != "GET" {
+		escape := func(v string) string { return v }
+		switch {
+		case strings.Contains(contentType, "json"):
+			escape = jsonEscape
+		case strings.Contains(contentType, "x-www-form-urlencoded"):
+			escape = url.QueryEscape
+		}
+		body, err := RenderMessageTemplate(settings.Body, vars(escape))
+		if err != nil {
+			return "", fmt.Errorf("invalid body template: %v", err)
+		}
+		reqBody = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, apiURL, reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, value := range settings.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("SMS API error (%d): %s", resp.StatusCode, truncateText(string(body), 200))
+	}
+	return parseHTTPSMSResponse(settings, body)
+}
+
+// parseHTTPSMSResponse checks a gateway's response for success and returns the
+// message ID it reports
+func parseHTTPSMSResponse(settings models.SMSGatewaySettings, body []byte) (string, error) {
+	var doc interface{}
+	isJSON := json.Unmarshal(body, &doc) == nil
+
+	if settings.SuccessField != "" {
+		value, ok := "", false
+		if isJSON {
+			value, ok = jsonPath(doc, settings.SuccessField)
+		}
+		if !ok || !successValue(value, settings.SuccessValues) {
+			reason := truncateText(string(body), 200)
+			if isJSON && settings.ErrorField != "" {
+				if e, ok := jsonPath(doc, settings.ErrorField); ok && e != "" {
+					reason = e
+				}
+			}
+			return "", fmt.Errorf("SMS API rejected the message: %s", reason)
+		}
+	}
+	if settings.SuccessRegex != "" {
+		re, err := regexp.Compile(settings.SuccessRegex)
+		if err != nil {
+			return "", fmt.Errorf("invalid success regex: %v", err)
+		}
+		if !re.Match(body) {
+			return "", fmt.Errorf("SMS API rejected the message: %s", truncateText(string(body), 200))
+		}
+	}
+
+	var id string
+	if isJSON && settings.MessageIDField != "" {
+		id, _ = jsonPath(doc, settings.MessageIDField)
+	}
+	if id == "" && settings.MessageIDRegex != "" {
+		if re, err := regexp.Compile(settings.MessageIDRegex); err == nil {
+			if m := re.FindSubmatch(body); len(m) > 1 {
+				id = string(m[1])
+			}
+		}
+	}
+	return id, nil
+}
+
+// successValue reports whether a response field means success: one of the
+// comma-separated values, or with none given anything but empty, false, 0 or null
+func successValue(value, values string) bool {
+	if values != "" {
+		return containsFold(strings.Split(values, ","), value)
+	}
+	return !containsFold([]string{"", "false", "0", "null"}, value)
+}
+
+// jsonPath resolves a dotted path such as "messages.0.id" in decoded JSON and returns
+// the value as text
+func jsonPath(doc interface{}, path string) (string, bool) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			current = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch value := current.(type) {
+	case nil:
+		return "", true
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	default:
+		encoded, _ := json.Marshal(value)
+		return string(encoded), true
+	}
+}
+
+// jsonEscape escapes a string for use inside a JSON string literal
+func jsonEscape(value string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	encoded := strings.TrimSpace(buf.String())
+	return encoded[1 : len(encoded)-1]
+}
+
+func truncateText(text string, max int) string {
+	if len(text) > max {
+		return text[:max] + "..."
+	}
+	return text
+}
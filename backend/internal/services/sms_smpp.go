@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/smpp"
+)
+
+// smppSessions holds one bound session per SMPP gateway
+var smppSessions = struct {
+	sync.Mutex
+	clients map[uint]*smppSession
+}{clients: make(map[uint]*smppSession)}
+
+type smppSession struct {
+	client    *smpp.Client
+	updatedAt time.Time // The gateway's updated_at when bound; a change rebinds
+}
+
+// smppClient returns the gateway's bound session, binding a new one when there is
+// none, it dropped or the gateway's settings changed since
+func smppClient(gw *models.SMSGateway) (*smpp.Client, error) {
+	smppSessions.Lock()
+	defer smppSessions.Unlock()
+
+	if session := smppSessions.clients[gw.ID]; session != nil {
+		if session.client.Err() == nil && session.updatedAt.Equal(gw.UpdatedAt) {
+			return session.client, nil
+		}
+		session.client.Close()
+		delete(smppSessions.clients, gw.ID)
+	}
+
+	settings := gw.Config()
+	if settings.Host == "" || settings.SystemID == "" {
+		return nil, fmt.Errorf("SMPP host and system ID are required")
+	}
+	port := settings.Port
+	if port == 0 {
+		port = 2775
+	}
+	addr := net.JoinHostPort(settings.Host, strconv.Itoa(port))
+	gatewayID := gw.ID
+
+	client, err := smpp.Dial(smpp.Config{
+		Addr:        addr,
+		TLS:         settings.TLS,
+		SystemID:    settings.SystemID,
+		Password:    settings.Password,
+		SystemType:  settings.SystemType,
+		SourceTON:   byte(settings.SourceTON),
+		SourceNPI:   byte(settings.SourceNPI),
+		DestTON:     byte(settings.DestTON),
+		DestNPI:     byte(settings.DestNPI),
+		EnquireLink: time.Duration(settings.EnquireLinkSecs) * time.Second,
+		OnReceipt: func(receipt smpp.DeliveryReceipt) {
+			handleSMPPReceipt(gatewayID, receipt)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("SMS: Bound to SMPP gateway %s at %s", gw.Name, addr)
+
+	smppSessions.clients[gw.ID] = &smppSession{client: client, updatedAt: gw.UpdatedAt}
+	return client, nil
+}
+
+// CloseSMPPSession unbinds a gateway's session, e.g. when it is deleted or deactivated
+func CloseSMPPSession(gatewayID uint) {
+	smppSessions.Lock()
+	session := smppSessions.clients[gatewayID]
+	delete(smppSessions.clients, gatewayID)
+	smppSessions.Unlock()
+
+	if session != nil {
+		session.client.Close()
+	}
+}
+
+// handleSMPPReceipt applies a final delivery receipt to the communication log
+func handleSMPPReceipt(gatewayID uint, receipt smpp.DeliveryReceipt) {
+	if !receipt.Final() || database.DB == nil {
+		return
+	}
+	var gw models.SMSGateway
+	if err := database.DB.First(&gw, gatewayID).Error; err != nil {
+		return
+	}
+	ApplySMSDeliveryReport(&gw, receipt.MessageID, receipt.State, receipt.Error)
+}
+
+// SMSGatewayService keeps active SMPP gateways bound, so delivery receipts arrive even
+// while nothing is being sent, and unbinds gateways that were deactivated
+type SMSGatewayService struct {
+	interval  time.Duration
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	isRunning bool
+}
+
+// NewSMSGatewayService creates a new SMS gateway service
+func NewSMSGatewayService(interval time.Duration) *SMSGatewayService {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &SMSGatewayService{
+		interval: interval,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins keeping SMPP sessions bound
+func (s *SMSGatewayService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("SMSGatewayService started (interval: %v)", s.interval)
+}
+
+// Stop stops the service and unbinds all SMPP sessions
+func (s *SMSGatewayService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+
+	smppSessions.Lock()
+	ids := make([]uint, 0, len(smppSessions.clients))
+	for id := range smppSessions.clients {
+		ids = append(ids, id)
+	}
+	smppSessions.Unlock()
+	for _, id := range ids {
+		CloseSMPPSession(id)
+	}
+	log.Println("SMSGatewayService stopped")
+}
+
+func (s *SMSGatewayService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.bindAll()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.bindAll()
+		}
+	}
+}
+
+// bindAll binds every active SMPP gateway and unbinds the rest
+func (s *SMSGatewayService) bindAll() {
+	if database.DB == nil {
+		return
+	}
+	var gateways []models.SMSGateway
+	database.DB.Where("provider = ? AND is_active = ?", models.SMSGatewaySMPP, true).Find(&gateways)
+
+	active := make(map[uint]bool, len(gateways))
+	for i := range gateways {
+		active[gateways[i].ID] = true
+		if _, err := smppClient(&gateways[i]); err != nil {
+			log.Printf("SMS: SMPP gateway %s unavailable: %v", gateways[i].Name, err)
+		}
+	}
+
+	smppSessions.Lock()
+	var stale []uint
+	for id := range smppSessions.clients {
+		if !active[id] {
+			stale = append(stale, id)
+		}
+	}
+	smppSessions.Unlock()
+	for _, id := range stale {
+		CloseSMPPSession(id)
+	}
+}
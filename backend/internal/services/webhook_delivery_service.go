@@ -0,0 +1,183 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+	"github.com/proisp/backend/internal/webhook"
+)
+
+const webhookExpiryCursorKey = "webhook_expiry_checked_at"
+
+// WebhookDeliveryService drains the webhook delivery queue, retrying failed deliveries
+// with exponential backoff. It also emits subscriber.expired when an expiry date passes,
+// since expiry is not triggered by any request.
+type WebhookDeliveryService struct {
+	pollInterval time.Duration
+	batchSize    int
+	lastPrune    time.Time
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+	isRunning    bool
+}
+
+// NewWebhookDeliveryService creates a new webhook delivery service
+func NewWebhookDeliveryService(pollInterval time.Duration) *WebhookDeliveryService {
+	if pollInterval <= 0 {
+		pollInterval = 10 * time.Second
+	}
+	return &WebhookDeliveryService{
+		pollInterval: pollInterval,
+		batchSize:    50,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start begins processing the queue
+func (s *WebhookDeliveryService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("WebhookDeliveryService started (interval: %v)", s.pollInterval)
+}
+
+// Stop stops the service
+func (s *WebhookDeliveryService) Stop() {
+	s.mu.Lock()
+	if !s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("WebhookDeliveryService stopped")
+}
+
+func (s *WebhookDeliveryService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.emitExpired()
+			s.processDue()
+			s.prune()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// processDue claims due deliveries and sends them. Claiming pushes next_attempt_at forward
+// under SKIP LOCKED so cluster nodes never send the same delivery twice.
+func (s *WebhookDeliveryService) processDue() {
+	now := time.Now()
+	var ids []uint
+	if err := database.DB.Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`, now.Add(5*time.Minute), models.WebhookStatusPending, now, s.batchSize).
+		Scan(&ids).Error; err != nil {
+		log.Printf("Webhook: Failed to claim deliveries: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	database.DB.Preload("Endpoint").Where("id IN ?", ids).Order("id").Find(&deliveries)
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		endpoint := delivery.Endpoint
+		delivery.Endpoint = nil
+		if endpoint == nil {
+			// Endpoint was deleted - nothing to deliver to
+			database.DB.Model(delivery).Updates(map[string]interface{}{
+				"status":     models.WebhookStatusDead,
+				"last_error": "endpoint deleted",
+			})
+			continue
+		}
+		if !endpoint.IsActive {
+			// Paused endpoint: hold the queue until it is re-enabled
+			continue
+		}
+		webhook.Deliver(endpoint, delivery)
+	}
+}
+
+// emitExpired sends subscriber.expired for every subscriber whose expiry passed since the last check.
+// The cursor is stored in system_preferences so restarts neither miss nor repeat events.
+func (s *WebhookDeliveryService) emitExpired() {
+	now := time.Now()
+
+	var pref models.SystemPreference
+	if err := database.DB.Where("key = ?", webhookExpiryCursorKey).First(&pref).Error; err != nil {
+		// First run: start from now rather than replaying every historic expiry
+		database.DB.Create(&models.SystemPreference{Key: webhookExpiryCursorKey, Value: now.Format(time.RFC3339), ValueType: "string"})
+		return
+	}
+	since, err := time.Parse(time.RFC3339, pref.Value)
+	if err != nil || now.Sub(since) < time.Minute {
+		if err != nil {
+			database.DB.Model(&pref).Update("value", now.Format(time.RFC3339))
+		}
+		return
+	}
+
+	var subscribers []models.Subscriber
+	if err := database.DB.Preload("Service").
+		Where("expiry_date > ? AND expiry_date <= ? AND deleted_at IS NULL", since, now).
+		Find(&subscribers).Error; err != nil {
+		log.Printf("Webhook: Failed to query expired subscribers: %v", err)
+		return
+	}
+	for i := range subscribers {
+		webhook.EmitSubscriber(models.WebhookEventSubscriberExpired, &subscribers[i], nil)
+	}
+
+	database.DB.Model(&pref).Update("value", now.Format(time.RFC3339))
+}
+
+// prune deletes delivered events past the retention period, once a day.
+// Dead deliveries are kept until replayed or deleted.
+func (s *WebhookDeliveryService) prune() {
+	if time.Since(s.lastPrune) < 24*time.Hour {
+		return
+	}
+	s.lastPrune = time.Now()
+
+	days := getIntPreference("webhook_retention_days", 30)
+	cutoff := time.Now().AddDate(0, 0, -days)
+	result := database.DB.Where("status = ? AND created_at < ?", models.WebhookStatusSuccess, cutoff).
+		Delete(&models.WebhookDelivery{})
+	if result.RowsAffected > 0 {
+		log.Printf("Webhook: Pruned %d delivered events older than %d days", result.RowsAffected, days)
+	}
+}
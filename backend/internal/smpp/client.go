@@ -0,0 +1,291 @@
+package smpp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config describes an SMSC account
+type Config struct {
+	Addr       string // host:port
+	TLS        bool
+	SystemID   string
+	Password   string
+	SystemType string
+	SourceTON  byte
+	SourceNPI  byte
+	DestTON    byte
+	DestNPI    byte
+
+	EnquireLink time.Duration // Keepalive interval, default 30s
+	Timeout     time.Duration // Connect and response timeout, default 10s
+
+	// OnReceipt is called for every delivery receipt, on its own goroutine
+	OnReceipt func(DeliveryReceipt)
+}
+
+// ErrClosed is returned for requests on a closed session
+var ErrClosed = errors.New("SMPP session closed")
+
+// Client is a bound transceiver session. It is safe for concurrent use; when the
+// connection drops, Closed is signalled and a new client must be dialled.
+type Client struct {
+	cfg  Config
+	conn net.Conn
+
+	sequence  uint32
+	reference uint32
+	writeMu   sync.Mutex
+
+	mu      sync.Mutex
+	pending map[uint32]chan *PDU
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Dial connects to the SMSC and binds as a transceiver
+func Dial(cfg Config) (*Client, error) {
+	if cfg.EnquireLink <= 0 {
+		cfg.EnquireLink = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout, KeepAlive: time.Minute}
+	var conn net.Conn
+	var err error
+	if cfg.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Addr, &tls.Config{})
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %v", cfg.Addr, err)
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		conn:    conn,
+		pending: make(map[uint32]chan *PDU),
+		closed:  make(chan struct{}),
+	}
+	go c.readLoop()
+
+	var body bodyWriter
+	body.cstring(cfg.SystemID)
+	body.cstring(cfg.Password)
+	body.cstring(cfg.SystemType)
+	body.octet(0x34) // interface_version 3.4
+	body.octet(0)    // addr_ton
+	body.octet(0)    // addr_npi
+	body.cstring("") // address_range
+	if _, err := c.request(BindTransceiver, body.Bytes()); err != nil {
+		c.fail(err)
+		return nil, fmt.Errorf("bind: %v", err)
+	}
+
+	go c.keepalive()
+	return c, nil
+}
+
+// Closed is signalled when the session ends
+func (c *Client) Closed() <-chan struct{} {
+	return c.closed
+}
+
+// Err returns why the session ended
+func (c *Client) Err() error {
+	select {
+	case <-c.closed:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close unbinds and closes the connection
+func (c *Client) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+	}
+	done := make(chan struct{})
+	go func() {
+		c.request(Unbind, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+	}
+	c.fail(ErrClosed)
+	return nil
+}
+
+// Submit sends text to a destination address, concatenated over several messages
+// when it is long. With receipt set the SMSC is asked for a delivery receipt of the
+// last part, whose message ID is returned.
+func (c *Client) Submit(from, to, text string, receipt bool) (string, error) {
+	coding, parts := Encode(text)
+	ref := byte(atomic.AddUint32(&c.reference, 1))
+
+	var messageID string
+	for i, part := range parts {
+		esmClass := byte(0)
+		payload := part
+		if len(parts) > 1 {
+			// UDH: concatenated short message, 8-bit reference
+			esmClass = 0x40
+			payload = append([]byte{0x05, 0x00, 0x03, ref, byte(len(parts)), byte(i + 1)}, part...)
+		}
+		registered := byte(0)
+		if receipt && i == len(parts)-1 {
+			registered = 1
+		}
+
+		var body bodyWriter
+		body.cstring("") // service_type
+		body.octet(c.cfg.SourceTON)
+		body.octet(c.cfg.SourceNPI)
+		body.cstring(from)
+		body.octet(c.cfg.DestTON)
+		body.octet(c.cfg.DestNPI)
+		body.cstring(to)
+		body.octet(esmClass)
+		body.octet(0)    // protocol_id
+		body.octet(0)    // priority_flag
+		body.cstring("") // schedule_delivery_time
+		body.cstring("") // validity_period
+		body.octet(registered)
+		body.octet(0) // replace_if_present_flag
+		body.octet(coding)
+		body.octet(0) // sm_default_msg_id
+		body.octet(byte(len(payload)))
+		body.Write(payload)
+
+		resp, err := c.request(SubmitSM, body.Bytes())
+		if err != nil {
+			return "", err
+		}
+		messageID = (&bodyReader{b: resp.Body}).cstring()
+	}
+	return messageID, nil
+}
+
+// request sends a PDU and waits for its response
+func (c *Client) request(commandID uint32, body []byte) (*PDU, error) {
+	seq := atomic.AddUint32(&c.sequence, 1)
+	ch := make(chan *PDU, 1)
+	c.mu.Lock()
+	c.pending[seq] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(&PDU{CommandID: commandID, Sequence: seq, Body: body}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Status != 0 {
+			return resp, StatusError(resp.Status)
+		}
+		return resp, nil
+	case <-c.closed:
+		return nil, c.err
+	case <-time.After(c.cfg.Timeout):
+		return nil, fmt.Errorf("no response from SMSC within %v", c.cfg.Timeout)
+	}
+}
+
+func (c *Client) write(p *PDU) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.cfg.Timeout))
+	if _, err := c.conn.Write(p.Marshal()); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// readLoop dispatches responses to their requests and answers the SMSC's requests
+func (c *Client) readLoop() {
+	for {
+		p, err := ReadPDU(c.conn)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		if p.IsResponse() {
+			c.mu.Lock()
+			ch := c.pending[p.Sequence]
+			c.mu.Unlock()
+			if ch != nil {
+				select {
+				case ch <- p:
+				default: // Duplicate response
+				}
+			}
+			continue
+		}
+
+		switch p.CommandID {
+		case EnquireLink:
+			c.write(&PDU{CommandID: EnquireLinkResp, Sequence: p.Sequence})
+		case DeliverSM:
+			// message_id is unused in deliver_sm_resp and left empty
+			c.write(&PDU{CommandID: DeliverSMResp, Sequence: p.Sequence, Body: []byte{0}})
+			if receipt, ok := parseDeliverSM(p.Body); ok && c.cfg.OnReceipt != nil {
+				go c.cfg.OnReceipt(receipt)
+			}
+		case Unbind:
+			c.write(&PDU{CommandID: UnbindResp, Sequence: p.Sequence})
+			c.fail(errors.New("unbound by SMSC"))
+			return
+		default:
+			c.write(&PDU{CommandID: GenericNack, Status: 0x03, Sequence: p.Sequence})
+		}
+	}
+}
+
+// keepalive sends enquire_link at the configured interval and ends the session
+// when the SMSC stops answering
+func (c *Client) keepalive() {
+	ticker := time.NewTicker(c.cfg.EnquireLink)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			if _, err := c.request(EnquireLink, nil); err != nil {
+				c.fail(fmt.Errorf("enquire_link: %v", err))
+				return
+			}
+		}
+	}
+}
+
+// fail ends the session once, recording why
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.closed)
+		c.conn.Close()
+	})
+}
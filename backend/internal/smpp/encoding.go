@@ -0,0 +1,116 @@
+package smpp
+
+import (
+	"unicode/utf16"
+)
+
+// Data coding schemes
+const (
+	CodingDefault byte = 0x00 // SMSC default alphabet (GSM 03.38, unpacked)
+	CodingUCS2    byte = 0x08 // UCS-2 (UTF-16BE)
+)
+
+// gsmBasic is the GSM 03.38 basic character set in code order; 0x1B is the escape
+// to the extension table and never a character
+const gsmBasic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsmExtension maps characters of the extension table, sent as 0x1B and the code
+var gsmExtension = map[rune]byte{
+	'\f': 0x0A, '^': 0x14, '{': 0x28, '}': 0x29, '\\': 0x2F,
+	'[': 0x3C, '~': 0x3D, ']': 0x3E, '|': 0x40, '€': 0x65,
+}
+
+var gsmCodes = func() map[rune]byte {
+	codes := make(map[rune]byte, 128)
+	i := 0
+	for _, r := range gsmBasic {
+		if r != 0x1B {
+			codes[r] = byte(i)
+		}
+		i++
+	}
+	return codes
+}()
+
+const gsmEscape = 0x1B
+
+// encodeGSM encodes text as unpacked GSM septets; ok is false when a character
+// is not in the GSM alphabet
+func encodeGSM(text string) ([]byte, bool) {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		if code, ok := gsmCodes[r]; ok {
+			out = append(out, code)
+		} else if code, ok := gsmExtension[r]; ok {
+			out = append(out, gsmEscape, code)
+		} else {
+			return nil, false
+		}
+	}
+	return out, true
+}
+
+// encodeUCS2 encodes text as UTF-16 big endian
+func encodeUCS2(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	out := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+// Encode encodes text in the GSM alphabet when it can, otherwise UCS-2, and splits it
+// into the payloads of a concatenated message: 160 septets or 70 characters fit one
+// message, longer text is sent in parts of 153 septets or 67 characters (the rest of
+// each part is taken by the concatenation header).
+func Encode(text string) (byte, [][]byte) {
+	if gsm, ok := encodeGSM(text); ok {
+		if len(gsm) <= 160 {
+			return CodingDefault, [][]byte{gsm}
+		}
+		var parts [][]byte
+		for len(gsm) > 0 {
+			n := 153
+			if n >= len(gsm) {
+				n = len(gsm)
+			} else if gsm[n-1] == gsmEscape {
+				n-- // Keep an escape with the character it belongs to
+			}
+			parts = append(parts, gsm[:n])
+			gsm = gsm[n:]
+		}
+		return CodingDefault, parts
+	}
+
+	ucs2 := encodeUCS2(text)
+	if len(ucs2) <= 140 {
+		return CodingUCS2, [][]byte{ucs2}
+	}
+	var parts [][]byte
+	for len(ucs2) > 0 {
+		n := 134
+		if n >= len(ucs2) {
+			n = len(ucs2)
+		} else if hi := ucs2[n-2]; hi >= 0xD8 && hi <= 0xDB {
+			n -= 2 // Keep a surrogate pair together
+		}
+		parts = append(parts, ucs2[:n])
+		ucs2 = ucs2[n:]
+	}
+	return CodingUCS2, parts
+}
+
+// decode converts a short message to text for its data coding
+func decode(coding byte, payload []byte) string {
+	if coding == CodingUCS2 {
+		units := make([]uint16, 0, len(payload)/2)
+		for i := 0; i+1 < len(payload); i += 2 {
+			units = append(units, uint16(payload[i])<<8|uint16(payload[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	// Receipts are plain ASCII whatever the SMSC's default alphabet
+	return string(payload)
+}
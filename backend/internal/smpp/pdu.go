@@ -0,0 +1,178 @@
+// Package smpp is a minimal SMPP 3.4 transceiver client: it binds, submits short
+// messages (concatenated when long) and receives delivery receipts.
+package smpp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Command IDs (SMPP 3.4 section 5.1.2.1)
+const (
+	GenericNack         uint32 = 0x80000000
+	BindTransceiver     uint32 = 0x00000009
+	BindTransceiverResp uint32 = 0x80000009
+	SubmitSM            uint32 = 0x00000004
+	SubmitSMResp        uint32 = 0x80000004
+	DeliverSM           uint32 = 0x00000005
+	DeliverSMResp       uint32 = 0x80000005
+	Unbind              uint32 = 0x00000006
+	UnbindResp          uint32 = 0x80000006
+	EnquireLink         uint32 = 0x00000015
+	EnquireLinkResp     uint32 = 0x80000015
+)
+
+// Optional parameter tags used in delivery receipts
+const (
+	TagReceiptedMessageID uint16 = 0x001E
+	TagMessageState       uint16 = 0x0427
+)
+
+const (
+	headerLength = 16
+	maxPDULength = 64 * 1024
+)
+
+// PDU is a protocol data unit with its header fields and raw body
+type PDU struct {
+	CommandID uint32
+	Status    uint32
+	Sequence  uint32
+	Body      []byte
+}
+
+// IsResponse reports whether the PDU answers a request
+func (p *PDU) IsResponse() bool {
+	return p.CommandID&0x80000000 != 0
+}
+
+// Marshal encodes the PDU for the wire
+func (p *PDU) Marshal() []byte {
+	buf := make([]byte, headerLength+len(p.Body))
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(buf)))
+	binary.BigEndian.PutUint32(buf[4:], p.CommandID)
+	binary.BigEndian.PutUint32(buf[8:], p.Status)
+	binary.BigEndian.PutUint32(buf[12:], p.Sequence)
+	copy(buf[headerLength:], p.Body)
+	return buf
+}
+
+// ReadPDU reads one PDU from r
+func ReadPDU(r io.Reader) (*PDU, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:])
+	if length < headerLength || length > maxPDULength {
+		return nil, fmt.Errorf("invalid PDU length %d", length)
+	}
+	p := &PDU{
+		CommandID: binary.BigEndian.Uint32(header[4:]),
+		Status:    binary.BigEndian.Uint32(header[8:]),
+		Sequence:  binary.BigEndian.Uint32(header[12:]),
+		Body:      make([]byte, length-headerLength),
+	}
+	if _, err := io.ReadFull(r, p.Body); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// bodyWriter builds PDU bodies field by field
+type bodyWriter struct {
+	bytes.Buffer
+}
+
+func (w *bodyWriter) cstring(s string) {
+	w.WriteString(s)
+	w.WriteByte(0)
+}
+
+func (w *bodyWriter) octet(b byte) {
+	w.WriteByte(b)
+}
+
+// bodyReader reads PDU bodies field by field; reads past the end yield zero values
+type bodyReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bodyReader) cstring() string {
+	if r.pos >= len(r.b) {
+		return ""
+	}
+	end := bytes.IndexByte(r.b[r.pos:], 0)
+	if end < 0 {
+		s := string(r.b[r.pos:])
+		r.pos = len(r.b)
+		return s
+	}
+	s := string(r.b[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
+
+func (r *bodyReader) octet() byte {
+	if r.pos >= len(r.b) {
+		return 0
+	}
+	b := r.b[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *bodyReader) octets(n int) []byte {
+	if r.pos+n > len(r.b) {
+		n = len(r.b) - r.pos
+	}
+	out := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+// tlvs reads the optional parameters that follow the mandatory fields
+func (r *bodyReader) tlvs() map[uint16][]byte {
+	params := map[uint16][]byte{}
+	for r.pos+4 <= len(r.b) {
+		tag := binary.BigEndian.Uint16(r.b[r.pos:])
+		length := int(binary.BigEndian.Uint16(r.b[r.pos+2:]))
+		r.pos += 4
+		params[tag] = r.octets(length)
+	}
+	return params
+}
+
+// StatusError is a non-zero command_status returned by the SMSC
+type StatusError uint32
+
+var statusNames = map[StatusError]string{
+	0x01: "invalid message length",
+	0x02: "invalid command length",
+	0x03: "invalid command ID",
+	0x04: "incorrect bind status",
+	0x05: "already bound",
+	0x08: "system error",
+	0x0A: "invalid source address",
+	0x0B: "invalid destination address",
+	0x0D: "bind failed",
+	0x0E: "invalid password",
+	0x0F: "invalid system ID",
+	0x14: "message queue full",
+	0x45: "submit failed",
+	0x58: "throttled",
+	0x61: "invalid scheduled delivery time",
+	0x62: "invalid validity period",
+	0x66: "invalid source address TON",
+	0x67: "invalid source address NPI",
+}
+
+func (e StatusError) Error() string {
+	if name, ok := statusNames[e]; ok {
+		return fmt.Sprintf("SMPP error 0x%08X: %s", uint32(e), name)
+	}
+	return fmt.Sprintf("SMPP error 0x%08X", uint32(e))
+}
@@ -0,0 +1,109 @@
+package smpp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Final message states reported in delivery receipts (SMPP 3.4 appendix B)
+const (
+	StateDelivered     = "DELIVRD"
+	StateExpired       = "EXPIRED"
+	StateDeleted       = "DELETED"
+	StateUndeliverable = "UNDELIV"
+	StateAccepted      = "ACCEPTD"
+	StateUnknown       = "UNKNOWN"
+	StateRejected      = "REJECTD"
+	StateEnroute       = "ENROUTE"
+)
+
+// messageStates maps the message_state optional parameter to receipt states
+var messageStates = map[byte]string{
+	1: StateEnroute,
+	2: StateDelivered,
+	3: StateExpired,
+	4: StateDeleted,
+	5: StateUndeliverable,
+	6: StateAccepted,
+	7: StateUnknown,
+	8: StateRejected,
+}
+
+var (
+	receiptID    = regexp.MustCompile(`(?i)\bid:(\S+)`)
+	receiptState = regexp.MustCompile(`(?i)\bstat:(\w+)`)
+	receiptError = regexp.MustCompile(`(?i)\berr:(\w+)`)
+)
+
+// DeliveryReceipt is a delivery report for a submitted message
+type DeliveryReceipt struct {
+	MessageID   string
+	State       string
+	Error       string
+	Source      string // The recipient of the original message
+	Destination string
+}
+
+// Delivered reports whether the message reached the handset
+func (r DeliveryReceipt) Delivered() bool {
+	return r.State == StateDelivered
+}
+
+// Final reports whether the state is final, i.e. no further receipt will follow
+func (r DeliveryReceipt) Final() bool {
+	switch r.State {
+	case StateDelivered, StateExpired, StateDeleted, StateUndeliverable, StateRejected, StateUnknown:
+		return true
+	}
+	return false
+}
+
+// parseDeliverSM parses a deliver_sm body. isReceipt is false for mobile originated
+// messages, which the client does not handle.
+func parseDeliverSM(body []byte) (DeliveryReceipt, bool) {
+	r := &bodyReader{b: body}
+	r.cstring() // service_type
+	r.octet()   // source_addr_ton
+	r.octet()   // source_addr_npi
+	source := r.cstring()
+	r.octet() // dest_addr_ton
+	r.octet() // dest_addr_npi
+	destination := r.cstring()
+	esmClass := r.octet()
+	r.octet()   // protocol_id
+	r.octet()   // priority_flag
+	r.cstring() // schedule_delivery_time
+	r.cstring() // validity_period
+	r.octet()   // registered_delivery
+	r.octet()   // replace_if_present_flag
+	coding := r.octet()
+	r.octet() // sm_default_msg_id
+	text := decode(coding, r.octets(int(r.octet())))
+	params := r.tlvs()
+
+	// Bit 2 of esm_class marks an SMSC delivery receipt
+	if esmClass&0x04 == 0 {
+		return DeliveryReceipt{}, false
+	}
+
+	receipt := DeliveryReceipt{Source: source, Destination: destination}
+	if m := receiptID.FindStringSubmatch(text); m != nil {
+		receipt.MessageID = m[1]
+	}
+	if m := receiptState.FindStringSubmatch(text); m != nil {
+		receipt.State = strings.ToUpper(m[1])
+	}
+	if m := receiptError.FindStringSubmatch(text); m != nil {
+		receipt.Error = m[1]
+	}
+	// The optional parameters are authoritative when present
+	if id, ok := params[TagReceiptedMessageID]; ok {
+		receipt.MessageID = strings.TrimRight(string(id), "\x00")
+	}
+	if state, ok := params[TagMessageState]; ok && len(state) == 1 {
+		if name, ok := messageStates[state[0]]; ok {
+			receipt.State = name
+		}
+	}
+	return receipt, true
+}
@@ -0,0 +1,268 @@
+package snmp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Standard MIB-II / HOST-RESOURCES-MIB OIDs used for NAS health polling
+const (
+	oidSysDescr        = ".1.3.6.1.2.1.1.1.0"
+	oidSysUpTime       = ".1.3.6.1.2.1.1.3.0"
+	oidHrProcessorLoad = ".1.3.6.1.2.1.25.3.3.1.2"
+	oidHrStorageType   = ".1.3.6.1.2.1.25.2.3.1.2"
+	oidHrStorageUnits  = ".1.3.6.1.2.1.25.2.3.1.4"
+	oidHrStorageSize   = ".1.3.6.1.2.1.25.2.3.1.5"
+	oidHrStorageUsed   = ".1.3.6.1.2.1.25.2.3.1.6"
+	oidHrStorageRam    = ".1.3.6.1.2.1.25.2.1.2"
+	oidIfName          = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHCInOctets    = ".1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCOutOctets   = ".1.3.6.1.2.1.31.1.1.1.10"
+	oidIfOperStatus    = ".1.3.6.1.2.1.2.2.1.8"
+)
+
+// Config holds SNMP connection settings for a device
+type Config struct {
+	Address   string
+	Port      int
+	Version   string // v2c or v3
+	Community string // v2c only
+
+	// SNMPv3 USM credentials
+	Username     string
+	AuthProtocol string // MD5, SHA, SHA256, SHA512 (empty = noAuth)
+	AuthPassword string
+	PrivProtocol string // DES, AES, AES256 (empty = noPriv)
+	PrivPassword string
+
+	Timeout time.Duration
+}
+
+// InterfaceCounter holds 64-bit traffic counters for one interface
+type InterfaceCounter struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	RxBytes int64  `json:"rx_bytes"`
+	TxBytes int64  `json:"tx_bytes"`
+}
+
+// SystemStats is the result of a single SNMP health poll
+type SystemStats struct {
+	Description   string             `json:"description"`
+	UptimeSeconds int64              `json:"uptime_seconds"`
+	CPULoad       int                `json:"cpu_load"`
+	MemoryUsed    int64              `json:"memory_used"`
+	MemoryTotal   int64              `json:"memory_total"`
+	Interfaces    []InterfaceCounter `json:"interfaces"`
+}
+
+// newSession builds a gosnmp session from the config
+func newSession(cfg Config) (*gosnmp.GoSNMP, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = 161
+	}
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	g := &gosnmp.GoSNMP{
+		Target:         cfg.Address,
+		Port:           uint16(port),
+		Timeout:        timeout,
+		Retries:        1,
+		MaxOids:        gosnmp.MaxOids,
+		MaxRepetitions: 25,
+	}
+
+	switch strings.ToLower(cfg.Version) {
+	case "", "v2c", "2c", "2":
+		g.Version = gosnmp.Version2c
+		g.Community = cfg.Community
+		if g.Community == "" {
+			g.Community = "public"
+		}
+	case "v3", "3":
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+
+		usm := &gosnmp.UsmSecurityParameters{
+			UserName:                 cfg.Username,
+			AuthenticationProtocol:   gosnmp.NoAuth,
+			PrivacyProtocol:          gosnmp.NoPriv,
+			AuthenticationPassphrase: cfg.AuthPassword,
+			PrivacyPassphrase:        cfg.PrivPassword,
+		}
+		g.MsgFlags = gosnmp.NoAuthNoPriv
+
+		switch strings.ToUpper(cfg.AuthProtocol) {
+		case "":
+		case "MD5":
+			usm.AuthenticationProtocol = gosnmp.MD5
+		case "SHA":
+			usm.AuthenticationProtocol = gosnmp.SHA
+		case "SHA256":
+			usm.AuthenticationProtocol = gosnmp.SHA256
+		case "SHA512":
+			usm.AuthenticationProtocol = gosnmp.SHA512
+		default:
+			return nil, fmt.Errorf("unsupported SNMPv3 auth protocol: %s", cfg.AuthProtocol)
+		}
+		if usm.AuthenticationProtocol != gosnmp.NoAuth {
+			g.MsgFlags = gosnmp.AuthNoPriv
+		}
+
+		switch strings.ToUpper(cfg.PrivProtocol) {
+		case "":
+		case "DES":
+			usm.PrivacyProtocol = gosnmp.DES
+		case "AES":
+			usm.PrivacyProtocol = gosnmp.AES
+		case "AES256":
+			usm.PrivacyProtocol = gosnmp.AES256
+		default:
+			return nil, fmt.Errorf("unsupported SNMPv3 privacy protocol: %s", cfg.PrivProtocol)
+		}
+		if usm.PrivacyProtocol != gosnmp.NoPriv {
+			if usm.AuthenticationProtocol == gosnmp.NoAuth {
+				return nil, fmt.Errorf("SNMPv3 privacy requires an auth protocol")
+			}
+			g.MsgFlags = gosnmp.AuthPriv
+		}
+
+		g.SecurityParameters = usm
+	default:
+		return nil, fmt.Errorf("unsupported SNMP version: %s", cfg.Version)
+	}
+
+	return g, nil
+}
+
+// Poll collects uptime, CPU, memory and interface counters from a device
+func Poll(cfg Config) (*SystemStats, error) {
+	g, err := newSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Connect(); err != nil {
+		return nil, fmt.Errorf("snmp connect failed: %v", err)
+	}
+	defer g.Conn.Close()
+
+	stats := &SystemStats{}
+
+	// System description and uptime (TimeTicks = 1/100 s)
+	pkt, err := g.Get([]string{oidSysDescr, oidSysUpTime})
+	if err != nil {
+		return nil, fmt.Errorf("snmp get failed: %v", err)
+	}
+	for _, v := range pkt.Variables {
+		switch v.Name {
+		case oidSysDescr:
+			if b, ok := v.Value.([]byte); ok {
+				stats.Description = string(b)
+			}
+		case oidSysUpTime:
+			stats.UptimeSeconds = gosnmp.ToBigInt(v.Value).Int64() / 100
+		}
+	}
+
+	// CPU load - average over all processors
+	if pdus, err := g.BulkWalkAll(oidHrProcessorLoad); err == nil && len(pdus) > 0 {
+		var total int64
+		for _, p := range pdus {
+			total += gosnmp.ToBigInt(p.Value).Int64()
+		}
+		stats.CPULoad = int(total / int64(len(pdus)))
+	}
+
+	// Memory from hrStorageTable (entries typed hrStorageRam)
+	stats.MemoryUsed, stats.MemoryTotal = pollMemory(g)
+
+	// Interface counters
+	stats.Interfaces = pollInterfaces(g)
+
+	return stats, nil
+}
+
+// pollMemory reads RAM usage in bytes from hrStorageTable
+func pollMemory(g *gosnmp.GoSNMP) (int64, int64) {
+	types, err := g.BulkWalkAll(oidHrStorageType)
+	if err != nil {
+		return 0, 0
+	}
+
+	var ramIndexes []string
+	for _, p := range types {
+		if oid, ok := p.Value.(string); ok && oid == oidHrStorageRam {
+			ramIndexes = append(ramIndexes, strings.TrimPrefix(p.Name, oidHrStorageType))
+		}
+	}
+	if len(ramIndexes) == 0 {
+		return 0, 0
+	}
+
+	var used, total int64
+	for _, idx := range ramIndexes {
+		pkt, err := g.Get([]string{oidHrStorageUnits + idx, oidHrStorageSize + idx, oidHrStorageUsed + idx})
+		if err != nil || len(pkt.Variables) != 3 {
+			continue
+		}
+		units := gosnmp.ToBigInt(pkt.Variables[0].Value).Int64()
+		total += gosnmp.ToBigInt(pkt.Variables[1].Value).Int64() * units
+		used += gosnmp.ToBigInt(pkt.Variables[2].Value).Int64() * units
+	}
+	return used, total
+}
+
+// pollInterfaces reads ifXTable 64-bit counters keyed by ifIndex
+func pollInterfaces(g *gosnmp.GoSNMP) []InterfaceCounter {
+	names, err := g.BulkWalkAll(oidIfName)
+	if err != nil {
+		return nil
+	}
+
+	byIndex := make(map[string]*InterfaceCounter)
+	var order []string
+	for _, p := range names {
+		idx := strings.TrimPrefix(p.Name, oidIfName)
+		name := ""
+		if b, ok := p.Value.([]byte); ok {
+			name = string(b)
+		}
+		byIndex[idx] = &InterfaceCounter{Name: name}
+		order = append(order, idx)
+	}
+
+	walk := func(root string, apply func(ic *InterfaceCounter, v interface{})) {
+		pdus, err := g.BulkWalkAll(root)
+		if err != nil {
+			return
+		}
+		for _, p := range pdus {
+			if ic, ok := byIndex[strings.TrimPrefix(p.Name, root)]; ok {
+				apply(ic, p.Value)
+			}
+		}
+	}
+
+	walk(oidIfHCInOctets, func(ic *InterfaceCounter, v interface{}) {
+		ic.RxBytes = gosnmp.ToBigInt(v).Int64()
+	})
+	walk(oidIfHCOutOctets, func(ic *InterfaceCounter, v interface{}) {
+		ic.TxBytes = gosnmp.ToBigInt(v).Int64()
+	})
+	walk(oidIfOperStatus, func(ic *InterfaceCounter, v interface{}) {
+		ic.Running = gosnmp.ToBigInt(v).Int64() == 1 // 1 = up
+	})
+
+	result := make([]InterfaceCounter, 0, len(order))
+	for _, idx := range order {
+		result = append(result, *byIndex[idx])
+	}
+	return result
+}
@@ -0,0 +1,405 @@
+package sso
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/proisp/backend/internal/models"
+)
+
+const (
+	discoveryCacheTTL = time.Hour
+	jwksCacheTTL      = time.Hour
+	jwksMinRefetch    = time.Minute // Unknown key IDs trigger a refetch at most this often
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Discovery is the subset of the OpenID Provider metadata we use
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse is the token endpoint's answer to an authorization code
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Error       string `json:"error"`
+	ErrorDesc   string `json:"error_description"`
+}
+
+// Identity is who the IdP says logged in, after claim mapping
+type Identity struct {
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+type cachedDiscovery struct {
+	doc       *Discovery
+	fetchedAt time.Time
+}
+
+type cachedJWKS struct {
+	keys      map[string]interface{} // kid -> *rsa.PublicKey / *ecdsa.PublicKey
+	fetchedAt time.Time
+}
+
+var (
+	cacheMu        sync.Mutex
+	discoveryCache = map[string]cachedDiscovery{}
+	jwksCache      = map[string]cachedJWKS{}
+)
+
+// InvalidateCache forgets discovery documents and keys, e.g. after a provider is edited
+func InvalidateCache() {
+	cacheMu.Lock()
+	discoveryCache = map[string]cachedDiscovery{}
+	jwksCache = map[string]cachedJWKS{}
+	cacheMu.Unlock()
+}
+
+// Discover fetches (and caches) the provider's /.well-known/openid-configuration
+func Discover(issuer string) (*Discovery, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	cacheMu.Lock()
+	cached, ok := discoveryCache[issuer]
+	cacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < discoveryCacheTTL {
+		return cached.doc, nil
+	}
+
+	var doc Discovery
+	if err := getJSON(issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return nil, fmt.Errorf("discovery failed: %v", err)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", doc.Issuer, issuer)
+	}
+
+	cacheMu.Lock()
+	discoveryCache[issuer] = cachedDiscovery{doc: &doc, fetchedAt: time.Now()}
+	cacheMu.Unlock()
+	return &doc, nil
+}
+
+// PKCEChallenge returns the S256 code challenge for a verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL is where the browser is sent to log in at the IdP
+func AuthCodeURL(p *models.SSOProvider, doc *Discovery, redirectURL, state, nonce, verifier string) string {
+	scopes := strings.TrimSpace(p.Scopes)
+	if scopes == "" {
+		scopes = "openid email profile"
+	}
+	if !strings.Contains(" "+scopes+" ", " openid ") {
+		scopes = "openid " + scopes
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", scopes)
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", PKCEChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange trades an authorization code for tokens (client_secret_post authentication)
+func Exchange(p *models.SSOProvider, doc *Discovery, code, redirectURL, verifier string) (*TokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var tokens TokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("token endpoint returned HTTP %d", resp.StatusCode)
+	}
+	if tokens.Error != "" {
+		return nil, fmt.Errorf("token endpoint: %s %s", tokens.Error, tokens.ErrorDesc)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("token endpoint returned HTTP %d without an id_token", resp.StatusCode)
+	}
+	return &tokens, nil
+}
+
+// VerifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func VerifyIDToken(p *models.SSOProvider, doc *Discovery, rawIDToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			// HS256 ID tokens are signed with the client secret
+			if p.ClientSecret == "" {
+				return nil, errors.New("HMAC-signed ID token but no client secret configured")
+			}
+			return []byte(p.ClientSecret), nil
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodRSAPSS:
+			kid, _ := token.Header["kid"].(string)
+			return signingKey(doc.JWKSURI, kid)
+		}
+		return nil, fmt.Errorf("unsupported signing algorithm %v", token.Header["alg"])
+	},
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// FetchUserInfo adds claims from the userinfo endpoint (groups are often only there)
+func FetchUserInfo(doc *Discovery, accessToken string) (map[string]interface{}, error) {
+	if doc.UserinfoEndpoint == "" || accessToken == "" {
+		return nil, nil
+	}
+	info := map[string]interface{}{}
+	if err := getJSON(doc.UserinfoEndpoint, accessToken, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ClaimsToIdentity applies the provider's claim names. userinfo fills claims missing from the ID token.
+func ClaimsToIdentity(p *models.SSOProvider, claims jwt.MapClaims, userinfo map[string]interface{}) (*Identity, error) {
+	get := func(name string) interface{} {
+		if v, ok := claims[name]; ok {
+			return v
+		}
+		if userinfo != nil {
+			// userinfo must describe the same subject as the ID token
+			if sub, _ := userinfo["sub"].(string); sub == claims["sub"] {
+				return userinfo[name]
+			}
+		}
+		return nil
+	}
+	str := func(name, fallback string) string {
+		if name == "" {
+			name = fallback
+		}
+		s, _ := get(name).(string)
+		return strings.TrimSpace(s)
+	}
+
+	identity := &Identity{
+		Subject:  str("sub", "sub"),
+		Username: str(p.UsernameClaim, "preferred_username"),
+		Email:    strings.ToLower(str(p.EmailClaim, "email")),
+		Name:     str(p.NameClaim, "name"),
+	}
+	if identity.Subject == "" {
+		return nil, errors.New("ID token has no subject")
+	}
+	// Only an explicit email_verified=true lets the email match an existing account;
+	// some IdPs send it as a string
+	switch verified := get("email_verified").(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = strings.EqualFold(verified, "true")
+	}
+
+	groupsClaim := p.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch groups := get(groupsClaim).(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	case string:
+		for _, g := range strings.Split(groups, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				identity.Groups = append(identity.Groups, g)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// signingKey returns the JWKS key with the given ID, refetching once if it is unknown (key rotation)
+func signingKey(jwksURI, kid string) (interface{}, error) {
+	cacheMu.Lock()
+	cached, ok := jwksCache[jwksURI]
+	cacheMu.Unlock()
+
+	if ok && time.Since(cached.fetchedAt) < jwksCacheTTL {
+		if key := pickKey(cached.keys, kid); key != nil {
+			return key, nil
+		}
+		if time.Since(cached.fetchedAt) < jwksMinRefetch {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+
+	keys, err := fetchJWKS(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	jwksCache[jwksURI] = cachedJWKS{keys: keys, fetchedAt: time.Now()}
+	cacheMu.Unlock()
+
+	if key := pickKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// pickKey finds a key by ID; tokens without a kid are accepted only when the set has a single key
+func pickKey(keys map[string]interface{}, kid string) interface{} {
+	if key, ok := keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func fetchJWKS(jwksURI string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(jwksURI, "", &set); err != nil {
+		return nil, fmt.Errorf("JWKS fetch failed: %v", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+func getJSON(endpoint, bearer string, dest interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dest)
+}
@@ -0,0 +1,246 @@
+package sso
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/middleware"
+	"github.com/proisp/backend/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	stateKeyPrefix     = "proisp:sso:state:"
+	loginCodeKeyPrefix = "proisp:sso:code:"
+	stateTTL           = 10 * time.Minute
+	loginCodeTTL       = time.Minute
+)
+
+// LoginState is kept server-side between the redirect to the IdP and the callback
+type LoginState struct {
+	ProviderID  uint   `json:"provider_id"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirect_url"`
+	ReturnTo    string `json:"return_to"`
+}
+
+// LoginCode hands a completed SSO login from the callback redirect to the SPA
+type LoginCode struct {
+	UserID     uint `json:"user_id"`
+	ProviderID uint `json:"provider_id"`
+}
+
+// RandomString returns n random bytes hex-encoded
+func RandomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SaveState stores a login state and returns its key (the OAuth "state" parameter)
+func SaveState(state *LoginState) (string, error) {
+	key := RandomString(24)
+	if err := database.CacheSet(stateKeyPrefix+key, state, stateTTL); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// TakeState returns and deletes a login state; each state can be used once
+func TakeState(key string) (*LoginState, error) {
+	var state LoginState
+	if err := database.CacheTake(stateKeyPrefix+key, &state); err != nil {
+		return nil, errors.New("login request expired or was already used, please try again")
+	}
+	return &state, nil
+}
+
+// SaveLoginCode stores a completed login for a short time and returns the one-time code
+func SaveLoginCode(code *LoginCode) (string, error) {
+	key := RandomString(24)
+	if err := database.CacheSet(loginCodeKeyPrefix+key, code, loginCodeTTL); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// TakeLoginCode returns and deletes a login code
+func TakeLoginCode(key string) (*LoginCode, error) {
+	var code LoginCode
+	if err := database.CacheTake(loginCodeKeyPrefix+key, &code); err != nil {
+		return nil, errors.New("invalid or expired login code")
+	}
+	return &code, nil
+}
+
+// ResolveUser finds (or provisions) the panel user for an SSO identity and applies role mappings.
+// Returned errors are safe to show to the person logging in.
+func ResolveUser(p *models.SSOProvider, identity *Identity) (*models.User, error) {
+	if identity.Email != "" && !p.AllowsEmail(identity.Email) {
+		return nil, fmt.Errorf("%s is not allowed to log in with %s", identity.Email, p.Name)
+	}
+	if identity.Email == "" && strings.TrimSpace(p.AllowedDomains) != "" {
+		return nil, errors.New("your identity provider did not share an email address")
+	}
+
+	userType, permissionGroup, mapped := mapRole(p, identity.Groups)
+
+	var user models.User
+	var link models.UserIdentity
+	linked := database.DB.Where("provider_id = ? AND subject = ?", p.ID, identity.Subject).First(&link).Error == nil
+	if linked {
+		if err := database.DB.First(&user, link.UserID).Error; err != nil {
+			// The panel user was deleted; drop the stale link and treat as a first login
+			database.DB.Delete(&link)
+			linked = false
+		}
+	}
+
+	if !linked {
+		// Existing accounts are matched by verified email only: usernames are chosen by the user at most IdPs
+		found := false
+		if identity.Email != "" && identity.EmailVerified {
+			found = database.DB.Where("LOWER(email) = ? AND user_type <> ?", identity.Email, models.UserTypeSubscriber).
+				First(&user).Error == nil
+		}
+		if !found {
+			if !p.AutoProvision {
+				return nil, errors.New("no panel account is linked to this identity, ask an administrator to create one")
+			}
+			if !mapped {
+				return nil, errors.New("your identity provider groups do not grant access to this panel")
+			}
+			created, err := provisionUser(identity, userType, permissionGroup)
+			if err != nil {
+				return nil, err
+			}
+			user = *created
+		}
+		link = models.UserIdentity{UserID: user.ID, ProviderID: p.ID, Subject: identity.Subject, Email: identity.Email}
+		if err := database.DB.Create(&link).Error; err != nil {
+			return nil, errors.New("failed to link identity")
+		}
+	}
+	if mapped && p.SyncRoles {
+		applyRole(&user, userType, permissionGroup)
+	}
+
+	if p.SyncRoles && !mapped && p.DefaultUserType == 0 && len(p.Mappings()) > 0 {
+		// Removed from every mapped group at the IdP: access ends here
+		return nil, errors.New("your identity provider groups no longer grant access to this panel")
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("account is disabled")
+	}
+	if user.UserType == models.UserTypeSubscriber {
+		return nil, errors.New("subscribers cannot log in to the panel")
+	}
+	if user.UserType == models.UserTypeReseller && user.ResellerID != nil {
+		var reseller models.Reseller
+		if err := database.DB.First(&reseller, *user.ResellerID).Error; err == nil && !reseller.IsActive {
+			return nil, errors.New("reseller account is deactivated")
+		}
+	}
+
+	now := time.Now()
+	database.DB.Model(&link).Updates(map[string]interface{}{"last_login_at": now, "email": identity.Email})
+	database.DB.Model(&user).Update("last_login", now)
+	return &user, nil
+}
+
+// mapRole picks the first role mapping matching one of the groups, else the provider default
+func mapRole(p *models.SSOProvider, groups []string) (models.UserType, *uint, bool) {
+	for _, m := range p.Mappings() {
+		for _, g := range groups {
+			if strings.EqualFold(g, m.Group) {
+				return m.UserType, m.PermissionGroup, true
+			}
+		}
+	}
+	if p.DefaultUserType != 0 {
+		return p.DefaultUserType, p.DefaultPermissionGroup, true
+	}
+	return 0, nil, false
+}
+
+// applyRole updates a linked user's type and permission group from the IdP.
+// Resellers are tied to a reseller account and are never re-typed.
+func applyRole(user *models.User, userType models.UserType, permissionGroup *uint) {
+	if user.UserType == models.UserTypeReseller || userType == models.UserTypeReseller {
+		return
+	}
+	if user.UserType == userType && equalUintPtr(user.PermissionGroup, permissionGroup) {
+		return
+	}
+	database.DB.Model(user).Updates(map[string]interface{}{
+		"user_type":        userType,
+		"permission_group": permissionGroup,
+	})
+	user.UserType = userType
+	user.PermissionGroup = permissionGroup
+	middleware.InvalidatePermissionCache()
+}
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// provisionUser creates a staff user on first SSO login (just-in-time provisioning)
+func provisionUser(identity *Identity, userType models.UserType, permissionGroup *uint) (*models.User, error) {
+	if userType == models.UserTypeReseller || userType == models.UserTypeSubscriber || userType == 0 {
+		return nil, errors.New("this role cannot be provisioned automatically, ask an administrator to create your account")
+	}
+
+	base := identity.Username
+	if base == "" && identity.Email != "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	base = strings.Trim(usernameCleaner.ReplaceAllString(base, ""), ".-_")
+	if base == "" {
+		base = "sso-user"
+	}
+	if len(base) > 90 {
+		base = base[:90]
+	}
+	username := base
+	for i := 2; ; i++ {
+		var count int64
+		database.DB.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			break
+		}
+		username = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	// SSO users get an unusable random password; they can be given one later for local login
+	hashed, err := bcrypt.GenerateFromPassword([]byte(RandomString(32)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := models.User{
+		Username:        username,
+		Password:        string(hashed),
+		Email:           identity.Email,
+		FullName:        identity.Name,
+		UserType:        userType,
+		IsActive:        true,
+		PermissionGroup: permissionGroup,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return nil, errors.New("failed to create account")
+	}
+	return &user, nil
+}
+
+func equalUintPtr(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,430 @@
+// Package webauthn implements the relying party side of WebAuthn (security keys and passkeys)
+// as a second login factor. Attestation statements are not verified: the panel asks for
+// "none" attestation and trusts whichever authenticator the user registers.
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+)
+
+// COSE algorithm identifiers accepted for credentials
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+// Timeout is how long the browser prompt stays open, in milliseconds
+const Timeout = 120000
+
+// CredentialParameter is one acceptable key type in CreationOptions
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CredentialDescriptor identifies a registered credential
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CreationOptions is passed to navigator.credentials.create() as publicKey (binary fields base64url)
+type CreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions is passed to navigator.credentials.get() as publicKey (binary fields base64url)
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int                    `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CredentialResponse is a PublicKeyCredential serialized by the browser (binary fields base64url)
+type CredentialResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"raw_id"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"client_data_json"`
+		AttestationObject string `json:"attestation_object,omitempty"`
+		AuthenticatorData string `json:"authenticator_data,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"user_handle,omitempty"`
+	} `json:"response"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// Credential is the result of a successful registration
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte // COSE_Key
+	Algorithm      int
+	SignCount      uint32
+	AAGUID         []byte
+	BackupEligible bool
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	publicKey []byte
+}
+
+// NewCreationOptions builds registration options; exclude lists credentials the user already has
+func NewCreationOptions(challenge []byte, rpID, rpName string, userHandle []byte, name, displayName string, exclude []CredentialDescriptor) *CreationOptions {
+	opts := &CreationOptions{
+		Challenge: Encode(challenge),
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            Timeout,
+		ExcludeCredentials: exclude,
+		Attestation:        "none",
+	}
+	if opts.ExcludeCredentials == nil {
+		opts.ExcludeCredentials = []CredentialDescriptor{}
+	}
+	opts.RP.ID = rpID
+	opts.RP.Name = rpName
+	opts.User.ID = Encode(userHandle)
+	opts.User.Name = name
+	opts.User.DisplayName = displayName
+	opts.AuthenticatorSelection.ResidentKey = "discouraged"
+	opts.AuthenticatorSelection.UserVerification = "discouraged"
+	return opts
+}
+
+// NewRequestOptions builds login options limited to the given credentials
+func NewRequestOptions(challenge []byte, rpID string, allow []CredentialDescriptor) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        Encode(challenge),
+		Timeout:          Timeout,
+		RPID:             rpID,
+		AllowCredentials: allow,
+		UserVerification: "discouraged",
+	}
+}
+
+// Encode returns unpadded base64url, the encoding used for all binary values on the wire
+func Encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode accepts base64url with or without padding
+func Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// VerifyRegistration checks a navigator.credentials.create() response against the
+// expected challenge and relying party ID and returns the new credential
+func VerifyRegistration(resp *CredentialResponse, challenge []byte, rpID string) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, errors.New("unexpected credential type")
+	}
+	if err := verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge, rpID); err != nil {
+		return nil, err
+	}
+
+	raw, err := Decode(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("invalid attestation object encoding")
+	}
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %v", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	authDataRaw, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+
+	authData, err := parseAuthenticatorData(authDataRaw)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(authData, rpID); err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedData == 0 || len(authData.credID) == 0 {
+		return nil, errors.New("authenticator did not return a credential")
+	}
+	if rawID, err := Decode(resp.RawID); err == nil && len(rawID) > 0 && !bytes.Equal(rawID, authData.credID) {
+		return nil, errors.New("credential ID mismatch")
+	}
+
+	_, alg, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:             authData.credID,
+		PublicKey:      authData.publicKey,
+		Algorithm:      alg,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+	}, nil
+}
+
+// VerifyAssertion checks a navigator.credentials.get() response made with a stored credential
+// and returns the authenticator's new signature counter
+func VerifyAssertion(resp *CredentialResponse, challenge []byte, rpID string, publicKey []byte, storedCount uint32) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, errors.New("unexpected credential type")
+	}
+	if err := verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge, rpID); err != nil {
+		return 0, err
+	}
+
+	authDataRaw, err := Decode(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, errors.New("invalid authenticator data encoding")
+	}
+	authData, err := parseAuthenticatorData(authDataRaw)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkAuthenticatorData(authData, rpID); err != nil {
+		return 0, err
+	}
+
+	signature, err := Decode(resp.Response.Signature)
+	if err != nil || len(signature) == 0 {
+		return 0, errors.New("invalid signature encoding")
+	}
+	clientDataRaw, _ := Decode(resp.Response.ClientDataJSON)
+	clientDataHash := sha256.Sum256(clientDataRaw)
+	signed := append(append([]byte(nil), authDataRaw...), clientDataHash[:]...)
+
+	key, alg, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	if !verifySignature(key, alg, signed, signature) {
+		return 0, errors.New("signature verification failed")
+	}
+
+	// Counters only ever increase; a step backwards means the key may have been cloned.
+	// Authenticators without a counter (most passkeys) always report zero.
+	if (authData.signCount != 0 || storedCount != 0) && authData.signCount <= storedCount {
+		return 0, errors.New("authenticator signature counter went backwards")
+	}
+	return authData.signCount, nil
+}
+
+func verifyClientData(encoded, ceremony string, challenge []byte, rpID string) error {
+	raw, err := Decode(encoded)
+	if err != nil {
+		return errors.New("invalid client data encoding")
+	}
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return errors.New("invalid client data")
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("unexpected ceremony type %q", data.Type)
+	}
+	got, err := Decode(data.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return errors.New("challenge mismatch")
+	}
+	if data.CrossOrigin {
+		return errors.New("cross-origin requests are not allowed")
+	}
+	return checkOrigin(data.Origin, rpID)
+}
+
+// checkOrigin requires the page origin to be the relying party ID or one of its subdomains,
+// over HTTPS (plain HTTP is only accepted for localhost, as browsers do)
+func checkOrigin(origin, rpID string) error {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return errors.New("invalid origin")
+	}
+	host := strings.ToLower(u.Hostname())
+	if u.Scheme != "https" && !(u.Scheme == "http" && host == "localhost") {
+		return fmt.Errorf("origin %s is not secure", origin)
+	}
+	rpID = strings.ToLower(rpID)
+	if host != rpID && !strings.HasSuffix(host, "."+rpID) {
+		return fmt.Errorf("origin %s does not belong to %s", origin, rpID)
+	}
+	return nil
+}
+
+func checkAuthenticatorData(authData *authenticatorData, rpID string) error {
+	expected := sha256.Sum256([]byte(rpID))
+	if subtle.ConstantTimeCompare(authData.rpIDHash, expected[:]) != 1 {
+		return errors.New("relying party ID mismatch")
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return errors.New("user presence was not confirmed")
+	}
+	return nil
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data too short")
+	}
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data too short")
+		}
+		authData.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen > 1023 || len(rest) < idLen {
+			return nil, errors.New("invalid credential ID length")
+		}
+		authData.credID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential public key: %v", err)
+		}
+		authData.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if authData.flags&flagExtensionData != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension data: %v", err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("unexpected trailing authenticator data")
+	}
+	return authData, nil
+}
+
+// parseCOSEKey reads a COSE_Key (RFC 9053) for one of the supported algorithms
+func parseCOSEKey(data []byte) (crypto.PublicKey, int, error) {
+	decoded, rest, err := decodeCBOR(data)
+	if err != nil || len(rest) != 0 {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+	m, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("invalid COSE key")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("unsupported EC2 key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, errors.New("EC2 key is not on the curve")
+		}
+		return key, AlgES256, nil
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), AlgEdDSA, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("unsupported RSA key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, AlgRS256, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported key type %d / algorithm %d", kty, alg)
+}
+
+func verifySignature(key crypto.PublicKey, alg int, data, signature []byte) bool {
+	switch alg {
+	case AlgES256:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key.(*ecdsa.PublicKey), digest[:], signature)
+	case AlgEdDSA:
+		return ed25519.Verify(key.(ed25519.PublicKey), data, signature)
+	case AlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/models"
+)
+
+// SubscriberData is the subscriber shape sent in event payloads (no credentials)
+func SubscriberData(sub *models.Subscriber) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":              sub.ID,
+		"username":        sub.Username,
+		"full_name":       sub.FullName,
+		"email":           sub.Email,
+		"phone":           sub.Phone,
+		"status":          subscriberStatusName(sub.Status),
+		"service_id":      sub.ServiceID,
+		"reseller_id":     sub.ResellerID,
+		"expiry_date":     sub.ExpiryDate,
+		"price":           sub.Price,
+		"connection_type": sub.ConnectionType,
+		"is_online":       sub.IsOnline,
+		"ip_address":      sub.IPAddress,
+		"mac_address":     sub.MACAddress,
+	}
+	if sub.Service != nil {
+		data["service_name"] = sub.Service.Name
+	}
+	return data
+}
+
+// EmitSubscriber sends a subscriber event with the subscriber's current state
+func EmitSubscriber(event string, sub *models.Subscriber, extra map[string]interface{}) {
+	data := SubscriberData(sub)
+	for k, v := range extra {
+		data[k] = v
+	}
+	Emit(event, data)
+}
+
+// EmitSubscriberByUsername loads the subscriber first; used where only the username is known (accounting)
+func EmitSubscriberByUsername(event, username string, extra map[string]interface{}) {
+	var sub models.Subscriber
+	if err := database.DB.Preload("Service").Where("username = ?", username).First(&sub).Error; err != nil {
+		return
+	}
+	EmitSubscriber(event, &sub, extra)
+}
+
+func subscriberStatusName(status models.SubscriberStatus) string {
+	switch status {
+	case models.SubscriberStatusActive:
+		return "active"
+	case models.SubscriberStatusInactive:
+		return "inactive"
+	case models.SubscriberStatusExpired:
+		return "expired"
+	case models.SubscriberStatusStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
@@ -0,0 +1,272 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/proisp/backend/internal/database"
+	"github.com/proisp/backend/internal/events"
+	"github.com/proisp/backend/internal/models"
+)
+
+// Headers sent with every delivery. The signature is HMAC-SHA256 over "<timestamp>.<body>".
+const (
+	HeaderEvent     = "X-ProISP-Event"
+	HeaderDelivery  = "X-ProISP-Delivery"
+	HeaderTimestamp = "X-ProISP-Timestamp"
+	HeaderSignature = "X-ProISP-Signature"
+)
+
+const (
+	retryBaseDelay   = 30 * time.Second
+	retryMaxDelay    = 6 * time.Hour
+	maxResponseBody  = 2048
+	endpointCacheTTL = 30 * time.Second
+)
+
+// Payload is the JSON body posted to endpoints
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Endpoints are cached briefly so accounting traffic doesn't query them on every packet.
+// The RADIUS server runs in its own process, so changes are picked up on expiry rather than invalidation.
+var (
+	cacheMu        sync.Mutex
+	cachedAt       time.Time
+	endpointsCache []models.WebhookEndpoint
+)
+
+func activeEndpoints() []models.WebhookEndpoint {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if time.Since(cachedAt) < endpointCacheTTL {
+		return endpointsCache
+	}
+	var endpoints []models.WebhookEndpoint
+	if err := database.DB.Where("is_active = ?", true).Find(&endpoints).Error; err != nil {
+		log.Printf("Webhook: Failed to load endpoints: %v", err)
+		return endpointsCache
+	}
+	endpointsCache = endpoints
+	cachedAt = time.Now()
+	return endpoints
+}
+
+// InvalidateCache drops the endpoint cache after endpoints are changed
+func InvalidateCache() {
+	cacheMu.Lock()
+	cachedAt = time.Time{}
+	cacheMu.Unlock()
+}
+
+// Emit queues an event for every active endpoint subscribed to it and publishes it on
+// the in-process event bus. The payload is built immediately; queueing happens in the
+// background so callers never block.
+func Emit(event string, data interface{}) {
+	events.Publish(event, data)
+
+	payload := Payload{
+		ID:        newEventID(),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Webhook: Failed to encode %s event: %v", event, err)
+		return
+	}
+
+	go func() {
+		resellerID, parentID := eventReseller(data)
+		for _, endpoint := range activeEndpoints() {
+			if !endpoint.Subscribes(event) || !endpoint.Receives(resellerID, parentID) {
+				continue
+			}
+			delivery := models.WebhookDelivery{
+				EndpointID:    endpoint.ID,
+				EventID:       payload.ID,
+				Event:         event,
+				Payload:       string(body),
+				Status:        models.WebhookStatusPending,
+				NextAttemptAt: time.Now(),
+			}
+			if err := database.DB.Create(&delivery).Error; err != nil {
+				log.Printf("Webhook: Failed to queue %s for endpoint %s: %v", event, endpoint.Name, err)
+			}
+		}
+	}()
+}
+
+// eventReseller returns the reseller an event belongs to, from the reseller_id of its
+// data or else the reseller of its subscriber_id, and that reseller's parent, so
+// endpoints of a parent reseller see its sub-resellers' events. Events without a
+// reseller return 0.
+func eventReseller(data interface{}) (uint, *uint) {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return 0, nil
+	}
+	resellerID := fieldID(fields["reseller_id"])
+	if resellerID == 0 {
+		if subscriberID := fieldID(fields["subscriber_id"]); subscriberID != 0 {
+			var ids []uint
+			database.DB.Model(&models.Subscriber{}).Where("id = ?", subscriberID).Pluck("reseller_id", &ids)
+			if len(ids) > 0 {
+				resellerID = ids[0]
+			}
+		}
+	}
+	if resellerID == 0 {
+		return 0, nil
+	}
+
+	var reseller models.Reseller
+	if err := database.DB.Select("id, parent_id").First(&reseller, resellerID).Error; err != nil {
+		return resellerID, nil
+	}
+	return resellerID, reseller.ParentID
+}
+
+func fieldID(value interface{}) uint {
+	switch id := value.(type) {
+	case uint:
+		return id
+	case *uint:
+		if id != nil {
+			return *id
+		}
+	}
+	return 0
+}
+
+// Sign returns the signature header value for a payload
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryDelay is the wait before the next attempt: 30s doubling per attempt, capped at 6h
+func RetryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= retryMaxDelay {
+			return retryMaxDelay
+		}
+	}
+	return delay
+}
+
+// Deliver posts a delivery to its endpoint and records the outcome.
+// Failures are rescheduled with backoff until the endpoint's MaxAttempts, then marked dead.
+func Deliver(endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	code, respBody, err := post(endpoint, delivery)
+	delivery.ResponseCode = code
+	delivery.ResponseBody = respBody
+
+	endpointUpdates := map[string]interface{}{}
+	if err == nil {
+		delivery.Status = models.WebhookStatusSuccess
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		endpointUpdates["last_success_at"] = now
+	} else {
+		delivery.LastError = err.Error()
+		maxAttempts := endpoint.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = 8
+		}
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = models.WebhookStatusDead
+			log.Printf("Webhook: %s to %s failed after %d attempts, moved to dead letters: %v",
+				delivery.Event, endpoint.Name, delivery.Attempts, err)
+		} else {
+			delivery.Status = models.WebhookStatusPending
+			delivery.NextAttemptAt = now.Add(RetryDelay(delivery.Attempts))
+		}
+		endpointUpdates["last_failure_at"] = now
+		endpointUpdates["last_error"] = err.Error()
+	}
+
+	database.DB.Model(&models.WebhookEndpoint{}).Where("id = ?", endpoint.ID).UpdateColumns(endpointUpdates)
+	if saveErr := database.DB.Save(delivery).Error; saveErr != nil {
+		log.Printf("Webhook: Failed to save delivery %d: %v", delivery.ID, saveErr)
+	}
+	return err
+}
+
+func post(endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ProISP-Webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.EventID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if endpoint.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+	}
+
+	timeout := time.Duration(endpoint.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(respBody), fmt.Errorf("endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(respBody), nil
+}
+
+// NewSecret generates a signing secret for a new endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "evt_" + hex.EncodeToString(b)
+}
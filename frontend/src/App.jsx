@@ -8,6 +8,7 @@ import Login from './pages/Login'
 import Dashboard from './pages/Dashboard'
 import CustomerPortal from './pages/CustomerPortal'
 import ChangePassword from './pages/ChangePassword'
+import TwoFactorSetup from './pages/TwoFactorSetup'
 import Impersonate from './pages/Impersonate'
 
 // Lazy load all other pages for code splitting
@@ -130,6 +131,7 @@ function App() {
       <Route path="/login" element={<Login />} />
       <Route path="/impersonate" element={<Impersonate />} />
       <Route path="/change-password" element={<ChangePassword />} />
+      <Route path="/setup-2fa" element={<TwoFactorSetup />} />
       <Route
         path="/*"
         element={
@@ -0,0 +1,234 @@
+import { useState, useEffect } from 'react'
+import { useNavigate } from 'react-router-dom'
+import toast from 'react-hot-toast'
+import api from '../services/api'
+import { useAuthStore } from '../store/authStore'
+import { DevicePhoneMobileIcon, XMarkIcon } from '@heroicons/react/24/outline'
+
+const channelLabels = { sms: 'SMS', whatsapp: 'WhatsApp' }
+
+const inputStyle = {
+  width: '100%', boxSizing: 'border-box', padding: '9px 10px', fontSize: 14, fontFamily: 'inherit',
+  border: '1px solid #ccc', borderRadius: 6, background: '#fff', color: '#000', outline: 'none',
+}
+
+const buttonStyle = (disabled, color) => ({
+  width: '100%', padding: '9px', fontSize: 14, fontWeight: 600, fontFamily: 'inherit',
+  color: '#fff', background: color || '#4a7ab5', border: 'none', borderRadius: 6,
+  cursor: disabled ? 'not-allowed' : 'pointer', opacity: disabled ? 0.7 : 1,
+})
+
+const linkStyle = { background: 'none', border: 'none', color: '#4a7ab5', cursor: 'pointer', fontSize: 12, padding: 0 }
+
+// Passwordless customer login: phone number -> one-time code -> account picker when the
+// number owns several subscriptions
+export default function CustomerOTPLogin({ primaryColor }) {
+  const navigate = useNavigate()
+  const { completeCustomerLogin } = useAuthStore()
+  const [channels, setChannels] = useState([])
+  const [open, setOpen] = useState(false)
+  const [step, setStep] = useState('phone') // phone | code | account
+  const [phone, setPhone] = useState('')
+  const [channel, setChannel] = useState('sms')
+  const [code, setCode] = useState('')
+  const [accounts, setAccounts] = useState([])
+  const [selectionToken, setSelectionToken] = useState('')
+  const [resendIn, setResendIn] = useState(0)
+  const [loading, setLoading] = useState(false)
+
+  useEffect(() => {
+    api.get('/customer/otp/channels')
+      .then((res) => {
+        const data = res.data.data || {}
+        if (data.enabled) {
+          setChannels(data.channels || [])
+          setChannel((data.channels || ['sms'])[0])
+        }
+      })
+      .catch(() => {})
+  }, [])
+
+  useEffect(() => {
+    if (resendIn <= 0) return
+    const timer = setTimeout(() => setResendIn(resendIn - 1), 1000)
+    return () => clearTimeout(timer)
+  }, [resendIn])
+
+  if (channels.length === 0) return null
+
+  const reset = () => {
+    setOpen(false)
+    setStep('phone')
+    setCode('')
+    setAccounts([])
+    setSelectionToken('')
+  }
+
+  const finish = (data) => {
+    completeCustomerLogin(data.token, data.customer)
+    toast.success('Login successful')
+    navigate('/portal')
+  }
+
+  const requestCode = async (e) => {
+    e?.preventDefault()
+    setLoading(true)
+    try {
+      const res = await api.post('/customer/otp/request', { phone, channel })
+      toast.success(res.data.message || 'Code sent')
+      setResendIn(res.data.data?.resend_in || 60)
+      setStep('code')
+    } catch (err) {
+      toast.error(err.response?.data?.message || 'Failed to send code')
+    }
+    setLoading(false)
+  }
+
+  const verifyCode = async (e) => {
+    e.preventDefault()
+    setLoading(true)
+    try {
+      const res = await api.post('/customer/otp/verify', { phone, code })
+      if (res.data.requires_selection) {
+        setAccounts(res.data.accounts || [])
+        setSelectionToken(res.data.selection_token)
+        setStep('account')
+      } else {
+        finish(res.data)
+      }
+    } catch (err) {
+      toast.error(err.response?.data?.message || 'Invalid code')
+      setCode('')
+    }
+    setLoading(false)
+  }
+
+  const selectAccount = async (username) => {
+    setLoading(true)
+    try {
+      const res = await api.post('/customer/otp/select', { selection_token: selectionToken, username })
+      finish(res.data)
+    } catch (err) {
+      toast.error(err.response?.data?.message || 'Login failed')
+      reset()
+    }
+    setLoading(false)
+  }
+
+  return (
+    <>
+      <div style={{ textAlign: 'center', marginTop: 10 }}>
+        <button type="button" onClick={() => setOpen(true)} style={{ ...linkStyle, display: 'inline-flex', alignItems: 'center', gap: 4 }}>
+          <DevicePhoneMobileIcon style={{ width: 14, height: 14 }} />
+          Customer? Sign in with a code sent to your phone
+        </button>
+      </div>
+
+      {open && (
+        <div style={{
+          position: 'fixed', inset: 0, zIndex: 50, background: 'rgba(0,0,0,0.45)',
+          display: 'flex', alignItems: 'center', justifyContent: 'center', padding: 16,
+        }}>
+          <div style={{ width: '100%', maxWidth: 360, background: '#fff', borderRadius: 10, padding: 20, fontSize: 13, color: '#000' }}>
+            <div style={{ display: 'flex', alignItems: 'center', justifyContent: 'space-between', marginBottom: 14 }}>
+              <div style={{ fontWeight: 600, fontSize: 15 }}>
+                {step === 'account' ? 'Choose an account' : 'Sign in with your phone'}
+              </div>
+              <button type="button" onClick={reset} style={{ background: 'none', border: 'none', cursor: 'pointer', padding: 0 }}>
+                <XMarkIcon style={{ width: 18, height: 18, color: '#666' }} />
+              </button>
+            </div>
+
+            {step === 'phone' && (
+              <form onSubmit={requestCode}>
+                <label style={{ display: 'block', marginBottom: 4, color: '#555' }}>Phone number</label>
+                <input
+                  type="tel"
+                  required
+                  value={phone}
+                  onChange={(e) => setPhone(e.target.value)}
+                  placeholder="e.g. 0770 123 4567"
+                  autoComplete="tel"
+                  autoFocus
+                  style={inputStyle}
+                />
+                {channels.length > 1 && (
+                  <div style={{ display: 'flex', gap: 12, margin: '10px 0 0' }}>
+                    {channels.map((ch) => (
+                      <label key={ch} style={{ display: 'flex', alignItems: 'center', gap: 4, cursor: 'pointer' }}>
+                        <input type="radio" name="otp-channel" checked={channel === ch} onChange={() => setChannel(ch)} />
+                        {channelLabels[ch] || ch}
+                      </label>
+                    ))}
+                  </div>
+                )}
+                <div style={{ marginTop: 14 }}>
+                  <button type="submit" disabled={loading} style={buttonStyle(loading, primaryColor)}>
+                    {loading ? 'Sending...' : `Send code via ${channelLabels[channel] || channel}`}
+                  </button>
+                </div>
+              </form>
+            )}
+
+            {step === 'code' && (
+              <form onSubmit={verifyCode}>
+                <div style={{ color: '#555', marginBottom: 10 }}>
+                  Enter the 6-digit code sent to {phone} via {channelLabels[channel] || channel}.
+                </div>
+                <input
+                  type="text"
+                  required
+                  value={code}
+                  onChange={(e) => setCode(e.target.value.replace(/\D/g, '').slice(0, 6))}
+                  placeholder="000000"
+                  maxLength={6}
+                  autoComplete="one-time-code"
+                  autoFocus
+                  style={{ ...inputStyle, textAlign: 'center', fontSize: 20, letterSpacing: 6, fontFamily: 'monospace' }}
+                />
+                <div style={{ marginTop: 14 }}>
+                  <button type="submit" disabled={loading || code.length !== 6} style={buttonStyle(loading || code.length !== 6, primaryColor)}>
+                    {loading ? 'Verifying...' : 'Verify & Sign In'}
+                  </button>
+                </div>
+                <div style={{ display: 'flex', justifyContent: 'space-between', marginTop: 10 }}>
+                  <button type="button" onClick={() => { setStep('phone'); setCode('') }} style={linkStyle}>
+                    Change number
+                  </button>
+                  <button type="button" onClick={requestCode} disabled={loading || resendIn > 0} style={{ ...linkStyle, color: resendIn > 0 ? '#999' : '#4a7ab5' }}>
+                    {resendIn > 0 ? `Resend in ${resendIn}s` : 'Resend code'}
+                  </button>
+                </div>
+              </form>
+            )}
+
+            {step === 'account' && (
+              <div>
+                <div style={{ color: '#555', marginBottom: 10 }}>
+                  This number is linked to several subscriptions. Which one do you want to manage?
+                </div>
+                {accounts.map((acc) => (
+                  <button
+                    key={acc.username}
+                    type="button"
+                    disabled={loading}
+                    onClick={() => selectAccount(acc.username)}
+                    style={{
+                      display: 'block', width: '100%', textAlign: 'left', padding: '9px 10px', marginBottom: 6,
+                      background: '#f5f5f5', border: '1px solid #ddd', borderRadius: 6, cursor: 'pointer', fontFamily: 'inherit',
+                    }}
+                  >
+                    <div style={{ fontWeight: 600, fontSize: 13 }}>{acc.username}</div>
+                    <div style={{ fontSize: 12, color: '#666' }}>
+                      {[acc.full_name, acc.service_name].filter(Boolean).join(' · ')}
+                    </div>
+                  </button>
+                ))}
+              </div>
+            )}
+          </div>
+        </div>
+      )}
+    </>
+  )
+}
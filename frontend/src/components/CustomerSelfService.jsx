@@ -0,0 +1,343 @@
+import { useState, useEffect } from 'react'
+import api from '../services/api'
+import toast from 'react-hot-toast'
+
+const money = (value) => `$${(value || 0).toFixed(2)}`
+
+const errorMessage = (err, fallback) => err.response?.data?.message || fallback
+
+// Self-service actions for the customer portal; which sections show depends on the
+// portal permissions the subscriber's reseller has enabled
+export default function CustomerSelfService({ dashboard, onChanged }) {
+  const [info, setInfo] = useState(null)
+  const [wallet, setWallet] = useState(null)
+  const [plans, setPlans] = useState([])
+  const [busy, setBusy] = useState('')
+
+  const [topUpCard, setTopUpCard] = useState({ code: '', pin: '' })
+  const [renewMethod, setRenewMethod] = useState('wallet')
+  const [renewCard, setRenewCard] = useState({ code: '', pin: '' })
+  const [contact, setContact] = useState({ email: '', phone: '', address: '' })
+  const [verification, setVerification] = useState(null)
+  const [verifyCode, setVerifyCode] = useState('')
+  const [passwords, setPasswords] = useState({ current_password: '', new_password: '', confirm: '' })
+
+  const perms = info?.permissions || {}
+
+  const fetchInfo = async () => {
+    try {
+      const res = await api.get('/customer/self-service')
+      if (res.data.success) {
+        setInfo(res.data.data)
+        if (res.data.data.permissions?.allow_change_plan) {
+          const plansRes = await api.get('/customer/plans')
+          if (plansRes.data.success) setPlans(plansRes.data.data || [])
+        }
+      }
+      const walletRes = await api.get('/customer/wallet')
+      if (walletRes.data.success) setWallet(walletRes.data.data)
+    } catch (err) {
+      console.error('Failed to fetch self-service info', err)
+    }
+  }
+
+  useEffect(() => {
+    fetchInfo()
+  }, [])
+
+  useEffect(() => {
+    if (dashboard) {
+      setContact({ email: dashboard.email || '', phone: dashboard.phone || '', address: dashboard.address || '' })
+    }
+  }, [dashboard])
+
+  const run = async (key, request, onSuccess) => {
+    setBusy(key)
+    try {
+      const res = await request()
+      if (res.data.success) {
+        toast.success(res.data.message || 'Done')
+        onSuccess?.(res.data.data)
+        fetchInfo()
+        onChanged?.()
+      }
+    } catch (err) {
+      toast.error(errorMessage(err, 'Request failed'))
+    } finally {
+      setBusy('')
+    }
+  }
+
+  const handleTopUp = (e) => {
+    e.preventDefault()
+    run('topup', () => api.post('/customer/wallet/topup', topUpCard), () => setTopUpCard({ code: '', pin: '' }))
+  }
+
+  const handleRenew = (e) => {
+    e.preventDefault()
+    const body = renewMethod === 'card' ? { method: 'card', ...renewCard } : { method: 'wallet' }
+    if (renewMethod === 'wallet' && !confirm(`Renew for ${money(info?.renewal_price)} from your wallet?`)) return
+    run('renew', () => api.post('/customer/renew', body), () => setRenewCard({ code: '', pin: '' }))
+  }
+
+  const handleChangePlan = (plan) => {
+    const charge = plan.quote?.total_charge || 0
+    const text = charge < 0
+      ? `Change to ${plan.name}? ${money(-charge)} will be credited to your wallet.`
+      : `Change to ${plan.name} for ${money(charge)}? You will be reconnected.`
+    if (!confirm(text)) return
+    run(`plan-${plan.id}`, () => api.post(`/customer/plans/${plan.id}/change`))
+  }
+
+  const handleResetFUP = () => {
+    if (!confirm(`Reset your daily FUP for ${money(info?.reset_fup_price)}?`)) return
+    run('fup', () => api.post('/customer/reset-fup'))
+  }
+
+  const handleContact = (e) => {
+    e.preventDefault()
+    run('contact', () => api.put('/customer/profile', contact), (data) => {
+      if (data?.verification_required) setVerification(data.sent_to || [])
+    })
+  }
+
+  const handleVerify = (e) => {
+    e.preventDefault()
+    run('verify', () => api.post('/customer/profile/verify', { code: verifyCode }), () => {
+      setVerification(null)
+      setVerifyCode('')
+    })
+  }
+
+  const handlePassword = (e) => {
+    e.preventDefault()
+    if (passwords.new_password !== passwords.confirm) {
+      toast.error('Passwords do not match')
+      return
+    }
+    run('password', () => api.post('/customer/password', {
+      current_password: passwords.current_password,
+      new_password: passwords.new_password,
+    }), () => setPasswords({ current_password: '', new_password: '', confirm: '' }))
+  }
+
+  if (!info) {
+    return <div className="text-center text-gray-500 dark:text-gray-400 py-8 text-[12px]">Loading...</div>
+  }
+
+  const sectionTitle = 'text-[12px] font-semibold text-gray-900 dark:text-white mb-3 pb-1 border-b border-[#ccc] dark:border-[#555]'
+
+  return (
+    <div className="space-y-3">
+      {/* Wallet */}
+      <div className="card p-3">
+        <h3 className={sectionTitle}>Wallet</h3>
+        <div className="flex items-baseline gap-2 mb-3">
+          <span className="text-[11px] text-gray-500 dark:text-[#aaa]">Balance</span>
+          <span className="text-[16px] font-semibold text-gray-900 dark:text-white">{money(info.wallet_balance)}</span>
+        </div>
+        {perms.allow_wallet_topup && (
+          <form onSubmit={handleTopUp} className="flex flex-wrap items-end gap-2 mb-3">
+            <div>
+              <div className="label">Prepaid card code</div>
+              <input className="input" value={topUpCard.code} onChange={(e) => setTopUpCard({ ...topUpCard, code: e.target.value })} required />
+            </div>
+            <div>
+              <div className="label">PIN</div>
+              <input className="input w-24" value={topUpCard.pin} onChange={(e) => setTopUpCard({ ...topUpCard, pin: e.target.value })} required />
+            </div>
+            <button type="submit" className="btn btn-primary" disabled={busy === 'topup'}>Top Up</button>
+          </form>
+        )}
+        {wallet?.transactions?.length > 0 && (
+          <div className="table-container">
+            <table className="table">
+              <thead>
+                <tr>
+                  <th>Date</th>
+                  <th>Description</th>
+                  <th style={{ textAlign: 'right' }}>Amount</th>
+                  <th style={{ textAlign: 'right' }}>Balance</th>
+                </tr>
+              </thead>
+              <tbody>
+                {wallet.transactions.map((tx) => (
+                  <tr key={tx.id}>
+                    <td className="text-[11px]">{new Date(tx.created_at).toLocaleString()}</td>
+                    <td className="text-[11px]">{tx.description}</td>
+                    <td className={`text-[11px] text-right ${tx.amount < 0 ? 'text-red-600' : 'text-green-600'}`}>
+                      {tx.amount < 0 ? '-' : '+'}{money(Math.abs(tx.amount))}
+                    </td>
+                    <td className="text-[11px] text-right">{money(tx.balance_after)}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </div>
+
+      {/* Renew */}
+      {perms.allow_renew && (
+        <div className="card p-3">
+          <h3 className={sectionTitle}>Renew Subscription</h3>
+          <form onSubmit={handleRenew} className="space-y-2">
+            <div className="flex gap-4 text-[12px]">
+              <label className="flex items-center gap-1">
+                <input type="radio" checked={renewMethod === 'wallet'} onChange={() => setRenewMethod('wallet')} />
+                Pay from wallet ({money(info.renewal_price)})
+              </label>
+              <label className="flex items-center gap-1">
+                <input type="radio" checked={renewMethod === 'card'} onChange={() => setRenewMethod('card')} />
+                Prepaid card
+              </label>
+            </div>
+            {renewMethod === 'card' && (
+              <div className="flex flex-wrap items-end gap-2">
+                <div>
+                  <div className="label">Card code</div>
+                  <input className="input" value={renewCard.code} onChange={(e) => setRenewCard({ ...renewCard, code: e.target.value })} required />
+                </div>
+                <div>
+                  <div className="label">PIN</div>
+                  <input className="input w-24" value={renewCard.pin} onChange={(e) => setRenewCard({ ...renewCard, pin: e.target.value })} required />
+                </div>
+              </div>
+            )}
+            <button type="submit" className="btn btn-primary" disabled={busy === 'renew'}>Renew</button>
+          </form>
+        </div>
+      )}
+
+      {/* Change plan */}
+      {perms.allow_change_plan && (
+        <div className="card p-3">
+          <h3 className={sectionTitle}>Change Plan</h3>
+          {plans.length === 0 ? (
+            <div className="text-center text-gray-500 dark:text-gray-400 py-4 text-[12px]">No other plans available</div>
+          ) : (
+            <div className="table-container">
+              <table className="table">
+                <thead>
+                  <tr>
+                    <th>Plan</th>
+                    <th>Speed</th>
+                    <th style={{ textAlign: 'right' }}>Price</th>
+                    <th style={{ textAlign: 'right' }}>To pay now</th>
+                    <th style={{ textAlign: 'right' }}>Action</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  {plans.map((plan) => {
+                    const blocked = plan.quote?.is_downgrade && !plan.quote?.downgrade_allowed
+                    return (
+                      <tr key={plan.id}>
+                        <td className="text-[11px] font-medium">{plan.name}</td>
+                        <td className="text-[11px]">{plan.download_speed}k / {plan.upload_speed}k</td>
+                        <td className="text-[11px] text-right">{money(plan.price)}</td>
+                        <td className="text-[11px] text-right">
+                          {plan.quote?.total_charge < 0 ? `${money(-plan.quote.total_charge)} credit` : money(plan.quote?.total_charge)}
+                        </td>
+                        <td style={{ textAlign: 'right' }}>
+                          <button
+                            onClick={() => handleChangePlan(plan)}
+                            disabled={blocked || busy === `plan-${plan.id}`}
+                            title={blocked ? 'Downgrades are not allowed' : ''}
+                            className="btn btn-sm"
+                          >
+                            {plan.quote?.is_upgrade ? 'Upgrade' : plan.quote?.is_downgrade ? 'Downgrade' : 'Switch'}
+                          </button>
+                        </td>
+                      </tr>
+                    )
+                  })}
+                </tbody>
+              </table>
+            </div>
+          )}
+        </div>
+      )}
+
+      {/* FUP reset */}
+      {perms.allow_reset_fup && info.reset_fup_price > 0 && (
+        <div className="card p-3">
+          <h3 className={sectionTitle}>Reset FUP</h3>
+          <div className="flex items-center gap-3 text-[12px]">
+            <span className="text-gray-700 dark:text-[#ccc]">
+              {info.fup_active
+                ? `Your speed is reduced. Restore full speed for ${money(info.reset_fup_price)}.`
+                : 'Your speed is not currently reduced.'}
+            </span>
+            <button onClick={handleResetFUP} disabled={!info.fup_active || busy === 'fup'} className="btn btn-primary btn-sm">
+              Reset FUP
+            </button>
+          </div>
+        </div>
+      )}
+
+      {/* Contact details */}
+      {perms.allow_contact_update && (
+        <div className="card p-3">
+          <h3 className={sectionTitle}>Contact Details</h3>
+          {verification ? (
+            <form onSubmit={handleVerify} className="space-y-2">
+              <div className="text-[12px] text-gray-700 dark:text-[#ccc]">
+                Enter the code we sent to your new {verification.join(' and ')}.
+              </div>
+              <div className="flex items-end gap-2">
+                <input className="input w-32" value={verifyCode} onChange={(e) => setVerifyCode(e.target.value)} placeholder="000000" required />
+                <button type="submit" className="btn btn-primary" disabled={busy === 'verify'}>Verify</button>
+                <button type="button" className="btn" onClick={() => setVerification(null)}>Cancel</button>
+              </div>
+            </form>
+          ) : (
+            <form onSubmit={handleContact} className="grid grid-cols-1 sm:grid-cols-3 gap-2 items-end">
+              <div>
+                <div className="label">Email</div>
+                <input type="email" className="input w-full" value={contact.email} onChange={(e) => setContact({ ...contact, email: e.target.value })} />
+              </div>
+              <div>
+                <div className="label">Phone</div>
+                <input className="input w-full" value={contact.phone} onChange={(e) => setContact({ ...contact, phone: e.target.value })} />
+              </div>
+              <div>
+                <div className="label">Address</div>
+                <input className="input w-full" value={contact.address} onChange={(e) => setContact({ ...contact, address: e.target.value })} />
+              </div>
+              <div>
+                <button type="submit" className="btn btn-primary" disabled={busy === 'contact'}>Save</button>
+              </div>
+            </form>
+          )}
+        </div>
+      )}
+
+      {/* PPPoE password */}
+      {perms.allow_password_change && (
+        <div className="card p-3">
+          <h3 className={sectionTitle}>Change Password</h3>
+          <form onSubmit={handlePassword} className="grid grid-cols-1 sm:grid-cols-3 gap-2 items-end">
+            <div>
+              <div className="label">Current password</div>
+              <input type="password" className="input w-full" value={passwords.current_password} onChange={(e) => setPasswords({ ...passwords, current_password: e.target.value })} required />
+            </div>
+            <div>
+              <div className="label">New password</div>
+              <input type="password" className="input w-full" value={passwords.new_password} onChange={(e) => setPasswords({ ...passwords, new_password: e.target.value })} required minLength={6} />
+            </div>
+            <div>
+              <div className="label">Confirm new password</div>
+              <input type="password" className="input w-full" value={passwords.confirm} onChange={(e) => setPasswords({ ...passwords, confirm: e.target.value })} required minLength={6} />
+            </div>
+            <div className="sm:col-span-3 text-[11px] text-gray-500 dark:text-[#aaa]">
+              This is also your internet (PPPoE) password. Update your router after changing it.
+            </div>
+            <div>
+              <button type="submit" className="btn btn-primary" disabled={busy === 'password'}>Change Password</button>
+            </div>
+          </form>
+        </div>
+      )}
+    </div>
+  )
+}
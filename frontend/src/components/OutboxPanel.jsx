@@ -0,0 +1,179 @@
+import { useState } from 'react'
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
+import toast from 'react-hot-toast'
+import api from '../services/api'
+
+const statusBadge = {
+  pending: 'badge-info',
+  sent: 'badge-success',
+  failed: 'badge-danger',
+  cancelled: 'badge-gray',
+}
+
+const formatTime = (value) => (value ? new Date(value).toLocaleString() : '-')
+
+export default function OutboxPanel() {
+  const queryClient = useQueryClient()
+  const [status, setStatus] = useState('failed')
+  const [channel, setChannel] = useState('')
+  const [search, setSearch] = useState('')
+  const [page, setPage] = useState(1)
+
+  const { data, isLoading } = useQuery({
+    queryKey: ['communication-outbox', status, channel, search, page],
+    queryFn: () => api.get('/communication/outbox', { params: { status, channel, search, page, limit: 25 } }).then(res => res.data)
+  })
+
+  const { data: stats } = useQuery({
+    queryKey: ['communication-outbox-stats'],
+    queryFn: () => api.get('/communication/outbox/stats').then(res => res.data.data),
+    refetchInterval: 15000,
+  })
+
+  const refresh = () => {
+    queryClient.invalidateQueries(['communication-outbox'])
+    queryClient.invalidateQueries(['communication-outbox-stats'])
+  }
+
+  const resendMutation = useMutation({
+    mutationFn: (id) => api.post(`/communication/outbox/${id}/resend`),
+    onSuccess: (res) => { toast.success(res.data.message); refresh() },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to resend')
+  })
+
+  const resendAllMutation = useMutation({
+    mutationFn: () => api.post('/communication/outbox/resend', { channel }),
+    onSuccess: (res) => { toast.success(res.data.message); refresh() },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to resend')
+  })
+
+  const cancelMutation = useMutation({
+    mutationFn: (id) => api.post(`/communication/outbox/${id}/cancel`),
+    onSuccess: (res) => { toast.success(res.data.message); refresh() },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to cancel')
+  })
+
+  const messages = data?.data || []
+  const meta = data?.meta || { page: 1, totalPages: 1, total: 0 }
+  const byStatus = stats?.by_status || {}
+
+  return (
+    <div className="space-y-2">
+      <div className="wb-toolbar flex justify-between items-center">
+        <div>
+          <div className="text-[13px] font-semibold">Outbox</div>
+          <div className="text-[10px] text-gray-500 dark:text-[#aaa]">
+            Queued messages are sent with retries; failed messages can be resent
+          </div>
+        </div>
+        <div className="flex items-center gap-1">
+          <select value={channel} onChange={(e) => { setChannel(e.target.value); setPage(1) }} className="input input-sm">
+            <option value="">All channels</option>
+            <option value="sms">SMS</option>
+            <option value="whatsapp">WhatsApp</option>
+            <option value="email">Email</option>
+          </select>
+          <input
+            value={search}
+            onChange={(e) => { setSearch(e.target.value); setPage(1) }}
+            placeholder="Recipient or text"
+            className="input input-sm"
+          />
+          {status === 'failed' && (
+            <button
+              onClick={() => {
+                if (confirm('Resend all failed messages?')) {
+                  resendAllMutation.mutate()
+                }
+              }}
+              disabled={resendAllMutation.isPending || messages.length === 0}
+              className="btn btn-primary btn-sm"
+            >
+              Resend All Failed
+            </button>
+          )}
+        </div>
+      </div>
+
+      <div className="grid grid-cols-4 gap-2">
+        {['pending', 'sent', 'failed', 'cancelled'].map(s => (
+          <button
+            key={s}
+            onClick={() => { setStatus(s); setPage(1) }}
+            className={`stat-card text-left ${status === s ? 'ring-1 ring-[#316AC5]' : ''}`}
+          >
+            <div className="text-[14px] font-bold text-gray-900 dark:text-[#e0e0e0]">{byStatus[s] || 0}</div>
+            <div className="text-[10px] text-gray-500 dark:text-[#aaa] capitalize">{s}</div>
+          </button>
+        ))}
+      </div>
+
+      <div className="table-container">
+        <table className="table">
+          <thead>
+            <tr>
+              <th>Created</th>
+              <th>Channel</th>
+              <th>Recipient</th>
+              <th>Message</th>
+              <th>Source</th>
+              <th>Attempts</th>
+              <th>Status</th>
+              <th>Actions</th>
+            </tr>
+          </thead>
+          <tbody>
+            {messages.map(msg => (
+              <tr key={msg.id}>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>{formatTime(msg.created_at)}</td>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>{msg.channel.toUpperCase()}</td>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>{msg.recipient}</td>
+                <td style={{ padding: '3px 8px', fontSize: 11, maxWidth: 320 }}>
+                  {msg.subject && <div className="font-medium truncate">{msg.subject}</div>}
+                  <div className="truncate text-gray-600 dark:text-[#bbb]" title={msg.body}>{msg.body}</div>
+                  {msg.last_error && (
+                    <div className="truncate text-[10px] text-red-600" title={msg.last_error}>{msg.last_error}</div>
+                  )}
+                </td>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>{msg.source || '-'}</td>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>
+                  {msg.attempts}/{msg.max_attempts}
+                  {msg.status === 'pending' && (
+                    <div className="text-[10px] text-gray-500 dark:text-[#aaa]">Next: {formatTime(msg.next_attempt_at)}</div>
+                  )}
+                </td>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>
+                  <span className={`badge ${statusBadge[msg.status] || 'badge-gray'}`}>{msg.status}</span>
+                </td>
+                <td style={{ padding: '3px 8px', fontSize: 11 }}>
+                  {(msg.status === 'failed' || msg.status === 'cancelled') && (
+                    <button onClick={() => resendMutation.mutate(msg.id)} className="btn btn-sm" style={{ padding: '1px 4px' }}>
+                      <span className="text-[11px]">Resend</span>
+                    </button>
+                  )}
+                  {msg.status === 'pending' && (
+                    <button onClick={() => cancelMutation.mutate(msg.id)} className="btn btn-danger btn-sm" style={{ padding: '1px 4px' }}>
+                      <span className="text-[11px]">Cancel</span>
+                    </button>
+                  )}
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+
+      {!isLoading && messages.length === 0 && (
+        <div className="text-center py-2 text-[11px] text-gray-500 dark:text-[#aaa]">No {status} messages.</div>
+      )}
+
+      {meta.totalPages > 1 && (
+        <div className="flex justify-end items-center gap-1 text-[11px]">
+          <button onClick={() => setPage(p => p - 1)} disabled={page <= 1} className="btn btn-sm">Prev</button>
+          <span>Page {meta.page} of {meta.totalPages}</span>
+          <button onClick={() => setPage(p => p + 1)} disabled={page >= meta.totalPages} className="btn btn-sm">Next</button>
+        </div>
+      )}
+    </div>
+  )
+}
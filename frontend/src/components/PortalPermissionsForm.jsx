@@ -0,0 +1,68 @@
+import { useState, useEffect } from 'react'
+import { useQuery, useMutation } from '@tanstack/react-query'
+import { resellerApi } from '../services/api'
+import toast from 'react-hot-toast'
+
+const options = [
+  { key: 'allow_renew', label: 'Renew', help: 'Renew with a prepaid card or from the wallet' },
+  { key: 'allow_wallet_topup', label: 'Wallet top-up', help: 'Add prepaid card value to the wallet' },
+  { key: 'allow_change_plan', label: 'Change plan', help: 'Switch to another assigned service, paying the prorated quote from the wallet' },
+  { key: 'allow_reset_fup', label: 'Buy FUP reset', help: "Reset daily FUP at the service's reset price" },
+  { key: 'allow_contact_update', label: 'Update contact details', help: 'New email or phone must be confirmed with a code' },
+  { key: 'allow_password_change', label: 'Change PPPoE password', help: 'Also changes the portal login password' },
+]
+
+// Which self-service actions a reseller's subscribers can use in the customer portal
+export default function PortalPermissionsForm({ resellerId, onCancel }) {
+  const [perms, setPerms] = useState(null)
+
+  const { data } = useQuery({
+    queryKey: ['portalPermissions', resellerId],
+    queryFn: () => resellerApi.getPortalPermissions(resellerId).then((r) => r.data.data),
+    enabled: !!resellerId,
+  })
+
+  useEffect(() => {
+    if (data) setPerms(data)
+  }, [data])
+
+  const saveMutation = useMutation({
+    mutationFn: () => resellerApi.updatePortalPermissions(resellerId, perms),
+    onSuccess: () => toast.success('Portal permissions updated'),
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to update portal permissions'),
+  })
+
+  if (!perms) {
+    return <div className="text-center py-4 text-[12px] text-gray-500">Loading...</div>
+  }
+
+  return (
+    <div>
+      <p className="text-[12px] text-gray-600 dark:text-gray-400 mb-2">
+        Choose what subscribers can do themselves from the customer portal.
+      </p>
+      <div className="space-y-1">
+        {options.map((opt) => (
+          <label key={opt.key} className="flex items-start gap-2 p-1.5 text-[12px] cursor-pointer">
+            <input
+              type="checkbox"
+              checked={!!perms[opt.key]}
+              onChange={(e) => setPerms({ ...perms, [opt.key]: e.target.checked })}
+              className="mt-0.5"
+            />
+            <div>
+              <div className="font-semibold">{opt.label}</div>
+              <div className="text-[11px] text-gray-500">{opt.help}</div>
+            </div>
+          </label>
+        ))}
+      </div>
+      <div className="modal-footer" style={{ marginTop: 8 }}>
+        {onCancel && <button type="button" onClick={onCancel} className="btn btn-sm">Cancel</button>}
+        <button type="button" onClick={() => saveMutation.mutate()} disabled={saveMutation.isLoading} className="btn btn-sm btn-primary">
+          {saveMutation.isLoading ? 'Saving...' : 'Save Portal Permissions'}
+        </button>
+      </div>
+    </div>
+  )
+}
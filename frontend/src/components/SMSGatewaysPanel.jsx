@@ -0,0 +1,390 @@
+import { useState } from 'react'
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
+import toast from 'react-hot-toast'
+import api from '../services/api'
+
+const providers = [
+  { value: 'smpp', label: 'SMPP 3.4' },
+  { value: 'http', label: 'Generic HTTP' },
+  { value: 'twilio', label: 'Twilio' },
+  { value: 'vonage', label: 'Vonage (Nexmo)' },
+  { value: 'custom', label: 'Custom URL template' },
+]
+
+const emptyForm = {
+  name: '',
+  provider: 'smpp',
+  sender_id: '',
+  is_active: true,
+  allow_sender_override: true,
+  settings: { port: 2775, request_dlr: true, method: 'POST', content_type: 'application/json' },
+  headersText: '',
+}
+
+const formatTime = (value) => (value ? new Date(value).toLocaleString() : '-')
+
+function Field({ label, hint, children, wide }) {
+  return (
+    <div className={wide ? 'md:col-span-2' : ''}>
+      <label className="label">{label}</label>
+      {children}
+      {hint && <div className="text-[11px] text-gray-500 dark:text-gray-400 mt-0.5">{hint}</div>}
+    </div>
+  )
+}
+
+export default function SMSGatewaysPanel() {
+  const queryClient = useQueryClient()
+  const [editing, setEditing] = useState(null)
+  const [showModal, setShowModal] = useState(false)
+  const [form, setForm] = useState(emptyForm)
+  const [testPhone, setTestPhone] = useState('')
+
+  const { data: gateways = [] } = useQuery({
+    queryKey: ['sms-gateways'],
+    queryFn: () => api.get('/sms-gateways').then(res => res.data.data),
+    refetchInterval: 30000,
+  })
+
+  const refresh = () => queryClient.invalidateQueries(['sms-gateways'])
+  const onError = (fallback) => (err) => toast.error(err.response?.data?.message || fallback)
+
+  const saveMutation = useMutation({
+    mutationFn: (data) => editing ? api.put(`/sms-gateways/${editing.id}`, data) : api.post('/sms-gateways', data),
+    onSuccess: (res) => { toast.success(res.data.message); setShowModal(false); refresh() },
+    onError: onError('Failed to save gateway'),
+  })
+  const deleteMutation = useMutation({
+    mutationFn: (id) => api.delete(`/sms-gateways/${id}`),
+    onSuccess: (res) => { toast.success(res.data.message); refresh() },
+    onError: onError('Failed to delete gateway'),
+  })
+  const orderMutation = useMutation({
+    mutationFn: (ids) => api.put('/sms-gateways/order', { ids }),
+    onSuccess: refresh,
+    onError: onError('Failed to reorder gateways'),
+  })
+  const resetMutation = useMutation({
+    mutationFn: (id) => api.post(`/sms-gateways/${id}/reset`),
+    onSuccess: (res) => { toast.success(res.data.message); refresh() },
+    onError: onError('Failed to reset gateway'),
+  })
+  const testMutation = useMutation({
+    mutationFn: ({ id, phone }) => api.post(`/sms-gateways/${id}/test`, { phone }),
+    onSuccess: (res) => { toast.success(res.data.message); refresh() },
+    onError: onError('Test SMS failed'),
+  })
+
+  const move = (index, delta) => {
+    const ids = gateways.map(g => g.id)
+    const target = index + delta
+    if (target < 0 || target >= ids.length) return
+    ids.splice(target, 0, ids.splice(index, 1)[0])
+    orderMutation.mutate(ids)
+  }
+
+  const openModal = (gateway) => {
+    setEditing(gateway || null)
+    setForm(gateway ? {
+      name: gateway.name,
+      provider: gateway.provider,
+      sender_id: gateway.sender_id || '',
+      is_active: gateway.is_active,
+      allow_sender_override: gateway.allow_sender_override,
+      settings: { ...gateway.settings },
+      headersText: gateway.settings?.headers ? JSON.stringify(gateway.settings.headers, null, 2) : '',
+    } : emptyForm)
+    setShowModal(true)
+  }
+
+  const set = (key, value) => setForm(f => ({ ...f, [key]: value }))
+  const setSetting = (key, value) => setForm(f => ({ ...f, settings: { ...f.settings, [key]: value } }))
+  const setting = (key) => form.settings?.[key] ?? ''
+
+  const handleSubmit = (e) => {
+    e.preventDefault()
+    const settings = { ...form.settings }
+    if (form.headersText.trim()) {
+      try {
+        settings.headers = JSON.parse(form.headersText)
+      } catch {
+        toast.error('Headers must be a JSON object')
+        return
+      }
+    } else {
+      delete settings.headers
+    }
+    for (const key of ['port', 'source_ton', 'source_npi', 'dest_ton', 'dest_npi', 'enquire_link_secs']) {
+      settings[key] = settings[key] === '' || settings[key] === undefined ? 0 : Number(settings[key])
+    }
+    saveMutation.mutate({
+      name: form.name,
+      provider: form.provider,
+      sender_id: form.sender_id,
+      is_active: form.is_active,
+      allow_sender_override: form.allow_sender_override,
+      settings,
+    })
+  }
+
+  const input = (key, props = {}) => (
+    <input value={setting(key)} onChange={(e) => setSetting(key, e.target.value)} className="input" {...props} />
+  )
+  const secretPlaceholder = editing?.has_secret ? 'Leave blank to keep' : ''
+
+  return (
+    <div className="card p-3">
+      <div className="flex justify-between items-center mb-2">
+        <div>
+          <h3 className="text-[13px] font-semibold text-gray-900 dark:text-white">SMS Gateways</h3>
+          <p className="text-[11px] text-gray-500 dark:text-gray-400">
+            Messages go through the first healthy gateway; on failure the next one is tried.
+            When no gateway is set up, the provider above is used.
+          </p>
+        </div>
+        <button type="button" onClick={() => openModal(null)} className="btn btn-primary btn-sm">Add Gateway</button>
+      </div>
+
+      {gateways.length > 0 && (
+        <div className="table-container">
+          <table className="table">
+            <thead>
+              <tr>
+                <th>Order</th>
+                <th>Name</th>
+                <th>Provider</th>
+                <th>Sender</th>
+                <th>Health</th>
+                <th>Sent / Failed</th>
+                <th>Actions</th>
+              </tr>
+            </thead>
+            <tbody>
+              {gateways.map((gw, index) => (
+                <tr key={gw.id}>
+                  <td style={{ padding: '3px 8px', fontSize: 11, whiteSpace: 'nowrap' }}>
+                    <button type="button" onClick={() => move(index, -1)} disabled={index === 0} className="btn btn-sm" style={{ padding: '0 4px' }}>&uarr;</button>
+                    <button type="button" onClick={() => move(index, 1)} disabled={index === gateways.length - 1} className="btn btn-sm ml-1" style={{ padding: '0 4px' }}>&darr;</button>
+                  </td>
+                  <td style={{ padding: '3px 8px', fontSize: 11 }}>
+                    {gw.name}
+                    {!gw.is_active && <span className="badge badge-gray ml-1">inactive</span>}
+                  </td>
+                  <td style={{ padding: '3px 8px', fontSize: 11 }}>{providers.find(p => p.value === gw.provider)?.label || gw.provider}</td>
+                  <td style={{ padding: '3px 8px', fontSize: 11 }}>{gw.sender_id || '-'}</td>
+                  <td style={{ padding: '3px 8px', fontSize: 11, maxWidth: 260 }}>
+                    {gw.healthy
+                      ? <span className="badge badge-success">{gw.consecutive_failures > 0 ? `${gw.consecutive_failures} failures` : 'healthy'}</span>
+                      : <span className="badge badge-danger" title={`Skipped until ${formatTime(gw.disabled_until)}`}>cooling down</span>}
+                    {gw.last_error && gw.consecutive_failures > 0 && (
+                      <div className="truncate text-[10px] text-red-600" title={gw.last_error}>{gw.last_error}</div>
+                    )}
+                  </td>
+                  <td style={{ padding: '3px 8px', fontSize: 11 }}>{gw.sent_count} / {gw.failed_count}</td>
+                  <td style={{ padding: '3px 8px', fontSize: 11, whiteSpace: 'nowrap' }}>
+                    <button type="button" onClick={() => openModal(gw)} className="btn btn-sm" style={{ padding: '1px 4px' }}>Edit</button>
+                    <button
+                      type="button"
+                      onClick={() => testPhone ? testMutation.mutate({ id: gw.id, phone: testPhone }) : toast.error('Enter a test phone number')}
+                      disabled={testMutation.isPending}
+                      className="btn btn-sm ml-1"
+                      style={{ padding: '1px 4px' }}
+                    >
+                      Test
+                    </button>
+                    {(!gw.healthy || gw.consecutive_failures > 0) && (
+                      <button type="button" onClick={() => resetMutation.mutate(gw.id)} className="btn btn-sm ml-1" style={{ padding: '1px 4px' }}>Reset</button>
+                    )}
+                    <button
+                      type="button"
+                      onClick={() => { if (confirm(`Delete gateway ${gw.name}?`)) deleteMutation.mutate(gw.id) }}
+                      className="btn btn-danger btn-sm ml-1"
+                      style={{ padding: '1px 4px' }}
+                    >
+                      Delete
+                    </button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
+
+      {gateways.length > 0 && (
+        <div className="flex items-center gap-2 mt-2">
+          <label className="text-[11px] text-gray-600 dark:text-gray-400">Test phone</label>
+          <input value={testPhone} onChange={(e) => setTestPhone(e.target.value)} placeholder="+1234567890" className="input input-sm" style={{ width: 160 }} />
+        </div>
+      )}
+
+      {showModal && (
+        <div className="modal-overlay">
+          <div className="modal" style={{ maxWidth: 640, width: '100%' }}>
+            <div className="modal-header">
+              <span>{editing ? `Edit ${editing.name}` : 'Add SMS Gateway'}</span>
+              <button type="button" onClick={() => setShowModal(false)} className="text-[14px]">&times;</button>
+            </div>
+            <form onSubmit={handleSubmit}>
+              <div className="modal-body" style={{ maxHeight: '70vh', overflow: 'auto' }}>
+                <div className="grid grid-cols-1 md:grid-cols-2 gap-2">
+                  <Field label="Name">
+                    <input value={form.name} onChange={(e) => set('name', e.target.value)} className="input" required />
+                  </Field>
+                  <Field label="Provider">
+                    <select value={form.provider} onChange={(e) => set('provider', e.target.value)} className="input">
+                      {providers.map(p => <option key={p.value} value={p.value}>{p.label}</option>)}
+                    </select>
+                  </Field>
+                  <Field label="Sender ID" hint="Up to 11 letters and digits, or a number">
+                    <input value={form.sender_id} onChange={(e) => set('sender_id', e.target.value)} className="input" maxLength={15} />
+                  </Field>
+                  <div className="flex flex-col gap-1 justify-end">
+                    <label className="flex items-center gap-2 text-[12px]">
+                      <input type="checkbox" checked={form.is_active} onChange={(e) => set('is_active', e.target.checked)} />
+                      Active
+                    </label>
+                    <label className="flex items-center gap-2 text-[12px]">
+                      <input type="checkbox" checked={form.allow_sender_override} onChange={(e) => set('allow_sender_override', e.target.checked)} />
+                      Use resellers' sender IDs
+                    </label>
+                  </div>
+
+                  {form.provider === 'twilio' && (
+                    <>
+                      <Field label="Account SID">{input('twilio_sid')}</Field>
+                      <Field label="Auth Token">{input('twilio_token', { type: 'password', placeholder: secretPlaceholder })}</Field>
+                      <Field label="From Number">{input('twilio_from', { placeholder: '+1234567890' })}</Field>
+                    </>
+                  )}
+
+                  {form.provider === 'vonage' && (
+                    <>
+                      <Field label="API Key">{input('vonage_key')}</Field>
+                      <Field label="API Secret">{input('vonage_secret', { type: 'password', placeholder: secretPlaceholder })}</Field>
+                      <Field label="From">{input('vonage_from')}</Field>
+                    </>
+                  )}
+
+                  {form.provider === 'smpp' && (
+                    <>
+                      <Field label="Host">{input('host', { placeholder: 'smsc.example.com', required: true })}</Field>
+                      <Field label="Port">{input('port', { type: 'number', min: 1, max: 65535 })}</Field>
+                      <Field label="System ID">{input('system_id', { required: true })}</Field>
+                      <Field label="Password">{input('password', { type: 'password', placeholder: secretPlaceholder })}</Field>
+                      <Field label="System Type">{input('system_type')}</Field>
+                      <Field label="Enquire Link (seconds)" hint="Keepalive interval, default 30">{input('enquire_link_secs', { type: 'number', min: 0 })}</Field>
+                      <Field label="Source TON / NPI" hint="5/0 for alphanumeric, 1/1 for international numbers">
+                        <div className="flex gap-1">
+                          {input('source_ton', { type: 'number', min: 0, max: 6 })}
+                          {input('source_npi', { type: 'number', min: 0, max: 18 })}
+                        </div>
+                      </Field>
+                      <Field label="Destination TON / NPI">
+                        <div className="flex gap-1">
+                          {input('dest_ton', { type: 'number', min: 0, max: 6 })}
+                          {input('dest_npi', { type: 'number', min: 0, max: 18 })}
+                        </div>
+                      </Field>
+                      <div className="md:col-span-2 flex gap-4">
+                        <label className="flex items-center gap-2 text-[12px]">
+                          <input type="checkbox" checked={!!form.settings.tls} onChange={(e) => setSetting('tls', e.target.checked)} />
+                          TLS
+                        </label>
+                        <label className="flex items-center gap-2 text-[12px]">
+                          <input type="checkbox" checked={!!form.settings.request_dlr} onChange={(e) => setSetting('request_dlr', e.target.checked)} />
+                          Request delivery receipts
+                        </label>
+                      </div>
+                    </>
+                  )}
+
+                  {(form.provider === 'http' || form.provider === 'custom') && (
+                    <>
+                      <Field
+                        label="URL"
+                        wide
+                        hint={form.provider === 'http'
+                          ? 'Placeholders: {{.to}}, {{.message}}, {{.sender}}, {{.dlr_url}} (escaped automatically)'
+                          : 'Placeholders: {{to}}, {{message}}, {{sender}}'}
+                      >
+                        {input('url', { placeholder: 'https://api.provider.com/sms/send', required: true })}
+                      </Field>
+                      <Field label="Method">
+                        <select value={setting('method') || 'POST'} onChange={(e) => setSetting('method', e.target.value)} className="input">
+                          <option value="POST">POST</option>
+                          <option value="GET">GET</option>
+                        </select>
+                      </Field>
+                      {form.provider === 'http' ? (
+                        <Field label="Content Type">
+                          <select value={setting('content_type') || 'application/json'} onChange={(e) => setSetting('content_type', e.target.value)} className="input">
+                            <option value="application/json">application/json</option>
+                            <option value="application/x-www-form-urlencoded">application/x-www-form-urlencoded</option>
+                            <option value="text/xml">text/xml</option>
+                            <option value="text/plain">text/plain</option>
+                          </select>
+                        </Field>
+                      ) : (
+                        <Field label="Query Parameters (GET)">{input('params', { placeholder: 'to={{to}}&text={{message}}' })}</Field>
+                      )}
+                      <Field label="Request Body" wide>
+                        <textarea
+                          value={setting('body')}
+                          onChange={(e) => setSetting('body', e.target.value)}
+                          rows={3}
+                          className="input font-mono"
+                          placeholder='{"to": "{{.to}}", "text": "{{.message}}", "from": "{{.sender}}"}'
+                        />
+                      </Field>
+                      <Field label="Headers (JSON)" wide>
+                        <textarea
+                          value={form.headersText}
+                          onChange={(e) => set('headersText', e.target.value)}
+                          rows={2}
+                          className="input font-mono"
+                          placeholder='{"Authorization": "Bearer ..."}'
+                        />
+                      </Field>
+                    </>
+                  )}
+
+                  {form.provider === 'http' && (
+                    <>
+                      <div className="md:col-span-2 text-[12px] font-semibold mt-1">Response</div>
+                      <Field label="Success Field" hint="JSON path, e.g. status or result.code">{input('success_field')}</Field>
+                      <Field label="Success Values" hint="Comma-separated; empty accepts any but false/0">{input('success_values', { placeholder: 'ok,success' })}</Field>
+                      <Field label="Success Regex" hint="For plain text responses">{input('success_regex')}</Field>
+                      <Field label="Error Field">{input('error_field', { placeholder: 'error.message' })}</Field>
+                      <Field label="Message ID Field">{input('message_id_field', { placeholder: 'messages.0.id' })}</Field>
+                      <Field label="Message ID Regex" hint="First group is the ID">{input('message_id_regex')}</Field>
+
+                      <div className="md:col-span-2 text-[12px] font-semibold mt-1">Delivery Receipts</div>
+                      <Field label="ID Field">{input('dlr_id_field', { placeholder: 'id' })}</Field>
+                      <Field label="Status Field">{input('dlr_status_field', { placeholder: 'status' })}</Field>
+                      <Field label="Delivered Values">{input('dlr_delivered_values', { placeholder: 'delivered,1' })}</Field>
+                      <Field label="Failed Values">{input('dlr_failed_values', { placeholder: 'failed,undelivered,2,16' })}</Field>
+                      <Field label="Error Field">{input('dlr_error_field')}</Field>
+                    </>
+                  )}
+
+                  {['twilio', 'vonage', 'http'].includes(form.provider) && (
+                    <Field label="Delivery Receipt URL" wide hint="Sent to the provider with each message; defaults to this server's receipt endpoint">
+                      {input('dlr_url', { placeholder: editing?.dlr_url || 'Filled in on save' })}
+                    </Field>
+                  )}
+                </div>
+              </div>
+              <div className="modal-footer">
+                <button type="button" onClick={() => setShowModal(false)} className="btn btn-sm">Cancel</button>
+                <button type="submit" disabled={saveMutation.isPending} className="btn btn-sm btn-primary">
+                  {saveMutation.isPending ? 'Saving...' : editing ? 'Update' : 'Create'}
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      )}
+    </div>
+  )
+}
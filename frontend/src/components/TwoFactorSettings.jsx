@@ -0,0 +1,335 @@
+import { useState } from 'react'
+import { useQuery, useMutation } from '@tanstack/react-query'
+import api from '../services/api'
+import { useAuthStore } from '../store/authStore'
+import { webauthnSupported, createCredential } from '../utils/webauthn'
+import toast from 'react-hot-toast'
+
+const inputClass = 'block w-full max-w-xs border-[#a0a0a0] dark:bg-gray-700 dark:text-white focus:border-blue-500 focus:ring-blue-500 sm:text-[12px]'
+const userTypes = ['admin', 'support', 'reseller', 'collector', 'readonly']
+
+export default function TwoFactorSettings() {
+  const { user, refreshUser } = useAuthStore()
+  const isAdmin = user?.user_type === 'admin'
+
+  const [twoFASetup, setTwoFASetup] = useState(null)
+  const [twoFACode, setTwoFACode] = useState('')
+  const [disablePassword, setDisablePassword] = useState('')
+  const [disableCode, setDisableCode] = useState('')
+  const [keyName, setKeyName] = useState('')
+  const [keyPassword, setKeyPassword] = useState('')
+  const [addingKey, setAddingKey] = useState(false)
+  const [codesPassword, setCodesPassword] = useState('')
+  const [recoveryCodes, setRecoveryCodes] = useState(null)
+  const [policyTypes, setPolicyTypes] = useState(null)
+
+  const { data: status, refetch: refetchStatus } = useQuery({
+    queryKey: ['2fa-status'],
+    queryFn: () => api.get('/auth/2fa/status').then(res => res.data.data),
+  })
+
+  const { data: keys = [], refetch: refetchKeys } = useQuery({
+    queryKey: ['2fa-webauthn'],
+    queryFn: () => api.get('/auth/2fa/webauthn').then(res => res.data.data || []),
+  })
+
+  const { data: policy, refetch: refetchPolicy } = useQuery({
+    queryKey: ['2fa-policy'],
+    queryFn: () => api.get('/users/two-factor-policy').then(res => res.data.data),
+    enabled: isAdmin,
+  })
+
+  const refresh = () => {
+    refetchStatus()
+    refetchKeys()
+    refreshUser()
+  }
+
+  const showRecoveryCodes = (res) => {
+    const codes = res.data.data?.recovery_codes
+    if (codes && codes.length) setRecoveryCodes(codes)
+  }
+
+  const setupTwoFAMutation = useMutation({
+    mutationFn: () => api.post('/auth/2fa/setup'),
+    onSuccess: (res) => setTwoFASetup(res.data.data),
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to setup 2FA'),
+  })
+
+  const verifyTwoFAMutation = useMutation({
+    mutationFn: (code) => api.post('/auth/2fa/verify', { code }),
+    onSuccess: (res) => {
+      toast.success('2FA enabled successfully!')
+      setTwoFASetup(null)
+      setTwoFACode('')
+      showRecoveryCodes(res)
+      refresh()
+    },
+    onError: (err) => toast.error(err.response?.data?.message || 'Invalid code'),
+  })
+
+  const disableTwoFAMutation = useMutation({
+    mutationFn: (data) => api.post('/auth/2fa/disable', data),
+    onSuccess: () => {
+      toast.success('Authenticator app removed')
+      setDisablePassword('')
+      setDisableCode('')
+      refresh()
+    },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to disable 2FA'),
+  })
+
+  const deleteKeyMutation = useMutation({
+    mutationFn: ({ id, password }) => api.delete(`/auth/2fa/webauthn/${id}`, { data: { password } }),
+    onSuccess: () => {
+      toast.success('Security key removed')
+      refresh()
+    },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to remove security key'),
+  })
+
+  const regenerateMutation = useMutation({
+    mutationFn: (password) => api.post('/auth/2fa/recovery-codes', { password }),
+    onSuccess: (res) => {
+      setCodesPassword('')
+      showRecoveryCodes(res)
+      refetchStatus()
+    },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to generate recovery codes'),
+  })
+
+  const policyMutation = useMutation({
+    mutationFn: (types) => api.put('/users/two-factor-policy', { required_user_types: types }),
+    onSuccess: () => {
+      toast.success('2FA policy updated')
+      setPolicyTypes(null)
+      refetchPolicy()
+      refetchStatus()
+    },
+    onError: (err) => toast.error(err.response?.data?.message || 'Failed to update policy'),
+  })
+
+  const addSecurityKey = async () => {
+    setAddingKey(true)
+    try {
+      const begin = await api.post('/auth/2fa/webauthn/register/begin', { password: keyPassword })
+      const credential = await createCredential(begin.data.data)
+      const res = await api.post('/auth/2fa/webauthn/register/finish', { name: keyName, credential })
+      toast.success('Security key added')
+      setKeyName('')
+      setKeyPassword('')
+      showRecoveryCodes(res)
+      refresh()
+    } catch (err) {
+      toast.error(err.response?.data?.message || (err.name === 'NotAllowedError' ? 'Security key prompt was cancelled' : 'Failed to add security key'))
+    } finally {
+      setAddingKey(false)
+    }
+  }
+
+  const removeSecurityKey = (key) => {
+    const password = window.prompt(`Enter your password to remove "${key.name}"`)
+    if (password) deleteKeyMutation.mutate({ id: key.id, password })
+  }
+
+  const selectedTypes = policyTypes ?? policy?.required_user_types ?? []
+  const togglePolicyType = (type) => {
+    setPolicyTypes(selectedTypes.includes(type) ? selectedTypes.filter(t => t !== type) : [...selectedTypes, type])
+  }
+
+  return (
+    <div className="space-y-3">
+      {status?.required && !status?.enabled && (
+        <div className="bg-yellow-50 dark:bg-yellow-900/30 border border-yellow-300 p-3 text-[12px] text-yellow-800">
+          Two-factor authentication is required for your account. Set up an authenticator app or a security key to continue using the panel.
+        </div>
+      )}
+
+      {recoveryCodes && (
+        <div className="bg-yellow-50 dark:bg-yellow-900/30 border border-yellow-300 p-3">
+          <p className="text-[12px] font-semibold text-yellow-900 mb-2">Save your recovery codes</p>
+          <p className="text-[12px] text-yellow-800 mb-3">
+            Each code can be used once to log in if you lose your authenticator app or security key. They will not be shown again.
+          </p>
+          <div className="grid grid-cols-2 gap-1 max-w-xs font-mono text-[13px] bg-white dark:bg-gray-800 border p-2 mb-3">
+            {recoveryCodes.map(code => <span key={code}>{code}</span>)}
+          </div>
+          <div className="flex gap-2">
+            <button
+              onClick={() => { navigator.clipboard?.writeText(recoveryCodes.join('\n')); toast.success('Copied') }}
+              className="px-3 py-1.5 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700"
+            >
+              Copy
+            </button>
+            <button onClick={() => setRecoveryCodes(null)} className="px-3 py-1.5 text-[12px] text-gray-700 border border-[#a0a0a0]">
+              I have saved them
+            </button>
+          </div>
+        </div>
+      )}
+
+      {/* Authenticator app */}
+      <div className={status?.totp_enabled ? 'bg-green-50 dark:bg-green-900/30 border border-green-200 p-3' : 'bg-gray-50 dark:bg-gray-700 border border-[#a0a0a0] p-3'}>
+        <p className="text-[12px] font-semibold text-gray-700 dark:text-gray-300 mb-2">
+          Authenticator app {status?.totp_enabled && <span className="text-green-700">(enabled)</span>}
+        </p>
+        {status?.totp_enabled ? (
+          <div className="space-y-3">
+            <input
+              type="password"
+              placeholder="Current password"
+              value={disablePassword}
+              onChange={(e) => setDisablePassword(e.target.value)}
+              className={inputClass}
+            />
+            <input
+              type="text"
+              placeholder="2FA code or recovery code"
+              value={disableCode}
+              onChange={(e) => setDisableCode(e.target.value.slice(0, 11))}
+              className={inputClass}
+            />
+            <button
+              onClick={() => disableTwoFAMutation.mutate({ password: disablePassword, code: disableCode })}
+              disabled={disableTwoFAMutation.isPending || !disablePassword || disableCode.length < 6}
+              className="px-4 py-2 text-[12px] font-medium text-white bg-red-600 hover:bg-red-700 disabled:opacity-50"
+            >
+              {disableTwoFAMutation.isPending ? 'Disabling...' : 'Disable authenticator app'}
+            </button>
+          </div>
+        ) : twoFASetup ? (
+          <div className="flex flex-col md:flex-row gap-3">
+            <div className="flex-shrink-0">
+              <p className="text-[12px] text-blue-800 mb-2">1. Scan this QR code with your authenticator app</p>
+              <img src={twoFASetup.qr_code} alt="2FA QR Code" className="w-48 h-48 border rounded" />
+            </div>
+            <div className="flex-1">
+              <p className="text-[12px] text-blue-800 mb-2">Or enter this code manually:</p>
+              <code className="block bg-white px-3 py-2 rounded border text-[12px] font-mono mb-3 break-all">
+                {twoFASetup.secret}
+              </code>
+              <p className="text-[12px] text-blue-800 mb-2">2. Enter the 6-digit code from your app:</p>
+              <div className="flex gap-2">
+                <input
+                  type="text"
+                  placeholder="000000"
+                  value={twoFACode}
+                  onChange={(e) => setTwoFACode(e.target.value.replace(/\D/g, '').slice(0, 6))}
+                  maxLength={6}
+                  className="block w-32 border-[#a0a0a0] dark:bg-gray-700 dark:text-white focus:border-blue-500 focus:ring-blue-500 text-center text-lg tracking-widest"
+                />
+                <button
+                  onClick={() => verifyTwoFAMutation.mutate(twoFACode)}
+                  disabled={verifyTwoFAMutation.isPending || twoFACode.length !== 6}
+                  className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
+                >
+                  {verifyTwoFAMutation.isPending ? 'Verifying...' : 'Verify & Enable'}
+                </button>
+              </div>
+              <button
+                onClick={() => { setTwoFASetup(null); setTwoFACode('') }}
+                className="mt-4 text-[12px] text-blue-600 hover:text-blue-800"
+              >
+                Cancel setup
+              </button>
+            </div>
+          </div>
+        ) : (
+          <>
+            <p className="text-[12px] text-gray-600 mb-3">
+              Use a 6-digit code from an authenticator app like Google Authenticator or Authy.
+            </p>
+            <button
+              onClick={() => setupTwoFAMutation.mutate()}
+              disabled={setupTwoFAMutation.isPending}
+              className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
+            >
+              {setupTwoFAMutation.isPending ? 'Setting up...' : 'Set up authenticator app'}
+            </button>
+          </>
+        )}
+      </div>
+
+      {/* Security keys and passkeys */}
+      <div className="bg-gray-50 dark:bg-gray-700 border border-[#a0a0a0] p-3">
+        <p className="text-[12px] font-semibold text-gray-700 dark:text-gray-300 mb-2">Security keys and passkeys</p>
+        {keys.length > 0 && (
+          <table className="w-full text-[12px] mb-3">
+            <tbody>
+              {keys.map(key => (
+                <tr key={key.id} className="border-b border-gray-200 dark:border-gray-600">
+                  <td className="py-1.5 font-medium">{key.name}{key.backup_eligible && <span className="ml-2 text-gray-500">(synced passkey)</span>}</td>
+                  <td className="py-1.5 text-gray-500">Added {new Date(key.created_at).toLocaleDateString()}</td>
+                  <td className="py-1.5 text-gray-500">{key.last_used_at ? `Last used ${new Date(key.last_used_at).toLocaleString()}` : 'Never used'}</td>
+                  <td className="py-1.5 text-right">
+                    <button onClick={() => removeSecurityKey(key)} className="text-red-600 hover:text-red-800">Remove</button>
+                  </td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        )}
+        {webauthnSupported() ? (
+          <div className="space-y-3">
+            <input type="text" placeholder="Key name (e.g. YubiKey)" value={keyName} onChange={(e) => setKeyName(e.target.value)} className={inputClass} />
+            <input type="password" placeholder="Current password" value={keyPassword} onChange={(e) => setKeyPassword(e.target.value)} className={inputClass} />
+            <button
+              onClick={addSecurityKey}
+              disabled={addingKey || !keyPassword}
+              className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
+            >
+              {addingKey ? 'Waiting for security key...' : 'Add security key'}
+            </button>
+          </div>
+        ) : (
+          <p className="text-[12px] text-gray-500">This browser does not support security keys.</p>
+        )}
+      </div>
+
+      {/* Recovery codes */}
+      {status?.enabled && (
+        <div className="bg-gray-50 dark:bg-gray-700 border border-[#a0a0a0] p-3">
+          <p className="text-[12px] font-semibold text-gray-700 dark:text-gray-300 mb-2">Recovery codes</p>
+          <p className="text-[12px] text-gray-600 mb-3">
+            {status.recovery_codes_remaining} of 10 recovery codes left. Generating new codes invalidates the old ones.
+          </p>
+          <div className="flex gap-2">
+            <input type="password" placeholder="Current password" value={codesPassword} onChange={(e) => setCodesPassword(e.target.value)} className={inputClass} />
+            <button
+              onClick={() => regenerateMutation.mutate(codesPassword)}
+              disabled={regenerateMutation.isPending || !codesPassword}
+              className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
+            >
+              Generate new codes
+            </button>
+          </div>
+        </div>
+      )}
+
+      {/* Policy (admins) */}
+      {isAdmin && policy && (
+        <div className="bg-gray-50 dark:bg-gray-700 border border-[#a0a0a0] p-3">
+          <p className="text-[12px] font-semibold text-gray-700 dark:text-gray-300 mb-2">Require 2FA for</p>
+          <p className="text-[12px] text-gray-600 mb-3">
+            Users of these types must set up a second factor before they can use the panel. SSO logins rely on the identity provider instead.
+          </p>
+          <div className="flex flex-wrap gap-4 mb-3">
+            {userTypes.map(type => (
+              <label key={type} className="flex items-center gap-1.5 text-[12px] capitalize">
+                <input type="checkbox" checked={selectedTypes.includes(type)} onChange={() => togglePolicyType(type)} />
+                {type}
+              </label>
+            ))}
+          </div>
+          <button
+            onClick={() => policyMutation.mutate(selectedTypes)}
+            disabled={policyMutation.isPending || policyTypes === null}
+            className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
+          >
+            Save policy
+          </button>
+        </div>
+      )}
+    </div>
+  )
+}
@@ -1,9 +1,12 @@
 import { useState } from 'react'
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
 import api from '../services/api'
+import { useAuthStore } from '../store/authStore'
+import OutboxPanel from '../components/OutboxPanel'
 
 export default function CommunicationRules() {
   const queryClient = useQueryClient()
+  const { hasPermission } = useAuthStore()
   const [showModal, setShowModal] = useState(false)
   const [editingRule, setEditingRule] = useState(null)
   const [formData, setFormData] = useState({
@@ -250,6 +253,8 @@ export default function CommunicationRules() {
         </div>
       )}
 
+      {hasPermission('communication.outbox') && <OutboxPanel />}
+
       {/* Add/Edit Modal */}
       {showModal && (
         <div className="modal-overlay">
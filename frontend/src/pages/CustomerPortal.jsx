@@ -21,6 +21,7 @@ import {
   BanknotesIcon
 } from '@heroicons/react/24/outline'
 import api from '../services/api'
+import CustomerSelfService from '../components/CustomerSelfService'
 
 // Format bytes to human readable
 function formatBytes(bytes) {
@@ -157,8 +158,8 @@ export default function CustomerPortal() {
     navigate('/login')
   }
 
-  const fetchDashboard = async () => {
-    setLoading(true)
+  const fetchDashboard = async (silent = false) => {
+    if (!silent) setLoading(true)
     try {
       const res = await api.get('/customer/dashboard')
       if (res.data.success) {
@@ -351,7 +352,7 @@ export default function CustomerPortal() {
       {/* Tabs */}
       <div className="max-w-7xl mx-auto px-3 mt-3">
         <div className="flex gap-0 border-b border-[#a0a0a0] dark:border-[#555]">
-          {['dashboard', 'sessions', 'usage', 'invoices', 'tickets'].map((tab) => (
+          {['dashboard', 'account', 'sessions', 'usage', 'invoices', 'tickets'].map((tab) => (
             <button
               key={tab}
               onClick={() => setActiveTab(tab)}
@@ -560,6 +561,10 @@ export default function CustomerPortal() {
           </div>
         )}
 
+        {activeTab === 'account' && (
+          <CustomerSelfService dashboard={dashboard} onChanged={() => fetchDashboard(true)} />
+        )}
+
         {activeTab === 'sessions' && (
           <div className="wb-group">
             <div className="wb-group-title">Session History (Last 30 Days)</div>
@@ -24,7 +24,7 @@ export default function Impersonate() {
         const response = await publicApi.exchangeImpersonateToken(token)
 
         if (response.data.success) {
-          const { token: jwtToken, user } = response.data.data
+          const { token: jwtToken, refresh_token: refreshToken, user } = response.data.data
 
           // Store session in sessionStorage with special impersonate key
           // This key is used by authStore to detect impersonated sessions
@@ -32,6 +32,7 @@ export default function Impersonate() {
           const authState = {
             user: user,
             token: jwtToken,
+            refreshToken: refreshToken,
             isCustomer: false,
             customerData: null,
           }
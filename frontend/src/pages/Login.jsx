@@ -3,6 +3,9 @@ import { useNavigate, useSearchParams, Navigate } from 'react-router-dom'
 import { useAuthStore } from '../store/authStore'
 import { useBrandingStore } from '../store/brandingStore'
 import toast from 'react-hot-toast'
+import api from '../services/api'
+import { webauthnSupported, getAssertion } from '../utils/webauthn'
+import CustomerOTPLogin from '../components/CustomerOTPLogin'
 import {
   UserIcon,
   LockClosedIcon,
@@ -233,11 +236,17 @@ export default function Login() {
   const [password, setPassword] = useState('')
   const [twoFACode, setTwoFACode] = useState('')
   const [requires2FA, setRequires2FA] = useState(false)
+  const [twoFAMethods, setTwoFAMethods] = useState(['totp'])
+  const [webauthnOptions, setWebauthnOptions] = useState(null)
+  const [useRecovery, setUseRecovery] = useState(false)
   const [loading, setLoading] = useState(false)
   const navigate = useNavigate()
   const [searchParams] = useSearchParams()
   const sessionReason = searchParams.get('reason')
-  const { login, isAuthenticated, isCustomer } = useAuthStore()
+  const ssoCode = searchParams.get('sso_code')
+  const ssoError = searchParams.get('sso_error')
+  const [ssoProviders, setSSOProviders] = useState([])
+  const { login, loginWithSSO, isAuthenticated, isCustomer } = useAuthStore()
   const {
     companyName, companyLogo, loginBackground, footerText, primaryColor,
     loginTagline, showLoginFeatures,
@@ -253,41 +262,149 @@ export default function Login() {
     }
   }, [loaded, fetchBranding])
 
-  const handleSubmit = async (e) => {
-    e.preventDefault()
+  useEffect(() => {
+    api.get('/auth/sso/providers')
+      .then((res) => setSSOProviders(res.data.data || []))
+      .catch(() => {})
+  }, [])
+
+  // Finish a single sign-on redirect: exchange the one-time code for a session
+  useEffect(() => {
+    if (ssoError) {
+      toast.error(ssoError)
+      return
+    }
+    if (!ssoCode) return
     setLoading(true)
+    loginWithSSO(ssoCode).then((result) => {
+      setLoading(false)
+      if (result.success) {
+        toast.success('Login successful')
+        const returnTo = searchParams.get('return_to')
+        navigate(returnTo && returnTo.startsWith('/') && !returnTo.startsWith('//') ? returnTo : '/', { replace: true })
+      } else {
+        toast.error(result.message || 'Single sign-on failed')
+        navigate('/login', { replace: true })
+      }
+    })
+  }, []) // eslint-disable-line react-hooks/exhaustive-deps
 
-    const result = await login(username, password, twoFACode)
+  const ssoButtons = ssoProviders.length > 0 && (
+    <div style={{ marginTop: 14 }}>
+      <div style={{ textAlign: 'center', fontSize: 12, color: '#888', marginBottom: 8 }}>or</div>
+      {ssoProviders.map((p) => (
+        <a
+          key={p.slug}
+          href={`/api/auth/sso/${encodeURIComponent(p.slug)}/login`}
+          style={{
+            display: 'block', width: '100%', boxSizing: 'border-box', padding: '9px', marginBottom: 6,
+            textAlign: 'center', fontSize: 13, color: '#333', textDecoration: 'none',
+            background: '#f5f5f5', border: '1px solid #ccc', borderRadius: 6,
+          }}
+        >
+          Sign in with {p.name}
+        </a>
+      ))}
+    </div>
+  )
 
+  const handleLoginResult = (result) => {
     if (result.success) {
       toast.success('Login successful')
       if (result.force_password_change) {
         toast('Please change your password to continue', { icon: '🔐' })
         navigate('/change-password')
+      } else if (result.two_factor_setup_required) {
+        toast('Please set up two-factor authentication to continue', { icon: '🔐' })
+        navigate('/setup-2fa')
       } else if (result.userType === 'customer') {
         navigate('/portal')
       } else {
         navigate('/')
       }
     } else if (result.requires_2fa) {
+      const methods = result.methods || ['totp']
       setRequires2FA(true)
-      toast('Please enter your 2FA code', { icon: '🔐' })
+      setTwoFAMethods(methods)
+      setWebauthnOptions(result.webauthn || null)
+      setUseRecovery(!methods.includes('totp'))
+      toast('Please verify your identity', { icon: '🔐' })
     } else {
       toast.error(result.message || 'Login failed')
     }
+  }
+
+  const handleSubmit = async (e) => {
+    e.preventDefault()
+    setLoading(true)
+
+    const secondFactor = requires2FA && useRecovery ? { recovery_code: twoFACode } : twoFACode
+    handleLoginResult(await login(username, password, secondFactor))
 
     setLoading(false)
   }
 
+  // Sign the login challenge with a security key or passkey
+  const handleSecurityKey = async () => {
+    setLoading(true)
+    try {
+      const assertion = await getAssertion(webauthnOptions)
+      const result = await login(username, password, { webauthn: assertion })
+      if (result.success) {
+        handleLoginResult(result)
+      } else {
+        toast.error(result.message || 'Security key verification failed')
+      }
+    } catch (err) {
+      toast.error('Security key was not used')
+    }
+    if (!useAuthStore.getState().isAuthenticated) {
+      // Each challenge works once; fetch a fresh one for the next try
+      const retry = await login(username, password, '')
+      if (retry.requires_2fa) setWebauthnOptions(retry.webauthn || null)
+    }
+    setLoading(false)
+  }
+
   const handleBack = () => {
     setRequires2FA(false)
     setTwoFACode('')
+    setUseRecovery(false)
+    setWebauthnOptions(null)
+  }
+
+  const handleCodeChange = (e) => {
+    setTwoFACode(useRecovery ? e.target.value.slice(0, 11) : e.target.value.replace(/\D/g, '').slice(0, 6))
   }
 
+  const codeReady = useRecovery ? twoFACode.replace(/[^a-zA-Z0-9]/g, '').length === 10 : twoFACode.length === 6
+  const twoFAPrompt = useRecovery
+    ? 'Enter one of your recovery codes'
+    : 'Enter the 6-digit code from your authenticator app'
+
+  const twoFAAlternatives = (
+    <div style={{ marginTop: 10, display: 'flex', flexDirection: 'column', gap: 6, alignItems: 'center', fontSize: 12 }}>
+      {twoFAMethods.includes('webauthn') && webauthnOptions && webauthnSupported() && (
+        <button type="button" onClick={handleSecurityKey} disabled={loading} style={{ ...winStyles.btnSecondary, width: '100%' }}>
+          Use security key or passkey
+        </button>
+      )}
+      {twoFAMethods.includes('totp') && (
+        <button
+          type="button"
+          onClick={() => { setUseRecovery(!useRecovery); setTwoFACode('') }}
+          style={{ background: 'none', border: 'none', color: '#4a7ab5', cursor: 'pointer', fontSize: 12, padding: 0 }}
+        >
+          {useRecovery ? 'Use authenticator app code' : 'Use a recovery code'}
+        </button>
+      )}
+    </div>
+  )
+
   // If user navigated to /login manually while authenticated, log them out first
   // This allows switching accounts (e.g., admin -> reseller)
   useEffect(() => {
-    if (isAuthenticated && !sessionReason) {
+    if (isAuthenticated && !sessionReason && !ssoCode) {
       const { logout } = useAuthStore.getState()
       logout()
     }
@@ -414,6 +531,8 @@ export default function Login() {
                 )}
               </button>
             </form>
+            {ssoButtons}
+            <CustomerOTPLogin primaryColor={primaryColor} />
           </>
         ) : (
           <>
@@ -426,7 +545,7 @@ export default function Login() {
                 <ShieldCheckIcon style={{ width: 28, height: 28, color: primaryColor || '#4a7ab5' }} />
               </div>
               <div style={{ fontWeight: 600, fontSize: 15, color: '#000', marginBottom: 4 }}>Verification Required</div>
-              <div style={{ fontSize: 13, color: '#666' }}>Enter the 6-digit code from your authenticator app</div>
+              <div style={{ fontSize: 13, color: '#666' }}>{twoFAPrompt}</div>
             </div>
 
             <form onSubmit={handleSubmit}>
@@ -435,9 +554,9 @@ export default function Login() {
                   type="text"
                   required
                   value={twoFACode}
-                  onChange={(e) => setTwoFACode(e.target.value.replace(/\D/g, '').slice(0, 6))}
-                  placeholder="000000"
-                  maxLength={6}
+                  onChange={handleCodeChange}
+                  placeholder={useRecovery ? 'xxxxx-xxxxx' : '000000'}
+                  maxLength={useRecovery ? 11 : 6}
                   autoComplete="one-time-code"
                   autoFocus
                   style={{
@@ -452,12 +571,12 @@ export default function Login() {
 
               <button
                 type="submit"
-                disabled={loading || twoFACode.length !== 6}
+                disabled={loading || !codeReady}
                 style={{
                   width: '100%', padding: '13px', fontSize: 16, fontWeight: 600, fontFamily: 'inherit',
                   color: '#fff', background: primaryColor || '#4a7ab5', border: 'none', borderRadius: 8,
-                  cursor: (loading || twoFACode.length !== 6) ? 'not-allowed' : 'pointer',
-                  opacity: (loading || twoFACode.length !== 6) ? 0.7 : 1,
+                  cursor: (loading || !codeReady) ? 'not-allowed' : 'pointer',
+                  opacity: (loading || !codeReady) ? 0.7 : 1,
                   display: 'flex', alignItems: 'center', justifyContent: 'center', gap: 8,
                 }}
               >
@@ -473,6 +592,7 @@ export default function Login() {
                   'Verify & Sign In'
                 )}
               </button>
+              {twoFAAlternatives}
 
               <button
                 type="button"
@@ -661,6 +781,8 @@ export default function Login() {
                       )}
                     </button>
                   </form>
+                  {ssoButtons}
+                  <CustomerOTPLogin primaryColor={primaryColor} />
 
                   <div style={{ textAlign: 'center', marginTop: 10, fontSize: 11, color: '#666' }}>
                     Admin, Reseller, or PPPoE Customer
@@ -685,7 +807,7 @@ export default function Login() {
                       Verification Required
                     </div>
                     <div style={{ fontSize: 11, color: '#555' }}>
-                      Enter the 6-digit code from your authenticator app
+                      {twoFAPrompt}
                     </div>
                   </div>
 
@@ -697,12 +819,12 @@ export default function Login() {
                         type="text"
                         required
                         value={twoFACode}
-                        onChange={(e) => setTwoFACode(e.target.value.replace(/\D/g, '').slice(0, 6))}
+                        onChange={handleCodeChange}
                         style={winStyles.twoFAInput}
                         onFocus={(e) => e.target.style.borderColor = '#4a7ab5'}
                         onBlur={(e) => e.target.style.borderColor = '#a0a0a0'}
-                        placeholder="000000"
-                        maxLength={6}
+                        placeholder={useRecovery ? 'xxxxx-xxxxx' : '000000'}
+                        maxLength={useRecovery ? 11 : 6}
                         autoComplete="one-time-code"
                         autoFocus
                       />
@@ -713,8 +835,8 @@ export default function Login() {
                     {/* Verify button */}
                     <button
                       type="submit"
-                      disabled={loading || twoFACode.length !== 6}
-                      style={winStyles.btnPrimary(loading || twoFACode.length !== 6)}
+                      disabled={loading || !codeReady}
+                      style={winStyles.btnPrimary(loading || !codeReady)}
                     >
                       {loading ? (
                         <>
@@ -728,6 +850,7 @@ export default function Login() {
                         'Verify & Sign In'
                       )}
                     </button>
+                    {twoFAAlternatives}
 
                     <div style={{ marginTop: 8 }}>
                       <button
@@ -2,12 +2,14 @@ import { useState } from 'react'
 import { useAuthStore } from '../store/authStore'
 import api from '../services/api'
 import toast from 'react-hot-toast'
+import PortalPermissionsForm from '../components/PortalPermissionsForm'
 import {
   UserCircleIcon,
   KeyIcon,
   EyeIcon,
   EyeSlashIcon,
   CheckCircleIcon,
+  GlobeAltIcon,
 } from '@heroicons/react/24/outline'
 
 export default function Profile() {
@@ -222,6 +224,19 @@ export default function Profile() {
           )}
         </div>
       </div>
+
+      {/* Customer portal permissions for the reseller's own subscribers */}
+      {user?.user_type === 'reseller' && user?.reseller_id && (
+        <div className="wb-group">
+          <div className="wb-group-title flex items-center gap-2">
+            <GlobeAltIcon className="w-4 h-4 text-gray-600 dark:text-gray-400" />
+            Customer Portal
+          </div>
+          <div className="wb-group-body">
+            <PortalPermissionsForm resellerId={user.reseller_id} />
+          </div>
+        </div>
+      )}
     </div>
   )
 }
@@ -24,9 +24,11 @@ import {
   ServerIcon,
   CubeIcon,
   Cog6ToothIcon,
+  GlobeAltIcon,
 } from '@heroicons/react/24/outline'
 import toast from 'react-hot-toast'
 import clsx from 'clsx'
+import PortalPermissionsForm from '../components/PortalPermissionsForm'
 
 export default function Resellers() {
   const queryClient = useQueryClient()
@@ -59,6 +61,7 @@ export default function Resellers() {
     notes: '',
     rebrand_enabled: false,
     custom_domain: '',
+    sms_sender_id: '',
   })
 
   const { data: resellers, isLoading } = useQuery({
@@ -227,6 +230,7 @@ export default function Resellers() {
         notes: reseller.notes || '',
         rebrand_enabled: reseller.rebrand_enabled || false,
         custom_domain: reseller.custom_domain || '',
+        sms_sender_id: reseller.sms_sender_id || '',
       })
     } else {
       setEditingReseller(null)
@@ -247,6 +251,7 @@ export default function Resellers() {
         notes: '',
         rebrand_enabled: false,
         custom_domain: '',
+        sms_sender_id: '',
       })
     }
     setShowModal(true)
@@ -618,6 +623,14 @@ export default function Resellers() {
                   <CubeIcon className="w-3.5 h-3.5 inline mr-1" />
                   Services ({assignedServices.filter(s => s.enabled).length})
                 </button>
+                <button
+                  type="button"
+                  onClick={() => setActiveTab('portal')}
+                  className={clsx('wb-tab', activeTab === 'portal' && 'active')}
+                >
+                  <GlobeAltIcon className="w-3.5 h-3.5 inline mr-1" />
+                  Customer Portal
+                </button>
               </div>
             )}
 
@@ -731,6 +744,13 @@ export default function Resellers() {
                         placeholder="portal.myisp.com" className="input font-mono" />
                       <div className="text-[11px] text-gray-500 mt-0.5">A record must point to this server's IP.</div>
                     </div>
+                    <div className="mt-2">
+                      <label className="label">SMS Sender ID</label>
+                      <input type="text" value={formData.sms_sender_id} maxLength={15}
+                        onChange={e => setFormData(p => ({ ...p, sms_sender_id: e.target.value }))}
+                        placeholder="MyISP" className="input" />
+                      <div className="text-[11px] text-gray-500 mt-0.5">Sender of SMS to this reseller's subscribers. Empty uses the gateway's.</div>
+                    </div>
                   </div>
                 </div>
 
@@ -841,6 +861,13 @@ export default function Resellers() {
                 </div>
               </div>
             )}
+
+            {/* Customer Portal Tab */}
+            {activeTab === 'portal' && editingReseller && (
+              <div className="modal-body" style={{ overflow: 'auto', flex: 1 }}>
+                <PortalPermissionsForm resellerId={editingReseller.id} onCancel={closeModal} />
+              </div>
+            )}
           </div>
         </div>
       )}
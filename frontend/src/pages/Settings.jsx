@@ -9,13 +9,15 @@ import toast from 'react-hot-toast'
 import { PhotoIcon, TrashIcon, SwatchIcon, CpuChipIcon, ServerIcon, ExclamationTriangleIcon, CheckCircleIcon, InformationCircleIcon, LockClosedIcon, GlobeAltIcon } from '@heroicons/react/24/outline'
 import ClusterTab from '../components/ClusterTab'
 import NetworkConfiguration from '../components/NetworkConfiguration'
+import TwoFactorSettings from '../components/TwoFactorSettings'
+import SMSGatewaysPanel from '../components/SMSGatewaysPanel'
 import { dashboardApi } from '../services/api'
 import { QRCodeSVG } from 'qrcode.react'
 import clsx from 'clsx'
 
 export default function Settings() {
   const queryClient = useQueryClient()
-  const { user, refreshUser } = useAuthStore()
+  const { user } = useAuthStore()
   const { companyName, companyLogo, loginBackground, favicon, footerText, primaryColor, fetchBranding, updateBranding } = useBrandingStore()
   const [searchParams, setSearchParams] = useSearchParams()
 
@@ -49,12 +51,6 @@ export default function Settings() {
   const [uploadingBackground, setUploadingBackground] = useState(false)
   const [uploadingFavicon, setUploadingFavicon] = useState(false)
 
-  // 2FA state
-  const [twoFASetup, setTwoFASetup] = useState(null)
-  const [twoFACode, setTwoFACode] = useState('')
-  const [disablePassword, setDisablePassword] = useState('')
-  const [disableCode, setDisableCode] = useState('')
-
   // Notification test state
   const [testingSmtp, setTestingSmtp] = useState(false)
   const [testingSms, setTestingSms] = useState(false)
@@ -134,51 +130,6 @@ export default function Settings() {
     }
   })
 
-  // 2FA queries and mutations
-  const { data: twoFAStatus, refetch: refetchTwoFA } = useQuery({
-    queryKey: ['2fa-status'],
-    queryFn: () => api.get('/auth/2fa/status').then(res => res.data.data),
-    enabled: activeTab === 'account'
-  })
-
-  const setupTwoFAMutation = useMutation({
-    mutationFn: () => api.post('/auth/2fa/setup'),
-    onSuccess: (res) => {
-      setTwoFASetup(res.data.data)
-    },
-    onError: (err) => {
-      toast.error(err.response?.data?.message || 'Failed to setup 2FA')
-    }
-  })
-
-  const verifyTwoFAMutation = useMutation({
-    mutationFn: (code) => api.post('/auth/2fa/verify', { code }),
-    onSuccess: () => {
-      toast.success('2FA enabled successfully!')
-      setTwoFASetup(null)
-      setTwoFACode('')
-      refetchTwoFA()
-      refreshUser()
-    },
-    onError: (err) => {
-      toast.error(err.response?.data?.message || 'Invalid code')
-    }
-  })
-
-  const disableTwoFAMutation = useMutation({
-    mutationFn: (data) => api.post('/auth/2fa/disable', data),
-    onSuccess: () => {
-      toast.success('2FA disabled successfully')
-      setDisablePassword('')
-      setDisableCode('')
-      refetchTwoFA()
-      refreshUser()
-    },
-    onError: (err) => {
-      toast.error(err.response?.data?.message || 'Failed to disable 2FA')
-    }
-  })
-
   const handleChange = (key, value) => {
     setFormData(prev => ({ ...prev, [key]: value }))
     setHasChanges(true)
@@ -609,7 +560,7 @@ export default function Settings() {
         subscriber_ids: waSendAll ? [] : waSelectedIDs,
       })
       if (res.data.success) {
-        toast.success(`✅ Sent to ${res.data.sent} subscribers${res.data.failed > 0 ? `, ${res.data.failed} failed` : ''}`)
+        toast.success(`✅ Queued for ${res.data.queued} subscribers${res.data.failed > 0 ? `, ${res.data.failed} failed` : ''}`)
         if (res.data.failed === 0) {
           setWaMessage('')
           setWaSelectedIDs([])
@@ -1323,110 +1274,7 @@ export default function Settings() {
               {/* Two-Factor Authentication */}
               <div>
                 <h3 className="text-[13px] font-semibold text-gray-900 dark:text-white mb-3">Two-Factor Authentication</h3>
-
-                {twoFAStatus?.enabled ? (
-                  <div className="bg-green-50 dark:bg-green-900/30 border border-green-200 p-3">
-                    <div className="flex items-center mb-3">
-                      <svg className="w-5 h-5 text-green-600 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                        <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 12l2 2 4-4m5.618-4.016A11.955 11.955 0 0112 2.944a11.955 11.955 0 01-8.618 3.04A12.02 12.02 0 003 9c0 5.591 3.824 10.29 9 11.622 5.176-1.332 9-6.03 9-11.622 0-1.042-.133-2.052-.382-3.016z" />
-                      </svg>
-                      <span className="font-medium text-green-800">2FA is enabled</span>
-                    </div>
-                    <p className="text-[12px] text-green-700 mb-3">Your account is protected with two-factor authentication.</p>
-
-                    <div className="border-t border-green-200 pt-4 mt-4">
-                      <p className="text-[12px] font-semibold text-gray-700 dark:text-gray-300 mb-2">Disable 2FA</p>
-                      <div className="space-y-3">
-                        <input
-                          type="password"
-                          placeholder="Current password"
-                          value={disablePassword}
-                          onChange={(e) => setDisablePassword(e.target.value)}
-                          className="block w-full max-w-xs border-[#a0a0a0] dark:bg-gray-700 dark:text-white focus:border-blue-500 focus:ring-blue-500 sm:text-[12px]"
-                        />
-                        <input
-                          type="text"
-                          placeholder="2FA code"
-                          value={disableCode}
-                          onChange={(e) => setDisableCode(e.target.value.replace(/\D/g, '').slice(0, 6))}
-                          maxLength={6}
-                          className="block w-full max-w-xs border-[#a0a0a0] dark:bg-gray-700 dark:text-white focus:border-blue-500 focus:ring-blue-500 sm:text-[12px]"
-                        />
-                        <button
-                          onClick={() => disableTwoFAMutation.mutate({ password: disablePassword, code: disableCode })}
-                          disabled={disableTwoFAMutation.isPending || !disablePassword || disableCode.length !== 6}
-                          className="px-4 py-2 text-[12px] font-medium text-white bg-red-600 hover:bg-red-700 disabled:opacity-50"
-                        >
-                          {disableTwoFAMutation.isPending ? 'Disabling...' : 'Disable 2FA'}
-                        </button>
-                      </div>
-                    </div>
-                  </div>
-                ) : twoFASetup ? (
-                  <div className="bg-blue-50 dark:bg-blue-900/30 border border-blue-200 p-3">
-                    <h4 className="font-medium text-blue-900 mb-3">Setup Two-Factor Authentication</h4>
-
-                    <div className="flex flex-col md:flex-row gap-3">
-                      <div className="flex-shrink-0">
-                        <p className="text-[12px] text-blue-800 mb-2">1. Scan this QR code with your authenticator app</p>
-                        <img src={twoFASetup.qr_code} alt="2FA QR Code" className="w-48 h-48 border rounded" />
-                      </div>
-
-                      <div className="flex-1">
-                        <p className="text-[12px] text-blue-800 mb-2">Or enter this code manually:</p>
-                        <code className="block bg-white px-3 py-2 rounded border text-[12px] font-mono mb-3 break-all">
-                          {twoFASetup.secret}
-                        </code>
-
-                        <p className="text-[12px] text-blue-800 mb-2">2. Enter the 6-digit code from your app:</p>
-                        <div className="flex gap-2">
-                          <input
-                            type="text"
-                            placeholder="000000"
-                            value={twoFACode}
-                            onChange={(e) => setTwoFACode(e.target.value.replace(/\D/g, '').slice(0, 6))}
-                            maxLength={6}
-                            className="block w-32 border-[#a0a0a0] dark:bg-gray-700 dark:text-white focus:border-blue-500 focus:ring-blue-500 text-center text-lg tracking-widest"
-                          />
-                          <button
-                            onClick={() => verifyTwoFAMutation.mutate(twoFACode)}
-                            disabled={verifyTwoFAMutation.isPending || twoFACode.length !== 6}
-                            className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
-                          >
-                            {verifyTwoFAMutation.isPending ? 'Verifying...' : 'Verify & Enable'}
-                          </button>
-                        </div>
-                      </div>
-                    </div>
-
-                    <button
-                      onClick={() => { setTwoFASetup(null); setTwoFACode('') }}
-                      className="mt-4 text-[12px] text-blue-600 hover:text-blue-800"
-                    >
-                      Cancel setup
-                    </button>
-                  </div>
-                ) : (
-                  <div className="bg-gray-50 dark:bg-gray-700 border border-[#a0a0a0] p-3">
-                    <div className="flex items-center mb-3">
-                      <svg className="w-5 h-5 text-gray-400 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                        <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M12 15v2m-6 4h12a2 2 0 002-2v-6a2 2 0 00-2-2H6a2 2 0 00-2 2v6a2 2 0 002 2zm10-10V7a4 4 0 00-8 0v4h8z" />
-                      </svg>
-                      <span className="font-medium text-gray-700 dark:text-gray-300 dark:text-gray-400">2FA is not enabled</span>
-                    </div>
-                    <p className="text-[12px] text-gray-600 mb-3">
-                      Add an extra layer of security to your account by enabling two-factor authentication.
-                      You'll need an authenticator app like Google Authenticator or Authy.
-                    </p>
-                    <button
-                      onClick={() => setupTwoFAMutation.mutate()}
-                      disabled={setupTwoFAMutation.isPending}
-                      className="px-4 py-2 text-[12px] font-medium text-white bg-blue-600 hover:bg-blue-700 disabled:opacity-50"
-                    >
-                      {setupTwoFAMutation.isPending ? 'Setting up...' : 'Enable 2FA'}
-                    </button>
-                  </div>
-                )}
+                <TwoFactorSettings />
               </div>
             </div>
           ) : activeTab === 'notifications' ? (
@@ -1666,6 +1514,8 @@ export default function Settings() {
                 </div>
               </div>
 
+              <SMSGatewaysPanel />
+
               {/* WhatsApp Settings */}
               <div className="card p-3">
                 <h3 className="text-[13px] font-semibold text-gray-900 dark:text-white mb-3">WhatsApp Notifications</h3>
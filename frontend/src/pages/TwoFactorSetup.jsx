@@ -0,0 +1,41 @@
+import { useQuery } from '@tanstack/react-query'
+import { useNavigate } from 'react-router-dom'
+import { useAuthStore } from '../store/authStore'
+import api from '../services/api'
+import TwoFactorSettings from '../components/TwoFactorSettings'
+
+export default function TwoFactorSetup() {
+  const navigate = useNavigate()
+  const { logout } = useAuthStore()
+
+  const { data: status } = useQuery({
+    queryKey: ['2fa-status'],
+    queryFn: () => api.get('/auth/2fa/status').then(res => res.data.data),
+  })
+
+  return (
+    <div className="min-h-screen bg-[#c0c0c0] flex items-center justify-center p-2" style={{ fontFamily: "'Segoe UI', Tahoma, Geneva, Verdana, sans-serif", fontSize: 11 }}>
+      <div className="w-full max-w-2xl">
+        <div className="card">
+          {/* Header */}
+          <div className="modal-header">
+            <span>Set Up Two-Factor Authentication</span>
+          </div>
+
+          <div className="p-2 bg-[#f0f0f0]">
+            <TwoFactorSettings />
+
+            <div className="pt-2 mt-3 border-t border-[#a0a0a0] flex justify-between">
+              <button onClick={() => { logout(); navigate('/login') }} className="btn">
+                Log out
+              </button>
+              <button onClick={() => navigate('/')} disabled={!status?.enabled} className="btn btn-primary">
+                Continue
+              </button>
+            </div>
+          </div>
+        </div>
+      </div>
+    </div>
+  )
+}
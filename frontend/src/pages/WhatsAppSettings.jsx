@@ -201,7 +201,7 @@ export default function WhatsAppSettings() {
         subscriber_ids: sendAll ? [] : selectedIDs,
       })
       if (res.data.success) {
-        toast.success(`Sent to ${res.data.sent} subscribers${res.data.failed > 0 ? `, ${res.data.failed} failed` : ''}`)
+        toast.success(`Queued for ${res.data.queued} subscribers${res.data.failed > 0 ? `, ${res.data.failed} failed` : ''}`)
         if (res.data.failed === 0) {
           setMessage('')
           setSelectedIDs([])
@@ -38,22 +38,43 @@ const parseJwt = (token) => {
   }
 }
 
-// Check if token needs refresh (within 1 hour of expiry)
+// Check if the access token needs refresh (expired or within 1 minute of expiry)
 const shouldRefreshToken = (token) => {
   if (!token) return false
   const payload = parseJwt(token)
   if (!payload || !payload.exp) return false
   const expiresAt = payload.exp * 1000 // Convert to milliseconds
-  const now = Date.now()
-  const oneHour = 60 * 60 * 1000
-  return expiresAt - now < oneHour && expiresAt > now
+  return expiresAt - Date.now() < 60 * 1000
+}
+
+// Exchange the refresh token for a new access token + rotated refresh token.
+// Returns the access token to use, or null if the session is gone.
+const refreshSession = async (storage, key, parsed) => {
+  try {
+    const response = await axios.post('/api/auth/refresh', { refresh_token: parsed.refreshToken })
+    if (response.data.success && response.data.token) {
+      parsed.token = response.data.token
+      parsed.refreshToken = response.data.refresh_token
+      storage.setItem(key, JSON.stringify(parsed))
+      return response.data.token
+    }
+  } catch (err) {
+    if (err.response?.status === 409) {
+      // Another tab rotated the token first and stored the result
+      try {
+        const latest = JSON.parse(storage.getItem(key) || '{}')
+        if (latest.token && latest.token !== parsed.token) return latest.token
+      } catch (e) { /* ignore */ }
+    }
+  }
+  return null
 }
 
 // Request interceptor - auto refresh token if needed
 api.interceptors.request.use(
   async (config) => {
-    // Skip refresh for auth endpoints
-    if (config.url?.includes('/auth/')) {
+    // Skip refresh for the endpoints that issue tokens
+    if (['/auth/login', '/auth/refresh', '/auth/impersonate-exchange'].some((path) => config.url?.includes(path))) {
       return config
     }
 
@@ -61,39 +82,24 @@ api.interceptors.request.use(
     const impersonateData = sessionStorage.getItem('proisp-impersonate')
     const authData = impersonateData || localStorage.getItem('proisp-auth')
     if (!authData) return config
+    const storage = impersonateData ? sessionStorage : localStorage
+    const storageKey = impersonateData ? 'proisp-impersonate' : 'proisp-auth'
 
     try {
       const parsed = JSON.parse(authData)
       const token = parsed?.token
 
-      if (token && shouldRefreshToken(token)) {
+      if (token && parsed.refreshToken && shouldRefreshToken(token)) {
         if (!isRefreshing) {
           isRefreshing = true
           try {
-            const response = await axios.post('/api/auth/refresh', null, {
-              headers: { Authorization: `Bearer ${token}` },
-            })
-            if (response.data.success && response.data.token) {
-              const newToken = response.data.token
-              // Update stored token in the correct storage
-              parsed.token = newToken
-              if (impersonateData) {
-                sessionStorage.setItem('proisp-impersonate', JSON.stringify(parsed))
-              } else {
-                localStorage.setItem('proisp-auth', JSON.stringify(parsed))
-              }
+            const newToken = await refreshSession(storage, storageKey, parsed)
+            const useToken = newToken || token
+            if (newToken) {
               api.defaults.headers.common['Authorization'] = `Bearer ${newToken}`
-              config.headers['Authorization'] = `Bearer ${newToken}`
-              onRefreshed(newToken)
-            } else {
-              // Refresh didn't return new token, use old token
-              config.headers['Authorization'] = `Bearer ${token}`
-              onRefreshed(token)
             }
-          } catch (err) {
-            // Refresh failed, continue with old token and notify subscribers
-            config.headers['Authorization'] = `Bearer ${token}`
-            onRefreshed(token)
+            config.headers['Authorization'] = `Bearer ${useToken}`
+            onRefreshed(useToken)
           } finally {
             isRefreshing = false
           }
@@ -136,7 +142,7 @@ api.interceptors.response.use(
     // Handle 401 Unauthorized - token expired or invalid
     // Skip for auth/login endpoints where 401 is expected (wrong credentials)
     const requestUrl = error.config?.url || ''
-    if (error.response?.status === 401 && !requestUrl.includes('/auth/') && !requestUrl.includes('/customer/login')) {
+    if (error.response?.status === 401 && !requestUrl.includes('/auth/') && !requestUrl.includes('/customer/login') && !requestUrl.includes('/customer/otp/')) {
       const authData = localStorage.getItem('proisp-auth')
       if (authData) {
         try {
@@ -164,6 +170,9 @@ api.interceptors.response.use(
       } else if (code === 'LICENSE_INVALID') {
         // License is blocked
         error.licenseBlocked = true
+      } else if (code === 'TWO_FACTOR_SETUP_REQUIRED' && window.location.pathname !== '/setup-2fa') {
+        // 2FA policy covers this user and nothing is enrolled yet
+        window.location.href = '/setup-2fa'
       }
     }
 
@@ -260,6 +269,9 @@ export const resellerApi = {
   updateAssignedNAS: (id, nasIds) => api.put(`/resellers/${id}/assigned-nas`, { nas_ids: nasIds }),
   getAssignedServices: (id) => api.get(`/resellers/${id}/assigned-services`),
   updateAssignedServices: (id, services) => api.put(`/resellers/${id}/assigned-services`, { services }),
+  // Customer portal self-service permissions
+  getPortalPermissions: (id) => api.get(`/resellers/${id}/portal-permissions`),
+  updatePortalPermissions: (id, data) => api.put(`/resellers/${id}/portal-permissions`, data),
 }
 
 export const dashboardApi = {
@@ -25,6 +25,7 @@ const loadInitialState = () => {
         return {
           user: data.user || null,
           token: data.token,
+          refreshToken: data.refreshToken || null,
           isAuthenticated: true,
           isCustomer: data.isCustomer || false,
           customerData: data.customerData || null,
@@ -37,6 +38,7 @@ const loadInitialState = () => {
   return {
     user: null,
     token: null,
+    refreshToken: null,
     isAuthenticated: false,
     isCustomer: false,
     customerData: null,
@@ -52,6 +54,7 @@ const saveToStorage = (state) => {
     const data = {
       user: state.user,
       token: state.token,
+      refreshToken: state.refreshToken,
       isCustomer: state.isCustomer,
       customerData: state.customerData,
     }
@@ -67,18 +70,24 @@ export const useAuthStore = create((set, get) => ({
   isImpersonated: isImpersonatedTab,
 
   // Login function
-  login: async (username, password, twoFACode = '') => {
+  // secondFactor is an authenticator app code, or { recovery_code } / { webauthn } for the other methods
+  login: async (username, password, secondFactor = '') => {
     // Try admin/reseller login first
     try {
       const payload = { username, password }
-      if (twoFACode) {
-        payload.two_fa_code = twoFACode
+      if (typeof secondFactor === 'string') {
+        if (secondFactor) {
+          payload.two_fa_code = secondFactor
+        }
+      } else if (secondFactor) {
+        Object.assign(payload, secondFactor)
       }
       const response = await api.post('/auth/login', payload)
       if (response.data.success) {
         const newState = {
           user: response.data.user,
           token: response.data.token,
+          refreshToken: response.data.refresh_token,
           isAuthenticated: true,
           isCustomer: false,
           customerData: null,
@@ -89,16 +98,27 @@ export const useAuthStore = create((set, get) => ({
         return {
           success: true,
           userType: 'admin',
-          force_password_change: response.data.force_password_change || response.data.user?.force_password_change
+          force_password_change: response.data.force_password_change || response.data.user?.force_password_change,
+          two_factor_setup_required: response.data.two_factor_setup_required
         }
       }
       if (response.data.requires_2fa) {
-        return { success: false, requires_2fa: true, message: response.data.message }
+        return {
+          success: false,
+          requires_2fa: true,
+          message: response.data.message,
+          methods: response.data.two_fa_methods || ['totp'],
+          webauthn: response.data.webauthn,
+        }
       }
     } catch (error) {
       if (error.response?.data?.requires_2fa) {
         return { success: false, requires_2fa: true, message: error.response.data.message }
       }
+      if (secondFactor) {
+        // A rejected second factor must not fall through to the customer login
+        return { success: false, message: error.response?.data?.message || 'Invalid 2FA code' }
+      }
     }
 
     // Try customer login
@@ -108,6 +128,7 @@ export const useAuthStore = create((set, get) => ({
         const newState = {
           user: null,
           token: response.data.token,
+          refreshToken: null,
           isAuthenticated: true,
           isCustomer: true,
           customerData: response.data.customer,
@@ -126,10 +147,54 @@ export const useAuthStore = create((set, get) => ({
     }
   },
 
+  // Store a customer session obtained outside the password form (OTP login)
+  completeCustomerLogin: (token, customer) => {
+    const newState = {
+      user: null,
+      token,
+      refreshToken: null,
+      isAuthenticated: true,
+      isCustomer: true,
+      customerData: customer,
+    }
+    set(newState)
+    saveToStorage(newState)
+    api.defaults.headers.common['Authorization'] = `Bearer ${token}`
+  },
+
+  // Complete a single sign-on login using the one-time code from the SSO callback
+  loginWithSSO: async (code) => {
+    try {
+      const response = await api.post('/auth/sso/exchange', { code })
+      if (response.data.success) {
+        const newState = {
+          user: response.data.user,
+          token: response.data.token,
+          refreshToken: response.data.refresh_token,
+          isAuthenticated: true,
+          isCustomer: false,
+          customerData: null,
+        }
+        set(newState)
+        saveToStorage(newState)
+        api.defaults.headers.common['Authorization'] = `Bearer ${response.data.token}`
+        return { success: true }
+      }
+      return { success: false, message: response.data.message }
+    } catch (error) {
+      return { success: false, message: error.response?.data?.message || 'Single sign-on failed' }
+    }
+  },
+
   logout: () => {
+    // End the server-side session; local state is cleared regardless
+    if (get().token && !get().isCustomer) {
+      api.post('/auth/logout').catch(() => {})
+    }
     set({
       user: null,
       token: null,
+      refreshToken: null,
       isAuthenticated: false,
       isCustomer: false,
       customerData: null,
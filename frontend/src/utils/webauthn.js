@@ -0,0 +1,66 @@
+// WebAuthn helpers: the API sends and expects binary values as unpadded base64url
+
+export const webauthnSupported = () =>
+  typeof window !== 'undefined' && !!window.PublicKeyCredential && !!navigator.credentials
+
+const toBuffer = (value) => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4)
+  const binary = atob(padded)
+  const bytes = new Uint8Array(binary.length)
+  for (let i = 0; i < binary.length; i++) bytes[i] = binary.charCodeAt(i)
+  return bytes.buffer
+}
+
+const fromBuffer = (buffer) => {
+  const bytes = new Uint8Array(buffer)
+  let binary = ''
+  for (let i = 0; i < bytes.length; i++) binary += String.fromCharCode(bytes[i])
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+const descriptors = (list) => (list || []).map((c) => ({ ...c, id: toBuffer(c.id) }))
+
+// Register a new security key from /auth/2fa/webauthn/register/begin options
+export const createCredential = async (options) => {
+  const credential = await navigator.credentials.create({
+    publicKey: {
+      ...options,
+      challenge: toBuffer(options.challenge),
+      user: { ...options.user, id: toBuffer(options.user.id) },
+      excludeCredentials: descriptors(options.excludeCredentials),
+    },
+  })
+  return {
+    id: credential.id,
+    raw_id: fromBuffer(credential.rawId),
+    type: credential.type,
+    response: {
+      client_data_json: fromBuffer(credential.response.clientDataJSON),
+      attestation_object: fromBuffer(credential.response.attestationObject),
+    },
+    transports: credential.response.getTransports ? credential.response.getTransports() : [],
+  }
+}
+
+// Sign a login challenge with a registered security key
+export const getAssertion = async (options) => {
+  const credential = await navigator.credentials.get({
+    publicKey: {
+      ...options,
+      challenge: toBuffer(options.challenge),
+      allowCredentials: descriptors(options.allowCredentials),
+    },
+  })
+  return {
+    id: credential.id,
+    raw_id: fromBuffer(credential.rawId),
+    type: credential.type,
+    response: {
+      client_data_json: fromBuffer(credential.response.clientDataJSON),
+      authenticator_data: fromBuffer(credential.response.authenticatorData),
+      signature: fromBuffer(credential.response.signature),
+      user_handle: credential.response.userHandle ? fromBuffer(credential.response.userHandle) : '',
+    },
+  }
+}